@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// archiveFilePattern は、アップロードする日ごとのファイルの名前です。日付は書き込みを終えたかの判定に使用します。
+var archiveFilePattern = regexp.MustCompile(`^monitoring-(\d{4}-\d{2}-\d{2})\.csv$`)
+
+// archiveUploader は、日ごとの監視データの CSV のうち、前日以前のファイルを S3 互換のストレージにアップロードします。
+// アップロードしたかどうかは、ファイルを削除するか (keep_local の場合は <ファイル名>.uploaded を作成して) 記録するため、
+// 再起動しても未完了のファイルから再開します。
+type archiveUploader struct {
+	cfg     config.ArchiveUploadConfig
+	dir     string
+	loc     *time.Location
+	client  *http.Client
+	trigger chan struct{}    // 日付が変わったときにすぐに確認するため
+	now     func() time.Time // テストで差し替えるため
+}
+
+// newArchiveUploader は、設定と CSV のディレクトリを指定して archiveUploader を作成します。日付は loc のタイムゾーンで判定します。
+func newArchiveUploader(cfg config.ArchiveUploadConfig, dir string, loc *time.Location) *archiveUploader {
+	return &archiveUploader{
+		cfg:     cfg,
+		dir:     dir,
+		loc:     loc,
+		client:  &http.Client{Timeout: 5 * time.Minute},
+		trigger: make(chan struct{}, 1),
+		now:     time.Now,
+	}
+}
+
+// rollover は、CSV の日付が変わったことを知らせます。監視ループを止めないよう、待たずに戻ります。
+func (u *archiveUploader) rollover() {
+	select {
+	case u.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// run は、done が閉じられるまで、起動時、日付が変わったとき、retry_interval_minutes ごとにアップロードしていないファイルを確認します。
+func (u *archiveUploader) run(done <-chan struct{}) {
+	ticker := time.NewTicker(time.Duration(u.cfg.RetryIntervalMinutes) * time.Minute)
+	defer ticker.Stop()
+	for {
+		u.uploadPending()
+		select {
+		case <-done:
+			return
+		case <-u.trigger:
+		case <-ticker.C:
+		}
+	}
+}
+
+// uploadPending は、前日以前のアップロードしていないファイルを古い順にアップロードします。失敗したファイルは次の確認で再試行します。
+func (u *archiveUploader) uploadPending() {
+	names, err := u.pending()
+	if err != nil {
+		log.Printf("[アーカイブ] %v", err)
+		return
+	}
+	for _, name := range names {
+		if err := u.upload(name); err != nil {
+			log.Printf("[アーカイブ] '%s' のアップロードに失敗しました。%d 分後に再試行します: %v", name, u.cfg.RetryIntervalMinutes, err)
+			continue
+		}
+		path := filepath.Join(u.dir, name)
+		if u.cfg.KeepLocal {
+			err = os.WriteFile(path+".uploaded", nil, 0o644)
+		} else {
+			err = os.Remove(path)
+		}
+		if err != nil {
+			log.Printf("[アーカイブ] '%s' のアップロードを記録できませんでした: %v", path, err)
+		}
+		log.Printf("[アーカイブ] '%s' を s3://%s/%s%s にアップロードしました。", name, u.cfg.Bucket, u.cfg.Prefix, name)
+	}
+}
+
+// pending は、アップロードしていない前日以前のファイルの名前を古い順に返します。
+func (u *archiveUploader) pending() ([]string, error) {
+	entries, err := os.ReadDir(u.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil // まだ CSV を書き込んでいない
+		}
+		return nil, fmt.Errorf("ディレクトリ '%s' を読み込めませんでした: %w", u.dir, err)
+	}
+	today := u.now().In(u.loc).Format("2006-01-02")
+	var names []string
+	for _, e := range entries {
+		match := archiveFilePattern.FindStringSubmatch(e.Name())
+		if match == nil || e.IsDir() || match[1] >= today {
+			continue // 当日のファイルはまだ書き込み中
+		}
+		if _, err := os.Stat(filepath.Join(u.dir, e.Name()+".uploaded")); err == nil {
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// upload は、ファイルを PUT Object でアップロードします。認証には AWS Signature Version 4 を使用します。
+func (u *archiveUploader) upload(name string) error {
+	body, err := os.ReadFile(filepath.Join(u.dir, name))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, u.cfg.Endpoint+"/"+u.cfg.Bucket+"/"+s3EscapePath(u.cfg.Prefix+name), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/csv; charset=utf-8")
+	signS3Request(req, body, u.cfg.Region, u.cfg.AccessKeyID, string(u.cfg.SecretAccessKey), u.now().UTC())
+	res, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("ステータス %d: %s", res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// s3EscapePath は、オブジェクトのキーを URL のパスとしてエスケープします。区切りの '/' はそのままにします。
+func s3EscapePath(key string) string {
+	parts := strings.Split(key, "/")
+	for i, p := range parts {
+		parts[i] = s3Escape(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+// s3Escape は、Signature Version 4 の規則 (RFC 3986 の非予約文字以外をエスケープ) で文字列をエスケープします。
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// signS3Request は、リクエストに AWS Signature Version 4 の Authorization ヘッダーを設定します。
+// 署名するヘッダーは host、x-amz-content-sha256、x-amz-date です。
+func signS3Request(req *http.Request, body []byte, region, accessKeyID, secretAccessKey string, now time.Time) {
+	payloadHash := sha256.Sum256(body)
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + hex.EncodeToString(payloadHash[:]) + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + secretAccessKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKeyID, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 は、key で data の HMAC-SHA256 を計算します。
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+func TestArchiveUploader(t *testing.T) {
+	var mu sync.Mutex
+	uploaded := map[string]string{}
+	fail := map[string]bool{"/bucket/eibs7/monitoring-2025-05-31.csv": true}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sum := sha256.Sum256(body)
+		date := r.Header.Get("X-Amz-Date")
+		if r.Method != http.MethodPut || r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) || !strings.HasSuffix(date, "T001000Z") {
+			t.Errorf("request = %s %s %v", r.Method, r.URL.Path, r.Header)
+		}
+		if auth := r.Header.Get("Authorization"); !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=id/"+date[:8]+"/ap-northeast-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=") {
+			t.Errorf("Authorization = %q", auth)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if fail[r.URL.Path] {
+			delete(fail, r.URL.Path) // fail only the first attempt
+			http.Error(w, "<Error><Code>SlowDown</Code></Error>", http.StatusServiceUnavailable)
+			return
+		}
+		uploaded[r.URL.Path] = string(body)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	for _, name := range []string{"monitoring-2025-05-30.csv", "monitoring-2025-05-31.csv", "monitoring-2025-06-01.csv", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := config.ArchiveUploadConfig{Enabled: true, Endpoint: srv.URL, Region: "ap-northeast-1", Bucket: "bucket", Prefix: "eibs7/", AccessKeyID: "id", SecretAccessKey: "key"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	u := newArchiveUploader(cfg, dir, time.UTC)
+	u.now = func() time.Time { return time.Date(2025, 6, 1, 0, 10, 0, 0, time.UTC) }
+
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+
+	// The failed upload stays on disk; today's file is still being written.
+	u.uploadPending()
+	if uploaded["/bucket/eibs7/monitoring-2025-05-30.csv"] != "monitoring-2025-05-30.csv" || len(uploaded) != 1 {
+		t.Errorf("uploaded = %v", uploaded)
+	}
+	if exists("monitoring-2025-05-30.csv") || !exists("monitoring-2025-05-31.csv") || !exists("monitoring-2025-06-01.csv") || !exists("notes.txt") {
+		t.Error("only the uploaded file should be removed")
+	}
+
+	// The next check retries it.
+	u.uploadPending()
+	if len(uploaded) != 2 || exists("monitoring-2025-05-31.csv") {
+		t.Errorf("retry: uploaded = %v", uploaded)
+	}
+
+	// keep_local marks uploaded files instead of removing them.
+	u.cfg.KeepLocal = true
+	u.now = func() time.Time { return time.Date(2025, 6, 2, 0, 10, 0, 0, time.UTC) }
+	u.uploadPending()
+	if len(uploaded) != 3 || !exists("monitoring-2025-06-01.csv") || !exists("monitoring-2025-06-01.csv.uploaded") {
+		t.Errorf("keep_local: uploaded = %v", uploaded)
+	}
+	uploaded = map[string]string{}
+	if names, err := u.pending(); err != nil || len(names) != 0 {
+		t.Errorf("pending after upload = %v, %v", names, err)
+	}
+}
+
+func TestSignS3Request(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPut, "https://s3.example.com/bucket/"+s3EscapePath("a b/c+d.csv"), strings.NewReader("x"))
+	if req.URL.EscapedPath() != "/bucket/a%20b/c%2Bd.csv" {
+		t.Errorf("path = %s", req.URL.EscapedPath())
+	}
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	signS3Request(req, []byte("x"), "us-east-1", "id", "key", now)
+	first := req.Header.Get("Authorization")
+	signS3Request(req, []byte("y"), "us-east-1", "id", "key", now)
+	if first == req.Header.Get("Authorization") {
+		t.Error("the signature does not cover the payload")
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+// backtest で機器の代わりに応答する IP アドレス (target_id で機器を指定している場合に使用)
+const backtestTargetIP = "192.0.2.1"
+
+// readBacktestCSV は、監視データの CSV (monitoring_csv_dir に出力したファイル) から記録を読み込みます。
+func readBacktestCSV(r io.Reader) ([]monitor.BacktestSample, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("見出し行を読み込めませんでした: %w", err)
+	}
+	if len(header) == 0 || header[0] != "時刻" {
+		return nil, errors.New("監視データの CSV ではありません (1列目が '時刻' ではありません)")
+	}
+	var samples []monitor.BacktestSample
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return samples, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(time.RFC3339, record[0])
+		if err != nil {
+			return nil, fmt.Errorf("%d 行目の時刻が不正です: %w", line, err)
+		}
+		s := monitor.BacktestSample{Time: t, Values: make(map[string]string, len(header)-1)}
+		for i, name := range header[1:] {
+			if i+1 < len(record) && record[i+1] != "" {
+				s.Values[name] = record[i+1]
+			}
+		}
+		samples = append(samples, s)
+	}
+}
+
+// readBacktestHistory は、履歴のデータベース ([history] の file) から from 以上 to 未満の記録を読み込みます。
+// from と to がゼロ値の場合は、その側の期間を制限しません。データベースは読み取り専用で開きます。
+func readBacktestHistory(path string, from, to time.Time) ([]monitor.BacktestSample, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	fromMs, toMs := int64(math.MinInt64), int64(math.MaxInt64)
+	if !from.IsZero() {
+		fromMs = from.UnixMilli()
+	}
+	if !to.IsZero() {
+		toMs = to.UnixMilli()
+	}
+	rows, err := db.Query(`SELECT c.id, c.time, m.name, m.value, m.text
+		FROM cycles c JOIN measurements m ON m.cycle_id = c.id
+		WHERE c.time >= ? AND c.time < ?
+		ORDER BY c.time, c.id`, fromMs, toMs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var samples []monitor.BacktestSample
+	lastID := int64(-1)
+	for rows.Next() {
+		var id, ms int64
+		var name string
+		var value sql.NullFloat64
+		var text sql.NullString
+		if err := rows.Scan(&id, &ms, &name, &value, &text); err != nil {
+			return nil, err
+		}
+		if id != lastID {
+			samples = append(samples, monitor.BacktestSample{Time: time.UnixMilli(ms), Values: make(map[string]string)})
+			lastID = id
+		}
+		switch {
+		case value.Valid:
+			samples[len(samples)-1].Values[name] = monitor.FormatCSVValue(value.Float64)
+		case text.Valid:
+			samples[len(samples)-1].Values[name] = text.String
+		}
+	}
+	return samples, rows.Err()
+}
+
+// loadBacktestSamples は、files (拡張子 .csv は監視データの CSV、それ以外は履歴のデータベース) から
+// from 以上 to 未満の記録を読み込み、時刻順に並べて返します。
+func loadBacktestSamples(files []string, from, to time.Time) ([]monitor.BacktestSample, error) {
+	var samples []monitor.BacktestSample
+	for _, path := range files {
+		var s []monitor.BacktestSample
+		var err error
+		if strings.EqualFold(filepath.Ext(path), ".csv") {
+			var f *os.File
+			if f, err = os.Open(path); err == nil {
+				s, err = readBacktestCSV(f)
+				f.Close()
+			}
+		} else {
+			s, err = readBacktestHistory(path, from, to)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("記録 '%s' を読み込めませんでした: %w", path, err)
+		}
+		for _, sample := range s {
+			if (from.IsZero() || !sample.Time.Before(from)) && (to.IsZero() || sample.Time.Before(to)) {
+				samples = append(samples, sample)
+			}
+		}
+	}
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Time.Before(samples[j].Time) })
+	return samples, nil
+}
+
+// backtestConfig は、backtest で使用しない機能を無効にします。
+// 予測や警報の取得などの外部との通信、ファイルへの記録、通知による監視は行わず、SetC は常に模擬機器に送信します。
+func backtestConfig(cfg *config.Config) {
+	if cfg.TargetID != "" {
+		cfg.TargetID = ""
+		if cfg.TargetIP == "" {
+			cfg.TargetIP = backtestTargetIP
+		}
+	}
+	cfg.EveningReserve.Enabled = false
+	cfg.ChargePlanning.Enabled = false
+	cfg.StormAlert.Enabled = false
+	cfg.OpenADR.Enabled = false
+	cfg.Alerts.Enabled = false
+	cfg.LogMonitoringData = false
+	cfg.NotificationMode = false
+	cfg.EnableControl = nil
+	cfg.DryRun = false
+	cfg.RequestRetries = 0
+}
+
+// writeBacktestReport は、制御が送信した SetC の一覧と集計を w に出力します。
+func writeBacktestReport(w io.Writer, r monitor.BacktestReport, loc *time.Location) {
+	fmt.Fprintf(w, "送信する SetC (%d 件):\n", len(r.Commands))
+	for _, c := range r.Commands {
+		fmt.Fprintf(w, "  %s  %s  %02X%02X%02X  %s\n", c.Time.In(loc).Format(time.RFC3339), c.IP,
+			c.DEOJ.ClassGroupCode, c.DEOJ.ClassCode, c.DEOJ.InstanceCode, formatPropertyLine(c.DEOJ, c.Property))
+	}
+	fmt.Fprintf(w, "期間: %s 〜 %s (監視サイクル: %d 回)\n", r.From.In(loc).Format(time.RFC3339), r.To.In(loc).Format(time.RFC3339), r.Cycles)
+	fmt.Fprintf(w, "系統からの充電電力量: %.2f kWh (記録: %.2f kWh)\n", r.GridChargeKWh, r.RecordedKWh)
+	if r.Priced {
+		fmt.Fprintf(w, "推定電気代: %.0f 円 (記録: %.0f 円)\n", r.Cost, r.RecordedCost)
+	} else {
+		fmt.Fprintln(w, "推定電気代: 単価が不明です (-price または [price_schedule] を指定してください)")
+	}
+}
+
+// parseBacktestTime は、-from / -to の時刻を解釈します。RFC 3339、"2006-01-02T15:04"、"2006-01-02" の形式を受け付け、
+// タイムゾーンのない時刻は loc のタイムゾーンで解釈します。dateOnly は日付だけを指定したかどうかです。
+func parseBacktestTime(s string, loc *time.Location) (t time.Time, dateOnly bool, err error) {
+	if s == "" {
+		return time.Time{}, false, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04", s, loc); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		return t, true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("時刻の形式が不正です: '%s'", s)
+}
+
+// runBacktest は、backtest サブコマンドを実行し、終了コードを返します。
+// 記録した監視データを模擬機器から応答させて制御に入力し、設定の変更を実機で試す前に制御の結果を確認できるようにします。
+func runBacktest(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("backtest", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configFile := fs.String("config", "", "設定ファイルのパスを指定します。未指定の場合は監視と同じ順に config.toml を探します。")
+	fromFlag := fs.String("from", "", "この時刻以降の記録を使用します (例: 2026-10-01, 2026-10-01T18:00)。")
+	toFlag := fs.String("to", "", "この時刻より前の記録を使用します。日付のみの場合はその日の終わりまでです。")
+	price := fs.Float64("price", 0, "系統からの充電の単価 (円/kWh) を指定します。[price_schedule] が有効な場合は単価表にないコマに使用します。")
+	verbose := fs.Bool("v", false, "監視サイクルのログを標準エラー出力に出力します。")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "使い方: %s backtest [オプション] [記録ファイル...]\n\n", os.Args[0])
+		fmt.Fprintln(stderr, "記録ファイルは監視データの CSV (.csv) または履歴のデータベースです。未指定の場合は [history] の file を使用します。")
+		fmt.Fprintln(stderr, "\nオプション:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	// 監視サイクルのログは通常は不要なため、-v を指定した場合のみ出力する
+	prev := log.Writer()
+	if *verbose {
+		log.SetOutput(stderr)
+	} else {
+		log.SetOutput(io.Discard)
+	}
+	defer log.SetOutput(prev)
+
+	path := *configFile
+	if path == "" {
+		found, err := findConfigFile(configSearchPaths())
+		if err != nil {
+			fmt.Fprintf(stderr, "エラー: %v\n", err)
+			return 1
+		}
+		path = found
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "エラー: 設定ファイル '%s' の読み込みに失敗しました: %v\n", path, err)
+		return 1
+	}
+	from, _, err := parseBacktestTime(*fromFlag, cfg.Loc())
+	if err != nil {
+		fmt.Fprintf(stderr, "エラー: -from: %v\n", err)
+		return 2
+	}
+	to, dateOnly, err := parseBacktestTime(*toFlag, cfg.Loc())
+	if err != nil {
+		fmt.Fprintf(stderr, "エラー: -to: %v\n", err)
+		return 2
+	}
+	if dateOnly {
+		to = to.AddDate(0, 0, 1)
+	}
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{cfg.History.File}
+	}
+	samples, err := loadBacktestSamples(files, from, to)
+	if err != nil {
+		fmt.Fprintf(stderr, "エラー: %v\n", err)
+		return 1
+	}
+	if len(samples) == 0 {
+		fmt.Fprintln(stderr, "エラー: 期間内の記録がありません")
+		return 1
+	}
+
+	backtestConfig(cfg)
+	writeBacktestReport(stdout, monitor.Backtest(cfg, samples, *price), cfg.Loc())
+	return 0
+}
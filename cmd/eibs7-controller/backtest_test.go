@@ -0,0 +1,147 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+// backtestTestConfig returns a configuration that charges from the grid between 00:00 and 06:00.
+func backtestTestConfig() *config.Config {
+	return &config.Config{
+		TargetIP:                         "192.168.0.10",
+		MonitorIntervalSeconds:           60,
+		ChargeStartTime:                  "00:00",
+		ChargeEndTime:                    "06:00",
+		ChargeTargetSOCPercent:           100,
+		ChargePowerUpdateIntervalMinutes: 10,
+		AutoModeThresholdWatts:           100,
+		ModeChangeInhibitMinutes:         10,
+		MinSurplusPowerJudgmentMinutes:   10,
+		SurplusPowerMarginWatts:          500,
+		MaxChargePowerWatts:              2000,
+		LivenessCheckIntervalSeconds:     60,
+		UnreachableFailureThreshold:      3,
+		ResponseTimeoutMilliseconds:      1000,
+		ReceiveBufferSize:                config.DefaultReceiveBufferSize,
+		Controller:                       config.DefaultControllerEOJ,
+	}
+}
+
+const backtestTestCSV = `時刻,蓄電池 (027D01).蓄電残量3,蓄電池 (027D01).運転モード設定,蓄電池 (027D01).充電電力設定値,蓄電池 (027D01).瞬時充放電電力計測値,住宅用太陽光発電 (027901).瞬時発電電力計測値,分電盤メータリング (028701).瞬時電力計測値,マルチ入力PCS (02A501).瞬時電力計測値,蓄電池 (027D01).AC実効容量（充電）,計算値.余剰電力
+2026-01-05T00:59:00+09:00,50,70,0,0,3000,500,0,10000,2500
+2026-01-05T01:00:00+09:00,50,70,0,0,1000,500,0,10000,500
+2026-01-05T01:01:00+09:00,50,70,0,0,1000,500,0,10000,500
+2026-01-05T01:02:00+09:00,50,70,0,0,1000,500,0,10000,500
+`
+
+func TestLoadBacktestSamplesFromCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "monitoring-2026-01-05.csv")
+	if err := os.WriteFile(path, []byte(backtestTestCSV), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2026, 1, 5, 1, 0, 0, 0, time.FixedZone("JST", 9*60*60))
+	samples, err := loadBacktestSamples([]string{path}, from, time.Time{})
+	if err != nil {
+		t.Fatalf("loadBacktestSamples: %v", err)
+	}
+	if len(samples) != 3 || !samples[0].Time.Equal(from) {
+		t.Fatalf("samples = %+v, want 3 samples from %s", samples, from)
+	}
+	if got := samples[0].Values["蓄電池 (027D01).蓄電残量3"]; got != "50" {
+		t.Errorf("SOC = %q, want 50", got)
+	}
+
+	if _, err := readBacktestCSV(strings.NewReader("time,a\n")); err == nil {
+		t.Error("readBacktestCSV accepted a file without the 時刻 column")
+	}
+}
+
+func TestReadBacktestHistory(t *testing.T) {
+	cfg := config.HistoryConfig{File: filepath.Join(t.TempDir(), "history.db")}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	h, err := openHistoryStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 1, 5, 1, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		r := &monitor.AuditRecord{
+			Time:         start.Add(time.Duration(i) * time.Minute),
+			Decision:     monitor.AuditStrategy,
+			Measurements: map[string]interface{}{"蓄電池 (027D01).蓄電残量3": uint8(50 + i), "蓄電池 (027D01).運転動作状態": monitor.WorkingStatus(0x42)},
+		}
+		if err := h.insert(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.close()
+
+	samples, err := readBacktestHistory(cfg.File, start.Add(time.Minute), time.Time{})
+	if err != nil {
+		t.Fatalf("readBacktestHistory: %v", err)
+	}
+	if len(samples) != 2 || !samples[0].Time.Equal(start.Add(time.Minute)) {
+		t.Fatalf("samples = %+v, want 2 samples from %s", samples, start.Add(time.Minute))
+	}
+	if got := samples[1].Values["蓄電池 (027D01).蓄電残量3"]; got != "52" {
+		t.Errorf("SOC = %q, want 52", got)
+	}
+	if got := samples[1].Values["蓄電池 (027D01).運転動作状態"]; got != monitor.WorkingStatus(0x42).String() {
+		t.Errorf("working status = %q, want %q", got, monitor.WorkingStatus(0x42).String())
+	}
+}
+
+func TestRunBacktestSamplesChargesInWindow(t *testing.T) {
+	samples, err := readBacktestCSV(strings.NewReader(backtestTestCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := backtestTestConfig()
+	cfg.Location = time.FixedZone("JST", 9*60*60)
+	backtestConfig(cfg)
+
+	rep := monitor.Backtest(cfg, samples, 30)
+
+	if rep.Cycles != 4 {
+		t.Errorf("cycles = %d, want 4", rep.Cycles)
+	}
+	var mode, power bool
+	for _, c := range rep.Commands {
+		switch c.Property.EPC {
+		case 0xDA:
+			mode = mode || c.Property.EDT[0] == 0x42
+		case 0xEB:
+			power = true
+		}
+	}
+	if !mode || !power {
+		t.Fatalf("commands = %+v, want charge mode and charge power", rep.Commands)
+	}
+	// The recorded battery was idle, so only the simulated control charged from the grid.
+	if rep.GridChargeKWh <= 0 || rep.RecordedKWh != 0 {
+		t.Errorf("grid charge = %.3f kWh (recorded %.3f kWh), want simulated > 0 and recorded 0", rep.GridChargeKWh, rep.RecordedKWh)
+	}
+	if !rep.Priced || rep.Cost != rep.GridChargeKWh*30 {
+		t.Errorf("cost = %.2f (priced %t), want %.2f", rep.Cost, rep.Priced, rep.GridChargeKWh*30)
+	}
+	// The clock follows the recorded samples, not the wall clock.
+	if !rep.Commands[0].Time.Equal(samples[0].Time) {
+		t.Errorf("first command at %s, want %s", rep.Commands[0].Time, samples[0].Time)
+	}
+
+	var out strings.Builder
+	writeBacktestReport(&out, rep, cfg.Loc())
+	for _, want := range []string{"送信する SetC", "192.168.0.10  027D01  DA (運転モード設定): 66 [42]", "監視サイクル: 4 回", "推定電気代: "} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report is missing %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+)
+
+// 送受信の方向
+type captureDirection int
+
+const (
+	captureInbound  captureDirection = 1 // 受信
+	captureOutbound captureDirection = 2 // 送信
+)
+
+// pcapng 形式および付与する IPv4/UDP ヘッダの定数
+const (
+	pcapngSectionHeaderBlock    = 0x0A0D0D0A
+	pcapngInterfaceDescription  = 0x00000001
+	pcapngEnhancedPacketBlock   = 0x00000006
+	pcapngByteOrderMagic        = 0x1A2B3C4D
+	pcapngLinkTypeRaw           = 101 // LINKTYPE_RAW (IPv4/IPv6 ヘッダから始まるパケット)
+	pcapngOptionEndOfOpt        = 0
+	pcapngOptionEPBFlags        = 2
+	pcapngSnapLen               = 65535
+	pcapngIPv4HeaderLength      = 20
+	pcapngUDPHeaderLength       = 8
+	pcapngIPv4ProtocolUDP       = 17
+	pcapngIPv4DefaultTimeToLive = 64
+)
+
+// 送受信データのキャプチャ (無効の場合は何もしない)
+var capture = newFrameCapture("")
+
+// frameCapture は、送受信した ECHONET Lite データグラムを IPv4/UDP ヘッダを付与して pcapng 形式で書き出します。
+// Wireshark などで ECHONET Lite として解析できます。
+type frameCapture struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// newFrameCapture は、出力先ファイルを指定して frameCapture を作成します。キャプチャは停止状態で作成されます。
+func newFrameCapture(path string) *frameCapture {
+	return &frameCapture{path: path}
+}
+
+// start は、キャプチャを開始します。既存のファイルには新しいセクションとして追記します。
+func (c *frameCapture) start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file != nil {
+		return nil
+	}
+	if c.path == "" {
+		return fmt.Errorf("キャプチャの出力先ファイルが設定されていません")
+	}
+	f, err := os.OpenFile(c.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("キャプチャファイル '%s' を開けませんでした: %w", c.path, err)
+	}
+	if _, err := f.Write(append(pcapngSectionHeader(), pcapngInterfaceDescriptionBlock()...)); err != nil {
+		f.Close()
+		return fmt.Errorf("キャプチャファイル '%s' への書き込みに失敗しました: %w", c.path, err)
+	}
+	c.file = f
+	return nil
+}
+
+// stop は、キャプチャを停止してファイルを閉じます。
+func (c *frameCapture) stop() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
+
+// toggle は、キャプチャの開始と停止を切り替えます。
+func (c *frameCapture) toggle() {
+	if c.active() {
+		if err := c.stop(); err != nil {
+			log.Printf("[キャプチャ] キャプチャファイルのクローズに失敗しました: %v", err)
+		}
+		log.Printf("[キャプチャ] キャプチャを停止しました (%s)", c.path)
+		return
+	}
+	if err := c.start(); err != nil {
+		log.Printf("[キャプチャ] キャプチャを開始できませんでした: %v", err)
+		return
+	}
+	log.Printf("[キャプチャ] キャプチャを開始しました (%s)", c.path)
+}
+
+// active は、キャプチャ中であれば true を返します。
+func (c *frameCapture) active() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.file != nil
+}
+
+// record は、送受信したデータグラムを1パケットとして書き出します。キャプチャ停止中は何もしません。
+// IPv4 以外のアドレスの場合は記録しません。
+func (c *frameCapture) record(dir captureDirection, local, remote *net.UDPAddr, payload []byte, ts time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil || local == nil || remote == nil {
+		return
+	}
+
+	src, dst := local, remote
+	if dir == captureInbound {
+		src, dst = remote, local
+	}
+	packet, err := buildIPv4UDPPacket(src, dst, payload)
+	if err != nil {
+		debugf("[キャプチャ] パケットを記録できませんでした: %v", err)
+		return
+	}
+	if _, err := c.file.Write(pcapngEnhancedPacket(packet, dir, ts)); err != nil {
+		log.Printf("[キャプチャ] キャプチャファイルへの書き込みに失敗しました。キャプチャを停止します: %v", err)
+		c.file.Close()
+		c.file = nil
+	}
+}
+
+// watchCaptureToggleSignal は、SIGUSR2 を受信するたびにキャプチャの開始と停止を切り替えます。
+func watchCaptureToggleSignal(c *frameCapture) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGUSR2)
+	go func() {
+		for range sigCh {
+			c.toggle()
+		}
+	}()
+}
+
+// captureLocalAddr は、remote 宛ての通信で使用されるローカルアドレスを返します。
+// 0.0.0.0 にバインドしたソケットでは実際の送信元アドレスがわからないため、経路から推定します。
+func captureLocalAddr(remote *net.UDPAddr) *net.UDPAddr {
+	addr := &net.UDPAddr{IP: net.IPv4zero, Port: echonetlite.Port}
+	if probe, err := net.DialUDP("udp4", nil, remote); err == nil {
+		if la, ok := probe.LocalAddr().(*net.UDPAddr); ok {
+			addr.IP = la.IP
+		}
+		probe.Close()
+	}
+	return addr
+}
+
+// capturingTransport は、送受信したデータグラムを frameCapture に記録する Transport です。
+type capturingTransport struct {
+	echonetlite.Transport
+	capture *frameCapture
+}
+
+// Send は、データグラムを送信し、キャプチャ中であれば記録します。
+func (t *capturingTransport) Send(data []byte, addr *net.UDPAddr) error {
+	if err := t.Transport.Send(data, addr); err != nil {
+		return err
+	}
+	if t.capture.active() {
+		t.capture.record(captureOutbound, captureLocalAddr(addr), addr, data, time.Now())
+	}
+	return nil
+}
+
+// Receive は、データグラムを受信し、キャプチャ中であれば記録します。
+func (t *capturingTransport) Receive(buf []byte, deadline time.Time) (int, *net.UDPAddr, error) {
+	n, addr, err := t.Transport.Receive(buf, deadline)
+	if err == nil && t.capture.active() {
+		t.capture.record(captureInbound, captureLocalAddr(addr), addr, buf[:n], time.Now())
+	}
+	return n, addr, err
+}
+
+// buildIPv4UDPPacket は、ペイロードに IPv4 ヘッダと UDP ヘッダを付与したパケットを作成します。
+func buildIPv4UDPPacket(src, dst *net.UDPAddr, payload []byte) ([]byte, error) {
+	srcIP, dstIP := src.IP.To4(), dst.IP.To4()
+	if srcIP == nil || dstIP == nil {
+		return nil, fmt.Errorf("IPv4 以外のアドレスには対応していません (%s -> %s)", src, dst)
+	}
+	totalLength := pcapngIPv4HeaderLength + pcapngUDPHeaderLength + len(payload)
+	if totalLength > 0xFFFF {
+		return nil, fmt.Errorf("ペイロードが大きすぎます (%d バイト)", len(payload))
+	}
+
+	packet := make([]byte, totalLength)
+	ip := packet[:pcapngIPv4HeaderLength]
+	ip[0] = 0x45 // バージョン 4, ヘッダ長 20 バイト
+	binary.BigEndian.PutUint16(ip[2:4], uint16(totalLength))
+	ip[8] = pcapngIPv4DefaultTimeToLive
+	ip[9] = pcapngIPv4ProtocolUDP
+	copy(ip[12:16], srcIP)
+	copy(ip[16:20], dstIP)
+	binary.BigEndian.PutUint16(ip[10:12], ipv4HeaderChecksum(ip))
+
+	udp := packet[pcapngIPv4HeaderLength:]
+	binary.BigEndian.PutUint16(udp[0:2], uint16(src.Port))
+	binary.BigEndian.PutUint16(udp[2:4], uint16(dst.Port))
+	binary.BigEndian.PutUint16(udp[4:6], uint16(pcapngUDPHeaderLength+len(payload)))
+	// UDP チェックサムは 0 (未計算) とする (IPv4 では許容される)
+	copy(udp[pcapngUDPHeaderLength:], payload)
+	return packet, nil
+}
+
+// ipv4HeaderChecksum は、IPv4 ヘッダのチェックサムを計算します。
+func ipv4HeaderChecksum(header []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(header); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(header[i : i+2]))
+	}
+	for sum>>16 != 0 {
+		sum = (sum & 0xFFFF) + (sum >> 16)
+	}
+	return ^uint16(sum)
+}
+
+// pcapngBlock は、ブロックタイプと本体から pcapng のブロックを組み立てます (本体は4バイト境界に揃えます)。
+func pcapngBlock(blockType uint32, body []byte) []byte {
+	padded := (len(body) + 3) &^ 3
+	total := uint32(12 + padded)
+	buf := bytes.NewBuffer(make([]byte, 0, total))
+	binary.Write(buf, binary.LittleEndian, blockType)
+	binary.Write(buf, binary.LittleEndian, total)
+	buf.Write(body)
+	buf.Write(make([]byte, padded-len(body)))
+	binary.Write(buf, binary.LittleEndian, total)
+	return buf.Bytes()
+}
+
+// pcapngSectionHeader は、Section Header Block を返します。
+func pcapngSectionHeader() []byte {
+	body := new(bytes.Buffer)
+	binary.Write(body, binary.LittleEndian, uint32(pcapngByteOrderMagic))
+	binary.Write(body, binary.LittleEndian, uint16(1)) // メジャーバージョン
+	binary.Write(body, binary.LittleEndian, uint16(0)) // マイナーバージョン
+	binary.Write(body, binary.LittleEndian, int64(-1)) // セクション長 (不明)
+	return pcapngBlock(pcapngSectionHeaderBlock, body.Bytes())
+}
+
+// pcapngInterfaceDescriptionBlock は、Interface Description Block を返します (タイムスタンプはマイクロ秒単位)。
+func pcapngInterfaceDescriptionBlock() []byte {
+	body := new(bytes.Buffer)
+	binary.Write(body, binary.LittleEndian, uint16(pcapngLinkTypeRaw))
+	binary.Write(body, binary.LittleEndian, uint16(0)) // 予約
+	binary.Write(body, binary.LittleEndian, uint32(pcapngSnapLen))
+	return pcapngBlock(pcapngInterfaceDescription, body.Bytes())
+}
+
+// pcapngEnhancedPacket は、パケットと送受信方向を記録した Enhanced Packet Block を返します。
+func pcapngEnhancedPacket(packet []byte, dir captureDirection, ts time.Time) []byte {
+	micros := uint64(ts.UnixNano() / int64(time.Microsecond))
+	body := new(bytes.Buffer)
+	binary.Write(body, binary.LittleEndian, uint32(0)) // インターフェースID
+	binary.Write(body, binary.LittleEndian, uint32(micros>>32))
+	binary.Write(body, binary.LittleEndian, uint32(micros))
+	binary.Write(body, binary.LittleEndian, uint32(len(packet))) // キャプチャ長
+	binary.Write(body, binary.LittleEndian, uint32(len(packet))) // 元のパケット長
+	body.Write(packet)
+	body.Write(make([]byte, ((len(packet)+3)&^3)-len(packet)))
+	// epb_flags オプション (下位2ビットが方向: 1 = 受信, 2 = 送信)
+	binary.Write(body, binary.LittleEndian, uint16(pcapngOptionEPBFlags))
+	binary.Write(body, binary.LittleEndian, uint16(4))
+	binary.Write(body, binary.LittleEndian, uint32(dir))
+	binary.Write(body, binary.LittleEndian, uint16(pcapngOptionEndOfOpt))
+	binary.Write(body, binary.LittleEndian, uint16(0))
+	return pcapngBlock(pcapngEnhancedPacketBlock, body.Bytes())
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readPcapngBlocks splits a pcapng file into (type, body) pairs and checks the length trailers.
+func readPcapngBlocks(t *testing.T, data []byte) [][2]interface{} {
+	t.Helper()
+	var blocks [][2]interface{}
+	for len(data) > 0 {
+		if len(data) < 12 {
+			t.Fatalf("truncated block header: %d bytes left", len(data))
+		}
+		blockType := binary.LittleEndian.Uint32(data[0:4])
+		total := binary.LittleEndian.Uint32(data[4:8])
+		if total%4 != 0 || int(total) > len(data) {
+			t.Fatalf("invalid block length %d", total)
+		}
+		if trailer := binary.LittleEndian.Uint32(data[total-4 : total]); trailer != total {
+			t.Fatalf("block trailer %d does not match length %d", trailer, total)
+		}
+		blocks = append(blocks, [2]interface{}{blockType, data[8 : total-4]})
+		data = data[total:]
+	}
+	return blocks
+}
+
+func TestFrameCaptureWritesPcapng(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.pcapng")
+	c := newFrameCapture(path)
+
+	local := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 2), Port: 3610}
+	remote := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 155), Port: 3610}
+	payload := []byte{0x10, 0x81, 0x00, 0x01, 0x05, 0xFF, 0x01, 0x02, 0x7D, 0x01, 0x62, 0x01, 0xE4, 0x00}
+
+	c.record(captureOutbound, local, remote, payload, time.Now()) // ignored while stopped
+	if err := c.start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	c.record(captureOutbound, local, remote, payload, time.Now())
+	c.record(captureInbound, local, remote, payload[:13], time.Now())
+	if err := c.stop(); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read capture: %v", err)
+	}
+	blocks := readPcapngBlocks(t, data)
+	if len(blocks) != 4 {
+		t.Fatalf("expected SHB, IDB and 2 EPBs, got %d blocks", len(blocks))
+	}
+	if blocks[0][0].(uint32) != pcapngSectionHeaderBlock || binary.LittleEndian.Uint32(blocks[0][1].([]byte)) != pcapngByteOrderMagic {
+		t.Errorf("first block is not a valid section header")
+	}
+	if blocks[1][0].(uint32) != pcapngInterfaceDescription || binary.LittleEndian.Uint16(blocks[1][1].([]byte)) != pcapngLinkTypeRaw {
+		t.Errorf("second block is not a raw IP interface description")
+	}
+
+	epb := blocks[2][1].([]byte)
+	capLen := binary.LittleEndian.Uint32(epb[12:16])
+	packet := epb[20 : 20+capLen]
+	if int(capLen) != pcapngIPv4HeaderLength+pcapngUDPHeaderLength+len(payload) {
+		t.Fatalf("unexpected captured length %d", capLen)
+	}
+	if ipv4HeaderChecksum(packet[:pcapngIPv4HeaderLength]) != 0 {
+		t.Errorf("IPv4 header checksum is invalid")
+	}
+	if !net.IP(packet[12:16]).Equal(local.IP) || !net.IP(packet[16:20]).Equal(remote.IP) {
+		t.Errorf("outbound packet has wrong addresses: %v -> %v", net.IP(packet[12:16]), net.IP(packet[16:20]))
+	}
+	if string(packet[28:]) != string(payload) {
+		t.Errorf("payload mismatch: % X", packet[28:])
+	}
+
+	inbound := blocks[3][1].([]byte)
+	inPacket := inbound[20:]
+	if !net.IP(inPacket[12:16]).Equal(remote.IP) {
+		t.Errorf("inbound packet source should be the remote host, got %v", net.IP(inPacket[12:16]))
+	}
+
+	// Restarting appends a new section to the same file.
+	if err := c.start(); err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	c.stop()
+	data, _ = os.ReadFile(path)
+	if got := len(readPcapngBlocks(t, data)); got != 6 {
+		t.Errorf("expected a new section to be appended, got %d blocks", got)
+	}
+}
+
+func TestBuildIPv4UDPPacketRejectsIPv6(t *testing.T) {
+	src := &net.UDPAddr{IP: net.ParseIP("fe80::1"), Port: 3610}
+	dst := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 155), Port: 3610}
+	if _, err := buildIPv4UDPPacket(src, dst, []byte{0x10}); err == nil {
+		t.Errorf("expected error for IPv6 address")
+	}
+}
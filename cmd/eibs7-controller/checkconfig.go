@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// runConfigCheck は、-check-config で指定された設定ファイルを読み込んで確認し、デフォルト値を適用した設定の一覧を出力します。
+// 機器との通信は行いません。設定に誤りがなければ 0 を、誤りがあれば 1 を返します。
+// configFile が空の場合は、起動時と同じ順に設定ファイルを探します。
+func runConfigCheck(configFile string) int {
+	path := configFile
+	if path == "" {
+		found, err := findConfigFile(configSearchPaths())
+		if err != nil {
+			log.Printf("[設定確認] %v", err)
+			return 1
+		}
+		path = found
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		log.Printf("[設定確認] %v", err)
+		return 1
+	}
+	warnings, err := checkConfig(cfg)
+	logConfig(path, cfg)
+	for _, w := range warnings {
+		log.Printf("[設定確認] 警告: %s", w)
+	}
+	if err != nil {
+		log.Printf("[設定確認] 設定ファイル '%s' の検証に失敗しました: %v", path, err)
+		return 1
+	}
+	log.Printf("[設定確認] 設定ファイル '%s' に誤りはありません (警告: %d 件)。", path, len(warnings))
+	return 0
+}
+
+// checkConfig は、config.Load の検証に加えて、監視サイクルで初めて判明する設定の誤りと、時間帯や期間の重なりを確認します。
+// 誤りがあればエラーを返します。重なりは設定の優先順位で動作が決まるため、警告として返します。
+func checkConfig(cfg *config.Config) (warnings []string, err error) {
+	weekday := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC) // 月曜日
+	weekend := time.Date(2024, 1, 6, 12, 0, 0, 0, time.UTC) // 土曜日
+	windows := map[string]config.TimeSlot{
+		"充電時間帯 (平日)": cfg.ChargeTimes(weekday).TimeSlot,
+		"充電時間帯 (週末)": cfg.ChargeTimes(weekend).TimeSlot,
+	}
+	names := []string{"充電時間帯 (平日)", "充電時間帯 (週末)"}
+	if cfg.Holidays.Enabled && cfg.Holidays.TreatAs == config.HolidaysAsCustom {
+		windows["充電時間帯 (祝日)"] = config.ChargeWindow{TimeSlot: cfg.WeekdayChargeSlot()}.Overlay(cfg.Holidays.ChargeTimes).TimeSlot
+		names = append(names, "充電時間帯 (祝日)")
+	}
+	for _, name := range names {
+		if err := windows[name].Validate(); err != nil {
+			return warnings, fmt.Errorf("%sが不正です: %w", name, err)
+		}
+	}
+
+	if cfg.DischargeTimes.Configured() {
+		for _, name := range names {
+			w := windows[name]
+			if slotsOverlap(cfg.DischargeTimes, w) {
+				warnings = append(warnings, fmt.Sprintf("'discharge_times' (%s) が%s (%s) と重なっています", cfg.DischargeTimes, name, w))
+			}
+		}
+	}
+	for i, a := range cfg.SeasonalChargeCaps {
+		for j := i + 1; j < len(cfg.SeasonalChargeCaps); j++ {
+			if datesOverlap(a.DateRange, cfg.SeasonalChargeCaps[j].DateRange) {
+				warnings = append(warnings, fmt.Sprintf("'seasonal_charge_caps' の %d 番目と %d 番目の期間が重なっています。重なる期間は %d 番目の設定を使用します", i+1, j+1, i+1))
+			}
+		}
+	}
+	for i, a := range cfg.StrategyPeriods {
+		for j := i + 1; j < len(cfg.StrategyPeriods); j++ {
+			b := cfg.StrategyPeriods[j]
+			if datesOverlap(a.DateRange, b.DateRange) && slotsOverlap(a.TimeSlot, b.TimeSlot) {
+				warnings = append(warnings, fmt.Sprintf("'strategy_periods' の %d 番目と %d 番目の時間帯が重なっています。重なる時間帯は %d 番目の制御方式 (%s) を使用します", i+1, j+1, i+1, a.Strategy))
+			}
+		}
+	}
+	return warnings, nil
+}
+
+// slotsOverlap は、2つの時間帯に共通する時刻があれば true を返します。監視サイクルと同じ判定を1分ごとに行います。
+// cron 形式の時間帯は曜日によって異なるため、1週間分を確認します。
+func slotsOverlap(a, b config.TimeSlot) bool {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) // 月曜日
+	days := 1
+	if a.Cron != "" || b.Cron != "" {
+		days = 7
+	}
+	for t := day; t.Before(day.AddDate(0, 0, days)); t = t.Add(time.Minute) {
+		inA, errA := a.Contains(t)
+		inB, errB := b.Contains(t)
+		if errA == nil && errB == nil && inA && inB {
+			return true
+		}
+	}
+	return false
+}
+
+// datesOverlap は、2つの期間に共通する日があれば true を返します。期間が空の場合は通年とみなします。
+func datesOverlap(a, b config.DateRange) bool {
+	// 02-29 も判定するため、うるう年の日付で確認する
+	for d := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC); d.Year() == 2024; d = d.AddDate(0, 0, 1) {
+		if (a == config.DateRange{} || a.ContainsDate(d)) && (b == config.DateRange{} || b.ContainsDate(d)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+func TestCheckConfigRejectsInvalidChargeTimes(t *testing.T) {
+	cfg := &config.Config{ChargeStartTime: "9時", ChargeEndTime: "15:00"}
+	if _, err := checkConfig(cfg); err == nil {
+		t.Error("checkConfig succeeded with charge_start_time = 9時, want error")
+	}
+
+	cfg = &config.Config{ChargeStartTime: "09:00", ChargeEndTime: "15:00", ChargeTimesWeekend: config.ChargeWindow{TimeSlot: config.TimeSlot{EndTime: "25:00"}}}
+	if _, err := checkConfig(cfg); err == nil || !strings.Contains(err.Error(), "週末") {
+		t.Errorf("checkConfig = %v, want an error for the weekend window", err)
+	}
+}
+
+func TestCheckConfigWarnsAboutOverlaps(t *testing.T) {
+	cfg := &config.Config{
+		ChargeStartTime: "09:00",
+		ChargeEndTime:   "15:00",
+		DischargeTimes:  config.TimeSlot{StartTime: "14:00", EndTime: "22:00"},
+		SeasonalChargeCaps: []config.SeasonalChargeCap{
+			{DateRange: config.DateRange{Start: "12-01", End: "02-28"}},
+			{DateRange: config.DateRange{Start: "02-01", End: "03-31"}},
+			{DateRange: config.DateRange{Start: "07-01", End: "08-31"}},
+		},
+		StrategyPeriods: []config.StrategyPeriod{
+			{Strategy: config.StrategySelfConsumption, TimeSlot: config.TimeSlot{StartTime: "22:00", EndTime: "06:00"}},
+			{Strategy: config.StrategyPeakShave, DateRange: config.DateRange{Start: "07-01", End: "08-31"}, TimeSlot: config.TimeSlot{StartTime: "05:00", EndTime: "07:00"}},
+			{Strategy: config.StrategyPeakShave, TimeSlot: config.TimeSlot{StartTime: "06:00", EndTime: "08:00"}},
+		},
+	}
+
+	warnings, err := checkConfig(cfg)
+	if err != nil {
+		t.Fatalf("checkConfig failed: %v", err)
+	}
+	want := []string{
+		"'discharge_times' (14:00 - 22:00) が充電時間帯 (平日)",
+		"'discharge_times' (14:00 - 22:00) が充電時間帯 (週末)",
+		"'seasonal_charge_caps' の 1 番目と 2 番目",
+		"'strategy_periods' の 1 番目と 2 番目",
+		"'strategy_periods' の 2 番目と 3 番目",
+	}
+	if len(warnings) != len(want) {
+		t.Fatalf("warnings = %q, want %d warnings", warnings, len(want))
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(warnings[i], w) {
+			t.Errorf("warnings[%d] = %q, want prefix %q", i, warnings[i], w)
+		}
+	}
+}
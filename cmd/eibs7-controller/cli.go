@@ -0,0 +1,450 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+// deviceCommands は、機器と直接やり取りするサブコマンドです。監視・制御は行わずに1回の要求で終了します。
+var deviceCommands = map[string]string{
+	"get":      "get <EOJ> <EPC>...         プロパティの値を取得します (例: get 027D01 E4 DA)",
+	"set":      "set <EOJ> <EPC> <EDT>      プロパティの値を設定します (例: set 027D01 DA 42)",
+	"discover": "discover                   ECHONET Lite 機器をマルチキャストで探し、インスタンスの一覧を表示します",
+	"sendraw":  "sendraw <フレーム> | <EOJ> <ESV> <EPC>[=<EDT>]...  任意のフレームを送信し、応答を解析して表示します (例: sendraw 027D01 62 E4 DA)",
+	"watch":    "watch [-iface <名前>] [-for <時間>]   マルチキャストグループに参加し、LAN の通知 (INF/INFC) を受信して表示します",
+}
+
+// esvNames は、ESV の表示名です。
+var esvNames = map[echonetlite.ESV]string{
+	echonetlite.ESVSetI:       "SetI",
+	echonetlite.ESVSetC:       "SetC",
+	echonetlite.ESVGet:        "Get",
+	echonetlite.ESVInfReq:     "INF_REQ",
+	echonetlite.ESVSetGet:     "SetGet",
+	echonetlite.ESVSet_Res:    "Set_Res",
+	echonetlite.ESVGet_Res:    "Get_Res",
+	echonetlite.ESVInf:        "INF",
+	echonetlite.ESVInfC:       "INFC",
+	echonetlite.ESVSetGet_Res: "SetGet_Res",
+	echonetlite.ESVInfC_Res:   "INFC_Res",
+	echonetlite.ESVSetI_SNA:   "SetI_SNA",
+	echonetlite.ESVSetC_SNA:   "SetC_SNA",
+	echonetlite.ESVGet_SNA:    "Get_SNA",
+	echonetlite.ESVInf_SNA:    "INF_SNA",
+	echonetlite.ESVSetGet_SNA: "SetGet_SNA",
+}
+
+// printUsage は、サブコマンドと監視のオプションの使い方を出力します。
+func printUsage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintf(w, "使い方:\n  %s [monitor] [オプション]   監視と制御を行います (既定)\n", os.Args[0])
+	for _, name := range []string{"get", "set", "discover", "sendraw", "watch"} {
+		fmt.Fprintf(w, "  %s %s\n", os.Args[0], deviceCommands[name])
+	}
+	fmt.Fprintf(w, "  %s tui [-url URL] [-token TOKEN]   実行中のコントローラーの状態を表示し、手動操作を行います\n", os.Args[0])
+	fmt.Fprintf(w, "  %s backtest [-from 日時] [-to 日時] [-price 円/kWh] [記録ファイル...]   記録した監視データで制御を試します\n", os.Args[0])
+	fmt.Fprintf(w, "\n監視のオプション:\n")
+	flag.PrintDefaults()
+}
+
+// runDeviceCommand は、get / set / discover / sendraw / watch のサブコマンドを実行し、終了コードを返します。
+// 機器の IP アドレスと応答の待機時間などは設定ファイルから読み込み、-ip で IP アドレスを上書きできます。
+// -ip を指定した場合は、設定ファイルがなくても既定値で実行します。watch は設定ファイルを使用せず、-ip で送信元を絞り込みます。
+func runDeviceCommand(name string, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	verbose := fs.Bool("v", false, "送受信のログを標準エラー出力に出力します。")
+	var configFile, targetIP, iface *string
+	var watchFor *time.Duration
+	if name == "watch" {
+		targetIP = fs.String("ip", "", "指定した IP アドレスの機器からの通知のみを表示します。")
+		iface = fs.String("iface", "", "マルチキャストグループに参加するネットワークインターフェースの名前を指定します (例: eth0)。未指定の場合はシステムが選択します。")
+		watchFor = fs.Duration("for", 0, "指定した時間が経過したら終了します。0 の場合は Ctrl-C で終了するまで受信します。")
+	} else {
+		configFile = fs.String("config", "", "設定ファイルのパスを指定します。未指定の場合は監視と同じ順に config.toml を探します。")
+		targetIP = fs.String("ip", "", "機器の IP アドレスを指定します。未指定の場合は設定ファイルの target_ip を使用します。")
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "使い方: %s %s\n\nオプション:\n", os.Args[0], deviceCommands[name])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	// 送受信の詳細は通常は不要なため、-v を指定した場合のみ出力する
+	prev := log.Writer()
+	if *verbose {
+		log.SetOutput(stderr)
+	} else {
+		log.SetOutput(io.Discard)
+	}
+	defer log.SetOutput(prev)
+
+	if name == "watch" {
+		if err := runWatch(*iface, *targetIP, *watchFor, fs.Args(), stdout); err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	cfg, err := deviceCommandConfig(*configFile, *targetIP)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+	transport, err := echonetlite.ListenUDP(echonetlite.Port)
+	if err != nil {
+		fmt.Fprintf(stderr, "UDPポート %d でのListenに失敗しました: %v\n", echonetlite.Port, err)
+		return 1
+	}
+	defer transport.Close()
+	client := monitor.NewEchonetClient(transport, cfg.TargetIP, time.Duration(cfg.ResponseTimeoutMilliseconds)*time.Millisecond)
+	client.Configure(cfg)
+
+	if err := execDeviceCommand(client, name, fs.Args(), stdout); err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// deviceCommandConfig は、サブコマンドで使用する設定を読み込みます。
+// targetIP を指定した場合は設定ファイルの target_ip を上書きし、設定ファイルが見つからなければ既定値を使用します。
+func deviceCommandConfig(configFile, targetIP string) (*config.Config, error) {
+	path := configFile
+	if path == "" {
+		found, err := findConfigFile(configSearchPaths())
+		if err != nil {
+			if targetIP == "" {
+				return nil, fmt.Errorf("%v (-ip で機器の IP アドレスを指定することもできます)", err)
+			}
+			return &config.Config{
+				TargetIP:                    targetIP,
+				ReceiveBufferSize:           config.DefaultReceiveBufferSize,
+				ResponseTimeoutMilliseconds: int(config.DefaultResponseTimeout / time.Millisecond),
+				Controller:                  config.DefaultControllerEOJ,
+			}, nil
+		}
+		path = found
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("設定の読み込みに失敗しました: %w", err)
+	}
+	if targetIP != "" {
+		cfg.TargetIP = targetIP
+	}
+	return cfg, nil
+}
+
+// execDeviceCommand は、サブコマンドの引数を解析して機器に要求を送り、結果を w に出力します。
+func execDeviceCommand(client *monitor.EchonetClient, name string, args []string, w io.Writer) error {
+	switch name {
+	case "get":
+		if len(args) < 2 {
+			return fmt.Errorf("使い方: %s", deviceCommands[name])
+		}
+		eoj, err := config.ParseEOJ(args[0])
+		if err != nil {
+			return err
+		}
+		epcs, err := config.ParseEPCs(args[1:])
+		if err != nil {
+			return err
+		}
+		props, err := client.GetProperties(eoj, epcs)
+		if err != nil {
+			return err
+		}
+		for _, p := range props {
+			fmt.Fprintln(w, formatPropertyLine(eoj, p))
+		}
+		return nil
+	case "set":
+		if len(args) != 3 {
+			return fmt.Errorf("使い方: %s", deviceCommands[name])
+		}
+		eoj, err := config.ParseEOJ(args[0])
+		if err != nil {
+			return err
+		}
+		epc, err := config.ParseEPC(args[1])
+		if err != nil {
+			return err
+		}
+		edt, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(args[2]), "0x"))
+		if err != nil || len(edt) == 0 || len(edt) > 255 {
+			return fmt.Errorf("'%s' は1から255バイトの16進文字列である必要があります", args[2])
+		}
+		if err := client.SetProperty(eoj, epc, edt); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%02X%02X%02X %02X (%s) を %X に設定しました\n", eoj.ClassGroupCode, eoj.ClassCode, eoj.InstanceCode, epc, echonetlite.PropertyName(eoj, epc), edt)
+		return nil
+	case "discover":
+		if len(args) != 0 {
+			return fmt.Errorf("使い方: %s", deviceCommands[name])
+		}
+		nodes, err := client.DiscoverNodes(client.Timeout)
+		if err != nil {
+			return err
+		}
+		if len(nodes) == 0 {
+			return errors.New("応答した機器はありませんでした")
+		}
+		ips := make([]string, 0, len(nodes))
+		for ip := range nodes {
+			ips = append(ips, ip)
+		}
+		sort.Slice(ips, func(i, j int) bool { return compareIP(ips[i], ips[j]) })
+		for _, ip := range ips {
+			instances := "(インスタンスリストを取得できませんでした)"
+			if eojs, err := client.Peer(ip).GetInstanceList(); err == nil {
+				names := make([]string, len(eojs))
+				for i, eoj := range eojs {
+					names[i] = fmt.Sprintf("%02X%02X%02X", eoj.ClassGroupCode, eoj.ClassCode, eoj.InstanceCode)
+				}
+				instances = strings.Join(names, " ")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", ip, nodes[ip], instances)
+		}
+		return nil
+	case "sendraw":
+		frame, err := parseRawFrame(args, client.NextTID(), client.SEOJ)
+		if err != nil {
+			return err
+		}
+		return sendRaw(client, frame, w)
+	}
+	return fmt.Errorf("不明なサブコマンドです: %s", name)
+}
+
+// parseRawFrame は、sendraw の引数から送信するフレームを作成します。
+// 引数が1つの場合は16進文字列のフレームそのもの、3つ以上の場合は DEOJ、ESV、EPC (「EPC=EDT」で値を指定) の一覧です。
+// 後者の SEOJ は seoj (コントローラーのオブジェクト)、TID は tid です。
+func parseRawFrame(args []string, tid echonetlite.TID, seoj echonetlite.EOJ) (echonetlite.Frame, error) {
+	var frame echonetlite.Frame
+	switch {
+	case len(args) == 1:
+		data, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(args[0]), "0x"))
+		if err != nil {
+			return frame, fmt.Errorf("'%s' は16進文字列である必要があります", args[0])
+		}
+		if err := frame.UnmarshalBinary(data); err != nil {
+			return frame, fmt.Errorf("フレームを解析できませんでした: %w", err)
+		}
+		return frame, nil
+	case len(args) >= 3:
+		eoj, err := config.ParseEOJ(args[0])
+		if err != nil {
+			return frame, err
+		}
+		esv, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(args[1]), "0x"))
+		if err != nil || len(esv) != 1 {
+			return frame, fmt.Errorf("'%s' は16進2桁の ESV である必要があります", args[1])
+		}
+		if echonetlite.ESV(esv[0]) == echonetlite.ESVSetGet {
+			return frame, errors.New("SetGet (0x6E) には対応していません。フレームを16進文字列で指定してください")
+		}
+		frame = echonetlite.Frame{
+			EHD1: echonetlite.EchonetLiteEHD1,
+			EHD2: echonetlite.Format1,
+			TID:  tid,
+			SEOJ: seoj,
+			DEOJ: eoj,
+			ESV:  echonetlite.ESV(esv[0]),
+		}
+		for _, arg := range args[2:] {
+			epcArg, edtArg, _ := strings.Cut(arg, "=")
+			epc, err := config.ParseEPC(epcArg)
+			if err != nil {
+				return frame, err
+			}
+			edt, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(edtArg), "0x"))
+			if err != nil || len(edt) > 255 {
+				return frame, fmt.Errorf("'%s' の EDT は255バイトまでの16進文字列である必要があります", arg)
+			}
+			if len(edt) == 0 {
+				edt = nil
+			}
+			frame.Properties = append(frame.Properties, echonetlite.Property{EPC: epc, PDC: byte(len(edt)), EDT: edt})
+		}
+		frame.OPC = byte(len(frame.Properties))
+		return frame, nil
+	}
+	return frame, fmt.Errorf("使い方: %s", deviceCommands["sendraw"])
+}
+
+// sendRaw は、フレームを対象機器に送信し、送受信したバイト列と解析した応答を w に出力します。
+// SetI (0x60) は機器が応答を返さないため、応答がなくてもエラーにしません。
+// 制御の安全確認やドライラン、監査ログを経由せずに送信するため、診断のためにのみ使用します。
+func sendRaw(c *monitor.EchonetClient, frame echonetlite.Frame, w io.Writer) error {
+	data, err := frame.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("フレームのシリアライズに失敗しました: %w", err)
+	}
+	fmt.Fprintf(w, "送信 (%s): %X\n", c.TargetIP, data)
+	fmt.Fprintln(w, formatFrameSummary(frame))
+
+	received, addr, err := c.SendAndReceive(frame)
+	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			if frame.ESV == echonetlite.ESVSetI {
+				fmt.Fprintln(w, "応答はありません (SetI は正常な場合に応答を返しません)")
+				return nil
+			}
+			return fmt.Errorf("応答がありませんでした (TID: %d): %w", frame.TID, err)
+		}
+		return err
+	}
+	fmt.Fprintf(w, "受信 (%s): %X\n", addr.IP, received)
+	var response echonetlite.Frame
+	if err := response.UnmarshalBinary(received); err != nil {
+		return fmt.Errorf("受信データのデシリアライズに失敗しました (TID: %d): %w", frame.TID, err)
+	}
+	fmt.Fprintln(w, formatFrameSummary(response))
+	writeFrameProperties(w, "  ", response)
+	return nil
+}
+
+// writeFrameProperties は、フレームのプロパティを送信元のオブジェクトのプロパティとして解析し、1行ずつ indent を付けて w に出力します。
+func writeFrameProperties(w io.Writer, indent string, frame echonetlite.Frame) {
+	for _, p := range frame.Properties {
+		if len(p.EDT) == 0 {
+			fmt.Fprintf(w, "%s%02X (%s): PDC=0\n", indent, p.EPC, echonetlite.PropertyName(frame.SEOJ, p.EPC))
+			continue
+		}
+		fmt.Fprintln(w, indent+formatPropertyLine(frame.SEOJ, p))
+	}
+}
+
+// formatFrameSummary は、フレームのヘッダーを「TID  SEOJ  DEOJ  ESV  OPC」の1行にします。
+func formatFrameSummary(f echonetlite.Frame) string {
+	esv := fmt.Sprintf("0x%02X", byte(f.ESV))
+	if name, ok := esvNames[f.ESV]; ok {
+		esv += " (" + name + ")"
+	}
+	return fmt.Sprintf("TID: %d  SEOJ: %02X%02X%02X  DEOJ: %02X%02X%02X  ESV: %s  OPC: %d",
+		f.TID, f.SEOJ.ClassGroupCode, f.SEOJ.ClassCode, f.SEOJ.InstanceCode,
+		f.DEOJ.ClassGroupCode, f.DEOJ.ClassCode, f.DEOJ.InstanceCode, esv, f.OPC)
+}
+
+// compareIP は、IP アドレスを数値の順に比較します。解析できないものは文字列として比較します。
+func compareIP(a, b string) bool {
+	ipA, ipB := net.ParseIP(a).To16(), net.ParseIP(b).To16()
+	if ipA == nil || ipB == nil {
+		return a < b
+	}
+	return string(ipA) < string(ipB)
+}
+
+// formatPropertyLine は、取得したプロパティを「EPC (名前): 値 [EDT]」の形式で返します。
+func formatPropertyLine(eoj echonetlite.EOJ, p echonetlite.Property) string {
+	if len(p.EDT) == 0 {
+		return fmt.Sprintf("%02X (%s): 取得できませんでした", p.EPC, echonetlite.PropertyName(eoj, p.EPC))
+	}
+	value, name, err := monitor.DecodeEDT(eoj, p.EPC, p.EDT)
+	if err != nil || isRawEDT(value) {
+		return fmt.Sprintf("%02X (%s): %X", p.EPC, name, p.EDT)
+	}
+	return fmt.Sprintf("%02X (%s): %v [%X]", p.EPC, name, value, p.EDT)
+}
+
+// isRawEDT は、monitor.DecodeEDT がバイト列のまま返した値かどうかを返します。
+func isRawEDT(v interface{}) bool {
+	_, ok := v.([]byte)
+	return ok
+}
+
+// runWatch は、マルチキャストグループに参加して通知 (INF/INFC) を受信し、Ctrl-C または duration の経過まで w に出力します。
+// iface はグループに参加するインターフェースの名前 (空の場合はシステムが選択)、source は表示する送信元の IP アドレス (空の場合はすべて) です。
+func runWatch(iface, source string, duration time.Duration, args []string, w io.Writer) error {
+	if len(args) != 0 {
+		return fmt.Errorf("使い方: %s", deviceCommands["watch"])
+	}
+	var ifi *net.Interface
+	if iface != "" {
+		var err error
+		if ifi, err = net.InterfaceByName(iface); err != nil {
+			return fmt.Errorf("インターフェース '%s' が見つかりません: %w", iface, err)
+		}
+	}
+	transport, err := echonetlite.ListenMulticastUDP(echonetlite.Port, ifi)
+	if err != nil {
+		return fmt.Errorf("マルチキャストグループに参加できませんでした (コントローラーの実行中は 3610 番ポートを使用できません): %w", err)
+	}
+	defer transport.Close()
+
+	done := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		<-signals
+		close(done)
+	}()
+	var deadline time.Time
+	if duration > 0 {
+		deadline = time.Now().Add(duration)
+	}
+	fmt.Fprintf(w, "%s:%d で通知を待機しています (Ctrl-C で終了)...\n", echonetlite.MulticastIP, echonetlite.Port)
+	return watchNotifications(transport, source, deadline, done, w)
+}
+
+// watchNotifications は、transport で受信した通知 (INF/INFC) を、受信時刻・送信元・解析したプロパティとともに w に出力します。
+// 通知以外のデータグラムと source 以外の送信元からの通知は無視します。応答 (INFC_Res) は送信しません。
+// deadline を過ぎるか done が閉じられると nil を返します。deadline がゼロの場合は done が閉じられるまで受信します。
+func watchNotifications(transport echonetlite.Transport, source string, deadline time.Time, done <-chan struct{}, w io.Writer) error {
+	buffer := make([]byte, config.DefaultReceiveBufferSize)
+	for {
+		select {
+		case <-done:
+			return nil
+		default:
+		}
+		now := time.Now()
+		if !deadline.IsZero() && !now.Before(deadline) {
+			return nil
+		}
+		// done を確認できるよう、1回の受信待ちは最大1秒とする
+		wait := now.Add(time.Second)
+		if !deadline.IsZero() && deadline.Before(wait) {
+			wait = deadline
+		}
+		n, addr, err := transport.Receive(buffer, wait)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				continue
+			}
+			return fmt.Errorf("UDPデータの受信に失敗しました: %w", err)
+		}
+		var frame echonetlite.Frame
+		if err := frame.UnmarshalBinary(buffer[:n]); err != nil || !echonetlite.IsNotification(frame.ESV) {
+			debugf("[通知] 通知以外のデータグラムを無視しました (送信元: %s)", addr)
+			continue
+		}
+		if source != "" && addr.IP.String() != source {
+			continue
+		}
+		fmt.Fprintf(w, "%s %s %X\n", time.Now().Format("15:04:05.000"), addr.IP, buffer[:n])
+		fmt.Fprintln(w, "  "+formatFrameSummary(frame))
+		writeFrameProperties(w, "    ", frame)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestExecDeviceCommandGetAndSet(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	client := monitor.NewEchonetClient(echonetlite.NewFakeTransport(device.Handle), "192.168.0.10", time.Second)
+
+	var out strings.Builder
+	if err := execDeviceCommand(client, "get", []string{"027D01", "E4", "0xDA", "E5"}, &out); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 3 || lines[0] != "E4 (蓄電残量3): 50 [32]" || lines[1] != "DA (運転モード設定): 66 [42]" || !strings.HasSuffix(lines[2], ": 取得できませんでした") {
+		t.Errorf("get output =\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := execDeviceCommand(client, "set", []string{"027D01", "DA", "46"}, &out); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if len(device.Sets) != 1 {
+		t.Fatalf("sent %d SetC, want 1", len(device.Sets))
+	}
+	if p := device.Sets[0].Properties[0]; p.EPC != 0xDA || string(p.EDT) != "\x46" {
+		t.Errorf("SetC = EPC 0x%X EDT %X, want DA 46", p.EPC, p.EDT)
+	}
+	if !strings.Contains(out.String(), "46 に設定しました") {
+		t.Errorf("set output = %q", out.String())
+	}
+}
+
+func TestExecDeviceCommandRejectsBadArguments(t *testing.T) {
+	client := monitor.NewEchonetClient(echonetlite.NewFakeTransport(nil), "192.168.0.10", time.Second)
+	for _, args := range [][]string{
+		{"get", "027D01"},
+		{"get", "027D", "E4"},
+		{"get", "027D01", "10"},
+		{"set", "027D01", "DA"},
+		{"set", "027D01", "DA", "4"},
+		{"discover", "extra"},
+		{"reboot"},
+	} {
+		var out strings.Builder
+		if err := execDeviceCommand(client, args[0], args[1:], &out); err == nil {
+			t.Errorf("execDeviceCommand(%q) succeeded, want error", args)
+		}
+	}
+}
+
+func TestExecDeviceCommandDiscover(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	device.Props[monitor.NodeProfileEOJ][0x83] = []byte{0xFE, 0x00, 0x00, 0x08, 0x01}
+	device.Props[monitor.NodeProfileEOJ][0xD6] = []byte{2, 0x02, 0x7D, 0x01, 0x02, 0x79, 0x01}
+	from := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10), Port: echonetlite.Port}
+	handler := func(data []byte, addr *net.UDPAddr) []echonetlite.Datagram {
+		return device.Handle(data, from)
+	}
+	client := monitor.NewEchonetClient(echonetlite.NewFakeTransport(handler), "", time.Second)
+
+	var out strings.Builder
+	if err := execDeviceCommand(client, "discover", nil, &out); err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if got, want := out.String(), "192.168.0.10\tFE00000801\t027D01 027901\n"; got != want {
+		t.Errorf("discover output = %q, want %q", got, want)
+	}
+}
+
+func TestDeviceCommandConfigUsesIPWithoutConfigFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if _, err := deviceCommandConfig("", ""); err == nil {
+		t.Error("deviceCommandConfig without a config file or -ip succeeded, want error")
+	}
+	cfg, err := deviceCommandConfig("", "192.168.0.20")
+	if err != nil {
+		t.Fatalf("deviceCommandConfig: %v", err)
+	}
+	if cfg.TargetIP != "192.168.0.20" || cfg.ResponseTimeoutMilliseconds <= 0 || cfg.ReceiveBufferSize != config.DefaultReceiveBufferSize {
+		t.Errorf("config = %+v", cfg)
+	}
+}
+
+func TestParseRawFrame(t *testing.T) {
+	frame, err := parseRawFrame([]string{"1081000105FF01027D016201E400"}, 7, config.DefaultControllerEOJ)
+	if err != nil {
+		t.Fatalf("parseRawFrame(hex): %v", err)
+	}
+	if frame.TID != 1 || frame.ESV != echonetlite.ESVGet || len(frame.Properties) != 1 || frame.Properties[0].EPC != 0xE4 {
+		t.Errorf("frame = %+v", frame)
+	}
+
+	frame, err = parseRawFrame([]string{"027D01", "61", "DA=42", "0xEB=000003E8"}, 7, config.DefaultControllerEOJ)
+	if err != nil {
+		t.Fatalf("parseRawFrame(fields): %v", err)
+	}
+	if frame.TID != 7 || frame.DEOJ != echonetlite.NewEOJ(0x02, 0x7D, 0x01) || frame.ESV != echonetlite.ESVSetC || frame.OPC != 2 {
+		t.Errorf("frame = %+v", frame)
+	}
+	if p := frame.Properties[1]; p.EPC != 0xEB || p.PDC != 4 || p.EDT[3] != 0xE8 {
+		t.Errorf("property = %+v", p)
+	}
+
+	for _, args := range [][]string{
+		{"1081"},
+		{"027D01", "62"},
+		{"027D01", "6E", "DA"},
+		{"027D01", "620", "DA"},
+		{"027D01", "62", "DA=4"},
+	} {
+		if _, err := parseRawFrame(args, 7, config.DefaultControllerEOJ); err == nil {
+			t.Errorf("parseRawFrame(%q) succeeded, want error", args)
+		}
+	}
+}
+
+func TestExecDeviceCommandSendRaw(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	client := monitor.NewEchonetClient(echonetlite.NewFakeTransport(device.Handle), "192.168.0.10", time.Second)
+
+	var out strings.Builder
+	if err := execDeviceCommand(client, "sendraw", []string{"027D01", "62", "E4", "CF"}, &out); err != nil {
+		t.Fatalf("sendraw: %v", err)
+	}
+	for _, want := range []string{"送信 (192.168.0.10): ", "ESV: 0x62 (Get)", "受信 (192.168.0.10): ", "SEOJ: 027D01", "ESV: 0x72 (Get_Res)  OPC: 2", "  E4 (蓄電残量3): 50 [32]"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("sendraw output is missing %q:\n%s", want, out.String())
+		}
+	}
+
+	// SetI is not answered by a device that accepts it.
+	out.Reset()
+	silent := monitor.NewEchonetClient(echonetlite.NewFakeTransport(nil), "192.168.0.10", time.Millisecond)
+	if err := execDeviceCommand(silent, "sendraw", []string{"027D01", "60", "DA=42"}, &out); err != nil {
+		t.Errorf("sendraw SetI without a response: %v", err)
+	}
+	if err := execDeviceCommand(silent, "sendraw", []string{"027D01", "62", "E4"}, &out); err == nil {
+		t.Error("sendraw Get without a response succeeded, want error")
+	}
+}
+
+func TestWatchNotifications(t *testing.T) {
+	transport := echonetlite.NewFakeTransport(nil)
+	device := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10), Port: echonetlite.Port}
+	other := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 20), Port: echonetlite.Port}
+	inf := func(tid echonetlite.TID, esv echonetlite.ESV) []byte {
+		f := echonetlite.Frame{
+			EHD1:       echonetlite.EchonetLiteEHD1,
+			EHD2:       echonetlite.Format1,
+			TID:        tid,
+			SEOJ:       echonetlite.NewEOJ(0x02, 0x7D, 0x01),
+			DEOJ:       monitor.NodeProfileEOJ,
+			ESV:        esv,
+			OPC:        1,
+			Properties: []echonetlite.Property{{EPC: 0xDA, PDC: 1, EDT: []byte{0x44}}},
+		}
+		data, err := f.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	transport.Inject(inf(1, echonetlite.ESVInf), device)
+	transport.Inject(inf(2, echonetlite.ESVGet_Res), device) // not a notification
+	transport.Inject(inf(3, echonetlite.ESVInfC), other)
+	transport.Inject(inf(4, echonetlite.ESVInfC), device)
+
+	var out strings.Builder
+	if err := watchNotifications(transport, "192.168.0.10", time.Now().Add(50*time.Millisecond), nil, &out); err != nil {
+		t.Fatalf("watchNotifications: %v", err)
+	}
+	got := out.String()
+	for _, want := range []string{"TID: 1  SEOJ: 027D01", "ESV: 0x73 (INF)", "    DA (運転モード設定): 68 [44]", "TID: 4", "ESV: 0x74 (INFC)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output is missing %q:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"TID: 2", "TID: 3"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("output contains %q:\n%s", unwanted, got)
+		}
+	}
+	// INFC is only observed, never answered.
+	if n := len(transport.Sent()); n != 0 {
+		t.Errorf("sent %d datagrams, want 0", n)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+)
+
+// ECHONET Lite Web API (ELWA) の読み取り専用のブリッジです。
+// ELWA に対応したクラウドサービスやツールから、機器に直接接続せずに、最後の監視サイクルの測定値を参照できます。
+// 複数台の蓄電池や太陽光発電は、制御に使用する合計 (蓄電残量は容量で重み付けした平均) を1台の機器として返します。
+//
+//	GET /elapi/v1/devices                               機器の一覧
+//	GET /elapi/v1/devices/<id>                          機器の Device Description
+//	GET /elapi/v1/devices/<id>/properties               全プロパティの値
+//	GET /elapi/v1/devices/<id>/properties/<プロパティ名>  1つのプロパティの値
+
+// elwaProperty は、ELWA で公開するプロパティです。
+type elwaProperty struct {
+	name   string // ELWA のプロパティ名
+	epc    byte
+	key    string // monitoringData のキー
+	ja, en string
+	schema map[string]interface{}
+	value  func(v interface{}) (interface{}, bool) // 測定値を ELWA の値に変換する
+}
+
+// elwaDevice は、ELWA で公開する機器です。
+type elwaDevice struct {
+	id, deviceType string
+	eoj            echonetlite.EOJ
+	ja, en         string
+	properties     []elwaProperty
+}
+
+// elwaOperationModes は、蓄電池の運転モード設定 (EPC 0xDA) の ELWA での値です。
+var elwaOperationModes = map[uint8]string{
+	0x40: "other",
+	0x41: "rapidCharging",
+	0x42: "charging",
+	0x43: "discharging",
+	0x44: "standby",
+	0x45: "test",
+	0x46: "auto",
+	0x48: "restart",
+	0x49: "capacityRecalculation",
+}
+
+// elwaDevices は、ELWA で公開する機器とプロパティの一覧です。
+var elwaDevices = []elwaDevice{
+	{
+		id: "storageBattery", deviceType: "storageBattery", eoj: echonetlite.NewEOJ(0x02, 0x7D, 0x01), ja: "蓄電池", en: "Storage battery",
+		properties: []elwaProperty{
+			{
+				name: "operationMode", epc: 0xDA, key: "蓄電池.運転モード設定", ja: "運転モード設定", en: "Operation mode",
+				schema: map[string]interface{}{"type": "string", "enum": []string{"rapidCharging", "charging", "discharging", "standby", "test", "auto", "restart", "capacityRecalculation", "other"}},
+				value: func(v interface{}) (interface{}, bool) {
+					mode, ok := v.(uint8)
+					if !ok {
+						return nil, false
+					}
+					name, ok := elwaOperationModes[mode]
+					return name, ok
+				},
+			},
+			{
+				name: "remainingCapacity3", epc: 0xE4, key: "蓄電池.蓄電残量3", ja: "蓄電残量3", en: "Remaining stored electricity 3",
+				schema: map[string]interface{}{"type": "number", "unit": "%", "minimum": 0, "maximum": 100},
+				value:  elwaNumber,
+			},
+			{
+				name: "instantaneousChargingDischargingElectricPower", epc: 0xD3, key: "蓄電池.瞬時充放電電力計測値", ja: "瞬時充放電電力計測値", en: "Measured instantaneous charging/discharging electric power",
+				schema: map[string]interface{}{"type": "number", "unit": "W"},
+				value:  elwaNumber,
+			},
+			{
+				name: "chargingElectricPower", epc: 0xEB, key: "蓄電池.充電電力設定値", ja: "充電電力設定値", en: "Charging electric power setting",
+				schema: map[string]interface{}{"type": "number", "unit": "W", "minimum": 0},
+				value:  elwaNumber,
+			},
+			{
+				name: "acEffectiveChargingCapacity", epc: 0xA0, key: "蓄電池.AC実効容量（充電）", ja: "AC実効容量（充電）", en: "AC effective capacity (charging)",
+				schema: map[string]interface{}{"type": "number", "unit": "Wh", "minimum": 0},
+				value:  elwaNumber,
+			},
+		},
+	},
+	{
+		id: "pvPowerGeneration", deviceType: "pvPowerGeneration", eoj: echonetlite.NewEOJ(0x02, 0x79, 0x01), ja: "住宅用太陽光発電", en: "Household solar power generation",
+		properties: []elwaProperty{
+			{
+				name: "instantaneousElectricPowerGeneration", epc: 0xE0, key: "住宅用太陽光発電.瞬時発電電力計測値", ja: "瞬時発電電力計測値", en: "Measured instantaneous amount of electricity generated",
+				schema: map[string]interface{}{"type": "number", "unit": "W", "minimum": 0},
+				value:  elwaNumber,
+			},
+		},
+	},
+}
+
+// elwaNumber は、整数の測定値を ELWA の数値にします。
+func elwaNumber(v interface{}) (interface{}, bool) {
+	switch n := v.(type) {
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	}
+	return nil, false
+}
+
+// elwaHandler は、/elapi/v1 以下のリクエストを処理する http.Handler を返します。プレフィックスは取り除いて呼び出します。
+func (a *httpAPI) elwaHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeAPIError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s は使用できません (ELWA のブリッジは読み取り専用です)", r.Method))
+			return
+		}
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if parts[0] != "devices" || len(parts) > 4 || (len(parts) >= 3 && parts[2] != "properties") {
+			writeAPIError(w, http.StatusNotFound, fmt.Errorf("'%s' はありません", r.URL.Path))
+			return
+		}
+		if len(parts) == 1 {
+			a.handleELWADevices(w)
+			return
+		}
+		device, ok := findELWADevice(parts[1])
+		if !ok {
+			writeAPIError(w, http.StatusNotFound, fmt.Errorf("機器 '%s' はありません", parts[1]))
+			return
+		}
+		switch len(parts) {
+		case 2:
+			writeAPIJSON(w, http.StatusOK, device.description())
+		case 3:
+			writeAPIJSON(w, http.StatusOK, a.elwaValues(device, ""))
+		case 4:
+			if _, ok := device.property(parts[3]); !ok {
+				writeAPIError(w, http.StatusNotFound, fmt.Errorf("機器 '%s' にプロパティ '%s' はありません", device.id, parts[3]))
+				return
+			}
+			values := a.elwaValues(device, parts[3])
+			if len(values) == 0 {
+				writeAPIError(w, http.StatusServiceUnavailable, fmt.Errorf("プロパティ '%s' の値をまだ取得していません", parts[3]))
+				return
+			}
+			writeAPIJSON(w, http.StatusOK, values)
+		}
+	})
+}
+
+// handleELWADevices は、機器の一覧を返します。
+func (a *httpAPI) handleELWADevices(w http.ResponseWriter) {
+	devices := make([]map[string]interface{}, 0, len(elwaDevices))
+	for _, d := range elwaDevices {
+		devices = append(devices, map[string]interface{}{
+			"id":         d.id,
+			"deviceType": d.deviceType,
+			"protocol":   map[string]string{"type": "ECHONET_Lite v1.13", "version": "Rel.J"},
+		})
+	}
+	writeAPIJSON(w, http.StatusOK, map[string]interface{}{"devices": devices})
+}
+
+// findELWADevice は、ID の機器を返します。
+func findELWADevice(id string) (elwaDevice, bool) {
+	for _, d := range elwaDevices {
+		if d.id == id {
+			return d, true
+		}
+	}
+	return elwaDevice{}, false
+}
+
+// property は、名前のプロパティを返します。
+func (d elwaDevice) property(name string) (elwaProperty, bool) {
+	for _, p := range d.properties {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return elwaProperty{}, false
+}
+
+// description は、機器の Device Description を返します。すべてのプロパティは読み取り専用です。
+func (d elwaDevice) description() map[string]interface{} {
+	properties := make(map[string]interface{}, len(d.properties))
+	for _, p := range d.properties {
+		properties[p.name] = map[string]interface{}{
+			"epc":          fmt.Sprintf("0x%02X", p.epc),
+			"descriptions": map[string]string{"ja": p.ja, "en": p.en},
+			"writable":     false,
+			"observable":   false,
+			"schema":       p.schema,
+		}
+	}
+	return map[string]interface{}{
+		"deviceType":   d.deviceType,
+		"eoj":          fmt.Sprintf("0x%02X%02X%02X", d.eoj.ClassGroupCode, d.eoj.ClassCode, d.eoj.InstanceCode),
+		"descriptions": map[string]string{"ja": d.ja, "en": d.en},
+		"properties":   properties,
+	}
+}
+
+// elwaValues は、最後の監視サイクルの測定値から、機器のプロパティの値を返します。
+// name を指定した場合はそのプロパティのみです。取得できていないプロパティは含めません。
+func (a *httpAPI) elwaValues(d elwaDevice, name string) map[string]interface{} {
+	a.mu.Lock()
+	last := a.last
+	a.mu.Unlock()
+	values := map[string]interface{}{}
+	if last == nil {
+		return values
+	}
+	for _, p := range d.properties {
+		if name != "" && p.name != name {
+			continue
+		}
+		if v, ok := p.value(last.Measurements[p.key]); ok {
+			values[p.name] = v
+		}
+	}
+	return values
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+func TestELWABridge(t *testing.T) {
+	api := newHTTPAPI("secret", monitor.NewManualOverride(time.UTC))
+	api.elwa = true
+	handler := api.handler()
+	get := func(method, path string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var v map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+			t.Fatalf("%s %s: %v (%s)", method, path, err, rec.Body)
+		}
+		return rec.Code, v
+	}
+
+	code, v := get("GET", "/elapi/v1/devices")
+	if devices, _ := v["devices"].([]interface{}); code != http.StatusOK || len(devices) != 2 {
+		t.Fatalf("devices = %d %v", code, v)
+	}
+	code, v = get("GET", "/elapi/v1/devices/storageBattery")
+	properties, _ := v["properties"].(map[string]interface{})
+	if code != http.StatusOK || v["eoj"] != "0x027D01" || properties["remainingCapacity3"] == nil {
+		t.Errorf("device description = %d %v", code, v)
+	}
+	// No cycle has run yet.
+	if code, _ := get("GET", "/elapi/v1/devices/storageBattery/properties/remainingCapacity3"); code != http.StatusServiceUnavailable {
+		t.Errorf("property before the first cycle = %d", code)
+	}
+
+	api.save(&monitor.AuditRecord{
+		Decision: monitor.AuditStrategy,
+		Measurements: map[string]interface{}{
+			"蓄電池.蓄電残量3":          uint8(80),
+			"蓄電池.運転モード設定":        uint8(0x42),
+			"蓄電池.瞬時充放電電力計測値":     int32(-500),
+			"住宅用太陽光発電.瞬時発電電力計測値": int32(2400),
+		},
+	})
+	code, v = get("GET", "/elapi/v1/devices/storageBattery/properties")
+	if code != http.StatusOK || len(v) != 3 || v["remainingCapacity3"] != 80.0 || v["operationMode"] != "charging" || v["instantaneousChargingDischargingElectricPower"] != -500.0 {
+		t.Errorf("battery properties = %d %v", code, v)
+	}
+	code, v = get("GET", "/elapi/v1/devices/pvPowerGeneration/properties/instantaneousElectricPowerGeneration")
+	if code != http.StatusOK || len(v) != 1 || v["instantaneousElectricPowerGeneration"] != 2400.0 {
+		t.Errorf("PV property = %d %v", code, v)
+	}
+
+	for _, bad := range []struct {
+		method, path string
+		code         int
+	}{
+		{"GET", "/elapi/v1/devices/airConditioner", http.StatusNotFound},
+		{"GET", "/elapi/v1/devices/storageBattery/properties/unknown", http.StatusNotFound},
+		{"GET", "/elapi/v1/devices/storageBattery/history", http.StatusNotFound},
+		{"PUT", "/elapi/v1/devices/storageBattery/properties/operationMode", http.StatusMethodNotAllowed},
+	} {
+		if code, _ := get(bad.method, bad.path); code != bad.code {
+			t.Errorf("%s %s = %d, want %d", bad.method, bad.path, code, bad.code)
+		}
+	}
+
+	// The bridge is off unless enabled.
+	api.elwa = false
+	req := httptest.NewRequest("GET", "/elapi/v1/devices", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	api.handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("disabled bridge = %d", rec.Code)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// emailReporter は、毎日 time の時刻に、その日の履歴を集計したまとめをメールで送信します。
+type emailReporter struct {
+	cfg     config.EmailReportConfig
+	history *historyStore
+	loc     *time.Location
+	send    func(subject, body string) error // テストで差し替えるため
+}
+
+// newEmailReporter は、設定と履歴を指定して emailReporter を作成します。時刻は loc のタイムゾーンで判定します。
+func newEmailReporter(cfg config.EmailReportConfig, history *historyStore, loc *time.Location) *emailReporter {
+	r := &emailReporter{cfg: cfg, history: history, loc: loc}
+	r.send = r.sendMail
+	return r
+}
+
+// run は、done が閉じられるまで、送信の時刻ごとにまとめを送信します。
+func (r *emailReporter) run(done <-chan struct{}) {
+	for {
+		next := config.NextOccurrence(time.Now().In(r.loc), r.cfg.Time)
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-done:
+			timer.Stop()
+			return
+		case <-timer.C:
+			if err := r.sendReport(next); err != nil {
+				log.Printf("[メール] 日ごとのまとめの送信に失敗しました: %v", err)
+			}
+		}
+	}
+}
+
+// sendReport は、now の日の 0:00 から now までの履歴を集計して送信します。
+func (r *emailReporter) sendReport(now time.Time) error {
+	y, m, d := now.Date()
+	report, err := r.history.report(time.Date(y, m, d, 0, 0, 0, 0, now.Location()), now)
+	if err != nil {
+		return fmt.Errorf("履歴を集計できませんでした: %w", err)
+	}
+	if err := r.send(fmt.Sprintf("[eibs7-controller] %s のまとめ", now.Format("2006-01-02")), formatHistoryReport(report)); err != nil {
+		return err
+	}
+	log.Printf("[メール] %s のまとめを %s に送信しました。", now.Format("2006-01-02"), strings.Join(r.cfg.To, ", "))
+	return nil
+}
+
+// formatHistoryReport は、集計結果をメールの本文にします。
+func formatHistoryReport(r historyReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "集計期間: %s - %s (監視サイクル: %d 回)\n\n", r.From.Format("2006-01-02 15:04"), r.To.Format("15:04"), r.Cycles)
+	fmt.Fprintf(&b, "発電電力量: %.2f kWh\n", r.GeneratedKWh)
+	fmt.Fprintf(&b, "買電電力量: %.2f kWh\n", r.ImportedKWh)
+	fmt.Fprintf(&b, "売電電力量: %.2f kWh\n", r.ExportedKWh)
+	fmt.Fprintf(&b, "充電電力量: %.2f kWh\n", r.ChargedKWh)
+	fmt.Fprintf(&b, "放電電力量: %.2f kWh\n", r.DischargedKWh)
+	if r.MinSOC >= 0 {
+		fmt.Fprintf(&b, "蓄電残量: 最小 %d%% / 最大 %d%%\n", r.MinSOC, r.MaxSOC)
+	} else {
+		b.WriteString("蓄電残量: 記録なし\n")
+	}
+	fmt.Fprintf(&b, "運転モードの変更: %d 回\n", r.ModeChanges)
+
+	var errs []string
+	results := make([]string, 0, len(r.FailedRequests))
+	for result := range r.FailedRequests {
+		results = append(results, result)
+	}
+	sort.Strings(results)
+	for _, result := range results {
+		errs = append(errs, fmt.Sprintf("設定の失敗 (%s): %d 回", result, r.FailedRequests[result]))
+	}
+	if r.FaultCycles > 0 {
+		errs = append(errs, fmt.Sprintf("機器の異常のため制御しなかった監視サイクル: %d 回", r.FaultCycles))
+	}
+	if r.UnreachableCycles > 0 {
+		errs = append(errs, fmt.Sprintf("機器に到達できなかった監視サイクル: %d 回", r.UnreachableCycles))
+	}
+	b.WriteString("\nエラー:\n")
+	if len(errs) == 0 {
+		b.WriteString("  なし\n")
+	}
+	for _, e := range errs {
+		b.WriteString("  " + e + "\n")
+	}
+	return b.String()
+}
+
+// sendMail は、SMTP サーバーにメールを送信します。
+func (r *emailReporter) sendMail(subject, body string) error {
+	addr := net.JoinHostPort(r.cfg.SMTPHost, strconv.Itoa(r.cfg.SMTPPort))
+	var auth smtp.Auth
+	if r.cfg.Username != "" {
+		auth = smtp.PlainAuth("", r.cfg.Username, string(r.cfg.Password), r.cfg.SMTPHost)
+	}
+	msg := buildEmail(r.cfg.From, r.cfg.To, subject, body, time.Now())
+	// エンベロープには表示名を除いたアドレスを使用する (validate で確認済み)
+	from, _ := mail.ParseAddress(r.cfg.From)
+	to := make([]string, len(r.cfg.To))
+	for i, addr := range r.cfg.To {
+		a, _ := mail.ParseAddress(addr)
+		to[i] = a.Address
+	}
+	if !r.cfg.TLS {
+		return smtp.SendMail(addr, auth, from.Address, to, msg) // サーバーが対応していれば STARTTLS を使用する
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", addr, &tls.Config{ServerName: r.cfg.SMTPHost})
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, r.cfg.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// buildEmail は、UTF-8 のテキストのメールを作成します。件名は MIME エンコードします。
+func buildEmail(from string, to []string, subject, body string, date time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(strings.ReplaceAll(body, "\n", "\r\n")))
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+	return []byte(b.String())
+}
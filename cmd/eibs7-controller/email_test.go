@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/base64"
+	"mime"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+func TestEmailReporterSendsDailyReport(t *testing.T) {
+	hcfg := config.HistoryConfig{File: filepath.Join(t.TempDir(), "history.db")}
+	hcfg.Validate()
+	h, err := openHistoryStore(hcfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.close()
+	day := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	h.save(&monitor.AuditRecord{Time: day.Add(-time.Hour), Decision: monitor.AuditStrategy, Measurements: map[string]interface{}{"蓄電池.蓄電残量3": uint8(10)}})
+	h.save(&monitor.AuditRecord{Time: day.Add(10 * time.Hour), Decision: monitor.AuditStrategy, Measurements: map[string]interface{}{"蓄電池.蓄電残量3": uint8(70)},
+		Requests: []monitor.AuditRequest{{EPC: "0xDA", Result: "timeout"}}})
+
+	r := newEmailReporter(config.EmailReportConfig{Time: "23:55", To: []string{"me@example.com"}}, h, time.UTC)
+	var subject, body string
+	r.send = func(s, b string) error { subject, body = s, b; return nil }
+	if err := r.sendReport(day.Add(23*time.Hour + 55*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if subject != "[eibs7-controller] 2025-06-01 のまとめ" {
+		t.Errorf("subject = %q", subject)
+	}
+	// The previous day's cycle is not included.
+	for _, want := range []string{"監視サイクル: 1 回", "蓄電残量: 最小 70% / 最大 70%", "設定の失敗 (timeout): 1 回"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body does not contain %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestBuildEmail(t *testing.T) {
+	msg := string(buildEmail("a@example.com", []string{"b@example.com", "c@example.com"}, "日本語の件名", "本文\n2行目", time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)))
+	header, encoded, ok := strings.Cut(msg, "\r\n\r\n")
+	if !ok {
+		t.Fatalf("no header separator: %q", msg)
+	}
+	var subject string
+	for _, line := range strings.Split(header, "\r\n") {
+		if s, ok := strings.CutPrefix(line, "Subject: "); ok {
+			subject, _ = new(mime.WordDecoder).DecodeHeader(s)
+		}
+	}
+	if subject != "日本語の件名" || !strings.Contains(header, "To: b@example.com, c@example.com") {
+		t.Errorf("header = %q (subject %q)", header, subject)
+	}
+	body, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(encoded, "\r\n", ""))
+	if err != nil || string(body) != "本文\r\n2行目" {
+		t.Errorf("body = %q, %v", body, err)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// grafanaHandler は、SQLite の履歴を Grafana のデータソースとして問い合わせる http.Handler を返します。
+// InfluxDB などを用意しなくても、過去の測定値をグラフにできます。
+//
+//	GET  /          接続確認 (JSON データソースの "Save & test")
+//	POST /metrics   数値の測定値のプロパティ名の一覧 (JSON データソース)
+//	POST /search    同上 (旧版の JSON データソース)
+//	POST /query     期間と対象のプロパティ名を指定した時系列 (JSON データソース)
+//	GET  /series    ?metric=<プロパティ名>&from=<開始>&to=<終了>[&interval_ms=<集計間隔>] の時系列 (Infinity データソース)
+//
+// 時刻は RFC 3339 形式または Unix 時間 (ミリ秒) で指定します。
+func grafanaHandler(h *historyStore) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			writeAPIError(w, http.StatusNotFound, fmt.Errorf("'%s' はありません", r.URL.Path))
+			return
+		}
+		writeAPIJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		names, err := h.metricNames()
+		if err != nil {
+			writeGrafanaError(w, err)
+			return
+		}
+		metrics := make([]map[string]string, 0, len(names))
+		for _, name := range names {
+			metrics = append(metrics, map[string]string{"label": name, "value": name})
+		}
+		writeAPIJSON(w, http.StatusOK, metrics)
+	})
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		names, err := h.metricNames()
+		if err != nil {
+			writeGrafanaError(w, err)
+			return
+		}
+		if names == nil {
+			names = []string{}
+		}
+		writeAPIJSON(w, http.StatusOK, names)
+	})
+	mux.HandleFunc("/query", func(w http.ResponseWriter, r *http.Request) {
+		handleGrafanaQuery(h, w, r)
+	})
+	mux.HandleFunc("/series", func(w http.ResponseWriter, r *http.Request) {
+		handleGrafanaSeries(h, w, r)
+	})
+	return mux
+}
+
+// grafanaQuery は、JSON データソースの /query の本文のうち、使用する項目です。
+type grafanaQuery struct {
+	Range struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	} `json:"range"`
+	IntervalMs int64 `json:"intervalMs"`
+	Targets    []struct {
+		Target string `json:"target"`
+		Hide   bool   `json:"hide"`
+	} `json:"targets"`
+}
+
+// grafanaSeries は、JSON データソースの時系列です。datapoints は [値, Unix 時間 (ミリ秒)] の配列です。
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// handleGrafanaQuery は、JSON データソースの /query に、対象ごとの時系列を返します。
+func handleGrafanaQuery(h *historyStore, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s は使用できません", r.Method))
+		return
+	}
+	var q grafanaQuery
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&q); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("本文を JSON として読み込めませんでした: %w", err))
+		return
+	}
+	from, to, err := parseGrafanaRange(q.Range.From, q.Range.To)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	result := []grafanaSeries{}
+	for _, target := range q.Targets {
+		if target.Hide || target.Target == "" {
+			continue
+		}
+		points, err := h.series(target.Target, from, to, time.Duration(q.IntervalMs)*time.Millisecond)
+		if err != nil {
+			writeGrafanaError(w, err)
+			return
+		}
+		series := grafanaSeries{Target: target.Target, Datapoints: make([][2]float64, 0, len(points))}
+		for _, p := range points {
+			series.Datapoints = append(series.Datapoints, [2]float64{p.Value, float64(p.Time.UnixMilli())})
+		}
+		result = append(result, series)
+	}
+	writeAPIJSON(w, http.StatusOK, result)
+}
+
+// handleGrafanaSeries は、Infinity データソース向けに、1つのプロパティの時系列を {"time", "value"} の配列で返します。
+func handleGrafanaSeries(h *historyStore, w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	metric := query.Get("metric")
+	if metric == "" {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("'metric' の指定が必要です"))
+		return
+	}
+	from, to, err := parseGrafanaRange(query.Get("from"), query.Get("to"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	var interval time.Duration
+	if s := query.Get("interval_ms"); s != "" {
+		ms, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || ms < 0 {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("'interval_ms' は 0 以上の整数である必要があります: '%s'", s))
+			return
+		}
+		interval = time.Duration(ms) * time.Millisecond
+	}
+	points, err := h.series(metric, from, to, interval)
+	if err != nil {
+		writeGrafanaError(w, err)
+		return
+	}
+	type point struct {
+		Time  string  `json:"time"`
+		Value float64 `json:"value"`
+	}
+	result := make([]point, 0, len(points))
+	for _, p := range points {
+		result = append(result, point{p.Time.UTC().Format(time.RFC3339), p.Value})
+	}
+	writeAPIJSON(w, http.StatusOK, result)
+}
+
+// parseGrafanaRange は、問い合わせの期間を読み込みます。
+func parseGrafanaRange(from, to string) (time.Time, time.Time, error) {
+	f, err := parseGrafanaTime(from)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("期間の開始 ('%s') を読み込めませんでした: %w", from, err)
+	}
+	t, err := parseGrafanaTime(to)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("期間の終了 ('%s') を読み込めませんでした: %w", to, err)
+	}
+	if t.Before(f) {
+		return time.Time{}, time.Time{}, fmt.Errorf("期間の終了 ('%s') が開始 ('%s') より前です", to, from)
+	}
+	return f, t, nil
+}
+
+// parseGrafanaTime は、RFC 3339 形式または Unix 時間 (ミリ秒) の時刻を読み込みます。
+func parseGrafanaTime(s string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// writeGrafanaError は、履歴の問い合わせに失敗したことをログに出力し、エラーを返します。
+func writeGrafanaError(w http.ResponseWriter, err error) {
+	log.Printf("[履歴] Grafana の問い合わせに失敗しました: %v", err)
+	writeAPIError(w, http.StatusInternalServerError, err)
+}
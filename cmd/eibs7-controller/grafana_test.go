@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+func TestGrafanaHandler(t *testing.T) {
+	cfg := config.HistoryConfig{File: filepath.Join(t.TempDir(), "history.db")}
+	cfg.Validate()
+	h, err := openHistoryStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.close()
+
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, soc := range []uint8{40, 50, 60} {
+		h.save(&monitor.AuditRecord{
+			Time:         base.Add(time.Duration(i) * time.Minute),
+			Decision:     monitor.AuditStrategy,
+			Measurements: map[string]interface{}{"蓄電池.蓄電残量3": soc, "蓄電池.状態": []byte{0xAB}},
+		})
+	}
+	handler := grafanaHandler(h)
+
+	do := func(method, target, body string, v interface{}) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		if v != nil {
+			if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+				t.Fatalf("%s %s: %v (%s)", method, target, err, rec.Body)
+			}
+		}
+		return rec.Code
+	}
+
+	if code := do("GET", "/", "", nil); code != http.StatusOK {
+		t.Errorf("health check = %d", code)
+	}
+	// Only numeric measurements can be charted.
+	var names []string
+	if do("POST", "/search", "{}", &names); len(names) != 1 || names[0] != "蓄電池.蓄電残量3" {
+		t.Errorf("search = %v", names)
+	}
+	var metrics []map[string]string
+	if do("POST", "/metrics", "{}", &metrics); len(metrics) != 1 || metrics[0]["value"] != "蓄電池.蓄電残量3" {
+		t.Errorf("metrics = %v", metrics)
+	}
+
+	var series []grafanaSeries
+	body := `{"range": {"from": "2025-06-01T12:00:30.000Z", "to": "2025-06-01T12:05:00.000Z"}, "targets": [{"target": "蓄電池.蓄電残量3"}]}`
+	if code := do("POST", "/query", body, &series); code != http.StatusOK || len(series) != 1 {
+		t.Fatalf("query = %d %v", code, series)
+	}
+	want := [][2]float64{{50, float64(base.Add(time.Minute).UnixMilli())}, {60, float64(base.Add(2 * time.Minute).UnixMilli())}}
+	if len(series[0].Datapoints) != 2 || series[0].Datapoints[0] != want[0] || series[0].Datapoints[1] != want[1] {
+		t.Errorf("datapoints = %v, want %v", series[0].Datapoints, want)
+	}
+
+	// Infinity: epoch milliseconds and a 10 minute bucket average the three cycles.
+	var points []struct {
+		Time  string  `json:"time"`
+		Value float64 `json:"value"`
+	}
+	target := fmt.Sprintf("/series?metric=%s&from=%d&to=%d&interval_ms=600000",
+		url.QueryEscape("蓄電池.蓄電残量3"), base.UnixMilli(), base.Add(10*time.Minute).UnixMilli())
+	if code := do("GET", target, "", &points); code != http.StatusOK || len(points) != 1 || points[0].Value != 50 || points[0].Time != "2025-06-01T12:00:00Z" {
+		t.Errorf("series = %d %v", code, points)
+	}
+
+	for _, bad := range []struct{ method, target, body string }{
+		{"POST", "/query", `{"range": {"from": "x", "to": "2025-06-01T12:00:00Z"}}`},
+		{"POST", "/query", `{"range": {"from": "2025-06-01T13:00:00Z", "to": "2025-06-01T12:00:00Z"}}`},
+		{"GET", "/query", ""},
+		{"GET", "/series?from=0&to=1", ""},
+		{"GET", "/series?metric=a&from=0&to=1&interval_ms=-1", ""},
+	} {
+		if code := do(bad.method, bad.target, bad.body, nil); code != http.StatusBadRequest && code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s = %d, want an error", bad.method, bad.target, code)
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor"
+
+	_ "modernc.org/sqlite" // SQLite ドライバ (cgo を使用しない)
+)
+
+// historyMigrations は、履歴のデータベースのスキーマの変更です。
+// i 番目の変更を適用すると、スキーマのバージョン (PRAGMA user_version) は i+1 になります。既存の変更は書き換えず、末尾に追加します。
+var historyMigrations = []string{
+	`CREATE TABLE cycles (
+		id INTEGER PRIMARY KEY,
+		time INTEGER NOT NULL, -- 監視サイクルの開始時刻 (Unix 時間、ミリ秒)
+		decision TEXT NOT NULL,
+		reason TEXT,
+		state TEXT,
+		strategy TEXT,
+		action_mode TEXT,
+		action_charge_power_watts INTEGER,
+		action_discharge_power_watts INTEGER
+	);
+	CREATE INDEX cycles_time ON cycles (time);
+	CREATE TABLE measurements (
+		cycle_id INTEGER NOT NULL REFERENCES cycles (id) ON DELETE CASCADE,
+		name TEXT NOT NULL, -- オブジェクト名.プロパティ名
+		value REAL,         -- 数値の場合
+		text TEXT,          -- 数値以外の場合 (バイト列は16進数)
+		PRIMARY KEY (cycle_id, name)
+	);
+	CREATE TABLE actions (
+		cycle_id INTEGER NOT NULL REFERENCES cycles (id) ON DELETE CASCADE,
+		target TEXT NOT NULL,
+		deoj TEXT NOT NULL,
+		epc TEXT NOT NULL,
+		edt TEXT NOT NULL,
+		tid INTEGER NOT NULL,
+		result TEXT NOT NULL,
+		error TEXT
+	);
+	CREATE INDEX actions_cycle_id ON actions (cycle_id);`,
+	// Grafana の問い合わせで、プロパティ名から期間の測定値を取得するため
+	`CREATE INDEX measurements_name ON measurements (name, cycle_id);`,
+}
+
+// historyStore は、監視サイクルごとの測定値と制御の判断、送信した SetC の結果を SQLite に保存します。
+// 保存期間を過ぎた履歴は、prune_interval_hours ごとに削除します。
+type historyStore struct {
+	mu            sync.Mutex
+	db            *sql.DB
+	retention     time.Duration
+	pruneInterval time.Duration
+	lastPrune     time.Time
+}
+
+// openHistoryStore は、履歴のデータベースを開き、未適用のスキーマの変更を適用します。
+func openHistoryStore(cfg config.HistoryConfig) (*historyStore, error) {
+	db, err := sql.Open("sqlite", cfg.File+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("履歴のデータベース '%s' を開けませんでした: %w", cfg.File, err)
+	}
+	db.SetMaxOpenConns(1) // SQLite への書き込みは1つの接続で行う
+	h := &historyStore{
+		db:            db,
+		retention:     time.Duration(cfg.RetentionDays) * 24 * time.Hour,
+		pruneInterval: time.Duration(cfg.PruneIntervalHours) * time.Hour,
+	}
+	if err := h.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("履歴のデータベース '%s' のスキーマの更新に失敗しました: %w", cfg.File, err)
+	}
+	return h, nil
+}
+
+// migrate は、データベースのスキーマのバージョンより後の変更を順に適用します。
+func (h *historyStore) migrate() error {
+	var version int
+	if err := h.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version > len(historyMigrations) {
+		return fmt.Errorf("スキーマのバージョン (%d) がこのバージョンの対応するもの (%d) より新しいです", version, len(historyMigrations))
+	}
+	for i := version; i < len(historyMigrations); i++ {
+		tx, err := h.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(historyMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("バージョン %d への変更: %w", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		log.Printf("[履歴] データベースのスキーマをバージョン %d に更新しました。", i+1)
+	}
+	return nil
+}
+
+// save は、監視サイクルの記録を保存し、削除の間隔が経過していれば保存期間を過ぎた履歴を削除します。
+// 監査ログの保存先として登録するため、エラーはログに出力します。
+func (h *historyStore) save(r *monitor.AuditRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.insert(r); err != nil {
+		log.Printf("[履歴] 監視サイクルの保存に失敗しました: %v", err)
+	}
+	if r.Time.Sub(h.lastPrune) >= h.pruneInterval {
+		h.lastPrune = r.Time
+		if n, err := h.prune(r.Time); err != nil {
+			log.Printf("[履歴] 古い履歴の削除に失敗しました: %v", err)
+		} else if n > 0 {
+			log.Printf("[履歴] 保存期間を過ぎた %d 件の監視サイクルを削除しました。", n)
+		}
+	}
+}
+
+// insert は、1つの監視サイクルの記録を1つのトランザクションで保存します。
+func (h *historyStore) insert(r *monitor.AuditRecord) error {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var mode sql.NullString
+	var chargePower, dischargePower sql.NullInt64
+	if a := r.Action; a != nil {
+		mode = sql.NullString{String: a.Mode, Valid: a.Mode != ""}
+		if a.ChargePowerWatts != nil {
+			chargePower = sql.NullInt64{Int64: int64(*a.ChargePowerWatts), Valid: true}
+		}
+		if a.DischargePowerWatts != nil {
+			dischargePower = sql.NullInt64{Int64: int64(*a.DischargePowerWatts), Valid: true}
+		}
+	}
+	res, err := tx.Exec(`INSERT INTO cycles (time, decision, reason, state, strategy, action_mode, action_charge_power_watts, action_discharge_power_watts)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.Time.UnixMilli(), r.Decision, r.Reason, r.State, r.Strategy, mode, chargePower, dischargePower)
+	if err != nil {
+		return err
+	}
+	cycleID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	for name, v := range r.Measurements {
+		value, text := historyValue(v)
+		if _, err := tx.Exec("INSERT INTO measurements (cycle_id, name, value, text) VALUES (?, ?, ?, ?)", cycleID, name, value, text); err != nil {
+			return err
+		}
+	}
+	for _, req := range r.Requests {
+		if _, err := tx.Exec("INSERT INTO actions (cycle_id, target, deoj, epc, edt, tid, result, error) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			cycleID, req.Target, req.DEOJ, req.EPC, req.EDT, req.TID, req.Result, req.Error); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// historyValue は、監視データの値を数値の列と文字列の列のどちらかに振り分けます。
+func historyValue(v interface{}) (sql.NullFloat64, sql.NullString) {
+	var f float64
+	switch v := v.(type) {
+	case int:
+		f = float64(v)
+	case int8:
+		f = float64(v)
+	case int16:
+		f = float64(v)
+	case int32:
+		f = float64(v)
+	case int64:
+		f = float64(v)
+	case uint8:
+		f = float64(v)
+	case uint16:
+		f = float64(v)
+	case uint32:
+		f = float64(v)
+	case uint64:
+		f = float64(v)
+	case float32:
+		f = float64(v)
+	case float64:
+		f = v
+	default:
+		return sql.NullFloat64{}, sql.NullString{String: monitor.FormatCSVValue(v), Valid: true}
+	}
+	return sql.NullFloat64{Float64: f, Valid: true}, sql.NullString{}
+}
+
+// metricNames は、数値の測定値が保存されているプロパティ名 (オブジェクト名.プロパティ名) を名前順に返します。
+func (h *historyStore) metricNames() ([]string, error) {
+	rows, err := h.db.Query("SELECT DISTINCT name FROM measurements WHERE value IS NOT NULL ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// historyPoint は、時系列の1点です。
+type historyPoint struct {
+	Time  time.Time
+	Value float64
+}
+
+// series は、name の数値の測定値のうち、from 以上 to 以下の時刻のものを時刻順に返します。
+// interval が正の場合は、interval ごとの平均値にまとめます (時刻は区間の開始時刻)。
+func (h *historyStore) series(name string, from, to time.Time, interval time.Duration) ([]historyPoint, error) {
+	bucket := interval.Milliseconds()
+	if bucket <= 0 {
+		bucket = 1
+	}
+	rows, err := h.db.Query(`SELECT c.time / ? * ? AS t, AVG(m.value)
+		FROM measurements m JOIN cycles c ON c.id = m.cycle_id
+		WHERE m.name = ? AND m.value IS NOT NULL AND c.time BETWEEN ? AND ?
+		GROUP BY t ORDER BY t`,
+		bucket, bucket, name, from.UnixMilli(), to.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var points []historyPoint
+	for rows.Next() {
+		var t int64
+		var v float64
+		if err := rows.Scan(&t, &v); err != nil {
+			return nil, err
+		}
+		points = append(points, historyPoint{time.UnixMilli(t), v})
+	}
+	return points, rows.Err()
+}
+
+// historyReport は、期間の測定値と制御の履歴を集計した結果です。電力量は瞬時値を監視サイクルの間隔で積算した値です。
+type historyReport struct {
+	From, To                       time.Time
+	Cycles                         int            // 期間の監視サイクルの数
+	GeneratedKWh                   float64        // 発電電力量
+	ImportedKWh, ExportedKWh       float64        // 買電・売電電力量
+	ChargedKWh, DischargedKWh      float64        // 蓄電池の充電・放電電力量
+	MinSOC, MaxSOC                 int            // 蓄電残量の最小値・最大値 (%)。蓄電残量の記録がない場合は -1
+	ModeChanges                    int            // 受け付けられた運転モードの設定 (EPC 0xDA) の回数
+	FailedRequests                 map[string]int // 失敗した SetC の数 (結果ごと)
+	FaultCycles, UnreachableCycles int            // 機器の異常・到達不能のため制御しなかった監視サイクルの数
+}
+
+// historyReportMaxGap は、電力量の積算で1つの瞬時値を使用する最長の時間です。
+// 停止していた間など、監視サイクルの間隔がこれより長い場合は、その間の電力量を積算しません。
+const historyReportMaxGap = 10 * time.Minute
+
+// report は、from 以上 to 未満の時刻の監視サイクルを集計します。
+func (h *historyStore) report(from, to time.Time) (historyReport, error) {
+	r := historyReport{From: from, To: to, MinSOC: -1, MaxSOC: -1, FailedRequests: make(map[string]int)}
+	fromMs, toMs := from.UnixMilli(), to.UnixMilli()
+
+	if err := h.db.QueryRow(`SELECT COUNT(*),
+		COALESCE(SUM(decision = ?), 0), COALESCE(SUM(decision = ?), 0)
+		FROM cycles WHERE time >= ? AND time < ?`,
+		monitor.AuditDeviceFault, monitor.AuditUnreachable, fromMs, toMs).Scan(&r.Cycles, &r.FaultCycles, &r.UnreachableCycles); err != nil {
+		return r, err
+	}
+	var minSOC, maxSOC sql.NullFloat64
+	if err := h.db.QueryRow(`SELECT MIN(m.value), MAX(m.value) FROM measurements m JOIN cycles c ON c.id = m.cycle_id
+		WHERE m.name = '蓄電池.蓄電残量3' AND c.time >= ? AND c.time < ?`, fromMs, toMs).Scan(&minSOC, &maxSOC); err != nil {
+		return r, err
+	}
+	if minSOC.Valid {
+		r.MinSOC, r.MaxSOC = int(minSOC.Float64), int(maxSOC.Float64)
+	}
+
+	rows, err := h.db.Query(`SELECT a.epc, a.result, COUNT(*) FROM actions a JOIN cycles c ON c.id = a.cycle_id
+		WHERE c.time >= ? AND c.time < ? GROUP BY a.epc, a.result`, fromMs, toMs)
+	if err != nil {
+		return r, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var epc, result string
+		var n int
+		if err := rows.Scan(&epc, &result, &n); err != nil {
+			return r, err
+		}
+		switch {
+		case result == "Set_Res" && epc == "0xDA":
+			r.ModeChanges += n
+		case result == "SetC_SNA" || result == "timeout" || result == "error":
+			r.FailedRequests[result] += n
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return r, err
+	}
+
+	for name, add := range map[string]func(kwh float64){
+		"住宅用太陽光発電.瞬時発電電力計測値": func(kwh float64) { r.GeneratedKWh += kwh },
+		"系統.瞬時電力計測値": func(kwh float64) {
+			if kwh > 0 {
+				r.ImportedKWh += kwh
+			} else {
+				r.ExportedKWh -= kwh
+			}
+		},
+		"蓄電池.瞬時充放電電力計測値": func(kwh float64) {
+			if kwh > 0 {
+				r.ChargedKWh += kwh
+			} else {
+				r.DischargedKWh -= kwh
+			}
+		},
+	} {
+		if err := h.integrate(name, fromMs, toMs, add); err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// integrate は、name の瞬時電力 (W) を次の監視サイクルまで一定とみなして積算し、区間ごとの電力量 (kWh) を add に渡します。
+func (h *historyStore) integrate(name string, fromMs, toMs int64, add func(kwh float64)) error {
+	rows, err := h.db.Query(`SELECT c.time, m.value FROM measurements m JOIN cycles c ON c.id = m.cycle_id
+		WHERE m.name = ? AND m.value IS NOT NULL AND c.time >= ? AND c.time < ? ORDER BY c.time`, name, fromMs, toMs)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var prevTime int64
+	var prevWatts float64
+	first := true
+	for rows.Next() {
+		var t int64
+		var w float64
+		if err := rows.Scan(&t, &w); err != nil {
+			return err
+		}
+		if !first && t-prevTime <= historyReportMaxGap.Milliseconds() {
+			add(prevWatts * float64(t-prevTime) / float64(time.Hour.Milliseconds()) / 1000)
+		}
+		prevTime, prevWatts, first = t, w, false
+	}
+	return rows.Err()
+}
+
+// prune は、now から保存期間を過ぎた監視サイクルを削除し、削除した件数を返します。測定値と SetC の結果も合わせて削除されます。
+func (h *historyStore) prune(now time.Time) (int64, error) {
+	res, err := h.db.Exec("DELETE FROM cycles WHERE time < ?", now.Add(-h.retention).UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// close は、データベースを閉じます。
+func (h *historyStore) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.db.Close()
+}
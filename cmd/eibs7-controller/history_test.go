@@ -0,0 +1,126 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+func TestHistoryStoreSaveAndPrune(t *testing.T) {
+	cfg := config.HistoryConfig{File: filepath.Join(t.TempDir(), "history.db"), RetentionDays: 7}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	h, err := openHistoryStore(cfg)
+	if err != nil {
+		t.Fatalf("openHistoryStore: %v", err)
+	}
+	defer h.close()
+
+	power := 1500
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	h.save(&monitor.AuditRecord{
+		Time:         base,
+		Decision:     monitor.AuditStrategy,
+		State:        "Charging",
+		Action:       &monitor.AuditAction{Mode: "0x42", ChargePowerWatts: &power},
+		Measurements: map[string]interface{}{"蓄電池.蓄電残量3": uint8(60), "蓄電池.状態": []byte{0xAB}},
+		Requests:     []monitor.AuditRequest{{Target: "192.168.0.10", DEOJ: "027D01", EPC: "0xDA", EDT: "42", TID: 3, Result: "Set_Res"}},
+	})
+
+	var soc float64
+	if err := h.db.QueryRow("SELECT value FROM measurements WHERE name = '蓄電池.蓄電残量3'").Scan(&soc); err != nil || soc != 60 {
+		t.Errorf("soc = %v, %v", soc, err)
+	}
+	var text string
+	if err := h.db.QueryRow("SELECT text FROM measurements WHERE name = '蓄電池.状態'").Scan(&text); err != nil || text != "AB" {
+		t.Errorf("text = %q, %v", text, err)
+	}
+	var result string
+	var charge int
+	if err := h.db.QueryRow("SELECT a.result, c.action_charge_power_watts FROM actions a JOIN cycles c ON c.id = a.cycle_id").Scan(&result, &charge); err != nil || result != "Set_Res" || charge != 1500 {
+		t.Errorf("action = %q, %d, %v", result, charge, err)
+	}
+
+	// A cycle past the prune interval removes cycles older than the retention together with their rows.
+	h.save(&monitor.AuditRecord{Time: base.Add(8 * 24 * time.Hour), Decision: monitor.AuditOutage})
+	for table, want := range map[string]int{"cycles": 1, "measurements": 0, "actions": 0} {
+		var n int
+		if err := h.db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil || n != want {
+			t.Errorf("%s rows = %d (%v), want %d", table, n, err, want)
+		}
+	}
+}
+
+func TestHistoryStoreMigrationIsIdempotent(t *testing.T) {
+	cfg := config.HistoryConfig{File: filepath.Join(t.TempDir(), "history.db")}
+	cfg.Validate()
+	for i := 0; i < 2; i++ {
+		h, err := openHistoryStore(cfg)
+		if err != nil {
+			t.Fatalf("open #%d: %v", i+1, err)
+		}
+		var version int
+		h.db.QueryRow("PRAGMA user_version").Scan(&version)
+		if version != len(historyMigrations) {
+			t.Errorf("user_version = %d, want %d", version, len(historyMigrations))
+		}
+		h.close()
+	}
+}
+
+func TestHistoryStoreReport(t *testing.T) {
+	cfg := config.HistoryConfig{File: filepath.Join(t.TempDir(), "history.db")}
+	cfg.Validate()
+	h, err := openHistoryStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.close()
+
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	cycle := func(offset time.Duration, soc uint8, grid, battery int32, requests ...monitor.AuditRequest) {
+		h.save(&monitor.AuditRecord{
+			Time:     base.Add(offset),
+			Decision: monitor.AuditStrategy,
+			Measurements: map[string]interface{}{
+				"蓄電池.蓄電残量3":          soc,
+				"住宅用太陽光発電.瞬時発電電力計測値": int32(3000),
+				"系統.瞬時電力計測値":         grid,
+				"蓄電池.瞬時充放電電力計測値":     battery,
+			},
+			Requests: requests,
+		})
+	}
+	// Each value holds until the next cycle: 10 minutes at 3 kW is 0.5 kWh.
+	cycle(0, 40, 1000, 2000, monitor.AuditRequest{EPC: "0xDA", Result: "Set_Res"})
+	cycle(10*time.Minute, 60, -500, -1000, monitor.AuditRequest{EPC: "0xEB", Result: "SetC_SNA"})
+	cycle(20*time.Minute, 55, 0, 0)
+	// A gap longer than historyReportMaxGap is not integrated.
+	cycle(3*time.Hour, 50, 1000, 0)
+	cycle(3*time.Hour+5*time.Minute, 50, 0, 0)
+	h.save(&monitor.AuditRecord{Time: base.Add(4 * time.Hour), Decision: monitor.AuditUnreachable})
+
+	r, err := h.report(base, base.Add(5*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	near := func(got, want float64) bool { return got > want-1e-9 && got < want+1e-9 }
+	if !near(r.GeneratedKWh, 1+0.25) || !near(r.ImportedKWh, 1.0/6+1.0/12) || !near(r.ExportedKWh, 1.0/12) {
+		t.Errorf("generated %v, imported %v, exported %v", r.GeneratedKWh, r.ImportedKWh, r.ExportedKWh)
+	}
+	if !near(r.ChargedKWh, 1.0/3) || !near(r.DischargedKWh, 1.0/6) {
+		t.Errorf("charged %v, discharged %v", r.ChargedKWh, r.DischargedKWh)
+	}
+	if r.Cycles != 6 || r.MinSOC != 40 || r.MaxSOC != 60 || r.ModeChanges != 1 || r.FailedRequests["SetC_SNA"] != 1 || r.UnreachableCycles != 1 {
+		t.Errorf("report = %+v", r)
+	}
+
+	// A range without cycles reports no SOC.
+	if r, err := h.report(base.Add(-time.Hour), base); err != nil || r.Cycles != 0 || r.MinSOC != -1 {
+		t.Errorf("empty report = %+v, %v", r, err)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+// httpAPI は、状態の取得 (GET /status)、監視サイクルごとの配信 (/ws) と手動操作 (POST /pause, /resume, /mode, /target-soc) を受け付けます。
+// 状態は、監査ログの保存先として登録して受け取った最後の監視サイクルの記録から返します。
+type httpAPI struct {
+	token    config.Secret
+	override *monitor.ManualOverride
+	health   *monitor.ServiceHealth // /healthz と /readyz で返す状態。監視ループと共有する
+	now      func() time.Time       // テストで差し替えるため
+
+	stream  *liveStream   // /ws に接続しているクライアント
+	history *historyStore // Grafana の問い合わせ (/grafana/) に使用する履歴。[history] が無効の場合は nil
+	elwa    bool          // ELWA のブリッジ (/elapi/v1/) を有効にする
+	nodered *noderedFeed  // Node-RED 向けのイベント (/nodered/)。node_red が無効の場合は nil
+
+	mu   sync.Mutex
+	last *monitor.AuditRecord // 最後の監視サイクルの記録。まだない場合は nil
+}
+
+// newHTTPAPI は、トークンと手動操作の対象を指定して httpAPI を作成します。
+func newHTTPAPI(token config.Secret, o *monitor.ManualOverride) *httpAPI {
+	return &httpAPI{
+		token:    token,
+		override: o,
+		health:   &monitor.ServiceHealth{},
+		stream:   newLiveStream(),
+		now:      o.Now,
+	}
+}
+
+// save は、監視サイクルの記録を /status で返すために保持し、/ws と /nodered/events のクライアントに配信します。
+// 監査ログの保存先として登録します。
+func (a *httpAPI) save(r *monitor.AuditRecord) {
+	if r.Decision == monitor.AuditOutsideCycle {
+		return
+	}
+	a.mu.Lock()
+	a.last = r
+	a.mu.Unlock()
+	a.stream.broadcast(a.status(r))
+	if a.nodered != nil {
+		kind, _ := a.override.Current(a.now())
+		a.nodered.cycle(r, kind)
+	}
+}
+
+// handler は、API のリクエストを処理する http.Handler を返します。/healthz と /readyz 以外のリクエストでトークンを確認します。
+func (a *httpAPI) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", a.handleStatus)
+	mux.HandleFunc("/pause", a.handlePause)
+	mux.HandleFunc("/resume", a.handleResume)
+	mux.HandleFunc("/mode", a.handleMode)
+	mux.HandleFunc("/target-soc", a.handleTargetSOC)
+	mux.HandleFunc("/ws", a.stream.handle)
+	if a.history != nil {
+		mux.Handle("/grafana/", http.StripPrefix("/grafana", grafanaHandler(a.history)))
+	}
+	if a.elwa {
+		mux.Handle("/elapi/v1/", http.StripPrefix("/elapi/v1", a.elwaHandler()))
+	}
+	if a.nodered != nil {
+		mux.HandleFunc("/nodered/events", a.handleNoderedEvents)
+		mux.HandleFunc("/nodered/command", a.handleNoderedCommand)
+	}
+	healthz, readyz := a.health.Handler(false, a.now), a.health.Handler(true, a.now)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 死活確認と準備完了の確認は、probe から参照できるようトークンを確認しない
+		switch r.URL.Path {
+		case "/healthz":
+			healthz(w, r)
+			return
+		case "/readyz":
+			readyz(w, r)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if (r.URL.Path == "/ws" || r.URL.Path == "/nodered/events") && token == "" {
+			token = r.URL.Query().Get("token") // ブラウザの WebSocket と EventSource はヘッダーを指定できないため
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			writeAPIError(w, http.StatusUnauthorized, errors.New("トークンが正しくありません"))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// apiStatus は、GET /status の応答です。
+type apiStatus struct {
+	Time         string                 `json:"time,omitempty"`
+	Decision     string                 `json:"decision,omitempty"`
+	Reason       string                 `json:"reason,omitempty"`
+	State        string                 `json:"state,omitempty"`
+	Strategy     string                 `json:"strategy,omitempty"`
+	Inputs       map[string]interface{} `json:"inputs,omitempty"`     // 運転モード、蓄電残量、充電時間帯と目標の蓄電残量など
+	Thresholds   map[string]int         `json:"thresholds,omitempty"` // 判断に使用した閾値
+	Action       *monitor.AuditAction   `json:"action,omitempty"`     // 制御方式が選択した蓄電池の設定
+	Requests     []monitor.AuditRequest `json:"requests,omitempty"`   // 送信した SetC とその結果
+	Measurements map[string]interface{} `json:"measurements,omitempty"`
+	Override     string                 `json:"override"` // status コマンドと同じ手動操作の状態
+}
+
+// handleStatus は、最後の監視サイクルの測定値と判断、手動操作の状態を返します。
+func (a *httpAPI) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s は使用できません", r.Method))
+		return
+	}
+	a.mu.Lock()
+	last := a.last
+	a.mu.Unlock()
+	writeAPIJSON(w, http.StatusOK, a.status(last))
+}
+
+// status は、監視サイクルの記録と現在の手動操作の状態から apiStatus を作成します。r が nil の場合は手動操作の状態のみです。
+func (a *httpAPI) status(r *monitor.AuditRecord) apiStatus {
+	var status apiStatus
+	if r != nil {
+		status = apiStatus{
+			Time:       r.Time.Format(time.RFC3339),
+			Decision:   r.Decision,
+			Reason:     r.Reason,
+			State:      r.State,
+			Strategy:   r.Strategy,
+			Inputs:     r.Inputs,
+			Thresholds: r.Thresholds,
+			Action:     r.Action,
+			Requests:   r.Requests,
+		}
+		status.Measurements = make(map[string]interface{}, len(r.Measurements))
+		for k, v := range r.Measurements {
+			if b, ok := v.([]byte); ok {
+				v = monitor.FormatCSVValue(b) // バイト列は16進数の文字列にする
+			}
+			status.Measurements[k] = v
+		}
+	}
+	status.Override, _ = a.override.HandleCommand("status", a.now())
+	return status
+}
+
+// apiOverrideRequest は、手動操作の POST の本文です。minutes を省略した場合は monitor.DefaultOverrideMinutes 分間有効です。
+type apiOverrideRequest struct {
+	Mode    string `json:"mode"`    // /mode: "charge" または "auto"
+	Percent int    `json:"percent"` // /target-soc: 目標の蓄電残量 (%)
+	Minutes int    `json:"minutes"`
+}
+
+// handlePause は、自動制御を停止します。
+func (a *httpAPI) handlePause(w http.ResponseWriter, r *http.Request) {
+	a.runCommand(w, r, func(req apiOverrideRequest) (string, error) {
+		return "pause" + apiMinutes(req), nil
+	})
+}
+
+// handleResume は、手動操作と目標の蓄電残量の変更を解除します。
+func (a *httpAPI) handleResume(w http.ResponseWriter, r *http.Request) {
+	a.runCommand(w, r, func(apiOverrideRequest) (string, error) {
+		return "resume", nil
+	})
+}
+
+// handleMode は、運転モードを充電モードまたは自動モードに固定します。
+func (a *httpAPI) handleMode(w http.ResponseWriter, r *http.Request) {
+	a.runCommand(w, r, func(req apiOverrideRequest) (string, error) {
+		switch req.Mode {
+		case "charge", "auto":
+			return req.Mode + apiMinutes(req), nil
+		default:
+			return "", fmt.Errorf("'mode' は \"charge\" または \"auto\" である必要があります: '%s'", req.Mode)
+		}
+	})
+}
+
+// handleTargetSOC は、充電時間帯の目標の蓄電残量を変更します。
+func (a *httpAPI) handleTargetSOC(w http.ResponseWriter, r *http.Request) {
+	a.runCommand(w, r, func(req apiOverrideRequest) (string, error) {
+		return fmt.Sprintf("target %d", req.Percent) + apiMinutes(req), nil
+	})
+}
+
+// apiMinutes は、minutes が指定されている場合にコマンドに付ける引数を返します。
+func apiMinutes(req apiOverrideRequest) string {
+	if req.Minutes == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" %d", req.Minutes)
+}
+
+// runCommand は、POST の本文から手動操作のコマンドを作成し、安全確認を適用して実行します。
+func (a *httpAPI) runCommand(w http.ResponseWriter, r *http.Request, command func(apiOverrideRequest) (string, error)) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s は使用できません", r.Method))
+		return
+	}
+	var req apiOverrideRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("本文を JSON として読み込めませんでした: %w", err))
+			return
+		}
+	}
+	if req.Minutes < 0 {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("'minutes' は正の整数である必要があります: %d", req.Minutes))
+		return
+	}
+	line, err := command(req)
+	if err == nil {
+		var res string
+		if res, err = a.override.HandleGuardedCommand(line, a.now()); err == nil {
+			log.Printf("[HTTP API] %s %s: %s", r.Method, r.URL.Path, res)
+			writeAPIJSON(w, http.StatusOK, map[string]string{"result": res})
+			return
+		}
+	}
+	log.Printf("[HTTP API] %s %s を実行できませんでした: %v", r.Method, r.URL.Path, err)
+	writeAPIError(w, http.StatusBadRequest, err)
+}
+
+// writeAPIJSON は、v を JSON で返します。
+func writeAPIJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeAPIError は、エラーを {"error": "..."} の形式で返します。
+func writeAPIError(w http.ResponseWriter, code int, err error) {
+	writeAPIJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// listenHTTPAPI は、HTTP API の受け付けを開始します。
+func listenHTTPAPI(cfg config.HTTPAPIConfig, api *httpAPI) (*http.Server, error) {
+	listener, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' で待ち受けできませんでした: %w", cfg.Listen, err)
+	}
+	srv := &http.Server{Handler: api.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("[HTTP API] 受け付けが停止しました: %v", err)
+		}
+	}()
+	return srv, nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+func TestHTTPAPI(t *testing.T) {
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.Local)
+	o := monitor.NewManualOverride(time.Local)
+	api := newHTTPAPI("secret", o)
+	api.now = func() time.Time { return now }
+	srv := httptest.NewServer(api.handler())
+	defer srv.Close()
+
+	do := func(method, path, token, body string) (int, map[string]interface{}) {
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		var v map[string]interface{}
+		if err := json.NewDecoder(res.Body).Decode(&v); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		return res.StatusCode, v
+	}
+
+	if code, _ := do("GET", "/status", "", ""); code != http.StatusUnauthorized {
+		t.Errorf("status without a token = %d", code)
+	}
+	if code, _ := do("GET", "/status", "wrong", ""); code != http.StatusUnauthorized {
+		t.Errorf("status with a wrong token = %d", code)
+	}
+
+	// Before the first cycle only the override state is known.
+	if code, v := do("GET", "/status", "secret", ""); code != http.StatusOK || v["override"] != "none" || v["decision"] != nil {
+		t.Errorf("status = %d %v", code, v)
+	}
+
+	api.save(&monitor.AuditRecord{
+		Time:         now,
+		Decision:     monitor.AuditStrategy,
+		State:        "charging",
+		Inputs:       map[string]interface{}{"operation_mode": "0x42"},
+		Action:       &monitor.AuditAction{Mode: "0x42"},
+		Measurements: map[string]interface{}{"蓄電池.蓄電残量3": uint8(50), "蓄電池.運転モード設定": []byte{0x42}},
+	})
+	code, v := do("GET", "/status", "secret", "")
+	if code != http.StatusOK || v["state"] != "charging" {
+		t.Fatalf("status = %d %v", code, v)
+	}
+	measurements, _ := v["measurements"].(map[string]interface{})
+	if measurements["蓄電池.蓄電残量3"] != 50.0 || measurements["蓄電池.運転モード設定"] != "42" {
+		t.Errorf("measurements = %v", measurements)
+	}
+	if action, _ := v["action"].(map[string]interface{}); action["mode"] != "0x42" {
+		t.Errorf("action = %v", v["action"])
+	}
+
+	if code, v := do("POST", "/mode", "secret", `{"mode": "charge", "minutes": 30}`); code != http.StatusOK || v["result"] != "charge until 10:30:00" {
+		t.Errorf("mode = %d %v", code, v)
+	}
+	if kind, _ := o.Current(now); kind != monitor.OverrideCharge || !o.IsGuarded() {
+		t.Errorf("override = %s (guarded %t), want a guarded charge", kind, o.IsGuarded())
+	}
+	if code, v := do("POST", "/target-soc", "secret", `{"percent": 80}`); code != http.StatusOK || v["result"] != "target 80% until 11:00:00" {
+		t.Errorf("target-soc = %d %v", code, v)
+	}
+	if code, v := do("POST", "/pause", "secret", ""); code != http.StatusOK || v["result"] != "pause until 11:00:00" {
+		t.Errorf("pause = %d %v", code, v)
+	}
+	if code, _ := do("POST", "/resume", "secret", ""); code != http.StatusOK {
+		t.Errorf("resume = %d", code)
+	}
+	if kind, _ := o.Current(now); kind != monitor.OverrideNone {
+		t.Errorf("override still active after resume: %s", kind)
+	}
+	if _, ok := o.TargetSOCPercent(now); ok {
+		t.Error("target SOC still set after resume")
+	}
+
+	for _, bad := range []struct{ method, path, body string }{
+		{"POST", "/mode", `{"mode": "discharge"}`},
+		{"POST", "/target-soc", `{"percent": 120}`},
+		{"POST", "/pause", `{"minutes": -1}`},
+		{"POST", "/pause", `not json`},
+	} {
+		if code, v := do(bad.method, bad.path, "secret", bad.body); code != http.StatusBadRequest || v["error"] == nil {
+			t.Errorf("%s %s %s = %d %v, want an error", bad.method, bad.path, bad.body, code, v)
+		}
+	}
+	if code, _ := do("GET", "/pause", "secret", ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /pause = %d", code)
+	}
+}
+
+func TestHTTPAPIHealth(t *testing.T) {
+	start := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	now := start
+	api := newHTTPAPI("secret", monitor.NewManualOverride(time.UTC))
+	health := &monitor.ServiceHealth{}
+	api.health = health
+	api.now = func() time.Time { return now }
+	handler := api.handler()
+	get := func(path string) (int, monitor.HealthStatus) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil)) // no token
+		var s monitor.HealthStatus
+		if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+			t.Fatalf("GET %s: %v (%s)", path, err, rec.Body)
+		}
+		return rec.Code, s
+	}
+
+	if code, _ := get("/healthz"); code != http.StatusServiceUnavailable {
+		t.Errorf("healthz before start = %d", code)
+	}
+	health.Start(start, 3*time.Minute)
+	if code, _ := get("/healthz"); code != http.StatusOK {
+		t.Errorf("healthz after start = %d", code)
+	}
+	if code, s := get("/readyz"); code != http.StatusServiceUnavailable || len(s.Problems) != 2 {
+		t.Errorf("readyz before the first cycle = %d %+v", code, s)
+	}
+
+	health.Observe(start, true, true)
+	now = start.Add(time.Minute)
+	health.Heartbeat(now)
+	if code, s := get("/readyz"); code != http.StatusOK || !s.DeviceReachable || !s.ConfigValid || s.LastSuccess != start.Format(time.RFC3339) {
+		t.Errorf("readyz after a cycle = %d %+v", code, s)
+	}
+
+	// The device becomes unreachable and a reload fails: not ready, but still live.
+	health.Observe(now, false, false)
+	health.ConfigLoaded(errors.New("bad config"))
+	if code, s := get("/readyz"); code != http.StatusServiceUnavailable || s.DeviceReachable || s.ConfigValid || len(s.Problems) != 2 {
+		t.Errorf("readyz when unreachable = %d %+v", code, s)
+	}
+	if code, _ := get("/healthz"); code != http.StatusOK {
+		t.Errorf("healthz when unreachable = %d", code)
+	}
+
+	// The loop stalls.
+	now = now.Add(3 * time.Minute)
+	if code, s := get("/healthz"); code != http.StatusServiceUnavailable || s.LastCycle != start.Add(time.Minute).Format(time.RFC3339) {
+		t.Errorf("healthz after a stall = %d %+v", code, s)
+	}
+}
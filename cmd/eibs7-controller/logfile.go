@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// ローテーションしたファイルの名前に付ける日時の形式
+const logFileRotationSuffix = "20060102-150405"
+
+// rotatingLogFile は、サイズと経過時間でローテーションするログファイルです。io.Writer として log の出力先に使用します。
+type rotatingLogFile struct {
+	mu       sync.Mutex
+	cfg      config.LogFileConfig
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	now      func() time.Time // テストで時刻を差し替えるため
+}
+
+// openRotatingLogFile は、ログファイルを追記で開きます。
+func openRotatingLogFile(cfg config.LogFileConfig) (*rotatingLogFile, error) {
+	l := &rotatingLogFile{cfg: cfg, now: time.Now}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// open は、ログファイルを追記で開き、現在のサイズを取得します。
+func (l *rotatingLogFile) open() error {
+	f, err := os.OpenFile(l.cfg.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("ログファイル '%s' を開けませんでした: %w", l.cfg.Path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("ログファイル '%s' の情報を取得できませんでした: %w", l.cfg.Path, err)
+	}
+	l.file, l.size, l.openedAt = f, info.Size(), l.now()
+	return nil
+}
+
+// Write は、ログを書き込みます。書き込むとサイズの上限を超える場合と、ローテーションの間隔が経過した場合は、先にローテーションします。
+func (l *rotatingLogFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return 0, os.ErrClosed
+	}
+	maxSize := int64(l.cfg.MaxSizeMB) * 1024 * 1024
+	interval := time.Duration(l.cfg.RotateIntervalHours) * time.Hour
+	if l.size > 0 && (l.size+int64(len(p)) > maxSize || interval > 0 && l.now().Sub(l.openedAt) >= interval) {
+		if err := l.rotate(); err != nil {
+			// ローテーションできなくても、ログは現在のファイルに書き続ける
+			fmt.Fprintf(os.Stderr, "ログファイルのローテーションに失敗しました: %v\n", err)
+		}
+	}
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// rotate は、現在のファイルを日時を付けた名前に変更して新しいファイルを開き、保持期間を過ぎたファイルを削除します。
+func (l *rotatingLogFile) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	rotated := l.cfg.Path + "." + l.now().Format(logFileRotationSuffix)
+	renameErr := os.Rename(l.cfg.Path, rotated)
+	if err := l.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+	l.removeOldFiles()
+	return nil
+}
+
+// removeOldFiles は、ローテーションしたファイルのうち、max_backups 個を超える古いものと max_age_days を過ぎたものを削除します。
+func (l *rotatingLogFile) removeOldFiles() {
+	matches, _ := filepath.Glob(l.cfg.Path + ".*")
+	type backup struct {
+		path string
+		at   time.Time
+	}
+	var backups []backup
+	for _, path := range matches {
+		at, err := time.ParseInLocation(logFileRotationSuffix, strings.TrimPrefix(path, l.cfg.Path+"."), time.Local)
+		if err != nil {
+			continue // ローテーションしたファイル以外は削除しない
+		}
+		backups = append(backups, backup{path, at})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].at.After(backups[j].at) })
+	for i, b := range backups {
+		if i >= l.cfg.MaxBackups || l.cfg.MaxAgeDays > 0 && l.now().Sub(b.at) > time.Duration(l.cfg.MaxAgeDays)*24*time.Hour {
+			os.Remove(b.path)
+		}
+	}
+}
+
+// Close は、ログファイルを閉じます。
+func (l *rotatingLogFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+func TestRotatingLogFile(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.LogFileConfig{Path: filepath.Join(dir, "controller.log"), MaxSizeMB: 1, RotateIntervalHours: 24}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	cfg.MaxBackups = 2
+	clock := time.Date(2025, 6, 6, 12, 0, 0, 0, time.Local)
+	now := func() time.Time { return clock }
+
+	l := &rotatingLogFile{cfg: cfg, now: now}
+	if err := l.open(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	chunk := bytes.Repeat([]byte("x"), 600*1024)
+	l.Write(chunk)
+	clock = clock.Add(time.Second)
+	l.Write(chunk) // exceeds 1 MB and rotates
+	backups, _ := filepath.Glob(cfg.Path + ".*")
+	if len(backups) != 1 || filepath.Base(backups[0]) != "controller.log.20250606-120001" {
+		t.Fatalf("backups after the size limit = %v", backups)
+	}
+
+	// The rotation interval rotates a small file too.
+	clock = clock.Add(24 * time.Hour)
+	l.Write([]byte("line\n"))
+	clock = clock.Add(24 * time.Hour)
+	l.Write([]byte("line\n"))
+	backups, _ = filepath.Glob(cfg.Path + ".*")
+	if len(backups) != 2 {
+		t.Fatalf("backups = %v, want the 2 newest", backups)
+	}
+	if _, err := os.Stat(cfg.Path + ".20250606-120001"); !os.IsNotExist(err) {
+		t.Errorf("the oldest backup should be removed beyond max_backups")
+	}
+	if data, _ := os.ReadFile(cfg.Path); string(data) != "line\n" {
+		t.Errorf("current log = %q", data)
+	}
+
+	// Backups older than max_age_days are removed.
+	l.cfg.MaxAgeDays = 1
+	clock = clock.Add(25 * time.Hour)
+	l.Write([]byte("line\n"))
+	backups, _ = filepath.Glob(cfg.Path + ".*")
+	if len(backups) != 1 || filepath.Base(backups[0]) != "controller.log.20250609-130001" {
+		t.Errorf("backups after max_age_days = %v", backups)
+	}
+}
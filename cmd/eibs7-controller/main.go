@@ -0,0 +1,515 @@
+// eibs7-controller は、太陽光発電の余剰電力に合わせて、ECHONET Lite で蓄電池 (EIBS7) の運転モードと充電電力を制御します。
+//
+// 設定は config.toml で行います (-config で指定、-check-config で検証)。
+// サブコマンド get / set / discover / sendraw / watch で、機器のプロパティの取得・設定、機器の探索、任意のフレームの送信、通知の受信を直接行えます。
+// tui で、実行中のコントローラーの状態を端末に表示して手動操作を行えます。
+// backtest で、記録した監視データを制御に入力し、設定を変更した場合に送信する SetC と系統からの充電電力量を確認できます。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/syslog"
+	"os" // ファイル読み込み用に os パッケージをインポート
+	"path/filepath"
+	"strings"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite" // モジュールパスはご自身のものに合わせてください
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+// 設定ファイル名
+const configFileName = "config.toml"
+
+// デバッグログを出力するかどうか (-debug フラグで有効化)
+var debugLogging = false
+
+// debugf は、デバッグログが有効な場合のみログを出力します。
+func debugf(format string, v ...interface{}) {
+	if !debugLogging {
+		return
+	}
+	log.Output(2, "[DEBUG] "+fmt.Sprintf(format, v...))
+}
+
+// configSearchPaths は、-config を指定しない場合に設定ファイルを探すパスを優先順に返します。
+// カレントディレクトリ、$XDG_CONFIG_HOME/eibs7-controller ($XDG_CONFIG_HOME が未設定の場合は ~/.config)、/etc/eibs7-controller の順です。
+func configSearchPaths() []string {
+	paths := []string{configFileName}
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "eibs7-controller", configFileName))
+	}
+	return append(paths, filepath.Join("/etc/eibs7-controller", configFileName))
+}
+
+// findConfigFile は、paths のうち最初に存在する設定ファイルのパスを返します。
+func findConfigFile(paths []string) (string, error) {
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("設定ファイルが見つかりません (検索したパス: %s)", strings.Join(paths, ", "))
+}
+
+// setupLogger は、ログの出力先を console (通常は標準出力) とsyslogの両方に設定します。
+// syslog に接続できた場合は、終了時に閉じるための syslog ライターを返します。
+func setupLogger(console io.Writer) *syslog.Writer {
+	// syslogライターを作成
+	// 優先度は INFO、ファシリティは LOG_USER、タグは "eibs7-controller"
+	syslogWriter, err := syslog.New(syslog.LOG_INFO|syslog.LOG_USER, "eibs7-controller")
+	if err != nil {
+		// syslogに接続できない場合でも、標準出力へのログは機能するように
+		// log.Printf を使い、処理は続行する。
+		log.Printf("警告: syslogへの接続に失敗しました: %v。ログは標準出力にのみ出力されます。", err)
+		return nil
+	}
+
+	// 標準出力とsyslogの両方に書き込むMultiWriterを作成
+	multiWriter := io.MultiWriter(console, syslogWriter)
+
+	// logパッケージのデフォルトロガーの出力先をMultiWriterに設定
+	// これ以降、log.Printf などで出力したものは、両方に書き込まれる
+	log.SetOutput(multiWriter)
+
+	// ログのフォーマットに日付と時刻、短いファイル名（行番号付き）を含める
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	log.Println("ロガーの設定が完了しました。標準出力とsyslogの両方に出力します。")
+	return syslogWriter
+}
+
+// logConfig は、読み込んだ設定ファイルと、デフォルト値を適用した設定の一覧をログに出力します。
+func logConfig(path string, cfg *config.Config) {
+	log.Printf("設定ファイル '%s' を読み込みました。", path)
+	log.Printf("  TargetIP: %s", cfg.TargetIP)
+	log.Printf("  TargetID: %s", cfg.TargetID)
+	log.Printf("  ControllerEOJ: %02X%02X%02X", cfg.Controller.ClassGroupCode, cfg.Controller.ClassCode, cfg.Controller.InstanceCode)
+	log.Printf("  MonitorIntervalSeconds: %d", cfg.MonitorIntervalSeconds)
+	log.Printf("  Timezone: %s", cfg.Loc())
+	log.Printf("  ChargeStartTime: %s", cfg.ChargeStartTime)
+	log.Printf("  ChargeEndTime: %s", cfg.ChargeEndTime)
+	log.Printf("  ChargeCron: %s", cfg.ChargeCron)
+	log.Printf("  ChargeTargetSOCPercent: %d", cfg.ChargeTargetSOCPercent)
+	log.Printf("  ChargePowerRampWatts: %d", cfg.ChargePowerRampWatts)
+	log.Printf("  ChargePowerGain: %.2f", cfg.ChargePowerGain)
+	log.Printf("  BatteryInstances: %v", cfg.BatteryInstances)
+	log.Printf("  Targets: %+v", cfg.Targets)
+	log.Printf("  Devices: %+v", cfg.Devices)
+	log.Printf("  EPCOverrides: %+v", cfg.EPCOverrides)
+	log.Printf("  ReserveSOCPercent: %d", cfg.ReserveSOCPercent)
+	log.Printf("  TargetReachedAction: %s", cfg.TargetReachedAction)
+	log.Printf("  FullSOCPercent: %d", cfg.FullSOCPercent)
+	log.Printf("  EmptySOCPercent: %d", cfg.EmptySOCPercent)
+	log.Printf("  ChargeTimesWeekend: %+v", cfg.ChargeTimesWeekend)
+	log.Printf("  Holidays: %+v", cfg.Holidays)
+	log.Printf("  Outage: %+v", cfg.Outage)
+	log.Printf("  DischargeTimes: %+v", cfg.DischargeTimes)
+	log.Printf("  SeasonalChargeCaps: %+v", cfg.SeasonalChargeCaps)
+	log.Printf("  ExportMaximization: %+v", cfg.ExportMaximization)
+	log.Printf("  Strategy: %v", cfg.Strategy)
+	log.Printf("  StrategyPeriods: %+v", cfg.StrategyPeriods)
+	log.Printf("  Profiles: %+v", cfg.Profiles)
+	log.Printf("  ProfileSchedule: %+v", cfg.ProfileSchedule)
+	log.Printf("  ChargePowerUpdateIntervalMinutes: %d", cfg.ChargePowerUpdateIntervalMinutes)
+	log.Printf("  AutoModeThresholdWatts: %d", cfg.AutoModeThresholdWatts)
+	log.Printf("  ChargeModeThresholdWatts: %d", cfg.ChargeModeThresholdWatts)
+	log.Printf("  SurplusSmoothingSamples: %d", cfg.SurplusSmoothingSamples)
+	log.Printf("  SurplusSmoothingAlpha: %.2f", cfg.SurplusSmoothingAlpha)
+	log.Printf("  MinChargeModeDwellMinutes: %d", cfg.MinChargeModeDwellMinutes)
+	log.Printf("  MinAutoModeDwellMinutes: %d", cfg.MinAutoModeDwellMinutes)
+	log.Printf("  ModeChangeInhibitMinutes: %d", cfg.ModeChangeInhibitMinutes)
+	log.Printf("  ConflictBackoffMinutes: %d", cfg.ConflictBackoffMinutes)
+	log.Printf("  EnergyCounters: %t", cfg.EnergyCounters)
+	log.Printf("  MinSurplusPowerJudgmentMinutes: %d", cfg.MinSurplusPowerJudgmentMinutes)
+	log.Printf("  SurplusPowerMarginWatts: %d", cfg.SurplusPowerMarginWatts)
+	log.Printf("  MaxChargePowerWatts: %d", cfg.MaxChargePowerWatts)
+	log.Printf("  DeviceMaxChargeWatts: %d", cfg.DeviceMaxChargeWatts)
+	log.Printf("  LogMonitoringData: %t", cfg.LogMonitoringData)
+	log.Printf("  MonitoringCSVDir: %s", cfg.MonitoringCSVDir)
+	log.Printf("  DryRun: %t", cfg.DryRun)
+	log.Printf("  EnableControl: %t", cfg.ControlEnabled())
+	log.Printf("  ShutdownOperationMode: %s", cfg.ShutdownOperationMode)
+	log.Printf("  LoopStallTimeoutSeconds: %d", cfg.LoopStallTimeoutSeconds)
+	log.Printf("  MaxDailyGridChargeWh: %.0f", cfg.MaxDailyGridChargeWh)
+	log.Printf("  GridChargeRolloverHour: %d", cfg.GridChargeRolloverHour)
+	log.Printf("  ReceiveBufferSize: %d", cfg.ReceiveBufferSize)
+	log.Printf("  ResponseTimeoutMilliseconds: %d", cfg.ResponseTimeoutMilliseconds)
+	log.Printf("  RequestRetries: %d", cfg.RequestRetries)
+	log.Printf("  RetryBackoffMilliseconds: %d", cfg.RetryBackoffMilliseconds)
+	log.Printf("  LivenessCheckIntervalSeconds: %d", cfg.LivenessCheckIntervalSeconds)
+	log.Printf("  UnreachableFailureThreshold: %d", cfg.UnreachableFailureThreshold)
+	log.Printf("  NotificationDedupWindowSeconds: %d", cfg.NotificationDedupWindowSeconds)
+	log.Printf("  NotificationMode: %t", cfg.NotificationMode)
+	log.Printf("  NotificationMaxAgeSeconds: %d", cfg.NotificationMaxAgeSeconds)
+	log.Printf("  SlowPollIntervalSeconds: %d", cfg.SlowPollIntervalSeconds)
+	log.Printf("  EveningReserve: %+v", cfg.EveningReserve)
+	log.Printf("  Capture: %+v", cfg.Capture)
+	log.Printf("  LogFile: %+v", cfg.LogFile)
+	log.Printf("  AuditLog: %+v", cfg.AuditLog)
+	log.Printf("  History: %+v", cfg.History)
+	log.Printf("  Postgres: %+v", cfg.Postgres)
+	log.Printf("  MQTT: %+v", cfg.MQTT)
+	log.Printf("  StormAlert: %+v", cfg.StormAlert)
+	log.Printf("  OpenADR: %+v", cfg.OpenADR)
+	log.Printf("  Forecast: %+v", cfg.Forecast)
+	log.Printf("  ChargePlanning: %+v", cfg.ChargePlanning)
+	log.Printf("  PriceSchedule: %+v", cfg.PriceSchedule)
+	log.Printf("  DischargeCap: %+v", cfg.DischargeCap)
+	log.Printf("  Override: %+v", cfg.Override)
+	log.Printf("  HTTPAPI: %+v", cfg.HTTPAPI)
+	log.Printf("  Alerts: %+v", cfg.Alerts)
+	log.Printf("  EmailReport: %+v", cfg.EmailReport)
+	log.Printf("  Modbus: %+v", cfg.Modbus)
+	log.Printf("  ArchiveUpload: %+v", cfg.ArchiveUpload)
+	log.Printf("  GoogleSheets: %+v", cfg.GoogleSheets)
+	log.Printf("  StateWebhooks: %+v", cfg.StateWebhooks)
+	log.Printf("  ImportGuard: %+v", cfg.ImportGuard)
+	log.Printf("  PeakShaving: %+v", cfg.PeakShaving)
+	log.Printf("  EVCharger: %+v", cfg.EVCharger)
+	log.Printf("  SmartMeter: %+v", cfg.SmartMeter)
+	log.Printf("  SurplusDiversion: %+v", cfg.SurplusDiversion)
+	log.Printf("  DemandResponse: %+v", cfg.DemandResponse)
+}
+
+func main() {
+	// サブコマンド (get / set / discover / sendraw / watch) は機器と直接やり取りして終了し、tui は実行中のコントローラーに接続する。
+	// backtest は機器と通信せずに記録を制御に入力する。monitor または省略時は監視と制御を行う
+	if len(os.Args) > 1 {
+		if _, ok := deviceCommands[os.Args[1]]; ok {
+			os.Exit(runDeviceCommand(os.Args[1], os.Args[2:], os.Stdout, os.Stderr))
+		}
+		if os.Args[1] == "tui" {
+			os.Exit(runTUI(os.Args[2:], os.Stdout, os.Stderr))
+		}
+		if os.Args[1] == "backtest" {
+			os.Exit(runBacktest(os.Args[2:], os.Stdout, os.Stderr))
+		}
+		if os.Args[1] == "monitor" {
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		}
+	}
+
+	// コマンドライン引数の定義
+	flag.Usage = printUsage
+	loopCount := flag.Int("loop", -1, "監視ループの実行回数を指定します。-1の場合は無限に実行します。")
+	flag.BoolVar(&debugLogging, "debug", false, "デバッグログを出力します。")
+	recordFile := flag.String("record", "", "送受信したデータグラムを指定したファイルに記録します (回帰テストの再生用)。")
+	dryRun := flag.Bool("dry-run", false, "監視のみ行い、蓄電池の設定 (SetC) を送信せずに設定内容をログに出力します。")
+	checkConfigOnly := flag.Bool("check-config", false, "設定ファイルを読み込んで検証し、デフォルト値を適用した設定の一覧を出力して終了します。機器とは通信しません。誤りがある場合は終了コード 1 で終了します。")
+	once := flag.Bool("once", false, "監視サイクルを1回だけ実行し、結果に応じた終了コードで終了します。cron などから定期的に実行する場合に使用します。")
+	format := flag.String("format", "text", "-once の結果の出力形式を指定します。json の場合は測定値と計算値、判断を JSON で標準出力に出力し、ログは標準エラー出力に出力します。")
+	configFile := flag.String("config", "", "設定ファイルのパスを指定します。未指定の場合はカレントディレクトリ、$XDG_CONFIG_HOME/eibs7-controller、/etc/eibs7-controller の順に config.toml を探します。")
+	flag.Parse()
+
+	if *checkConfigOnly {
+		os.Exit(runConfigCheck(*configFile))
+	}
+	if *format != "text" && *format != "json" {
+		log.Fatalf("-format には text または json を指定してください: '%s'", *format)
+	}
+	if *format == "json" && !*once {
+		log.Fatal("-format=json は -once と組み合わせて指定してください。")
+	}
+	if *once {
+		*loopCount = 1
+	}
+	// -once の結果の終了コード。ほかの defer (ログや履歴を閉じる処理) の後に終了するため、最初に登録する
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
+	// JSON の結果を標準出力に出力する場合は、ログを標準エラー出力に出力する
+	console := io.Writer(os.Stdout)
+	if *format == "json" {
+		console = os.Stderr
+	}
+	if syslogWriter := setupLogger(console); syslogWriter != nil { // ロガーを設定
+		defer syslogWriter.Close() // 終了時に syslog へのログを送り切る
+	}
+
+	// --- 設定ファイルの読み込み ---
+	configPath := *configFile
+	if configPath == "" {
+		path, err := findConfigFile(configSearchPaths())
+		if err != nil {
+			log.Fatalf("設定の読み込みに失敗しました: %v", err)
+		}
+		configPath = path
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		log.Fatalf("設定の読み込みに失敗しました: %v", err)
+	}
+	if *dryRun {
+		cfg.DryRun = true // コマンドライン引数は設定ファイルより優先する
+	}
+	if cfg.LogFile.Enabled { // 標準出力と syslog に加えてファイルにも出力する
+		logFile, err := openRotatingLogFile(cfg.LogFile)
+		if err != nil {
+			log.Fatalf("ログファイルの設定に失敗しました: %v", err)
+		}
+		defer logFile.Close()
+		log.SetOutput(io.MultiWriter(log.Writer(), logFile))
+	}
+	logConfig(configPath, cfg)
+
+	// --- フレームキャプチャ (SIGUSR2 で開始/停止を切り替え) ---
+	capture = newFrameCapture(cfg.Capture.File)
+	if cfg.Capture.Enabled {
+		if err := capture.start(); err != nil {
+			log.Printf("[キャプチャ] キャプチャを開始できませんでした: %v", err)
+		} else {
+			log.Printf("[キャプチャ] キャプチャを開始しました (%s)", cfg.Capture.File)
+		}
+	}
+	defer capture.stop()
+
+	// --- 制御の判断の監査ログ ---
+	audit := &monitor.DecisionAudit{}
+	if cfg.AuditLog.Enabled {
+		if audit, err = monitor.OpenDecisionAudit(cfg.AuditLog.File); err != nil {
+			log.Fatalf("監査ログの設定に失敗しました: %v", err)
+		}
+		log.Printf("[監査ログ] 制御の判断を '%s' に記録します。", cfg.AuditLog.File)
+	}
+	defer audit.Close()
+
+	// --- Webhook による異常の通知 ---
+	alerts := &monitor.WebhookAlerter{}
+	if cfg.Alerts.Enabled {
+		alerts = monitor.NewWebhookAlerter(cfg.Alerts)
+		defer alerts.Close()
+		log.Printf("[アラート] 異常などを %s の Webhook に通知します (同じ種類の通知の間隔: %d 分)。", cfg.Alerts.Format, cfg.Alerts.MinIntervalMinutes)
+	}
+
+	// --- 測定値と制御の履歴 (SQLite) ---
+	var history *historyStore
+	if cfg.History.Enabled {
+		var err error
+		history, err = openHistoryStore(cfg.History)
+		if err != nil {
+			log.Fatalf("履歴の設定に失敗しました: %v", err)
+		}
+		defer history.close()
+		audit.AddStore(history.save)
+		log.Printf("[履歴] 測定値と制御の履歴を '%s' に保存します (保存期間: %d 日)。", cfg.History.File, cfg.History.RetentionDays)
+	}
+	if cfg.Postgres.Enabled {
+		sink, err := openPostgresSink(cfg.Postgres)
+		if err != nil {
+			log.Fatalf("PostgreSQL の設定に失敗しました: %v", err)
+		}
+		defer sink.close()
+		audit.AddStore(sink.save)
+		log.Printf("[PostgreSQL] 測定値と制御の履歴をテーブル '%s*' に保存します。", cfg.Postgres.TablePrefix)
+	}
+
+	// --- 制御の状態の変化の Webhook ---
+	if len(cfg.StateWebhooks) > 0 {
+		webhooks, err := newStateWebhooks(cfg.StateWebhooks)
+		if err != nil {
+			log.Fatalf("[Webhook] Webhook の設定に失敗しました: %v", err)
+		}
+		defer webhooks.close()
+		audit.AddStore(webhooks.save)
+		log.Printf("[Webhook] 制御の状態が変わったときに %d 件の Webhook に送信します。", len(cfg.StateWebhooks))
+	}
+
+	// --- MQTT による監視データと制御の状態の配信 ---
+	if cfg.MQTT.Enabled {
+		publisher, disconnect, err := openMQTTPublisher(cfg.MQTT)
+		if err != nil {
+			log.Fatalf("MQTT の設定に失敗しました: %v", err)
+		}
+		defer disconnect()
+		audit.AddStore(publisher.save)
+		log.Printf("[MQTT] 監視データと制御の状態を '%s/...' に配信します (QoS: %d, retain: %t)。", cfg.MQTT.TopicPrefix, cfg.MQTT.QoS, cfg.MQTT.Retain)
+	}
+	watchCaptureToggleSignal(capture)
+
+	// --- ECHONET Lite 通信 ---
+	// 機器によっては応答を送信元ポートではなく 3610 番ポート宛てに返すため、3610 番ポートで待ち受ける
+	udpTransport, err := echonetlite.ListenUDP(echonetlite.Port)
+	if err != nil {
+		log.Fatalf("UDPポート %d でのListenに失敗しました: %v", echonetlite.Port, err)
+	}
+	defer udpTransport.Close()
+	var transport echonetlite.Transport = &capturingTransport{Transport: udpTransport, capture: capture}
+
+	// --- 送受信の記録 (回帰テストで再生するため) ---
+	if *recordFile != "" {
+		f, err := os.OpenFile(*recordFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			log.Fatalf("記録ファイル '%s' を開けませんでした: %v", *recordFile, err)
+		}
+		defer f.Close()
+		recorder := echonetlite.NewRecordingTransport(transport, f)
+		defer func() {
+			if err := recorder.Err(); err != nil {
+				log.Printf("記録ファイル '%s' への書き込みに失敗しました: %v", *recordFile, err)
+			}
+		}()
+		transport = recorder
+		log.Printf("送受信したデータグラムを '%s' に記録します。", *recordFile)
+	}
+	client := monitor.NewEchonetClient(transport, cfg.TargetIP, time.Duration(cfg.ResponseTimeoutMilliseconds)*time.Millisecond) // 設定ファイルから読み込んだIPアドレスを使用
+	client.Configure(cfg)
+	client.DryRun, client.Debug = cfg.DryRun, debugLogging
+	if !cfg.ControlEnabled() {
+		log.Println("[監視のみ] enable_control = false のため、監視・計算・ログ出力のみ行い、蓄電池や機器の設定 (SetC) は送信しません。")
+	} else if cfg.DryRun {
+		log.Println("[ドライラン] ドライランモードです。蓄電池の設定 (SetC) は送信せず、設定内容をログに出力します。")
+	}
+
+	// --- 定期実行のための Ticker を作成 ---
+	ticker := time.NewTicker(time.Duration(cfg.MonitorIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	log.Printf("監視を開始します。監視間隔: %d秒", cfg.MonitorIntervalSeconds)
+
+	// --- メインループ (監視サイクル) ---
+	if cfg.DiscoverTargets {
+		monitor.DiscoverInstances(client, cfg)
+	}
+	health := &monitor.ServiceHealth{}
+	m := monitor.New(cfg, client, monitor.Options{Audit: audit, Alerts: alerts, Health: health})
+	if err := m.CheckCapabilities(); err != nil {
+		log.Fatalf("[機能確認] %v", err)
+	}
+
+	// --- 手動操作 (UNIX ドメインソケットでコマンドを受け付ける) ---
+	if cfg.Override.Enabled {
+		listener, err := listenOverrideSocket(cfg.Override.Socket, m.Override)
+		if err != nil {
+			log.Fatalf("[手動操作] 手動操作の受け付けを開始できませんでした: %v", err)
+		}
+		defer listener.Close()
+		log.Printf("[手動操作] '%s' で手動操作のコマンドを受け付けます。", cfg.Override.Socket)
+	}
+
+	// --- HTTP API (状態の取得と手動操作) ---
+	if cfg.HTTPAPI.Enabled {
+		api := newHTTPAPI(cfg.HTTPAPI.Token, m.Override)
+		api.health = health
+		api.history = history
+		api.elwa = cfg.HTTPAPI.ELWA
+		if cfg.HTTPAPI.NodeRED {
+			api.nodered = newNoderedFeed()
+		}
+		srv, err := listenHTTPAPI(cfg.HTTPAPI, api)
+		if err != nil {
+			log.Fatalf("[HTTP API] HTTP API の受け付けを開始できませんでした: %v", err)
+		}
+		defer srv.Close()
+		audit.AddStore(api.save)
+		log.Printf("[HTTP API] 'http://%s/' で状態の取得と手動操作を受け付けます。", cfg.HTTPAPI.Listen)
+	}
+
+	// --- Modbus TCP (測定値のレジスタと手動操作) ---
+	if cfg.Modbus.Enabled {
+		gw := newModbusGateway(cfg.Modbus.AllowWrites, m.Override)
+		listener, err := listenModbus(cfg.Modbus, gw)
+		if err != nil {
+			log.Fatalf("[Modbus] Modbus TCP の受け付けを開始できませんでした: %v", err)
+		}
+		defer listener.Close()
+		audit.AddStore(gw.save)
+		log.Printf("[Modbus] '%s' で Modbus TCP を受け付けます (書き込み: %t)。", cfg.Modbus.Listen, cfg.Modbus.AllowWrites)
+	}
+
+	// SIGINT/SIGTERM を受信したら、実行中の監視サイクルの終了後にループを抜ける
+	shutdown := watchShutdownSignal()
+	// SIGHUP を受信したら、次の監視サイクルの開始前に設定ファイルを読み込み直す
+	reload := watchReloadSignal()
+
+	// --- 日ごとのまとめのメール ---
+	if cfg.EmailReport.Enabled {
+		go newEmailReporter(cfg.EmailReport, history, cfg.Loc()).run(shutdown)
+		log.Printf("[メール] 毎日 %s に、その日のまとめを %s に送信します。", cfg.EmailReport.Time, strings.Join(cfg.EmailReport.To, ", "))
+	}
+
+	// --- 日ごとの行のスプレッドシートへの追記 ---
+	if cfg.GoogleSheets.Enabled {
+		sheets, err := newSheetsReporter(cfg.GoogleSheets, history, cfg.Loc())
+		if err != nil {
+			log.Fatalf("[スプレッドシート] サービスアカウントの鍵を読み込めませんでした: %v", err)
+		}
+		go sheets.run(shutdown)
+		log.Printf("[スプレッドシート] 毎日 %s に、その日の電力量をシート '%s' に追記します。", cfg.GoogleSheets.Time, cfg.GoogleSheets.Sheet)
+	}
+
+	// --- 日ごとの CSV のアップロード ---
+	if cfg.ArchiveUpload.Enabled {
+		uploader := newArchiveUploader(cfg.ArchiveUpload, cfg.MonitoringCSVDir, cfg.Loc())
+		m.OnCSVRollover(uploader.rollover)
+		go uploader.run(shutdown)
+		log.Printf("[アーカイブ] 前日以前の監視データの CSV を %s/%s にアップロードします。", cfg.ArchiveUpload.Endpoint, cfg.ArchiveUpload.Bucket)
+	}
+
+	// 監視ループが停止した場合 (ソケットでのブロックやサイクル中の panic の繰り返し) は、蓄電池を自動モードに戻す
+	stall := newStallWatchdog(time.Duration(cfg.LoopStallTimeoutSeconds)*time.Second, func() {
+		for _, c := range m.Clients() {
+			if err := c.SendBatteryOperationModeNoWait(0x46); err != nil { // 0x46: 自動モード
+				log.Printf("[ウォッチドッグ] 蓄電池の運転モード設定（自動）に失敗しました (%s): %v", c.TargetIP, err)
+			}
+		}
+	}, time.Now())
+	go stall.run(shutdown)
+	health.Start(time.Now(), time.Duration(cfg.LoopStallTimeoutSeconds)*time.Second)
+
+	// -once の結果として、監視サイクルの記録を受け取る
+	var report *onceReport
+	if *once {
+		report = &onceReport{}
+		audit.AddStore(report.save)
+	}
+
+	var nextCycle time.Time
+	completed := false
+loop:
+	for i := 0; *loopCount == -1 || i < *loopCount; i++ {
+		if i > 0 {
+			if cfg.NotificationMode {
+				if !client.ReceiveNotifications(nextCycle, shutdown) { // 次のサイクルまで通知を受信する
+					break loop
+				}
+			} else {
+				select {
+				case <-ticker.C: // 2回目以降はtickerを待つ
+				case <-shutdown:
+					break loop
+				}
+			}
+		}
+		select {
+		case <-reload:
+			m.ReloadConfig(configPath, *dryRun)
+		default:
+		}
+		nextCycle = time.Now().Add(time.Duration(cfg.MonitorIntervalSeconds) * time.Second)
+		if completed = monitor.RunCycleRecovering(m); completed {
+			stall.heartbeat(time.Now())
+			health.Heartbeat(time.Now())
+		}
+	}
+
+	select {
+	case <-shutdown:
+		for _, c := range m.Clients() {
+			monitor.RestoreSafeOperationMode(c, cfg.ShutdownOperationMode)
+		}
+	default:
+	}
+	if report != nil {
+		exitCode = report.finish(completed, *format, os.Stdout)
+	}
+	log.Println("終了します。")
+}
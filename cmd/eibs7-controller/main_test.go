@@ -0,0 +1,27 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "testing"
+)
+
+func TestFindConfigFile(t *testing.T) {
+    dir := t.TempDir()
+    missing := dir + "/missing.toml"
+    found := dir + "/config.toml"
+    if err := os.WriteFile(found, []byte(`target_ip = "192.168.0.10"`), 0o644); err != nil { t.Fatalf("write: %v", err) }
+
+    path, err := findConfigFile([]string{missing, found})
+    if err != nil || path != found { t.Errorf("findConfigFile = %q, %v, want %q", path, err, found) }
+    if _, err := findConfigFile([]string{missing}); err == nil {
+        t.Errorf("expected error when no config file exists")
+    }
+}
+
+func TestConfigSearchPaths(t *testing.T) {
+    t.Setenv("XDG_CONFIG_HOME", "/tmp/xdg")
+    paths := configSearchPaths()
+    want := []string{"config.toml", "/tmp/xdg/eibs7-controller/config.toml", "/etc/eibs7-controller/config.toml"}
+    if fmt.Sprint(paths) != fmt.Sprint(want) { t.Errorf("configSearchPaths = %v, want %v", paths, want) }
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+// 入力レジスタ (ファンクションコード 0x04、読み取り専用) のアドレス
+const (
+	modbusInputSOC           = iota // 蓄電残量 (%)
+	modbusInputMode                 // 蓄電池の運転モード設定 (ECHONET Lite の値、例: 0x46 = 自動)
+	modbusInputBatteryPower         // 蓄電池の瞬時充放電電力 (W、符号付き、正: 充電)
+	modbusInputPVPower              // 太陽光発電の瞬時発電電力 (W)
+	modbusInputGridPower            // 系統の瞬時電力 (W、符号付き、正: 買電)
+	modbusInputChargeSetting        // 蓄電池の充電電力設定値 (W)
+	modbusInputAge                  // 最後の監視サイクルからの経過時間 (秒)
+	modbusInputCount
+)
+
+// 保持レジスタ (ファンクションコード 0x03 で読み取り、allow_writes の場合は 0x06, 0x10 で書き込み) のアドレス
+const (
+	modbusHoldingOverride  = iota // 手動操作 (0: なし、1: pause、2: charge、3: auto)。0 の書き込みは resume
+	modbusHoldingMinutes          // 手動操作と目標の蓄電残量の変更の有効期間 (分)。次の書き込みから使用する
+	modbusHoldingTargetSOC        // 充電時間帯の目標の蓄電残量 (%)。0 は設定どおり (書き込みは 1〜100。解除は手動操作に 0 を書き込む)
+	modbusHoldingCount
+)
+
+// 取得できていない値のレジスタの値
+const (
+	modbusUnavailable       = 0xFFFF
+	modbusUnavailableSigned = 0x8000 // 符号付きの値 (-32768)
+)
+
+// Modbus の例外コード
+const (
+	modbusIllegalFunction    = 0x01
+	modbusIllegalAddress     = 0x02
+	modbusIllegalValue       = 0x03
+	modbusServerDeviceFailed = 0x04
+)
+
+// modbusGateway は、最後の監視サイクルの測定値を入力レジスタ、手動操作を保持レジスタとして公開します。
+// 手動操作は HTTP API と同じく、自動制御と同じモード変更の抑制時間と最低リザーブを適用します。
+type modbusGateway struct {
+	allowWrites bool
+	override    *monitor.ManualOverride
+	now         func() time.Time // テストで差し替えるため
+
+	mu      sync.Mutex
+	last    *monitor.AuditRecord // 最後の監視サイクルの記録。まだない場合は nil
+	minutes int                  // 手動操作の有効期間 (分)
+}
+
+// newModbusGateway は、書き込みの可否と手動操作の対象を指定して modbusGateway を作成します。
+func newModbusGateway(allowWrites bool, o *monitor.ManualOverride) *modbusGateway {
+	return &modbusGateway{
+		allowWrites: allowWrites,
+		override:    o,
+		now:         o.Now,
+		minutes:     monitor.DefaultOverrideMinutes,
+	}
+}
+
+// save は、監視サイクルの記録を入力レジスタとして返すために保持します。監査ログの保存先として登録します。
+func (g *modbusGateway) save(r *monitor.AuditRecord) {
+	if r.Decision == monitor.AuditOutsideCycle {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.last = r
+}
+
+// inputRegisters は、入力レジスタの値を返します。
+func (g *modbusGateway) inputRegisters() []uint16 {
+	g.mu.Lock()
+	last := g.last
+	g.mu.Unlock()
+	regs := make([]uint16, modbusInputCount)
+	for i := range regs {
+		regs[i] = modbusUnavailable
+	}
+	regs[modbusInputBatteryPower] = modbusUnavailableSigned
+	regs[modbusInputGridPower] = modbusUnavailableSigned
+	if last == nil {
+		return regs
+	}
+	unsigned := func(key string) uint16 {
+		if v, ok := elwaNumber(last.Measurements[key]); ok {
+			return uint16(clampRegister(v.(int64), 0, 0xFFFE))
+		}
+		return modbusUnavailable
+	}
+	signed := func(key string) uint16 {
+		if v, ok := elwaNumber(last.Measurements[key]); ok {
+			return uint16(int16(clampRegister(v.(int64), -32767, 32767)))
+		}
+		return modbusUnavailableSigned
+	}
+	regs[modbusInputSOC] = unsigned("蓄電池.蓄電残量3")
+	regs[modbusInputMode] = unsigned("蓄電池.運転モード設定")
+	regs[modbusInputBatteryPower] = signed("蓄電池.瞬時充放電電力計測値")
+	regs[modbusInputPVPower] = unsigned("住宅用太陽光発電.瞬時発電電力計測値")
+	regs[modbusInputGridPower] = signed("系統.瞬時電力計測値")
+	regs[modbusInputChargeSetting] = unsigned("蓄電池.充電電力設定値")
+	regs[modbusInputAge] = uint16(clampRegister(int64(g.now().Sub(last.Time)/time.Second), 0, 0xFFFE))
+	return regs
+}
+
+// clampRegister は、v を lo 以上 hi 以下に制限します。
+func clampRegister(v, lo, hi int64) int64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// holdingRegisters は、保持レジスタの値を返します。
+func (g *modbusGateway) holdingRegisters() []uint16 {
+	now := g.now()
+	kind, _ := g.override.Current(now)
+	target, _ := g.override.TargetSOCPercent(now)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	regs := make([]uint16, modbusHoldingCount)
+	regs[modbusHoldingOverride] = uint16(kind)
+	regs[modbusHoldingMinutes] = uint16(g.minutes)
+	regs[modbusHoldingTargetSOC] = uint16(target)
+	return regs
+}
+
+// writeHolding は、保持レジスタに書き込み、手動操作を実行します。失敗した場合は例外コードを返します。
+func (g *modbusGateway) writeHolding(addr int, value uint16) byte {
+	var command string
+	switch addr {
+	case modbusHoldingOverride:
+		kind := monitor.OverrideKind(value)
+		if kind < monitor.OverrideNone || kind > monitor.OverrideAuto {
+			return modbusIllegalValue
+		}
+		command = "resume"
+		if kind != monitor.OverrideNone {
+			command = fmt.Sprintf("%s %d", kind, g.currentMinutes())
+		}
+	case modbusHoldingMinutes:
+		if value == 0 {
+			return modbusIllegalValue
+		}
+		g.mu.Lock()
+		g.minutes = int(value)
+		g.mu.Unlock()
+		return 0
+	case modbusHoldingTargetSOC:
+		if value == 0 || value > 100 {
+			return modbusIllegalValue
+		}
+		command = fmt.Sprintf("target %d %d", value, g.currentMinutes())
+	default:
+		return modbusIllegalAddress
+	}
+	result, err := g.override.HandleGuardedCommand(command, g.now())
+	if err != nil {
+		log.Printf("[Modbus] 手動操作 '%s' に失敗しました: %v", command, err)
+		return modbusServerDeviceFailed
+	}
+	log.Printf("[Modbus] 手動操作 '%s' を受け付けました: %s", command, result)
+	return 0
+}
+
+// currentMinutes は、手動操作の有効期間 (分) を返します。
+func (g *modbusGateway) currentMinutes() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.minutes
+}
+
+// handlePDU は、1つの要求の PDU (ファンクションコードとデータ) を処理し、応答の PDU を返します。
+func (g *modbusGateway) handlePDU(pdu []byte) []byte {
+	fc := pdu[0]
+	exception := func(code byte) []byte { return []byte{fc | 0x80, code} }
+	switch fc {
+	case 0x03, 0x04: // Read Holding Registers, Read Input Registers
+		if len(pdu) != 5 {
+			return exception(modbusIllegalValue)
+		}
+		addr, count := int(binary.BigEndian.Uint16(pdu[1:])), int(binary.BigEndian.Uint16(pdu[3:]))
+		if count < 1 || count > 125 {
+			return exception(modbusIllegalValue)
+		}
+		regs := g.inputRegisters()
+		if fc == 0x03 {
+			regs = g.holdingRegisters()
+		}
+		if addr+count > len(regs) {
+			return exception(modbusIllegalAddress)
+		}
+		resp := []byte{fc, byte(2 * count)}
+		for _, v := range regs[addr : addr+count] {
+			resp = binary.BigEndian.AppendUint16(resp, v)
+		}
+		return resp
+	case 0x06, 0x10: // Write Single Register, Write Multiple Registers
+		if !g.allowWrites {
+			return exception(modbusIllegalFunction)
+		}
+		if fc == 0x06 {
+			if len(pdu) != 5 {
+				return exception(modbusIllegalValue)
+			}
+			if code := g.writeHolding(int(binary.BigEndian.Uint16(pdu[1:])), binary.BigEndian.Uint16(pdu[3:])); code != 0 {
+				return exception(code)
+			}
+			return pdu // 要求と同じ内容を返す
+		}
+		if len(pdu) < 6 {
+			return exception(modbusIllegalValue)
+		}
+		addr, count := int(binary.BigEndian.Uint16(pdu[1:])), int(binary.BigEndian.Uint16(pdu[3:]))
+		if count < 1 || count > 123 || int(pdu[5]) != 2*count || len(pdu) != 6+2*count {
+			return exception(modbusIllegalValue)
+		}
+		if addr+count > modbusHoldingCount {
+			return exception(modbusIllegalAddress)
+		}
+		// 期間を先に書き込めるよう、アドレスの小さい順ではなく期間 → 目標 → 手動操作の順に反映する
+		for _, reg := range []int{modbusHoldingMinutes, modbusHoldingTargetSOC, modbusHoldingOverride} {
+			if reg < addr || reg >= addr+count {
+				continue
+			}
+			if code := g.writeHolding(reg, binary.BigEndian.Uint16(pdu[6+2*(reg-addr):])); code != 0 {
+				return exception(code)
+			}
+		}
+		return pdu[:5]
+	default:
+		return exception(modbusIllegalFunction)
+	}
+}
+
+// serveModbusConn は、1つの接続の要求を、接続が閉じられるまで処理します。
+func serveModbusConn(conn net.Conn, g *modbusGateway) {
+	defer conn.Close()
+	header := make([]byte, 7) // MBAP ヘッダー: トランザクション ID, プロトコル ID, 長さ, ユニット ID
+	for {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+		if _, err := io.ReadFull(conn, header); err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("[Modbus] 要求を受信できませんでした (%s): %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		length := int(binary.BigEndian.Uint16(header[4:]))
+		if binary.BigEndian.Uint16(header[2:]) != 0 || length < 2 || length > 254 {
+			log.Printf("[Modbus] Modbus TCP の要求ではないため切断します (%s)", conn.RemoteAddr())
+			return
+		}
+		pdu := make([]byte, length-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			log.Printf("[Modbus] 要求を受信できませんでした (%s): %v", conn.RemoteAddr(), err)
+			return
+		}
+		resp := g.handlePDU(pdu)
+		frame := append([]byte{}, header[:4]...)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(resp)+1))
+		frame = append(frame, header[6])
+		frame = append(frame, resp...)
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if _, err := conn.Write(frame); err != nil {
+			log.Printf("[Modbus] 応答を送信できませんでした (%s): %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// listenModbus は、Modbus TCP の受け付けを開始します。
+func listenModbus(cfg config.ModbusConfig, g *modbusGateway) (net.Listener, error) {
+	listener, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' で待ち受けできませんでした: %w", cfg.Listen, err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					log.Printf("[Modbus] 接続を受け付けられませんでした: %v", err)
+				}
+				return
+			}
+			go serveModbusConn(conn, g)
+		}
+	}()
+	return listener, nil
+}
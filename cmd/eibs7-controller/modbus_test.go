@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+func TestModbusGatewayRegisters(t *testing.T) {
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	o := monitor.NewManualOverride(time.UTC)
+	g := newModbusGateway(false, o)
+	g.now = func() time.Time { return now }
+
+	read := func(fc byte, addr, count uint16) []uint16 {
+		t.Helper()
+		resp := g.handlePDU([]byte{fc, byte(addr >> 8), byte(addr), byte(count >> 8), byte(count)})
+		if resp[0] != fc || int(resp[1]) != 2*int(count) {
+			t.Fatalf("read 0x%02X %d+%d = % X", fc, addr, count, resp)
+		}
+		regs := make([]uint16, count)
+		for i := range regs {
+			regs[i] = binary.BigEndian.Uint16(resp[2+2*i:])
+		}
+		return regs
+	}
+
+	// Before the first cycle every value is unavailable.
+	if regs := read(0x04, 0, modbusInputCount); regs[modbusInputSOC] != 0xFFFF || regs[modbusInputGridPower] != 0x8000 {
+		t.Errorf("input registers before the first cycle = %v", regs)
+	}
+
+	g.save(&monitor.AuditRecord{
+		Time:     now.Add(-30 * time.Second),
+		Decision: monitor.AuditStrategy,
+		Measurements: map[string]interface{}{
+			"蓄電池.蓄電残量3":          uint8(80),
+			"蓄電池.運転モード設定":        uint8(0x42),
+			"蓄電池.瞬時充放電電力計測値":     int32(-1500),
+			"住宅用太陽光発電.瞬時発電電力計測値": int32(3200),
+			"系統.瞬時電力計測値":         int32(-40000), // clamped
+			"蓄電池.充電電力設定値":        uint32(2000),
+		},
+	})
+	want := []uint16{80, 0x42, uint16(0xFFFF - 1500 + 1), 3200, uint16(0xFFFF - 32767 + 1), 2000, 30}
+	regs := read(0x04, 0, modbusInputCount)
+	for i := range want {
+		if regs[i] != want[i] {
+			t.Errorf("input register %d = %d, want %d", i, regs[i], want[i])
+		}
+	}
+	if regs := read(0x03, 0, modbusHoldingCount); regs[modbusHoldingOverride] != 0 || regs[modbusHoldingMinutes] != 60 || regs[modbusHoldingTargetSOC] != 0 {
+		t.Errorf("holding registers = %v", regs)
+	}
+
+	for _, bad := range []struct {
+		pdu  []byte
+		code byte
+	}{
+		{[]byte{0x04, 0x00, 0x05, 0x00, 0x05}, modbusIllegalAddress},
+		{[]byte{0x04, 0x00, 0x00, 0x00, 0x00}, modbusIllegalValue},
+		{[]byte{0x06, 0x00, 0x00, 0x00, 0x02}, modbusIllegalFunction}, // read-only by default
+		{[]byte{0x01, 0x00, 0x00, 0x00, 0x01}, modbusIllegalFunction},
+	} {
+		if resp := g.handlePDU(bad.pdu); len(resp) != 2 || resp[0] != bad.pdu[0]|0x80 || resp[1] != bad.code {
+			t.Errorf("% X = % X, want exception %d", bad.pdu, resp, bad.code)
+		}
+	}
+}
+
+func TestModbusGatewayWrites(t *testing.T) {
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	o := monitor.NewManualOverride(time.UTC)
+	g := newModbusGateway(true, o)
+	g.now = func() time.Time { return now }
+
+	// Minutes, target SOC and charge in one Write Multiple Registers request.
+	pdu := []byte{0x10, 0x00, 0x00, 0x00, 0x03, 0x06, 0x00, 0x02, 0x00, 0x1E, 0x00, 0x50}
+	if resp := g.handlePDU(pdu); len(resp) != 5 || resp[0] != 0x10 {
+		t.Fatalf("write multiple = % X", resp)
+	}
+	if kind, until := o.Current(now); kind != monitor.OverrideCharge || !until.Equal(now.Add(30*time.Minute)) || !o.IsGuarded() {
+		t.Errorf("override = %v until %v (guarded: %t)", kind, until, o.IsGuarded())
+	}
+	if percent, ok := o.TargetSOCPercent(now); !ok || percent != 80 {
+		t.Errorf("target SOC = %d, %t", percent, ok)
+	}
+
+	// Writing 0 to the override register resumes normal control.
+	if resp := g.handlePDU([]byte{0x06, 0x00, 0x00, 0x00, 0x00}); resp[0] != 0x06 {
+		t.Fatalf("write single = % X", resp)
+	}
+	if kind, _ := o.Current(now); kind != monitor.OverrideNone {
+		t.Errorf("override after resume = %v", kind)
+	}
+	for _, pdu := range [][]byte{
+		{0x06, 0x00, 0x00, 0x00, 0x07}, // unknown override
+		{0x06, 0x00, 0x02, 0x00, 0x65}, // 101%
+		{0x06, 0x00, 0x01, 0x00, 0x00}, // 0 minutes
+	} {
+		if resp := g.handlePDU(pdu); resp[0] != 0x86 || resp[1] != modbusIllegalValue {
+			t.Errorf("% X = % X, want an illegal value exception", pdu, resp)
+		}
+	}
+}
+
+func TestModbusTCP(t *testing.T) {
+	g := newModbusGateway(false, monitor.NewManualOverride(time.UTC))
+	g.save(&monitor.AuditRecord{Time: time.Now(), Decision: monitor.AuditStrategy, Measurements: map[string]interface{}{"蓄電池.蓄電残量3": uint8(55)}})
+	listener, err := listenModbus(config.ModbusConfig{Listen: "127.0.0.1:0"}, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// Transaction 0x1234, unit 1, read input register 0.
+	if _, err := conn.Write([]byte{0x12, 0x34, 0x00, 0x00, 0x00, 0x06, 0x01, 0x04, 0x00, 0x00, 0x00, 0x01}); err != nil {
+		t.Fatal(err)
+	}
+	resp := make([]byte, 11)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x12, 0x34, 0x00, 0x00, 0x00, 0x05, 0x01, 0x04, 0x02, 0x00, 55}
+	if string(resp) != string(want) {
+		t.Errorf("response = % X, want % X", resp, want)
+	}
+}
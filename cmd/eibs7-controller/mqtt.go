@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// mqttMessage は、配信する1つのメッセージです。
+type mqttMessage struct {
+	topic   string
+	payload []byte
+}
+
+// mqttPublisher は、監視サイクルごとに監視データと制御の状態を配信します。
+// トピックは次のとおりです。
+//   - <topic_prefix>/measurements: 監視データと計算値 (「オブジェクト名.プロパティ名」をキーとする JSON)
+//   - <topic_prefix>/state: 制御の状態と判断、選択した設定 (JSON)
+//   - <topic_prefix>/transition: 制御の状態が変わった場合のみ、遷移前と遷移後の状態 (JSON)
+type mqttPublisher struct {
+	prefix    string
+	qos       byte
+	retain    bool
+	publish   func(topic string, qos byte, retain bool, payload []byte) // テストで差し替えるため
+	lastState string
+}
+
+// openMQTTPublisher は、ブローカーに接続して mqttPublisher を作成します。
+// 接続が切れた場合は自動的に再接続し、接続していない間の監視サイクルの配信は破棄します。
+func openMQTTPublisher(cfg config.MQTTConfig) (*mqttPublisher, func(), error) {
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(string(cfg.Password)).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Printf("[MQTT] ブローカーとの接続が切れました: %v", err)
+		}).
+		SetOnConnectHandler(func(mqtt.Client) {
+			log.Printf("[MQTT] ブローカー '%s' に接続しました。", cfg.Broker)
+		})
+	if strings.HasPrefix(cfg.Broker, "ssl://") || strings.HasPrefix(cfg.Broker, "tls://") || strings.HasPrefix(cfg.Broker, "mqtts://") {
+		tlsConfig, err := cfg.TLSConfig()
+		if err != nil {
+			return nil, nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+	client := mqtt.NewClient(opts)
+	// SetConnectRetry により接続できるまで再試行するため、起動時は接続を待たない
+	client.Connect()
+
+	p := &mqttPublisher{
+		prefix: cfg.TopicPrefix,
+		qos:    byte(cfg.QoS),
+		retain: cfg.Retain,
+		publish: func(topic string, qos byte, retain bool, payload []byte) {
+			if !client.IsConnectionOpen() {
+				return
+			}
+			client.Publish(topic, qos, retain, payload) // 監視ループを止めないよう、完了は待たない
+		},
+	}
+	disconnect := func() { client.Disconnect(250) }
+	return p, disconnect, nil
+}
+
+// save は、監視サイクルの記録を配信します。監査ログの保存先として登録します。
+func (p *mqttPublisher) save(r *monitor.AuditRecord) {
+	for _, msg := range p.messages(r) {
+		p.publish(msg.topic, p.qos, p.retain, msg.payload)
+	}
+}
+
+// messages は、監視サイクルの記録から配信するメッセージを作成します。
+// 監視サイクル以外で送信した SetC の記録は配信しません。
+func (p *mqttPublisher) messages(r *monitor.AuditRecord) []mqttMessage {
+	if r.Decision == monitor.AuditOutsideCycle {
+		return nil
+	}
+	var msgs []mqttMessage
+	add := func(topic string, v interface{}) {
+		payload, err := json.Marshal(v)
+		if err != nil {
+			log.Printf("[MQTT] %s の変換に失敗しました: %v", topic, err)
+			return
+		}
+		msgs = append(msgs, mqttMessage{p.prefix + "/" + topic, payload})
+	}
+
+	if len(r.Measurements) > 0 {
+		values := make(map[string]interface{}, len(r.Measurements)+1)
+		for k, v := range r.Measurements {
+			if b, ok := v.([]byte); ok {
+				v = monitor.FormatCSVValue(b) // バイト列は16進数の文字列にする
+			}
+			values[k] = v
+		}
+		values["time"] = r.Time.Format(time.RFC3339)
+		add("measurements", values)
+	}
+
+	// 制御方式による制御では状態機械の状態を、それ以外は判断 (outage, override など) を状態とする
+	state := r.State
+	if state == "" {
+		state = r.Decision
+	}
+	add("state", struct {
+		Time     string               `json:"time"`
+		State    string               `json:"state"`
+		Decision string               `json:"decision"`
+		Reason   string               `json:"reason,omitempty"`
+		Strategy string               `json:"strategy,omitempty"`
+		Action   *monitor.AuditAction `json:"action,omitempty"`
+	}{r.Time.Format(time.RFC3339), state, r.Decision, r.Reason, r.Strategy, r.Action})
+	if state != p.lastState {
+		add("transition", struct {
+			Time string `json:"time"`
+			From string `json:"from,omitempty"`
+			To   string `json:"to"`
+		}{r.Time.Format(time.RFC3339), p.lastState, state})
+		p.lastState = state
+	}
+	return msgs
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+func TestMQTTPublisherMessages(t *testing.T) {
+	type published struct {
+		topic   string
+		qos     byte
+		retain  bool
+		payload map[string]interface{}
+	}
+	var got []published
+	p := &mqttPublisher{prefix: "home/eibs7", qos: 1, retain: true, publish: func(topic string, qos byte, retain bool, payload []byte) {
+		var v map[string]interface{}
+		if err := json.Unmarshal(payload, &v); err != nil {
+			t.Fatalf("%s: %v", topic, err)
+		}
+		got = append(got, published{topic, qos, retain, v})
+	}}
+
+	now := time.Date(2025, 6, 6, 12, 0, 0, 0, time.UTC)
+	p.save(&monitor.AuditRecord{
+		Time:         now,
+		Decision:     monitor.AuditStrategy,
+		State:        "Charging",
+		Measurements: map[string]interface{}{"蓄電池.蓄電残量3": uint8(60), "蓄電池.状態": []byte{0xAB}},
+	})
+	if len(got) != 3 || got[0].topic != "home/eibs7/measurements" || got[1].topic != "home/eibs7/state" || got[2].topic != "home/eibs7/transition" {
+		t.Fatalf("published = %+v", got)
+	}
+	if got[0].payload["蓄電池.蓄電残量3"] != float64(60) || got[0].payload["蓄電池.状態"] != "AB" || got[0].qos != 1 || !got[0].retain {
+		t.Errorf("measurements = %+v", got[0])
+	}
+	if got[2].payload["to"] != "Charging" {
+		t.Errorf("transition = %v", got[2].payload)
+	}
+
+	// The transition topic is only published when the state changes; other decisions become the state.
+	got = nil
+	p.save(&monitor.AuditRecord{Time: now.Add(time.Minute), Decision: monitor.AuditStrategy, State: "Charging"})
+	if len(got) != 1 || got[0].topic != "home/eibs7/state" {
+		t.Fatalf("published without a state change = %+v", got)
+	}
+	got = nil
+	p.save(&monitor.AuditRecord{Time: now.Add(2 * time.Minute), Decision: monitor.AuditOutage})
+	if len(got) != 2 || got[1].payload["from"] != "Charging" || got[1].payload["to"] != "outage" {
+		t.Errorf("published for the outage = %+v", got)
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+// Node-RED のフローから扱いやすいイベントの API です。
+// イベントは入れ子のない JSON で、topic で種類を区別します (msg.topic にそのまま使用できます)。
+//
+//	GET  /nodered/events   Accept: text/event-stream の場合は Server-Sent Events で配信し続ける。
+//	                       それ以外はロングポーリングで、?after=<seq> より後のイベントの配列を返す (なければ ?timeout=<秒> まで待つ)
+//	POST /nodered/command  手動操作のコマンド。本文は {"command": "charge", "minutes": 30} または "charge 30" のテキスト
+
+// Node-RED のイベントの設定
+const (
+	noderedBuffer         = 64               // 保持する最近のイベントの数。取りこぼしたクライアントは保持している分から受け取る
+	noderedDefaultTimeout = 30 * time.Second // ロングポーリングのデフォルトの待ち時間
+	noderedMaxTimeout     = 120 * time.Second
+	noderedKeepAlive      = 30 * time.Second // SSE の接続を維持するためのコメントの間隔
+)
+
+// noderedEvent は、Node-RED に配信するイベントです。取得できていない値は省略します。
+type noderedEvent struct {
+	Seq   uint64 `json:"seq"`
+	Topic string `json:"topic"` // "cycle" (監視サイクルごと)、"mode" (運転モードの変化)、"override" (手動操作の変化)、"command" (/nodered/command の結果)
+	Time  string `json:"time"`
+
+	Decision string `json:"decision,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	Strategy string `json:"strategy,omitempty"`
+
+	Mode               string `json:"mode,omitempty"`          // 蓄電池の運転モード設定 (例: "0x42")
+	PreviousMode       string `json:"previous_mode,omitempty"` // topic が "mode" の場合の変化前の運転モード
+	SOC                *int64 `json:"soc,omitempty"`           // 蓄電残量 (%)
+	BatteryWatts       *int64 `json:"battery_w,omitempty"`     // 蓄電池の瞬時充放電電力 (W、正: 充電)
+	PVWatts            *int64 `json:"pv_w,omitempty"`          // 太陽光発電の瞬時発電電力 (W)
+	GridWatts          *int64 `json:"grid_w,omitempty"`        // 系統の瞬時電力 (W、正: 買電)
+	ChargeSettingWatts *int64 `json:"charge_setting_w,omitempty"`
+
+	Override string `json:"override,omitempty"` // 手動操作の種類 ("none"、"pause"、"charge"、"auto")
+	Command  string `json:"command,omitempty"`  // topic が "command" の場合の実行したコマンド
+	Result   string `json:"result,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// noderedFeed は、最近のイベントを通し番号 (seq) とともに保持し、SSE とロングポーリングのクライアントに返します。
+// クライアントは受け取った最後の seq を指定して続きを受け取るため、監視ループがクライアントを待つことはありません。
+type noderedFeed struct {
+	mu       sync.Mutex
+	events   []noderedEvent
+	seq      uint64
+	changed  chan struct{} // イベントを追加するたびに close して新しいチャネルに置き換える
+	mode     string        // 前回の監視サイクルの運転モード
+	override string        // 前回の監視サイクルの手動操作の種類
+}
+
+// newNoderedFeed は、イベントのない noderedFeed を作成します。
+func newNoderedFeed() *noderedFeed {
+	return &noderedFeed{changed: make(chan struct{})}
+}
+
+// publish は、イベントに通し番号を付けて追加し、待っているクライアントに知らせます。
+func (f *noderedFeed) publish(ev noderedEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seq++
+	ev.Seq = f.seq
+	f.events = append(f.events, ev)
+	if len(f.events) > noderedBuffer {
+		f.events = f.events[len(f.events)-noderedBuffer:]
+	}
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// since は、after より後のイベントと、次のイベントの追加で close されるチャネルを返します。
+func (f *noderedFeed) since(after uint64) ([]noderedEvent, <-chan struct{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var events []noderedEvent
+	for _, ev := range f.events {
+		if ev.Seq > after {
+			events = append(events, ev)
+		}
+	}
+	return events, f.changed
+}
+
+// latest は、最後のイベントの通し番号を返します。
+func (f *noderedFeed) latest() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.seq
+}
+
+// cycle は、監視サイクルの記録から "cycle" のイベントを追加し、運転モードや手動操作が前回から変わった場合はそのイベントも追加します。
+func (f *noderedFeed) cycle(r *monitor.AuditRecord, override monitor.OverrideKind) {
+	number := func(key string) *int64 {
+		if v, ok := elwaNumber(r.Measurements[key]); ok {
+			n := v.(int64)
+			return &n
+		}
+		return nil
+	}
+	ev := noderedEvent{
+		Topic:              "cycle",
+		Time:               r.Time.Format(time.RFC3339),
+		Decision:           r.Decision,
+		Reason:             r.Reason,
+		Strategy:           r.Strategy,
+		Mode:               formatOperationMode(r.Measurements["蓄電池.運転モード設定"]),
+		SOC:                number("蓄電池.蓄電残量3"),
+		BatteryWatts:       number("蓄電池.瞬時充放電電力計測値"),
+		PVWatts:            number("住宅用太陽光発電.瞬時発電電力計測値"),
+		GridWatts:          number("系統.瞬時電力計測値"),
+		ChargeSettingWatts: number("蓄電池.充電電力設定値"),
+		Override:           override.String(),
+	}
+	f.publish(ev)
+
+	f.mu.Lock()
+	previousMode, previousOverride := f.mode, f.override
+	if ev.Mode != "" {
+		f.mode = ev.Mode
+	}
+	f.override = ev.Override
+	f.mu.Unlock()
+	if previousMode != "" && ev.Mode != "" && ev.Mode != previousMode {
+		f.publish(noderedEvent{Topic: "mode", Time: ev.Time, Mode: ev.Mode, PreviousMode: previousMode, Reason: r.Reason, Strategy: r.Strategy})
+	}
+	if previousOverride != "" && ev.Override != previousOverride {
+		f.publish(noderedEvent{Topic: "override", Time: ev.Time, Override: ev.Override})
+	}
+}
+
+// formatOperationMode は、運転モード設定の測定値を "0x42" の形式にします。取得できていない場合は空です。
+func formatOperationMode(v interface{}) string {
+	switch m := v.(type) {
+	case uint8:
+		return fmt.Sprintf("0x%02X", m)
+	case []byte:
+		if len(m) == 1 {
+			return fmt.Sprintf("0x%02X", m[0])
+		}
+	}
+	return ""
+}
+
+// handleNoderedEvents は、イベントを SSE またはロングポーリングで返します。
+func (a *httpAPI) handleNoderedEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s は使用できません", r.Method))
+		return
+	}
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		a.serveNoderedSSE(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	var after uint64
+	if s := query.Get("after"); s != "" {
+		var err error
+		if after, err = strconv.ParseUint(s, 10, 64); err != nil {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("'after' は 0 以上の整数である必要があります: '%s'", s))
+			return
+		}
+	}
+	timeout := noderedDefaultTimeout
+	if s := query.Get("timeout"); s != "" {
+		seconds, err := strconv.Atoi(s)
+		if err != nil || seconds < 0 || time.Duration(seconds)*time.Second > noderedMaxTimeout {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("'timeout' は 0 から %d の整数である必要があります: '%s'", int(noderedMaxTimeout/time.Second), s))
+			return
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	events, changed := a.nodered.since(after)
+	if len(events) == 0 && timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-changed:
+			events, _ = a.nodered.since(after)
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if events == nil {
+		events = []noderedEvent{} // Node-RED の split ノードで扱えるよう、null ではなく空の配列を返す
+	}
+	writeAPIJSON(w, http.StatusOK, events)
+}
+
+// serveNoderedSSE は、切断されるまでイベントを SSE で送信します。
+// Last-Event-ID を指定して再接続した場合は、その後のイベントから送信します。指定しない場合は接続後のイベントのみです。
+func (a *httpAPI) serveNoderedSSE(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Errorf("SSE に対応していません"))
+		return
+	}
+	after := a.nodered.latest()
+	if id, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+		after = id
+	}
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	log.Printf("[HTTP API] Node-RED のクライアント (%s) が SSE で接続しました。", r.RemoteAddr)
+
+	keepAlive := time.NewTicker(noderedKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		events, changed := a.nodered.since(after)
+		for _, ev := range events {
+			data, err := json.Marshal(ev)
+			if err != nil {
+				log.Printf("[HTTP API] 配信するイベントの変換に失敗しました: %v", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.Seq, ev.Topic, data); err != nil {
+				return
+			}
+			after = ev.Seq
+		}
+		flusher.Flush()
+		select {
+		case <-changed:
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// noderedCommand は、POST /nodered/command の JSON の本文です。
+type noderedCommand struct {
+	Command string `json:"command"` // "pause"、"resume"、"charge"、"auto"、"target"、"status"
+	Percent int    `json:"percent"` // "target" の目標の蓄電残量 (%)
+	Minutes int    `json:"minutes"` // 省略した場合は monitor.DefaultOverrideMinutes 分間
+}
+
+// line は、UNIX ドメインソケットと同じ1行のコマンドにします。
+func (c noderedCommand) line() string {
+	fields := []string{c.Command}
+	if c.Percent != 0 {
+		fields = append(fields, strconv.Itoa(c.Percent))
+	}
+	if c.Minutes != 0 {
+		fields = append(fields, strconv.Itoa(c.Minutes))
+	}
+	return strings.Join(fields, " ")
+}
+
+// handleNoderedCommand は、手動操作のコマンドに安全確認を適用して実行し、結果を "command" のイベントとしても配信します。
+// 本文は JSON のほか、Node-RED の inject ノードから送りやすいテキスト ("charge 30" など) も受け付けます。
+func (a *httpAPI) handleNoderedCommand(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s は使用できません", r.Method))
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 4096))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("本文を読み込めませんでした: %w", err))
+		return
+	}
+	line := strings.TrimSpace(string(body))
+	if strings.HasPrefix(line, "{") {
+		var c noderedCommand
+		if err := json.Unmarshal(body, &c); err != nil {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("本文を JSON として読み込めませんでした: %w", err))
+			return
+		}
+		line = c.line()
+	} else if s, err := strconv.Unquote(line); err == nil {
+		line = s // JSON の文字列として送られたテキスト
+	}
+
+	now := a.now()
+	ev := noderedEvent{Topic: "command", Time: now.Format(time.RFC3339), Command: line}
+	res, err := a.override.HandleGuardedCommand(line, now)
+	if err != nil {
+		log.Printf("[HTTP API] Node-RED のコマンド '%s' を実行できませんでした: %v", line, err)
+		ev.Error = err.Error()
+		a.nodered.publish(ev)
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	log.Printf("[HTTP API] Node-RED のコマンド '%s': %s", line, res)
+	kind, _ := a.override.Current(now)
+	ev.Result, ev.Override = res, kind.String()
+	a.nodered.publish(ev)
+	writeAPIJSON(w, http.StatusOK, map[string]string{"command": line, "result": res})
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+func TestNoderedEvents(t *testing.T) {
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.Local)
+	api := newHTTPAPI("secret", monitor.NewManualOverride(time.Local))
+	api.now = func() time.Time { return now }
+	api.nodered = newNoderedFeed()
+	srv := httptest.NewServer(api.handler())
+	defer srv.Close()
+
+	poll := func(query string) []map[string]interface{} {
+		req, _ := http.NewRequest("GET", srv.URL+"/nodered/events"+query, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		var events []map[string]interface{}
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("GET /nodered/events%s = %d", query, res.StatusCode)
+		}
+		if err := json.NewDecoder(res.Body).Decode(&events); err != nil {
+			t.Fatal(err)
+		}
+		return events
+	}
+
+	if events := poll("?timeout=0"); len(events) != 0 {
+		t.Errorf("events before the first cycle = %v", events)
+	}
+
+	save := func(mode byte, soc uint8) {
+		api.save(&monitor.AuditRecord{
+			Time:     now,
+			Decision: monitor.AuditStrategy,
+			Strategy: "default",
+			Measurements: map[string]interface{}{
+				"蓄電池.運転モード設定":        []byte{mode},
+				"蓄電池.蓄電残量3":          soc,
+				"系統.瞬時電力計測値":         int32(-1200),
+				"住宅用太陽光発電.瞬時発電電力計測値": int32(3000),
+			},
+		})
+	}
+	save(0x42, 50)
+	save(0x42, 51)
+	save(0x46, 52)
+
+	events := poll("?after=0&timeout=0")
+	var topics []string
+	for _, ev := range events {
+		topics = append(topics, ev["topic"].(string))
+	}
+	if strings.Join(topics, ",") != "cycle,cycle,cycle,mode" {
+		t.Fatalf("topics = %v", topics)
+	}
+	first := events[0]
+	if first["seq"] != 1.0 || first["mode"] != "0x42" || first["soc"] != 50.0 || first["grid_w"] != -1200.0 || first["pv_w"] != 3000.0 || first["override"] != "none" {
+		t.Errorf("cycle event = %v", first)
+	}
+	if _, ok := first["battery_w"]; ok {
+		t.Errorf("unavailable value included: %v", first)
+	}
+	if mode := events[3]; mode["mode"] != "0x46" || mode["previous_mode"] != "0x42" {
+		t.Errorf("mode event = %v", mode)
+	}
+	if events := poll("?after=3&timeout=0"); len(events) != 1 || events[0]["seq"] != 4.0 {
+		t.Errorf("events after 3 = %v", events)
+	}
+
+	// A long poll returns as soon as the next event is published.
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		save(0x46, 53)
+	}()
+	if events := poll("?after=4&timeout=10"); len(events) != 1 || events[0]["soc"] != 53.0 {
+		t.Errorf("long poll = %v", events)
+	}
+
+	req, _ := http.NewRequest("GET", srv.URL+"/nodered/events?after=x", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	if res, err := http.DefaultClient.Do(req); err != nil || res.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid after = %v %v", res, err)
+	}
+}
+
+func TestNoderedSSE(t *testing.T) {
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.Local)
+	api := newHTTPAPI("secret", monitor.NewManualOverride(time.Local))
+	api.now = func() time.Time { return now }
+	api.nodered = newNoderedFeed()
+	srv := httptest.NewServer(api.handler())
+	defer srv.Close()
+
+	api.nodered.publish(noderedEvent{Topic: "cycle"}) // before the connection
+
+	// EventSource cannot set headers, so the token is accepted in the query.
+	req, _ := http.NewRequest("GET", srv.URL+"/nodered/events?token=secret", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK || !strings.HasPrefix(res.Header.Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("SSE response = %d %s", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+
+	api.nodered.publish(noderedEvent{Topic: "override", Override: "pause"})
+	lines := bufio.NewScanner(res.Body)
+	var got []string
+	for len(got) < 3 && lines.Scan() {
+		if lines.Text() != "" {
+			got = append(got, lines.Text())
+		}
+	}
+	want := []string{"id: 2", "event: override", `data: {"seq":2,"topic":"override","time":"","override":"pause"}`}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("SSE = %q, want %q", got, want)
+	}
+}
+
+func TestNoderedCommand(t *testing.T) {
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.Local)
+	api := newHTTPAPI("secret", monitor.NewManualOverride(time.Local))
+	api.now = func() time.Time { return now }
+	api.nodered = newNoderedFeed()
+	srv := httptest.NewServer(api.handler())
+	defer srv.Close()
+
+	post := func(body string) (int, map[string]string) {
+		req, _ := http.NewRequest("POST", srv.URL+"/nodered/command", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		var v map[string]string
+		json.NewDecoder(res.Body).Decode(&v)
+		return res.StatusCode, v
+	}
+
+	if code, v := post(`{"command": "charge", "minutes": 30}`); code != http.StatusOK || v["result"] != "charge until 10:30:00" {
+		t.Errorf("JSON command = %d %v", code, v)
+	}
+	if code, v := post(`"target 80 15"`); code != http.StatusOK || v["command"] != "target 80 15" || v["result"] != "target 80% until 10:15:00" {
+		t.Errorf("string command = %d %v", code, v)
+	}
+	if code, v := post("resume\n"); code != http.StatusOK || v["result"] != "resumed" {
+		t.Errorf("text command = %d %v", code, v)
+	}
+	if code, v := post(`{"command": "shutdown"}`); code != http.StatusBadRequest || v["error"] == "" {
+		t.Errorf("unknown command = %d %v", code, v)
+	}
+
+	events, _ := api.nodered.since(0)
+	if len(events) != 4 {
+		t.Fatalf("events = %+v", events)
+	}
+	if ev := events[0]; ev.Topic != "command" || ev.Command != "charge 30" || ev.Override != "charge" || ev.Result == "" {
+		t.Errorf("command event = %+v", ev)
+	}
+	if ev := events[3]; ev.Command != "shutdown" || ev.Error == "" {
+		t.Errorf("failed command event = %+v", ev)
+	}
+
+	// Disabled unless node_red = true.
+	api.nodered = nil
+	disabled := httptest.NewServer(api.handler())
+	defer disabled.Close()
+	req, _ := http.NewRequest("POST", disabled.URL+"/nodered/command", strings.NewReader("pause"))
+	req.Header.Set("Authorization", "Bearer secret")
+	if res, err := http.DefaultClient.Do(req); err != nil || res.StatusCode != http.StatusNotFound {
+		t.Errorf("disabled = %v %v", res, err)
+	}
+}
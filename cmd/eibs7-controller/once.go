@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+// onceResult は、-once -format=json で標準出力に出力する1回の監視サイクルの結果です。
+// ok が false の場合は error に理由を出力し、終了コード 1 で終了します。
+type onceResult struct {
+	OK           bool                   `json:"ok"`
+	Error        string                 `json:"error,omitempty"`
+	Time         string                 `json:"time,omitempty"`
+	Decision     string                 `json:"decision,omitempty"`
+	Reason       string                 `json:"reason,omitempty"`
+	State        string                 `json:"state,omitempty"`
+	Strategy     string                 `json:"strategy,omitempty"`
+	Inputs       map[string]interface{} `json:"inputs,omitempty"`
+	Thresholds   map[string]int         `json:"thresholds,omitempty"`
+	Action       *monitor.AuditAction   `json:"action,omitempty"`
+	Requests     []monitor.AuditRequest `json:"requests,omitempty"`
+	Measurements map[string]interface{} `json:"measurements,omitempty"` // 監視データ (オブジェクト名.プロパティ名) と計算値
+}
+
+// onceReport は、-once で実行した監視サイクルの記録を受け取り、結果と終了コードを決めます。
+type onceReport struct {
+	mu     sync.Mutex
+	record *monitor.AuditRecord
+}
+
+// save は、監視サイクルの記録を保存します。監視サイクル以外の記録は無視します。
+func (o *onceReport) save(r *monitor.AuditRecord) {
+	if r.Decision == monitor.AuditOutsideCycle {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.record = r
+}
+
+// result は、監視サイクルの記録から結果を作成します。completed は監視サイクルが panic せずに終了したかどうかです。
+// 監視データを取得できなかった場合、制御をスキップした場合、SetC が失敗した場合は失敗とします。
+func (o *onceReport) result(completed bool) onceResult {
+	o.mu.Lock()
+	r := o.record
+	o.mu.Unlock()
+
+	if !completed {
+		return onceResult{Error: "監視サイクルが異常終了しました"}
+	}
+	if r == nil {
+		return onceResult{Error: "監視サイクルの記録がありません"}
+	}
+	res := onceResult{
+		OK:           true,
+		Time:         r.Time.Format(time.RFC3339),
+		Decision:     r.Decision,
+		Reason:       r.Reason,
+		State:        r.State,
+		Strategy:     r.Strategy,
+		Inputs:       r.Inputs,
+		Thresholds:   r.Thresholds,
+		Action:       r.Action,
+		Requests:     r.Requests,
+		Measurements: r.Measurements,
+	}
+	var failed []string
+	for _, req := range r.Requests {
+		switch req.Result {
+		case "Set_Res", "dry_run", "sent":
+		default:
+			failed = append(failed, fmt.Sprintf("%s EPC %s: %s", req.DEOJ, req.EPC, req.Result))
+		}
+	}
+	switch {
+	case r.Decision == monitor.AuditSkipped || r.Decision == monitor.AuditUnreachable:
+		res.OK, res.Error = false, r.Reason
+	case len(r.Measurements) == 0:
+		res.OK, res.Error = false, "監視データを取得できませんでした"
+	case len(failed) > 0:
+		res.OK, res.Error = false, "SetC に失敗しました ("+strings.Join(failed, ", ")+")"
+	}
+	return res
+}
+
+// finish は、結果を format の形式で w に出力し、終了コードを返します。
+// text の場合は結果をログに出力し、json の場合は JSON を w に出力します。
+func (o *onceReport) finish(completed bool, format string, w io.Writer) int {
+	res := o.result(completed)
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(res); err != nil {
+			log.Printf("[1回実行] 結果を出力できませんでした: %v", err)
+			return 1
+		}
+	} else {
+		names := make([]string, 0, len(res.Measurements))
+		for name := range res.Measurements {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			log.Printf("[1回実行] %s: %v", name, res.Measurements[name])
+		}
+	}
+	if !res.OK {
+		log.Printf("[1回実行] 監視サイクルが失敗しました: %s", res.Error)
+		return 1
+	}
+	log.Printf("[1回実行] 監視サイクルが完了しました (判断: %s)", res.Decision)
+	return 0
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/monitor"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestOnceReportJSON(t *testing.T) {
+	audit := &monitor.DecisionAudit{}
+	report := &onceReport{}
+	audit.AddStore(report.save)
+
+	now := monitortest.Now
+	cfg := monitortest.NewConfig(now.Add(2*time.Hour), now.Add(3*time.Hour))
+	client := monitor.NewEchonetClient(echonetlite.NewFakeTransport(monitortest.NewFakeEIBS7().Handle), cfg.TargetIP, time.Second)
+	m := monitor.New(cfg, client, monitor.Options{Audit: audit})
+	completed := monitor.RunCycleRecovering(m)
+
+	var out strings.Builder
+	if code := report.finish(completed, "json", &out); code != 0 {
+		t.Fatalf("finish = %d, want 0 (output: %s)", code, out.String())
+	}
+	var res onceResult
+	if err := json.Unmarshal([]byte(out.String()), &res); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+	}
+	if !res.OK || res.Decision == "" || len(res.Measurements) == 0 {
+		t.Errorf("result = %+v, want ok with a decision and measurements", res)
+	}
+	if len(res.Requests) != 1 || res.Requests[0].Result != "Set_Res" {
+		t.Errorf("requests = %+v, want one successful SetC", res.Requests)
+	}
+}
+
+func TestOnceReportFailures(t *testing.T) {
+	if res := (&onceReport{}).result(false); res.OK {
+		t.Error("a panicking cycle was reported as ok")
+	}
+	if res := (&onceReport{}).result(true); res.OK {
+		t.Error("a cycle without a record was reported as ok")
+	}
+
+	r := &onceReport{}
+	r.save(&monitor.AuditRecord{Decision: monitor.AuditStrategy, Measurements: map[string]interface{}{"soc": 50}, Requests: []monitor.AuditRequest{{DEOJ: "027D01", EPC: "DA", Result: "SetC_SNA"}}})
+	if res := r.result(true); res.OK || !strings.Contains(res.Error, "SetC_SNA") {
+		t.Errorf("result = %+v, want a SetC failure", res)
+	}
+	r.save(&monitor.AuditRecord{Decision: monitor.AuditUnreachable, Reason: "対象機器に到達できません"})
+	if res := r.result(true); res.OK || res.Error != "対象機器に到達できません" {
+		t.Errorf("result = %+v, want the unreachable reason", res)
+	}
+	// Requests sent outside the cycle do not replace the cycle's record.
+	r.save(&monitor.AuditRecord{Decision: monitor.AuditOutsideCycle})
+	if r.record.Decision != monitor.AuditUnreachable {
+		t.Errorf("record = %q, want the cycle's record", r.record.Decision)
+	}
+}
+
+func TestOnceReportUnansweredDevice(t *testing.T) {
+	audit := &monitor.DecisionAudit{}
+	report := &onceReport{}
+	audit.AddStore(report.save)
+
+	now := monitortest.Now
+	cfg := monitortest.NewConfig(now.Add(2*time.Hour), now.Add(3*time.Hour))
+	m := monitor.New(cfg, monitor.NewEchonetClient(echonetlite.NewFakeTransport(nil), cfg.TargetIP, time.Millisecond), monitor.Options{Audit: audit})
+	completed := monitor.RunCycleRecovering(m)
+
+	var out strings.Builder
+	if code := report.finish(completed, "json", &out); code != 1 {
+		t.Errorf("finish = %d, want 1 (output: %s)", code, out.String())
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+// listenOverrideSocket は、UNIX ドメインソケットで手動操作のコマンドの受け付けを開始します。
+// 接続ごとに1行のコマンドを読み込み、"ok <応答>" または "error <理由>" を返して切断します。
+func listenOverrideSocket(path string, o *monitor.ManualOverride) (net.Listener, error) {
+	// 前回の実行で残ったソケットファイルを削除する
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("ソケットファイル '%s' を削除できませんでした: %w", path, err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("ソケット '%s' で待ち受けできませんでした: %w", path, err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					log.Printf("[手動操作] 接続の受け付けに失敗しました: %v", err)
+				}
+				return
+			}
+			go serveOverrideConn(conn, o)
+		}
+	}()
+	return listener, nil
+}
+
+// serveOverrideConn は、1つの接続からコマンドを読み込んで実行し、結果を返します。
+func serveOverrideConn(conn net.Conn, o *monitor.ManualOverride) {
+	defer conn.Close()
+	conn.SetDeadline(o.Now().Add(10 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return
+	}
+	res, err := o.HandleCommand(line, o.Now())
+	if err != nil {
+		log.Printf("[手動操作] コマンド '%s' を実行できませんでした: %v", strings.TrimSpace(line), err)
+		fmt.Fprintf(conn, "error %v\n", err)
+		return
+	}
+	fmt.Fprintf(conn, "ok %s\n", res)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+func TestOverrideSocket(t *testing.T) {
+	o := monitor.NewManualOverride(time.Local)
+	path := filepath.Join(t.TempDir(), "override.sock")
+	listener, err := listenOverrideSocket(path, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	send := func(cmd string) string {
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		fmt.Fprintln(conn, cmd)
+		res, _ := bufio.NewReader(conn).ReadString('\n')
+		return strings.TrimSpace(res)
+	}
+
+	if res := send("auto 15"); !strings.HasPrefix(res, "ok auto until ") {
+		t.Errorf("auto 15 = %q", res)
+	}
+	if kind, _ := o.Current(time.Now()); kind != monitor.OverrideAuto {
+		t.Errorf("current = %s, want auto", kind)
+	}
+	if res := send("bogus"); !strings.HasPrefix(res, "error ") {
+		t.Errorf("bogus = %q, want an error", res)
+	}
+}
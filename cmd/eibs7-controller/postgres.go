@@ -0,0 +1,181 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // PostgreSQL ドライバ
+)
+
+// postgresSchema は、テーブルを作成する SQL を返します。既存のテーブルは変更しません。
+// TimescaleDB のハイパーテーブルには外部キーを使用できないため、各テーブルは監視サイクルの時刻で関連付けます。
+func postgresSchema(prefix string, hypertables bool) []string {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS ` + prefix + `cycles (
+			time TIMESTAMPTZ NOT NULL,
+			decision TEXT NOT NULL,
+			reason TEXT,
+			state TEXT,
+			strategy TEXT,
+			action_mode TEXT,
+			action_charge_power_watts INTEGER,
+			action_discharge_power_watts INTEGER
+		)`,
+		`CREATE TABLE IF NOT EXISTS ` + prefix + `measurements (
+			time TIMESTAMPTZ NOT NULL,
+			name TEXT NOT NULL,
+			value DOUBLE PRECISION,
+			text TEXT
+		)`,
+		`CREATE TABLE IF NOT EXISTS ` + prefix + `actions (
+			time TIMESTAMPTZ NOT NULL,
+			target TEXT NOT NULL,
+			deoj TEXT NOT NULL,
+			epc TEXT NOT NULL,
+			edt TEXT NOT NULL,
+			tid INTEGER NOT NULL,
+			result TEXT NOT NULL,
+			error TEXT
+		)`,
+	}
+	for _, table := range []string{"cycles", "measurements", "actions"} {
+		if hypertables {
+			stmts = append(stmts, fmt.Sprintf("SELECT create_hypertable('%s%s', 'time', if_not_exists => TRUE)", prefix, table))
+		} else {
+			stmts = append(stmts, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %[1]s%[2]s_time ON %[1]s%[2]s (time)", prefix, table))
+		}
+	}
+	return stmts
+}
+
+// postgresSink は、監視サイクルの記録を PostgreSQL に書き込みます。
+// 監視ループを止めないよう、記録はキューに入れて別の goroutine で書き込み、キューがいっぱいの場合は破棄します。
+type postgresSink struct {
+	db                                           *sql.DB
+	insertCycle, insertMeasurement, insertAction *sql.Stmt
+	queue                                        chan *monitor.AuditRecord
+	done                                         chan struct{}
+
+	mu     sync.Mutex
+	closed bool // close の後は記録をキューに入れない
+}
+
+// openPostgresSink は、接続プールを作成してテーブルを作成し、挿入の SQL を準備して書き込みを開始します。
+func openPostgresSink(cfg config.PostgresConfig) (*postgresSink, error) {
+	db, err := sql.Open("pgx", string(cfg.URL))
+	if err != nil {
+		return nil, fmt.Errorf("PostgreSQL に接続できませんでした: %w", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxConnections)
+	db.SetMaxIdleConns(cfg.MaxConnections)
+	db.SetConnMaxIdleTime(10 * time.Minute)
+	for _, stmt := range postgresSchema(cfg.TablePrefix, cfg.Hypertables) {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("PostgreSQL のテーブルの作成に失敗しました: %w", err)
+		}
+	}
+
+	s := &postgresSink{db: db, queue: make(chan *monitor.AuditRecord, cfg.QueueSize), done: make(chan struct{})}
+	prepare := func(query string) *sql.Stmt {
+		if err != nil {
+			return nil
+		}
+		var stmt *sql.Stmt
+		stmt, err = db.Prepare(query)
+		return stmt
+	}
+	p := cfg.TablePrefix
+	s.insertCycle = prepare(`INSERT INTO ` + p + `cycles (time, decision, reason, state, strategy, action_mode, action_charge_power_watts, action_discharge_power_watts)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`)
+	s.insertMeasurement = prepare(`INSERT INTO ` + p + `measurements (time, name, value, text) VALUES ($1, $2, $3, $4)`)
+	s.insertAction = prepare(`INSERT INTO ` + p + `actions (time, target, deoj, epc, edt, tid, result, error) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("PostgreSQL への挿入の準備に失敗しました: %w", err)
+	}
+	go s.run()
+	return s, nil
+}
+
+// save は、監視サイクルの記録を書き込みのキューに入れます。監査ログの保存先として登録します。
+func (s *postgresSink) save(r *monitor.AuditRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.queue <- r:
+	default:
+		log.Printf("[PostgreSQL] 書き込みが追いつかないため、%s の監視サイクルの記録を破棄しました。", r.Time.Format("15:04:05"))
+	}
+}
+
+// run は、キューの記録を順に書き込みます。close でキューが閉じられると、残りを書き込んでから終了します。
+func (s *postgresSink) run() {
+	defer close(s.done)
+	for r := range s.queue {
+		if err := s.insert(r); err != nil {
+			log.Printf("[PostgreSQL] 監視サイクルの保存に失敗しました: %v", err)
+		}
+	}
+}
+
+// insert は、1つの監視サイクルの記録を1つのトランザクションで書き込みます。
+func (s *postgresSink) insert(r *monitor.AuditRecord) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var mode sql.NullString
+	var chargePower, dischargePower sql.NullInt64
+	if a := r.Action; a != nil {
+		mode = sql.NullString{String: a.Mode, Valid: a.Mode != ""}
+		if a.ChargePowerWatts != nil {
+			chargePower = sql.NullInt64{Int64: int64(*a.ChargePowerWatts), Valid: true}
+		}
+		if a.DischargePowerWatts != nil {
+			dischargePower = sql.NullInt64{Int64: int64(*a.DischargePowerWatts), Valid: true}
+		}
+	}
+	if _, err := tx.Stmt(s.insertCycle).Exec(r.Time, r.Decision, r.Reason, r.State, r.Strategy, mode, chargePower, dischargePower); err != nil {
+		return err
+	}
+	insertMeasurement := tx.Stmt(s.insertMeasurement)
+	for name, v := range r.Measurements {
+		value, text := historyValue(v)
+		if _, err := insertMeasurement.Exec(r.Time, name, value, text); err != nil {
+			return err
+		}
+	}
+	insertAction := tx.Stmt(s.insertAction)
+	for _, req := range r.Requests {
+		if _, err := insertAction.Exec(r.Time, req.Target, req.DEOJ, req.EPC, req.EDT, int(req.TID), req.Result, req.Error); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// close は、キューの残りを書き込んでから接続プールを閉じます。
+func (s *postgresSink) close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+	<-s.done
+	s.db.Close()
+}
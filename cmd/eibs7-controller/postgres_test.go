@@ -0,0 +1,21 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPostgresSchema(t *testing.T) {
+	plain := strings.Join(postgresSchema("home_", false), ";\n")
+	for _, want := range []string{"CREATE TABLE IF NOT EXISTS home_cycles", "CREATE TABLE IF NOT EXISTS home_measurements", "CREATE INDEX IF NOT EXISTS home_actions_time ON home_actions (time)"} {
+		if !strings.Contains(plain, want) {
+			t.Errorf("schema does not contain %q", want)
+		}
+	}
+	if strings.Contains(plain, "create_hypertable") {
+		t.Errorf("plain schema should not create hypertables")
+	}
+	if hyper := strings.Join(postgresSchema("home_", true), ";\n"); !strings.Contains(hyper, "create_hypertable('home_measurements', 'time', if_not_exists => TRUE)") {
+		t.Errorf("hypertable schema = %s", hyper)
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchReloadSignal は、SIGHUP を受信するたびに通知するチャネルを返します。
+// 監視ループは、次の監視サイクルの開始前に設定ファイルを読み込み直します。
+func watchReloadSignal() <-chan os.Signal {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	return sigCh
+}
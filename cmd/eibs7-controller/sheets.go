@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// Google の API の URL
+const (
+	googleSheetsURL = "https://sheets.googleapis.com/v4/spreadsheets"
+)
+
+// sheetsReporter は、毎日 time の時刻に、その日の履歴を集計した1行をスプレッドシートに追記します。
+type sheetsReporter struct {
+	cfg       config.GoogleSheetsConfig
+	account   *config.ServiceAccount
+	history   *historyStore
+	loc       *time.Location
+	client    *http.Client
+	sheetsURL string // テストで差し替えるため
+}
+
+// newSheetsReporter は、設定と履歴を指定して sheetsReporter を作成します。時刻は loc のタイムゾーンで判定します。
+func newSheetsReporter(cfg config.GoogleSheetsConfig, history *historyStore, loc *time.Location) (*sheetsReporter, error) {
+	account, err := config.LoadServiceAccount(cfg.CredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	return &sheetsReporter{
+		cfg:       cfg,
+		account:   account,
+		history:   history,
+		loc:       loc,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		sheetsURL: googleSheetsURL,
+	}, nil
+}
+
+// run は、done が閉じられるまで、追記の時刻ごとにその日の行を追記します。
+func (r *sheetsReporter) run(done <-chan struct{}) {
+	for {
+		next := config.NextOccurrence(time.Now().In(r.loc), r.cfg.Time)
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-done:
+			timer.Stop()
+			return
+		case <-timer.C:
+			if err := r.appendReport(next); err != nil {
+				log.Printf("[スプレッドシート] 日ごとの行の追記に失敗しました: %v", err)
+			}
+		}
+	}
+}
+
+// reportRow は、集計結果を1行にします。列は日付、発電・買電・売電・充電・放電・自家消費の電力量 (kWh)、
+// 蓄電残量の最小・最大 (%)、節約額の目安 (円) の順です。
+// 自家消費は、発電と放電のうち家庭で使用した分 (発電 - 売電 + 放電 - 充電) です。
+// 節約額の目安は、自家消費の分を買電しなかった金額と売電の金額の合計です。
+func (r *sheetsReporter) reportRow(day time.Time, rep historyReport) []interface{} {
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	selfConsumed := math.Max(0, rep.GeneratedKWh-rep.ExportedKWh+rep.DischargedKWh-rep.ChargedKWh)
+	savings := selfConsumed*r.cfg.ImportPrice + rep.ExportedKWh*r.cfg.ExportPrice
+	row := []interface{}{
+		day.Format("2006-01-02"),
+		round(rep.GeneratedKWh), round(rep.ImportedKWh), round(rep.ExportedKWh),
+		round(rep.ChargedKWh), round(rep.DischargedKWh), round(selfConsumed),
+		"", "", // 蓄電残量の記録がない場合は空
+		math.Round(savings),
+	}
+	if rep.MinSOC >= 0 {
+		row[7], row[8] = rep.MinSOC, rep.MaxSOC
+	}
+	return row
+}
+
+// appendReport は、now の日の 0:00 から now までの履歴を集計して、1行を追記します。
+func (r *sheetsReporter) appendReport(now time.Time) error {
+	y, m, d := now.Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	rep, err := r.history.report(day, now)
+	if err != nil {
+		return fmt.Errorf("履歴を集計できませんでした: %w", err)
+	}
+	token, err := r.accessToken(time.Now())
+	if err != nil {
+		return fmt.Errorf("アクセストークンを取得できませんでした: %w", err)
+	}
+	body, err := json.Marshal(map[string]interface{}{"values": [][]interface{}{r.reportRow(day, rep)}})
+	if err != nil {
+		return err
+	}
+	u := fmt.Sprintf("%s/%s/values/%s:append?valueInputOption=USER_ENTERED&insertDataOption=INSERT_ROWS",
+		r.sheetsURL, url.PathEscape(r.cfg.SpreadsheetID), url.PathEscape(r.cfg.Sheet))
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	if err := r.do(req, nil); err != nil {
+		return err
+	}
+	log.Printf("[スプレッドシート] %s の行をシート '%s' に追記しました。", day.Format("2006-01-02"), r.cfg.Sheet)
+	return nil
+}
+
+// accessToken は、サービスアカウントの JWT でアクセストークンを取得します。1日1回の追記のため、キャッシュはしません。
+func (r *sheetsReporter) accessToken(now time.Time) (string, error) {
+	assertion, err := r.account.Assertion(now)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {assertion}}
+	req, err := http.NewRequest(http.MethodPost, r.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var res struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := r.do(req, &res); err != nil {
+		return "", err
+	}
+	if res.AccessToken == "" {
+		return "", errors.New("応答に access_token がありません")
+	}
+	return res.AccessToken, nil
+}
+
+// do は、リクエストを送信し、2xx 以外の応答をエラーにします。v が nil でなければ応答の JSON を読み込みます。
+func (r *sheetsReporter) do(req *http.Request, v interface{}) error {
+	res, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("ステータス %d: %s", res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
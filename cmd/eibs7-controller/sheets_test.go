@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+// writeServiceAccount writes a service account key file whose token endpoint is tokenURI.
+func writeServiceAccount(t *testing.T, tokenURI string) (string, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "eibs7@example.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    tokenURI,
+	})
+	path := filepath.Join(t.TempDir(), "service-account.json")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path, key
+}
+
+func TestGoogleSheetsConfigValidate(t *testing.T) {
+	path, _ := writeServiceAccount(t, "https://oauth2.googleapis.com/token")
+	c := config.GoogleSheetsConfig{Enabled: true, CredentialsFile: path, SpreadsheetID: "sheet-id"}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Sheet != "Sheet1" || c.Time != "23:55" {
+		t.Errorf("defaults = %+v", c)
+	}
+	for _, bad := range []config.GoogleSheetsConfig{
+		{Enabled: true, CredentialsFile: path},
+		{Enabled: true, SpreadsheetID: "sheet-id"},
+		{Enabled: true, CredentialsFile: filepath.Join(t.TempDir(), "missing.json"), SpreadsheetID: "sheet-id"},
+		{Enabled: true, CredentialsFile: path, SpreadsheetID: "sheet-id", Time: "25:00"},
+		{Enabled: true, CredentialsFile: path, SpreadsheetID: "sheet-id", ImportPrice: -1},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", bad)
+		}
+	}
+}
+
+func TestSheetsReporterAppendsDailyRow(t *testing.T) {
+	var key *rsa.PrivateKey
+	var appended map[string][][]interface{}
+	var appendPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			r.ParseForm()
+			parts := strings.Split(r.PostForm.Get("assertion"), ".")
+			if r.PostForm.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || len(parts) != 3 {
+				t.Errorf("token request = %v", r.PostForm)
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+				t.Errorf("JWT signature: %v", err)
+			}
+			claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+			if !strings.Contains(string(claims), `"scope":"https://www.googleapis.com/auth/spreadsheets"`) {
+				t.Errorf("claims = %s", claims)
+			}
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "token-1", "token_type": "Bearer"})
+		case strings.HasPrefix(r.URL.Path, "/v4/spreadsheets/"):
+			if r.Header.Get("Authorization") != "Bearer token-1" || r.URL.Query().Get("valueInputOption") != "USER_ENTERED" {
+				t.Errorf("append request = %s %v", r.URL, r.Header)
+			}
+			appendPath = r.URL.Path
+			json.NewDecoder(r.Body).Decode(&appended)
+			w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	path, k := writeServiceAccount(t, srv.URL+"/token")
+	key = k
+	hcfg := config.HistoryConfig{File: filepath.Join(t.TempDir(), "history.db")}
+	hcfg.Validate()
+	h, err := openHistoryStore(hcfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.close()
+	day := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	h.save(&monitor.AuditRecord{Time: day.Add(10 * time.Hour), Decision: monitor.AuditStrategy, Measurements: map[string]interface{}{"蓄電池.蓄電残量3": uint8(40)}})
+	h.save(&monitor.AuditRecord{Time: day.Add(11 * time.Hour), Decision: monitor.AuditStrategy, Measurements: map[string]interface{}{"蓄電池.蓄電残量3": uint8(90)}})
+
+	cfg := config.GoogleSheetsConfig{Enabled: true, CredentialsFile: path, SpreadsheetID: "sheet-id", Sheet: "日報", ImportPrice: 30, ExportPrice: 16}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := newSheetsReporter(cfg, h, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.sheetsURL = srv.URL + "/v4/spreadsheets"
+	if err := r.appendReport(day.Add(23*time.Hour + 55*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if appendPath != "/v4/spreadsheets/sheet-id/values/日報:append" {
+		t.Errorf("path = %s", appendPath)
+	}
+	row := appended["values"]
+	if len(row) != 1 || len(row[0]) != 10 || row[0][0] != "2025-06-01" || row[0][7] != 40.0 || row[0][8] != 90.0 {
+		t.Errorf("row = %v", row)
+	}
+}
+
+func TestSheetsReportRow(t *testing.T) {
+	r := &sheetsReporter{cfg: config.GoogleSheetsConfig{ImportPrice: 30, ExportPrice: 16}}
+	day := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	row := r.reportRow(day, historyReport{GeneratedKWh: 20, ImportedKWh: 3, ExportedKWh: 5, ChargedKWh: 6, DischargedKWh: 4.004, MinSOC: -1, MaxSOC: -1})
+	// Self-consumed: 20 - 5 + 4.004 - 6 = 13.004 kWh; savings: 13.004 * 30 + 5 * 16 = 470 yen.
+	want := []interface{}{"2025-06-01", 20.0, 3.0, 5.0, 6.0, 4.0, 13.0, "", "", 470.0}
+	for i := range want {
+		if row[i] != want[i] {
+			t.Errorf("row[%d] = %v, want %v", i, row[i], want[i])
+		}
+	}
+}
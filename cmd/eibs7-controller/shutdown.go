@@ -0,0 +1,23 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchShutdownSignal は、SIGINT または SIGTERM を受信すると閉じられるチャネルを返します。
+// 終了処理中に再度シグナルを受信した場合は、終了処理を待たずに直ちに終了します。
+func watchShutdownSignal() <-chan struct{} {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		sig := <-sigCh
+		log.Printf("シグナル (%v) を受信しました。現在の監視サイクルの終了後に終了します。", sig)
+		signal.Reset(syscall.SIGINT, syscall.SIGTERM)
+		close(done)
+	}()
+	return done
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// stallWatchdog は、監視ループが一定時間以上サイクルを完了していないこと (ソケットでのブロックや、
+// サイクル中の panic の繰り返しなど) を検出し、蓄電池を安全な運転モードに戻すフォールバックを実行します。
+// 停止を検出している間は unhealthy として扱い、サイクルが再び完了すると healthy に戻ります。
+type stallWatchdog struct {
+	timeout  time.Duration
+	fallback func()
+
+	mu            sync.Mutex
+	lastHeartbeat time.Time
+	stalled       bool
+}
+
+// newStallWatchdog は、停止とみなす時間とフォールバックの処理を指定して stallWatchdog を作成します。
+func newStallWatchdog(timeout time.Duration, fallback func(), now time.Time) *stallWatchdog {
+	return &stallWatchdog{timeout: timeout, fallback: fallback, lastHeartbeat: now}
+}
+
+// heartbeat は、監視サイクルが完了したことを記録します。
+func (w *stallWatchdog) heartbeat(now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stalled {
+		log.Printf("[ウォッチドッグ] 監視ループが再開しました (停止期間: %s)。", now.Sub(w.lastHeartbeat).Truncate(time.Second))
+		w.stalled = false
+	}
+	w.lastHeartbeat = now
+}
+
+// healthy は、監視ループの停止を検出していなければ true を返します。
+func (w *stallWatchdog) healthy() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return !w.stalled
+}
+
+// check は、最後にサイクルが完了してから timeout 以上経過していれば unhealthy にして、フォールバックを1回実行します。
+// フォールバックは次にサイクルが完了するまで再実行しません。
+func (w *stallWatchdog) check(now time.Time) {
+	w.mu.Lock()
+	if w.stalled || now.Sub(w.lastHeartbeat) < w.timeout {
+		w.mu.Unlock()
+		return
+	}
+	w.stalled = true
+	last := w.lastHeartbeat
+	w.mu.Unlock()
+
+	log.Printf("[ウォッチドッグ] 監視ループが %s 以上サイクルを完了していません (最終完了: %s)。安全な運転モードに戻します。", w.timeout, last.Format("15:04:05"))
+	w.fallback()
+}
+
+// run は、timeout の 1/4 の間隔で check を繰り返します。done が閉じられると終了します。
+func (w *stallWatchdog) run(done <-chan struct{}) {
+	ticker := time.NewTicker(w.timeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			w.check(now)
+		}
+	}
+}
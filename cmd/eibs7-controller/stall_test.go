@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStallWatchdogFallsBackOnce(t *testing.T) {
+	start := time.Date(2025, 6, 1, 10, 0, 0, 0, time.Local)
+	fallbacks := 0
+	w := newStallWatchdog(3*time.Minute, func() { fallbacks++ }, start)
+
+	w.check(start.Add(2 * time.Minute))
+	if fallbacks != 0 || !w.healthy() {
+		t.Fatalf("fallback ran before the timeout (fallbacks=%d, healthy=%t)", fallbacks, w.healthy())
+	}
+
+	w.check(start.Add(3 * time.Minute))
+	w.check(start.Add(4 * time.Minute))
+	if fallbacks != 1 {
+		t.Errorf("fallback ran %d times while stalled, want 1", fallbacks)
+	}
+	if w.healthy() {
+		t.Error("watchdog still healthy after the loop stalled")
+	}
+
+	// A completed cycle makes the loop healthy again and re-arms the fallback.
+	w.heartbeat(start.Add(5 * time.Minute))
+	if !w.healthy() {
+		t.Error("watchdog unhealthy after a completed cycle")
+	}
+	w.check(start.Add(8 * time.Minute))
+	if fallbacks != 2 {
+		t.Errorf("fallback ran %d times after a second stall, want 2", fallbacks)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+// containsString は、list に s が含まれるかを返します。
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// stateWebhooks は、監視サイクルの記録から状態の変化を検出し、Webhook に送信します。監査ログの保存先として登録します。
+// 監視ループを止めないよう、送信はキューに入れて別の goroutine で行い、キューがいっぱいの場合は破棄します。
+type stateWebhooks struct {
+	hooks  []stateWebhook
+	client *http.Client
+	queue  chan config.StateEvent
+	done   chan struct{}
+
+	// 前回の監視サイクルの状態 (監視ループからのみ参照する)
+	mode   string
+	storm  bool
+	budget bool
+	seen   bool
+}
+
+// stateWebhook は、テンプレートを読み込んだ Webhook の設定です。
+type stateWebhook struct {
+	cfg       config.StateWebhookConfig
+	url, body *template.Template
+}
+
+// newStateWebhooks は、Webhook の設定を指定して stateWebhooks を作成し、送信を開始します。
+func newStateWebhooks(cfgs []config.StateWebhookConfig) (*stateWebhooks, error) {
+	w := &stateWebhooks{
+		client: &http.Client{Timeout: 10 * time.Second},
+		queue:  make(chan config.StateEvent, 16),
+		done:   make(chan struct{}),
+	}
+	for _, cfg := range cfgs {
+		u, body, err := cfg.Templates()
+		if err != nil {
+			return nil, err
+		}
+		w.hooks = append(w.hooks, stateWebhook{cfg: cfg, url: u, body: body})
+	}
+	go w.run()
+	return w, nil
+}
+
+// save は、監視サイクルの記録を前回と比べ、変化した状態の出来事を送信します。
+// 機器に到達できなかった監視サイクルでは、嵐警戒モードと充電電力量の上限の状態を判定しません。
+func (w *stateWebhooks) save(r *monitor.AuditRecord) {
+	switch r.Decision {
+	case monitor.AuditOutsideCycle, monitor.AuditSkipped, monitor.AuditUnreachable:
+		return
+	}
+	ev := config.StateEvent{
+		Time:   r.Time.Format(time.RFC3339),
+		Mode:   formatOperationMode(r.Measurements["蓄電池.運転モード設定"]),
+		Reason: r.Reason,
+	}
+	if v, ok := elwaNumber(r.Measurements["蓄電池.蓄電残量3"]); ok {
+		soc := v.(int64)
+		ev.SOC = &soc
+	}
+	storm := r.Decision == monitor.AuditStorm
+	budget, hasBudget := r.Inputs["grid_budget_exhausted"].(bool)
+
+	var events []string
+	if w.mode != "" && ev.Mode != "" && ev.Mode != w.mode {
+		ev.PreviousMode = w.mode
+		events = append(events, config.StateModeChange)
+	}
+	if w.seen && storm && !w.storm {
+		events = append(events, config.StateStormStart)
+	} else if w.seen && !storm && w.storm {
+		events = append(events, config.StateStormEnd)
+	}
+	if w.seen && hasBudget && budget && !w.budget {
+		events = append(events, config.StateBudgetExhausted)
+	} else if w.seen && hasBudget && !budget && w.budget {
+		events = append(events, config.StateBudgetReset)
+	}
+
+	if ev.Mode != "" {
+		w.mode = ev.Mode
+	}
+	w.storm, w.seen = storm, true
+	if hasBudget {
+		w.budget = budget
+	}
+	for _, name := range events {
+		e := ev
+		e.Event = name
+		select {
+		case w.queue <- e:
+		default:
+			log.Printf("[Webhook] 送信が追いつかないため、出来事 (%s) を破棄しました。", name)
+		}
+	}
+}
+
+// run は、キューの出来事を、その出来事を送信する設定の Webhook に順に送信します。close でキューが閉じられると、残りを送信してから終了します。
+func (w *stateWebhooks) run() {
+	defer close(w.done)
+	for ev := range w.queue {
+		for i := range w.hooks {
+			hook := &w.hooks[i]
+			if !containsString(hook.cfg.Events, ev.Event) {
+				continue
+			}
+			if err := w.send(hook, ev); err != nil {
+				log.Printf("[Webhook] 出来事 (%s) の送信に失敗しました: %v", ev.Event, err)
+			}
+		}
+	}
+}
+
+// send は、テンプレートから URL と本文を作成して、1つの Webhook に送信します。
+func (w *stateWebhooks) send(hook *stateWebhook, ev config.StateEvent) error {
+	var u strings.Builder
+	if err := hook.url.Execute(&u, ev); err != nil {
+		return fmt.Errorf("URL のテンプレートを実行できませんでした: %w", err)
+	}
+	if _, err := url.Parse(u.String()); err != nil {
+		return fmt.Errorf("URL が不正です: %w", withoutURL(err))
+	}
+	var body bytes.Buffer
+	switch {
+	case hook.body != nil:
+		if err := hook.body.Execute(&body, ev); err != nil {
+			return fmt.Errorf("本文のテンプレートを実行できませんでした: %w", err)
+		}
+	case hook.cfg.Method != http.MethodGet:
+		if err := json.NewEncoder(&body).Encode(ev); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(hook.cfg.Method, u.String(), &body)
+	if err != nil {
+		return withoutURL(err)
+	}
+	if body.Len() > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range hook.cfg.Headers {
+		req.Header.Set(k, string(v))
+	}
+	res, err := w.client.Do(req)
+	if err != nil {
+		return withoutURL(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("ステータス %d: %s", res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// withoutURL は、*url.Error から URL を取り除いたエラーを返します。URL は認証のキーを含むことがあるため、ログに出力しません。
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%s: %w", urlErr.Op, urlErr.Err)
+	}
+	return err
+}
+
+// close は、キューに残っている出来事を送信してから終了します。
+func (w *stateWebhooks) close() {
+	close(w.queue)
+	<-w.done
+}
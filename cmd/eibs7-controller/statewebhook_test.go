@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+func TestStateWebhooks(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, r.Method+" "+r.URL.RequestURI()+" "+r.Header.Get("X-Token")+" "+strings.TrimSpace(string(body)))
+	}))
+	defer srv.Close()
+
+	cfgs := []config.StateWebhookConfig{
+		{
+			URL:     config.Secret(srv.URL + "/trigger/{{.Event}}?soc={{.SOC}}"),
+			Events:  []string{config.StateModeChange},
+			Body:    `{"value1": {{json .PreviousMode}}, "value2": {{json .Mode}}}`,
+			Headers: map[string]config.Secret{"X-Token": "t"},
+		},
+		{
+			URL:    config.Secret(srv.URL + "/state/{{.Event}}?reason={{urlquery .Reason}}"),
+			Method: "get",
+			Events: []string{config.StateStormStart, config.StateStormEnd, config.StateBudgetExhausted, config.StateBudgetReset},
+		},
+	}
+	for i := range cfgs {
+		if err := cfgs[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err := newStateWebhooks(cfgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	cycle := func(decision, reason string, mode byte, budget interface{}) {
+		r := &monitor.AuditRecord{
+			Time:         now,
+			Decision:     decision,
+			Reason:       reason,
+			Inputs:       map[string]interface{}{},
+			Measurements: map[string]interface{}{"蓄電池.運転モード設定": []byte{mode}, "蓄電池.蓄電残量3": uint8(60)},
+		}
+		if budget != nil {
+			r.Inputs["grid_budget_exhausted"] = budget
+		}
+		w.save(r)
+	}
+	cycle(monitor.AuditStrategy, "", 0x46, false) // the first cycle only records the state
+	cycle(monitor.AuditStrategy, "", 0x42, false)
+	cycle(monitor.AuditUnreachable, "", 0x44, nil) // ignored
+	cycle(monitor.AuditStorm, "嵐警戒モード", 0x42, nil)
+	cycle(monitor.AuditStrategy, "", 0x42, true)
+	cycle(monitor.AuditStrategy, "", 0x42, nil) // the budget is not known this cycle
+	cycle(monitor.AuditStrategy, "", 0x42, false)
+	w.close()
+
+	want := []string{
+		`POST /trigger/mode_change?soc=60 t {"value1": "0x46", "value2": "0x42"}`,
+		"GET /state/storm_start?reason=%E5%B5%90%E8%AD%A6%E6%88%92%E3%83%A2%E3%83%BC%E3%83%89  ",
+		"GET /state/storm_end?reason=  ",
+		"GET /state/budget_exhausted?reason=  ",
+		"GET /state/budget_reset?reason=  ",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestStateWebhookErrorsOmitURL(t *testing.T) {
+	err := withoutURL(&url.Error{Op: "Post", URL: "https://example.com/hook?key=url-key-1234", Err: errors.New("connection refused")})
+	if strings.Contains(err.Error(), "url-key-1234") || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("withoutURL = %q, want the cause without the URL", err)
+	}
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+
+	"golang.org/x/term"
+)
+
+// tuiLogLines は、ダッシュボードに保持する判断の履歴の行数です。
+const tuiLogLines = 200
+
+// tuiOverrideMinutes は、ダッシュボードのキー操作で手動操作を行う時間 (分) です。
+const tuiOverrideMinutes = 30
+
+// batteryModeNames は、蓄電池の運転モード設定 (EPC 0xDA) の表示名です。
+var batteryModeNames = map[int64]string{
+	0x40: "その他",
+	0x41: "急速充電",
+	0x42: "充電",
+	0x43: "放電",
+	0x44: "待機",
+	0x45: "テスト",
+	0x46: "自動",
+	0x48: "再起動",
+	0x49: "実効容量再計算処理",
+}
+
+// tuiClient は、実行中のコントローラーの HTTP API に接続します。
+type tuiClient struct {
+	base   string // 例: "http://127.0.0.1:8080"
+	token  string
+	client *http.Client
+}
+
+// status は、最後の監視サイクルの状態 (/status) を取得します。
+func (c *tuiClient) status() (apiStatus, error) {
+	var status apiStatus
+	err := c.do(http.MethodGet, "/status", nil, &status)
+	return status, err
+}
+
+// command は、手動操作の API に POST し、結果を返します。
+func (c *tuiClient) command(path string, req apiOverrideRequest) (string, error) {
+	var res struct {
+		Result string `json:"result"`
+	}
+	if err := c.do(http.MethodPost, path, req, &res); err != nil {
+		return "", err
+	}
+	return res.Result, nil
+}
+
+// do は、HTTP API にリクエストを送り、応答の JSON を out に読み込みます。エラーの応答は error の内容をエラーにします。
+func (c *tuiClient) do(method, path string, body interface{}, out interface{}) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.base+path, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(res.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("%s %s: %s (%d)", method, path, apiErr.Error, res.StatusCode)
+		}
+		return fmt.Errorf("%s %s: %s", method, path, res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// tuiModel は、ダッシュボードに表示する状態です。
+type tuiModel struct {
+	endpoint string
+	status   *apiStatus
+	err      error    // 最後の状態の取得のエラー
+	message  string   // 最後のキー操作の結果
+	log      []string // 判断の履歴 (古い順)
+	lastTime string   // 履歴に追加した最後の監視サイクルの時刻
+}
+
+// update は、取得した状態を反映し、新しい監視サイクルであれば判断の履歴に追加します。
+func (m *tuiModel) update(status apiStatus, err error) {
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.err = nil
+	m.status = &status
+	if status.Time == "" || status.Time == m.lastTime {
+		return
+	}
+	m.lastTime = status.Time
+	at := status.Time
+	if t, err := time.Parse(time.RFC3339, status.Time); err == nil {
+		at = t.Format("15:04:05")
+	}
+	line := fmt.Sprintf("%s %s", at, status.Decision)
+	if status.Strategy != "" {
+		line += " (" + status.Strategy + ")"
+	}
+	if status.Reason != "" {
+		line += ": " + status.Reason
+	}
+	m.log = append(m.log, line)
+	if len(m.log) > tuiLogLines {
+		m.log = m.log[len(m.log)-tuiLogLines:]
+	}
+}
+
+// tuiNumber は、/status の測定値を整数として返します。
+func tuiNumber(values map[string]interface{}, key string) (int64, bool) {
+	switch v := values[key].(type) {
+	case float64:
+		return int64(v), true
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// tuiPower は、電力を「1234 W (充電)」の形式にします。正の場合は positive、負の場合は negative を付けます。
+func tuiPower(values map[string]interface{}, key, positive, negative string) string {
+	w, ok := tuiNumber(values, key)
+	if !ok {
+		return "-"
+	}
+	s := fmt.Sprintf("%6d W", w)
+	switch {
+	case w > 0 && positive != "":
+		s += " (" + positive + ")"
+	case w < 0 && negative != "":
+		s += " (" + negative + ")"
+	}
+	return s
+}
+
+// tuiBar は、割合を幅 width の棒グラフにします。
+func tuiBar(percent int64, width int) string {
+	if percent < 0 {
+		percent = 0
+	} else if percent > 100 {
+		percent = 100
+	}
+	filled := int(percent) * width / 100
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", width-filled) + "]"
+}
+
+// render は、ダッシュボードを幅 width、高さ height の端末に表示する文字列を作成します。
+// 判断の履歴は、残りの行に収まる新しいものから表示します。
+func (m *tuiModel) render(width, height int) string {
+	var lines []string
+	add := func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+
+	add("eibs7-controller  %s", m.endpoint)
+	if m.err != nil {
+		add("接続エラー: %v", m.err)
+	}
+	if m.status == nil {
+		add("状態を取得しています...")
+	} else {
+		s := m.status
+		cycle := "監視サイクル: " + s.Time
+		if s.State != "" {
+			cycle += "  状態: " + s.State
+		}
+		add("%s  判断: %s", cycle, s.Decision)
+		values := s.Measurements
+		if soc, ok := tuiNumber(values, "蓄電池.蓄電残量3"); ok {
+			add("蓄電残量     %s %3d%%", tuiBar(soc, 30), soc)
+		} else {
+			add("蓄電残量     -")
+		}
+		add("太陽光発電   %s", tuiPower(values, "住宅用太陽光発電.瞬時発電電力計測値", "", ""))
+		add("系統         %s", tuiPower(values, "系統.瞬時電力計測値", "買電", "売電"))
+		add("蓄電池       %s", tuiPower(values, "蓄電池.瞬時充放電電力計測値", "充電", "放電"))
+		add("家庭の消費   %s", tuiPower(s.Inputs, "household_load_watts", "", ""))
+		mode := "-"
+		if v, ok := tuiNumber(values, "蓄電池.運転モード設定"); ok {
+			mode = fmt.Sprintf("0x%02X", v)
+			if name, ok := batteryModeNames[v]; ok {
+				mode += " (" + name + ")"
+			}
+		}
+		add("運転モード   %s  充電電力設定値 %s", mode, strings.TrimSpace(tuiPower(values, "蓄電池.充電電力設定値", "", "")))
+		window := "時間帯外"
+		if charging, _ := s.Inputs["charging_window"].(bool); charging {
+			window = "充電時間帯"
+		}
+		if target, ok := tuiNumber(s.Inputs, "charge_target_soc_percent"); ok {
+			window += fmt.Sprintf(" (目標 %d%%)", target)
+		}
+		add("スケジュール %s  制御方式: %s", window, s.Strategy)
+		if s.Reason != "" {
+			add("理由         %s", s.Reason)
+		}
+		add("手動操作     %s", s.Override)
+	}
+	add("")
+	add("--- 判断の履歴 ---")
+
+	footer := []string{
+		fmt.Sprintf("[p] 一時停止 %d 分  [c] 充電 %d 分  [a] 自動 %d 分  [r] 解除  [q] 終了", tuiOverrideMinutes, tuiOverrideMinutes, tuiOverrideMinutes),
+		m.message,
+	}
+	room := height - len(lines) - len(footer)
+	if room < 0 {
+		room = 0
+	}
+	history := m.log
+	if len(history) > room {
+		history = history[len(history)-room:]
+	}
+	lines = append(lines, history...)
+	for i := len(history); i < room; i++ {
+		lines = append(lines, "")
+	}
+	lines = append(lines, footer...)
+
+	for i, line := range lines {
+		lines[i] = truncateDisplay(line, width)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// truncateDisplay は、全角文字を2桁として、表示幅が width を超える部分を切り詰めます。
+func truncateDisplay(s string, width int) string {
+	w := 0
+	for i, r := range s {
+		cw := 1
+		if r >= 0x1100 {
+			cw = 2
+		}
+		if w+cw > width {
+			return s[:i]
+		}
+		w += cw
+	}
+	return s
+}
+
+// tuiKeyCommand は、キー操作に対応する HTTP API のパスと本文を返します。対応しないキーの場合は ok が false です。
+func tuiKeyCommand(key byte) (path string, req apiOverrideRequest, ok bool) {
+	switch key {
+	case 'p':
+		return "/pause", apiOverrideRequest{Minutes: tuiOverrideMinutes}, true
+	case 'c':
+		return "/mode", apiOverrideRequest{Mode: "charge", Minutes: tuiOverrideMinutes}, true
+	case 'a':
+		return "/mode", apiOverrideRequest{Mode: "auto", Minutes: tuiOverrideMinutes}, true
+	case 'r':
+		return "/resume", apiOverrideRequest{}, true
+	}
+	return "", apiOverrideRequest{}, false
+}
+
+// runTUI は、tui サブコマンドを実行します。実行中のコントローラーの HTTP API から状態を定期的に取得して表示し、
+// キー操作で手動操作を送信します。接続先とトークンは設定ファイルの [http_api] から読み込み、-url と -token で上書きできます。
+func runTUI(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("tui", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configFile := fs.String("config", "", "設定ファイルのパスを指定します。未指定の場合は監視と同じ順に config.toml を探します。")
+	endpoint := fs.String("url", "", "HTTP API の URL を指定します (例: http://127.0.0.1:8080)。未指定の場合は設定ファイルの http_api.listen を使用します。")
+	token := fs.String("token", os.Getenv("EIBS7_API_TOKEN"), "HTTP API のトークンを指定します。未指定の場合は環境変数 EIBS7_API_TOKEN または設定ファイルの http_api.token を使用します。")
+	interval := fs.Duration("interval", 2*time.Second, "状態を取得する間隔を指定します。")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *endpoint == "" || *token == "" {
+		path := *configFile
+		if path == "" {
+			found, err := findConfigFile(configSearchPaths())
+			if err != nil && *endpoint == "" {
+				fmt.Fprintf(stderr, "%v (-url で HTTP API の URL を指定することもできます)\n", err)
+				return 1
+			}
+			path = found
+		}
+		if path != "" {
+			cfg, err := config.Load(path)
+			if err != nil {
+				fmt.Fprintf(stderr, "設定の読み込みに失敗しました: %v\n", err)
+				return 1
+			}
+			if !cfg.HTTPAPI.Enabled && *endpoint == "" {
+				fmt.Fprintln(stderr, "tui には HTTP API が必要です。設定ファイルで [http_api] を有効にしてください。")
+				return 1
+			}
+			if *endpoint == "" {
+				*endpoint = "http://" + cfg.HTTPAPI.Listen
+			}
+			if *token == "" {
+				*token = string(cfg.HTTPAPI.Token)
+			}
+		}
+	}
+
+	client := &tuiClient{base: strings.TrimSuffix(*endpoint, "/"), token: *token, client: &http.Client{Timeout: 5 * time.Second}}
+	restore, err := tuiRawMode()
+	if err != nil {
+		fmt.Fprintf(stderr, "端末を設定できませんでした: %v\n", err)
+		return 1
+	}
+	defer restore()
+	fmt.Fprint(stdout, "\x1b[?25l")           // カーソルを隠す
+	defer fmt.Fprint(stdout, "\x1b[?25h\r\n") // カーソルを戻す
+
+	keys := make(chan byte)
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if n, err := os.Stdin.Read(buf); err != nil || n == 0 {
+				close(keys)
+				return
+			}
+			keys <- buf[0]
+		}
+	}()
+	signals := make(chan os.Signal, 1)
+	// 端末を閉じた場合も含め、終了前に必ず端末の設定を元に戻す
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	m := &tuiModel{endpoint: client.base}
+	for {
+		m.update(client.status())
+		width, height := tuiSize()
+		// raw モードでは改行で行頭に戻らないため、CR を補う
+		fmt.Fprint(stdout, "\x1b[H\x1b[2J"+strings.ReplaceAll(m.render(width, height), "\n", "\r\n"))
+		select {
+		case key, ok := <-keys:
+			if !ok || key == 'q' || key == 0x03 {
+				return 0
+			}
+			if path, req, ok := tuiKeyCommand(key); ok {
+				res, err := client.command(path, req)
+				if err != nil {
+					m.message = "エラー: " + err.Error()
+				} else {
+					m.message = res
+				}
+			}
+		case <-ticker.C:
+		case <-signals:
+			return 0
+		}
+	}
+}
+
+// tuiRawMode は、キーを1文字ずつエコーせずに読めるように標準入力の端末を raw モードにし、元に戻す関数を返します。
+// 標準入力が端末でない場合はエラーを返します。
+func tuiRawMode() (restore func(), err error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, errors.New("標準入力が端末ではありません")
+	}
+	saved, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	return func() { term.Restore(fd, saved) }, nil
+}
+
+// tuiSize は、端末の幅と高さを返します。取得できない場合は 80x24 とします。
+func tuiSize() (width, height int) {
+	if width, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 && height > 0 {
+		return width, height
+	}
+	return 80, 24
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor"
+)
+
+func TestTUIClientTalksToHTTPAPI(t *testing.T) {
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.Local)
+	api := newHTTPAPI("secret", monitor.NewManualOverride(time.Local))
+	api.now = func() time.Time { return now }
+	api.save(&monitor.AuditRecord{
+		Time:         now,
+		Decision:     monitor.AuditStrategy,
+		Measurements: map[string]interface{}{"蓄電池.蓄電残量3": uint8(50), "蓄電池.運転モード設定": uint8(0x42)},
+	})
+	srv := httptest.NewServer(api.handler())
+	defer srv.Close()
+	c := &tuiClient{base: srv.URL, token: "secret", client: http.DefaultClient}
+
+	status, err := c.status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.Decision != monitor.AuditStrategy {
+		t.Errorf("decision = %q, want %q", status.Decision, monitor.AuditStrategy)
+	}
+	path, req, _ := tuiKeyCommand('c')
+	res, err := c.command(path, req)
+	if err != nil || !strings.HasPrefix(res, "charge until") {
+		t.Errorf("command(c) = %q, %v, want a charge override", res, err)
+	}
+
+	c.token = "wrong"
+	if _, err := c.status(); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("status with a wrong token = %v, want 401", err)
+	}
+}
+
+func TestTUIModelRender(t *testing.T) {
+	m := &tuiModel{endpoint: "http://127.0.0.1:8080"}
+	if out := m.render(80, 24); !strings.Contains(out, "状態を取得しています") {
+		t.Errorf("render before the first status:\n%s", out)
+	}
+
+	status := apiStatus{
+		Time:     "2025-06-01T10:00:00+09:00",
+		Decision: monitor.AuditStrategy,
+		Strategy: config.StrategySelfConsumption,
+		Reason:   "余剰電力で充電",
+		Inputs:   map[string]interface{}{"charging_window": true, "charge_target_soc_percent": 90.0, "household_load_watts": 400.0},
+		Measurements: map[string]interface{}{
+			"蓄電池.蓄電残量3":          50.0,
+			"蓄電池.運転モード設定":        66.0,
+			"系統.瞬時電力計測値":         -200.0,
+			"蓄電池.瞬時充放電電力計測値":     1000.0,
+			"住宅用太陽光発電.瞬時発電電力計測値": 1600.0,
+		},
+		Override: "ok none",
+	}
+	m.update(status, nil)
+	m.update(status, nil) // the same cycle is logged once
+	status.Time = "2025-06-01T10:01:00+09:00"
+	m.update(status, nil)
+	if len(m.log) != 2 || !strings.HasPrefix(m.log[1], "10:01:00 strategy") {
+		t.Errorf("log = %q", m.log)
+	}
+
+	out := m.render(100, 30)
+	for _, want := range []string{"50%", "0x42 (充電)", "(売電)", "(充電)", "充電時間帯 (目標 90%)", "10:00:00 strategy", "[q] 終了"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("render is missing %q:\n%s", want, out)
+		}
+	}
+	if lines := strings.Split(out, "\n"); len(lines) != 30 {
+		t.Errorf("render has %d lines, want 30", len(lines))
+	}
+
+	// A short terminal keeps the newest decisions only.
+	out = m.render(100, 16)
+	if strings.Contains(out, "10:00:00") || !strings.Contains(out, "10:01:00") {
+		t.Errorf("short render:\n%s", out)
+	}
+}
+
+func TestTruncateDisplay(t *testing.T) {
+	if got := truncateDisplay("蓄電残量 abc", 6); got != "蓄電残" {
+		t.Errorf("truncateDisplay = %q", got)
+	}
+	if got := truncateDisplay("abc", 10); got != "abc" {
+		t.Errorf("truncateDisplay = %q", got)
+	}
+}
+
+func TestTUIRawModeRequiresTerminal(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stdin := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = stdin }()
+
+	if restore, err := tuiRawMode(); err == nil {
+		restore()
+		t.Error("tuiRawMode succeeded on a regular file, want an error")
+	}
+}
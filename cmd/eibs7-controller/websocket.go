@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket の接続ごとの設定
+const (
+	liveStreamBuffer     = 16               // 送信を待つイベントの最大数。超えたクライアントは切断する
+	liveStreamWriteWait  = 10 * time.Second // 1つのメッセージの送信の期限
+	liveStreamPingPeriod = 30 * time.Second // 接続を維持するための Ping の間隔
+)
+
+// liveStream は、/ws に接続しているクライアントに、監視サイクルごとの監視データと制御の判断を JSON のイベントとして配信します。
+// イベントの形式は GET /status の応答と同じです。
+// 監視ループを止めないよう、送信が追いつかないクライアントはイベントを待たずに切断します。
+type liveStream struct {
+	upgrader websocket.Upgrader
+
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+}
+
+// newLiveStream は、クライアントのいない liveStream を作成します。
+func newLiveStream() *liveStream {
+	return &liveStream{
+		// トークンで認証するため、ダッシュボードを別のオリジンから配信していても接続を受け付ける
+		upgrader: websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }},
+		clients:  make(map[chan []byte]struct{}),
+	}
+}
+
+// broadcast は、v を JSON にして接続しているすべてのクライアントに送信します。
+func (s *liveStream) broadcast(v interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.clients) == 0 {
+		return
+	}
+	msg, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("[HTTP API] 配信するイベントの変換に失敗しました: %v", err)
+		return
+	}
+	for ch := range s.clients {
+		select {
+		case ch <- msg:
+		default:
+			log.Println("[HTTP API] 送信が追いつかない WebSocket のクライアントを切断します。")
+			delete(s.clients, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe は、イベントを受け取るチャネルを登録します。
+func (s *liveStream) subscribe() chan []byte {
+	ch := make(chan []byte, liveStreamBuffer)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients[ch] = struct{}{}
+	return ch
+}
+
+// unsubscribe は、チャネルの登録を解除します。broadcast で切断済みの場合は何もしません。
+func (s *liveStream) unsubscribe(ch chan []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.clients[ch]; ok {
+		delete(s.clients, ch)
+		close(ch)
+	}
+}
+
+// handle は、/ws への接続を WebSocket に切り替え、切断されるまでイベントを送信します。
+// クライアントからのメッセージは読み捨て、切断の検出にのみ使用します。
+func (s *liveStream) handle(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade がエラーの応答を返す
+	}
+	defer conn.Close()
+	log.Printf("[HTTP API] WebSocket のクライアント (%s) が接続しました。", r.RemoteAddr)
+
+	ch := s.subscribe()
+	defer s.unsubscribe(ch)
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(liveStreamPingPeriod)
+	defer ping.Stop()
+	for {
+		select {
+		case msg, ok := <-ch:
+			conn.SetWriteDeadline(time.Now().Add(liveStreamWriteWait))
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"))
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(liveStreamWriteWait)); err != nil {
+				return
+			}
+		case <-closed:
+			log.Printf("[HTTP API] WebSocket のクライアント (%s) が切断しました。", r.RemoteAddr)
+			return
+		}
+	}
+}
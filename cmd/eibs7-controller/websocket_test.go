@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/monitor"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestLiveStream(t *testing.T) {
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.Local)
+	api := newHTTPAPI("secret", monitor.NewManualOverride(time.Local))
+	api.now = func() time.Time { return now }
+	srv := httptest.NewServer(api.handler())
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	if _, res, err := websocket.DefaultDialer.Dial(url, nil); err == nil || res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("dial without a token = %v, %v", res, err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?token=secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// The handler subscribes after the upgrade; wait until it has.
+	for deadline := time.Now().Add(time.Second); ; {
+		api.stream.mu.Lock()
+		n := len(api.stream.clients)
+		api.stream.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("client was not subscribed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	api.save(&monitor.AuditRecord{
+		Time:         now,
+		Decision:     monitor.AuditStrategy,
+		State:        "charging",
+		Measurements: map[string]interface{}{"蓄電池.蓄電残量3": uint8(50)},
+	})
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event apiStatus
+	if err := json.Unmarshal(msg, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.State != "charging" || event.Measurements["蓄電池.蓄電残量3"] != 50.0 || event.Override != "none" {
+		t.Errorf("event = %+v", event)
+	}
+}
+
+func TestLiveStreamDropsSlowClient(t *testing.T) {
+	s := newLiveStream()
+	ch := s.subscribe()
+	for i := 0; i <= liveStreamBuffer; i++ {
+		s.broadcast(i)
+	}
+	for i := 0; i < liveStreamBuffer; i++ {
+		<-ch
+	}
+	if _, ok := <-ch; ok {
+		t.Error("slow client was not disconnected")
+	}
+	if len(s.clients) != 0 {
+		t.Errorf("%d clients still subscribed", len(s.clients))
+	}
+	s.unsubscribe(ch) // must not close the channel twice
+}
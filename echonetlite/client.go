@@ -0,0 +1,436 @@
+package echonetlite
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Port は ECHONET Lite の UDP ポート番号です。
+const Port = 3610
+
+// DefaultReceiveBufferSize は、Client の受信バッファサイズ (バイト) のデフォルト値です。
+const DefaultReceiveBufferSize = 1024
+
+// 重複応答とみなす期間
+const duplicateResponseWindow = 60 * time.Second
+
+// ErrResponseTruncated は、応答フレームが受信バッファに収まらなかったことを示します。
+// 呼び出し側は要求するプロパティを分割して再要求できます。
+var ErrResponseTruncated = errors.New("応答フレームが受信バッファサイズを超えています")
+
+// Clock は現在時刻を返します。応答の待機期限と重複判定に使用します。
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// Client は、Transport を介して ECHONET Lite 機器と要求・応答をやり取りするクライアントです。
+// TID の割り当てと重複応答の検出は、Peer で作成したクライアントと共有します。
+// 設定用のフィールドは、要求の送信を始める前に設定してください。
+type Client struct {
+	Transport         Transport
+	TargetIP          string        // 対象機器のIPアドレスまたはホスト名
+	SEOJ              EOJ           // 送信元の ECHONET Lite オブジェクト (Get と SetC で使用)
+	Timeout           time.Duration // 応答の待機時間
+	Retries           int           // 応答がない場合に要求を再送する回数
+	RetryBackoff      time.Duration // 最初の再送までの待機時間。再送のたびに2倍にする
+	ReceiveBufferSize int           // 受信バッファのサイズ (バイト)。0 の場合は DefaultReceiveBufferSize
+	Clock             Clock         // 応答の待機期限と重複判定に使用する時計
+
+	// Sleep は、再送までの待機に使用する関数です。Clock と同様に、テストで時間を進めずに再送を確認するために差し替えます。
+	// nil の場合は time.Sleep を使用します。
+	Sleep func(d time.Duration)
+	// Notify は、応答の待機中や ReceiveNotifications で受信した通知 (INF/INFC) を処理する関数です。
+	// data は受信したデータグラムそのもの、received は Clock で計った受信時刻です。nil の場合、通知は破棄します。
+	Notify func(frame Frame, data []byte, addr *net.UDPAddr, received time.Time)
+	// Debugf は、破棄した重複応答などの詳細なログを出力する関数です。nil の場合は出力しません。
+	Debugf func(format string, v ...interface{})
+
+	tids      *tidCounter
+	responses *duplicateFilter
+}
+
+// NewClient は、Transport と対象機器のアドレスと応答の待機時間を指定して Client を作成します。
+// 送信元のオブジェクトはコントローラ (05FF01) です。
+func NewClient(transport Transport, targetIP string, timeout time.Duration) *Client {
+	return &Client{
+		Transport: transport,
+		TargetIP:  targetIP,
+		SEOJ:      NewEOJ(0x05, 0xFF, 0x01),
+		Timeout:   timeout,
+		Clock:     systemClock{},
+		tids:      newTIDCounter(),
+		responses: newDuplicateFilter(duplicateResponseWindow),
+	}
+}
+
+// Peer は、c と Transport と設定、TID の割り当て、重複応答の検出を共有し、別の機器 targetIP と通信する Client を作成します。
+func (c *Client) Peer(targetIP string) *Client {
+	p := *c
+	p.TargetIP = targetIP
+	return &p
+}
+
+// NextTID は、次に送信するフレームの TID を返します。複数の goroutine から同時に使用できます。
+func (c *Client) NextTID() TID {
+	return c.tids.next()
+}
+
+func (c *Client) now() time.Time {
+	if c.Clock == nil {
+		return time.Now()
+	}
+	return c.Clock.Now()
+}
+
+func (c *Client) sleep(d time.Duration) {
+	if c.Sleep == nil {
+		time.Sleep(d)
+		return
+	}
+	c.Sleep(d)
+}
+
+func (c *Client) debugf(format string, v ...interface{}) {
+	if c.Debugf != nil {
+		c.Debugf(format, v...)
+	}
+}
+
+func (c *Client) receiveBufferSize() int {
+	if c.ReceiveBufferSize <= 0 {
+		return DefaultReceiveBufferSize
+	}
+	return c.ReceiveBufferSize
+}
+
+// remoteAddr は、対象機器のアドレスを解決します。
+func (c *Client) remoteAddr() (*net.UDPAddr, error) {
+	remoteAddrStr := net.JoinHostPort(c.TargetIP, strconv.Itoa(Port))
+	remoteAddr, err := net.ResolveUDPAddr("udp", remoteAddrStr)
+	if err != nil {
+		return nil, fmt.Errorf("送信先アドレスの解決に失敗しました (%s): %w", remoteAddrStr, err)
+	}
+	return remoteAddr, nil
+}
+
+// IsNotification は、ESV が要求とは無関係に届く通知 (INF/INFC) であれば true を返します。
+func IsNotification(esv ESV) bool {
+	return esv == ESVInf || esv == ESVInfC
+}
+
+// tidCounter は、送信するフレームのトランザクションID (TID) を割り当てます。
+// 監視ループとウォッチドッグなど複数の goroutine から同時に使用できます。
+type tidCounter struct {
+	n atomic.Uint32
+}
+
+// newTIDCounter は、初期値をランダムにした tidCounter を作成します。
+// 再起動の直後に、再起動前の要求への遅れて届いた応答を新しい要求の応答と誤認しないようにするためです。
+func newTIDCounter() *tidCounter {
+	t := &tidCounter{}
+	t.n.Store(uint32(rand.Intn(0x10000)))
+	return t
+}
+
+// next は、次の TID を返します。TID 0 は使用しません。
+func (t *tidCounter) next() TID {
+	for {
+		if tid := TID(t.n.Add(1)); tid != 0 {
+			return tid
+		}
+	}
+}
+
+// responseKey は、受信した応答を識別するためのキー (送信元と TID と SEOJ の組) です。
+// Peer で複数の機器と通信する場合に、別の機器からの同じ TID の応答を重複とみなさないよう送信元を含めます。
+type responseKey struct {
+	Addr string
+	TID  TID
+	SEOJ EOJ
+}
+
+// duplicateFilter は、一定期間内に受信した応答のキーを記録し、重複を検出します。
+// EIBS7 は Get_Res を再送することがあり、2通目が次の要求の応答と誤認されるのを防ぎます。
+// 監視ループとウォッチドッグなど複数の goroutine から同時に使用できます。
+type duplicateFilter struct {
+	mu     sync.Mutex
+	window time.Duration
+	seen   map[responseKey]time.Time
+}
+
+// newDuplicateFilter は、指定された期間を重複判定の対象とする duplicateFilter を作成します。
+func newDuplicateFilter(window time.Duration) *duplicateFilter {
+	return &duplicateFilter{
+		window: window,
+		seen:   make(map[responseKey]time.Time),
+	}
+}
+
+// isDuplicate は、キーが期間内に既に受信済みであれば true を返します。
+// 受信済みでなければキーを記録して false を返します。期限切れのキーはここで削除します。
+func (f *duplicateFilter) isDuplicate(key responseKey, now time.Time) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for k, t := range f.seen {
+		if now.Sub(t) > f.window {
+			delete(f.seen, k)
+		}
+	}
+	if _, ok := f.seen[key]; ok {
+		return true
+	}
+	f.seen[key] = now
+	return false
+}
+
+// SendAndReceive は指定された ECHONET Lite フレームを対象機器へ送信し、応答を受信します。
+// 応答がタイムアウトした場合は、RetryBackoff (再送のたびに2倍) 待機してから同じ TID で Retries 回まで再送します。
+// 同じ TID で再送するため、前の送信への遅れて届いた応答も受け付けます。
+func (c *Client) SendAndReceive(frame Frame) ([]byte, *net.UDPAddr, error) {
+	backoff := c.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		data, addr, err := c.exchange(frame)
+		var netErr net.Error
+		if err == nil || attempt > c.Retries || !errors.As(err, &netErr) || !netErr.Timeout() {
+			return data, addr, err
+		}
+		log.Printf("応答がないため、%s 後に再送します (TID: %d, 再送: %d/%d 回目)", backoff, frame.TID, attempt, c.Retries)
+		c.sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// exchange は指定された ECHONET Lite フレームを対象機器へ1回送信し、
+// 応答をクライアントのタイムアウト時間まで待機して受信します。
+// 送信したフレームと TID が一致する応答のみを返します。TID が一致しない応答や、
+// 直近に受信済みの応答 (送信元と TID と SEOJ が同じもの) は破棄し、タイムアウトまで待機を続けます。
+func (c *Client) exchange(frame Frame) ([]byte, *net.UDPAddr, error) {
+	// 1. フレームをバイト列にシリアライズする
+	sendData, err := frame.MarshalBinary()
+	if err != nil {
+		return nil, nil, fmt.Errorf("フレームのシリアライズに失敗しました (TID: %d): %w", frame.TID, err)
+	}
+	log.Printf("送信データ (Hex, TID: %d): %X", frame.TID, sendData)
+
+	// 2. 送信先アドレスを解決する
+	remoteAddr, err := c.remoteAddr()
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("送信先: %s", remoteAddr.String())
+
+	// 3. バイト列を送信する
+	if err := c.Transport.Send(sendData, remoteAddr); err != nil {
+		return nil, nil, fmt.Errorf("UDPデータの送信に失敗しました (宛先: %s): %w", remoteAddr.String(), err)
+	}
+	log.Printf("%d バイトのデータを送信しました (宛先: %s, TID: %d)", len(sendData), remoteAddr.String(), frame.TID)
+
+	// 4. 応答を待機する
+	log.Printf("応答を待機しています (TID: %d, タイムアウト: %s)...", frame.TID, c.Timeout)
+
+	// 切り詰めを検出するため、設定サイズより1バイト大きいバッファで受信する
+	bufferSize := c.receiveBufferSize()
+	buffer := make([]byte, bufferSize+1)
+	deadline := c.now().Add(c.Timeout)
+
+	for {
+		bytesRead, addr, err := c.Transport.Receive(buffer, deadline)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				log.Printf("応答がタイムアウトしました (TID: %d)", frame.TID)
+				return nil, nil, err
+			}
+			return nil, nil, fmt.Errorf("UDPデータの受信に失敗しました (TID: %d): %w", frame.TID, err)
+		}
+
+		log.Printf("%s から %d バイトのデータを受信しました (TID: %d)", addr.String(), bytesRead, frame.TID)
+		log.Printf("受信データ (Hex, TID: %d): %X", frame.TID, buffer[:bytesRead])
+
+		// 受信バッファに収まらなかったフレームは切り詰められているため解析できない。
+		// 送信した要求への応答 (TID が一致) であれば専用のエラーを返す。
+		if bytesRead > bufferSize {
+			if bytesRead >= 4 && TID(binary.BigEndian.Uint16(buffer[2:4])) == frame.TID {
+				return nil, nil, fmt.Errorf("%w (TID: %d, バッファサイズ: %d バイト)", ErrResponseTruncated, frame.TID, bufferSize)
+			}
+			log.Printf("受信バッファサイズを超えるフレームを破棄します (TID: %d, 送信元: %s)", frame.TID, addr.String())
+			continue
+		}
+
+		// 送信した要求に対応する応答かどうかを確認する。
+		// 対応しないもの (デシリアライズできないもの、TID が一致しない古い応答、重複応答) は破棄し、
+		// タイムアウトまで受信を続ける。
+		var received Frame
+		if err := received.UnmarshalBinary(buffer[:bytesRead]); err != nil {
+			log.Printf("受信データのデシリアライズに失敗したため破棄します (TID: %d, 送信元: %s): %v", frame.TID, addr.String(), err)
+			continue
+		}
+		// 要求とは無関係に届いた通知 (INF/INFC) は、通知として処理する
+		if IsNotification(received.ESV) {
+			if c.Notify != nil {
+				c.Notify(received, append([]byte(nil), buffer[:bytesRead]...), addr, c.now())
+			}
+			continue
+		}
+		if received.TID != frame.TID {
+			log.Printf("TID が一致しない応答を破棄します (受信TID: %d, 送信TID: %d, 送信元: %s)", received.TID, frame.TID, addr.String())
+			continue
+		}
+		key := responseKey{Addr: addr.String(), TID: received.TID, SEOJ: received.SEOJ}
+		if c.responses.isDuplicate(key, c.now()) {
+			c.debugf("重複した応答を破棄しました (TID: %d, SEOJ: %02X%02X%02X, 送信元: %s)", received.TID, received.SEOJ.ClassGroupCode, received.SEOJ.ClassCode, received.SEOJ.InstanceCode, addr.String())
+			continue
+		}
+
+		return buffer[:bytesRead], addr, nil
+	}
+}
+
+// Send は、フレームを対象機器へ送信し、応答を待たずに戻ります。
+// 応答は、後の要求の応答待ちで TID の一致しない応答として破棄されます。
+func (c *Client) Send(frame Frame) error {
+	sendData, err := frame.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("フレームのシリアライズに失敗しました (TID: %d): %w", frame.TID, err)
+	}
+	remoteAddr, err := c.remoteAddr()
+	if err != nil {
+		return err
+	}
+	if err := c.Transport.Send(sendData, remoteAddr); err != nil {
+		return fmt.Errorf("UDPデータの送信に失敗しました (宛先: %s): %w", remoteAddr.String(), err)
+	}
+	return nil
+}
+
+// request は、frame を送信して応答を受信し、デシリアライズした応答を返します。
+func (c *Client) request(frame Frame) (Frame, error) {
+	var response Frame
+	data, _, err := c.SendAndReceive(frame)
+	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return response, fmt.Errorf("処理がタイムアウトしました (TID: %d): %w", frame.TID, err)
+		}
+		return response, fmt.Errorf("ECHONET Lite 通信中にエラーが発生しました (TID: %d): %w", frame.TID, err)
+	}
+	if err := response.UnmarshalBinary(data); err != nil {
+		return response, fmt.Errorf("受信データのデシリアライズに失敗しました (TID: %d): %w", frame.TID, err)
+	}
+	return response, nil
+}
+
+// NewRequest は、送信元を SEOJ、宛先を deoj とする要求フレームを、次の TID で作成します。PDC は EDT の長さにします。
+func (c *Client) NewRequest(deoj EOJ, esv ESV, props ...Property) Frame {
+	frame := Frame{
+		EHD1: EchonetLiteEHD1,
+		EHD2: Format1,
+		TID:  c.NextTID(),
+		SEOJ: c.SEOJ,
+		DEOJ: deoj,
+		ESV:  esv,
+		OPC:  byte(len(props)),
+	}
+	for _, p := range props {
+		p.PDC = byte(len(p.EDT))
+		frame.Properties = append(frame.Properties, p)
+	}
+	return frame
+}
+
+// Get は、指定された機器のプロパティを1回の Get 要求で取得し、応答 (Get_Res または Get_SNA) を返します。
+// 機器が一部のプロパティに応答しない場合 (Get_SNA) も応答を返します。応答しなかったプロパティの EDT は空です。
+func (c *Client) Get(deoj EOJ, epcs ...byte) (Frame, error) {
+	props := make([]Property, len(epcs))
+	for i, epc := range epcs {
+		props[i] = Property{EPC: epc}
+	}
+	frame := c.NewRequest(deoj, ESVGet, props...)
+	response, err := c.request(frame)
+	if err != nil {
+		return response, err
+	}
+	if response.ESV != ESVGet_Res && response.ESV != ESVGet_SNA {
+		return response, fmt.Errorf("予期しないESV (0x%X) を受信しました (TID: %d)", response.ESV, frame.TID)
+	}
+	return response, nil
+}
+
+// SetC は、指定された機器のプロパティを1回の SetC 要求で設定し、成功の応答 (Set_Res) を返します。
+// 機器が設定を拒否した場合 (SetC_SNA) は、応答とエラーを返します。
+func (c *Client) SetC(deoj EOJ, props ...Property) (Frame, error) {
+	return c.SendSetC(c.NewRequest(deoj, ESVSetC, props...))
+}
+
+// SendSetC は、NewRequest で作成した SetC フレームを送信し、成功の応答 (Set_Res) を返します。
+// 送信する前に要求の内容 (TID など) を記録する場合に、SetC の代わりに使用します。
+// 機器が設定を拒否した場合 (SetC_SNA) は、応答とエラーを返します。
+func (c *Client) SendSetC(frame Frame) (Frame, error) {
+	response, err := c.request(frame)
+	if err != nil {
+		return response, err
+	}
+	switch response.ESV {
+	case ESVSet_Res:
+		return response, nil
+	case ESVSetC_SNA:
+		return response, fmt.Errorf("SetCエラー応答(失敗)を受信しました (TID: %d, ESV: 0x%X)", response.TID, response.ESV)
+	default:
+		return response, fmt.Errorf("予期しないESV (0x%X) を受信しました (TID: %d)", response.ESV, frame.TID)
+	}
+}
+
+// ReceiveNotifications は、deadline まで要求とは無関係に届くデータグラムを受信し、通知 (INF/INFC) を Notify に渡します。
+// 監視の合間に通知を受け取るために使用します。
+// done が閉じられた場合は deadline を待たずに false を返します。
+func (c *Client) ReceiveNotifications(deadline time.Time, done <-chan struct{}) bool {
+	buffer := make([]byte, c.receiveBufferSize())
+	for {
+		select {
+		case <-done:
+			return false
+		default:
+		}
+		now := c.now()
+		if !now.Before(deadline) {
+			return true
+		}
+		// done を確認できるよう、1回の受信待ちは最大1秒とする
+		wait := deadline
+		if limit := now.Add(time.Second); limit.Before(wait) {
+			wait = limit
+		}
+		bytesRead, addr, err := c.Transport.Receive(buffer, wait)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				continue
+			}
+			log.Printf("[通知] UDPデータの受信に失敗しました: %v", err)
+			// 受信エラーが続く場合に CPU を占有しないよう、deadline まで待機する
+			select {
+			case <-done:
+				return false
+			case <-time.After(deadline.Sub(c.now())):
+				return true
+			}
+		}
+		var received Frame
+		if err := received.UnmarshalBinary(buffer[:bytesRead]); err != nil || !IsNotification(received.ESV) {
+			c.debugf("[通知] 通知以外のデータグラムを破棄しました (送信元: %s)", addr.String())
+			continue
+		}
+		if c.Notify != nil {
+			c.Notify(received, append([]byte(nil), buffer[:bytesRead]...), addr, c.now())
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package echonetlite
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTIDCounterConcurrent(t *testing.T) {
+	tids := &tidCounter{}
+	tids.n.Store(0xFFFF - 100) // wraps around during the test
+	const goroutines, perGoroutine = 8, 1000
+	results := make(chan TID, goroutines*perGoroutine)
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perGoroutine; j++ {
+				results <- tids.next()
+			}
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	seen := make(map[TID]bool)
+	for tid := range results {
+		if tid == 0 {
+			t.Fatal("next returned TID 0")
+		}
+		if seen[tid] {
+			t.Fatalf("TID %d allocated twice", tid)
+		}
+		seen[tid] = true
+	}
+}
+
+func TestPeerSharesTIDs(t *testing.T) {
+	c := NewClient(NewFakeTransport(nil), "192.168.0.10", time.Second)
+	p := c.Peer("192.168.0.11")
+	if first, second := c.NextTID(), p.NextTID(); second == first {
+		t.Errorf("peer allocated TID %d again", second)
+	}
+}
+
+func TestDuplicateFilter(t *testing.T) {
+	f := newDuplicateFilter(time.Minute)
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	key := responseKey{TID: 10, SEOJ: NewEOJ(0x02, 0x7D, 0x01)}
+
+	if f.isDuplicate(key, base) {
+		t.Fatalf("first response must not be reported as duplicate")
+	}
+	if !f.isDuplicate(key, base.Add(2*time.Second)) {
+		t.Fatalf("retransmitted response within the window must be reported as duplicate")
+	}
+	// Same TID from another object is a different response.
+	other := responseKey{TID: 10, SEOJ: NewEOJ(0x02, 0x79, 0x01)}
+	if f.isDuplicate(other, base.Add(3*time.Second)) {
+		t.Fatalf("same TID with different SEOJ must not be reported as duplicate")
+	}
+	// Same TID and SEOJ from another device is a different response.
+	fromPeer := responseKey{Addr: "192.168.0.11:3610", TID: 10, SEOJ: NewEOJ(0x02, 0x7D, 0x01)}
+	if f.isDuplicate(fromPeer, base.Add(4*time.Second)) {
+		t.Fatalf("same TID and SEOJ from another address must not be reported as duplicate")
+	}
+	// After the window expires the key is forgotten (e.g. TID wrap-around).
+	if f.isDuplicate(key, base.Add(2*time.Minute)) {
+		t.Fatalf("expired key must not be reported as duplicate")
+	}
+}
+
+func TestIsNotification(t *testing.T) {
+	for _, esv := range []ESV{ESVInf, ESVInfC} {
+		if !IsNotification(esv) {
+			t.Errorf("ESV 0x%X should be a notification", esv)
+		}
+	}
+	for _, esv := range []ESV{ESVGet_Res, ESVSet_Res, ESVInfC_Res} {
+		if IsNotification(esv) {
+			t.Errorf("ESV 0x%X should not be a notification", esv)
+		}
+	}
+}
+
+// fakeDevice answers Get with one byte per property and accepts SetC except for EPC 0xFF.
+func fakeDevice(data []byte, addr *net.UDPAddr) []Datagram {
+	var req Frame
+	if err := req.UnmarshalBinary(data); err != nil {
+		return nil
+	}
+	res := Frame{EHD1: req.EHD1, EHD2: req.EHD2, TID: req.TID, SEOJ: req.DEOJ, DEOJ: req.SEOJ, OPC: req.OPC}
+	switch req.ESV {
+	case ESVGet:
+		res.ESV = ESVGet_Res
+		for _, p := range req.Properties {
+			res.Properties = append(res.Properties, Property{EPC: p.EPC, PDC: 1, EDT: []byte{p.EPC}})
+		}
+	case ESVSetC:
+		res.ESV = ESVSet_Res
+		for _, p := range req.Properties {
+			if p.EPC == 0xFF {
+				res.ESV = ESVSetC_SNA
+			}
+			res.Properties = append(res.Properties, Property{EPC: p.EPC})
+		}
+	}
+	out, err := res.MarshalBinary()
+	if err != nil {
+		return nil
+	}
+	return []Datagram{{Data: out, Addr: addr}}
+}
+
+func TestClientGetAndSetC(t *testing.T) {
+	transport := NewFakeTransport(fakeDevice)
+	c := NewClient(transport, "192.168.0.10", time.Second)
+	battery := NewEOJ(0x02, 0x7D, 0x01)
+
+	res, err := c.Get(battery, 0xE4, 0xDA)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if res.ESV != ESVGet_Res || len(res.Properties) != 2 || res.Properties[1].EDT[0] != 0xDA {
+		t.Errorf("Get response = %+v", res)
+	}
+
+	if _, err := c.SetC(battery, Property{EPC: 0xDA, EDT: []byte{0x42}}); err != nil {
+		t.Errorf("SetC: %v", err)
+	}
+	sent := transport.Sent()
+	var req Frame
+	if err := req.UnmarshalBinary(sent[len(sent)-1].Data); err != nil {
+		t.Fatal(err)
+	}
+	if req.SEOJ != c.SEOJ || req.Properties[0].PDC != 1 {
+		t.Errorf("SetC request = %+v, want SEOJ %v and PDC 1", req, c.SEOJ)
+	}
+
+	if res, err := c.SetC(battery, Property{EPC: 0xFF, EDT: []byte{0x01}}); err == nil || res.ESV != ESVSetC_SNA {
+		t.Errorf("rejected SetC = %+v, %v; want SetC_SNA and an error", res, err)
+	}
+}
+
+func TestClientPassesNotificationsToNotify(t *testing.T) {
+	device := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10), Port: Port}
+	inf := Frame{EHD1: EchonetLiteEHD1, EHD2: Format1, TID: 1, SEOJ: NewEOJ(0x02, 0x7D, 0x01), DEOJ: NewEOJ(0x05, 0xFF, 0x01),
+		ESV: ESVInf, OPC: 1, Properties: []Property{{EPC: 0xE4, PDC: 1, EDT: []byte{50}}}}
+	data, err := inf.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := NewFakeTransport(func(req []byte, addr *net.UDPAddr) []Datagram {
+		// The notification arrives before the response.
+		return append([]Datagram{{Data: data, Addr: device}}, fakeDevice(req, addr)...)
+	})
+	c := NewClient(transport, "192.168.0.10", time.Second)
+	var notified []Frame
+	c.Notify = func(frame Frame, _ []byte, _ *net.UDPAddr, _ time.Time) { notified = append(notified, frame) }
+
+	if _, err := c.Get(NewEOJ(0x02, 0x7D, 0x01), 0xE4); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(notified) != 1 || notified[0].ESV != ESVInf {
+		t.Errorf("notified %+v, want the INF frame", notified)
+	}
+}
+
+func TestClientRetryBackoffUsesSleep(t *testing.T) {
+	transport := NewFakeTransport(nil) // never answers
+	c := NewClient(transport, "192.168.0.10", time.Millisecond)
+	c.Retries, c.RetryBackoff = 3, 500*time.Millisecond
+	var slept []time.Duration
+	c.Sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	if _, err := c.Get(NewEOJ(0x02, 0x7D, 0x01), 0xE4); err == nil {
+		t.Fatal("Get from a silent device succeeded")
+	}
+	want := []time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second}
+	if len(slept) != len(want) {
+		t.Fatalf("slept %v, want %v", slept, want)
+	}
+	for i := range want {
+		if slept[i] != want[i] {
+			t.Errorf("slept %v, want %v", slept, want)
+			break
+		}
+	}
+	if n := len(transport.Sent()); n != 4 {
+		t.Errorf("sent %d requests, want 4", n)
+	}
+}
@@ -1,9 +1,13 @@
+// Package echonetlite は、ECHONET Lite のフレームの組み立てと解析、UDP での送受信、送受信の記録と再生を提供します。
+//
+// 蓄電池コントローラー (cmd/eibs7-controller) のほか、ECHONET Lite の機器と通信するプログラムから使用できます。
 package echonetlite
 
 import (
 	"bytes"
 	"encoding/binary"
 	"fmt" // エラーメッセージ用
+	"io"
 )
 
 // Echonet Lite Header 1
@@ -278,9 +282,14 @@ func (f *Frame) UnmarshalBinary(data []byte) error {
 		prop.PDC = pdcByte
 
 		// 8c. EDT (Property Value Data) (prop.PDC bytes)
+		// PDC が残りのデータより大きい場合、bytes.Reader.Read はエラーを返さずに途中まで読み込むため、先に長さを確認する
+		// (LAN 上の任意のホストから不正なデータグラムを受信しても、不足分をゼロで埋めた値を返さないようにする)
+		if int(prop.PDC) > reader.Len() {
+			return fmt.Errorf("EDT for property %d (EPC: 0x%X) is truncated: PDC is %d, but only %d bytes remain", i, prop.EPC, prop.PDC, reader.Len())
+		}
 		if prop.PDC > 0 {
 			prop.EDT = make([]byte, prop.PDC)
-			if _, err := reader.Read(prop.EDT); err != nil {
+			if _, err := io.ReadFull(reader, prop.EDT); err != nil {
 				return fmt.Errorf("failed to read EDT for property %d (EPC: 0x%X, PDC: %d): %w", i, prop.EPC, prop.PDC, err)
 			}
 		} else {
@@ -86,3 +86,32 @@ func TestUnmarshalExpectedBytes(t *testing.T) {
     }
 }
 
+func FuzzUnmarshalBinary(f *testing.F) {
+    f.Add([]byte{0x10, 0x81, 0x12, 0x34, 0x05, 0xFF, 0x01, 0x02, 0x7D, 0x01, 0x62, 0x01, 0xE4, 0x00})
+    f.Add([]byte{0x10, 0x81, 0x00, 0x01, 0x02, 0x7D, 0x01, 0x05, 0xFF, 0x01, 0x72, 0x02, 0xE4, 0x01, 0x32, 0xEB, 0x04, 0x00, 0x00, 0x03, 0xE8})
+    f.Add([]byte{0x10, 0x81, 0x00, 0x01, 0x02, 0x7D, 0x01, 0x05, 0xFF, 0x01, 0x72, 0x01, 0xEB, 0x04, 0x00}) // PDC larger than the remaining data
+    f.Add([]byte{0x10, 0x81, 0x00, 0x01, 0x02, 0x7D, 0x01, 0x05, 0xFF, 0x01, 0x72, 0xFF})                   // OPC without properties
+    f.Fuzz(func(t *testing.T, data []byte) {
+        var frame Frame
+        if err := frame.UnmarshalBinary(data); err != nil {
+            return
+        }
+        // A successfully parsed frame must describe bytes that were actually present,
+        // so marshaling it again reproduces the input up to any trailing data.
+        if int(frame.OPC) != len(frame.Properties) {
+            t.Fatalf("OPC = %d, but %d properties", frame.OPC, len(frame.Properties))
+        }
+        for _, p := range frame.Properties {
+            if int(p.PDC) != len(p.EDT) {
+                t.Fatalf("property 0x%X: PDC = %d, but EDT has %d bytes", p.EPC, p.PDC, len(p.EDT))
+            }
+        }
+        out, err := frame.MarshalBinary()
+        if err != nil {
+            t.Fatalf("MarshalBinary of a parsed frame failed: %v", err)
+        }
+        if !bytes.HasPrefix(data, out) {
+            t.Fatalf("round trip mismatch:\n input: %X\noutput: %X", data, out)
+        }
+    })
+}
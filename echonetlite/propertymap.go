@@ -0,0 +1,34 @@
+package echonetlite
+
+import "fmt"
+
+// DecodePropertyMap は、プロパティマップ (状変アナウンス 0x9D, Set 0x9E, Get 0x9F) の EDT を EPC の一覧にします。
+// 先頭の1バイトはプロパティの数です。16個未満の場合は続くバイトが EPC の一覧、
+// 16個以上の場合は続く16バイトがビットマップ (n バイト目の b ビット目が EPC 0x80 + 0x10*b + n) です。
+func DecodePropertyMap(edt []byte) ([]byte, error) {
+	if len(edt) == 0 {
+		return nil, fmt.Errorf("プロパティマップが空です")
+	}
+	count := int(edt[0])
+	if count < 16 {
+		if len(edt) != 1+count {
+			return nil, fmt.Errorf("プロパティマップの長さ (%d バイト) がプロパティの数 (%d) と一致しません", len(edt), count)
+		}
+		return append([]byte(nil), edt[1:]...), nil
+	}
+	if len(edt) != 17 {
+		return nil, fmt.Errorf("ビットマップ形式のプロパティマップの長さ (%d バイト) は 17 バイトである必要があります", len(edt))
+	}
+	var epcs []byte
+	for b := 0; b < 8; b++ {
+		for n := 0; n < 16; n++ {
+			if edt[1+n]&(1<<b) != 0 {
+				epcs = append(epcs, byte(0x80+0x10*b+n))
+			}
+		}
+	}
+	if len(epcs) != count {
+		return nil, fmt.Errorf("ビットマップのプロパティの数 (%d) が先頭のプロパティの数 (%d) と一致しません", len(epcs), count)
+	}
+	return epcs, nil
+}
@@ -0,0 +1,43 @@
+package echonetlite
+
+import "testing"
+
+func TestDecodePropertyMapList(t *testing.T) {
+	epcs, err := DecodePropertyMap([]byte{0x03, 0x80, 0xDA, 0xEB})
+	if err != nil {
+		t.Fatalf("DecodePropertyMap failed: %v", err)
+	}
+	if string(epcs) != string([]byte{0x80, 0xDA, 0xEB}) {
+		t.Errorf("EPCs = %X, want 80 DA EB", epcs)
+	}
+}
+
+func TestDecodePropertyMapBitmap(t *testing.T) {
+	// 16 EPCs: 0x80-0x8F are bit 0 of every byte.
+	edt := make([]byte, 17)
+	edt[0] = 17
+	for n := 1; n <= 16; n++ {
+		edt[n] = 0x01
+	}
+	edt[1+0x0A] |= 1 << 5 // 0xDA
+	epcs, err := DecodePropertyMap(edt)
+	if err != nil {
+		t.Fatalf("DecodePropertyMap failed: %v", err)
+	}
+	if len(epcs) != 17 || epcs[0] != 0x80 || epcs[15] != 0x8F || epcs[16] != 0xDA {
+		t.Errorf("EPCs = %X, want 80-8F and DA", epcs)
+	}
+}
+
+func TestDecodePropertyMapErrors(t *testing.T) {
+	for _, edt := range [][]byte{
+		nil,
+		{0x02, 0x80},
+		{0x10, 0x01},
+		append([]byte{0x11}, make([]byte, 16)...),
+	} {
+		if _, err := DecodePropertyMap(edt); err == nil {
+			t.Errorf("DecodePropertyMap(%X) succeeded, want error", edt)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package echonetlite
+
+import "fmt"
+
+// PropertyName は、指定された機器オブジェクトの EPC に対応するプロパティ名を返します。
+// 不明な組み合わせの場合は、DEOJ と EPC を含む名前を返します。
+func PropertyName(deoj EOJ, epc byte) string {
+	if deoj.ClassGroupCode != 0x0E { // 機器オブジェクトスーパークラス
+		switch epc {
+		case 0x88:
+			return "異常発生状態"
+		case 0x89:
+			return "異常内容"
+		}
+	}
+	switch deoj.ClassGroupCode {
+	case 0x01: // 空調関連機器クラスグループ
+		switch deoj.ClassCode {
+		case 0x30: // 家庭用エアコンクラス
+			switch epc {
+			case 0x80:
+				return "動作状態"
+			case 0xB0:
+				return "運転モード設定"
+			case 0xB3:
+				return "温度設定値"
+			case 0x8F:
+				return "節電動作設定"
+			}
+		}
+	case 0x02: // 住宅設備関連機器クラスグループ
+		switch deoj.ClassCode {
+		case 0x7D: // 蓄電池クラス
+			switch epc {
+			case 0xE4:
+				return "蓄電残量3"
+			case 0xDA:
+				return "運転モード設定"
+			case 0xEB:
+				return "充電電力設定値"
+			case 0xEC:
+				return "放電電力設定値"
+			case 0xD3:
+				return "瞬時充放電電力計測値"
+			case 0xA0:
+				return "AC実効容量（充電）"
+			case 0xD6:
+				return "積算放電電力量計測値"
+			case 0xD8:
+				return "積算充電電力量計測値"
+			case 0xCF:
+				return "運転動作状態"
+			case 0xD0:
+				return "系統連系状態"
+			}
+		case 0x79: // 住宅用太陽光発電クラス
+			switch epc {
+			case 0xE0:
+				return "瞬時発電電力計測値"
+			case 0xE1:
+				return "積算発電電力量計測値"
+			}
+		case 0x87: // 分電盤メータリングクラス
+			switch epc {
+			case 0xC6:
+				return "瞬時電力計測値"
+			}
+		case 0xA5: // マルチ入力PCSクラス
+			switch epc {
+			case 0xE7:
+				return "瞬時電力計測値"
+			}
+		case 0x6B: // 電気給湯機クラス
+			switch epc {
+			case 0xB0:
+				return "沸き上げ自動設定"
+			case 0xB2:
+				return "沸き上げ中状態"
+			}
+		case 0x88: // 低圧スマート電力量メータクラス
+			switch epc {
+			case 0xE7:
+				return "瞬時電力計測値"
+			case 0xE0:
+				return "積算電力量計測値（正方向計測値）"
+			case 0xE3:
+				return "積算電力量計測値（逆方向計測値）"
+			case 0xE1:
+				return "積算電力量単位（正方向、逆方向計測値）"
+			}
+		case 0x7E: // 電気自動車充放電器クラス
+			switch epc {
+			case 0xD3:
+				return "瞬時充放電電力計測値"
+			case 0xDA:
+				return "運転モード設定"
+			}
+		case 0xA1: // 電気自動車充電器クラス
+			switch epc {
+			case 0xD3:
+				return "瞬時充電電力計測値"
+			case 0xDA:
+				return "運転モード設定"
+			}
+		}
+	case 0x0E: // プロファイルクラスグループ
+		switch deoj.ClassCode {
+		case 0xF0: // ノードプロファイルクラス
+			switch epc {
+			case 0x80:
+				return "動作状態"
+			}
+		}
+	}
+	return fmt.Sprintf("不明なプロパティ (DEOJ: %02X%02X, EPC: %02X)", deoj.ClassGroupCode, deoj.ClassCode, epc)
+}
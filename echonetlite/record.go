@@ -0,0 +1,198 @@
+package echonetlite
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// 記録ファイルの1行 (JSON Lines 形式) に対応するエントリ
+type recordEntry struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"dir"`  // "send" または "recv"
+	Addr      string    `json:"addr"` // 送信では宛先、受信では送信元
+	Data      string    `json:"data"` // 16進文字列
+}
+
+const (
+	recordSend = "send"
+	recordRecv = "recv"
+)
+
+// RecordingTransport は、送受信したデータグラムを JSON Lines 形式で書き出す Transport です。
+// 実機との通信を記録し、ReplayHandler で再生することで制御ロジックの回帰テストに利用できます。
+type RecordingTransport struct {
+	Transport
+
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewRecordingTransport は、t で送受信したデータグラムを w に記録する RecordingTransport を作成します。
+func NewRecordingTransport(t Transport, w io.Writer) *RecordingTransport {
+	return &RecordingTransport{Transport: t, enc: json.NewEncoder(w)}
+}
+
+// Send はデータグラムを送信し、送信に成功した場合は記録します。
+func (t *RecordingTransport) Send(data []byte, addr *net.UDPAddr) error {
+	if err := t.Transport.Send(data, addr); err != nil {
+		return err
+	}
+	t.record(recordSend, addr, data)
+	return nil
+}
+
+// Receive はデータグラムを受信し、受信に成功した場合は記録します。
+func (t *RecordingTransport) Receive(buf []byte, deadline time.Time) (int, *net.UDPAddr, error) {
+	n, addr, err := t.Transport.Receive(buf, deadline)
+	if err == nil {
+		t.record(recordRecv, addr, buf[:n])
+	}
+	return n, addr, err
+}
+
+// Err は、記録中に最初に発生した書き込みエラーを返します。
+// 記録の失敗によって通信は中断しません。
+func (t *RecordingTransport) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+func (t *RecordingTransport) record(dir string, addr *net.UDPAddr, data []byte) {
+	entry := recordEntry{Time: time.Now(), Direction: dir, Data: hex.EncodeToString(data)}
+	if addr != nil {
+		entry.Addr = addr.String()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.enc.Encode(entry); err != nil && t.err == nil {
+		t.err = err
+	}
+}
+
+// Exchange は、記録された要求と、次の要求までに受信したデータグラムの組です。
+// 最初の要求より前に受信したデータグラムは、Request.Data が nil の Exchange に格納されます。
+type Exchange struct {
+	Request   Datagram
+	Responses []Datagram
+}
+
+// ReadRecording は、RecordingTransport が書き出した記録を要求ごとの Exchange に分けて読み込みます。
+func ReadRecording(r io.Reader) ([]Exchange, error) {
+	var exchanges []Exchange
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry recordEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		data, err := hex.DecodeString(entry.Data)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid data: %w", line, err)
+		}
+		var addr *net.UDPAddr
+		if entry.Addr != "" {
+			if addr, err = net.ResolveUDPAddr("udp", entry.Addr); err != nil {
+				return nil, fmt.Errorf("line %d: invalid address: %w", line, err)
+			}
+		}
+		d := Datagram{Data: data, Addr: addr}
+
+		switch entry.Direction {
+		case recordSend:
+			exchanges = append(exchanges, Exchange{Request: d})
+		case recordRecv:
+			if len(exchanges) == 0 {
+				exchanges = append(exchanges, Exchange{})
+			}
+			last := &exchanges[len(exchanges)-1]
+			last.Responses = append(last.Responses, d)
+		default:
+			return nil, fmt.Errorf("line %d: unknown direction %q", line, entry.Direction)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return exchanges, nil
+}
+
+// Replayer は、記録された Exchange を元に、送信された要求に対して記録時の応答を返します。
+// Handle を FakeTransport のハンドラとして使用します。
+//
+// 要求は TID を除いた内容で照合し、未使用の Exchange のうち最初に一致したものの応答を返します。
+// 応答の TID は、記録時の要求と同じであれば今回の要求の TID に置き換えます。
+type Replayer struct {
+	mu        sync.Mutex
+	exchanges []Exchange
+	used      []bool
+	unmatched []Datagram
+}
+
+// NewReplayer は、exchanges を再生する Replayer を作成します。
+func NewReplayer(exchanges []Exchange) *Replayer {
+	return &Replayer{exchanges: exchanges, used: make([]bool, len(exchanges))}
+}
+
+// Handle は、要求に一致する記録済みの応答を返します。一致するものがない場合は応答を返しません (タイムアウトになります)。
+func (r *Replayer) Handle(data []byte, addr *net.UDPAddr) []Datagram {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, ex := range r.exchanges {
+		if r.used[i] || ex.Request.Data == nil || !sameExceptTID(ex.Request.Data, data) {
+			continue
+		}
+		r.used[i] = true
+		responses := make([]Datagram, 0, len(ex.Responses))
+		for _, res := range ex.Responses {
+			out := append([]byte(nil), res.Data...)
+			if len(out) >= 4 && len(data) >= 4 && bytes.Equal(out[2:4], ex.Request.Data[2:4]) {
+				copy(out[2:4], data[2:4])
+			}
+			responses = append(responses, Datagram{Data: out, Addr: res.Addr})
+		}
+		return responses
+	}
+	r.unmatched = append(r.unmatched, Datagram{Data: append([]byte(nil), data...), Addr: addr})
+	return nil
+}
+
+// Unmatched は、記録に一致するものがなかった要求を返します。
+func (r *Replayer) Unmatched() []Datagram {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Datagram(nil), r.unmatched...)
+}
+
+// Remaining は、まだ再生されていない要求の数を返します。
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for i, ex := range r.exchanges {
+		if !r.used[i] && ex.Request.Data != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// sameExceptTID は、TID (3〜4バイト目) を除いて2つのフレームが一致すれば true を返します。
+func sameExceptTID(a, b []byte) bool {
+	if len(a) != len(b) || len(a) < 4 {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(a[:2], b[:2]) && bytes.Equal(a[4:], b[4:])
+}
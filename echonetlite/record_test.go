@@ -0,0 +1,63 @@
+package echonetlite
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	device := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10), Port: 3610}
+	request := []byte{0x10, 0x81, 0x00, 0x01, 0x05, 0xFF, 0x01, 0x02, 0x7D, 0x01, 0x62, 0x01, 0xE4, 0x00}
+	response := []byte{0x10, 0x81, 0x00, 0x01, 0x02, 0x7D, 0x01, 0x05, 0xFF, 0x01, 0x72, 0x01, 0xE4, 0x01, 0x32}
+	notification := []byte{0x10, 0x81, 0x00, 0x09, 0x02, 0x7D, 0x01, 0x05, 0xFF, 0x01, 0x73, 0x01, 0xDA, 0x01, 0x42}
+
+	var log bytes.Buffer
+	rec := NewRecordingTransport(NewFakeTransport(func(data []byte, addr *net.UDPAddr) []Datagram {
+		return []Datagram{{Data: response, Addr: addr}, {Data: notification, Addr: addr}}
+	}), &log)
+	if err := rec.Send(request, device); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	buf := make([]byte, 64)
+	for {
+		if _, _, err := rec.Receive(buf, time.Now()); err != nil {
+			break
+		}
+	}
+	if err := rec.Err(); err != nil {
+		t.Fatalf("recording failed: %v", err)
+	}
+
+	exchanges, err := ReadRecording(&log)
+	if err != nil {
+		t.Fatalf("ReadRecording failed: %v", err)
+	}
+	if len(exchanges) != 1 || len(exchanges[0].Responses) != 2 {
+		t.Fatalf("unexpected exchanges: %+v", exchanges)
+	}
+
+	// Replay the same request with a different TID.
+	replayer := NewReplayer(exchanges)
+	replayed := append([]byte(nil), request...)
+	replayed[2], replayed[3] = 0x12, 0x34
+	responses := replayer.Handle(replayed, device)
+	if len(responses) != 2 {
+		t.Fatalf("expected 2 responses, got %d", len(responses))
+	}
+	if responses[0].Data[2] != 0x12 || responses[0].Data[3] != 0x34 {
+		t.Errorf("response TID was not rewritten: %X", responses[0].Data)
+	}
+	if !bytes.Equal(responses[1].Data, notification) {
+		t.Errorf("notification was modified: %X", responses[1].Data)
+	}
+	if replayer.Remaining() != 0 {
+		t.Errorf("Remaining = %d", replayer.Remaining())
+	}
+
+	// A second identical request has no recorded response left.
+	if responses := replayer.Handle(replayed, device); responses != nil || len(replayer.Unmatched()) != 1 {
+		t.Errorf("expected unmatched request, got %v", responses)
+	}
+}
@@ -0,0 +1,161 @@
+package echonetlite
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Transport は ECHONET Lite データグラムの送受信を抽象化します。
+// 実機との通信には UDPTransport を、実機やソケットを使わないテストには FakeTransport を使用します。
+type Transport interface {
+	// Send はデータグラムを指定されたアドレスへ送信します。
+	Send(data []byte, addr *net.UDPAddr) error
+	// Receive は deadline までにデータグラムを1つ受信して buf に格納し、受信バイト数と送信元を返します。
+	// deadline までに受信できなかった場合は Timeout() が true を返す net.Error を返します。
+	// データグラムが buf より大きい場合、buf に収まる分だけが格納されます。
+	Receive(buf []byte, deadline time.Time) (int, *net.UDPAddr, error)
+	// Close は送受信に使用しているリソースを解放します。
+	Close() error
+}
+
+// UDPTransport は UDP ソケットを使用する Transport です。
+type UDPTransport struct {
+	conn *net.UDPConn
+}
+
+// ListenUDP は指定されたポートにバインドした UDPTransport を作成します。
+// ECHONET Lite では応答が送信元ポートではなく 3610 番ポート宛てに届く機器があるため、通常は 3610 を指定します。
+func ListenUDP(port int) (*UDPTransport, error) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: port})
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on UDP port %d: %w", port, err)
+	}
+	return &UDPTransport{conn: conn}, nil
+}
+
+// MulticastIP は ECHONET Lite のマルチキャストアドレスです。機器は状態の変化の通知 (INF) をこのアドレスに送信します。
+var MulticastIP = net.IPv4(224, 0, 23, 0)
+
+// ListenMulticastUDP は指定されたポートにバインドし、マルチキャストグループ (MulticastIP) に参加した UDPTransport を作成します。
+// ifi が nil の場合はシステムが選択したインターフェースで参加します。ユニキャストのデータグラムも受信します。
+func ListenMulticastUDP(port int, ifi *net.Interface) (*UDPTransport, error) {
+	conn, err := net.ListenMulticastUDP("udp4", ifi, &net.UDPAddr{IP: MulticastIP, Port: port})
+	if err != nil {
+		return nil, fmt.Errorf("failed to join multicast group %s on UDP port %d: %w", MulticastIP, port, err)
+	}
+	return &UDPTransport{conn: conn}, nil
+}
+
+// Send はデータグラムを指定されたアドレスへ送信します。
+func (t *UDPTransport) Send(data []byte, addr *net.UDPAddr) error {
+	_, err := t.conn.WriteToUDP(data, addr)
+	return err
+}
+
+// Receive は deadline までにデータグラムを1つ受信します。
+func (t *UDPTransport) Receive(buf []byte, deadline time.Time) (int, *net.UDPAddr, error) {
+	if err := t.conn.SetReadDeadline(deadline); err != nil {
+		return 0, nil, err
+	}
+	return t.conn.ReadFromUDP(buf)
+}
+
+// Close はソケットを閉じます。
+func (t *UDPTransport) Close() error {
+	return t.conn.Close()
+}
+
+// LocalAddr はソケットのローカルアドレスを返します。
+func (t *UDPTransport) LocalAddr() *net.UDPAddr {
+	addr, _ := t.conn.LocalAddr().(*net.UDPAddr)
+	return addr
+}
+
+// Datagram は FakeTransport で送受信されたデータグラムです。
+// 送信したものでは Addr は宛先、受信するものでは Addr は送信元を表します。
+type Datagram struct {
+	Data []byte
+	Addr *net.UDPAddr
+}
+
+// FakeHandler は FakeTransport に送信されたデータグラムを受け取り、応答として受信させるデータグラムを返します。
+type FakeHandler func(data []byte, addr *net.UDPAddr) []Datagram
+
+// FakeTransport はソケットを使用しないインメモリの Transport です。
+// 送信されたデータグラムを Handler に渡し、Handler が返したデータグラムを受信キューに積みます。
+// 受信キューが空の場合、Receive は待機せずに直ちにタイムアウトします。
+type FakeTransport struct {
+	mu      sync.Mutex
+	handler FakeHandler
+	sent    []Datagram
+	queue   []Datagram
+	closed  bool
+}
+
+// NewFakeTransport は handler を応答の生成に使用する FakeTransport を作成します。handler は nil でも構いません。
+func NewFakeTransport(handler FakeHandler) *FakeTransport {
+	return &FakeTransport{handler: handler}
+}
+
+// Send はデータグラムを記録し、Handler が返した応答を受信キューに積みます。
+func (t *FakeTransport) Send(data []byte, addr *net.UDPAddr) error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return net.ErrClosed
+	}
+	sent := Datagram{Data: append([]byte(nil), data...), Addr: addr}
+	t.sent = append(t.sent, sent)
+	handler := t.handler
+	t.mu.Unlock()
+
+	if handler == nil {
+		return nil
+	}
+	responses := handler(sent.Data, addr)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.queue = append(t.queue, responses...)
+	return nil
+}
+
+// Receive は受信キューの先頭のデータグラムを返します。キューが空の場合は直ちにタイムアウトします。
+func (t *FakeTransport) Receive(buf []byte, deadline time.Time) (int, *net.UDPAddr, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return 0, nil, net.ErrClosed
+	}
+	if len(t.queue) == 0 {
+		return 0, nil, os.ErrDeadlineExceeded
+	}
+	d := t.queue[0]
+	t.queue = t.queue[1:]
+	return copy(buf, d.Data), d.Addr, nil
+}
+
+// Close は FakeTransport を閉じます。以降の Send と Receive はエラーになります。
+func (t *FakeTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	return nil
+}
+
+// Inject は要求とは無関係に届くデータグラム (通知など) を受信キューに積みます。
+func (t *FakeTransport) Inject(data []byte, from *net.UDPAddr) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.queue = append(t.queue, Datagram{Data: append([]byte(nil), data...), Addr: from})
+}
+
+// Sent はこれまでに送信されたデータグラムを返します。
+func (t *FakeTransport) Sent() []Datagram {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Datagram(nil), t.sent...)
+}
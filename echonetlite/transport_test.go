@@ -0,0 +1,108 @@
+package echonetlite
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFakeTransportRespondsViaHandler(t *testing.T) {
+	device := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10), Port: 3610}
+	tr := NewFakeTransport(func(data []byte, addr *net.UDPAddr) []Datagram {
+		return []Datagram{{Data: append([]byte{0xAA}, data...), Addr: addr}}
+	})
+
+	if err := tr.Send([]byte{0x01, 0x02}, device); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	buf := make([]byte, 16)
+	n, from, err := tr.Receive(buf, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	if !bytes.Equal(buf[:n], []byte{0xAA, 0x01, 0x02}) || from.String() != device.String() {
+		t.Errorf("Receive = (%X, %s)", buf[:n], from)
+	}
+	if sent := tr.Sent(); len(sent) != 1 || !bytes.Equal(sent[0].Data, []byte{0x01, 0x02}) {
+		t.Errorf("Sent = %v", sent)
+	}
+}
+
+func TestFakeTransportTimesOutWhenEmpty(t *testing.T) {
+	tr := NewFakeTransport(nil)
+	tr.Inject([]byte{0x10}, &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10), Port: 3610})
+
+	buf := make([]byte, 16)
+	if _, _, err := tr.Receive(buf, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Receive of injected datagram failed: %v", err)
+	}
+	_, _, err := tr.Receive(buf, time.Now().Add(time.Hour))
+	if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() || !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+
+	tr.Close()
+	if err := tr.Send([]byte{0x01}, nil); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Send after Close = %v", err)
+	}
+}
+
+func TestUDPTransportLoopback(t *testing.T) {
+	a, err := ListenUDP(0)
+	if err != nil {
+		t.Skipf("UDP not available: %v", err)
+	}
+	defer a.Close()
+	b, err := ListenUDP(0)
+	if err != nil {
+		t.Fatalf("ListenUDP failed: %v", err)
+	}
+	defer b.Close()
+
+	to := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: b.LocalAddr().Port}
+	if err := a.Send([]byte{0x10, 0x81}, to); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	buf := make([]byte, 16)
+	n, from, err := b.Receive(buf, time.Now().Add(2*time.Second))
+	if err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	if !bytes.Equal(buf[:n], []byte{0x10, 0x81}) || from.Port != a.LocalAddr().Port {
+		t.Errorf("Receive = (%X, %s)", buf[:n], from)
+	}
+
+	_, _, err = b.Receive(buf, time.Now().Add(10*time.Millisecond))
+	if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+}
+
+func TestMulticastUDPTransportReceivesGroupTraffic(t *testing.T) {
+	listener, err := ListenMulticastUDP(0, nil)
+	if err != nil {
+		t.Skipf("multicast not available: %v", err)
+	}
+	defer listener.Close()
+	sender, err := ListenUDP(0)
+	if err != nil {
+		t.Fatalf("ListenUDP failed: %v", err)
+	}
+	defer sender.Close()
+
+	group := &net.UDPAddr{IP: MulticastIP, Port: listener.LocalAddr().Port}
+	if err := sender.Send([]byte{0x10, 0x81}, group); err != nil {
+		t.Skipf("multicast send not available: %v", err)
+	}
+	buf := make([]byte, 16)
+	n, _, err := listener.Receive(buf, time.Now().Add(time.Second))
+	if err != nil {
+		t.Skipf("multicast loopback not available: %v", err)
+	}
+	if !bytes.Equal(buf[:n], []byte{0x10, 0x81}) {
+		t.Errorf("Receive = %X", buf[:n])
+	}
+}
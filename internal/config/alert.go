@@ -0,0 +1,136 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// AlertsConfig は、異常や制御上の出来事を Slack や Discord の Webhook、LINE、ntfy、Pushover に通知する設定です。
+type AlertsConfig struct {
+	Enabled            bool           `toml:"enabled"`
+	WebhookURL         Secret         `toml:"webhook_url"`          // Incoming Webhook の URL。"env:" または "file:" で参照できる。LINE、ntfy、Pushover のみに通知する場合は不要
+	Format             string         `toml:"format"`               // "slack" (デフォルト) または "discord"
+	MinIntervalMinutes int            `toml:"min_interval_minutes"` // 同じ種類の通知を送信する最短の間隔 (分、デフォルト: 30)
+	Events             AlertEvents    `toml:"events"`
+	Line               LineConfig     `toml:"line"`
+	Ntfy               NtfyConfig     `toml:"ntfy"`
+	Pushover           PushoverConfig `toml:"pushover"`
+}
+
+// LineConfig は、LINE Messaging API で通知する設定です。
+type LineConfig struct {
+	Enabled          bool   `toml:"enabled"`
+	ChannelToken     Secret `toml:"channel_token"`      // チャネルアクセストークン。"env:" または "file:" で参照できる
+	To               string `toml:"to"`                 // 送信先のユーザー ID またはグループ ID。未設定の場合は友だち全員に送信する
+	DailySummaryTime string `toml:"daily_summary_time"` // 毎日の蓄電池のまとめを送信する時刻 (HH:MM)。未設定の場合は送信しない
+}
+
+// validate は、LineConfig の値の妥当性を確認します。
+func (c *LineConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	token, err := c.ChannelToken.resolve()
+	if err != nil {
+		return fmt.Errorf("'alerts.line.channel_token' を読み込めませんでした: %w", err)
+	}
+	if token == "" {
+		return fmt.Errorf("'alerts.line.enabled' が true の場合は 'alerts.line.channel_token' の設定が必要です")
+	}
+	c.ChannelToken = token
+	if c.DailySummaryTime != "" {
+		if _, err := time.Parse("15:04", c.DailySummaryTime); err != nil {
+			return fmt.Errorf("'alerts.line.daily_summary_time' ('%s') は HH:MM 形式である必要があります", c.DailySummaryTime)
+		}
+	}
+	return nil
+}
+
+// AlertEvents は、通知する出来事の種類ごとの有効・無効です。
+type AlertEvents struct {
+	Unreachable bool `toml:"unreachable"`  // 機器に到達できなくなった
+	Fault       bool `toml:"fault"`        // 機器が異常の発生を通知した
+	SetSNA      bool `toml:"set_sna"`      // SetC が拒否された (SetC_SNA)
+	ImportLimit bool `toml:"import_limit"` // 買電電力が上限を超えた
+	Storm       bool `toml:"storm"`        // 嵐警戒モードを開始した
+}
+
+// Webhook の形式
+const (
+	alertFormatSlack   = "slack"
+	AlertFormatDiscord = "discord"
+)
+
+// Validate は、AlertsConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (c *AlertsConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if err := c.Line.validate(); err != nil {
+		return err
+	}
+	if err := c.Ntfy.validate(); err != nil {
+		return err
+	}
+	if err := c.Pushover.validate(); err != nil {
+		return err
+	}
+	webhookURL, err := c.WebhookURL.resolve()
+	if err != nil {
+		return fmt.Errorf("'alerts.webhook_url' を読み込めませんでした: %w", err)
+	}
+	if webhookURL == "" && !c.Line.Enabled && !c.Ntfy.Enabled && !c.Pushover.Enabled {
+		return fmt.Errorf("'alerts.enabled' が true の場合は 'alerts.webhook_url'、[alerts.line]、[alerts.ntfy]、[alerts.pushover] のいずれかの設定が必要です")
+	}
+	if u, err := url.Parse(string(webhookURL)); webhookURL != "" && (err != nil || (u.Scheme != "https" && u.Scheme != "http")) {
+		return fmt.Errorf("'alerts.webhook_url' は http または https の URL である必要があります")
+	}
+	c.WebhookURL = webhookURL
+	switch c.Format {
+	case "":
+		c.Format = alertFormatSlack
+	case alertFormatSlack, AlertFormatDiscord:
+	default:
+		return fmt.Errorf("'alerts.format' ('%s') は \"%s\" または \"%s\" である必要があります", c.Format, alertFormatSlack, AlertFormatDiscord)
+	}
+	if c.MinIntervalMinutes == 0 {
+		c.MinIntervalMinutes = 30
+	}
+	if c.MinIntervalMinutes < 0 {
+		return fmt.Errorf("'alerts.min_interval_minutes' (%d) は 1 以上である必要があります", c.MinIntervalMinutes)
+	}
+	if c.Events == (AlertEvents{}) && c.Line.DailySummaryTime == "" {
+		return fmt.Errorf("'alerts.events' で通知する出来事を1つ以上有効にしてください")
+	}
+	return nil
+}
+
+// AlertEvent は、通知する出来事の種類です。
+type AlertEvent string
+
+const (
+	AlertUnreachable AlertEvent = "unreachable"
+	AlertFault       AlertEvent = "fault"
+	AlertSetSNA      AlertEvent = "set_sna"
+	AlertImportLimit AlertEvent = "import_limit"
+	AlertStorm       AlertEvent = "storm"
+)
+
+// Enabled は、event の通知が有効であれば true を返します。
+func (e AlertEvents) Enabled(event AlertEvent) bool {
+	switch event {
+	case AlertUnreachable:
+		return e.Unreachable
+	case AlertFault:
+		return e.Fault
+	case AlertSetSNA:
+		return e.SetSNA
+	case AlertImportLimit:
+		return e.ImportLimit
+	case AlertStorm:
+		return e.Storm
+	default:
+		return false
+	}
+}
@@ -0,0 +1,24 @@
+package config
+
+import "testing"
+
+func TestAlertsConfigValidate(t *testing.T) {
+	c := AlertsConfig{Enabled: true, WebhookURL: "https://hooks.slack.com/services/x", Events: AlertEvents{Fault: true}}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Format != alertFormatSlack || c.MinIntervalMinutes != 30 {
+		t.Errorf("defaults = %q, %d", c.Format, c.MinIntervalMinutes)
+	}
+	for _, bad := range []AlertsConfig{
+		{Enabled: true, Events: AlertEvents{Fault: true}},
+		{Enabled: true, WebhookURL: "hooks.slack.com", Events: AlertEvents{Fault: true}},
+		{Enabled: true, WebhookURL: "https://example.com", Format: "teams", Events: AlertEvents{Fault: true}},
+		{Enabled: true, WebhookURL: "https://example.com", MinIntervalMinutes: -1, Events: AlertEvents{Fault: true}},
+		{Enabled: true, WebhookURL: "https://example.com"},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", bad)
+		}
+	}
+}
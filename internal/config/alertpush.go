@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// NtfyConfig は、ntfy (https://ntfy.sh) でスマートフォンに通知する設定です。
+type NtfyConfig struct {
+	Enabled  bool     `toml:"enabled"`
+	Server   string   `toml:"server"`   // デフォルト: "https://ntfy.sh"
+	Topic    string   `toml:"topic"`    // 通知するトピック。公開サーバーでは推測されにくい名前にする
+	Token    Secret   `toml:"token"`    // アクセストークン。"env:" または "file:" で参照できる。未設定の場合は認証しない
+	Priority string   `toml:"priority"` // "min"、"low"、"default"、"high" (デフォルト)、"urgent"
+	Events   []string `toml:"events"`   // 通知する出来事の種類。未設定の場合は [alerts.events] で有効な全て
+}
+
+// validate は、NtfyConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (c *NtfyConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Server == "" {
+		c.Server = "https://ntfy.sh"
+	}
+	if u, err := url.Parse(c.Server); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("'alerts.ntfy.server' ('%s') は http または https の URL である必要があります", c.Server)
+	}
+	c.Server = strings.TrimSuffix(c.Server, "/")
+	if c.Topic == "" || strings.Contains(c.Topic, "/") {
+		return fmt.Errorf("'alerts.ntfy.enabled' が true の場合は 'alerts.ntfy.topic' の設定が必要です ('/' は使用できません)")
+	}
+	token, err := c.Token.resolve()
+	if err != nil {
+		return fmt.Errorf("'alerts.ntfy.token' を読み込めませんでした: %w", err)
+	}
+	c.Token = token
+	switch c.Priority {
+	case "":
+		c.Priority = "high"
+	case "min", "low", "default", "high", "urgent":
+	default:
+		return fmt.Errorf("'alerts.ntfy.priority' ('%s') は \"min\"、\"low\"、\"default\"、\"high\"、\"urgent\" のいずれかである必要があります", c.Priority)
+	}
+	return validateAlertEventNames("alerts.ntfy.events", c.Events)
+}
+
+// Accepts は、event を ntfy に通知する場合に true を返します。
+func (c NtfyConfig) Accepts(event AlertEvent) bool {
+	return acceptsAlertEvent(c.Events, event)
+}
+
+// PushoverConfig は、Pushover (https://pushover.net) でスマートフォンに通知する設定です。
+type PushoverConfig struct {
+	Enabled  bool     `toml:"enabled"`
+	AppToken Secret   `toml:"app_token"` // アプリケーションの API トークン。"env:" または "file:" で参照できる
+	UserKey  Secret   `toml:"user_key"`  // ユーザーキーまたはグループキー。"env:" または "file:" で参照できる
+	Priority int      `toml:"priority"`  // -2 から 1 (デフォルト: 0)。確認が必要な 2 (緊急) は使用できない
+	Events   []string `toml:"events"`    // 通知する出来事の種類。未設定の場合は [alerts.events] で有効な全て
+}
+
+// validate は、PushoverConfig の値の妥当性を確認します。
+func (c *PushoverConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	appToken, err := c.AppToken.resolve()
+	if err != nil {
+		return fmt.Errorf("'alerts.pushover.app_token' を読み込めませんでした: %w", err)
+	}
+	userKey, err := c.UserKey.resolve()
+	if err != nil {
+		return fmt.Errorf("'alerts.pushover.user_key' を読み込めませんでした: %w", err)
+	}
+	if appToken == "" || userKey == "" {
+		return fmt.Errorf("'alerts.pushover.enabled' が true の場合は 'alerts.pushover.app_token' と 'alerts.pushover.user_key' の設定が必要です")
+	}
+	c.AppToken, c.UserKey = appToken, userKey
+	if c.Priority < -2 || c.Priority > 1 {
+		return fmt.Errorf("'alerts.pushover.priority' (%d) は -2 から 1 の範囲である必要があります", c.Priority)
+	}
+	return validateAlertEventNames("alerts.pushover.events", c.Events)
+}
+
+// Accepts は、event を Pushover に通知する場合に true を返します。
+func (c PushoverConfig) Accepts(event AlertEvent) bool {
+	return acceptsAlertEvent(c.Events, event)
+}
+
+// validateAlertEventNames は、出来事の種類の名前が正しいことを確認します。
+func validateAlertEventNames(key string, names []string) error {
+	for _, name := range names {
+		switch AlertEvent(name) {
+		case AlertUnreachable, AlertFault, AlertSetSNA, AlertImportLimit, AlertStorm:
+		default:
+			return fmt.Errorf("'%s' の '%s' は \"%s\"、\"%s\"、\"%s\"、\"%s\"、\"%s\" のいずれかである必要があります",
+				key, name, AlertUnreachable, AlertFault, AlertSetSNA, AlertImportLimit, AlertStorm)
+		}
+	}
+	return nil
+}
+
+// acceptsAlertEvent は、names が空または event を含む場合に true を返します。
+func acceptsAlertEvent(names []string, event AlertEvent) bool {
+	if len(names) == 0 {
+		return true
+	}
+	for _, name := range names {
+		if AlertEvent(name) == event {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ArchiveUploadConfig は、日ごとの監視データの CSV を S3 互換のストレージにアップロードする設定です。
+// log_monitoring_data と monitoring_csv_dir の設定が必要です。
+// 日付が変わって書き込みを終えたファイルをアップロードし、成功するまでは手元に残して retry_interval_minutes ごとに再試行します。
+type ArchiveUploadConfig struct {
+	Enabled              bool   `toml:"enabled"`
+	Endpoint             string `toml:"endpoint"`               // S3 互換の API の URL (例: "https://s3.ap-northeast-1.amazonaws.com"、MinIO の場合は "http://minio:9000")
+	Region               string `toml:"region"`                 // 署名に使用するリージョン (デフォルト: "us-east-1")
+	Bucket               string `toml:"bucket"`                 // アップロード先のバケット
+	Prefix               string `toml:"prefix"`                 // オブジェクトのキーの接頭辞 (例: "eibs7/")
+	AccessKeyID          string `toml:"access_key_id"`          // アクセスキー ID
+	SecretAccessKey      Secret `toml:"secret_access_key"`      // シークレットアクセスキー。"env:" または "file:" で参照できる
+	RetryIntervalMinutes int    `toml:"retry_interval_minutes"` // アップロードしていないファイルを確認する間隔 (分、デフォルト: 10)
+	KeepLocal            bool   `toml:"keep_local"`             // true の場合はアップロード後もファイルを削除しない (デフォルト: 削除する)
+}
+
+// Validate は、ArchiveUploadConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (c *ArchiveUploadConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	u, err := url.Parse(c.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("'archive_upload.endpoint' ('%s') は http または https の URL である必要があります", c.Endpoint)
+	}
+	c.Endpoint = strings.TrimSuffix(c.Endpoint, "/")
+	if c.Region == "" {
+		c.Region = "us-east-1"
+	}
+	if c.Bucket == "" {
+		return fmt.Errorf("'archive_upload.enabled' が true の場合は 'archive_upload.bucket' の設定が必要です")
+	}
+	if c.AccessKeyID == "" {
+		return fmt.Errorf("'archive_upload.enabled' が true の場合は 'archive_upload.access_key_id' の設定が必要です")
+	}
+	key, err := c.SecretAccessKey.resolve()
+	if err != nil {
+		return fmt.Errorf("'archive_upload.secret_access_key' を読み込めませんでした: %w", err)
+	}
+	if key == "" {
+		return fmt.Errorf("'archive_upload.enabled' が true の場合は 'archive_upload.secret_access_key' の設定が必要です")
+	}
+	c.SecretAccessKey = key
+	if c.RetryIntervalMinutes == 0 {
+		c.RetryIntervalMinutes = 10
+	}
+	if c.RetryIntervalMinutes < 0 {
+		return fmt.Errorf("'archive_upload.retry_interval_minutes' (%d) は正の整数である必要があります", c.RetryIntervalMinutes)
+	}
+	return nil
+}
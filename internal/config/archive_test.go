@@ -0,0 +1,24 @@
+package config
+
+import "testing"
+
+func TestArchiveUploadConfigValidate(t *testing.T) {
+	c := ArchiveUploadConfig{Enabled: true, Endpoint: "https://s3.example.com/", Bucket: "b", AccessKeyID: "id", SecretAccessKey: "key"}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Endpoint != "https://s3.example.com" || c.Region != "us-east-1" || c.RetryIntervalMinutes != 10 {
+		t.Errorf("defaults = %+v", c)
+	}
+	for _, bad := range []ArchiveUploadConfig{
+		{Enabled: true, Endpoint: "s3.example.com", Bucket: "b", AccessKeyID: "id", SecretAccessKey: "key"},
+		{Enabled: true, Endpoint: "https://s3.example.com", AccessKeyID: "id", SecretAccessKey: "key"},
+		{Enabled: true, Endpoint: "https://s3.example.com", Bucket: "b", SecretAccessKey: "key"},
+		{Enabled: true, Endpoint: "https://s3.example.com", Bucket: "b", AccessKeyID: "id"},
+		{Enabled: true, Endpoint: "https://s3.example.com", Bucket: "b", AccessKeyID: "id", SecretAccessKey: "key", RetryIntervalMinutes: -1},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", bad)
+		}
+	}
+}
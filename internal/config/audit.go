@@ -0,0 +1,7 @@
+package config
+
+// AuditLogConfig は、制御の判断を記録する監査ログの設定です。
+type AuditLogConfig struct {
+	Enabled bool   `toml:"enabled"`
+	File    string `toml:"file"` // 出力先のファイル (JSON Lines 形式、追記のみ)
+}
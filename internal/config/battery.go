@@ -0,0 +1,21 @@
+package config
+
+import "fmt"
+
+// ValidateBatteryInstances は、battery_instances の値を確認します。
+func ValidateBatteryInstances(instances []int) error {
+	seen := make(map[int]bool)
+	for _, i := range instances {
+		if i < 0x01 || i > 0x7F {
+			return fmt.Errorf("インスタンスコード (%d) は 1 から 127 の範囲である必要があります", i)
+		}
+		if seen[i] {
+			return fmt.Errorf("インスタンスコード (%d) が重複しています", i)
+		}
+		seen[i] = true
+	}
+	return nil
+}
+
+// EIBS7 の充電電力設定値 (EPC 0xEB) の上限値 (W)
+const defaultDeviceMaxChargeWatts = 5430
@@ -0,0 +1,14 @@
+package config
+
+import "testing"
+
+func TestValidateBatteryInstances(t *testing.T) {
+	if err := ValidateBatteryInstances([]int{1, 2}); err != nil {
+		t.Errorf("ValidateBatteryInstances([1 2]) = %v", err)
+	}
+	for _, bad := range [][]int{{0}, {1, 128}, {1, 1}} {
+		if err := ValidateBatteryInstances(bad); err == nil {
+			t.Errorf("ValidateBatteryInstances(%v) succeeded", bad)
+		}
+	}
+}
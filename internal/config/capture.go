@@ -0,0 +1,7 @@
+package config
+
+// CaptureConfig は、送受信した ECHONET Lite データグラムのキャプチャ設定です。
+type CaptureConfig struct {
+	Enabled bool   `toml:"enabled"` // 起動時からキャプチャするかどうか (SIGUSR2 で切り替え可能)
+	File    string `toml:"file"`    // 出力先の pcapng ファイル
+}
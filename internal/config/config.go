@@ -0,0 +1,603 @@
+// Package config は、蓄電池コントローラーの設定ファイル (TOML, YAML, JSON) の読み込みと値の妥当性の確認を提供します。
+//
+// Load は、設定ファイルを読み込み、デフォルト値を設定して値を検証した Config を返します。
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/controller"
+)
+
+// ECHONET Lite フレームの最小長 (ヘッダ(4) + EOJ(6) + ESV(1) + OPC(1))
+const minFrameLength = 12
+
+// 送信元 (コントローラー) の ECHONET Lite オブジェクトのデフォルト値
+var DefaultControllerEOJ = echonetlite.NewEOJ(0x05, 0xFF, 0x01) // クラスグループ: 管理操作, クラス: コントローラ, インスタンス: 1
+
+// 受信バッファサイズのデフォルト値
+const DefaultReceiveBufferSize = echonetlite.DefaultReceiveBufferSize
+
+// 設定ファイルの内容をマッピングする構造体
+type Config struct {
+	TargetIP                         string  `toml:"target_ip"`           // IPアドレスまたはホスト名
+	TargetID                         string  `toml:"target_id"`           // ノードプロファイルの識別番号 (EPC 0x83, 16進)。指定した場合はアドレス変更時に再探索する
+	ControllerInstance               int     `toml:"controller_instance"` // 送信元のコントローラーオブジェクトのインスタンスコード (1〜127)。未設定の場合は 1
+	ControllerEOJ                    string  `toml:"controller_eoj"`      // 送信元の ECHONET Lite オブジェクト (16進6桁)。指定した場合は controller_instance の代わりに使用する
+	MonitorIntervalSeconds           int     `toml:"monitor_interval_seconds"`
+	Timezone                         string  `toml:"timezone"` // 時間帯・期間・日ごとの集計を判定するタイムゾーン (IANA 名, 例: "Asia/Tokyo")。未設定の場合はシステムのタイムゾーン
+	ChargeStartTime                  string  `toml:"charge_start_time"`
+	ChargeEndTime                    string  `toml:"charge_end_time"`
+	ChargeCron                       string  `toml:"charge_cron"` // 充電時間帯の cron 形式の式 (分 時 日 月 曜日)。指定した場合は charge_start_time と charge_end_time の代わりに使用する
+	ChargePowerUpdateIntervalMinutes int     `toml:"charge_power_update_interval_minutes"`
+	AutoModeThresholdWatts           int     `toml:"auto_mode_threshold_watts"`
+	ChargeModeThresholdWatts         int     `toml:"charge_mode_threshold_watts"`
+	SurplusSmoothingSamples          int     `toml:"surplus_smoothing_samples"`     // 運転モードの判定に使用する余剰電力の移動平均のサンプル数
+	SurplusSmoothingAlpha            float64 `toml:"surplus_smoothing_alpha"`       // 指定した場合は移動平均の代わりに指数移動平均 (EMA) を使用する (0〜1)
+	MinChargeModeDwellMinutes        int     `toml:"min_charge_mode_dwell_minutes"` // 「充電」の最低滞在時間 (分)
+	MinAutoModeDwellMinutes          int     `toml:"min_auto_mode_dwell_minutes"`   // 「自動」の最低滞在時間 (分)
+	ModeChangeInhibitMinutes         int     `toml:"mode_change_inhibit_minutes"`
+	ConflictBackoffMinutes           int     `toml:"conflict_backoff_minutes"` // 他のコントローラーが運転モードを変更した場合に自動制御を控える時間 (分)
+	MinSurplusPowerJudgmentMinutes   int     `toml:"min_surplus_power_judgment_minutes"`
+	SurplusPowerMarginWatts          int     `toml:"surplus_power_margin_watts"`
+	MaxChargePowerWatts              int     `toml:"max_charge_power_watts"`
+	PowerCapWatts                    int     `toml:"power_cap_watts"`         // max_charge_power_watts の別名
+	SurplusMarginWatts               int     `toml:"surplus_margin_watts"`    // surplus_power_margin_watts の別名
+	DeviceMaxChargeWatts             int     `toml:"device_max_charge_watts"` // 蓄電池1台に設定できる充電電力設定値の上限 (W)。未設定の場合は EIBS7 の上限値 5430
+	LogMonitoringData                bool    `toml:"log_monitoring_data"`
+	MonitoringCSVDir                 string  `toml:"monitoring_csv_dir"`         // log_monitoring_data が true の場合に、監視データを日ごとの CSV に記録するディレクトリ
+	EnergyCounters                   bool    `toml:"energy_counters"`            // 蓄電池と太陽光発電の積算電力量を取得し、当日の値をログに出力する
+	DryRun                           bool    `toml:"dry_run"`                    // 監視のみ行い、蓄電池の設定 (SetC) を送信しない
+	EnableControl                    *bool   `toml:"enable_control"`             // false の場合は監視・計算・ログ出力のみ行い、SetC を送信しない (dry_run と同じ)。未設定の場合は true
+	ShutdownOperationMode            string  `toml:"shutdown_operation_mode"`    // SIGINT/SIGTERM で終了する前に設定する運転モード ("auto", "standby", "none")
+	LoopStallTimeoutSeconds          int     `toml:"loop_stall_timeout_seconds"` // 監視サイクルがこの時間 (秒) 以上完了しない場合、蓄電池を自動モードに戻す
+	ReceiveBufferSize                int     `toml:"receive_buffer_size"`
+	ResponseTimeoutMilliseconds      int     `toml:"response_timeout_milliseconds"` // 応答の待機時間 (ミリ秒)。未設定の場合は 5000
+	RequestRetries                   int     `toml:"request_retries"`               // 応答がない場合に要求を再送する回数。未設定の場合は 0 (再送しない)
+	RetryBackoffMilliseconds         int     `toml:"retry_backoff_milliseconds"`    // 再送するまでの待機時間 (ミリ秒)。再送のたびに2倍にする。未設定の場合は 500
+	LivenessCheckIntervalSeconds     int     `toml:"liveness_check_interval_seconds"`
+	UnreachableFailureThreshold      int     `toml:"unreachable_failure_threshold"`
+	NotificationDedupWindowSeconds   int     `toml:"notification_dedup_window_seconds"`
+	NotificationMode                 bool    `toml:"notification_mode"`            // 通知 (INF) で受信した値を優先し、ポーリングを減らす
+	NotificationMaxAgeSeconds        int     `toml:"notification_max_age_seconds"` // 通知で受信した値を使用する期間 (秒)
+	SlowPollIntervalSeconds          int     `toml:"slow_poll_interval_seconds"`   // 値がほとんど変化しないプロパティを取得する間隔 (秒)
+	MaxGridChargeKWhPerDay           float64 `toml:"max_grid_charge_kwh_per_day"`  // 0以下の場合は無制限 (max_daily_grid_charge_wh を推奨)
+	MaxDailyGridChargeWh             float64 `toml:"max_daily_grid_charge_wh"`     // 1日あたりに系統から充電する電力量の上限 (Wh)。0以下の場合は無制限
+	GridChargeRolloverHour           int     `toml:"grid_charge_rollover_hour"`    // 系統からの充電電力量の積算をリセットする時刻 (時, 0〜23)
+
+	ChargeTargetSOCPercent int            `toml:"charge_target_soc_percent"` // 充電時間帯の目標の蓄電残量 (%)。未設定の場合は 100
+	ChargePowerRampWatts   int            `toml:"charge_power_ramp_watts"`   // 1回の更新で変更する充電電力の上限 (W)。0 の場合は制限なし
+	ChargePowerGain        float64        `toml:"charge_power_gain"`         // 目標との差分のうち1回の更新で変更する割合 (0〜1)。0 の場合は差分をすべて変更する
+	BatteryInstances       []int          `toml:"battery_instances"`         // 制御する蓄電池のインスタンスコード (例: [1, 2])。未設定の場合は機器のインスタンスリストから決める
+	Targets                []DeviceTarget `toml:"targets"`                   // target_ip の機器に加えて協調制御する EIBS7 の一覧
+	Devices                []DeviceConfig `toml:"devices"`                   // 監視・制御対象の機器と役割。指定した場合は battery_instances と targets の代わりに使用する
+	EPCOverrides           []EPCOverride  `toml:"epc_overrides"`             // 監視対象ごとに取得するプロパティの変更
+	ReserveSOCPercent      int            `toml:"reserve_soc_percent"`       // 停電に備えて放電させない最低の蓄電残量 (%)。0 の場合は無効
+	TargetReachedAction    string         `toml:"target_reached_action"`     // 充電時間帯の途中で目標の蓄電残量に達した場合の動作 ("hold": 充電時間帯の終了まで「充電」を維持, "stop": 直ちに「自動」に切り替え)。未設定の場合は "hold"
+	FullSOCPercent         int            `toml:"full_soc_percent"`          // この蓄電残量 (%) 以上を満充電とみなし、充電電力設定値を更新しない。未設定の場合は 100
+	EmptySOCPercent        int            `toml:"empty_soc_percent"`         // この蓄電残量 (%) 以下を空とみなし、放電の設定を行わない。未設定の場合は 5
+	ChargeTimesWeekend     ChargeWindow   `toml:"charge_times_weekend"`      // 週末 (土日) の充電時間帯。未設定の項目は平日の設定を使用する
+	Holidays               HolidayConfig  `toml:"holidays"`                  // 国民の祝日・休日に適用する充電時間帯
+	Outage                 OutageConfig   `toml:"outage"`                    // 停電 (自立運転) を検出した場合の動作
+	DischargeTimes         TimeSlot       `toml:"discharge_times"`           // 放電を許可する時間帯。設定した場合、充電時間帯以外でこの時間帯の外では待機モードにして放電を止める
+
+	SeasonalChargeCaps []SeasonalChargeCap `toml:"seasonal_charge_caps"` // 期間ごとの最大充電電力と余剰電力の余力
+	ExportMaximization []ExportWindow      `toml:"export_maximization"`  // 蓄電池に充電せず売電を優先する期間と時間帯
+
+	Strategy        string           `toml:"strategy"`         // 制御方式 (charge_schedule, self_consumption, peak_shave, export_priority)。未設定の場合は charge_schedule
+	StrategyPeriods []StrategyPeriod `toml:"strategy_periods"` // 時間帯ごとに使用する制御方式
+
+	Profiles        map[string]Profile `toml:"profiles"`         // 名前を付けた運転プロファイル
+	ProfileSchedule []ProfileRule      `toml:"profile_schedule"` // 運転プロファイルを有効にする曜日・期間・時間帯
+
+	Forecast         ForecastConfig       `toml:"forecast"`
+	EveningReserve   EveningReserveConfig `toml:"evening_reserve"`
+	Capture          CaptureConfig        `toml:"capture"`
+	LogFile          LogFileConfig        `toml:"log_file"`
+	AuditLog         AuditLogConfig       `toml:"audit_log"`
+	History          HistoryConfig        `toml:"history"`
+	Postgres         PostgresConfig       `toml:"postgres"`
+	MQTT             MQTTConfig           `toml:"mqtt"`
+	StormAlert       StormAlertConfig     `toml:"storm_alert"`
+	OpenADR          OpenADRConfig        `toml:"openadr"`
+	ChargePlanning   ChargePlanningConfig `toml:"charge_planning"`
+	PriceSchedule    PriceScheduleConfig  `toml:"price_schedule"`
+	DischargeCap     DischargeCapConfig   `toml:"discharge_cap"`
+	Override         OverrideConfig       `toml:"override"`
+	HTTPAPI          HTTPAPIConfig        `toml:"http_api"`
+	Alerts           AlertsConfig         `toml:"alerts"`
+	EmailReport      EmailReportConfig    `toml:"email_report"`
+	Modbus           ModbusConfig         `toml:"modbus"`
+	ArchiveUpload    ArchiveUploadConfig  `toml:"archive_upload"`
+	GoogleSheets     GoogleSheetsConfig   `toml:"google_sheets"`
+	StateWebhooks    []StateWebhookConfig `toml:"state_webhooks"` // 制御の状態が変わったときに送信する Webhook
+	ImportGuard      ImportGuardConfig    `toml:"import_guard"`
+	PeakShaving      PeakShavingConfig    `toml:"peak_shaving"`
+	EVCharger        EVChargerConfig      `toml:"ev_charger"`
+	SmartMeter       SmartMeterConfig     `toml:"smart_meter"`
+	SurplusDiversion DiversionConfig      `toml:"surplus_diversion"`
+	DemandResponse   DemandResponseConfig `toml:"demand_response"`
+
+	// 以下は Load が設定ファイルの内容から決める項目で、設定ファイルからは読み込まない
+	DiscoverTargets bool              `toml:"-"` // battery_instances が未設定の場合は true。起動時にインスタンスリストから監視対象を決める
+	Instances       []echonetlite.EOJ `toml:"-"` // インスタンスリストから決めた蓄電池以外の監視対象。nil の場合は既定の監視対象
+	Location        *time.Location    `toml:"-"` // timezone から読み込んだタイムゾーン
+	Controller      echonetlite.EOJ   `toml:"-"` // controller_instance または controller_eoj から決めた送信元の ECHONET Lite オブジェクト
+	Clock           controller.Clock  `toml:"-"` // 現在時刻を返す時計。nil の場合はシステムの時計。backtest やテストでは模擬した時刻を返す
+}
+
+// ControlEnabled は、enable_control が false に設定されていなければ true を返します。
+func (c *Config) ControlEnabled() bool {
+	return c.EnableControl == nil || *c.EnableControl
+}
+
+// Load は設定ファイルを読み込み、Config構造体を返します。
+func Load(filePath string) (*Config, error) {
+	var config Config
+
+	// ファイルの内容を読み込む
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("設定ファイル '%s' の読み込みに失敗しました: %w", filePath, err)
+	}
+
+	// TOMLデータを構造体にデコードする (拡張子が .yaml, .yml, .json の場合は YAML, JSON として読み込む)
+	if err := decodeConfigData(filePath, data, &config); err != nil {
+		return nil, fmt.Errorf("設定ファイル '%s' の解析に失敗しました: %w", filePath, err)
+	}
+
+	// 設定の誤りは最初の誤りで止めずに集め、最後にまとめて返す
+	var v configValidator
+
+	// 必須項目のチェック (例: TargetIP)
+	// target_id を指定した場合は、target_ip が空でも起動時の探索でアドレスを解決する
+	if config.TargetID == "" {
+		v.check(config.TargetIP != "", "'target_ip' または 'target_id' が設定されていないか、空です")
+	} else {
+		_, err := hex.DecodeString(NormalizeNodeID(config.TargetID))
+		v.check(err == nil, "'target_id' が16進数の文字列ではありません ('%s')", config.TargetID)
+	}
+
+	// 送信元のコントローラーオブジェクト (同じ LAN で複数のコントローラーを動かす場合に重複しないよう変更する)
+	config.Controller = DefaultControllerEOJ
+	switch {
+	case config.ControllerEOJ != "":
+		v.check(config.ControllerInstance == 0, "'controller_eoj' と 'controller_instance' は同時に指定できません")
+		eoj, err := ParseEOJ(config.ControllerEOJ)
+		if err == nil && (eoj.InstanceCode == 0 || eoj.InstanceCode > 0x7F) {
+			err = fmt.Errorf("インスタンスコード (0x%02X) は 01 から 7F の範囲である必要があります", eoj.InstanceCode)
+		}
+		v.field("controller_eoj", err)
+		config.Controller = eoj
+	case config.ControllerInstance != 0:
+		v.rangeInt("controller_instance", config.ControllerInstance, 1, 0x7F)
+		config.Controller.InstanceCode = byte(config.ControllerInstance)
+	}
+
+	// タイムゾーンは起動時に1回だけ読み込み、以降の判定はすべて同じ *time.Location で行う
+	config.Location = time.Local
+	if config.Timezone != "" {
+		loc, err := time.LoadLocation(config.Timezone)
+		v.field("timezone", err)
+		if err == nil {
+			config.Location = loc
+		}
+	}
+
+	// MonitorIntervalSeconds のデフォルト値設定
+	if config.MonitorIntervalSeconds <= 0 {
+		log.Printf("設定ファイル '%s' の 'monitor_interval_seconds' が未設定または0以下です。デフォルト値10秒を使用します。", filePath)
+		config.MonitorIntervalSeconds = 10
+	}
+
+	// ChargePowerUpdateIntervalMinutes のデフォルト値設定
+	if config.ChargePowerUpdateIntervalMinutes <= 0 {
+		log.Printf("設定ファイル '%s' の 'charge_power_update_interval_minutes' が未設定または0以下です。デフォルト値10分を使用します。", filePath)
+		config.ChargePowerUpdateIntervalMinutes = 10
+	}
+
+	// ModeChangeInhibitMinutes のデフォルト値設定
+	if config.ModeChangeInhibitMinutes <= 0 {
+		log.Printf("設定ファイル '%s' の 'mode_change_inhibit_minutes' が未設定または0以下です。デフォルト値5分を使用します。", filePath)
+		config.ModeChangeInhibitMinutes = 5
+	}
+
+	// ConflictBackoffMinutes のデフォルト値設定
+	if config.ConflictBackoffMinutes <= 0 {
+		config.ConflictBackoffMinutes = 30
+	}
+
+	// MinSurplusPowerJudgmentMinutes のデフォルト値設定
+	if config.MinSurplusPowerJudgmentMinutes <= 0 {
+		log.Printf("設定ファイル '%s' の 'min_surplus_power_judgment_minutes' が未設定または0以下です。デフォルト値5分を使用します。", filePath)
+		config.MinSurplusPowerJudgmentMinutes = 5
+	}
+
+	// 充電電力の上限の計算に使用する値は別名でも設定できる
+	if config.PowerCapWatts > 0 {
+		v.check(config.MaxChargePowerWatts <= 0, "'max_charge_power_watts' と 'power_cap_watts' の両方が設定されています")
+		config.MaxChargePowerWatts = config.PowerCapWatts
+	}
+	if config.SurplusMarginWatts > 0 {
+		v.check(config.SurplusPowerMarginWatts <= 0, "'surplus_power_margin_watts' と 'surplus_margin_watts' の両方が設定されています")
+		config.SurplusPowerMarginWatts = config.SurplusMarginWatts
+	}
+
+	// SurplusPowerMarginWatts のデフォルト値設定
+	if config.SurplusPowerMarginWatts <= 0 {
+		log.Printf("設定ファイル '%s' の 'surplus_power_margin_watts' が未設定または0以下です。デフォルト値500Wを使用します。", filePath)
+		config.SurplusPowerMarginWatts = 500
+	}
+
+	// MaxChargePowerWatts のデフォルト値設定
+	if config.MaxChargePowerWatts <= 0 {
+		log.Printf("設定ファイル '%s' の 'max_charge_power_watts' が未設定または0以下です。デフォルト値3000Wを使用します。", filePath)
+		config.MaxChargePowerWatts = 3000
+	}
+
+	// DeviceMaxChargeWatts のデフォルト値設定と検証
+	if config.DeviceMaxChargeWatts <= 0 {
+		config.DeviceMaxChargeWatts = defaultDeviceMaxChargeWatts
+	}
+	v.rangeInt("device_max_charge_watts", config.DeviceMaxChargeWatts, 100, 100000)
+	v.check(config.SurplusPowerMarginWatts < config.MaxChargePowerWatts, "'surplus_power_margin_watts' (%d) は 'max_charge_power_watts' (%d) 未満である必要があります", config.SurplusPowerMarginWatts, config.MaxChargePowerWatts)
+
+	// LivenessCheckIntervalSeconds のデフォルト値設定
+	if config.LivenessCheckIntervalSeconds <= 0 {
+		config.LivenessCheckIntervalSeconds = 60
+	}
+
+	// UnreachableFailureThreshold のデフォルト値設定
+	if config.UnreachableFailureThreshold <= 0 {
+		config.UnreachableFailureThreshold = 3
+	}
+
+	// NotificationDedupWindowSeconds のデフォルト値設定
+	if config.NotificationDedupWindowSeconds <= 0 {
+		config.NotificationDedupWindowSeconds = int(DefaultNotificationDedupWindow / time.Second)
+	}
+
+	// NotificationMaxAgeSeconds, SlowPollIntervalSeconds のデフォルト値設定
+	if config.NotificationMaxAgeSeconds <= 0 {
+		config.NotificationMaxAgeSeconds = 2 * config.MonitorIntervalSeconds
+	}
+	if config.SlowPollIntervalSeconds <= 0 {
+		config.SlowPollIntervalSeconds = 300
+	}
+
+	// ReceiveBufferSize のデフォルト値設定
+	if config.ReceiveBufferSize <= 0 {
+		config.ReceiveBufferSize = DefaultReceiveBufferSize
+	}
+	v.minInt("receive_buffer_size", config.ReceiveBufferSize, minFrameLength) // ECHONET Lite フレームの最小長
+
+	// 応答の待機時間と再送の設定のデフォルト値設定と検証
+	if config.ResponseTimeoutMilliseconds <= 0 {
+		config.ResponseTimeoutMilliseconds = int(DefaultResponseTimeout / time.Millisecond)
+	}
+	if config.RetryBackoffMilliseconds <= 0 {
+		config.RetryBackoffMilliseconds = int(defaultRetryBackoff / time.Millisecond)
+	}
+	v.rangeInt("response_timeout_milliseconds", config.ResponseTimeoutMilliseconds, 100, 60000)
+	v.rangeInt("request_retries", config.RequestRetries, 0, 10)
+	v.rangeInt("retry_backoff_milliseconds", config.RetryBackoffMilliseconds, 1, 60000)
+
+	// Forecast のデフォルト値設定と検証
+	v.add(config.Forecast.Validate())
+
+	// Capture のデフォルト値設定
+	if config.Capture.File == "" {
+		config.Capture.File = "eibs7-capture.pcapng"
+	}
+
+	// LogFile のデフォルト値設定と検証
+	v.add(config.LogFile.Validate())
+
+	// AuditLog のデフォルト値設定
+	if config.AuditLog.File == "" {
+		config.AuditLog.File = "eibs7-decisions.jsonl"
+	}
+
+	// History のデフォルト値設定と検証
+	v.add(config.History.Validate())
+
+	// Postgres のデフォルト値設定と検証
+	v.add(config.Postgres.validate())
+
+	// MQTT のデフォルト値設定と検証
+	v.add(config.MQTT.validate())
+
+	// HTTPAPI のデフォルト値設定と検証
+	v.add(config.HTTPAPI.validate())
+
+	// Alerts のデフォルト値設定と検証
+	v.add(config.Alerts.Validate())
+
+	// EmailReport のデフォルト値設定と検証
+	v.add(config.EmailReport.validate())
+	v.check(!config.EmailReport.Enabled || config.History.Enabled, "'email_report.enabled' が true の場合は [history] を有効にする必要があります")
+
+	// Modbus のデフォルト値設定と検証
+	v.add(config.Modbus.validate())
+
+	// ArchiveUpload のデフォルト値設定と検証
+	v.add(config.ArchiveUpload.Validate())
+	v.check(!config.ArchiveUpload.Enabled || (config.LogMonitoringData && config.MonitoringCSVDir != ""), "'archive_upload.enabled' が true の場合は 'log_monitoring_data' と 'monitoring_csv_dir' の設定が必要です")
+
+	// GoogleSheets のデフォルト値設定と検証
+	v.add(config.GoogleSheets.Validate())
+	v.check(!config.GoogleSheets.Enabled || config.History.Enabled, "'google_sheets.enabled' が true の場合は [history] を有効にする必要があります")
+
+	// StateWebhooks のデフォルト値設定と検証
+	for i := range config.StateWebhooks {
+		v.item("state_webhooks", i, config.StateWebhooks[i].Validate())
+	}
+
+	// EnableControl = false は制御を行わない監視専用の運転で、SetC を送信しない点は dry_run と同じ
+	if config.EnableControl != nil {
+		v.check(!(*config.EnableControl && config.DryRun), "'enable_control' = true と 'dry_run' = true は同時に指定できません")
+		if !*config.EnableControl {
+			config.DryRun = true
+		}
+	}
+
+	// ShutdownOperationMode のデフォルト値設定と検証
+	if config.ShutdownOperationMode == "" {
+		config.ShutdownOperationMode = "auto"
+	}
+	v.add(ValidateShutdownOperationMode(config.ShutdownOperationMode))
+
+	// LoopStallTimeoutSeconds のデフォルト値設定と検証
+	if config.LoopStallTimeoutSeconds <= 0 {
+		config.LoopStallTimeoutSeconds = 3 * config.MonitorIntervalSeconds
+	}
+	v.check(config.LoopStallTimeoutSeconds > config.MonitorIntervalSeconds, "'loop_stall_timeout_seconds' (%d) は 'monitor_interval_seconds' (%d) より大きい必要があります", config.LoopStallTimeoutSeconds, config.MonitorIntervalSeconds)
+	// 1つの要求の再送を含めた最大の待機時間が、監視ループの停止と判定する時間を超えないようにする
+	if worst := config.requestWorstCase(); worst > 0 {
+		v.check(worst < time.Duration(config.LoopStallTimeoutSeconds)*time.Second, "1つの要求の最大待機時間 (%s: 'response_timeout_milliseconds', 'request_retries', 'retry_backoff_milliseconds' から計算) は 'loop_stall_timeout_seconds' (%d 秒) より短い必要があります", worst, config.LoopStallTimeoutSeconds)
+	}
+
+	// MaxDailyGridChargeWh の設定 (以前の max_grid_charge_kwh_per_day も引き続き使用できる)
+	if config.MaxGridChargeKWhPerDay > 0 {
+		v.check(config.MaxDailyGridChargeWh <= 0, "'max_daily_grid_charge_wh' と 'max_grid_charge_kwh_per_day' の両方が設定されています")
+		config.MaxDailyGridChargeWh = config.MaxGridChargeKWhPerDay * 1000
+	}
+	v.rangeInt("grid_charge_rollover_hour", config.GridChargeRolloverHour, 0, 23)
+
+	// Override のデフォルト値設定
+	if config.Override.Socket == "" {
+		config.Override.Socket = "/tmp/eibs7-controller.sock"
+	}
+
+	// ChargeTargetSOCPercent のデフォルト値設定
+	if config.ChargeTargetSOCPercent <= 0 {
+		config.ChargeTargetSOCPercent = 100
+	}
+
+	// ChargeModeThresholdWatts のデフォルト値設定と検証 (未設定の場合はヒステリシスなし)
+	if config.ChargeModeThresholdWatts <= 0 {
+		config.ChargeModeThresholdWatts = config.AutoModeThresholdWatts
+	} else {
+		v.check(config.ChargeModeThresholdWatts >= config.AutoModeThresholdWatts, "'charge_mode_threshold_watts' (%d) は 'auto_mode_threshold_watts' (%d) 以上である必要があります", config.ChargeModeThresholdWatts, config.AutoModeThresholdWatts)
+	}
+	v.minInt("min_charge_mode_dwell_minutes", config.MinChargeModeDwellMinutes, 0)
+	v.minInt("min_auto_mode_dwell_minutes", config.MinAutoModeDwellMinutes, 0)
+
+	// SurplusSmoothingSamples, SurplusSmoothingAlpha の検証
+	v.minInt("surplus_smoothing_samples", config.SurplusSmoothingSamples, 0)
+	v.check(config.SurplusSmoothingAlpha >= 0 && config.SurplusSmoothingAlpha < 1, "'surplus_smoothing_alpha' (%.2f) は 0 以上 1 未満である必要があります", config.SurplusSmoothingAlpha)
+
+	// ChargePowerRampWatts, ChargePowerGain の検証
+	v.minInt("charge_power_ramp_watts", config.ChargePowerRampWatts, 0)
+	v.rangeFloat("charge_power_gain", config.ChargePowerGain, 0, 1)
+
+	// Devices の検証 (battery_instances, targets とは同時に指定できない)
+	if len(config.Devices) > 0 {
+		v.check(len(config.BatteryInstances) == 0 && len(config.Targets) == 0, "'devices' は 'battery_instances' や 'targets' と同時に指定できません")
+		v.field("devices", ValidateDevices(config.Devices, config.TargetIP))
+	}
+
+	// BatteryInstances のデフォルト値設定と検証
+	if len(config.BatteryInstances) == 0 {
+		config.DiscoverTargets = len(config.Devices) == 0
+		config.BatteryInstances = []int{1}
+	}
+	v.field("battery_instances", ValidateBatteryInstances(config.BatteryInstances))
+
+	// Targets のデフォルト値設定と検証
+	v.field("targets", validateTargets(config.Targets, config.TargetIP))
+
+	// EPCOverrides のデフォルト値設定と検証
+	for i := range config.EPCOverrides {
+		v.item("epc_overrides", i, config.EPCOverrides[i].Validate(config.TargetIP))
+	}
+
+	// TargetReachedAction のデフォルト値設定と検証
+	if config.TargetReachedAction == "" {
+		config.TargetReachedAction = targetReachedHold
+	}
+	v.oneOf("target_reached_action", config.TargetReachedAction, targetReachedHold, TargetReachedStop)
+
+	// FullSOCPercent, EmptySOCPercent のデフォルト値設定と検証
+	if config.FullSOCPercent <= 0 {
+		config.FullSOCPercent = 100
+	}
+	if config.EmptySOCPercent <= 0 {
+		config.EmptySOCPercent = 5
+	}
+	v.check(config.EmptySOCPercent < config.FullSOCPercent, "'empty_soc_percent' (%d) は 'full_soc_percent' (%d) 未満である必要があります", config.EmptySOCPercent, config.FullSOCPercent)
+
+	// 蓄電残量 (%) の設定値の検証
+	v.rangeInt("charge_target_soc_percent", config.ChargeTargetSOCPercent, 0, 100)
+	v.rangeInt("charge_times_weekend.target_soc_percent", config.ChargeTimesWeekend.TargetSOCPercent, 0, 100)
+	v.rangeInt("reserve_soc_percent", config.ReserveSOCPercent, 0, 100)
+	v.rangeInt("full_soc_percent", config.FullSOCPercent, 0, 100)
+
+	// ChargeCron の検証
+	if config.ChargeCron != "" {
+		_, err := parseCron(config.ChargeCron)
+		v.field("charge_cron", err)
+	}
+
+	// ChargeTimesWeekend の検証 (未設定の項目は平日の設定を使用するため、設定された項目のみ確認する)
+	v.field("charge_times_weekend", config.ChargeTimesWeekend.TimeSlot.validatePartial())
+
+	// DischargeTimes の検証
+	if config.DischargeTimes != (TimeSlot{}) {
+		v.field("discharge_times", config.DischargeTimes.Validate())
+	}
+
+	// SeasonalChargeCaps の検証
+	for i, s := range config.SeasonalChargeCaps {
+		v.item("seasonal_charge_caps", i, s.validate())
+	}
+
+	// ExportMaximization の検証
+	for i, w := range config.ExportMaximization {
+		v.item("export_maximization", i, w.validate())
+	}
+
+	// Strategy のデフォルト値設定と検証
+	if config.Strategy == "" {
+		config.Strategy = StrategyChargeSchedule
+	}
+	v.field("strategy", validateStrategy(config.Strategy))
+	for i, p := range config.StrategyPeriods {
+		v.item("strategy_periods", i, p.validate())
+	}
+
+	// Profiles と ProfileSchedule の検証
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names) // 誤りを毎回同じ順に出力する
+	for _, name := range names {
+		v.field("profiles."+name, config.Profiles[name].validate())
+	}
+	for i, r := range config.ProfileSchedule {
+		v.item("profile_schedule", i, r.validate(config.Profiles))
+	}
+
+	// PeakShaving のデフォルト値設定と検証
+	v.add(config.PeakShaving.Validate())
+
+	// EVCharger のデフォルト値設定と検証
+	v.add(config.EVCharger.validate())
+
+	// SmartMeter のデフォルト値設定と検証
+	v.add(config.SmartMeter.validate())
+
+	// SurplusDiversion のデフォルト値設定と検証
+	v.add(config.SurplusDiversion.validate())
+
+	// DemandResponse のデフォルト値設定と検証
+	v.add(config.DemandResponse.validate(config.ImportGuard))
+
+	// ImportGuard のデフォルト値設定と検証
+	v.add(config.ImportGuard.Validate())
+
+	// StormAlert の検証
+	v.add(config.StormAlert.Validate())
+	// OpenADR のデフォルト値設定と検証
+	v.add(config.OpenADR.Validate())
+
+	// ChargePlanning の検証
+	v.add(config.ChargePlanning.Validate(config.Forecast))
+
+	// Outage のデフォルト値設定と検証
+	v.add(config.Outage.validate())
+
+	// Holidays の検証
+	v.add(config.Holidays.validate())
+
+	// PriceSchedule の検証
+	v.add(config.PriceSchedule.Validate())
+
+	// DischargeCap の検証
+	v.add(config.DischargeCap.Validate())
+
+	// EveningReserve の検証
+	v.add(config.EveningReserve.Validate(config.Forecast))
+
+	if err := v.err(); err != nil {
+		return nil, fmt.Errorf("設定ファイル '%s' の検証に失敗しました:\n%w", filePath, err)
+	}
+	return &config, nil
+}
+
+// IsChargingTime は、現在時刻が設定された充電時間帯内にあるかどうかを判定します。
+func IsChargingTime(now time.Time, startTimeStr, endTimeStr string) (bool, error) {
+	const timeFormat = "15:04"
+
+	// 時刻部分のみを抽出
+	currentTime, err := time.Parse(timeFormat, now.Format(timeFormat))
+	if err != nil {
+		return false, fmt.Errorf("現在時刻の解析に失敗しました: %w", err)
+	}
+
+	startTime, err := time.Parse(timeFormat, startTimeStr)
+	if err != nil {
+		return false, fmt.Errorf("開始時刻の解析に失敗しました ('%s'): %w", startTimeStr, err)
+	}
+
+	endTime, err := time.Parse(timeFormat, endTimeStr)
+	if err != nil {
+		return false, fmt.Errorf("終了時刻の解析に失敗しました ('%s'): %w", endTimeStr, err)
+	}
+
+	// 終了時刻が開始時刻より前の場合は、日付をまたぐ設定と判断
+	if endTime.Before(startTime) {
+		// 例: 23:00 - 02:00 の場合
+		// (現在時刻 >= 開始時刻) OR (現在時刻 < 終了時刻)
+		return !currentTime.Before(startTime) || currentTime.Before(endTime), nil
+	} else {
+		// 例: 09:00 - 15:00 の場合
+		// (現在時刻 >= 開始時刻) AND (現在時刻 < 終了時刻)
+		return !currentTime.Before(startTime) && currentTime.Before(endTime), nil
+	}
+}
+
+// 同一内容の通知を重複とみなす期間のデフォルト値
+const DefaultNotificationDedupWindow = 5 * time.Second
+
+// 応答の待機時間と再送までの待機時間のデフォルト値
+const (
+	DefaultResponseTimeout = 5 * time.Second
+	defaultRetryBackoff    = 500 * time.Millisecond
+)
+
+// requestWorstCase は、応答のない機器への1つの要求が、再送を含めて応答を待機する最大の時間を返します。
+func (c *Config) requestWorstCase() time.Duration {
+	timeout := time.Duration(c.ResponseTimeoutMilliseconds) * time.Millisecond
+	backoff := time.Duration(c.RetryBackoffMilliseconds) * time.Millisecond
+	total := timeout
+	for i := 0; i < c.RequestRetries; i++ {
+		total += backoff + timeout
+		backoff *= 2
+	}
+	return total
+}
+
+// NormalizeNodeID は、設定ファイルに記述された識別番号を比較用の形式 (区切り文字なしの大文字16進) に変換します。
+func NormalizeNodeID(id string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", ":", "", "-", "").Replace(id))
+}
+
+// ControllerConfig は、制御の状態機械の設定を返します。
+func (c *Config) ControllerConfig() controller.Config {
+	return controller.Config{
+		ChargeModeThresholdWatts: c.ChargeModeThresholdWatts,
+		AutoModeThresholdWatts:   c.AutoModeThresholdWatts,
+		MinChargeModeDwell:       time.Duration(c.MinChargeModeDwellMinutes) * time.Minute,
+		MinAutoModeDwell:         time.Duration(c.MinAutoModeDwellMinutes) * time.Minute,
+		ModeChangeInhibit:        time.Duration(c.ModeChangeInhibitMinutes) * time.Minute,
+	}
+}
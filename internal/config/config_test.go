@@ -0,0 +1,172 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigDefaultsAndValidation(t *testing.T) {
+	// create temporary config with minimal required field
+	tmp, err := os.CreateTemp("", "config_*.toml")
+	if err != nil {
+		t.Fatalf("temp file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	content := []byte(`target_ip = "192.168.0.10"`)
+	if _, err := tmp.Write(content); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	tmp.Close()
+
+	cfg, err := Load(tmp.Name())
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if cfg.TargetIP != "192.168.0.10" {
+		t.Errorf("unexpected TargetIP: %s", cfg.TargetIP)
+	}
+	// defaults applied
+	if cfg.MonitorIntervalSeconds != 10 {
+		t.Errorf("default MonitorIntervalSeconds not set, got %d", cfg.MonitorIntervalSeconds)
+	}
+	if cfg.ChargePowerUpdateIntervalMinutes != 10 {
+		t.Errorf("default ChargePowerUpdateIntervalMinutes not set, got %d", cfg.ChargePowerUpdateIntervalMinutes)
+	}
+	if cfg.ModeChangeInhibitMinutes != 5 {
+		t.Errorf("default ModeChangeInhibitMinutes not set, got %d", cfg.ModeChangeInhibitMinutes)
+	}
+}
+
+func TestLoadConfigMissingTargetIP(t *testing.T) {
+	tmp, _ := os.CreateTemp("", "bad_*.toml")
+	defer os.Remove(tmp.Name())
+	tmp.Write([]byte(`monitor_interval_seconds = 5`))
+	tmp.Close()
+	_, err := Load(tmp.Name())
+	if err == nil {
+		t.Fatalf("expected error for missing target_ip")
+	}
+}
+
+func TestIsChargingTime(t *testing.T) {
+	// Helper to create a time at given hour:minute on arbitrary date
+	makeNow := func(h, m int) time.Time {
+		t0, _ := time.Parse("2006-01-02 15:04", fmt.Sprintf("2025-01-01 %02d:%02d", h, m))
+		return t0
+	}
+	// simple same-day interval where now is mocked via system time – we cannot change time.Now easily, so test logic with known times.
+	// We'll test the parsing and boundary logic using fixed strings that include wrap-around.
+	// For non-wrapping case
+	now := makeNow(12, 0)
+	ok, err := IsChargingTime(now, "09:00", "15:00")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ok {
+		t.Fatalf("expected true for non-wrapping interval, got false")
+	}
+	// Wrapping interval where now may be outside; we just ensure no error and boolean returned.
+	now2 := makeNow(3, 0)
+	ok2, err2 := IsChargingTime(now2, "23:00", "02:00")
+	if err2 != nil {
+		t.Fatalf("wrap interval parse error: %v", err2)
+	}
+	if ok2 {
+		t.Fatalf("expected false for outside wrap interval, got true")
+	}
+
+}
+
+func TestLoadConfigReceiveBufferSize(t *testing.T) {
+	write := func(content string) string {
+		tmp, err := os.CreateTemp("", "config_*.toml")
+		if err != nil {
+			t.Fatalf("temp file: %v", err)
+		}
+		tmp.Write([]byte(content))
+		tmp.Close()
+		t.Cleanup(func() { os.Remove(tmp.Name()) })
+		return tmp.Name()
+	}
+
+	cfg, err := Load(write(`target_ip = "192.168.0.10"`))
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if cfg.ReceiveBufferSize != 1024 {
+		t.Errorf("default ReceiveBufferSize not set, got %d", cfg.ReceiveBufferSize)
+	}
+
+	cfg, err = Load(write("target_ip = \"192.168.0.10\"\nreceive_buffer_size = 4096"))
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if cfg.ReceiveBufferSize != 4096 {
+		t.Errorf("ReceiveBufferSize not applied, got %d", cfg.ReceiveBufferSize)
+	}
+
+	if _, err := Load(write("target_ip = \"192.168.0.10\"\nreceive_buffer_size = 8")); err == nil {
+		t.Errorf("expected error for receive_buffer_size smaller than a minimal frame")
+	}
+}
+
+func TestLoadConfigTargetIDWithoutIP(t *testing.T) {
+	tmp, _ := os.CreateTemp("", "config_*.toml")
+	defer os.Remove(tmp.Name())
+	tmp.Write([]byte(`target_id = "FE:00:00:08:01"`))
+	tmp.Close()
+	cfg, err := Load(tmp.Name())
+	if err != nil {
+		t.Fatalf("target_id alone should be accepted: %v", err)
+	}
+	if cfg.TargetIP != "" {
+		t.Errorf("unexpected TargetIP: %s", cfg.TargetIP)
+	}
+
+	bad, _ := os.CreateTemp("", "config_*.toml")
+	defer os.Remove(bad.Name())
+	bad.Write([]byte(`target_id = "not-hex"`))
+	bad.Close()
+	if _, err := Load(bad.Name()); err == nil {
+		t.Errorf("expected error for non-hex target_id")
+	}
+}
+
+func TestLoadConfigModeThresholds(t *testing.T) {
+	tmp, _ := os.CreateTemp("", "config_*.toml")
+	defer os.Remove(tmp.Name())
+	tmp.Write([]byte("target_ip = \"127.0.0.1\"\nauto_mode_threshold_watts = 500\n"))
+	tmp.Close()
+	cfg, err := Load(tmp.Name())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.ChargeModeThresholdWatts != 500 {
+		t.Errorf("ChargeModeThresholdWatts should default to the auto threshold, got %d", cfg.ChargeModeThresholdWatts)
+	}
+
+	bad, _ := os.CreateTemp("", "config_*.toml")
+	defer os.Remove(bad.Name())
+	bad.Write([]byte("target_ip = \"127.0.0.1\"\nauto_mode_threshold_watts = 500\ncharge_mode_threshold_watts = 300\n"))
+	bad.Close()
+	if _, err := Load(bad.Name()); err == nil {
+		t.Errorf("expected error when the charge threshold is below the auto threshold")
+	}
+}
+
+func TestRequestWorstCase(t *testing.T) {
+	cfg := &Config{ResponseTimeoutMilliseconds: 2000, RequestRetries: 2, RetryBackoffMilliseconds: 500}
+	// 2 s + (0.5 s + 2 s) + (1 s + 2 s)
+	if got := cfg.requestWorstCase(); got != 7500*time.Millisecond {
+		t.Errorf("requestWorstCase = %s, want 7.5s", got)
+	}
+}
+
+func TestNormalizeNodeID(t *testing.T) {
+	got := NormalizeNodeID("fe:00:00:08 0a-0b")
+	if got != "FE0000080A0B" {
+		t.Errorf("NormalizeNodeID = %q", got)
+	}
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// decodeConfigData は、設定ファイルの内容を拡張子に応じて解析し、config に格納します。
+// YAML (.yaml, .yml) と JSON (.json) は TOML に変換してから解析するため、設定項目の名前と検証は TOML と共通です。
+// それ以外の拡張子は TOML として解析します。
+// include で他の設定ファイルを指定した場合は、それらを順に読み込み、後のファイルとこのファイルの値で上書きします。
+func decodeConfigData(filePath string, data []byte, config *Config) error {
+	doc, err := parseConfigDocument(filePath, data)
+	if err != nil {
+		return err
+	}
+	if _, ok := doc["include"]; !ok && !isYAMLOrJSON(filePath) {
+		return toml.Unmarshal(data, config) // 型の誤りを行番号付きで報告するため、TOML はそのまま解析する
+	}
+
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return err
+	}
+	if doc, err = resolveConfigIncludes(filePath, doc, map[string]bool{absPath: true}); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(doc); err != nil {
+		return fmt.Errorf("TOML への変換に失敗しました: %w", err)
+	}
+	return toml.Unmarshal(buf.Bytes(), config)
+}
+
+// isYAMLOrJSON は、拡張子から設定ファイルが YAML または JSON であれば true を返します。
+func isYAMLOrJSON(filePath string) bool {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// parseConfigDocument は、設定ファイルの内容を拡張子に応じて解析し、TOML に変換できる値の map として返します。
+func parseConfigDocument(filePath string, data []byte) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("YAML の解析に失敗しました: %w", err)
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber() // 整数の設定値を float64 にしない
+		if err := dec.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("JSON の解析に失敗しました: %w", err)
+		}
+	default:
+		if err := toml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		return doc, nil
+	}
+	if doc == nil {
+		return map[string]interface{}{}, nil // 空の YAML
+	}
+	return normalizeConfigValue(doc).(map[string]interface{}), nil
+}
+
+// resolveConfigIncludes は、doc の include で指定された設定ファイルを順に読み込んで統合し、最後に doc の値で上書きしたものを返します。
+// include の相対パスは、指定したファイルのディレクトリを基準にします。読み込んだファイルの include も同様に処理し、
+// 循環している場合はエラーを返します。visiting は読み込み中のファイルの絶対パスです。
+func resolveConfigIncludes(filePath string, doc map[string]interface{}, visiting map[string]bool) (map[string]interface{}, error) {
+	var includes []string
+	switch v := doc["include"].(type) {
+	case nil:
+	case string:
+		includes = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("'include' はファイル名の文字列の配列である必要があります")
+			}
+			includes = append(includes, s)
+		}
+	default:
+		return nil, fmt.Errorf("'include' はファイル名の文字列の配列である必要があります")
+	}
+	delete(doc, "include")
+
+	merged := make(map[string]interface{})
+	for _, include := range includes {
+		path := include
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(filePath), path)
+		}
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		if visiting[absPath] {
+			return nil, fmt.Errorf("'include' の '%s' は読み込み中のファイルを含むため、循環しています", include)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("'include' の設定ファイル '%s' の読み込みに失敗しました: %w", path, err)
+		}
+		included, err := parseConfigDocument(path, data)
+		if err != nil {
+			return nil, fmt.Errorf("'include' の設定ファイル '%s' の解析に失敗しました: %w", path, err)
+		}
+		visiting[absPath] = true
+		included, err = resolveConfigIncludes(path, included, visiting)
+		delete(visiting, absPath)
+		if err != nil {
+			return nil, err
+		}
+		mergeConfigDocument(merged, included)
+	}
+	mergeConfigDocument(merged, doc)
+	return merged, nil
+}
+
+// mergeConfigDocument は、src の値で dst を上書きします。
+// テーブルは項目ごとに統合し、それ以外の値 (配列とテーブルの配列を含む) は src の値で置き換えます。
+func mergeConfigDocument(dst, src map[string]interface{}) {
+	for key, value := range src {
+		if table, ok := value.(map[string]interface{}); ok {
+			if base, ok := dst[key].(map[string]interface{}); ok {
+				mergeConfigDocument(base, table)
+				continue
+			}
+			copied := make(map[string]interface{}, len(table))
+			mergeConfigDocument(copied, table)
+			value = copied
+		}
+		dst[key] = value
+	}
+}
+
+// normalizeConfigValue は、YAML と JSON から解析した値を TOML に変換できる値にします。
+// JSON の数値は整数であれば int64 に、それ以外は float64 にし、null は設定されていないものとして取り除きます。
+func normalizeConfigValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if value != nil {
+				m[key] = normalizeConfigValue(value)
+			}
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, 0, len(v))
+		for _, value := range v {
+			if value != nil {
+				s = append(s, normalizeConfigValue(value))
+			}
+		}
+		return s
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadConfigYAMLAndJSON(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.toml": `
+target_ip = "192.168.0.10"
+charge_start_time = "09:00"
+surplus_smoothing_alpha = 0.5
+battery_instances = [1, 2]
+
+[[seasonal_charge_caps]]
+start = "07-01"
+end = "08-31"
+max_charge_power_watts = 1500
+
+[smart_meter]
+enabled = true
+`,
+		"config.yaml": `
+target_ip: 192.168.0.10
+charge_start_time: "09:00"
+surplus_smoothing_alpha: 0.5
+battery_instances: [1, 2]
+target_id: null
+seasonal_charge_caps:
+  - start: "07-01"
+    end: "08-31"
+    max_charge_power_watts: 1500
+smart_meter:
+  enabled: true
+`,
+		"config.json": `{
+  "target_ip": "192.168.0.10",
+  "charge_start_time": "09:00",
+  "surplus_smoothing_alpha": 0.5,
+  "battery_instances": [1, 2],
+  "seasonal_charge_caps": [{"start": "07-01", "end": "08-31", "max_charge_power_watts": 1500}],
+  "smart_meter": {"enabled": true}
+}`,
+	}
+	configs := make(map[string]*Config)
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load(%s) failed: %v", name, err)
+		}
+		configs[name] = cfg
+	}
+
+	want := configs["config.toml"]
+	for _, name := range []string{"config.yaml", "config.json"} {
+		if !reflect.DeepEqual(configs[name], want) {
+			t.Errorf("%s = %+v, want %+v", name, configs[name], want)
+		}
+	}
+}
+
+func TestLoadConfigJSONValidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"target_ip": "192.168.0.10", "grid_charge_rollover_hour": 24}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Load succeeded with grid_charge_rollover_hour = 24, want error")
+	}
+}
+
+func TestLoadConfigInclude(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"common/base.toml": `
+target_ip = "192.168.0.10"
+charge_start_time = "09:00"
+charge_end_time = "15:00"
+battery_instances = [1, 2]
+
+[smart_meter]
+enabled = true
+ip = "192.168.0.20"
+`,
+		"common/tariff.yaml": `
+include: base.toml
+charge_end_time: "16:00"
+`,
+		"site.toml": `
+include = ["common/tariff.yaml"]
+target_ip = "192.168.1.10"
+battery_instances = [1]
+
+[smart_meter]
+ip = "192.168.1.20"
+`,
+		"loop_a.toml": `include = ["loop_b.toml"]`,
+		"loop_b.toml": `include = ["loop_a.toml"]`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := Load(filepath.Join(dir, "site.toml"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	// Later files override earlier ones; tables are merged key by key and arrays are replaced.
+	if cfg.TargetIP != "192.168.1.10" || cfg.ChargeStartTime != "09:00" || cfg.ChargeEndTime != "16:00" {
+		t.Errorf("target_ip = %q, charge times = %s-%s", cfg.TargetIP, cfg.ChargeStartTime, cfg.ChargeEndTime)
+	}
+	if !reflect.DeepEqual(cfg.BatteryInstances, []int{1}) {
+		t.Errorf("battery_instances = %v, want [1]", cfg.BatteryInstances)
+	}
+	if !cfg.SmartMeter.Enabled || cfg.SmartMeter.IP != "192.168.1.20" {
+		t.Errorf("smart_meter = %+v", cfg.SmartMeter)
+	}
+
+	if _, err := Load(filepath.Join(dir, "loop_a.toml")); err == nil {
+		t.Error("Load succeeded with circular includes, want error")
+	}
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule は、cron 形式の式 (分 時 日 月 曜日) を解析したものです。
+// 時間帯として使用する場合は、式に一致する各1分間を時間帯に含めます (例: "* 9-14 * * mon-fri" は平日の 9:00〜15:00)。
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64 // 一致する値のビット集合
+
+	domAny, dowAny bool // 日と曜日が "*" の場合は true
+}
+
+// cronFields は、cron 形式の各フィールドの名前、範囲、名前で指定できる値です。
+var cronFields = []struct {
+	name     string
+	min, max int
+	names    map[string]int
+}{
+	{"分", 0, 59, nil},
+	{"時", 0, 23, nil},
+	{"日", 1, 31, nil},
+	{"月", 1, 12, map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}},
+	{"曜日", 0, 7, map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}},
+}
+
+// parseCron は、5つのフィールド (分 時 日 月 曜日) の cron 形式の式を解析します。
+// 各フィールドには "*", 値, 範囲 (a-b), 間隔 (*/n, a-b/n) とそのカンマ区切りの一覧を指定できます。
+// 月と曜日は英語の3文字の略称 (jan, mon など) も使用でき、曜日の 7 は日曜日です。
+func parseCron(expr string) (cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return cronSchedule{}, fmt.Errorf("cron 形式の式 '%s' は5つのフィールド (分 時 日 月 曜日) が必要です", expr)
+	}
+	var bits [5]uint64
+	for i, f := range fields {
+		b, err := parseCronField(f, i)
+		if err != nil {
+			return cronSchedule{}, fmt.Errorf("cron 形式の式 '%s' の%sが不正です: %w", expr, cronFields[i].name, err)
+		}
+		bits[i] = b
+	}
+	s := cronSchedule{
+		minute: bits[0], hour: bits[1], dom: bits[2], month: bits[3], dow: bits[4],
+		domAny: fields[2] == "*", dowAny: fields[4] == "*",
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1 // 7 は日曜日
+	}
+	return s, nil
+}
+
+// parseCronField は、cron 形式の index 番目のフィールドを解析し、一致する値のビット集合を返します。
+func parseCronField(field string, index int) (uint64, error) {
+	spec := cronFields[index]
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("間隔 '%s' は正の整数である必要があります", part[i+1:])
+			}
+			rangePart, step = part[:i], n
+		}
+		lo, hi := spec.min, spec.max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = cronValue(from, spec.names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = cronValue(to, spec.names); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				hi = spec.max // "a/n" は a から最大値まで
+			}
+		}
+		if lo < spec.min || hi > spec.max || lo > hi {
+			return 0, fmt.Errorf("'%s' は %d から %d の範囲である必要があります", part, spec.min, spec.max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// cronValue は、cron 形式のフィールドの1つの値を数値に変換します。
+func cronValue(s string, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("'%s' は数値ではありません", s)
+	}
+	return v, nil
+}
+
+// matches は、t を含む1分間が式に一致すれば true を返します。
+// cron と同様に、日と曜日の両方を指定した場合は、どちらかに一致すれば一致とみなします。
+func (s cronSchedule) matches(t time.Time) bool {
+	if s.minute&(1<<uint(t.Minute())) == 0 || s.hour&(1<<uint(t.Hour())) == 0 || s.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	domOK := s.dom&(1<<uint(t.Day())) != 0
+	dowOK := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
+
+// cronSearchLimit は、時間帯の終了時刻を探す期間の上限です。
+const cronSearchLimit = 8 * 24 * time.Hour
+
+// windowEnd は、now を含む時間帯、または now の後で最初に始まる時間帯の終了時刻 (一致しなくなる最初の分) を返します。
+// cronSearchLimit の間に見つからない場合は ok = false を返します。
+func (s cronSchedule) windowEnd(now time.Time) (end time.Time, ok bool) {
+	t := now.Truncate(time.Minute)
+	limit := t.Add(cronSearchLimit)
+	for ; !s.matches(t); t = t.Add(time.Minute) {
+		if t.After(limit) {
+			return time.Time{}, false
+		}
+	}
+	for ; s.matches(t); t = t.Add(time.Minute) {
+		if t.After(limit) {
+			return time.Time{}, false
+		}
+	}
+	return t, true
+}
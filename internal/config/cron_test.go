@@ -0,0 +1,136 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{
+		"* * * * *",
+		"*/15 9-14 * * mon-fri",
+		"0,30 22 1-7 jan,dec 0",
+		"* * 29 2 *",
+		"* 23 * * 7",
+		"5/10 * * * *",
+	} {
+		if _, err := parseCron(expr); err != nil {
+			t.Errorf("parseCron(%q): %v", expr, err)
+		}
+	}
+	for _, expr := range []string{
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"* 10-9 * * *",
+		"*/0 * * * *",
+		"* * * * funday",
+	} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestCronScheduleMatches(t *testing.T) {
+	at := func(y int, m time.Month, d, hh, mm int) time.Time {
+		return time.Date(y, m, d, hh, mm, 30, 0, time.UTC)
+	}
+	for _, tc := range []struct {
+		expr string
+		t    time.Time
+		want bool
+	}{
+		{"* 9-14 * * mon-fri", at(2025, 6, 6, 9, 0), true},   // Friday
+		{"* 9-14 * * mon-fri", at(2025, 6, 6, 15, 0), false}, // end is exclusive
+		{"* 9-14 * * mon-fri", at(2025, 6, 7, 10, 0), false}, // Saturday
+		{"*/15 * * * *", at(2025, 6, 6, 10, 45), true},
+		{"*/15 * * * *", at(2025, 6, 6, 10, 46), false},
+		{"* * * * 7", at(2025, 6, 8, 12, 0), true}, // 7 is Sunday
+		// Feb 29 only exists in leap years.
+		{"* * 29 2 *", at(2024, 2, 29, 0, 0), true},
+		{"* * 29 2 *", at(2025, 3, 1, 0, 0), false},
+		// The last day of a 30-day month is not the 31st.
+		{"* * 31 * *", at(2025, 4, 30, 12, 0), false},
+		{"* * 31 * *", at(2025, 5, 31, 12, 0), true},
+		// When both day of month and day of week are restricted, either one matches.
+		{"* * 1 * mon", at(2025, 6, 1, 12, 0), true}, // Sunday the 1st
+		{"* * 1 * mon", at(2025, 6, 2, 12, 0), true}, // Monday the 2nd
+		{"* * 1 * mon", at(2025, 6, 3, 12, 0), false},
+	} {
+		s, err := parseCron(tc.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", tc.expr, err)
+		}
+		if got := s.matches(tc.t); got != tc.want {
+			t.Errorf("%q matches %s = %t, want %t", tc.expr, tc.t.Format("2006-01-02 15:04 Mon"), got, tc.want)
+		}
+	}
+}
+
+func TestCronWindowEnd(t *testing.T) {
+	for _, tc := range []struct {
+		expr string
+		now  time.Time
+		want time.Time
+	}{
+		// Inside the window: the end of the current window.
+		{"* 9-14 * * *", time.Date(2025, 6, 6, 10, 20, 15, 0, time.UTC), time.Date(2025, 6, 6, 15, 0, 0, 0, time.UTC)},
+		// Before the window: the end of the next window.
+		{"* 9-14 * * *", time.Date(2025, 6, 6, 7, 0, 0, 0, time.UTC), time.Date(2025, 6, 6, 15, 0, 0, 0, time.UTC)},
+		// A window on the last day of the month ends at midnight of the next month.
+		{"* 22-23 31 * *", time.Date(2025, 1, 31, 23, 30, 0, 0, time.UTC), time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		// A window that continues across the month boundary.
+		{"* * 28-31 * *", time.Date(2025, 2, 28, 12, 0, 0, 0, time.UTC), time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"* * 28-31 * *", time.Date(2024, 2, 28, 12, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		s, err := parseCron(tc.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", tc.expr, err)
+		}
+		end, ok := s.windowEnd(tc.now)
+		if !ok || !end.Equal(tc.want) {
+			t.Errorf("%q windowEnd(%s) = (%s, %t), want %s", tc.expr, tc.now, end, ok, tc.want)
+		}
+	}
+
+	// Feb 29 is more than cronSearchLimit away outside leap years.
+	s, _ := parseCron("* * 29 2 *")
+	if _, ok := s.windowEnd(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)); ok {
+		t.Errorf("windowEnd for Feb 29 in 2025 should not be found")
+	}
+}
+
+func TestTimeSlotCron(t *testing.T) {
+	slot := TimeSlot{Cron: "* 9-14 * * mon-fri"}
+	if err := slot.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if !slot.Configured() {
+		t.Errorf("a cron slot should be configured")
+	}
+	in, err := slot.Contains(time.Date(2025, 6, 6, 12, 0, 0, 0, time.Local))
+	if err != nil || !in {
+		t.Errorf("Contains = (%t, %v), want true", in, err)
+	}
+	if err := (TimeSlot{StartTime: "09:00", Cron: "* * * * *"}).Validate(); err == nil {
+		t.Errorf("Validate should reject cron combined with start_time")
+	}
+
+	// charge_cron replaces the weekday start and end times; a weekend start/end pair replaces the cron.
+	cfg := &Config{ChargeStartTime: "09:00", ChargeEndTime: "15:00", ChargeCron: "* 10-13 * * *"}
+	friday := time.Date(2025, 6, 6, 12, 0, 0, 0, time.Local)
+	if got := cfg.ChargeTimes(friday).TimeSlot; got != (TimeSlot{Cron: "* 10-13 * * *"}) {
+		t.Errorf("weekday ChargeTimes = %+v", got)
+	}
+	if got := cfg.ChargeTimes(friday).End(friday); !got.Equal(time.Date(2025, 6, 6, 14, 0, 0, 0, time.Local)) {
+		t.Errorf("End = %s, want 14:00", got)
+	}
+	cfg.ChargeTimesWeekend = ChargeWindow{TimeSlot: TimeSlot{StartTime: "08:00", EndTime: "12:00"}}
+	if got := cfg.ChargeTimes(friday.AddDate(0, 0, 1)).TimeSlot; got != (TimeSlot{StartTime: "08:00", EndTime: "12:00"}) {
+		t.Errorf("weekend ChargeTimes = %+v", got)
+	}
+}
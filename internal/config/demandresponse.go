@@ -0,0 +1,50 @@
+package config
+
+import "fmt"
+
+// エアコンの設定を緩和する方法
+const (
+	DemandResponseEco      = "eco"      // 節電動作 (EPC 0x8F) にする
+	DemandResponseSetpoint = "setpoint" // 設定温度 (EPC 0xB3) を冷房時は上げ、暖房時は下げる
+)
+
+// DemandResponseConfig は、買電制限 (import_guard) の二次対策として、家庭用エアコン (0x0130) の設定を緩和する設定です。
+// 充電電力を下げる余地がなくなっても買電電力が上限を超えている場合に、エアコンを節電動作にするか設定温度を緩和します。
+// 買電電力が上限から restore_margin_watts 以上下がった場合は、元の設定に戻します。
+type DemandResponseConfig struct {
+	Enabled               bool   `toml:"enabled"`
+	AirConditioners       []int  `toml:"air_conditioners"`        // エアコンのインスタンスコード
+	Action                string `toml:"action"`                  // 緩和の方法 ("eco" または "setpoint")。未設定の場合は "eco"
+	SetpointOffsetCelsius int    `toml:"setpoint_offset_celsius"` // action = "setpoint" の場合に設定温度を変更する幅 (℃)。未設定の場合は 2
+	RestoreMarginWatts    int    `toml:"restore_margin_watts"`    // 元の設定に戻す買電電力の上限からの余裕 (W)。未設定の場合は 500
+}
+
+// validate は、DemandResponseConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (c *DemandResponseConfig) validate(guard ImportGuardConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if !guard.Enabled {
+		return fmt.Errorf("'demand_response' を有効にするには 'import_guard' を有効にする必要があります")
+	}
+	if len(c.AirConditioners) == 0 {
+		return fmt.Errorf("'demand_response' を有効にするには 'demand_response.air_conditioners' の設定が必要です")
+	}
+	if err := ValidateBatteryInstances(c.AirConditioners); err != nil {
+		return fmt.Errorf("'demand_response.air_conditioners' が不正です: %w", err)
+	}
+	switch c.Action {
+	case "":
+		c.Action = DemandResponseEco
+	case DemandResponseEco, DemandResponseSetpoint:
+	default:
+		return fmt.Errorf("'demand_response.action' ('%s') は \"%s\" または \"%s\" である必要があります", c.Action, DemandResponseEco, DemandResponseSetpoint)
+	}
+	if c.SetpointOffsetCelsius <= 0 {
+		c.SetpointOffsetCelsius = 2
+	}
+	if c.RestoreMarginWatts <= 0 {
+		c.RestoreMarginWatts = 500
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package config
+
+import "testing"
+
+func TestDemandResponseConfigValidate(t *testing.T) {
+	guard := ImportGuardConfig{Enabled: true, LimitWatts: 3000}
+	c := DemandResponseConfig{Enabled: true, AirConditioners: []int{1}}
+	if err := c.validate(guard); err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	if c.Action != DemandResponseEco || c.SetpointOffsetCelsius != 2 || c.RestoreMarginWatts != 500 {
+		t.Errorf("defaults = %+v", c)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		c     DemandResponseConfig
+		guard ImportGuardConfig
+	}{
+		{"import guard disabled", DemandResponseConfig{Enabled: true, AirConditioners: []int{1}}, ImportGuardConfig{}},
+		{"no air conditioners", DemandResponseConfig{Enabled: true}, guard},
+		{"bad action", DemandResponseConfig{Enabled: true, AirConditioners: []int{1}, Action: "off"}, guard},
+	} {
+		if err := tc.c.validate(tc.guard); err == nil {
+			t.Errorf("%s: validate succeeded, want error", tc.name)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+)
+
+// DeviceTarget は、target_ip の機器に加えて協調制御する EIBS7 です。
+// 同じ LAN 上の複数の EIBS7 を1つの設定ファイルで制御するために使用します。
+type DeviceTarget struct {
+	IP               string `toml:"ip"`                // IPアドレスまたはホスト名
+	BatteryInstances []int  `toml:"battery_instances"` // 制御する蓄電池のインスタンスコード。未設定の場合は [1]
+}
+
+// validate は、DeviceTarget にデフォルト値を設定し、値の妥当性を確認します。
+func (t *DeviceTarget) validate() error {
+	if t.IP == "" {
+		return fmt.Errorf("'ip' が設定されていません")
+	}
+	if len(t.BatteryInstances) == 0 {
+		t.BatteryInstances = []int{1}
+	}
+	return ValidateBatteryInstances(t.BatteryInstances)
+}
+
+// validateTargets は、targets の各機器を確認します。target_ip と同じ機器や重複した機器は指定できません。
+func validateTargets(targets []DeviceTarget, targetIP string) error {
+	seen := map[string]bool{targetIP: true}
+	for i := range targets {
+		if err := targets[i].validate(); err != nil {
+			return fmt.Errorf("%d 番目の機器: %w", i+1, err)
+		}
+		if seen[targets[i].IP] {
+			return fmt.Errorf("%d 番目の機器: '%s' が重複しています", i+1, targets[i].IP)
+		}
+		seen[targets[i].IP] = true
+	}
+	return nil
+}
+
+// DeviceConfig は、[[devices]] で宣言する監視・制御対象の機器です。
+// 宣言した場合は battery_instances と targets の代わりに、宣言した機器のみを監視・制御します。
+// 制御では、機器を監視対象名ではなく役割で探します。
+type DeviceConfig struct {
+	IP   string   `toml:"ip"`   // IPアドレスまたはホスト名。未設定の場合は target_ip
+	Role string   `toml:"role"` // 機器の役割 ("battery", "pv", "meter", "pcs")
+	EOJs []string `toml:"eojs"` // ECHONET Lite オブジェクト (16進6桁, 例: "027D01")。未設定の場合は役割のクラスのインスタンス 0x01
+
+	ParsedEOJs []echonetlite.EOJ `toml:"-"` // EOJs を解析したもの。Load が設定する
+}
+
+// validate は、DeviceConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (d *DeviceConfig) validate(targetIP string) error {
+	if d.IP == "" {
+		d.IP = targetIP
+	}
+	class, ok := roleClasses[d.Role]
+	if !ok {
+		return fmt.Errorf("'role' ('%s') は \"%s\", \"%s\", \"%s\", \"%s\" のいずれかである必要があります", d.Role, RoleBattery, RolePV, RoleMeter, RolePCS)
+	}
+	if len(d.EOJs) == 0 {
+		d.EOJs = []string{fmt.Sprintf("02%02X01", class)}
+	}
+	d.ParsedEOJs = nil
+	for _, s := range d.EOJs {
+		eoj, err := ParseEOJ(s)
+		if err != nil {
+			return fmt.Errorf("'eojs' の %w", err)
+		}
+		if eoj.ClassGroupCode != 0x02 || eoj.ClassCode != class {
+			return fmt.Errorf("'eojs' の '%s' は役割 '%s' のクラス (02%02X) ではありません", s, d.Role, class)
+		}
+		if eoj.InstanceCode < 0x01 || eoj.InstanceCode > 0x7F {
+			return fmt.Errorf("'eojs' の '%s' のインスタンスコードは 01 から 7F の範囲である必要があります", s)
+		}
+		d.ParsedEOJs = append(d.ParsedEOJs, eoj)
+	}
+	return nil
+}
+
+// ParseEOJ は、16進6桁の文字列 (例: "027D01", "0x027D01") を ECHONET Lite オブジェクトに変換します。
+func ParseEOJ(s string) (echonetlite.EOJ, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(s), "0x"))
+	if err != nil || len(b) != 3 {
+		return echonetlite.EOJ{}, fmt.Errorf("'%s' は16進6桁の ECHONET Lite オブジェクトである必要があります", s)
+	}
+	return echonetlite.NewEOJ(b[0], b[1], b[2]), nil
+}
+
+// ParseEPC は、16進2桁の文字列 (例: "E0", "0xE0") を EPC に変換します。
+func ParseEPC(s string) (byte, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(s), "0x"))
+	if err != nil || len(b) != 1 || b[0] < 0x80 {
+		return 0, fmt.Errorf("'%s' は16進2桁の EPC (80 から FF) である必要があります", s)
+	}
+	return b[0], nil
+}
+
+// ValidateDevices は、devices の各機器を確認します。同じ機器の同じ ECHONET Lite オブジェクトは重複して指定できません。
+// 蓄電池は1台以上、分電盤メータリングは住宅全体の買電・売電電力を計測する1台のみ指定できます。
+func ValidateDevices(devices []DeviceConfig, targetIP string) error {
+	seen := make(map[string]bool)
+	batteries, meters := 0, 0
+	for i := range devices {
+		d := &devices[i]
+		if err := d.validate(targetIP); err != nil {
+			return fmt.Errorf("%d 番目の機器: %w", i+1, err)
+		}
+		for _, eoj := range d.ParsedEOJs {
+			key := fmt.Sprintf("%s/%02X%02X%02X", d.IP, eoj.ClassGroupCode, eoj.ClassCode, eoj.InstanceCode)
+			if seen[key] {
+				return fmt.Errorf("%d 番目の機器: '%s' が重複しています", i+1, key)
+			}
+			seen[key] = true
+		}
+		switch d.Role {
+		case RoleBattery:
+			batteries += len(d.ParsedEOJs)
+		case RoleMeter:
+			meters += len(d.ParsedEOJs)
+		}
+	}
+	if batteries == 0 {
+		return fmt.Errorf("役割が \"%s\" の機器が1台以上必要です", RoleBattery)
+	}
+	if meters > 1 {
+		return fmt.Errorf("役割が \"%s\" の機器は1台のみ指定できます", RoleMeter)
+	}
+	return nil
+}
+
+// 監視対象の機器の役割
+const (
+	RoleBattery = "battery" // 蓄電池 (0x027D)
+	RolePV      = "pv"      // 住宅用太陽光発電 (0x0279)
+	RoleMeter   = "meter"   // 住宅全体の買電・売電電力を計測する分電盤メータリング (0x0287)
+	RolePCS     = "pcs"     // マルチ入力PCS (0x02A5)
+)
+
+// roleClasses は、役割ごとの機器のクラスコードです。
+var roleClasses = map[string]byte{
+	RoleBattery: 0x7D,
+	RolePV:      0x79,
+	RoleMeter:   0x87,
+	RolePCS:     0xA5,
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"testing"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+)
+
+func TestValidateTargets(t *testing.T) {
+	targets := []DeviceTarget{{IP: "192.168.0.11"}}
+	if err := validateTargets(targets, "192.168.0.10"); err != nil {
+		t.Fatalf("validateTargets failed: %v", err)
+	}
+	if len(targets[0].BatteryInstances) != 1 || targets[0].BatteryInstances[0] != 1 {
+		t.Errorf("BatteryInstances = %v, want default [1]", targets[0].BatteryInstances)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		targets []DeviceTarget
+	}{
+		{"missing ip", []DeviceTarget{{}}},
+		{"same as target_ip", []DeviceTarget{{IP: "192.168.0.10"}}},
+		{"duplicate", []DeviceTarget{{IP: "192.168.0.11"}, {IP: "192.168.0.11"}}},
+		{"bad instance", []DeviceTarget{{IP: "192.168.0.11", BatteryInstances: []int{0}}}},
+	} {
+		if err := validateTargets(tc.targets, "192.168.0.10"); err == nil {
+			t.Errorf("%s: validateTargets succeeded, want error", tc.name)
+		}
+	}
+}
+
+func TestValidateDevices(t *testing.T) {
+	devices := []DeviceConfig{{Role: RoleBattery}, {IP: "192.168.0.11", Role: RolePV, EOJs: []string{"027902"}}}
+	if err := ValidateDevices(devices, "192.168.0.10"); err != nil {
+		t.Fatalf("validateDevices failed: %v", err)
+	}
+	if devices[0].IP != "192.168.0.10" || len(devices[0].ParsedEOJs) != 1 || devices[0].ParsedEOJs[0] != echonetlite.NewEOJ(0x02, 0x7D, 0x01) {
+		t.Errorf("battery = %+v, want target_ip and 027D01", devices[0])
+	}
+	if devices[1].ParsedEOJs[0] != echonetlite.NewEOJ(0x02, 0x79, 0x02) {
+		t.Errorf("pv EOJ = %v, want 027902", devices[1].ParsedEOJs[0])
+	}
+
+	for _, tc := range []struct {
+		name    string
+		devices []DeviceConfig
+	}{
+		{"unknown role", []DeviceConfig{{Role: "heater"}}},
+		{"bad eoj", []DeviceConfig{{Role: RoleBattery, EOJs: []string{"027D"}}}},
+		{"class mismatch", []DeviceConfig{{Role: RoleBattery, EOJs: []string{"027901"}}}},
+		{"duplicate", []DeviceConfig{{Role: RoleBattery}, {Role: RoleBattery, EOJs: []string{"027D01"}}}},
+		{"no battery", []DeviceConfig{{Role: RolePV}}},
+		{"two meters", []DeviceConfig{{Role: RoleBattery}, {Role: RoleMeter, EOJs: []string{"028701", "028702"}}}},
+	} {
+		if err := ValidateDevices(tc.devices, "192.168.0.10"); err == nil {
+			t.Errorf("%s: validateDevices succeeded, want error", tc.name)
+		}
+	}
+}
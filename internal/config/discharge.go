@@ -0,0 +1,42 @@
+package config
+
+import "fmt"
+
+// DischargeCapConfig は、ピーク時間帯に放電電力を家庭の消費電力以下に制限する設定です。
+// 蓄電池から系統へ逆潮流 (売電) しないよう、放電電力設定値 (EPC 0xEC) を消費電力に合わせて更新します。
+type DischargeCapConfig struct {
+	Enabled         bool `toml:"enabled"`
+	TimeSlot             // ピーク時間帯
+	MarginWatts     int  `toml:"margin_watts"`      // 消費電力から差し引く余裕 (W)
+	MaxWatts        int  `toml:"max_watts"`         // 放電電力の上限 (W)。0 の場合は消費電力のみで制限する
+	UpdateStepWatts int  `toml:"update_step_watts"` // 前回の設定値との差がこの値未満の場合は更新しない (W)
+}
+
+// Validate は、DischargeCapConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (c *DischargeCapConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if err := c.TimeSlot.Validate(); err != nil {
+		return fmt.Errorf("'discharge_cap' のピーク時間帯が不正です: %w", err)
+	}
+	if c.MarginWatts < 0 || c.MaxWatts < 0 {
+		return fmt.Errorf("'discharge_cap.margin_watts' と 'discharge_cap.max_watts' は 0 以上である必要があります")
+	}
+	if c.UpdateStepWatts <= 0 {
+		c.UpdateStepWatts = 100
+	}
+	return nil
+}
+
+// DischargePowerCap は、家庭の消費電力 (W) から放電電力設定値 (W) を計算します。
+func (c DischargeCapConfig) DischargePowerCap(householdLoad int32) int {
+	power := int(householdLoad) - c.MarginWatts
+	if c.MaxWatts > 0 && power > c.MaxWatts {
+		power = c.MaxWatts
+	}
+	if power < 0 {
+		power = 0
+	}
+	return power
+}
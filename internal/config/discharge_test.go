@@ -0,0 +1,19 @@
+package config
+
+import "testing"
+
+func TestDischargePowerCap(t *testing.T) {
+	cfg := DischargeCapConfig{MarginWatts: 100, MaxWatts: 2000}
+	for _, tc := range []struct {
+		load int32
+		want int
+	}{
+		{1500, 1400},
+		{5000, 2000}, // limited by max_watts
+		{50, 0},      // never negative
+	} {
+		if got := cfg.DischargePowerCap(tc.load); got != tc.want {
+			t.Errorf("DischargePowerCap(%d) = %d, want %d", tc.load, got, tc.want)
+		}
+	}
+}
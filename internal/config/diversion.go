@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+)
+
+// 余剰電力の振り向け先の種類
+const (
+	DiversionWaterHeater = "water_heater" // 電気給湯機 (エコキュート, 0x026B)
+)
+
+// DiversionConfig は、蓄電池で吸収しきれない余剰電力を売電せず、電気給湯機の沸き上げなどに振り向ける設定です。
+// 売電電力が閾値を超え、蓄電池が満充電または充電電力の上限で充電している場合に、loads の順に1台ずつ沸き上げを開始します。
+type DiversionConfig struct {
+	Enabled              bool            `toml:"enabled"`
+	ExportThresholdWatts int             `toml:"export_threshold_watts"` // 振り向けを開始する売電電力 (W)。未設定の場合は 1000
+	Loads                []DiversionLoad `toml:"loads"`                  // 振り向け先 (優先順)
+}
+
+// DiversionLoad は、余剰電力の振り向け先の機器です。
+type DiversionLoad struct {
+	Type     string `toml:"type"`     // 機器の種類 ("water_heater")
+	Instance int    `toml:"instance"` // インスタンスコード。未設定の場合は 1
+}
+
+// validate は、DiversionConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (c *DiversionConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.ExportThresholdWatts <= 0 {
+		c.ExportThresholdWatts = 1000
+	}
+	if len(c.Loads) == 0 {
+		return fmt.Errorf("'surplus_diversion' を有効にするには 'surplus_diversion.loads' の設定が必要です")
+	}
+	seen := make(map[echonetlite.EOJ]bool)
+	for i := range c.Loads {
+		l := &c.Loads[i]
+		if l.Type != DiversionWaterHeater {
+			return fmt.Errorf("'surplus_diversion.loads' (%d 番目) の種類 '%s' は不正です (\"%s\" を指定してください)", i+1, l.Type, DiversionWaterHeater)
+		}
+		if l.Instance == 0 {
+			l.Instance = 1
+		}
+		if l.Instance < 0x01 || l.Instance > 0x7F {
+			return fmt.Errorf("'surplus_diversion.loads' (%d 番目) のインスタンスコード (%d) は 1 から 127 の範囲である必要があります", i+1, l.Instance)
+		}
+		if seen[l.EOJ()] {
+			return fmt.Errorf("'surplus_diversion.loads' (%d 番目) が重複しています", i+1)
+		}
+		seen[l.EOJ()] = true
+	}
+	return nil
+}
+
+// EOJ は、振り向け先の ECHONET Lite オブジェクトを返します。
+func (l DiversionLoad) EOJ() echonetlite.EOJ {
+	return echonetlite.NewEOJ(0x02, 0x6B, byte(l.Instance))
+}
@@ -0,0 +1,23 @@
+package config
+
+import "testing"
+
+func TestDiversionConfigValidate(t *testing.T) {
+	c := DiversionConfig{Enabled: true, Loads: []DiversionLoad{{Type: DiversionWaterHeater}}}
+	if err := c.validate(); err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	if c.ExportThresholdWatts != 1000 || c.Loads[0].Instance != 1 {
+		t.Errorf("defaults = threshold %d, instance %d, want 1000 and 1", c.ExportThresholdWatts, c.Loads[0].Instance)
+	}
+
+	for _, bad := range []DiversionConfig{
+		{Enabled: true},
+		{Enabled: true, Loads: []DiversionLoad{{Type: "pool_pump"}}},
+		{Enabled: true, Loads: []DiversionLoad{{Type: DiversionWaterHeater, Instance: 1}, {Type: DiversionWaterHeater}}},
+	} {
+		if err := bad.validate(); err == nil {
+			t.Errorf("validate(%+v) succeeded, want error", bad)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"net/mail"
+	"time"
+)
+
+// EmailReportConfig は、履歴を集計した日ごとのまとめをメールで送信する設定です。[history] の有効化が必要です。
+type EmailReportConfig struct {
+	Enabled  bool     `toml:"enabled"`
+	Time     string   `toml:"time"`      // 送信する時刻 (HH:MM、デフォルト: "23:55")。その日の 0:00 からこの時刻までを集計する
+	SMTPHost string   `toml:"smtp_host"` // SMTP サーバー
+	SMTPPort int      `toml:"smtp_port"` // デフォルト: 587 (tls = true の場合は 465)
+	TLS      bool     `toml:"tls"`       // true の場合は接続時から TLS (SMTPS)。false の場合はサーバーが対応していれば STARTTLS を使用する
+	Username string   `toml:"username"`  // 未設定の場合は認証しない
+	Password Secret   `toml:"password"`  // "env:" または "file:" で参照できる
+	From     string   `toml:"from"`
+	To       []string `toml:"to"`
+}
+
+// validate は、EmailReportConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (c *EmailReportConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Time == "" {
+		c.Time = "23:55"
+	}
+	if _, err := time.Parse("15:04", c.Time); err != nil {
+		return fmt.Errorf("'email_report.time' ('%s') は HH:MM 形式である必要があります", c.Time)
+	}
+	if c.SMTPHost == "" {
+		return fmt.Errorf("'email_report.enabled' が true の場合は 'email_report.smtp_host' の設定が必要です")
+	}
+	if c.SMTPPort == 0 {
+		c.SMTPPort = 587
+		if c.TLS {
+			c.SMTPPort = 465
+		}
+	}
+	if c.SMTPPort < 0 || c.SMTPPort > 65535 {
+		return fmt.Errorf("'email_report.smtp_port' (%d) が範囲外です", c.SMTPPort)
+	}
+	if _, err := mail.ParseAddress(c.From); err != nil {
+		return fmt.Errorf("'email_report.from' ('%s') がメールアドレスではありません: %w", c.From, err)
+	}
+	if len(c.To) == 0 {
+		return fmt.Errorf("'email_report.enabled' が true の場合は 'email_report.to' の設定が必要です")
+	}
+	for _, to := range c.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("'email_report.to' ('%s') がメールアドレスではありません: %w", to, err)
+		}
+	}
+	password, err := c.Password.resolve()
+	if err != nil {
+		return fmt.Errorf("'email_report.password' を読み込めませんでした: %w", err)
+	}
+	c.Password = password
+	return nil
+}
@@ -0,0 +1,27 @@
+package config
+
+import "testing"
+
+func TestEmailReportConfigValidate(t *testing.T) {
+	c := EmailReportConfig{Enabled: true, SMTPHost: "smtp.example.com", From: "eibs7 <eibs7@example.com>", To: []string{"me@example.com"}}
+	if err := c.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Time != "23:55" || c.SMTPPort != 587 {
+		t.Errorf("defaults = %q, %d", c.Time, c.SMTPPort)
+	}
+	tls := EmailReportConfig{Enabled: true, TLS: true, SMTPHost: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}}
+	if err := tls.validate(); err != nil || tls.SMTPPort != 465 {
+		t.Errorf("tls port = %d, %v", tls.SMTPPort, err)
+	}
+	for _, bad := range []EmailReportConfig{
+		{Enabled: true, From: "a@example.com", To: []string{"b@example.com"}},
+		{Enabled: true, SMTPHost: "h", From: "not an address", To: []string{"b@example.com"}},
+		{Enabled: true, SMTPHost: "h", From: "a@example.com"},
+		{Enabled: true, SMTPHost: "h", From: "a@example.com", To: []string{"b@example.com"}, Time: "25:00"},
+	} {
+		if err := bad.validate(); err == nil {
+			t.Errorf("validate(%+v) succeeded", bad)
+		}
+	}
+}
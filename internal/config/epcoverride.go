@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+)
+
+// EPCOverride は、監視対象ごとに取得するプロパティ (EPC) を変更する設定です。
+// 再コンパイルせずに、機器の追加のプロパティをログで確認するために使用します。
+type EPCOverride struct {
+	IP        string   `toml:"ip"`         // 機器の IPアドレスまたはホスト名。未設定の場合は target_ip
+	EOJ       string   `toml:"eoj"`        // 監視対象の ECHONET Lite オブジェクト (16進6桁, 例: "027901")
+	EPCs      []string `toml:"epcs"`       // 既定の代わりに取得するプロパティ (16進2桁, 例: "E0")。未設定の場合は既定のプロパティ
+	ExtraEPCs []string `toml:"extra_epcs"` // 既定のプロパティに加えて取得するプロパティ
+
+	// 以下は Validate が設定する、EOJ, EPCs, ExtraEPCs を解析したもの
+	ParsedEOJ       echonetlite.EOJ `toml:"-"`
+	ParsedEPCs      []byte          `toml:"-"`
+	ParsedExtraEPCs []byte          `toml:"-"`
+}
+
+// Validate は、EPCOverride にデフォルト値を設定し、ECHONET Lite オブジェクトと EPC の形式を確認します。
+// 蓄電池のプロパティを置き換える場合は、制御に使用する蓄電残量3, 運転モード設定, 充電電力設定値を含める必要があります。
+func (o *EPCOverride) Validate(targetIP string) error {
+	if o.IP == "" {
+		o.IP = targetIP
+	}
+	eoj, err := ParseEOJ(o.EOJ)
+	if err != nil {
+		return fmt.Errorf("'eoj' の %w", err)
+	}
+	o.ParsedEOJ = eoj
+	if o.ParsedEPCs, err = ParseEPCs(o.EPCs); err != nil {
+		return fmt.Errorf("'epcs' の %w", err)
+	}
+	if o.ParsedExtraEPCs, err = ParseEPCs(o.ExtraEPCs); err != nil {
+		return fmt.Errorf("'extra_epcs' の %w", err)
+	}
+	if len(o.ParsedEPCs) == 0 && len(o.ParsedExtraEPCs) == 0 {
+		return fmt.Errorf("'epcs' または 'extra_epcs' の設定が必要です")
+	}
+	if eoj.ClassGroupCode == 0x02 && eoj.ClassCode == 0x7D && len(o.ParsedEPCs) > 0 {
+		for _, epc := range []byte{0xE4, 0xDA, 0xEB} {
+			if !containsEPC(o.ParsedEPCs, epc) {
+				return fmt.Errorf("蓄電池の 'epcs' には制御に使用するプロパティ %s (EPC: 0x%X) が必要です", echonetlite.PropertyName(eoj, epc), epc)
+			}
+		}
+	}
+	return nil
+}
+
+// ParseEPCs は、16進2桁の文字列の一覧を EPC の一覧に変換します。
+func ParseEPCs(values []string) ([]byte, error) {
+	var epcs []byte
+	for _, s := range values {
+		epc, err := ParseEPC(s)
+		if err != nil {
+			return nil, err
+		}
+		epcs = append(epcs, epc)
+	}
+	return epcs, nil
+}
+
+// containsEPC は、epcs に epc が含まれていれば true を返します。
+func containsEPC(epcs []byte, epc byte) bool {
+	for _, e := range epcs {
+		if e == epc {
+			return true
+		}
+	}
+	return false
+}
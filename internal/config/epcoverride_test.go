@@ -0,0 +1,32 @@
+package config
+
+import (
+	"testing"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+)
+
+func TestEPCOverrideValidate(t *testing.T) {
+	o := EPCOverride{EOJ: "027901", ExtraEPCs: []string{"0xE1", "d0"}}
+	if err := o.Validate("192.168.0.10"); err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	if o.IP != "192.168.0.10" || o.ParsedEOJ != echonetlite.NewEOJ(0x02, 0x79, 0x01) || string(o.ParsedExtraEPCs) != string([]byte{0xE1, 0xD0}) {
+		t.Errorf("override = %+v, want target_ip, 027901 and E1 D0", o)
+	}
+
+	for _, tc := range []struct {
+		name string
+		o    EPCOverride
+	}{
+		{"bad eoj", EPCOverride{EOJ: "0279", ExtraEPCs: []string{"E1"}}},
+		{"bad epc", EPCOverride{EOJ: "027901", ExtraEPCs: []string{"E"}}},
+		{"epc below 0x80", EPCOverride{EOJ: "027901", EPCs: []string{"10"}}},
+		{"nothing to change", EPCOverride{EOJ: "027901"}},
+		{"battery without control EPCs", EPCOverride{EOJ: "027D01", EPCs: []string{"E4", "DA"}}},
+	} {
+		if err := tc.o.Validate("192.168.0.10"); err == nil {
+			t.Errorf("%s: validate succeeded, want error", tc.name)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+)
+
+// EV の充電と蓄電池の充電のどちらに余剰電力を優先するか
+const (
+	EVPriorityEV      = "ev"      // EV の充電を優先する (EV の充電は停止しない)
+	EVPriorityBattery = "battery" // 充電時間帯に蓄電池の充電を優先し、EV の充電を一時停止する
+)
+
+// EVChargerConfig は、電気自動車充電器 (0x02A1) または電気自動車充放電器 (0x027E) の監視・制御の設定です。
+// EV の充電電力は分電盤メータリングの瞬時電力に含まれるため、自家消費電力の一部として余剰電力の計算に反映されます。
+type EVChargerConfig struct {
+	Enabled       bool   `toml:"enabled"`
+	Bidirectional bool   `toml:"bidirectional"` // true: 電気自動車充放電器 (V2H, 0x027E), false: 電気自動車充電器 (0x02A1)
+	Instance      int    `toml:"instance"`      // インスタンスコード。未設定の場合は 1
+	Priority      string `toml:"priority"`      // 余剰電力を優先する充電 ("ev" または "battery")。未設定の場合は "ev"
+}
+
+// validate は、EVChargerConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (c *EVChargerConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Instance == 0 {
+		c.Instance = 1
+	}
+	if c.Instance < 0x01 || c.Instance > 0x7F {
+		return fmt.Errorf("'ev_charger.instance' (%d) は 1 から 127 の範囲である必要があります", c.Instance)
+	}
+	switch c.Priority {
+	case "":
+		c.Priority = EVPriorityEV
+	case EVPriorityEV, EVPriorityBattery:
+	default:
+		return fmt.Errorf("'ev_charger.priority' ('%s') は \"%s\" または \"%s\" である必要があります", c.Priority, EVPriorityEV, EVPriorityBattery)
+	}
+	return nil
+}
+
+// EOJ は、EV 充電器の ECHONET Lite オブジェクトを返します。
+func (c EVChargerConfig) EOJ() echonetlite.EOJ {
+	if c.Bidirectional {
+		return echonetlite.NewEOJ(0x02, 0x7E, byte(c.Instance))
+	}
+	return echonetlite.NewEOJ(0x02, 0xA1, byte(c.Instance))
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"testing"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+)
+
+func TestEVChargerConfigValidate(t *testing.T) {
+	c := EVChargerConfig{Enabled: true}
+	if err := c.validate(); err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	if c.Instance != 1 || c.Priority != EVPriorityEV {
+		t.Errorf("defaults = instance %d, priority %q, want 1 and %q", c.Instance, c.Priority, EVPriorityEV)
+	}
+	if eoj := c.EOJ(); eoj != echonetlite.NewEOJ(0x02, 0xA1, 0x01) {
+		t.Errorf("EOJ() = %v, want 02A101", eoj)
+	}
+	c.Bidirectional = true
+	if eoj := c.EOJ(); eoj != echonetlite.NewEOJ(0x02, 0x7E, 0x01) {
+		t.Errorf("EOJ() = %v, want 027E01", eoj)
+	}
+
+	for _, bad := range []EVChargerConfig{
+		{Enabled: true, Instance: 0x80},
+		{Enabled: true, Priority: "grid"},
+	} {
+		if err := bad.validate(); err == nil {
+			t.Errorf("validate(%+v) succeeded, want error", bad)
+		}
+	}
+}
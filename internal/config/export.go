@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// ExportWindow は、売電を優先する期間と時間帯です。
+// 期間中の時間帯 (FIT の売電単価が高い日中など) は、蓄電池が太陽光の余剰電力を吸収しないよう待機モードにして余剰電力をすべて売電し、
+// 時間帯の後は通常の制御 (自家消費優先) に戻ります。
+type ExportWindow struct {
+	DateRange
+	TimeSlot
+}
+
+// validate は、期間と時間帯の形式を確認します。
+func (w ExportWindow) validate() error {
+	if err := w.DateRange.validate(); err != nil {
+		return err
+	}
+	return w.TimeSlot.Validate()
+}
+
+// exportWindow は、指定された日時が export_maximization のいずれかの期間・時間帯に含まれる場合、その設定を返します。
+func (c *Config) exportWindow(now time.Time) (ExportWindow, bool, error) {
+	for _, w := range c.ExportMaximization {
+		if !w.ContainsDate(now) {
+			continue
+		}
+		inWindow, err := w.TimeSlot.Contains(now)
+		if err != nil {
+			return ExportWindow{}, false, fmt.Errorf("売電優先の時間帯の判定に失敗しました: %w", err)
+		}
+		if inWindow {
+			return w, true, nil
+		}
+	}
+	return ExportWindow{}, false, nil
+}
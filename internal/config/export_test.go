@@ -0,0 +1,38 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExportWindow(t *testing.T) {
+	cfg := &Config{ExportMaximization: []ExportWindow{
+		{DateRange: DateRange{Start: "04-01", End: "09-30"}, TimeSlot: TimeSlot{StartTime: "09:00", EndTime: "15:00"}},
+	}}
+	for _, tc := range []struct {
+		at   string
+		want bool
+	}{
+		{"2025-05-01 10:00", true},
+		{"2025-05-01 08:59", false},
+		{"2025-05-01 15:00", false},
+		{"2025-10-01 10:00", false}, // outside the date range
+		{"2025-09-30 14:59", true},
+	} {
+		now, _ := time.ParseInLocation("2006-01-02 15:04", tc.at, time.Local)
+		_, got, err := cfg.exportWindow(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: in export window = %t, want %t", tc.at, got, tc.want)
+		}
+	}
+}
+
+func TestExportWindowValidate(t *testing.T) {
+	bad := ExportWindow{DateRange: DateRange{Start: "04-01", End: "09-30"}, TimeSlot: TimeSlot{StartTime: "9am", EndTime: "15:00"}}
+	if err := bad.validate(); err == nil {
+		t.Error("invalid start_time accepted")
+	}
+}
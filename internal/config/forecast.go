@@ -0,0 +1,54 @@
+package config
+
+import "fmt"
+
+// ForecastConfig は、太陽光発電量予測に使用する予測の取得元、設置場所とパネルの設定です。
+type ForecastConfig struct {
+	Provider          string  `toml:"provider"`            // 予測の取得元 ("open-meteo" または "solcast", デフォルト: "open-meteo")
+	SolcastAPIKey     Secret  `toml:"solcast_api_key"`     // Solcast の API キー ("env:<環境変数名>", "file:<パス>" で参照可能)
+	SolcastResourceID string  `toml:"solcast_resource_id"` // Solcast に登録した Rooftop Site の ID
+	Latitude          float64 `toml:"latitude"`
+	Longitude         float64 `toml:"longitude"`
+	PanelKW           float64 `toml:"panel_kw"`          // パネル容量 (kW)
+	PanelTilt         float64 `toml:"panel_tilt"`        // 傾斜角 (度, 0 = 水平)
+	PanelAzimuth      float64 `toml:"panel_azimuth"`     // 方位角 (度, 0 = 南, -90 = 東, 90 = 西)
+	PerformanceRatio  float64 `toml:"performance_ratio"` // システム出力係数 (損失を考慮した係数)
+}
+
+// 発電量予測の取得元
+const (
+	forecastProviderOpenMeteo = "open-meteo"
+	ForecastProviderSolcast   = "solcast"
+)
+
+// Validate は、ForecastConfig にデフォルト値を設定し、予測の取得元に必要な設定があることを確認します。
+func (c *ForecastConfig) Validate() error {
+	if c.PerformanceRatio <= 0 {
+		c.PerformanceRatio = 0.8
+	}
+	switch c.Provider {
+	case "":
+		c.Provider = forecastProviderOpenMeteo
+	case forecastProviderOpenMeteo:
+	case ForecastProviderSolcast:
+		key, err := c.SolcastAPIKey.resolve()
+		if err != nil {
+			return fmt.Errorf("'forecast.solcast_api_key' を読み込めませんでした: %w", err)
+		}
+		c.SolcastAPIKey = key
+		if c.SolcastAPIKey == "" || c.SolcastResourceID == "" {
+			return fmt.Errorf("'forecast.provider' が \"solcast\" の場合は 'forecast.solcast_api_key' と 'forecast.solcast_resource_id' の設定が必要です")
+		}
+	default:
+		return fmt.Errorf("'forecast.provider' の値が不正です ('%s')", c.Provider)
+	}
+	return nil
+}
+
+// configured は、発電量予測を取得するための設定があれば true を返します。
+func (c ForecastConfig) configured() bool {
+	if c.Provider == ForecastProviderSolcast {
+		return c.SolcastAPIKey != "" && c.SolcastResourceID != ""
+	}
+	return c.PanelKW > 0
+}
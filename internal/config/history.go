@@ -0,0 +1,32 @@
+package config
+
+import (
+	"fmt"
+
+	_ "modernc.org/sqlite"
+)
+
+// HistoryConfig は、監視サイクルごとの測定値と制御の履歴を SQLite に保存する設定です。
+type HistoryConfig struct {
+	Enabled            bool   `toml:"enabled"`
+	File               string `toml:"file"`                 // データベースファイル (デフォルト: "eibs7-history.db")
+	RetentionDays      int    `toml:"retention_days"`       // 履歴を残す日数 (デフォルト: 90)。0 未満は不可
+	PruneIntervalHours int    `toml:"prune_interval_hours"` // 古い履歴を削除する間隔 (時間、デフォルト: 24)
+}
+
+// Validate は、HistoryConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (c *HistoryConfig) Validate() error {
+	if c.File == "" {
+		c.File = "eibs7-history.db"
+	}
+	if c.RetentionDays == 0 {
+		c.RetentionDays = 90
+	}
+	if c.PruneIntervalHours == 0 {
+		c.PruneIntervalHours = 24
+	}
+	if c.RetentionDays < 0 || c.PruneIntervalHours < 0 {
+		return fmt.Errorf("'history.retention_days' と 'history.prune_interval_hours' は 1 以上である必要があります")
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// treat_holidays_as の値
+const (
+	holidaysAsWeekend = "weekend" // 祝日は週末の充電時間帯 (charge_times_weekend) を使用する
+	holidaysAsWeekday = "weekday" // 祝日も平日の充電時間帯を使用する
+	HolidaysAsCustom  = "custom"  // 祝日は holidays.charge_times の充電時間帯を使用する
+)
+
+// HolidayConfig は、国民の祝日・休日に適用する充電時間帯の設定です。
+// 祝日は組み込みの計算で判定します。内閣府の「国民の祝日」の CSV (syukujitsu.csv) を指定した場合は、その日付を使用します。
+type HolidayConfig struct {
+	Enabled     bool         `toml:"enabled"`
+	File        string       `toml:"file"`              // 内閣府の syukujitsu.csv。未設定の場合は組み込みの計算で判定する
+	TreatAs     string       `toml:"treat_holidays_as"` // "weekend", "weekday", "custom" のいずれか (デフォルト: "weekend")
+	ChargeTimes ChargeWindow `toml:"charge_times"`      // treat_holidays_as = "custom" の充電時間帯。未設定の項目は平日の設定を使用する
+
+	dates map[string]bool // file から読み込んだ祝日 (YYYY-MM-DD)。nil の場合は組み込みの計算で判定する
+}
+
+// validate は、HolidayConfig にデフォルト値を設定し、値の妥当性を確認します。file を指定した場合はここで読み込みます。
+func (c *HolidayConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.TreatAs == "" {
+		c.TreatAs = holidaysAsWeekend
+	}
+	switch c.TreatAs {
+	case holidaysAsWeekend, holidaysAsWeekday:
+	case HolidaysAsCustom:
+		if c.ChargeTimes.Cron != "" {
+			if err := c.ChargeTimes.TimeSlot.Validate(); err != nil {
+				return fmt.Errorf("'holidays.charge_times' が不正です: %w", err)
+			}
+		}
+		// 未設定の項目は平日の設定と組み合わせて使用するため、設定された時刻の形式のみ確認する
+		for _, s := range []string{c.ChargeTimes.StartTime, c.ChargeTimes.EndTime} {
+			if _, err := time.Parse("15:04", s); s != "" && err != nil {
+				return fmt.Errorf("'holidays.charge_times' の時刻 '%s' は HH:MM 形式である必要があります", s)
+			}
+		}
+		if c.ChargeTimes.TargetSOCPercent < 0 || c.ChargeTimes.TargetSOCPercent > 100 {
+			return fmt.Errorf("'holidays.charge_times.target_soc_percent' (%d) は 0 から 100 の範囲である必要があります", c.ChargeTimes.TargetSOCPercent)
+		}
+	default:
+		return fmt.Errorf("'holidays.treat_holidays_as' ('%s') は \"weekend\", \"weekday\", \"custom\" のいずれかである必要があります", c.TreatAs)
+	}
+	if c.File == "" {
+		return nil
+	}
+	f, err := os.Open(c.File)
+	if err != nil {
+		return fmt.Errorf("'holidays.file' の祝日の一覧 '%s' を開けませんでした: %w", c.File, err)
+	}
+	defer f.Close()
+	dates, err := parseHolidayCSV(f)
+	if err != nil {
+		return fmt.Errorf("'holidays.file' の祝日の一覧 '%s' が不正です: %w", c.File, err)
+	}
+	c.dates = dates
+	return nil
+}
+
+// isHoliday は、指定された日が国民の祝日・休日であれば true を返します。日付は t のタイムゾーンで判定します。
+func (c *HolidayConfig) isHoliday(t time.Time) bool {
+	if c.dates != nil {
+		return c.dates[t.Format("2006-01-02")]
+	}
+	_, ok := japaneseHolidays(t.Year())[t.Format("01-02")]
+	return ok
+}
+
+// parseHolidayCSV は、内閣府の syukujitsu.csv を読み込み、祝日の日付 (YYYY-MM-DD) の集合を返します。
+// 1列目の日付 (YYYY/M/D) のみを使用するため、Shift_JIS の祝日名や見出し行はそのまま読み飛ばします。
+func parseHolidayCSV(r io.Reader) (map[string]bool, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	dates := make(map[string]bool)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("祝日の一覧の読み込みに失敗しました: %w", err)
+		}
+		date, err := time.Parse("2006/1/2", strings.ReplaceAll(strings.TrimSpace(record[0]), "-", "/"))
+		if err != nil {
+			continue
+		}
+		dates[date.Format("2006-01-02")] = true
+	}
+	if len(dates) == 0 {
+		return nil, fmt.Errorf("祝日の日付が含まれていません")
+	}
+	return dates, nil
+}
+
+// japaneseHolidays は、現行の祝日法に基づいて year 年の国民の祝日・休日を計算し、日付 (MM-DD) と名前の対応を返します。
+// 春分の日と秋分の日は 1980〜2099 年の近似式で計算します。
+// 東京オリンピックによる 2020, 2021 年の移動など、特別措置の祝日には対応しないため、必要な場合は holidays.file を指定してください。
+func japaneseHolidays(year int) map[string]string {
+	holidays := make(map[string]string)
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	// nthMonday は、month 月の第 n 月曜日を返します。
+	nthMonday := func(month time.Month, n int) time.Time {
+		first := date(month, 1)
+		offset := (int(time.Monday) - int(first.Weekday()) + 7) % 7
+		return first.AddDate(0, 0, offset+7*(n-1))
+	}
+	add := func(t time.Time, name string) {
+		holidays[t.Format("01-02")] = name
+	}
+
+	add(date(time.January, 1), "元日")
+	add(nthMonday(time.January, 2), "成人の日")
+	add(date(time.February, 11), "建国記念の日")
+	add(date(time.February, 23), "天皇誕生日")
+	add(date(time.March, int(20.8431+0.242194*float64(year-1980))-(year-1980)/4), "春分の日")
+	add(date(time.April, 29), "昭和の日")
+	add(date(time.May, 3), "憲法記念日")
+	add(date(time.May, 4), "みどりの日")
+	add(date(time.May, 5), "こどもの日")
+	add(nthMonday(time.July, 3), "海の日")
+	add(date(time.August, 11), "山の日")
+	add(nthMonday(time.September, 3), "敬老の日")
+	add(date(time.September, int(23.2488+0.242194*float64(year-1980))-(year-1980)/4), "秋分の日")
+	add(nthMonday(time.October, 2), "スポーツの日")
+	add(date(time.November, 3), "文化の日")
+	add(date(time.November, 23), "勤労感謝の日")
+
+	// 国民の休日: 前日と翌日が祝日である祝日以外の日
+	var citizens []time.Time
+	for d := date(time.January, 2); d.Year() == year && d.Month() < time.December; d = d.AddDate(0, 0, 1) {
+		_, before := holidays[d.AddDate(0, 0, -1).Format("01-02")]
+		_, today := holidays[d.Format("01-02")]
+		_, after := holidays[d.AddDate(0, 0, 1).Format("01-02")]
+		if before && after && !today {
+			citizens = append(citizens, d)
+		}
+	}
+	for _, d := range citizens {
+		add(d, "国民の休日")
+	}
+
+	// 振替休日: 日曜日の祝日の後の、最初の祝日でない日
+	var substitutes []time.Time
+	for d := date(time.January, 1); d.Year() == year; d = d.AddDate(0, 0, 1) {
+		if _, ok := holidays[d.Format("01-02")]; !ok || d.Weekday() != time.Sunday {
+			continue
+		}
+		next := d.AddDate(0, 0, 1)
+		for {
+			if _, ok := holidays[next.Format("01-02")]; !ok {
+				break
+			}
+			next = next.AddDate(0, 0, 1)
+		}
+		if next.Year() == year {
+			substitutes = append(substitutes, next)
+		}
+	}
+	for _, d := range substitutes {
+		add(d, "振替休日")
+	}
+	return holidays
+}
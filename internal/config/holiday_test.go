@@ -0,0 +1,110 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJapaneseHolidays(t *testing.T) {
+	// Holidays of 2025 including the substitute holidays on 02-24, 05-06 and 11-24.
+	want := []string{
+		"01-01", "01-13", "02-11", "02-23", "02-24", "03-20", "04-29", "05-03", "05-04", "05-05", "05-06",
+		"07-21", "08-11", "09-15", "09-23", "10-13", "11-03", "11-23", "11-24",
+	}
+	var got []string
+	for d := range japaneseHolidays(2025) {
+		got = append(got, d)
+	}
+	sort.Strings(got)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("japaneseHolidays(2025) = %v, want %v", got, want)
+	}
+
+	// 2026-09-22 lies between Respect for the Aged Day and the autumnal equinox.
+	if name := japaneseHolidays(2026)["09-22"]; name != "国民の休日" {
+		t.Errorf("2026-09-22 = %q, want 国民の休日", name)
+	}
+}
+
+func TestParseHolidayCSV(t *testing.T) {
+	// The names are Shift_JIS in the published file; only the dates are used.
+	csv := "\x8d\x91\x96\xaf\x82\xcc\x8f\x6a\x93\xfa,name\n2025/1/1,\x8c\xb3\x93\xfa\n2025/1/13,x\n"
+	dates, err := parseHolidayCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("parseHolidayCSV: %v", err)
+	}
+	if len(dates) != 2 || !dates["2025-01-01"] || !dates["2025-01-13"] {
+		t.Errorf("dates = %v", dates)
+	}
+	if _, err := parseHolidayCSV(strings.NewReader("header\n")); err == nil {
+		t.Errorf("expected error for a file without dates")
+	}
+}
+
+func TestChargeTimesOnHolidays(t *testing.T) {
+	cfg := &Config{
+		ChargeStartTime:        "09:00",
+		ChargeEndTime:          "15:00",
+		ChargeTargetSOCPercent: 80,
+		ChargeTimesWeekend:     ChargeWindow{TimeSlot: TimeSlot{StartTime: "10:00"}},
+		Holidays: HolidayConfig{
+			Enabled:     true,
+			ChargeTimes: ChargeWindow{TimeSlot: TimeSlot{EndTime: "16:00"}, TargetSOCPercent: 100},
+		},
+	}
+	if err := cfg.Holidays.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	holiday := time.Date(2025, 11, 24, 12, 0, 0, 0, time.Local) // Monday, substitute holiday
+	workday := holiday.AddDate(0, 0, 1)
+
+	for _, tc := range []struct {
+		treatAs string
+		want    ChargeWindow
+	}{
+		{holidaysAsWeekend, ChargeWindow{TimeSlot: TimeSlot{StartTime: "10:00", EndTime: "15:00"}, TargetSOCPercent: 80}},
+		{holidaysAsWeekday, ChargeWindow{TimeSlot: TimeSlot{StartTime: "09:00", EndTime: "15:00"}, TargetSOCPercent: 80}},
+		{HolidaysAsCustom, ChargeWindow{TimeSlot: TimeSlot{StartTime: "09:00", EndTime: "16:00"}, TargetSOCPercent: 100}},
+	} {
+		cfg.Holidays.TreatAs = tc.treatAs
+		if got := cfg.ChargeTimes(holiday); got != tc.want {
+			t.Errorf("%s: ChargeTimes(holiday) = %+v, want %+v", tc.treatAs, got, tc.want)
+		}
+		if got := cfg.ChargeTimes(workday); got.StartTime != "09:00" || got.EndTime != "15:00" {
+			t.Errorf("%s: ChargeTimes(workday) = %+v", tc.treatAs, got)
+		}
+	}
+
+	// Holidays are ignored unless enabled.
+	cfg.Holidays.Enabled = false
+	if got := cfg.ChargeTimes(holiday); got.StartTime != "09:00" {
+		t.Errorf("disabled: ChargeTimes(holiday) = %+v", got)
+	}
+}
+
+func TestHolidayConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "syukujitsu.csv")
+	if err := os.WriteFile(path, []byte("date,name\n2025/6/2,test\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := HolidayConfig{Enabled: true, File: path}
+	if err := c.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if !c.isHoliday(time.Date(2025, 6, 2, 8, 0, 0, 0, time.Local)) {
+		t.Errorf("2025-06-02 from the file should be a holiday")
+	}
+	// The built-in table is not used once a file is loaded.
+	if c.isHoliday(time.Date(2025, 1, 1, 8, 0, 0, 0, time.Local)) {
+		t.Errorf("2025-01-01 is not in the file")
+	}
+
+	bad := HolidayConfig{Enabled: true, TreatAs: "sunday"}
+	if err := bad.validate(); err == nil || !strings.Contains(err.Error(), "treat_holidays_as") {
+		t.Errorf("validate with unknown treat_holidays_as = %v", err)
+	}
+}
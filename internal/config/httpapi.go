@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+	"net"
+)
+
+// HTTPAPIConfig は、状態の取得と手動操作を HTTP で受け付ける API の設定です。
+// 手動操作は UNIX ドメインソケットのコマンドと同じですが、自動制御と同じモード変更の抑制時間と最低リザーブを適用します。
+type HTTPAPIConfig struct {
+	Enabled bool   `toml:"enabled"`
+	Listen  string `toml:"listen"`   // 待ち受けるアドレス (デフォルト: "127.0.0.1:8080")
+	Token   Secret `toml:"token"`    // Authorization: Bearer で指定するトークン。"env:" または "file:" で参照できる
+	ELWA    bool   `toml:"elwa"`     // true の場合は ECHONET Lite Web API (ELWA) の読み取り専用のブリッジ (/elapi/v1/) を有効にする
+	NodeRED bool   `toml:"node_red"` // true の場合は Node-RED 向けのイベント (/nodered/events) とコマンド (/nodered/command) を有効にする
+}
+
+// validate は、HTTPAPIConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (c *HTTPAPIConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Listen == "" {
+		c.Listen = "127.0.0.1:8080"
+	}
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		return fmt.Errorf("'http_api.listen' ('%s') は「ホスト:ポート」の形式である必要があります: %w", c.Listen, err)
+	}
+	token, err := c.Token.resolve()
+	if err != nil {
+		return fmt.Errorf("'http_api.token' を読み込めませんでした: %w", err)
+	}
+	if token == "" {
+		return fmt.Errorf("'http_api.enabled' が true の場合は 'http_api.token' の設定が必要です")
+	}
+	c.Token = token
+	return nil
+}
@@ -0,0 +1,24 @@
+package config
+
+import "testing"
+
+func TestHTTPAPIConfigValidate(t *testing.T) {
+	c := HTTPAPIConfig{Enabled: true, Token: "secret"}
+	if err := c.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Listen != "127.0.0.1:8080" {
+		t.Errorf("listen = %q, want the default", c.Listen)
+	}
+	for _, bad := range []HTTPAPIConfig{
+		{Enabled: true},
+		{Enabled: true, Token: "secret", Listen: "8080"},
+	} {
+		if err := bad.validate(); err == nil {
+			t.Errorf("validate(%+v) succeeded", bad)
+		}
+	}
+	if err := (&HTTPAPIConfig{}).validate(); err != nil {
+		t.Errorf("disabled config: %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+package config
+
+import "fmt"
+
+// ImportGuardConfig は、分電盤で計測した買電電力が上限を超えた場合に充電電力を下げる設定です。
+// 契約アンペアのブレーカーが落ちないよう、充電時間帯や他の制御にかかわらず優先して適用します。
+type ImportGuardConfig struct {
+	Enabled    bool `toml:"enabled"`
+	LimitWatts int  `toml:"limit_watts"` // 買電電力の上限 (W)。契約容量から余裕を差し引いた値を指定する
+	StepWatts  int  `toml:"step_watts"`  // 1回の監視サイクルで下げる充電電力 (W)
+}
+
+// Validate は、ImportGuardConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (c *ImportGuardConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.LimitWatts <= 0 {
+		return fmt.Errorf("'import_guard' を有効にするには 'import_guard.limit_watts' の設定が必要です")
+	}
+	if c.StepWatts <= 0 {
+		c.StepWatts = 500
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package config
+
+import "fmt"
+
+// LogFileConfig は、ログをファイルに出力する場合の設定です。
+// ファイルが max_size_mb を超えた場合、または rotate_interval_hours が経過した場合にローテーションし、
+// ローテーションしたファイルは max_backups 個、max_age_days 日まで残します。
+type LogFileConfig struct {
+	Enabled             bool   `toml:"enabled"`
+	Path                string `toml:"path"`                  // 出力先のファイル (デフォルト: "eibs7-controller.log")
+	MaxSizeMB           int    `toml:"max_size_mb"`           // ローテーションするファイルサイズ (MB、デフォルト: 10)
+	RotateIntervalHours int    `toml:"rotate_interval_hours"` // ファイルを開いてからローテーションするまでの時間 (時間)。0 の場合はサイズのみで判定する
+	MaxBackups          int    `toml:"max_backups"`           // 残すローテーション済みのファイルの数 (デフォルト: 5)
+	MaxAgeDays          int    `toml:"max_age_days"`          // ローテーション済みのファイルを残す日数。0 の場合は日数では削除しない
+}
+
+// Validate は、LogFileConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (c *LogFileConfig) Validate() error {
+	if c.Path == "" {
+		c.Path = "eibs7-controller.log"
+	}
+	if c.MaxSizeMB == 0 {
+		c.MaxSizeMB = 10
+	}
+	if c.MaxBackups == 0 {
+		c.MaxBackups = 5
+	}
+	if c.MaxSizeMB < 0 || c.RotateIntervalHours < 0 || c.MaxBackups < 0 || c.MaxAgeDays < 0 {
+		return fmt.Errorf("'log_file' の max_size_mb, rotate_interval_hours, max_backups, max_age_days は 0 以上である必要があります")
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"fmt"
+	"net"
+)
+
+// ModbusConfig は、蓄電残量や電力、運転モードを Modbus TCP のレジスタとして公開する設定です。
+// ECHONET Lite に対応していない監視システムや EMS から参照できます。
+type ModbusConfig struct {
+	Enabled     bool   `toml:"enabled"`
+	Listen      string `toml:"listen"`       // 待ち受けるアドレス (デフォルト: "127.0.0.1:5020")
+	AllowWrites bool   `toml:"allow_writes"` // true の場合は保持レジスタへの書き込みで手動操作を受け付ける (デフォルト: 読み取り専用)
+}
+
+// validate は、ModbusConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (c *ModbusConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Listen == "" {
+		c.Listen = "127.0.0.1:5020"
+	}
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		return fmt.Errorf("'modbus.listen' ('%s') は「ホスト:ポート」の形式である必要があります: %w", c.Listen, err)
+	}
+	return nil
+}
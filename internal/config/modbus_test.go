@@ -0,0 +1,13 @@
+package config
+
+import "testing"
+
+func TestModbusConfigValidate(t *testing.T) {
+	c := ModbusConfig{Enabled: true}
+	if err := c.validate(); err != nil || c.Listen != "127.0.0.1:5020" {
+		t.Errorf("validate = %v, listen = %q", err, c.Listen)
+	}
+	if err := (&ModbusConfig{Enabled: true, Listen: "5020"}).validate(); err == nil {
+		t.Error("listen without a host accepted")
+	}
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// MQTTConfig は、監視データと制御の状態を MQTT で配信する設定です。
+type MQTTConfig struct {
+	Enabled     bool   `toml:"enabled"`
+	Broker      string `toml:"broker"`    // 接続先 (例: "tcp://192.168.0.5:1883", TLS の場合は "ssl://...:8883")
+	ClientID    string `toml:"client_id"` // デフォルト: "eibs7-controller"
+	Username    string `toml:"username"`
+	Password    Secret `toml:"password"`     // "env:" または "file:" で参照できる
+	TopicPrefix string `toml:"topic_prefix"` // トピックの接頭辞 (デフォルト: "eibs7")
+	QoS         int    `toml:"qos"`          // 0, 1, 2 のいずれか
+	Retain      bool   `toml:"retain"`       // true の場合、最後の値をブローカーに保持させる
+
+	// TLS (broker が ssl:// または tls:// の場合に使用)
+	CAFile             string `toml:"ca_file"`              // ブローカーの証明書を検証する CA 証明書 (未設定の場合はシステムの CA)
+	CertFile           string `toml:"cert_file"`            // クライアント証明書 (key_file と組み合わせて使用)
+	KeyFile            string `toml:"key_file"`             // クライアント証明書の秘密鍵
+	InsecureSkipVerify bool   `toml:"insecure_skip_verify"` // true の場合、ブローカーの証明書を検証しない
+}
+
+// validate は、MQTTConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (c *MQTTConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Broker == "" {
+		return fmt.Errorf("'mqtt.enabled' が true の場合は 'mqtt.broker' の設定が必要です")
+	}
+	if c.ClientID == "" {
+		c.ClientID = "eibs7-controller"
+	}
+	if c.TopicPrefix == "" {
+		c.TopicPrefix = "eibs7"
+	}
+	c.TopicPrefix = strings.TrimSuffix(c.TopicPrefix, "/")
+	if strings.ContainsAny(c.TopicPrefix, "+#") {
+		return fmt.Errorf("'mqtt.topic_prefix' ('%s') にワイルドカード (+, #) は使用できません", c.TopicPrefix)
+	}
+	if c.QoS < 0 || c.QoS > 2 {
+		return fmt.Errorf("'mqtt.qos' (%d) は 0, 1, 2 のいずれかである必要があります", c.QoS)
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("'mqtt.cert_file' と 'mqtt.key_file' は両方を設定する必要があります")
+	}
+	password, err := c.Password.resolve()
+	if err != nil {
+		return fmt.Errorf("'mqtt.password' を読み込めませんでした: %w", err)
+	}
+	c.Password = password
+	return nil
+}
+
+// TLSConfig は、CA 証明書とクライアント証明書の設定から TLS の設定を作成します。
+func (c *MQTTConfig) TLSConfig() (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("'mqtt.ca_file' の読み込みに失敗しました: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("'mqtt.ca_file' ('%s') に PEM 形式の証明書が含まれていません", c.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("'mqtt.cert_file' と 'mqtt.key_file' の読み込みに失敗しました: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
@@ -0,0 +1,23 @@
+package config
+
+import "testing"
+
+func TestMQTTConfigValidate(t *testing.T) {
+	c := MQTTConfig{Enabled: true, Broker: "tcp://localhost:1883", TopicPrefix: "home/eibs7/"}
+	if err := c.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if c.TopicPrefix != "home/eibs7" || c.ClientID != "eibs7-controller" {
+		t.Errorf("defaults = %+v", c)
+	}
+	for _, bad := range []MQTTConfig{
+		{Enabled: true},
+		{Enabled: true, Broker: "tcp://localhost:1883", QoS: 3},
+		{Enabled: true, Broker: "tcp://localhost:1883", TopicPrefix: "home/#"},
+		{Enabled: true, Broker: "ssl://localhost:8883", CertFile: "client.pem"},
+	} {
+		if err := bad.validate(); err == nil {
+			t.Errorf("validate(%+v) succeeded, want error", bad)
+		}
+	}
+}
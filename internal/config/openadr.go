@@ -0,0 +1,120 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// OpenADR のイベントに対する制御
+const (
+	OpenADRNone        = "none"         // 何もしない
+	OpenADRLimitCharge = "limit_charge" // 充電電力を limit_charge_watts に制限する
+	OpenADRDischarge   = "discharge"    // 放電モードにして放電する (ピークカット)
+)
+
+// OpenADRConfig は、OpenADR 2.0b の VEN として VTN からデマンドレスポンスのイベントを受信する設定です。
+// VTN に定期的にイベントを問い合わせ (oadrRequestEvent)、SIMPLE シグナルのレベルに応じた制御をイベントの期間中に行います。
+type OpenADRConfig struct {
+	Enabled             bool   `toml:"enabled"`
+	VTNURL              string `toml:"vtn_url"`               // VTN のサービスの URL (例: "https://vtn.example.com/OpenADR2/Simple/2.0b")
+	VENID               string `toml:"ven_id"`                // VTN に登録した VEN ID
+	CAFile              string `toml:"ca_file"`               // VTN の証明書を検証する CA 証明書 (未設定の場合はシステムの CA)
+	CertFile            string `toml:"cert_file"`             // クライアント証明書 (key_file と組み合わせて使用)
+	KeyFile             string `toml:"key_file"`              // クライアント証明書の秘密鍵
+	PollIntervalSeconds int    `toml:"poll_interval_seconds"` // イベントを問い合わせる間隔 (秒、デフォルト: 60)
+	Moderate            string `toml:"moderate"`              // SIMPLE レベル 1 の制御 (デフォルト: "limit_charge")
+	High                string `toml:"high"`                  // SIMPLE レベル 2 の制御 (デフォルト: "discharge")
+	Special             string `toml:"special"`               // SIMPLE レベル 3 の制御 (デフォルト: "discharge")
+	LimitChargeWatts    int    `toml:"limit_charge_watts"`    // "limit_charge" の充電電力の上限 (W、デフォルト: 0 = 充電しない)
+	DischargeWatts      int    `toml:"discharge_watts"`       // "discharge" の放電電力 (W)。0 の場合は家庭の消費電力に合わせる (逆潮流しない)
+	OptOut              bool   `toml:"opt_out"`               // true の場合はすべてのイベントに optOut で応答し、制御を行わない
+}
+
+// Validate は、OpenADRConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (c *OpenADRConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if u, err := url.Parse(c.VTNURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("'openadr.vtn_url' ('%s') は http または https の URL である必要があります", c.VTNURL)
+	}
+	c.VTNURL = strings.TrimSuffix(c.VTNURL, "/")
+	if c.VENID == "" {
+		return fmt.Errorf("'openadr.enabled' が true の場合は 'openadr.ven_id' の設定が必要です")
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("'openadr.cert_file' と 'openadr.key_file' は両方を設定する必要があります")
+	}
+	if _, err := c.TLSConfig(); err != nil {
+		return err
+	}
+	if c.PollIntervalSeconds == 0 {
+		c.PollIntervalSeconds = 60
+	}
+	if c.PollIntervalSeconds < 0 {
+		return fmt.Errorf("'openadr.poll_interval_seconds' (%d) は 1 以上である必要があります", c.PollIntervalSeconds)
+	}
+	for _, a := range []struct {
+		key    string
+		action *string
+		def    string
+	}{
+		{"moderate", &c.Moderate, OpenADRLimitCharge},
+		{"high", &c.High, OpenADRDischarge},
+		{"special", &c.Special, OpenADRDischarge},
+	} {
+		switch *a.action {
+		case "":
+			*a.action = a.def
+		case OpenADRNone, OpenADRLimitCharge, OpenADRDischarge:
+		default:
+			return fmt.Errorf("'openadr.%s' ('%s') は \"%s\"、\"%s\"、\"%s\" のいずれかである必要があります", a.key, *a.action, OpenADRNone, OpenADRLimitCharge, OpenADRDischarge)
+		}
+	}
+	if c.LimitChargeWatts < 0 || c.DischargeWatts < 0 {
+		return fmt.Errorf("'openadr.limit_charge_watts' と 'openadr.discharge_watts' は 0 以上である必要があります")
+	}
+	return nil
+}
+
+// TLSConfig は、CA 証明書とクライアント証明書の設定から TLS の設定を作成します。
+func (c *OpenADRConfig) TLSConfig() (*tls.Config, error) {
+	config := &tls.Config{}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("'openadr.ca_file' の読み込みに失敗しました: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("'openadr.ca_file' ('%s') に PEM 形式の証明書が含まれていません", c.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("'openadr.cert_file' と 'openadr.key_file' の読み込みに失敗しました: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// Action は、SIMPLE シグナルのレベルに対する制御を返します。
+func (c OpenADRConfig) Action(level int) string {
+	switch {
+	case level >= 3:
+		return c.Special
+	case level == 2:
+		return c.High
+	case level == 1:
+		return c.Moderate
+	default:
+		return OpenADRNone
+	}
+}
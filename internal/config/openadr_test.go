@@ -0,0 +1,24 @@
+package config
+
+import "testing"
+
+func TestOpenADRConfigValidate(t *testing.T) {
+	c := OpenADRConfig{Enabled: true, VTNURL: "https://vtn.example.com", VENID: "ven"}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if c.PollIntervalSeconds != 60 || c.Moderate != OpenADRLimitCharge || c.High != OpenADRDischarge || c.Special != OpenADRDischarge {
+		t.Errorf("defaults = %+v", c)
+	}
+	for _, bad := range []OpenADRConfig{
+		{Enabled: true, VENID: "ven"},
+		{Enabled: true, VTNURL: "https://vtn.example.com"},
+		{Enabled: true, VTNURL: "https://vtn.example.com", VENID: "ven", CertFile: "cert.pem"},
+		{Enabled: true, VTNURL: "https://vtn.example.com", VENID: "ven", High: "shed"},
+		{Enabled: true, VTNURL: "https://vtn.example.com", VENID: "ven", DischargeWatts: -1},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", bad)
+		}
+	}
+}
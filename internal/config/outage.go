@@ -0,0 +1,36 @@
+package config
+
+import "fmt"
+
+// outage.policy の値
+const (
+	OutagePolicySuspend   = "suspend"    // 蓄電池には何も設定せず、機器の自立運転に任せる
+	OutagePolicyHoldSOC   = "hold_soc"   // 蓄電池を待機モードにして、蓄電残量を温存する
+	OutagePolicyLoadsOnly = "loads_only" // 蓄電池を自動モードにして家庭の負荷への放電のみ行い、hold_soc_percent 以下では待機モードにする
+)
+
+// OutageConfig は、停電 (自立運転) を検出した場合の動作の設定です。
+type OutageConfig struct {
+	Policy             string `toml:"policy"`               // "suspend", "hold_soc", "loads_only" のいずれか (デフォルト: "suspend")
+	HoldSOCPercent     int    `toml:"hold_soc_percent"`     // loads_only で放電を止める蓄電残量 (%)。0 の場合は止めない
+	ResumeAfterMinutes int    `toml:"resume_after_minutes"` // 系統の復旧後、この時間 (分) 停電がなければ自動制御を再開する。0 の場合は直ちに再開する
+}
+
+// validate は、OutageConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (c *OutageConfig) validate() error {
+	if c.Policy == "" {
+		c.Policy = OutagePolicySuspend
+	}
+	switch c.Policy {
+	case OutagePolicySuspend, OutagePolicyHoldSOC, OutagePolicyLoadsOnly:
+	default:
+		return fmt.Errorf("'outage.policy' ('%s') は \"suspend\", \"hold_soc\", \"loads_only\" のいずれかである必要があります", c.Policy)
+	}
+	if c.HoldSOCPercent < 0 || c.HoldSOCPercent > 100 {
+		return fmt.Errorf("'outage.hold_soc_percent' (%d) は 0 から 100 の範囲である必要があります", c.HoldSOCPercent)
+	}
+	if c.ResumeAfterMinutes < 0 {
+		return fmt.Errorf("'outage.resume_after_minutes' (%d) は 0 以上である必要があります", c.ResumeAfterMinutes)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package config
+
+import "testing"
+
+func TestOutageConfigValidate(t *testing.T) {
+	var c OutageConfig
+	if err := c.validate(); err != nil || c.Policy != OutagePolicySuspend {
+		t.Errorf("validate of an empty config = %v, policy %q", err, c.Policy)
+	}
+	for _, bad := range []OutageConfig{
+		{Policy: "discharge"},
+		{Policy: OutagePolicyLoadsOnly, HoldSOCPercent: 101},
+		{ResumeAfterMinutes: -1},
+	} {
+		if err := bad.validate(); err == nil {
+			t.Errorf("validate(%+v) succeeded, want error", bad)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package config
+
+// OverrideConfig は、実行中に自動制御を一時的に上書きする手動操作の設定です。
+// UNIX ドメインソケットに1行のコマンドを送信して操作します (例: echo "charge 60" | nc -U /tmp/eibs7-controller.sock)。
+type OverrideConfig struct {
+	Enabled bool   `toml:"enabled"`
+	Socket  string `toml:"socket"` // コマンドを受け付ける UNIX ドメインソケットのパス
+}
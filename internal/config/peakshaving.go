@@ -0,0 +1,51 @@
+package config
+
+import "fmt"
+
+// PeakShavingConfig は、夕方などの需要のピーク時間帯に、買電電力が閾値を超えないよう蓄電池を放電させる設定です。
+// 閾値を超えた分だけ放電モードで放電し、閾値以下の間は待機モードにして蓄電残量を温存します。
+type PeakShavingConfig struct {
+	Enabled              bool `toml:"enabled"`
+	TimeSlot                  // ピークカットを行う時間帯
+	ImportThresholdWatts int  `toml:"import_threshold_watts"` // 買電電力の閾値 (W)
+	MinSOCPercent        int  `toml:"min_soc_percent"`        // この蓄電残量 (%) 以下では放電しない
+	MaxDischargeWatts    int  `toml:"max_discharge_watts"`    // 放電電力の上限 (W)
+	UpdateStepWatts      int  `toml:"update_step_watts"`      // 前回の設定値との差がこの値未満の場合は更新しない (W)
+}
+
+// Validate は、PeakShavingConfig にデフォルト値を設定し、値の妥当性を確認します。
+// 放電電力の設定は、strategy_periods で peak_shave を使用する場合のため、無効な場合も設定します。
+func (c *PeakShavingConfig) Validate() error {
+	if c.MaxDischargeWatts <= 0 {
+		c.MaxDischargeWatts = 3000
+	}
+	if c.UpdateStepWatts <= 0 {
+		c.UpdateStepWatts = 100
+	}
+	if !c.Enabled {
+		return nil
+	}
+	if err := c.TimeSlot.Validate(); err != nil {
+		return fmt.Errorf("'peak_shaving' の時間帯が不正です: %w", err)
+	}
+	if c.ImportThresholdWatts < 0 {
+		return fmt.Errorf("'peak_shaving.import_threshold_watts' (%d) は 0 以上である必要があります", c.ImportThresholdWatts)
+	}
+	if c.MinSOCPercent < 0 || c.MinSOCPercent > 100 {
+		return fmt.Errorf("'peak_shaving.min_soc_percent' (%d) は 0 から 100 の範囲である必要があります", c.MinSOCPercent)
+	}
+	return nil
+}
+
+// PeakShavingDischargePower は、買電電力を閾値以下にするために必要な放電電力 (W) を返します。
+// importWatts は現在の放電を反映した買電電力のため、現在の放電電力 currentDischargeWatts に超過分 (または余裕分) を加減します。
+func (c PeakShavingConfig) PeakShavingDischargePower(importWatts, currentDischargeWatts int) int {
+	power := currentDischargeWatts + importWatts - c.ImportThresholdWatts
+	if power > c.MaxDischargeWatts {
+		power = c.MaxDischargeWatts
+	}
+	if power < 0 {
+		power = 0
+	}
+	return power
+}
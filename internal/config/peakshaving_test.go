@@ -0,0 +1,21 @@
+package config
+
+import "testing"
+
+func TestPeakShavingDischargePower(t *testing.T) {
+	cfg := PeakShavingConfig{ImportThresholdWatts: 2000, MaxDischargeWatts: 3000}
+	for _, tc := range []struct {
+		importW, discharge, want int
+	}{
+		{1500, 0, 0},      // under the threshold
+		{2600, 0, 600},    // start discharging the excess
+		{2100, 600, 700},  // still over while discharging: discharge more
+		{1800, 700, 500},  // headroom: discharge less
+		{6000, 500, 3000}, // capped
+		{500, 300, 0},     // load dropped: stop
+	} {
+		if got := cfg.PeakShavingDischargePower(tc.importW, tc.discharge); got != tc.want {
+			t.Errorf("PeakShavingDischargePower(%d, %d) = %d, want %d", tc.importW, tc.discharge, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// ChargePlanningConfig は、発電量予測に基づいて充電時間帯の目標の蓄電残量を下げる設定です。
+// 夜間に系統から充電する場合、充電時間帯の後に見込まれる太陽光の余剰分は充電しないようにします。
+type ChargePlanningConfig struct {
+	Enabled               bool    `toml:"enabled"`
+	ForecastUntil         string  `toml:"forecast_until"`          // 充電時間帯の終了後、発電量を集計する終了時刻 (HH:MM)
+	DaytimeConsumptionKWh float64 `toml:"daytime_consumption_kwh"` // 同じ期間に見込まれる家庭の消費電力量 (kWh)
+	MinTargetSOCPercent   int     `toml:"min_target_soc_percent"`  // 目標の蓄電残量を下げる場合の下限 (%)
+}
+
+// Validate は、ChargePlanningConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (c *ChargePlanningConfig) Validate(forecast ForecastConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.ForecastUntil == "" {
+		c.ForecastUntil = "15:00"
+	}
+	if _, err := time.Parse("15:04", c.ForecastUntil); err != nil {
+		return fmt.Errorf("'charge_planning.forecast_until' の形式が不正です ('%s')", c.ForecastUntil)
+	}
+	if c.DaytimeConsumptionKWh < 0 {
+		return fmt.Errorf("'charge_planning.daytime_consumption_kwh' (%.2f) は 0 以上である必要があります", c.DaytimeConsumptionKWh)
+	}
+	if c.MinTargetSOCPercent < 0 || c.MinTargetSOCPercent > 100 {
+		return fmt.Errorf("'charge_planning.min_target_soc_percent' (%d) は 0 から 100 の範囲である必要があります", c.MinTargetSOCPercent)
+	}
+	if !forecast.configured() {
+		return fmt.Errorf("'charge_planning' を有効にするには発電量予測 ('forecast') の設定が必要です")
+	}
+	return nil
+}
+
+// NextOccurrence は、now より後で最初に hhmm (HH:MM) になる時刻を返します。
+func NextOccurrence(now time.Time, hhmm string) time.Time {
+	t, _ := time.Parse("15:04", hhmm)
+	y, m, d := now.Date()
+	next := time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
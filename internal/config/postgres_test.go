@@ -0,0 +1,24 @@
+package config
+
+import "testing"
+
+func TestPostgresConfigValidate(t *testing.T) {
+	t.Setenv("EIBS7_TEST_POSTGRES_URL", "postgres://eibs7@localhost/home")
+	c := PostgresConfig{Enabled: true, URL: "env:EIBS7_TEST_POSTGRES_URL"}
+	if err := c.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if c.URL != "postgres://eibs7@localhost/home" || c.TablePrefix != "eibs7_" || c.MaxConnections != 4 || c.QueueSize != 100 {
+		t.Errorf("defaults = %+v", c)
+	}
+
+	for _, bad := range []PostgresConfig{
+		{Enabled: true},
+		{Enabled: true, URL: "postgres://localhost/home", TablePrefix: "eibs7; DROP TABLE x; --"},
+		{Enabled: true, URL: "postgres://localhost/home", MaxConnections: -1},
+	} {
+		if err := bad.validate(); err == nil {
+			t.Errorf("validate(%+v) succeeded, want error", bad)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package config
+
+import "fmt"
+
+// PriceScheduleConfig は、電力の単価表 (JEPX のスポット価格など) に基づいて充電する時間帯を選ぶ設定です。
+type PriceScheduleConfig struct {
+	Enabled     bool   `toml:"enabled"`
+	File        string `toml:"file"`         // 単価表の CSV ファイル (受渡日, 時刻コード, ..., 単価)
+	PriceColumn int    `toml:"price_column"` // 単価の列番号 (0 始まり)
+}
+
+// JEPX のスポット市場の CSV でシステムプライスが格納されている列番号
+const defaultPriceColumn = 5
+
+// Validate は、PriceScheduleConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (c *PriceScheduleConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.File == "" {
+		return fmt.Errorf("'price_schedule' を有効にするには 'price_schedule.file' の設定が必要です")
+	}
+	if c.PriceColumn <= 0 {
+		c.PriceColumn = defaultPriceColumn
+	}
+	if c.PriceColumn < 2 {
+		return fmt.Errorf("'price_schedule.price_column' (%d) は受渡日と時刻コードの列 (0, 1) 以外である必要があります", c.PriceColumn)
+	}
+	return nil
+}
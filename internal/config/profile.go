@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Profile は、名前を付けた運転プロファイルです。
+// 夏季と冬季など、時期や曜日によって異なる閾値・上限・目標の蓄電残量・制御方式を1つの設定ファイルで切り替えるために使用します。
+// 未設定 (0 または空) の項目は通常の設定を使用します。
+type Profile struct {
+	ChargeModeThresholdWatts int    `toml:"charge_mode_threshold_watts"` // 「充電」に切り替える余剰電力 (W)
+	AutoModeThresholdWatts   int    `toml:"auto_mode_threshold_watts"`   // 「自動」に切り替える余剰電力 (W)
+	MaxChargePowerWatts      int    `toml:"max_charge_power_watts"`      // 最大充電電力 (W)
+	SurplusPowerMarginWatts  int    `toml:"surplus_power_margin_watts"`  // 余剰電力の余力 (W)
+	ChargeTargetSOCPercent   int    `toml:"charge_target_soc_percent"`   // 充電時間帯の目標の蓄電残量 (%)
+	Strategy                 string `toml:"strategy"`                    // 制御方式
+}
+
+// validate は、プロファイルの値の妥当性を確認します。
+func (p Profile) validate() error {
+	if p.ChargeModeThresholdWatts < 0 || p.AutoModeThresholdWatts < 0 || p.MaxChargePowerWatts < 0 || p.SurplusPowerMarginWatts < 0 {
+		return fmt.Errorf("電力は 0 以上である必要があります")
+	}
+	if p.ChargeModeThresholdWatts > 0 && p.AutoModeThresholdWatts > 0 && p.ChargeModeThresholdWatts < p.AutoModeThresholdWatts {
+		return fmt.Errorf("'charge_mode_threshold_watts' (%d) は 'auto_mode_threshold_watts' (%d) 以上である必要があります", p.ChargeModeThresholdWatts, p.AutoModeThresholdWatts)
+	}
+	if p.ChargeTargetSOCPercent < 0 || p.ChargeTargetSOCPercent > 100 {
+		return fmt.Errorf("'charge_target_soc_percent' (%d) は 0 から 100 の範囲である必要があります", p.ChargeTargetSOCPercent)
+	}
+	if p.Strategy != "" {
+		return validateStrategy(p.Strategy)
+	}
+	return nil
+}
+
+// weekdayNames は、profile_schedule の weekdays に指定できる曜日の名前です。
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ProfileRule は、運転プロファイルを有効にする曜日・期間・時間帯です。
+// 未設定の条件は常に一致します (例: weekdays を省略すると毎日)。
+type ProfileRule struct {
+	Profile  string   `toml:"profile"`  // 有効にするプロファイルの名前
+	Weekdays []string `toml:"weekdays"` // 曜日 (sun, mon, tue, wed, thu, fri, sat)
+	DateRange
+	TimeSlot
+}
+
+// validate は、プロファイルの名前と各条件の形式を確認します。
+func (r ProfileRule) validate(profiles map[string]Profile) error {
+	if _, ok := profiles[r.Profile]; !ok {
+		return fmt.Errorf("プロファイル '%s' が定義されていません", r.Profile)
+	}
+	for _, d := range r.Weekdays {
+		if _, ok := weekdayNames[strings.ToLower(d)]; !ok {
+			return fmt.Errorf("曜日 '%s' は不正です (sun, mon, tue, wed, thu, fri, sat のいずれかを指定してください)", d)
+		}
+	}
+	if r.DateRange != (DateRange{}) {
+		if err := r.DateRange.validate(); err != nil {
+			return err
+		}
+	}
+	if r.TimeSlot != (TimeSlot{}) {
+		return r.TimeSlot.Validate()
+	}
+	return nil
+}
+
+// matches は、指定された日時がすべての条件に一致すれば true を返します。
+func (r ProfileRule) matches(now time.Time) (bool, error) {
+	if len(r.Weekdays) > 0 {
+		found := false
+		for _, d := range r.Weekdays {
+			if weekdayNames[strings.ToLower(d)] == now.Weekday() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	if r.DateRange != (DateRange{}) && !r.ContainsDate(now) {
+		return false, nil
+	}
+	if r.TimeSlot != (TimeSlot{}) {
+		return r.TimeSlot.Contains(now)
+	}
+	return true, nil
+}
+
+// ActiveProfile は、指定された日時に有効な運転プロファイルの名前と設定を返します。
+// profile_schedule のうち最初に一致したものを使用し、一致しない場合は名前が空の Profile{} を返します。
+func (c *Config) ActiveProfile(now time.Time) (string, Profile) {
+	for _, r := range c.ProfileSchedule {
+		// 時間帯の形式は読み込み時に検証済みのため、判定のエラーは一致しないものとして扱う
+		if ok, err := r.matches(now); err == nil && ok {
+			return r.Profile, c.Profiles[r.Profile]
+		}
+	}
+	return "", Profile{}
+}
+
+// ModeThresholds は、指定された日時に適用する「充電」と「自動」に切り替える余剰電力の閾値 (W) を返します。
+func (c *Config) ModeThresholds(now time.Time) (chargeModeWatts, autoModeWatts int) {
+	chargeModeWatts, autoModeWatts = c.ChargeModeThresholdWatts, c.AutoModeThresholdWatts
+	_, p := c.ActiveProfile(now)
+	if p.AutoModeThresholdWatts > 0 {
+		autoModeWatts = p.AutoModeThresholdWatts
+	}
+	if p.ChargeModeThresholdWatts > 0 {
+		chargeModeWatts = p.ChargeModeThresholdWatts
+	}
+	// 一方のみ変更した場合に「充電」の閾値が「自動」の閾値を下回らないようにする
+	if chargeModeWatts < autoModeWatts {
+		chargeModeWatts = autoModeWatts
+	}
+	return chargeModeWatts, autoModeWatts
+}
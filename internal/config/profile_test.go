@@ -0,0 +1,138 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testProfileConfig() *Config {
+	return &Config{
+		ChargeModeThresholdWatts: 1000,
+		AutoModeThresholdWatts:   500,
+		MaxChargePowerWatts:      3000,
+		SurplusPowerMarginWatts:  500,
+		ChargeTargetSOCPercent:   100,
+		Strategy:                 "charge_schedule",
+		Profiles: map[string]Profile{
+			"winter":  {MaxChargePowerWatts: 2000, ChargeTargetSOCPercent: 90, Strategy: "self_consumption"},
+			"weekend": {AutoModeThresholdWatts: 1500},
+		},
+		ProfileSchedule: []ProfileRule{
+			{Profile: "weekend", Weekdays: []string{"sat", "Sun"}},
+			{Profile: "winter", DateRange: DateRange{Start: "12-01", End: "02-28"}, TimeSlot: TimeSlot{StartTime: "06:00", EndTime: "18:00"}},
+		},
+	}
+}
+
+func TestActiveProfile(t *testing.T) {
+	cfg := testProfileConfig()
+	for _, tc := range []struct {
+		at   string
+		want string
+	}{
+		{"2025-06-02 10:00", ""},        // Monday in June
+		{"2025-06-07 10:00", "weekend"}, // Saturday
+		{"2026-01-05 10:00", "winter"},  // Monday in January
+		{"2026-01-05 20:00", ""},        // outside the winter time slot
+		{"2026-01-04 10:00", "weekend"}, // Sunday in January: the first rule wins
+	} {
+		now, err := time.ParseInLocation("2006-01-02 15:04", tc.at, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := cfg.ActiveProfile(now); got != tc.want {
+			t.Errorf("ActiveProfile(%s) = %q, want %q", tc.at, got, tc.want)
+		}
+	}
+}
+
+func TestProfileOverridesSettings(t *testing.T) {
+	cfg := testProfileConfig()
+	winter := time.Date(2026, 1, 5, 10, 0, 0, 0, time.Local)
+	if maxWatts, margin := cfg.ChargePowerLimits(winter); maxWatts != 2000 || margin != 500 {
+		t.Errorf("ChargePowerLimits = %d W / %d W, want 2000 W / 500 W", maxWatts, margin)
+	}
+	if got := cfg.ChargeTimes(winter).TargetSOCPercent; got != 90 {
+		t.Errorf("TargetSOCPercent = %d, want 90", got)
+	}
+	if got, _ := cfg.StrategyName(winter); got != "self_consumption" {
+		t.Errorf("StrategyName = %q, want self_consumption", got)
+	}
+
+	// Raising only the auto threshold lifts the charge threshold with it.
+	weekend := time.Date(2025, 6, 7, 10, 0, 0, 0, time.Local)
+	if charge, auto := cfg.ModeThresholds(weekend); charge != 1500 || auto != 1500 {
+		t.Errorf("ModeThresholds = %d W / %d W, want 1500 W / 1500 W", charge, auto)
+	}
+	if got, _ := cfg.StrategyName(weekend); got != "charge_schedule" {
+		t.Errorf("StrategyName = %q, want charge_schedule", got)
+	}
+
+	// Seasonal caps still take precedence over the profile.
+	cfg.SeasonalChargeCaps = []SeasonalChargeCap{{DateRange: DateRange{Start: "01-01", End: "01-31"}, MaxChargePowerWatts: 1000}}
+	if maxWatts, _ := cfg.ChargePowerLimits(winter); maxWatts != 1000 {
+		t.Errorf("ChargePowerLimits = %d W with a seasonal cap, want 1000 W", maxWatts)
+	}
+}
+
+func TestProfileValidate(t *testing.T) {
+	profiles := map[string]Profile{"summer": {}}
+	if err := (ProfileRule{Profile: "summer", Weekdays: []string{"mon"}}).validate(profiles); err != nil {
+		t.Errorf("valid rule rejected: %v", err)
+	}
+	for _, bad := range []ProfileRule{
+		{Profile: "autumn"},
+		{Profile: "summer", Weekdays: []string{"monday"}},
+		{Profile: "summer", DateRange: DateRange{Start: "6/1", End: "08-31"}},
+		{Profile: "summer", TimeSlot: TimeSlot{StartTime: "25:00", EndTime: "10:00"}},
+	} {
+		if err := bad.validate(profiles); err == nil {
+			t.Errorf("validate(%+v) succeeded", bad)
+		}
+	}
+	for _, bad := range []Profile{
+		{ChargeModeThresholdWatts: 500, AutoModeThresholdWatts: 1000},
+		{ChargeTargetSOCPercent: 101},
+		{MaxChargePowerWatts: -1},
+		{Strategy: "unknown"},
+	} {
+		if err := bad.validate(); err == nil {
+			t.Errorf("validate(%+v) succeeded", bad)
+		}
+	}
+}
+
+func TestLoadConfigProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `target_ip = "192.168.0.10"
+
+[profiles.summer]
+max_charge_power_watts = 1500
+strategy = "self_consumption"
+
+[[profile_schedule]]
+profile = "summer"
+start = "07-01"
+end = "09-30"
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if got := cfg.Profiles["summer"].MaxChargePowerWatts; got != 1500 {
+		t.Errorf("profiles.summer.max_charge_power_watts = %d, want 1500", got)
+	}
+
+	bad := content + "\n[[profile_schedule]]\nprofile = \"winter\"\n"
+	if err := os.WriteFile(path, []byte(bad), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Load accepted a schedule for an undefined profile")
+	}
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// EveningReserveConfig は、夕方の放電時間帯に残しておく蓄電残量 (リザーブ) の設定です。
+// 翌朝の発電量予測が少ないほどリザーブを大きくし、曇りの朝に備えて放電を控えます。
+type EveningReserveConfig struct {
+	Enabled           bool    `toml:"enabled"`
+	StartTime         string  `toml:"start_time"`          // 夕方の放電時間帯の開始時刻 (HH:MM)
+	EndTime           string  `toml:"end_time"`            // 夕方の放電時間帯の終了時刻 (HH:MM)
+	MorningEndTime    string  `toml:"morning_end_time"`    // 翌朝の発電量を集計する終了時刻 (HH:MM)
+	MinReservePercent int     `toml:"min_reserve_percent"` // 翌朝が十分に晴れる場合のリザーブ (%)
+	MaxReservePercent int     `toml:"max_reserve_percent"` // 翌朝の発電が見込めない場合のリザーブ (%)
+	SunnyMorningKWh   float64 `toml:"sunny_morning_kwh"`   // 十分に晴れているとみなす翌朝の発電量 (kWh)
+}
+
+// Validate は、EveningReserveConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (c *EveningReserveConfig) Validate(forecast ForecastConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.StartTime == "" {
+		c.StartTime = "17:00"
+	}
+	if c.EndTime == "" {
+		c.EndTime = "23:00"
+	}
+	if c.MorningEndTime == "" {
+		c.MorningEndTime = "12:00"
+	}
+	for _, f := range []struct{ name, value string }{
+		{"start_time", c.StartTime},
+		{"end_time", c.EndTime},
+		{"morning_end_time", c.MorningEndTime},
+	} {
+		if _, err := time.Parse("15:04", f.value); err != nil {
+			return fmt.Errorf("'evening_reserve.%s' の形式が不正です ('%s')", f.name, f.value)
+		}
+	}
+	if c.SunnyMorningKWh <= 0 {
+		c.SunnyMorningKWh = 5.0
+	}
+	if c.MinReservePercent < 0 || c.MaxReservePercent > 100 || c.MinReservePercent > c.MaxReservePercent {
+		return fmt.Errorf("'evening_reserve.min_reserve_percent' (%d) と 'evening_reserve.max_reserve_percent' (%d) は 0 <= min <= max <= 100 を満たす必要があります", c.MinReservePercent, c.MaxReservePercent)
+	}
+	if !forecast.configured() {
+		return fmt.Errorf("'evening_reserve' を有効にするには発電量予測 ('forecast') の設定が必要です")
+	}
+	return nil
+}
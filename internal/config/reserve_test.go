@@ -0,0 +1,22 @@
+package config
+
+import "testing"
+
+func TestEveningReserveConfigValidation(t *testing.T) {
+	cfg := EveningReserveConfig{Enabled: true, MinReservePercent: 70, MaxReservePercent: 30}
+	if err := cfg.Validate(ForecastConfig{PanelKW: 5}); err == nil {
+		t.Errorf("expected error when min reserve exceeds max reserve")
+	}
+	cfg = EveningReserveConfig{Enabled: true, MaxReservePercent: 30}
+	if err := cfg.Validate(ForecastConfig{}); err == nil {
+		t.Errorf("expected error without forecast.panel_kw")
+	}
+	cfg = EveningReserveConfig{Enabled: true, MaxReservePercent: 30, StartTime: "5pm"}
+	if err := cfg.Validate(ForecastConfig{PanelKW: 5}); err == nil {
+		t.Errorf("expected error for malformed start_time")
+	}
+	disabled := EveningReserveConfig{StartTime: "invalid"}
+	if err := disabled.Validate(ForecastConfig{}); err != nil {
+		t.Errorf("disabled reserve must not be validated: %v", err)
+	}
+}
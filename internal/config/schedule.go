@@ -0,0 +1,211 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/controller"
+)
+
+// TimeSlot は、充電・放電などの時間帯の開始時刻と終了時刻 (HH:MM形式) です。
+// 開始時刻と終了時刻の代わりに cron 形式の式 (分 時 日 月 曜日) を指定すると、式に一致する各1分間を時間帯とします。
+type TimeSlot struct {
+	StartTime string `toml:"start_time"`
+	EndTime   string `toml:"end_time"`
+	Cron      string `toml:"cron"` // 例: "* 9-14 * * mon-fri"
+}
+
+// isWeekend は、土曜日または日曜日であれば true を返します。
+func isWeekend(t time.Time) bool {
+	return t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+}
+
+// Loc は、時間帯・期間・日ごとの集計を判定するタイムゾーンを返します。timezone が未設定の場合はシステムのタイムゾーンです。
+func (c *Config) Loc() *time.Location {
+	if c.Location == nil {
+		return time.Local
+	}
+	return c.Location
+}
+
+// Now は、現在時刻を timezone のタイムゾーンで返します。
+// 時刻 (HH:MM)、日付 (MM-DD)、曜日、日ごとの集計の切り替えは、この時刻の壁時計で判定します。
+func (c *Config) Now() time.Time {
+	if c.Clock != nil {
+		return c.Clock.Now().In(c.Loc())
+	}
+	return time.Now().In(c.Loc())
+}
+
+// ControllerClock は、制御の状態機械に渡す時計を返します。clock が未設定の場合はシステムの時計です。
+// 状態機械と監視サイクルの時間帯・抑制時間の判定は、同じ時計を使用します。
+func (c *Config) ControllerClock() controller.Clock {
+	if c.Clock == nil {
+		return controller.SystemClock()
+	}
+	return c.Clock
+}
+
+// ChargeWindow は、充電時間帯と、その時間帯に充電する目標の蓄電残量です。
+type ChargeWindow struct {
+	TimeSlot
+	TargetSOCPercent int `toml:"target_soc_percent"` // 目標の蓄電残量 (%)
+}
+
+// ChargeTimes は、指定された日時に適用する充電時間帯を返します。
+// 週末は charge_times_weekend の設定を使用し、未設定の項目は平日の設定
+// (charge_start_time / charge_end_time / charge_target_soc_percent) を使用します。
+// holidays を有効にした場合、祝日は treat_holidays_as に従って週末・平日・holidays.charge_times のいずれかの設定を使用します。
+// 有効な運転プロファイルに目標の蓄電残量がある場合は、平日・週末にかかわらずその値を使用します。
+func (c *Config) ChargeTimes(now time.Time) ChargeWindow {
+	window := ChargeWindow{
+		TimeSlot:         c.WeekdayChargeSlot(),
+		TargetSOCPercent: c.ChargeTargetSOCPercent,
+	}
+	weekend := isWeekend(now)
+	if c.Holidays.Enabled && c.Holidays.isHoliday(now) {
+		switch c.Holidays.TreatAs {
+		case holidaysAsWeekend:
+			weekend = true
+		case holidaysAsWeekday:
+			weekend = false
+		case HolidaysAsCustom:
+			return c.withProfileTarget(now, window.Overlay(c.Holidays.ChargeTimes))
+		}
+	}
+	if weekend {
+		window = window.Overlay(c.ChargeTimesWeekend)
+	}
+	return c.withProfileTarget(now, window)
+}
+
+// WeekdayChargeSlot は、平日の充電時間帯を返します。charge_cron を指定した場合は charge_start_time と charge_end_time の代わりに使用します。
+func (c *Config) WeekdayChargeSlot() TimeSlot {
+	if c.ChargeCron != "" {
+		return TimeSlot{Cron: c.ChargeCron}
+	}
+	return TimeSlot{StartTime: c.ChargeStartTime, EndTime: c.ChargeEndTime}
+}
+
+// Overlay は、o の設定された項目で w を置き換えた充電時間帯を返します。
+// o に cron 形式の式がある場合は時間帯全体を置き換え、w が cron 形式の場合は o の開始時刻と終了時刻の両方で置き換えます。
+func (w ChargeWindow) Overlay(o ChargeWindow) ChargeWindow {
+	switch {
+	case o.Cron != "":
+		w.TimeSlot = TimeSlot{Cron: o.Cron}
+	case w.Cron != "":
+		// cron 形式の時間帯には開始時刻または終了時刻の一方だけを組み合わせられない
+		if o.Configured() {
+			w.TimeSlot = o.TimeSlot
+		}
+	default:
+		if o.StartTime != "" {
+			w.StartTime = o.StartTime
+		}
+		if o.EndTime != "" {
+			w.EndTime = o.EndTime
+		}
+	}
+	if o.TargetSOCPercent > 0 {
+		w.TargetSOCPercent = o.TargetSOCPercent
+	}
+	return w
+}
+
+// withProfileTarget は、有効な運転プロファイルに目標の蓄電残量があれば、window の目標をその値に置き換えます。
+func (c *Config) withProfileTarget(now time.Time, window ChargeWindow) ChargeWindow {
+	if _, p := c.ActiveProfile(now); p.ChargeTargetSOCPercent > 0 {
+		window.TargetSOCPercent = p.ChargeTargetSOCPercent
+	}
+	return window
+}
+
+// target_reached_action の値
+const (
+	targetReachedHold = "hold" // 充電時間帯の終了まで「充電」を維持する
+	TargetReachedStop = "stop" // 直ちに「自動」に切り替え、充電時間帯の終了まで充電を再開しない
+)
+
+// TargetChargeWh は、AC実効容量 (Wh) と現在の蓄電残量 (%) から、目標の蓄電残量までに必要な充電量 (Wh) を返します。
+// 既に目標に達している場合は 0 を返します。
+func (w ChargeWindow) TargetChargeWh(acCapacity uint32, soc uint8) float64 {
+	if int(soc) >= w.TargetSOCPercent {
+		return 0
+	}
+	return float64(acCapacity) * float64(w.TargetSOCPercent-int(soc)) / 100.0
+}
+
+// Configured は、開始時刻と終了時刻の両方、または cron 形式の式が設定されていれば true を返します。
+func (s TimeSlot) Configured() bool {
+	return s.Cron != "" || s.StartTime != "" && s.EndTime != ""
+}
+
+// Validate は、開始時刻と終了時刻が HH:MM 形式であること、または cron 形式の式が正しいことを確認します。
+func (s TimeSlot) Validate() error {
+	if s.Cron != "" {
+		if s.StartTime != "" || s.EndTime != "" {
+			return fmt.Errorf("'cron' と 'start_time', 'end_time' は同時に指定できません")
+		}
+		_, err := parseCron(s.Cron)
+		return err
+	}
+	for _, v := range []string{s.StartTime, s.EndTime} {
+		if _, err := time.Parse("15:04", v); err != nil {
+			return fmt.Errorf("時刻 '%s' が HH:MM 形式ではありません", v)
+		}
+	}
+	return nil
+}
+
+// validatePartial は、設定された開始時刻と終了時刻のみ HH:MM 形式であることを確認します。
+// 未設定の項目を平日の設定で補う charge_times_weekend のように、一部だけを設定できる時間帯に使用します。
+func (s TimeSlot) validatePartial() error {
+	if s.Cron != "" {
+		return s.Validate()
+	}
+	for _, v := range []string{s.StartTime, s.EndTime} {
+		if v == "" {
+			continue
+		}
+		if _, err := time.Parse("15:04", v); err != nil {
+			return fmt.Errorf("時刻 '%s' が HH:MM 形式ではありません", v)
+		}
+	}
+	return nil
+}
+
+// Contains は、指定された時刻が時間帯に含まれれば true を返します。
+func (s TimeSlot) Contains(now time.Time) (bool, error) {
+	if s.Cron != "" {
+		c, err := parseCron(s.Cron)
+		if err != nil {
+			return false, err
+		}
+		return c.matches(now), nil
+	}
+	return IsChargingTime(now, s.StartTime, s.EndTime)
+}
+
+// End は、now の後の時間帯の終了時刻を返します。日付をまたぐ時間帯にも対応するため、次に来る終了時刻とします。
+// cron 形式の場合は、now を含む (含まない場合は次に始まる) 時間帯の終了時刻で、見つからない場合は now を返します。
+func (s TimeSlot) End(now time.Time) time.Time {
+	if s.Cron != "" {
+		c, err := parseCron(s.Cron)
+		if err != nil {
+			return now
+		}
+		if end, ok := c.windowEnd(now); ok {
+			return end
+		}
+		return now
+	}
+	return NextOccurrence(now, s.EndTime)
+}
+
+// String は、ログに出力する時間帯の表記を返します。
+func (s TimeSlot) String() string {
+	if s.Cron != "" {
+		return "cron: " + s.Cron
+	}
+	return s.StartTime + " - " + s.EndTime
+}
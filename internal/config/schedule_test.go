@@ -0,0 +1,158 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChargeTimesWeekend(t *testing.T) {
+	cfg := &Config{
+		ChargeStartTime:    "09:00",
+		ChargeEndTime:      "15:00",
+		ChargeTimesWeekend: ChargeWindow{TimeSlot: TimeSlot{StartTime: "10:30"}},
+	}
+	friday := time.Date(2025, 6, 6, 12, 0, 0, 0, time.Local)
+	saturday := friday.AddDate(0, 0, 1)
+
+	if got := cfg.ChargeTimes(friday); got.TimeSlot != (TimeSlot{StartTime: "09:00", EndTime: "15:00"}) {
+		t.Errorf("weekday ChargeTimes = %+v", got)
+	}
+	// The weekend end time is not set, so the weekday value is used.
+	if got := cfg.ChargeTimes(saturday); got.TimeSlot != (TimeSlot{StartTime: "10:30", EndTime: "15:00"}) {
+		t.Errorf("weekend ChargeTimes = %+v", got)
+	}
+
+	cfg.ChargeTimesWeekend = ChargeWindow{}
+	if got := cfg.ChargeTimes(saturday.AddDate(0, 0, 1)); got.TimeSlot != (TimeSlot{StartTime: "09:00", EndTime: "15:00"}) {
+		t.Errorf("ChargeTimes without weekend entries = %+v", got)
+	}
+}
+
+func TestChargeWindowTargetChargeWh(t *testing.T) {
+	w := ChargeWindow{TargetSOCPercent: 80}
+	if got := w.TargetChargeWh(10000, 50); got != 3000 {
+		t.Errorf("TargetChargeWh(10000, 50) = %v, want 3000", got)
+	}
+	if got := w.TargetChargeWh(10000, 85); got != 0 {
+		t.Errorf("targetChargeWh above target = %v, want 0", got)
+	}
+}
+
+func TestLoadConfigValidatesWeekendChargeTimes(t *testing.T) {
+	dir := t.TempDir()
+	load := func(weekend string) error {
+		path := filepath.Join(dir, "config.toml")
+		content := "target_ip = \"192.168.0.10\"\n[charge_times_weekend]\n" + weekend
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := Load(path)
+		return err
+	}
+
+	// Only the set entries are checked; the others fall back to the weekday values.
+	for _, weekend := range []string{
+		"start_time = \"10:30\"\n",
+		"end_time = \"16:00\"\n",
+		"start_time = \"10:30\"\nend_time = \"16:00\"\n",
+		"target_soc_percent = 80\n",
+	} {
+		if err := load(weekend); err != nil {
+			t.Errorf("Load with %q: %v", weekend, err)
+		}
+	}
+	for _, weekend := range []string{
+		"start_time = \"25:00\"\n",
+		"end_time = \"10.30\"\n",
+		"start_time = \"10:30\"\nend_time = \"16\"\n",
+	} {
+		if err := load(weekend); err == nil || !strings.Contains(err.Error(), "'charge_times_weekend'") {
+			t.Errorf("Load with %q = %v, want error mentioning 'charge_times_weekend'", weekend, err)
+		}
+	}
+}
+
+func TestLoadConfigTimezone(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	cfg, err := Load(write("tokyo.toml", "target_ip = \"192.168.0.10\"\ntimezone = \"Asia/Tokyo\"\n"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := cfg.Loc().String(); got != "Asia/Tokyo" {
+		t.Errorf("Loc() = %s, want Asia/Tokyo", got)
+	}
+	if got := cfg.Now().Location(); got != cfg.Loc() {
+		t.Errorf("Now() location = %s, want %s", got, cfg.Loc())
+	}
+
+	cfg, err = Load(write("default.toml", "target_ip = \"192.168.0.10\"\n"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Loc() != time.Local {
+		t.Errorf("Loc() without timezone = %s, want Local", cfg.Loc())
+	}
+
+	_, err = Load(write("bad.toml", "target_ip = \"192.168.0.10\"\ntimezone = \"Mars/Olympus_Mons\"\n"))
+	if err == nil || !strings.Contains(err.Error(), "'timezone'") {
+		t.Errorf("Load with unknown timezone = %v, want error mentioning 'timezone'", err)
+	}
+}
+
+func TestScheduleEvaluationInTimezone(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("tzdata not available: %v", err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("tzdata not available: %v", err)
+	}
+	cfg := &Config{
+		ChargeStartTime:    "09:00",
+		ChargeEndTime:      "15:00",
+		ChargeTimesWeekend: ChargeWindow{TimeSlot: TimeSlot{StartTime: "10:00"}},
+	}
+
+	// 2025-06-07 01:00 UTC is Saturday 10:00 in Tokyo and Friday 21:00 in New York.
+	instant := time.Date(2025, 6, 7, 1, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		loc      *time.Location
+		start    string
+		charging bool
+	}{
+		{tokyo, "10:00", true},
+		{newYork, "09:00", false},
+	} {
+		now := instant.In(tc.loc)
+		w := cfg.ChargeTimes(now)
+		if w.StartTime != tc.start {
+			t.Errorf("%s: ChargeTimes start = %s, want %s", tc.loc, w.StartTime, tc.start)
+		}
+		charging, err := IsChargingTime(now, w.StartTime, w.EndTime)
+		if err != nil || charging != tc.charging {
+			t.Errorf("%s: IsChargingTime = (%t, %v), want %t", tc.loc, charging, err, tc.charging)
+		}
+	}
+
+	// After the DST change on 2025-03-09 New York is UTC-4. The window is evaluated
+	// on the wall clock, so 13:00 UTC (09:00 EDT) is inside it and 12:59 UTC is not.
+	dstMorning := time.Date(2025, 3, 10, 13, 0, 0, 0, time.UTC).In(newYork) // Monday 09:00 EDT
+	if charging, err := IsChargingTime(dstMorning, "09:00", "15:00"); err != nil || !charging {
+		t.Errorf("isChargingTime at %s = (%t, %v), want true", dstMorning, charging, err)
+	}
+	if charging, _ := IsChargingTime(dstMorning.Add(-time.Minute), "09:00", "15:00"); charging {
+		t.Errorf("isChargingTime at %s = true, want false", dstMorning.Add(-time.Minute))
+	}
+}
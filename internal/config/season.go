@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// DateRange は、毎年繰り返す期間の開始日と終了日 (MM-DD形式) です。
+type DateRange struct {
+	Start string `toml:"start"` // 期間の開始日 (MM-DD)
+	End   string `toml:"end"`   // 期間の終了日 (MM-DD、この日を含む)。開始日より前の場合は年をまたぐ期間
+}
+
+// validate は、開始日と終了日が MM-DD 形式であることを確認します。
+func (r DateRange) validate() error {
+	for _, v := range []string{r.Start, r.End} {
+		if _, err := time.Parse("01-02", v); err != nil {
+			return fmt.Errorf("日付 '%s' が MM-DD 形式ではありません", v)
+		}
+	}
+	return nil
+}
+
+// ContainsDate は、指定された日が期間に含まれれば true を返します。
+func (r DateRange) ContainsDate(now time.Time) bool {
+	today := now.Format("01-02")
+	if r.Start <= r.End {
+		return today >= r.Start && today <= r.End
+	}
+	// 年をまたぐ期間 (例: 12-01 から 02-28)
+	return today >= r.Start || today <= r.End
+}
+
+// SeasonalChargeCap は、期間ごとに充電電力の上限と余剰電力の余力を変更する設定です。
+// 夏季など契約アンペアに余裕がない時期に、充電電力を抑えるために使用します。
+type SeasonalChargeCap struct {
+	DateRange
+	MaxChargePowerWatts     int `toml:"max_charge_power_watts"`     // 期間中の最大充電電力 (W)。0 の場合は max_charge_power_watts を使用
+	SurplusPowerMarginWatts int `toml:"surplus_power_margin_watts"` // 期間中の余剰電力の余力 (W)。0 の場合は surplus_power_margin_watts を使用
+}
+
+// validate は、期間と電力の設定を確認します。
+func (s SeasonalChargeCap) validate() error {
+	if err := s.DateRange.validate(); err != nil {
+		return err
+	}
+	if s.MaxChargePowerWatts < 0 || s.SurplusPowerMarginWatts < 0 {
+		return fmt.Errorf("期間 %s - %s の電力は 0 以上である必要があります", s.Start, s.End)
+	}
+	return nil
+}
+
+// ChargePowerLimits は、指定された日に適用する最大充電電力 (W) と余剰電力の余力 (W) を返します。
+// seasonal_charge_caps のうち最初に一致した期間の設定を使用し、一致しない場合や未設定の項目は
+// 有効な運転プロファイルの設定、または通常の設定を使用します。
+func (c *Config) ChargePowerLimits(now time.Time) (maxWatts, marginWatts int) {
+	maxWatts, marginWatts = c.MaxChargePowerWatts, c.SurplusPowerMarginWatts
+	if _, p := c.ActiveProfile(now); p.MaxChargePowerWatts > 0 || p.SurplusPowerMarginWatts > 0 {
+		if p.MaxChargePowerWatts > 0 {
+			maxWatts = p.MaxChargePowerWatts
+		}
+		if p.SurplusPowerMarginWatts > 0 {
+			marginWatts = p.SurplusPowerMarginWatts
+		}
+	}
+	for _, s := range c.SeasonalChargeCaps {
+		if !s.ContainsDate(now) {
+			continue
+		}
+		if s.MaxChargePowerWatts > 0 {
+			maxWatts = s.MaxChargePowerWatts
+		}
+		if s.SurplusPowerMarginWatts > 0 {
+			marginWatts = s.SurplusPowerMarginWatts
+		}
+		break
+	}
+	return maxWatts, marginWatts
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChargePowerLimits(t *testing.T) {
+	cfg := &Config{
+		MaxChargePowerWatts:     3000,
+		SurplusPowerMarginWatts: 500,
+		SeasonalChargeCaps: []SeasonalChargeCap{
+			{DateRange: DateRange{Start: "07-01", End: "09-30"}, MaxChargePowerWatts: 1500},
+			{DateRange: DateRange{Start: "12-01", End: "02-28"}, MaxChargePowerWatts: 2000, SurplusPowerMarginWatts: 300},
+			{DateRange: DateRange{Start: "08-01", End: "08-31"}, MaxChargePowerWatts: 1000}, // shadowed by the summer entry
+		},
+	}
+	for _, tc := range []struct {
+		date                  string
+		maxWatts, marginWatts int
+	}{
+		{"2025-06-30", 3000, 500},
+		{"2025-07-01", 1500, 500},
+		{"2025-08-15", 1500, 500},
+		{"2025-09-30", 1500, 500},
+		{"2025-10-01", 3000, 500},
+		{"2025-12-01", 2000, 300},
+		{"2026-01-15", 2000, 300},
+		{"2026-03-01", 3000, 500},
+	} {
+		now, _ := time.ParseInLocation("2006-01-02", tc.date, time.Local)
+		gotMax, gotMargin := cfg.ChargePowerLimits(now.Add(12 * time.Hour))
+		if gotMax != tc.maxWatts || gotMargin != tc.marginWatts {
+			t.Errorf("%s: limits = %d W / %d W, want %d W / %d W", tc.date, gotMax, gotMargin, tc.maxWatts, tc.marginWatts)
+		}
+	}
+}
+
+func TestSeasonalChargeCapValidate(t *testing.T) {
+	if err := (SeasonalChargeCap{DateRange: DateRange{Start: "07-01", End: "09-30"}}).validate(); err != nil {
+		t.Errorf("valid period rejected: %v", err)
+	}
+	for _, bad := range []SeasonalChargeCap{
+		{DateRange: DateRange{Start: "7/1", End: "09-30"}},
+		{DateRange: DateRange{Start: "07-01", End: "13-01"}},
+		{DateRange: DateRange{Start: "07-01", End: "09-30"}, MaxChargePowerWatts: -1},
+	} {
+		if err := bad.validate(); err == nil {
+			t.Errorf("validate(%+v) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Secret は、API キーやパスワードなどの外部サービスの認証情報の設定値です。
+// 設定ファイルに直接書く代わりに、"env:<環境変数名>" とすると環境変数から、"file:<パス>" とするとファイルから読み込みます。
+// ログに出力する場合は値を伏せます。
+type Secret string
+
+// 認証情報の参照先の接頭辞
+const (
+	secretEnvPrefix  = "env:"
+	secretFilePrefix = "file:"
+)
+
+// String は、値を伏せた文字列を返します。設定の一覧をログに出力しても認証情報が残らないようにします。
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "********"
+}
+
+// resolve は、環境変数やファイルを参照している場合に参照先の値を返し、それ以外はそのまま返します。
+// ファイルの末尾の改行は取り除きます。参照先が存在しないか空の場合はエラーを返します。
+func (s Secret) resolve() (Secret, error) {
+	v := string(s)
+	switch {
+	case strings.HasPrefix(v, secretEnvPrefix):
+		name := strings.TrimPrefix(v, secretEnvPrefix)
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			return "", fmt.Errorf("環境変数 '%s' が設定されていないか、空です", name)
+		}
+		return Secret(value), nil
+	case strings.HasPrefix(v, secretFilePrefix):
+		path := strings.TrimPrefix(v, secretFilePrefix)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("ファイル '%s' の読み込みに失敗しました: %w", path, err)
+		}
+		value := strings.TrimRight(string(data), "\r\n")
+		if value == "" {
+			return "", fmt.Errorf("ファイル '%s' が空です", path)
+		}
+		return Secret(value), nil
+	}
+	return s, nil
+}
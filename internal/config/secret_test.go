@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecretResolve(t *testing.T) {
+	t.Setenv("EIBS7_TEST_KEY", "from-env")
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		value, want Secret
+	}{
+		{"plain", "plain"},
+		{"env:EIBS7_TEST_KEY", "from-env"},
+		{Secret("file:" + path), "from-file"},
+	} {
+		got, err := tc.value.resolve()
+		if err != nil || got != tc.want {
+			t.Errorf("%q.resolve() = %q, %v, want %q", string(tc.value), string(got), err, string(tc.want))
+		}
+	}
+	for _, value := range []Secret{"env:EIBS7_TEST_MISSING", Secret("file:" + path + ".missing")} {
+		if _, err := value.resolve(); err == nil {
+			t.Errorf("%q.resolve() succeeded, want error", string(value))
+		}
+	}
+}
+
+func TestSecretIsMaskedInLogs(t *testing.T) {
+	cfg := ForecastConfig{Provider: ForecastProviderSolcast, SolcastAPIKey: "api-key-1234", SolcastResourceID: "site-1"}
+	if s := fmt.Sprintf("%+v", cfg); strings.Contains(s, "api-key-1234") {
+		t.Errorf("config log %q contains the API key", s)
+	}
+}
+
+func TestForecastConfigResolvesAPIKey(t *testing.T) {
+	t.Setenv("EIBS7_TEST_SOLCAST_KEY", "api-key-1234")
+	cfg := ForecastConfig{Provider: ForecastProviderSolcast, SolcastAPIKey: "env:EIBS7_TEST_SOLCAST_KEY", SolcastResourceID: "site-1"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	if cfg.SolcastAPIKey != "api-key-1234" {
+		t.Errorf("SolcastAPIKey = %q, want the environment value", string(cfg.SolcastAPIKey))
+	}
+}
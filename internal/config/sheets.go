@@ -0,0 +1,124 @@
+package config
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// GoogleSheetsConfig は、履歴を集計した日ごとの電力量と節約額の目安を Google スプレッドシートに1行ずつ追記する設定です。
+// サービスアカウントで認証するため、スプレッドシートをサービスアカウントのメールアドレスと共有 (編集者) してください。
+// [history] の有効化が必要です。
+type GoogleSheetsConfig struct {
+	Enabled         bool    `toml:"enabled"`
+	CredentialsFile string  `toml:"credentials_file"`         // サービスアカウントの鍵の JSON ファイル
+	SpreadsheetID   string  `toml:"spreadsheet_id"`           // スプレッドシートの ID (URL の /d/ と /edit の間)
+	Sheet           string  `toml:"sheet"`                    // 追記するシート名 (デフォルト: "Sheet1")
+	Time            string  `toml:"time"`                     // 追記する時刻 (HH:MM、デフォルト: "23:55")。その日の 0:00 からこの時刻までを集計する
+	ImportPrice     float64 `toml:"import_price_yen_per_kwh"` // 買電の単価 (円/kWh)。節約額の目安に使用する
+	ExportPrice     float64 `toml:"export_price_yen_per_kwh"` // 売電の単価 (円/kWh)
+}
+
+// Validate は、GoogleSheetsConfig にデフォルト値を設定し、値の妥当性を確認します。鍵のファイルも読み込んで確認します。
+func (c *GoogleSheetsConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.SpreadsheetID == "" {
+		return fmt.Errorf("'google_sheets.enabled' が true の場合は 'google_sheets.spreadsheet_id' の設定が必要です")
+	}
+	if c.Sheet == "" {
+		c.Sheet = "Sheet1"
+	}
+	if c.Time == "" {
+		c.Time = "23:55"
+	}
+	if _, err := time.Parse("15:04", c.Time); err != nil {
+		return fmt.Errorf("'google_sheets.time' ('%s') は HH:MM 形式である必要があります", c.Time)
+	}
+	if c.ImportPrice < 0 || c.ExportPrice < 0 {
+		return fmt.Errorf("'google_sheets.import_price_yen_per_kwh' と 'google_sheets.export_price_yen_per_kwh' は 0 以上である必要があります")
+	}
+	if c.CredentialsFile == "" {
+		return fmt.Errorf("'google_sheets.enabled' が true の場合は 'google_sheets.credentials_file' の設定が必要です")
+	}
+	if _, err := LoadServiceAccount(c.CredentialsFile); err != nil {
+		return fmt.Errorf("'google_sheets.credentials_file' を読み込めませんでした: %w", err)
+	}
+	return nil
+}
+
+const (
+	googleSheetsScope = "https://www.googleapis.com/auth/spreadsheets"
+)
+
+// ServiceAccount は、サービスアカウントの鍵の JSON ファイルのうち、アクセストークンの取得に使用する項目です。
+type ServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+
+	key *rsa.PrivateKey
+}
+
+// LoadServiceAccount は、サービスアカウントの鍵の JSON ファイルを読み込みます。
+func LoadServiceAccount(path string) (*ServiceAccount, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sa ServiceAccount
+	if err := json.Unmarshal(b, &sa); err != nil {
+		return nil, fmt.Errorf("JSON として読み込めませんでした: %w", err)
+	}
+	if sa.ClientEmail == "" || sa.TokenURI == "" {
+		return nil, errors.New("client_email と token_uri がありません")
+	}
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return nil, errors.New("private_key が PEM 形式ではありません")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("private_key を読み込めませんでした: %w", err)
+		}
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private_key が RSA の鍵ではありません")
+	}
+	sa.key = rsaKey
+	return &sa, nil
+}
+
+// Assertion は、アクセストークンの取得に使用する、RS256 で署名した JWT を作成します。
+func (sa *ServiceAccount) Assertion(now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   sa.ClientEmail,
+		"scope": googleSheetsScope,
+		"aud":   sa.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, sa.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
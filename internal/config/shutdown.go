@@ -0,0 +1,17 @@
+package config
+
+import "fmt"
+
+// 終了時に設定する運転モード ("none" の場合は変更しない)
+var ShutdownOperationModes = map[string]byte{
+	"auto":    0x46, // 0x46: 自動モード
+	"standby": 0x44, // 0x44: 待機モード
+}
+
+// ValidateShutdownOperationMode は、shutdown_operation_mode の値を確認します。
+func ValidateShutdownOperationMode(mode string) error {
+	if _, ok := ShutdownOperationModes[mode]; ok || mode == "none" {
+		return nil
+	}
+	return fmt.Errorf("'shutdown_operation_mode' ('%s') は \"auto\", \"standby\", \"none\" のいずれかである必要があります", mode)
+}
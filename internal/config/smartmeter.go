@@ -0,0 +1,26 @@
+package config
+
+import "fmt"
+
+// SmartMeterConfig は、低圧スマート電力量メータ (0x0288) から B ルートで買電・売電電力を取得する設定です。
+// Wi-SUN で B ルートに接続したブリッジ (HEMS コントローラなど) が公開する ECHONET Lite オブジェクトを使用します。
+// 有効な場合、余剰電力と買電制限・ピークカットの判定には、分電盤メータリングの推定値ではなくスマートメーターの計測値を優先して使用します。
+type SmartMeterConfig struct {
+	Enabled  bool   `toml:"enabled"`
+	IP       string `toml:"ip"`       // ブリッジの IPアドレスまたはホスト名。未設定の場合は target_ip の機器
+	Instance int    `toml:"instance"` // インスタンスコード。未設定の場合は 1
+}
+
+// validate は、SmartMeterConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (c *SmartMeterConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Instance == 0 {
+		c.Instance = 1
+	}
+	if c.Instance < 0x01 || c.Instance > 0x7F {
+		return fmt.Errorf("'smart_meter.instance' (%d) は 1 から 127 の範囲である必要があります", c.Instance)
+	}
+	return nil
+}
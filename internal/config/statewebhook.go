@@ -0,0 +1,131 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+// StateWebhookConfig は、制御の状態が変わったときに HTTP のリクエストを送信する Webhook の設定です。
+// IFTTT や Zapier などの自動化サービスから、運転モードの変更や嵐警戒モードの開始に反応できます。
+// URL と本文は text/template で、{{.Event}}、{{.Time}}、{{.Mode}}、{{.PreviousMode}}、{{.SOC}}、{{.Reason}} を参照できます。
+// URL では {{urlquery .Reason}}、本文では {{json .Reason}} で値をエスケープできます。
+// URL とヘッダーの値は認証のキーを含むことがあるため、"env:" または "file:" で参照でき、ログには出力しません。
+type StateWebhookConfig struct {
+	URL     Secret            `toml:"url"`     // 送信先の URL のテンプレート。"env:" または "file:" で参照できる
+	Method  string            `toml:"method"`  // "POST" (デフォルト)、"PUT" または "GET"
+	Events  []string          `toml:"events"`  // 送信する出来事 (stateWebhookEvents のいずれか)
+	Body    string            `toml:"body"`    // 本文のテンプレート。未設定の場合は出来事の JSON (GET の場合は本文なし)
+	Headers map[string]Secret `toml:"headers"` // 追加するヘッダー (例: 認証のトークン)。値は "env:" または "file:" で参照できる
+}
+
+// 状態の変化の出来事
+const (
+	StateModeChange      = "mode_change"      // 蓄電池の運転モード設定が変わった
+	StateStormStart      = "storm_start"      // 嵐警戒モードを開始した
+	StateStormEnd        = "storm_end"        // 嵐警戒モードを終了した
+	StateBudgetExhausted = "budget_exhausted" // 系統からの充電電力量が1日の上限 (max_daily_grid_charge_wh) に達した
+	StateBudgetReset     = "budget_reset"     // 1日の区切りで系統からの充電電力量の積算がリセットされた
+)
+
+// stateWebhookEvents は、設定できる出来事です。
+var stateWebhookEvents = []string{StateModeChange, StateStormStart, StateStormEnd, StateBudgetExhausted, StateBudgetReset}
+
+// stateWebhookFuncs は、テンプレートで使用できる関数です。
+var stateWebhookFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Validate は、StateWebhookConfig の URL とヘッダーの参照を読み込み、デフォルト値を設定して値の妥当性とテンプレートを確認します。
+func (c *StateWebhookConfig) Validate() error {
+	u, err := c.URL.resolve()
+	if err != nil {
+		return fmt.Errorf("'url' を読み込めませんでした: %w", err)
+	}
+	if !strings.HasPrefix(string(u), "http://") && !strings.HasPrefix(string(u), "https://") {
+		return fmt.Errorf("'url' は http または https の URL である必要があります")
+	}
+	c.URL = u
+	headers := make(map[string]Secret, len(c.Headers))
+	for name, value := range c.Headers {
+		v, err := value.resolve()
+		if err != nil {
+			return fmt.Errorf("'headers' の '%s' を読み込めませんでした: %w", name, err)
+		}
+		headers[name] = v
+	}
+	c.Headers = headers
+	if c.Method == "" {
+		c.Method = http.MethodPost
+	}
+	c.Method = strings.ToUpper(c.Method)
+	switch c.Method {
+	case http.MethodPost, http.MethodPut, http.MethodGet:
+	default:
+		return fmt.Errorf("'method' ('%s') は \"POST\"、\"PUT\"、\"GET\" のいずれかである必要があります", c.Method)
+	}
+	if len(c.Events) == 0 {
+		return fmt.Errorf("'events' に送信する出来事を1つ以上指定してください")
+	}
+	for _, e := range c.Events {
+		if !containsString(stateWebhookEvents, e) {
+			return fmt.Errorf("'events' の '%s' は %s のいずれかである必要があります", e, strings.Join(stateWebhookEvents, "、"))
+		}
+	}
+	if c.Body != "" && c.Method == http.MethodGet {
+		return fmt.Errorf("'method' が \"GET\" の場合は 'body' を設定できません")
+	}
+	urlTemplate, body, err := c.Templates()
+	if err != nil {
+		return err
+	}
+	// 存在しない項目の参照などは実行するまで分からないため、空の出来事で試す
+	if err := urlTemplate.Execute(io.Discard, StateEvent{}); err != nil {
+		return fmt.Errorf("'url' のテンプレートが不正です: %w", err)
+	}
+	if body != nil {
+		if err := body.Execute(io.Discard, StateEvent{}); err != nil {
+			return fmt.Errorf("'body' のテンプレートが不正です: %w", err)
+		}
+	}
+	return nil
+}
+
+// Templates は、URL と本文のテンプレートを読み込みます。本文を設定していない場合、本文のテンプレートは nil です。
+func (c *StateWebhookConfig) Templates() (u, body *template.Template, err error) {
+	if u, err = template.New("url").Funcs(stateWebhookFuncs).Parse(string(c.URL)); err != nil {
+		return nil, nil, fmt.Errorf("'url' のテンプレートが不正です: %w", err)
+	}
+	if c.Body != "" {
+		if body, err = template.New("body").Funcs(stateWebhookFuncs).Parse(c.Body); err != nil {
+			return nil, nil, fmt.Errorf("'body' のテンプレートが不正です: %w", err)
+		}
+	}
+	return u, body, nil
+}
+
+// containsString は、list に s が含まれるかを返します。
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// StateEvent は、Webhook に送信する状態の変化です。テンプレートと、本文を省略した場合の JSON で使用します。
+type StateEvent struct {
+	Event        string `json:"event"`
+	Time         string `json:"time"`
+	Mode         string `json:"mode,omitempty"`          // 蓄電池の運転モード設定 (例: "0x42")
+	PreviousMode string `json:"previous_mode,omitempty"` // mode_change の場合の変化前の運転モード
+	SOC          *int64 `json:"soc,omitempty"`           // 蓄電残量 (%)
+	Reason       string `json:"reason,omitempty"`        // 監視サイクルの判断の理由
+}
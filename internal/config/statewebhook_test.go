@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStateWebhookConfigValidate(t *testing.T) {
+	c := StateWebhookConfig{URL: "https://example.com/hook", Events: []string{StateModeChange}}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Method != http.MethodPost {
+		t.Errorf("method = %q, want POST", c.Method)
+	}
+	for _, bad := range []StateWebhookConfig{
+		{URL: "example.com/hook", Events: []string{StateModeChange}},
+		{URL: "https://example.com/hook"},
+		{URL: "https://example.com/hook", Events: []string{"soc_low"}},
+		{URL: "https://example.com/hook", Events: []string{StateModeChange}, Method: "DELETE"},
+		{URL: "https://example.com/hook", Events: []string{StateModeChange}, Method: "GET", Body: "{}"},
+		{URL: "https://example.com/{{.Event", Events: []string{StateModeChange}},
+		{URL: "https://example.com/hook", Events: []string{StateModeChange}, Body: "{{json .Nope}}"},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", bad)
+		}
+	}
+}
+
+func TestStateWebhookConfigResolvesSecrets(t *testing.T) {
+	t.Setenv("EIBS7_TEST_HOOK_URL", "https://example.com/hook?key=url-key-1234")
+	t.Setenv("EIBS7_TEST_HOOK_TOKEN", "header-token-1234")
+	c := StateWebhookConfig{
+		URL:     "env:EIBS7_TEST_HOOK_URL",
+		Events:  []string{StateModeChange},
+		Headers: map[string]Secret{"Authorization": "env:EIBS7_TEST_HOOK_TOKEN", "X-Source": "eibs7-controller"},
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if c.URL != "https://example.com/hook?key=url-key-1234" || c.Headers["Authorization"] != "header-token-1234" || c.Headers["X-Source"] != "eibs7-controller" {
+		t.Errorf("url = %q, Authorization = %q, X-Source = %q", string(c.URL), string(c.Headers["Authorization"]), string(c.Headers["X-Source"]))
+	}
+	if s := fmt.Sprintf("%+v", []StateWebhookConfig{c}); strings.Contains(s, "url-key-1234") || strings.Contains(s, "header-token-1234") {
+		t.Errorf("config log %q contains a credential", s)
+	}
+
+	missing := StateWebhookConfig{URL: "https://example.com/hook", Events: []string{StateModeChange}, Headers: map[string]Secret{"Authorization": "env:EIBS7_TEST_MISSING"}}
+	if err := missing.Validate(); err == nil {
+		t.Error("Validate succeeded with a missing header reference")
+	}
+}
@@ -0,0 +1,33 @@
+package config
+
+import "fmt"
+
+// StormAlertConfig は、気象庁の警報に応じて蓄電池を満充電にする嵐警戒モードの設定です。
+type StormAlertConfig struct {
+	Enabled              bool     `toml:"enabled"`
+	OfficeCode           string   `toml:"office_code"`            // 府県予報区のコード (例: "130000" = 東京都)
+	AreaCode             string   `toml:"area_code"`              // 警報を確認する区域のコード (一次細分区域または市区町村, 例: "1310100")
+	WarningCodes         []string `toml:"warning_codes"`          // 嵐警戒モードにする警報のコード
+	CheckIntervalMinutes int      `toml:"check_interval_minutes"` // 警報を確認する間隔 (分)
+}
+
+// 嵐警戒モードにする警報のコードのデフォルト値
+// 02: 暴風雪警報, 03: 大雨警報, 05: 暴風警報, 32: 暴風雪特別警報, 33: 大雨特別警報, 35: 暴風特別警報
+var defaultStormWarningCodes = []string{"02", "03", "05", "32", "33", "35"}
+
+// Validate は、StormAlertConfig にデフォルト値を設定し、値の妥当性を確認します。
+func (c *StormAlertConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.OfficeCode == "" || c.AreaCode == "" {
+		return fmt.Errorf("'storm_alert' を有効にするには 'storm_alert.office_code' と 'storm_alert.area_code' の設定が必要です")
+	}
+	if len(c.WarningCodes) == 0 {
+		c.WarningCodes = defaultStormWarningCodes
+	}
+	if c.CheckIntervalMinutes <= 0 {
+		c.CheckIntervalMinutes = 10
+	}
+	return nil
+}
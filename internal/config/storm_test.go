@@ -0,0 +1,10 @@
+package config
+
+import "testing"
+
+func TestStormAlertConfigValidation(t *testing.T) {
+	cfg := StormAlertConfig{Enabled: true, OfficeCode: "130000"}
+	if err := cfg.Validate(); err == nil {
+		t.Errorf("expected error without area_code")
+	}
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// 制御方式の名前
+const (
+	StrategyChargeSchedule  = "charge_schedule"  // 充電時間帯に充電し、それ以外は自家消費する (従来の制御)
+	StrategySelfConsumption = "self_consumption" // 時間帯にかかわらず自動モードで自家消費する
+	StrategyPeakShave       = "peak_shave"       // 買電電力が閾値を超えた分だけ放電する
+	StrategyExportPriority  = "export_priority"  // 待機モードにして余剰電力をすべて売電する
+)
+
+// strategyNames は、設定で指定できる制御方式の名前です。
+var strategyNames = []string{StrategyChargeSchedule, StrategySelfConsumption, StrategyPeakShave, StrategyExportPriority}
+
+// validateStrategy は、制御方式の名前が既知のものであることを確認します。
+func validateStrategy(name string) error {
+	for _, n := range strategyNames {
+		if name == n {
+			return nil
+		}
+	}
+	return fmt.Errorf("制御方式 '%s' は不正です (%s のいずれかを指定してください)", name, strings.Join(strategyNames, ", "))
+}
+
+// StrategyPeriod は、指定した時間帯 (と期間) に使用する制御方式です。
+type StrategyPeriod struct {
+	Strategy  string `toml:"strategy"` // 制御方式の名前
+	DateRange        // 期間 (省略した場合は通年)
+	TimeSlot         // 時間帯
+}
+
+// validate は、制御方式の名前と期間・時間帯の形式を確認します。
+func (p StrategyPeriod) validate() error {
+	if err := validateStrategy(p.Strategy); err != nil {
+		return err
+	}
+	if p.DateRange != (DateRange{}) {
+		if err := p.DateRange.validate(); err != nil {
+			return err
+		}
+	}
+	return p.TimeSlot.Validate()
+}
+
+// contains は、指定された日時が期間と時間帯に含まれれば true を返します。
+func (p StrategyPeriod) contains(now time.Time) (bool, error) {
+	if p.DateRange != (DateRange{}) && !p.ContainsDate(now) {
+		return false, nil
+	}
+	return p.TimeSlot.Contains(now)
+}
+
+// StrategyName は、指定された日時に使用する制御方式の名前と、その理由を返します。
+// strategy_periods のうち最初に一致した時間帯の制御方式を優先し、次に export_maximization の時間帯では export_priority を、
+// それ以外では有効な運転プロファイルの制御方式、または strategy の設定 (未設定の場合は charge_schedule) を使用します。
+func (c *Config) StrategyName(now time.Time) (name, reason string) {
+	for _, p := range c.StrategyPeriods {
+		inPeriod, err := p.contains(now)
+		if err != nil {
+			log.Printf("[制御] 制御方式の時間帯の判定に失敗しました: %v", err)
+			continue
+		}
+		if inPeriod {
+			return p.Strategy, fmt.Sprintf("strategy_periods (%s)", p.TimeSlot)
+		}
+	}
+	if w, ok, err := c.exportWindow(now); err != nil {
+		log.Printf("[制御] %v", err)
+	} else if ok {
+		return StrategyExportPriority, fmt.Sprintf("売電優先の時間帯 (%s)", w.TimeSlot)
+	}
+	if profile, p := c.ActiveProfile(now); p.Strategy != "" {
+		return p.Strategy, fmt.Sprintf("運転プロファイル '%s'", profile)
+	}
+	if c.Strategy != "" {
+		return c.Strategy, "strategy"
+	}
+	return StrategyChargeSchedule, "デフォルト"
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateStrategy(t *testing.T) {
+	for _, name := range strategyNames {
+		if err := validateStrategy(name); err != nil {
+			t.Errorf("validateStrategy(%q) = %v", name, err)
+		}
+	}
+	if err := validateStrategy("greedy"); err == nil {
+		t.Error("validateStrategy accepted an unknown name")
+	}
+}
+
+func TestStrategyPeriodValidate(t *testing.T) {
+	ok := StrategyPeriod{Strategy: "peak_shave", TimeSlot: TimeSlot{StartTime: "17:00", EndTime: "21:00"}}
+	if err := ok.validate(); err != nil {
+		t.Errorf("validate() = %v for a period without dates", err)
+	}
+	for _, p := range []StrategyPeriod{
+		{Strategy: "unknown", TimeSlot: ok.TimeSlot},
+		{Strategy: "peak_shave", TimeSlot: TimeSlot{StartTime: "17", EndTime: "21:00"}},
+		{Strategy: "peak_shave", TimeSlot: ok.TimeSlot, DateRange: DateRange{Start: "7/1", End: "09-30"}},
+	} {
+		if err := p.validate(); err == nil {
+			t.Errorf("validate() accepted %+v", p)
+		}
+	}
+}
+
+func TestStrategyName(t *testing.T) {
+	cfg := &Config{
+		Strategy: "self_consumption",
+		StrategyPeriods: []StrategyPeriod{
+			{Strategy: "peak_shave", TimeSlot: TimeSlot{StartTime: "17:00", EndTime: "21:00"}},
+			{Strategy: "charge_schedule", DateRange: DateRange{Start: "12-01", End: "02-28"}, TimeSlot: TimeSlot{StartTime: "10:00", EndTime: "14:00"}},
+		},
+		ExportMaximization: []ExportWindow{{
+			DateRange: DateRange{Start: "04-01", End: "09-30"},
+			TimeSlot:  TimeSlot{StartTime: "09:00", EndTime: "15:00"},
+		}},
+	}
+	for _, tc := range []struct {
+		at   string
+		want string
+	}{
+		{"2025-06-01 18:00", "peak_shave"},
+		{"2025-06-01 11:00", "export_priority"},
+		{"2025-01-10 11:00", "charge_schedule"},
+		{"2025-03-10 11:00", "self_consumption"},
+	} {
+		now, err := time.ParseInLocation("2006-01-02 15:04", tc.at, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := cfg.StrategyName(now); got != tc.want {
+			t.Errorf("StrategyName(%s) = %q, want %q", tc.at, got, tc.want)
+		}
+	}
+
+	if got, _ := (&Config{}).StrategyName(time.Now()); got != "charge_schedule" {
+		t.Errorf("StrategyName() = %q without any setting, want charge_schedule", got)
+	}
+}
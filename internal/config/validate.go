@@ -0,0 +1,72 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// configValidator は、設定の検証で見つかった誤りを集め、最初の誤りで止めずにまとめて返します。
+// 各規則の誤りには設定項目の名前を含めます。
+type configValidator struct {
+	errs []error
+}
+
+// add は、設定項目の名前を含む誤り err を追加します。err が nil の場合は何もしません。
+// 各設定のテーブルの validate() の結果を追加する場合に使用します。
+func (v *configValidator) add(err error) {
+	if err != nil {
+		v.errs = append(v.errs, err)
+	}
+}
+
+// field は、設定項目 name の誤り err を、設定項目の名前を付けて追加します。err が nil の場合は何もしません。
+func (v *configValidator) field(name string, err error) {
+	if err != nil {
+		v.errs = append(v.errs, fmt.Errorf("'%s' が不正です: %w", name, err))
+	}
+}
+
+// item は、配列の設定項目 name の index 番目 (0 から) の誤り err を、設定項目の名前と順番を付けて追加します。err が nil の場合は何もしません。
+func (v *configValidator) item(name string, index int, err error) {
+	if err != nil {
+		v.errs = append(v.errs, fmt.Errorf("'%s' (%d 番目) が不正です: %w", name, index+1, err))
+	}
+}
+
+// check は、条件 ok を満たさない場合に、format の誤りを追加します。複数の設定項目にまたがる規則に使用します。
+func (v *configValidator) check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		v.errs = append(v.errs, fmt.Errorf(format, args...))
+	}
+}
+
+// minInt は、設定項目 name の値が min 以上であることを確認します。
+func (v *configValidator) minInt(name string, value, min int) {
+	v.check(value >= min, "'%s' (%d) は %d 以上である必要があります", name, value, min)
+}
+
+// rangeInt は、設定項目 name の値が min から max の範囲であることを確認します。
+func (v *configValidator) rangeInt(name string, value, min, max int) {
+	v.check(value >= min && value <= max, "'%s' (%d) は %d から %d の範囲である必要があります", name, value, min, max)
+}
+
+// rangeFloat は、設定項目 name の値が min から max の範囲であることを確認します。
+func (v *configValidator) rangeFloat(name string, value, min, max float64) {
+	v.check(value >= min && value <= max, "'%s' (%.2f) は %.2f から %.2f の範囲である必要があります", name, value, min, max)
+}
+
+// oneOf は、設定項目 name の値が allowed のいずれかであることを確認します。
+func (v *configValidator) oneOf(name, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.errs = append(v.errs, fmt.Errorf("'%s' ('%s') は \"%s\" のいずれかである必要があります", name, value, strings.Join(allowed, "\", \"")))
+}
+
+// err は、集めた誤りを1行に1件ずつまとめたエラーを返します。誤りがない場合は nil を返します。
+func (v *configValidator) err() error {
+	return errors.Join(v.errs...)
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigValidatorRules(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		apply func(v *configValidator)
+		want  string // expected error substring, empty if valid
+	}{
+		{"add nil", func(v *configValidator) { v.add(nil) }, ""},
+		{"add", func(v *configValidator) { v.add(errors.New("'x' は不正です")) }, "'x' は不正です"},
+		{"field nil", func(v *configValidator) { v.field("x", nil) }, ""},
+		{"field", func(v *configValidator) { v.field("x", errors.New("重複")) }, "'x' が不正です: 重複"},
+		{"item", func(v *configValidator) { v.item("x", 1, errors.New("重複")) }, "'x' (2 番目) が不正です: 重複"},
+		{"check ok", func(v *configValidator) { v.check(true, "never") }, ""},
+		{"check", func(v *configValidator) { v.check(false, "'%s' と '%s'", "a", "b") }, "'a' と 'b'"},
+		{"minInt ok", func(v *configValidator) { v.minInt("x", 0, 0) }, ""},
+		{"minInt", func(v *configValidator) { v.minInt("x", -1, 0) }, "'x' (-1) は 0 以上"},
+		{"rangeInt ok", func(v *configValidator) { v.rangeInt("x", 23, 0, 23) }, ""},
+		{"rangeInt", func(v *configValidator) { v.rangeInt("x", 24, 0, 23) }, "'x' (24) は 0 から 23 の範囲"},
+		{"rangeFloat ok", func(v *configValidator) { v.rangeFloat("x", 1, 0, 1) }, ""},
+		{"rangeFloat", func(v *configValidator) { v.rangeFloat("x", 1.5, 0, 1) }, "'x' (1.50) は 0.00 から 1.00 の範囲"},
+		{"oneOf ok", func(v *configValidator) { v.oneOf("x", "b", "a", "b") }, ""},
+		{"oneOf", func(v *configValidator) { v.oneOf("x", "c", "a", "b") }, "'x' ('c') は \"a\", \"b\" のいずれか"},
+	} {
+		var v configValidator
+		tc.apply(&v)
+		err := v.err()
+		if tc.want == "" {
+			if err != nil {
+				t.Errorf("%s: err = %v, want nil", tc.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: err = %v, want %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestLoadConfigReportsAllErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `
+target_ip = "192.168.0.10"
+grid_charge_rollover_hour = 24
+target_reached_action = "drain"
+charge_power_gain = 1.5
+
+[ev_charger]
+enabled = true
+instance = 200
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("Load succeeded, want error")
+	}
+	for _, field := range []string{"grid_charge_rollover_hour", "target_reached_action", "charge_power_gain", "ev_charger.instance"} {
+		if !strings.Contains(err.Error(), "'"+field+"'") {
+			t.Errorf("error does not mention %s:\n%v", field, err)
+		}
+	}
+}
+
+func TestLoadConfigControllerEOJ(t *testing.T) {
+	dir := t.TempDir()
+	for i, tc := range []struct {
+		content string
+		want    string // expected EOJ, or error substring
+		ok      bool
+	}{
+		{``, "05FF01", true},
+		{`controller_instance = 2`, "05FF02", true},
+		{`controller_eoj = "05FF7F"`, "05FF7F", true},
+		{`controller_instance = 128`, "'controller_instance'", false},
+		{`controller_eoj = "05FF00"`, "'controller_eoj'", false},
+		{`controller_eoj = "05FF"`, "'controller_eoj'", false},
+		{"controller_eoj = \"05FF02\"\ncontroller_instance = 3", "同時に指定できません", false},
+	} {
+		path := filepath.Join(dir, fmt.Sprintf("config%d.toml", i))
+		if err := os.WriteFile(path, []byte("target_ip = \"192.168.0.10\"\n"+tc.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := Load(path)
+		if !tc.ok {
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("%q: err = %v, want %q", tc.content, err, tc.want)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", tc.content, err)
+		}
+		c := cfg.Controller
+		if got := fmt.Sprintf("%02X%02X%02X", c.ClassGroupCode, c.ClassCode, c.InstanceCode); got != tc.want {
+			t.Errorf("%q: Controller = %s, want %s", tc.content, got, tc.want)
+		}
+	}
+}
+
+func TestLoadConfigChargePowerCapAliases(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("target_ip = \"192.168.0.10\"\n"+content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	cfg, err := Load(write("alias.toml", "power_cap_watts = 2500\nsurplus_margin_watts = 300\ndevice_max_charge_watts = 4000\n"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.MaxChargePowerWatts != 2500 || cfg.SurplusPowerMarginWatts != 300 || cfg.DeviceMaxChargeWatts != 4000 {
+		t.Errorf("max = %d, margin = %d, device max = %d, want 2500, 300, 4000", cfg.MaxChargePowerWatts, cfg.SurplusPowerMarginWatts, cfg.DeviceMaxChargeWatts)
+	}
+
+	cfg, err = Load(write("default.toml", ""))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.DeviceMaxChargeWatts != 5430 {
+		t.Errorf("default device max = %d, want 5430", cfg.DeviceMaxChargeWatts)
+	}
+
+	_, err = Load(write("both.toml", "power_cap_watts = 2500\nmax_charge_power_watts = 3000\nsurplus_margin_watts = 4000\n"))
+	if err == nil {
+		t.Fatal("Load succeeded, want error")
+	}
+	for _, want := range []string{"'power_cap_watts'", "'surplus_power_margin_watts' (4000) は 'max_charge_power_watts' (2500) 未満"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadConfigEnableControl(t *testing.T) {
+	dir := t.TempDir()
+	for i, tc := range []struct {
+		content        string
+		dryRun, enable bool
+		wantErr        bool
+	}{
+		{"", false, true, false},
+		{"enable_control = true", false, true, false},
+		{"enable_control = false", true, false, false},
+		{"enable_control = true\ndry_run = true", false, false, true},
+	} {
+		path := filepath.Join(dir, fmt.Sprintf("config%d.toml", i))
+		if err := os.WriteFile(path, []byte("target_ip = \"192.168.0.10\"\n"+tc.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := Load(path)
+		if tc.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "'enable_control'") {
+				t.Errorf("%q: err = %v, want enable_control error", tc.content, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", tc.content, err)
+		}
+		if cfg.DryRun != tc.dryRun || cfg.ControlEnabled() != tc.enable {
+			t.Errorf("%q: DryRun = %t, ControlEnabled = %t, want %t, %t", tc.content, cfg.DryRun, cfg.ControlEnabled(), tc.dryRun, tc.enable)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Package controller は、蓄電池の運転モードを決める制御の状態機械を提供します。
+//
+// 状態機械は機器との通信を行わず、監視サイクルごとに与えられた入力から状態を遷移させます。
+// 呼び出し側は遷移後の状態に応じて運転モードや充電電力を設定します。
+package controller
+
+import (
+	"fmt"
+	"time"
+)
+
+// State は制御の状態です。
+type State int
+
+const (
+	// Idle は充電時間帯外の状態です。蓄電池は自動 (または待機) モードで運転します。
+	Idle State = iota
+	// Charging は充電時間帯で、余剰電力が十分にあるため充電モードで運転する状態です。
+	Charging
+	// SurplusLimited は充電時間帯で、余剰電力が不足しているため買電を避けて自動 (または待機) モードで運転する状態です。
+	SurplusLimited
+	// Inhibited は充電時間帯で、運転モードの変更後の抑制時間が経過していないため制御を行わない状態です。
+	Inhibited
+	// Fault は機器に到達できないため制御を行わない状態です。
+	Fault
+	// PeakShaving は充電時間帯外のピークカット時間帯で、買電電力が閾値を超えないよう放電する状態です。
+	PeakShaving
+	// DeviceFault は機器が異常の発生を通知しているため制御を行わない状態です。
+	DeviceFault
+)
+
+// String は状態の名前を返します。
+func (s State) String() string {
+	switch s {
+	case Idle:
+		return "Idle"
+	case Charging:
+		return "Charging"
+	case SurplusLimited:
+		return "SurplusLimited"
+	case Inhibited:
+		return "Inhibited"
+	case Fault:
+		return "Fault"
+	case PeakShaving:
+		return "PeakShaving"
+	case DeviceFault:
+		return "DeviceFault"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+// Clock は現在時刻を返します。テストでは任意の時刻を返す実装を注入します。
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// SystemClock は time.Now を使用する Clock を返します。
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+// Config は状態遷移の閾値と時間の設定です。
+type Config struct {
+	ChargeModeThresholdWatts int           // 「充電」に切り替える余剰電力 (W)
+	AutoModeThresholdWatts   int           // 「自動」に切り替える余剰電力 (W)
+	MinChargeModeDwell       time.Duration // 「充電」の最低滞在時間
+	MinAutoModeDwell         time.Duration // 「自動」の最低滞在時間
+	ModeChangeInhibit        time.Duration // 運転モードの変更後、制御を行わない時間
+}
+
+// Input は監視サイクルごとの状態遷移の入力です。
+type Input struct {
+	Reachable           bool // 機器に到達できる
+	InChargingWindow    bool // 充電時間帯である
+	InPeakShavingWindow bool // ピークカットの時間帯である
+	DeviceFault         bool // 機器が異常の発生を通知している
+	SurplusWatts        int  // 余剰電力 (W)。平滑化した値を指定します。
+}
+
+// Controller は制御の状態機械です。
+//
+// 遷移は次のとおりです。
+//   - 機器に到達できない場合は、どの状態からも Fault に遷移します。
+//   - 機器が異常の発生を通知している場合は、どの状態からも DeviceFault に遷移します。
+//   - 充電時間帯外では、ピークカットの時間帯であれば PeakShaving に、それ以外は Idle に遷移します。
+//   - 充電時間帯で、ModeChanged の記録から ModeChangeInhibit が経過していない場合は Inhibited に遷移します。
+//   - それ以外の充電時間帯では、余剰電力のヒステリシスに応じて Charging または SurplusLimited に遷移します。
+type Controller struct {
+	cfg   Config
+	clock Clock
+
+	state          State
+	surplus        *hysteresis
+	lastModeChange time.Time
+}
+
+// New は設定と時刻の取得元を指定して Controller を作成します。初期状態は Idle です。
+func New(cfg Config, clock Clock) *Controller {
+	return &Controller{
+		cfg:     cfg,
+		clock:   clock,
+		state:   Idle,
+		surplus: newHysteresis(cfg.ChargeModeThresholdWatts, cfg.AutoModeThresholdWatts, cfg.MinChargeModeDwell, cfg.MinAutoModeDwell),
+	}
+}
+
+// State は現在の状態を返します。
+func (c *Controller) State() State {
+	return c.state
+}
+
+// Step は入力から状態を遷移させ、遷移前と遷移後の状態を返します。
+func (c *Controller) Step(in Input) (from, to State) {
+	from = c.state
+	now := c.clock.Now()
+	switch {
+	case !in.Reachable:
+		c.state = Fault
+	case in.DeviceFault:
+		c.state = DeviceFault
+	case !in.InChargingWindow:
+		c.surplus.reset()
+		if in.InPeakShavingWindow {
+			c.state = PeakShaving
+		} else {
+			c.state = Idle
+		}
+	case c.InhibitRemaining() > 0:
+		c.state = Inhibited
+	case c.surplus.update(now, in.SurplusWatts):
+		c.state = Charging
+	default:
+		c.state = SurplusLimited
+	}
+	return from, c.state
+}
+
+// SetThresholds は、「充電」と「自動」に切り替える余剰電力の閾値 (W) を変更します。
+// 運転プロファイルの切り替えなどで閾値が変わる場合に使用します。現在のモードと滞在時間は維持します。
+func (c *Controller) SetThresholds(chargeModeWatts, autoModeWatts int) {
+	c.cfg.ChargeModeThresholdWatts = chargeModeWatts
+	c.cfg.AutoModeThresholdWatts = autoModeWatts
+	c.surplus.enterWatts = chargeModeWatts
+	c.surplus.exitWatts = autoModeWatts
+}
+
+// SetConfig は、閾値と時間の設定を変更します。設定ファイルの再読み込みで使用します。
+// 現在の状態、モードの滞在時間と運転モードの変更時刻は維持します。
+func (c *Controller) SetConfig(cfg Config) {
+	c.cfg = cfg
+	c.surplus.enterWatts = cfg.ChargeModeThresholdWatts
+	c.surplus.exitWatts = cfg.AutoModeThresholdWatts
+	c.surplus.minCharging = cfg.MinChargeModeDwell
+	c.surplus.minAuto = cfg.MinAutoModeDwell
+}
+
+// ModeChanged は、運転モードを変更したことを記録します。
+// 充電時間帯では、記録から ModeChangeInhibit が経過するまで Inhibited に遷移します。
+func (c *Controller) ModeChanged() {
+	c.lastModeChange = c.clock.Now()
+}
+
+// InhibitRemaining は、運転モードの変更後の抑制時間の残りを返します。抑制中でなければ 0 を返します。
+func (c *Controller) InhibitRemaining() time.Duration {
+	if c.lastModeChange.IsZero() {
+		return 0
+	}
+	remaining := c.cfg.ModeChangeInhibit - c.clock.Now().Sub(c.lastModeChange)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
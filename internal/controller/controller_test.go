@@ -0,0 +1,263 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when the test advances it.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestController() (*Controller, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 6, 1, 10, 0, 0, 0, time.Local)}
+	c := New(Config{
+		ChargeModeThresholdWatts: 1000,
+		AutoModeThresholdWatts:   500,
+		ModeChangeInhibit:        10 * time.Minute,
+	}, clock)
+	return c, clock
+}
+
+var (
+	outsideWindow = Input{Reachable: true, InChargingWindow: false, SurplusWatts: 2000}
+	highSurplus   = Input{Reachable: true, InChargingWindow: true, SurplusWatts: 2000}
+	lowSurplus    = Input{Reachable: true, InChargingWindow: true, SurplusWatts: 0}
+	unreachable   = Input{Reachable: false, InChargingWindow: true, SurplusWatts: 2000}
+	peakWindow    = Input{Reachable: true, InChargingWindow: false, InPeakShavingWindow: true}
+	deviceFault   = Input{Reachable: true, InChargingWindow: true, SurplusWatts: 2000, DeviceFault: true}
+)
+
+func step(t *testing.T, c *Controller, in Input, wantFrom, wantTo State) {
+	t.Helper()
+	from, to := c.Step(in)
+	if from != wantFrom || to != wantTo {
+		t.Errorf("Step(%+v) = %s -> %s, want %s -> %s", in, from, to, wantFrom, wantTo)
+	}
+	if c.State() != to {
+		t.Errorf("State() = %s after Step returned %s", c.State(), to)
+	}
+}
+
+func TestControllerStartsIdle(t *testing.T) {
+	c, _ := newTestController()
+	if c.State() != Idle {
+		t.Errorf("initial state = %s, want Idle", c.State())
+	}
+	step(t, c, outsideWindow, Idle, Idle)
+}
+
+func TestControllerIdleToCharging(t *testing.T) {
+	c, _ := newTestController()
+	step(t, c, highSurplus, Idle, Charging)
+}
+
+func TestControllerIdleToSurplusLimited(t *testing.T) {
+	c, _ := newTestController()
+	step(t, c, lowSurplus, Idle, SurplusLimited)
+}
+
+func TestControllerChargingToSurplusLimitedAndBack(t *testing.T) {
+	c, clock := newTestController()
+	step(t, c, highSurplus, Idle, Charging)
+	clock.advance(time.Minute)
+	step(t, c, Input{Reachable: true, InChargingWindow: true, SurplusWatts: 700}, Charging, Charging) // between thresholds
+	clock.advance(time.Minute)
+	step(t, c, lowSurplus, Charging, SurplusLimited)
+	clock.advance(time.Minute)
+	step(t, c, Input{Reachable: true, InChargingWindow: true, SurplusWatts: 700}, SurplusLimited, SurplusLimited)
+	clock.advance(time.Minute)
+	step(t, c, highSurplus, SurplusLimited, Charging)
+}
+
+func TestControllerSurplusLimitedToInhibited(t *testing.T) {
+	c, clock := newTestController()
+	step(t, c, lowSurplus, Idle, SurplusLimited)
+	c.ModeChanged()
+
+	clock.advance(time.Minute)
+	step(t, c, highSurplus, SurplusLimited, Inhibited)
+	if got := c.InhibitRemaining(); got != 9*time.Minute {
+		t.Errorf("InhibitRemaining() = %s, want 9m", got)
+	}
+	clock.advance(8 * time.Minute)
+	step(t, c, highSurplus, Inhibited, Inhibited)
+}
+
+func TestControllerInhibitedToCharging(t *testing.T) {
+	c, clock := newTestController()
+	step(t, c, lowSurplus, Idle, SurplusLimited)
+	c.ModeChanged()
+	clock.advance(time.Minute)
+	step(t, c, highSurplus, SurplusLimited, Inhibited)
+
+	clock.advance(9 * time.Minute)
+	if got := c.InhibitRemaining(); got != 0 {
+		t.Errorf("InhibitRemaining() = %s after the inhibit time, want 0", got)
+	}
+	step(t, c, highSurplus, Inhibited, Charging)
+}
+
+func TestControllerInhibitedToSurplusLimited(t *testing.T) {
+	c, clock := newTestController()
+	step(t, c, lowSurplus, Idle, SurplusLimited)
+	c.ModeChanged()
+	clock.advance(time.Minute)
+	step(t, c, lowSurplus, SurplusLimited, Inhibited)
+
+	clock.advance(10 * time.Minute)
+	step(t, c, lowSurplus, Inhibited, SurplusLimited)
+}
+
+func TestControllerInhibitedToIdle(t *testing.T) {
+	c, clock := newTestController()
+	step(t, c, lowSurplus, Idle, SurplusLimited)
+	c.ModeChanged()
+	clock.advance(time.Minute)
+	step(t, c, lowSurplus, SurplusLimited, Inhibited)
+
+	// The inhibit time only applies inside the charging window.
+	step(t, c, outsideWindow, Inhibited, Idle)
+}
+
+func TestControllerChargingToIdle(t *testing.T) {
+	c, clock := newTestController()
+	step(t, c, highSurplus, Idle, Charging)
+	clock.advance(time.Minute)
+	step(t, c, outsideWindow, Charging, Idle)
+}
+
+func TestControllerSurplusLimitedToIdle(t *testing.T) {
+	c, clock := newTestController()
+	step(t, c, lowSurplus, Idle, SurplusLimited)
+	clock.advance(time.Minute)
+	step(t, c, outsideWindow, SurplusLimited, Idle)
+}
+
+func TestControllerIdleResetsSurplusHysteresis(t *testing.T) {
+	c, clock := newTestController()
+	step(t, c, lowSurplus, Idle, SurplusLimited)
+	clock.advance(time.Minute)
+	step(t, c, outsideWindow, SurplusLimited, Idle)
+
+	// After leaving the window the hysteresis starts over, so a surplus between the thresholds charges.
+	clock.advance(time.Minute)
+	step(t, c, Input{Reachable: true, InChargingWindow: true, SurplusWatts: 700}, Idle, Charging)
+}
+
+func TestControllerAnyStateToFault(t *testing.T) {
+	for _, setup := range []struct {
+		name  string
+		input Input
+		want  State
+	}{
+		{"Idle", outsideWindow, Idle},
+		{"Charging", highSurplus, Charging},
+		{"SurplusLimited", lowSurplus, SurplusLimited},
+	} {
+		c, clock := newTestController()
+		step(t, c, setup.input, Idle, setup.want)
+		clock.advance(time.Minute)
+		step(t, c, unreachable, setup.want, Fault)
+	}
+
+	c, clock := newTestController()
+	step(t, c, lowSurplus, Idle, SurplusLimited)
+	c.ModeChanged()
+	clock.advance(time.Minute)
+	step(t, c, lowSurplus, SurplusLimited, Inhibited)
+	step(t, c, unreachable, Inhibited, Fault)
+	step(t, c, unreachable, Fault, Fault)
+}
+
+func TestControllerFaultRecovery(t *testing.T) {
+	c, clock := newTestController()
+	step(t, c, unreachable, Idle, Fault)
+	clock.advance(time.Minute)
+	step(t, c, outsideWindow, Fault, Idle)
+
+	step(t, c, unreachable, Idle, Fault)
+	clock.advance(time.Minute)
+	step(t, c, highSurplus, Fault, Charging)
+}
+
+func TestControllerDeviceFault(t *testing.T) {
+	c, clock := newTestController()
+	step(t, c, highSurplus, Idle, Charging)
+	clock.advance(time.Minute)
+	step(t, c, deviceFault, Charging, DeviceFault)
+	step(t, c, Input{Reachable: false, DeviceFault: true}, DeviceFault, Fault) // unreachable takes precedence
+	step(t, c, deviceFault, Fault, DeviceFault)
+	clock.advance(time.Minute)
+	step(t, c, outsideWindow, DeviceFault, Idle)
+}
+
+func TestControllerIdleToPeakShavingAndBack(t *testing.T) {
+	c, clock := newTestController()
+	step(t, c, peakWindow, Idle, PeakShaving)
+	clock.advance(time.Minute)
+	step(t, c, peakWindow, PeakShaving, PeakShaving)
+	clock.advance(time.Minute)
+	step(t, c, outsideWindow, PeakShaving, Idle)
+}
+
+func TestControllerChargingWindowOverridesPeakShaving(t *testing.T) {
+	c, clock := newTestController()
+	step(t, c, peakWindow, Idle, PeakShaving)
+	clock.advance(time.Minute)
+	overlap := highSurplus
+	overlap.InPeakShavingWindow = true
+	step(t, c, overlap, PeakShaving, Charging)
+	clock.advance(time.Minute)
+	step(t, c, peakWindow, Charging, PeakShaving)
+}
+
+func TestControllerPeakShavingFault(t *testing.T) {
+	c, clock := newTestController()
+	step(t, c, peakWindow, Idle, PeakShaving)
+	clock.advance(time.Minute)
+	step(t, c, unreachable, PeakShaving, Fault)
+	clock.advance(time.Minute)
+	step(t, c, peakWindow, Fault, PeakShaving)
+}
+
+func TestStateString(t *testing.T) {
+	if got := SurplusLimited.String(); got != "SurplusLimited" {
+		t.Errorf("SurplusLimited.String() = %q", got)
+	}
+	if got := State(99).String(); got != "State(99)" {
+		t.Errorf("State(99).String() = %q", got)
+	}
+}
+
+func TestControllerSetThresholds(t *testing.T) {
+	c, clock := newTestController()
+	step(t, c, highSurplus, Idle, Charging)
+	clock.advance(time.Minute)
+
+	// A surplus of 700 W keeps charging with the initial thresholds but not after raising them.
+	mid := Input{Reachable: true, InChargingWindow: true, SurplusWatts: 700}
+	step(t, c, mid, Charging, Charging)
+	c.SetThresholds(1500, 800)
+	clock.advance(time.Minute)
+	step(t, c, mid, Charging, SurplusLimited)
+}
+
+func TestControllerSetConfigKeepsModeChange(t *testing.T) {
+	c, clock := newTestController()
+	step(t, c, lowSurplus, Idle, SurplusLimited)
+	c.ModeChanged()
+	clock.advance(time.Minute)
+
+	// The recorded mode change survives the new settings and is measured against the new inhibit time.
+	c.SetConfig(Config{ChargeModeThresholdWatts: 1000, AutoModeThresholdWatts: 500, ModeChangeInhibit: 5 * time.Minute})
+	step(t, c, highSurplus, SurplusLimited, Inhibited)
+	if got := c.InhibitRemaining(); got != 4*time.Minute {
+		t.Errorf("InhibitRemaining() = %s, want 4m", got)
+	}
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"log"
+	"time"
+)
+
+// hysteresis は、余剰電力に応じた「充電」と「自動」の切り替えにヒステリシスを持たせます。
+// 「自動」への切り替えは余剰電力が exitWatts を下回ったとき、「充電」への復帰は enterWatts 以上になったときに行い、
+// さらに各モードを最低滞在時間が経過するまで維持することで、閾値付近でのモードの頻繁な切り替えを防ぎます。
+type hysteresis struct {
+	enterWatts  int           // 「充電」に切り替える余剰電力 (W)
+	exitWatts   int           // 「自動」に切り替える余剰電力 (W)
+	minCharging time.Duration // 「充電」の最低滞在時間
+	minAuto     time.Duration // 「自動」の最低滞在時間
+
+	charging bool
+	since    time.Time // 現在のモードになった時刻
+}
+
+// newHysteresis は、切り替えの閾値と各モードの最低滞在時間を指定して hysteresis を作成します。
+func newHysteresis(enterWatts, exitWatts int, minCharging, minAuto time.Duration) *hysteresis {
+	return &hysteresis{
+		enterWatts:  enterWatts,
+		exitWatts:   exitWatts,
+		minCharging: minCharging,
+		minAuto:     minAuto,
+	}
+}
+
+// update は、現在の余剰電力 (W) からモードを更新し、「充電」であれば true を返します。
+// 初回は、余剰電力が exitWatts 以上であれば「充電」とします。
+func (h *hysteresis) update(now time.Time, surplusWatts int) bool {
+	if h.since.IsZero() {
+		h.charging = surplusWatts >= h.exitWatts
+		h.since = now
+		return h.charging
+	}
+
+	if h.charging {
+		if surplusWatts < h.exitWatts {
+			if dwell := now.Sub(h.since); dwell < h.minCharging {
+				log.Printf("[制御] 余剰電力 (%d W) が閾値 (%d W) を下回っていますが、「充電」の最低滞在時間が経過していないため維持します（残り: %s）。", surplusWatts, h.exitWatts, (h.minCharging - dwell).Truncate(time.Second))
+			} else {
+				h.charging = false
+				h.since = now
+			}
+		}
+	} else {
+		if surplusWatts >= h.enterWatts {
+			if dwell := now.Sub(h.since); dwell < h.minAuto {
+				log.Printf("[制御] 余剰電力 (%d W) が閾値 (%d W) 以上ですが、「自動」の最低滞在時間が経過していないため維持します（残り: %s）。", surplusWatts, h.enterWatts, (h.minAuto - dwell).Truncate(time.Second))
+			} else {
+				h.charging = true
+				h.since = now
+			}
+		}
+	}
+	return h.charging
+}
+
+// reset は、充電時間帯の終了時などにモードの状態を初期化します。
+func (h *hysteresis) reset() {
+	h.charging = false
+	h.since = time.Time{}
+}
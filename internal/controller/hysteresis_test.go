@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHysteresisThresholds(t *testing.T) {
+	h := newHysteresis(1000, 500, 0, 0)
+	base := time.Date(2025, 6, 1, 10, 0, 0, 0, time.Local)
+
+	steps := []struct {
+		surplus int
+		want    bool
+	}{
+		{800, true},  // initial: above the exit threshold
+		{600, true},  // between thresholds: stay charging
+		{400, false}, // below exit: auto
+		{900, false}, // between thresholds: stay auto
+		{1000, true}, // reaches enter: charge
+		{499, false},
+	}
+	for i, s := range steps {
+		if got := h.update(base.Add(time.Duration(i)*time.Minute), s.surplus); got != s.want {
+			t.Errorf("step %d (surplus %d W): charging = %t, want %t", i, s.surplus, got, s.want)
+		}
+	}
+}
+
+func TestHysteresisDwell(t *testing.T) {
+	h := newHysteresis(1000, 500, 10*time.Minute, 5*time.Minute)
+	base := time.Date(2025, 6, 1, 10, 0, 0, 0, time.Local)
+
+	h.update(base, 2000)
+	if !h.update(base.Add(9*time.Minute), 0) {
+		t.Errorf("left charge mode before the minimum dwell time")
+	}
+	if h.update(base.Add(10*time.Minute), 0) {
+		t.Errorf("stayed in charge mode after the minimum dwell time")
+	}
+	if h.update(base.Add(14*time.Minute), 2000) {
+		t.Errorf("left auto mode before the minimum dwell time")
+	}
+	if !h.update(base.Add(15*time.Minute), 2000) {
+		t.Errorf("stayed in auto mode after the minimum dwell time")
+	}
+
+	h.reset()
+	if h.update(base.Add(16*time.Minute), 0) {
+		t.Errorf("state after reset should follow the current surplus")
+	}
+}
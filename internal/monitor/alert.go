@@ -0,0 +1,242 @@
+package monitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// LINE Messaging API のエンドポイント
+const (
+	linePushURL      = "https://api.line.me/v2/bot/message/push"
+	lineBroadcastURL = "https://api.line.me/v2/bot/message/broadcast"
+)
+
+// WebhookAlerter は、出来事を Webhook と LINE、ntfy、Pushover に通知します。
+// 同じ種類の出来事は min_interval_minutes に1回までとし、その間に抑制した件数は次の通知に含めます。
+// 監視ループを止めないよう、送信はキューに入れて別の goroutine で行い、キューがいっぱいの場合は破棄します。
+// ゼロ値は何も通知しません (通知が無効の場合に使用します)。
+type WebhookAlerter struct {
+	cfg         config.AlertsConfig
+	client      *http.Client
+	now         func() time.Time // テストで差し替えるため
+	lineURL     string           // LINE Messaging API のエンドポイント (テストで差し替えるため)
+	pushoverURL string           // Pushover のエンドポイント (テストで差し替えるため)
+	queue       chan alertMessage
+	done        chan struct{}
+
+	mu         sync.Mutex
+	lastSent   map[config.AlertEvent]time.Time
+	suppressed map[config.AlertEvent]int
+	closed     bool
+}
+
+// NewWebhookAlerter は、設定に基づいて WebhookAlerter を作成し、送信を開始します。
+func NewWebhookAlerter(cfg config.AlertsConfig) *WebhookAlerter {
+	a := &WebhookAlerter{
+		cfg:        cfg,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		now:        time.Now,
+		queue:      make(chan alertMessage, 16),
+		done:       make(chan struct{}),
+		lastSent:   make(map[config.AlertEvent]time.Time),
+		suppressed: make(map[config.AlertEvent]int),
+	}
+	a.pushoverURL = pushoverMessagesURL
+	a.lineURL = lineBroadcastURL
+	if cfg.Line.To != "" {
+		a.lineURL = linePushURL
+	}
+	go a.run()
+	return a
+}
+
+// alertMessage は、送信を待つ通知です。
+type alertMessage struct {
+	event config.AlertEvent // 出来事の種類。蓄電池のまとめ (LINE にのみ送信する) の場合は空
+	text  string
+}
+
+// fire は、event が有効で、前回の同じ種類の通知から min_interval_minutes が経過していれば message を通知します。
+func (a *WebhookAlerter) fire(event config.AlertEvent, message string) {
+	if a.queue == nil || !a.cfg.Events.Enabled(event) {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return
+	}
+	now := a.now()
+	if last, ok := a.lastSent[event]; ok && now.Sub(last) < time.Duration(a.cfg.MinIntervalMinutes)*time.Minute {
+		a.suppressed[event]++
+		return
+	}
+	if n := a.suppressed[event]; n > 0 {
+		message += fmt.Sprintf(" (前回の通知以降、同じ種類の通知を %d 件抑制しました)", n)
+	}
+	a.lastSent[event], a.suppressed[event] = now, 0
+	a.enqueue(alertMessage{event: event, text: message}, string(event))
+}
+
+// dailySummary は、毎日の蓄電池のまとめを LINE に送信します。出来事の有効・無効と送信の間隔は適用しません。
+func (a *WebhookAlerter) dailySummary(message string) {
+	if a.queue == nil || !a.cfg.Line.Enabled || a.cfg.Line.DailySummaryTime == "" {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.closed {
+		a.enqueue(alertMessage{text: message}, "蓄電池のまとめ")
+	}
+}
+
+// enqueue は、通知を送信のキューに入れます。呼び出し側で mu を保持します。
+func (a *WebhookAlerter) enqueue(msg alertMessage, name string) {
+	select {
+	case a.queue <- msg:
+	default:
+		log.Printf("[アラート] 送信が追いつかないため、通知 (%s) を破棄しました。", name)
+	}
+}
+
+// run は、キューの通知を順に送信します。close でキューが閉じられると、残りを送信してから終了します。
+func (a *WebhookAlerter) run() {
+	defer close(a.done)
+	for msg := range a.queue {
+		text := msg.text
+		if msg.event != "" {
+			text = "[eibs7-controller] " + text
+		}
+		if a.cfg.WebhookURL != "" && msg.event != "" {
+			if err := a.post(string(a.cfg.WebhookURL), "", a.webhookPayload(text)); err != nil {
+				log.Printf("[アラート] Webhook への送信に失敗しました: %v", err)
+			}
+		}
+		if a.cfg.Line.Enabled {
+			if err := a.post(a.lineURL, a.cfg.Line.ChannelToken, a.linePayload(text)); err != nil {
+				log.Printf("[アラート] LINE への送信に失敗しました: %v", err)
+			}
+		}
+		// ntfy と Pushover はタイトルにコントローラー名を表示するため、本文には付けない
+		if a.cfg.Ntfy.Enabled && msg.event != "" && a.cfg.Ntfy.Accepts(msg.event) {
+			if err := a.postNtfy(msg); err != nil {
+				log.Printf("[アラート] ntfy への送信に失敗しました: %v", err)
+			}
+		}
+		if a.cfg.Pushover.Enabled && msg.event != "" && a.cfg.Pushover.Accepts(msg.event) {
+			if err := a.postPushover(msg); err != nil {
+				log.Printf("[アラート] Pushover への送信に失敗しました: %v", err)
+			}
+		}
+	}
+}
+
+// webhookPayload は、Webhook の形式に合わせた本文を返します。
+func (a *WebhookAlerter) webhookPayload(text string) interface{} {
+	if a.cfg.Format == config.AlertFormatDiscord {
+		return map[string]string{"content": text}
+	}
+	return map[string]string{"text": text}
+}
+
+// linePayload は、LINE Messaging API のテキストメッセージの本文を返します。送信先を指定しない場合はブロードキャストします。
+func (a *WebhookAlerter) linePayload(text string) interface{} {
+	type message struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	return struct {
+		To       string    `json:"to,omitempty"`
+		Messages []message `json:"messages"`
+	}{a.cfg.Line.To, []message{{"text", text}}}
+}
+
+// post は、1つの通知を JSON で送信します。token を指定した場合は Authorization: Bearer に設定します。
+func (a *WebhookAlerter) post(endpoint string, token config.Secret, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+string(token))
+	}
+	return a.do(req)
+}
+
+// do は、通知のリクエストを送信し、2xx 以外の応答をエラーにします。
+func (a *WebhookAlerter) do(req *http.Request) error {
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Close は、キューの残りを送信してから終了します。
+func (a *WebhookAlerter) Close() {
+	if a.queue == nil {
+		return
+	}
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return
+	}
+	a.closed = true
+	close(a.queue)
+	a.mu.Unlock()
+	<-a.done
+}
+
+// sendDailySummary は、alerts.line.daily_summary_time を過ぎた最初の監視サイクルで、蓄電池のまとめを LINE に送信します。
+// 起動した日は、起動後に送信時刻を迎えた場合のみ送信します。
+func (m *Monitor) sendDailySummary(now time.Time, monitoringData map[string]interface{}) {
+	line := m.cfg.Alerts.Line
+	if !m.cfg.Alerts.Enabled || !line.Enabled || line.DailySummaryTime == "" {
+		return
+	}
+	if m.nextDailySummary.IsZero() {
+		m.nextDailySummary = config.NextOccurrence(now, line.DailySummaryTime)
+		return
+	}
+	if now.Before(m.nextDailySummary) {
+		return
+	}
+	m.nextDailySummary = config.NextOccurrence(now, line.DailySummaryTime)
+	m.alerts.dailySummary(m.dailySummary(now, monitoringData))
+}
+
+// dailySummary は、現在の蓄電残量と運転モード、当日の充電・放電・発電電力量をまとめた文章を返します。
+// 電力量は energy_counters が有効な場合のみ含めます。
+func (m *Monitor) dailySummary(now time.Time, monitoringData map[string]interface{}) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[eibs7-controller] 蓄電池のまとめ (%s)", now.Format("2006-01-02 15:04"))
+	if soc, ok := monitoringData["蓄電池.蓄電残量3"].(uint8); ok {
+		fmt.Fprintf(&b, "\n蓄電残量: %d%%", soc)
+	}
+	if mode, ok := monitoringData["蓄電池.運転モード設定"].(uint8); ok {
+		fmt.Fprintf(&b, "\n運転モード: %s", WorkingStatus(mode))
+	}
+	if m.cfg.EnergyCounters {
+		totals := m.energyTotals()
+		fmt.Fprintf(&b, "\n本日の充電電力量: %.1f kWh\n本日の放電電力量: %.1f kWh\n本日の発電電力量: %.1f kWh", totals["充電"], totals["放電"], totals["発電"])
+	}
+	return b.String()
+}
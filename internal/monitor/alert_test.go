@@ -0,0 +1,213 @@
+package monitor
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestWebhookAlerter(t *testing.T) {
+	received := make(chan map[string]string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		received <- body
+	}))
+	defer srv.Close()
+
+	cfg := config.AlertsConfig{Enabled: true, WebhookURL: config.Secret(srv.URL), Format: config.AlertFormatDiscord, Events: config.AlertEvents{Fault: true, Storm: true}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	a := NewWebhookAlerter(cfg)
+	a.now = func() time.Time { return now }
+
+	a.fire(config.AlertFault, "fault 1")
+	a.fire(config.AlertFault, "fault 2")           // rate limited
+	a.fire(config.AlertUnreachable, "unreachable") // disabled
+	a.fire(config.AlertStorm, "storm")             // separate limit per event
+	now = now.Add(30 * time.Minute)
+	a.fire(config.AlertFault, "fault 3")
+	a.Close()
+	close(received)
+
+	var got []string
+	for body := range received {
+		got = append(got, body["content"])
+	}
+	if len(got) != 3 {
+		t.Fatalf("sent %d alerts, want 3: %q", len(got), got)
+	}
+	if got[0] != "[eibs7-controller] fault 1" || got[1] != "[eibs7-controller] storm" {
+		t.Errorf("alerts = %q", got)
+	}
+	if !strings.HasPrefix(got[2], "[eibs7-controller] fault 3") || !strings.Contains(got[2], "1 件抑制") {
+		t.Errorf("alert after the interval = %q, want the suppressed count", got[2])
+	}
+
+	// Firing after close and on the disabled default alerter must not panic.
+	a.fire(config.AlertFault, "after close")
+	(&WebhookAlerter{}).fire(config.AlertFault, "disabled")
+}
+
+func TestWebhookAlerterLine(t *testing.T) {
+	type request struct {
+		path, auth string
+		body       map[string]interface{}
+	}
+	received := make(chan request, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		received <- request{r.URL.Path, r.Header.Get("Authorization"), body}
+	}))
+	defer srv.Close()
+
+	cfg := config.AlertsConfig{
+		Enabled:    true,
+		WebhookURL: config.Secret(srv.URL + "/webhook"),
+		Events:     config.AlertEvents{Fault: true},
+		Line:       config.LineConfig{Enabled: true, ChannelToken: "line-token", To: "U123", DailySummaryTime: "21:00"},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	a := NewWebhookAlerter(cfg)
+	a.lineURL = srv.URL + "/line"
+	a.fire(config.AlertFault, "fault")
+	a.dailySummary("summary")
+	a.Close()
+	close(received)
+
+	var got []request
+	for r := range received {
+		got = append(got, r)
+	}
+	// The alert goes to both channels; the summary only to LINE.
+	if len(got) != 3 || got[0].path != "/webhook" || got[1].path != "/line" || got[2].path != "/line" {
+		t.Fatalf("requests = %+v", got)
+	}
+	if got[1].auth != "Bearer line-token" || got[1].body["to"] != "U123" {
+		t.Errorf("LINE request = %+v", got[1])
+	}
+	messages, _ := got[2].body["messages"].([]interface{})
+	if len(messages) != 1 || messages[0].(map[string]interface{})["text"] != "summary" {
+		t.Errorf("LINE messages = %v", got[2].body["messages"])
+	}
+
+	// LINE alone is enough; a bad summary time is rejected.
+	if err := (&config.AlertsConfig{Enabled: true, Line: config.LineConfig{Enabled: true, ChannelToken: "x", DailySummaryTime: "21:00"}}).Validate(); err != nil {
+		t.Errorf("LINE-only config: %v", err)
+	}
+	if err := (&config.AlertsConfig{Enabled: true, Events: config.AlertEvents{Fault: true}, Line: config.LineConfig{Enabled: true, ChannelToken: "x", DailySummaryTime: "9pm"}}).Validate(); err == nil {
+		t.Error("invalid daily_summary_time accepted")
+	}
+	if err := (&config.AlertsConfig{Enabled: true, Events: config.AlertEvents{Fault: true}, Line: config.LineConfig{Enabled: true}}).Validate(); err == nil {
+		t.Error("LINE without a channel token accepted")
+	}
+}
+
+func TestMonitorDailySummary(t *testing.T) {
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct {
+				Text string `json:"text"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		sent = append(sent, body.Messages[0].Text)
+	}))
+	defer srv.Close()
+
+	now := time.Date(2025, 6, 1, 20, 0, 0, 0, time.Local)
+	m := newTestMonitor(monitortest.NewFakeEIBS7(), now, now.Add(time.Hour))
+	m.cfg.Alerts = config.AlertsConfig{Enabled: true, Line: config.LineConfig{Enabled: true, ChannelToken: "x", DailySummaryTime: "21:00"}}
+	a := NewWebhookAlerter(m.cfg.Alerts)
+	a.lineURL = srv.URL
+	m.alerts = a
+
+	data := map[string]interface{}{"蓄電池.蓄電残量3": uint8(80), "蓄電池.運転モード設定": uint8(0x46)}
+	m.sendDailySummary(now, data)                     // first cycle: only schedules
+	m.sendDailySummary(now.Add(59*time.Minute), data) // before 21:00
+	m.sendDailySummary(now.Add(61*time.Minute), data) // sends
+	m.sendDailySummary(now.Add(62*time.Minute), data) // already sent today
+	a.Close()
+
+	if len(sent) != 1 {
+		t.Fatalf("sent %d summaries, want 1: %q", len(sent), sent)
+	}
+	if !strings.Contains(sent[0], "蓄電残量: 80%") || !strings.Contains(sent[0], "自動 (0x46)") {
+		t.Errorf("summary = %q", sent[0])
+	}
+}
+
+func TestWebhookAlerterPush(t *testing.T) {
+	type request struct {
+		path, contentType, title, priority, auth, body string
+		form                                           map[string]string
+	}
+	received := make(chan request, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := request{path: r.URL.Path, contentType: r.Header.Get("Content-Type"), title: r.Header.Get("Title"), priority: r.Header.Get("Priority"), auth: r.Header.Get("Authorization")}
+		if r.URL.Path == "/pushover" {
+			r.ParseForm()
+			req.form = map[string]string{"token": r.PostForm.Get("token"), "user": r.PostForm.Get("user"), "message": r.PostForm.Get("message"), "priority": r.PostForm.Get("priority")}
+		} else {
+			b, _ := io.ReadAll(r.Body)
+			req.body = string(b)
+		}
+		received <- req
+	}))
+	defer srv.Close()
+
+	cfg := config.AlertsConfig{
+		Enabled:  true,
+		Events:   config.AlertEvents{Fault: true, Storm: true},
+		Ntfy:     config.NtfyConfig{Enabled: true, Server: srv.URL + "/", Topic: "eibs7", Token: "ntfy-token"},
+		Pushover: config.PushoverConfig{Enabled: true, AppToken: "app", UserKey: "user", Priority: 1, Events: []string{"fault"}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	a := NewWebhookAlerter(cfg)
+	a.pushoverURL = srv.URL + "/pushover"
+	a.fire(config.AlertFault, "fault")
+	a.fire(config.AlertStorm, "storm") // not in the Pushover events
+	a.Close()
+	close(received)
+
+	var got []request
+	for r := range received {
+		got = append(got, r)
+	}
+	if len(got) != 3 || got[0].path != "/eibs7" || got[1].path != "/pushover" || got[2].path != "/eibs7" {
+		t.Fatalf("requests = %+v", got)
+	}
+	if got[0].body != "fault" || got[0].title != "eibs7-controller" || got[0].priority != "high" || got[0].auth != "Bearer ntfy-token" {
+		t.Errorf("ntfy request = %+v", got[0])
+	}
+	if f := got[1].form; f["token"] != "app" || f["user"] != "user" || f["message"] != "fault" || f["priority"] != "1" {
+		t.Errorf("Pushover request = %+v", got[1])
+	}
+
+	for _, bad := range []config.AlertsConfig{
+		{Enabled: true, Events: config.AlertEvents{Fault: true}, Ntfy: config.NtfyConfig{Enabled: true}},
+		{Enabled: true, Events: config.AlertEvents{Fault: true}, Ntfy: config.NtfyConfig{Enabled: true, Topic: "t", Priority: "loud"}},
+		{Enabled: true, Events: config.AlertEvents{Fault: true}, Ntfy: config.NtfyConfig{Enabled: true, Topic: "t", Events: []string{"fire"}}},
+		{Enabled: true, Events: config.AlertEvents{Fault: true}, Pushover: config.PushoverConfig{Enabled: true, AppToken: "app"}},
+		{Enabled: true, Events: config.AlertEvents{Fault: true}, Pushover: config.PushoverConfig{Enabled: true, AppToken: "app", UserKey: "user", Priority: 2}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package monitor
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Pushover のメッセージ API のエンドポイント
+const pushoverMessagesURL = "https://api.pushover.net/1/messages.json"
+
+// postNtfy は、1つの通知を ntfy に送信します。
+func (a *WebhookAlerter) postNtfy(msg alertMessage) error {
+	c := a.cfg.Ntfy
+	req, err := http.NewRequest(http.MethodPost, c.Server+"/"+url.PathEscape(c.Topic), strings.NewReader(msg.text))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("Title", "eibs7-controller")
+	req.Header.Set("Priority", c.Priority)
+	req.Header.Set("Tags", string(msg.event))
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+string(c.Token))
+	}
+	return a.do(req)
+}
+
+// postPushover は、1つの通知を Pushover に送信します。
+func (a *WebhookAlerter) postPushover(msg alertMessage) error {
+	c := a.cfg.Pushover
+	form := url.Values{
+		"token":    {string(c.AppToken)},
+		"user":     {string(c.UserKey)},
+		"title":    {"eibs7-controller"},
+		"message":  {msg.text},
+		"priority": {strconv.Itoa(c.Priority)},
+	}
+	req, err := http.NewRequest(http.MethodPost, a.pushoverURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return a.do(req)
+}
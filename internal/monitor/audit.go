@@ -0,0 +1,226 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+)
+
+// 監査ログの制御の判断 (decision)
+const (
+	AuditSkipped      = "skipped"       // 対象機器のアドレスが確定していないため、監視サイクルをスキップした
+	AuditOutage       = "outage"        // 停電中の制御
+	AuditDeviceFault  = "device_fault"  // 機器の異常のため制御を停止した
+	auditImportLimit  = "import_limit"  // 買電制限のため充電電力を下げた
+	auditOverride     = "override"      // 手動操作
+	auditConflict     = "conflict"      // 他のコントローラーとの競合のため自動制御を控えた
+	AuditStorm        = "storm"         // 嵐警戒モード
+	AuditUnreachable  = "unreachable"   // 機器に到達できないため制御をスキップした
+	AuditStrategy     = "strategy"      // 制御方式による制御
+	AuditOutsideCycle = "outside_cycle" // 監視サイクル以外で送信した SetC (終了時の運転モードの設定など)
+)
+
+// AuditRecord は、監査ログの1行です。監視サイクルごとに、判断に使用した入力と閾値、選択した制御、送信した SetC の結果を記録します。
+type AuditRecord struct {
+	Time       time.Time              `json:"time"`
+	Decision   string                 `json:"decision"`
+	Reason     string                 `json:"reason,omitempty"`
+	State      string                 `json:"state,omitempty"`    // 制御の状態機械の状態
+	Strategy   string                 `json:"strategy,omitempty"` // 制御方式
+	Inputs     map[string]interface{} `json:"inputs,omitempty"`
+	Thresholds map[string]int         `json:"thresholds,omitempty"`
+	Action     *AuditAction           `json:"action,omitempty"`
+	Requests   []AuditRequest         `json:"requests,omitempty"`
+
+	Measurements map[string]interface{} `json:"-"` // 監視データと計算値 (履歴の保存用。監査ログには出力しない)
+}
+
+// AuditAction は、制御方式が選択した蓄電池の設定です。変更しない項目は省略します。
+type AuditAction struct {
+	Mode                string `json:"mode,omitempty"`
+	ChargePowerWatts    *int   `json:"charge_power_watts,omitempty"`
+	DischargePowerWatts *int   `json:"discharge_power_watts,omitempty"`
+}
+
+// AuditRequest は、送信した SetC とその結果です。
+// result は "Set_Res", "SetC_SNA", "timeout", "error", "dry_run" (ドライランで送信しなかった), "sent" (応答を待たない送信) のいずれかです。
+type AuditRequest struct {
+	Target string `json:"target"`
+	DEOJ   string `json:"deoj"`
+	EPC    string `json:"epc"`
+	EDT    string `json:"edt"`
+	TID    uint16 `json:"tid"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// DecisionAudit は、制御の判断を JSON Lines 形式でファイルに追記します。
+// フレーム単位のデバッグログとは別に、監視サイクルごとの判断を機械的に解析できるようにします。
+// AddStore で登録した保存先 (測定値の履歴など) にも、監視サイクルごとの記録を渡します。
+// ゼロ値はファイルに記録せず、AddStore で登録した保存先にのみ記録を渡します。
+type DecisionAudit struct {
+	mu     sync.Mutex
+	file   *os.File
+	stores []func(*AuditRecord)
+	record *AuditRecord // 記録中の監視サイクル。監視サイクルの外では nil
+}
+
+// OpenDecisionAudit は、監査ログのファイルを追記で開きます。
+func OpenDecisionAudit(path string) (*DecisionAudit, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("監査ログ '%s' を開けませんでした: %w", path, err)
+	}
+	return &DecisionAudit{file: f}, nil
+}
+
+// AddStore は、監視サイクルごとの記録を受け取る保存先を登録します。
+func (a *DecisionAudit) AddStore(store func(*AuditRecord)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stores = append(a.stores, store)
+}
+
+// begin は、監視サイクルの記録を開始します。
+func (a *DecisionAudit) begin(now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil && len(a.stores) == 0 {
+		return
+	}
+	a.record = &AuditRecord{Time: now, Inputs: make(map[string]interface{}), Thresholds: make(map[string]int)}
+}
+
+// measurements は、監視サイクルで取得した監視データと計算値を記録します。
+func (a *DecisionAudit) measurements(data, computed map[string]interface{}) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.record == nil {
+		return
+	}
+	a.record.Measurements = make(map[string]interface{}, len(data)+len(computed))
+	for k, v := range data {
+		a.record.Measurements[k] = v
+	}
+	for k, v := range computed {
+		a.record.Measurements[k] = v
+	}
+}
+
+// input は、判断に使用した入力を記録します。
+func (a *DecisionAudit) input(name string, value interface{}) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.record != nil {
+		a.record.Inputs[name] = value
+	}
+}
+
+// threshold は、判断に使用した閾値を記録します。
+func (a *DecisionAudit) threshold(name string, value int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.record != nil {
+		a.record.Thresholds[name] = value
+	}
+}
+
+// decide は、監視サイクルの判断と理由を記録します。
+func (a *DecisionAudit) decide(decision, reason string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.record != nil {
+		a.record.Decision, a.record.Reason = decision, reason
+	}
+}
+
+// strategy は、制御方式による判断と、選択した蓄電池の設定を記録します。
+func (a *DecisionAudit) strategy(name, reason, state string, actions controlActions) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.record == nil {
+		return
+	}
+	a.record.Decision, a.record.Reason, a.record.State, a.record.Strategy = AuditStrategy, reason, state, name
+	action := &AuditAction{}
+	if actions.mode != 0 {
+		action.Mode = fmt.Sprintf("0x%X", actions.mode)
+	}
+	if actions.chargePower >= 0 {
+		action.ChargePowerWatts = &actions.chargePower
+	}
+	if actions.dischargePower >= 0 {
+		action.DischargePowerWatts = &actions.dischargePower
+	}
+	a.record.Action = action
+}
+
+// request は、送信した SetC とその結果を記録します。監視サイクルの外で送信した場合は、時刻を now としてその場で1行を書き出します。
+func (a *DecisionAudit) request(now time.Time, target string, frame echonetlite.Frame, result string, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil && len(a.stores) == 0 {
+		return
+	}
+	r := AuditRequest{
+		Target: target,
+		DEOJ:   fmt.Sprintf("%02X%02X%02X", frame.DEOJ.ClassGroupCode, frame.DEOJ.ClassCode, frame.DEOJ.InstanceCode),
+		TID:    uint16(frame.TID),
+		Result: result,
+	}
+	if len(frame.Properties) > 0 {
+		r.EPC, r.EDT = fmt.Sprintf("0x%X", frame.Properties[0].EPC), fmt.Sprintf("%X", frame.Properties[0].EDT)
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if a.record != nil {
+		a.record.Requests = append(a.record.Requests, r)
+		return
+	}
+	a.write(&AuditRecord{Time: now, Decision: AuditOutsideCycle, Requests: []AuditRequest{r}})
+}
+
+// end は、監視サイクルの記録を1行で書き出します。
+func (a *DecisionAudit) end() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.record == nil {
+		return
+	}
+	a.write(a.record)
+	a.record = nil
+}
+
+// write は、監査ログに1行を書き出し、登録された保存先に記録を渡します。呼び出し側で mu を保持します。
+func (a *DecisionAudit) write(record *AuditRecord) {
+	for _, store := range a.stores {
+		store(record)
+	}
+	if a.file == nil {
+		return
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("[監査ログ] 記録の変換に失敗しました: %v", err)
+		return
+	}
+	if _, err := a.file.Write(append(line, '\n')); err != nil {
+		log.Printf("[監査ログ] 書き込みに失敗しました: %v", err)
+	}
+}
+
+// Close は、監査ログのファイルを閉じます。
+func (a *DecisionAudit) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file != nil {
+		a.file.Close()
+		a.file = nil
+	}
+}
@@ -0,0 +1,73 @@
+package monitor
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestDecisionAuditRecordsCycle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decisions.jsonl")
+	a, err := OpenDecisionAudit(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	device := monitortest.NewFakeEIBS7()
+	now := monitortest.Now
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour))
+	m.audit, m.client.audit = a, a
+	m.runCycle()
+	a.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want one per cycle:\n%s", len(lines), data)
+	}
+	var record AuditRecord
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if record.Decision != AuditStrategy || record.State != "Idle" || record.Action == nil {
+		t.Errorf("record = %+v", record)
+	}
+	if record.Inputs["charging_window"] != false || record.Thresholds["max_charge_power_watts"] != 2000 {
+		t.Errorf("inputs = %v, thresholds = %v", record.Inputs, record.Thresholds)
+	}
+	// Outside the charging window the battery is switched from charge to auto mode.
+	if len(record.Requests) != 1 || record.Requests[0].EPC != "0xDA" || record.Requests[0].EDT != "46" || record.Requests[0].Result != "Set_Res" {
+		t.Errorf("requests = %+v", record.Requests)
+	}
+}
+
+func TestDecisionAuditOutsideCycle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decisions.jsonl")
+	a, err := OpenDecisionAudit(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := echonetlite.Frame{
+		TID:        7,
+		DEOJ:       echonetlite.NewEOJ(0x02, 0x7D, 0x01),
+		ESV:        echonetlite.ESVSetC,
+		Properties: []echonetlite.Property{{EPC: 0xDA, PDC: 1, EDT: []byte{0x44}}},
+	}
+	sent := time.Date(2025, 6, 1, 22, 0, 0, 0, time.UTC)
+	a.request(sent, "192.168.0.10", frame, "sent", nil)
+	a.Close()
+
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `"decision":"outside_cycle"`) || !strings.Contains(string(data), `"result":"sent"`) ||
+		!strings.Contains(string(data), `"time":"2025-06-01T22:00:00Z"`) {
+		t.Errorf("audit log = %s", data)
+	}
+}
@@ -0,0 +1,317 @@
+package monitor
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// BacktestSample は、記録した1回の監視サイクルの時刻と監視データです。
+// 監視データはオブジェクト名.プロパティ名ごとに、監視データの CSV と同じ形式の文字列で保持します。
+type BacktestSample struct {
+	Time   time.Time
+	Values map[string]string
+}
+
+// backtestCommand は、backtest で制御が送信した SetC の1つのプロパティです。
+type backtestCommand struct {
+	Time     time.Time
+	IP       string
+	DEOJ     echonetlite.EOJ
+	Property echonetlite.Property
+}
+
+// backtestKey は、backtest の模擬機器のプロパティを指定します。
+type backtestKey struct {
+	ip  string
+	eoj echonetlite.EOJ
+	epc byte
+}
+
+// backtestValue は、記録の値を EDT に変換した結果をキャッシュするキーです。
+type backtestValue struct {
+	eoj      echonetlite.EOJ
+	epc      byte
+	recorded string
+}
+
+// backtestDevice は、記録した監視データで Get に応答し、SetC を記録する模擬機器です。
+// SetC で設定したプロパティは、以降の記録の値の代わりに設定した値で応答します。
+type backtestDevice struct {
+	names    map[backtestKey]string   // プロパティに対応する監視データの名前 (オブジェクト名.プロパティ名)
+	sample   BacktestSample           // 現在の監視サイクルの記録
+	set      map[backtestKey][]byte   // SetC で設定した値
+	encoded  map[backtestValue][]byte // 記録の値を変換した EDT (変換できない場合は nil)
+	commands []backtestCommand
+}
+
+// newBacktestDevice は、監視対象のプロパティと監視データの名前を対応付けた模擬機器を作成します。
+func newBacktestDevice(m *Monitor) *backtestDevice {
+	d := &backtestDevice{
+		names:   make(map[backtestKey]string),
+		set:     make(map[backtestKey][]byte),
+		encoded: make(map[backtestValue][]byte),
+	}
+	for _, t := range m.targets {
+		ip := m.cfg.TargetIP
+		if t.client != nil {
+			ip = t.client.TargetIP
+		}
+		for _, epc := range t.EPCs {
+			d.names[backtestKey{ip, t.EOJ, epc}] = t.ObjectName + "." + echonetlite.PropertyName(t.EOJ, epc)
+		}
+	}
+	return d
+}
+
+// edt は、プロパティの現在の値を返します。SetC で設定した値があればその値を、なければ記録の値を変換して返します。
+func (d *backtestDevice) edt(key backtestKey) []byte {
+	if edt, ok := d.set[key]; ok {
+		return edt
+	}
+	if key.eoj == NodeProfileEOJ && key.epc == 0x80 {
+		return []byte{0x30} // 動作状態: ON (死活確認に応答する)
+	}
+	name, ok := d.names[key]
+	if !ok {
+		return nil
+	}
+	recorded, ok := d.sample.Values[name]
+	if !ok || recorded == "" {
+		return nil
+	}
+	v := backtestValue{key.eoj, key.epc, recorded}
+	edt, ok := d.encoded[v]
+	if !ok {
+		edt, _ = encodeRecordedValue(key.eoj, key.epc, recorded)
+		d.encoded[v] = edt
+	}
+	return edt
+}
+
+// handle は、FakeTransport に送信されたフレームに応答します。値のないプロパティは PDC=0 で応答します。
+func (d *backtestDevice) handle(data []byte, addr *net.UDPAddr) []echonetlite.Datagram {
+	var req echonetlite.Frame
+	if err := req.UnmarshalBinary(data); err != nil {
+		return nil
+	}
+	res := echonetlite.Frame{
+		EHD1: req.EHD1,
+		EHD2: req.EHD2,
+		TID:  req.TID,
+		SEOJ: req.DEOJ,
+		DEOJ: req.SEOJ,
+	}
+	ip := addr.IP.String()
+	switch req.ESV {
+	case echonetlite.ESVGet:
+		res.ESV = echonetlite.ESVGet_Res
+		for _, p := range req.Properties {
+			edt := d.edt(backtestKey{ip, req.DEOJ, p.EPC})
+			res.Properties = append(res.Properties, echonetlite.Property{EPC: p.EPC, PDC: byte(len(edt)), EDT: edt})
+		}
+	case echonetlite.ESVSetC:
+		res.ESV = echonetlite.ESVSet_Res
+		for _, p := range req.Properties {
+			d.set[backtestKey{ip, req.DEOJ, p.EPC}] = append([]byte(nil), p.EDT...)
+			d.commands = append(d.commands, backtestCommand{Time: d.sample.Time, IP: ip, DEOJ: req.DEOJ, Property: p})
+			res.Properties = append(res.Properties, echonetlite.Property{EPC: p.EPC})
+		}
+	default:
+		return nil
+	}
+	res.OPC = byte(len(res.Properties))
+	out, err := res.MarshalBinary()
+	if err != nil {
+		return nil
+	}
+	return []echonetlite.Datagram{{Data: out, Addr: addr}}
+}
+
+// encodeRecordedValue は、記録した監視データの値を、DecodeEDT で同じ値に戻る EDT に変換します。
+// 数値は 1・2・4 バイトの整数と 0.001 倍の積算値 (kWh) を、それ以外は16進数のバイト列と 1・2 バイトのすべての値を試します。
+func encodeRecordedValue(eoj echonetlite.EOJ, epc byte, recorded string) ([]byte, bool) {
+	matches := func(edt []byte) bool {
+		v, _, err := DecodeEDT(eoj, epc, edt)
+		return err == nil && recordedValueEqual(FormatCSVValue(v), recorded)
+	}
+	f, err := strconv.ParseFloat(recorded, 64)
+	numeric := err == nil
+	if numeric {
+		var candidates [][]byte
+		if f == math.Trunc(f) {
+			if f >= 0 && f <= math.MaxUint8 {
+				candidates = append(candidates, []byte{byte(f)})
+			}
+			if f >= 0 && f <= math.MaxUint16 {
+				candidates = append(candidates, binary.BigEndian.AppendUint16(nil, uint16(f)))
+			}
+			if f >= math.MinInt32 && f <= math.MaxUint32 {
+				candidates = append(candidates, binary.BigEndian.AppendUint32(nil, uint32(int64(f))))
+			}
+		}
+		if n := math.Round(f * 1000); n >= 0 && n <= math.MaxUint32 {
+			candidates = append(candidates, binary.BigEndian.AppendUint32(nil, uint32(n)))
+		}
+		for _, edt := range candidates {
+			if matches(edt) {
+				return edt, true
+			}
+		}
+	}
+	// DecodeEDT が値に変換しないプロパティは、バイト列の16進数で記録されている
+	if edt, err := hex.DecodeString(recorded); err == nil && len(edt) > 0 {
+		if v, _, _ := DecodeEDT(eoj, epc, edt); isRawEDT(v) {
+			return edt, true
+		}
+	}
+	if numeric {
+		return nil, false
+	}
+	// 運転動作状態や異常内容などの名前で記録されている値
+	for n := 0; n <= math.MaxUint8; n++ {
+		if edt := []byte{byte(n)}; matches(edt) {
+			return edt, true
+		}
+	}
+	for n := 0; n <= math.MaxUint16; n++ {
+		if edt := binary.BigEndian.AppendUint16(nil, uint16(n)); matches(edt) {
+			return edt, true
+		}
+	}
+	return nil, false
+}
+
+// recordedValueEqual は、監視データの値の文字列が等しいかどうかを返します。数値は丸め誤差を無視して比較します。
+func recordedValueEqual(a, b string) bool {
+	if a == b {
+		return true
+	}
+	x, errX := strconv.ParseFloat(a, 64)
+	y, errY := strconv.ParseFloat(b, 64)
+	return errX == nil && errY == nil && math.Abs(x-y) <= 1e-6*math.Max(1, math.Abs(y))
+}
+
+// BacktestReport は、backtest の結果です。電力量は瞬時値を監視サイクルの間隔で積算した値です。
+type BacktestReport struct {
+	From, To      time.Time
+	Cycles        int
+	Commands      []backtestCommand
+	GridChargeKWh float64 // 制御を模擬した系統からの充電電力量
+	Cost          float64 // GridChargeKWh の推定電気代 (円)
+	RecordedKWh   float64 // 記録の系統からの充電電力量
+	RecordedCost  float64 // RecordedKWh の推定電気代 (円)
+	Priced        bool    // 単価が分かり、電気代を推定したかどうか
+}
+
+// Backtest は、記録を時刻順に制御に入力し、制御が送信した SetC と系統からの充電電力量、推定電気代を集計します。
+// 時計は記録の時刻に合わせて進めます。系統からの充電電力量は、前回までに模擬した運転モードが「充電」または「急速充電」の場合に、
+// 充電電力設定値のうちその時点の余剰電力で賄えない分を系統からの充電とみなします。
+// 単価は price_schedule が有効な場合は単価表のコマの単価、単価表にないコマと無効な場合は price (円/kWh) です。
+func Backtest(cfg *config.Config, samples []BacktestSample, price float64) BacktestReport {
+	now := samples[0].Time
+	cfg.Clock = clockFunc(func() time.Time { return now })
+
+	audit := &DecisionAudit{}
+	var record *AuditRecord
+	audit.AddStore(func(r *AuditRecord) {
+		if r.Decision != AuditOutsideCycle {
+			record = r
+		}
+	})
+
+	client := NewEchonetClient(nil, cfg.TargetIP, time.Duration(cfg.ResponseTimeoutMilliseconds)*time.Millisecond)
+	client.Configure(cfg)
+	m := New(cfg, client, Options{Audit: audit})
+	device := newBacktestDevice(m)
+	client.Transport = echonetlite.NewFakeTransport(device.handle)
+
+	rep := BacktestReport{From: samples[0].Time, To: samples[len(samples)-1].Time}
+	priceAt := func(t time.Time) float64 {
+		if cfg.PriceSchedule.Enabled {
+			m.prices.reload()
+			if p, ok := m.prices.prices[blockStart(t.In(cfg.Loc()))]; ok {
+				rep.Priced = true
+				return p
+			}
+		}
+		if price > 0 {
+			rep.Priced = true
+		}
+		return price
+	}
+	maxGap := 2 * time.Duration(cfg.MonitorIntervalSeconds) * time.Second
+	var prev time.Time
+	for _, s := range samples {
+		now, device.sample, record = s.Time, s, nil
+		charging := device.chargePower(m) // 前回までの監視サイクルで設定した運転モードと充電電力設定値での充電電力
+		RunCycleRecovering(m)
+		rep.Cycles++
+
+		// gridChargeBudget と同様に、監視サイクルの時点の電力を前回の監視サイクルからの経過時間で積算する
+		last := prev
+		prev = s.Time
+		if last.IsZero() || record == nil {
+			continue
+		}
+		surplus, ok := record.Inputs["surplus_watts"].(int32)
+		if !ok {
+			continue
+		}
+		elapsed := s.Time.Sub(last)
+		if elapsed > maxGap {
+			elapsed = maxGap
+		}
+		p := priceAt(last)
+		kwh := gridChargePower(charging, surplus) * elapsed.Hours() / 1000
+		rep.GridChargeKWh += kwh
+		rep.Cost += kwh * p
+		if batteryPower, ok := record.Measurements["蓄電池.瞬時充放電電力計測値"].(int32); ok {
+			kwh := gridChargePower(batteryPower, surplus) * elapsed.Hours() / 1000
+			rep.RecordedKWh += kwh
+			rep.RecordedCost += kwh * p
+		}
+	}
+	rep.Commands = device.commands
+	return rep
+}
+
+// chargePower は、模擬した運転モードと充電電力設定値から、蓄電池の合計の充電電力 (W) を求めます。
+// 運転モードが「充電」または「急速充電」で、満充電ではない蓄電池が充電電力設定値で充電しているとみなします。
+func (d *backtestDevice) chargePower(m *Monitor) int32 {
+	var total int32
+	for _, u := range m.batteries {
+		ip := m.cfg.TargetIP
+		if u.client != nil {
+			ip = u.client.TargetIP
+		}
+		mode := d.edt(backtestKey{ip, u.eoj, 0xDA})
+		if len(mode) != 1 || (mode[0] != 0x41 && mode[0] != 0x42) {
+			continue
+		}
+		if soc := d.edt(backtestKey{ip, u.eoj, 0xE4}); len(soc) == 1 && batteryEdgeOf(m.cfg, soc[0]) == batteryFull {
+			continue
+		}
+		if setting := d.edt(backtestKey{ip, u.eoj, 0xEB}); len(setting) == 4 {
+			total += int32(binary.BigEndian.Uint32(setting))
+		}
+	}
+	return total
+}
+
+// isRawEDT は、DecodeEDT がバイト列のまま返した値かどうかを返します。
+func isRawEDT(v interface{}) bool {
+	_, ok := v.([]byte)
+	return ok
+}
+
+// clockFunc は、現在時刻を返す関数を controller.Clock として使用します。
+type clockFunc func() time.Time
+
+func (f clockFunc) Now() time.Time { return f() }
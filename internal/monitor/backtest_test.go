@@ -0,0 +1,34 @@
+package monitor
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+)
+
+func TestEncodeRecordedValue(t *testing.T) {
+	battery := echonetlite.NewEOJ(0x02, 0x7D, 0x01)
+	for _, tc := range []struct {
+		eoj      echonetlite.EOJ
+		epc      byte
+		recorded string
+		want     []byte
+	}{
+		{battery, 0xE4, "50", []byte{50}},
+		{battery, 0xEB, "2000", binary.BigEndian.AppendUint32(nil, 2000)},
+		{battery, 0xD3, "-500", binary.BigEndian.AppendUint32(nil, uint32(0xFFFFFE0C))},
+		{battery, 0xD6, "12.345", binary.BigEndian.AppendUint32(nil, 12345)},
+		{battery, 0xCF, WorkingStatus(0x42).String(), []byte{0x42}},
+		{echonetlite.NewEOJ(0x02, 0x79, 0x01), 0xE0, "3000", []byte{0x0B, 0xB8}},
+		{battery, 0xF0, "0102", []byte{0x01, 0x02}}, // not decoded, recorded as hex
+	} {
+		got, ok := encodeRecordedValue(tc.eoj, tc.epc, tc.recorded)
+		if !ok || string(got) != string(tc.want) {
+			t.Errorf("encodeRecordedValue(EPC 0x%X, %q) = %X, %t; want %X", tc.epc, tc.recorded, got, ok, tc.want)
+		}
+	}
+	if _, ok := encodeRecordedValue(battery, 0xE4, "300"); ok {
+		t.Error("encodeRecordedValue(E4, 300) succeeded, want failure")
+	}
+}
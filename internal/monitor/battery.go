@@ -0,0 +1,267 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+)
+
+// batteryUnit は、監視・制御する蓄電池の1台と、直近の監視サイクルで取得した値です。
+type batteryUnit struct {
+	eoj    echonetlite.EOJ
+	name   string         // 監視対象の名前 (例: "蓄電池 (027D01)")
+	client *EchonetClient // targets の機器の蓄電池の場合のみ。nil の場合は target_ip の機器
+
+	mode           byte      // 運転モード。取得できなかった場合は 0
+	chargeSetting  int       // 充電電力設定値 (W)。取得できなかった場合は -1
+	soc            int       // 蓄電残量 (%)。取得できなかった場合は -1
+	capacityWh     int       // AC実効容量 (Wh)。取得できなかった場合は -1
+	lastModeChange time.Time // この蓄電池の運転モードを最後に変更した時刻
+	lastSetMode    byte      // 最後に設定した運転モード (他のコントローラーとの競合の検出に使用)。未設定の場合は 0
+	lastSetAt      time.Time // lastSetMode を設定した時刻
+
+	status         WorkingStatus // 運転動作状態。取得できなかった場合は 0
+	statusMismatch bool          // 設定した運転モードと運転動作状態の不一致を警告済みの場合は true
+}
+
+// newBatteryUnits は、インスタンスコードの一覧から蓄電池の一覧を作成します。一覧が空の場合はインスタンス 0x01 のみとします。
+func newBatteryUnits(instances []int) []*batteryUnit {
+	if len(instances) == 0 {
+		instances = []int{1}
+	}
+	units := make([]*batteryUnit, 0, len(instances))
+	for _, i := range instances {
+		units = append(units, &batteryUnit{
+			eoj:  echonetlite.NewEOJ(0x02, 0x7D, byte(i)),
+			name: fmt.Sprintf("蓄電池 (027D%02X)", i),
+		})
+	}
+	return units
+}
+
+// aggregateBatteries は、各蓄電池から取得した値を蓄電池全体の値にまとめ、"蓄電池.<プロパティ名>" のキーで monitoringData に格納します。
+// 蓄電残量は AC実効容量で重み付けした平均、電力と容量は合計です。いずれかの蓄電池で取得できなかった値は格納しません。
+// 蓄電池全体の運転モードを返します。蓄電池ごとに運転モードが異なる場合や取得できなかった場合は 0 を返します。
+func (m *Monitor) aggregateBatteries(monitoringData map[string]interface{}) byte {
+	var (
+		mode                               byte
+		modeOK, socOK, capOK, setOK, powOK = true, true, true, true, true
+		capacity, chargeSetting            uint32
+		power                              int32
+		weightedSOC, socSum                float64
+	)
+	for i, u := range m.batteries {
+		u.mode, u.chargeSetting, u.soc, u.capacityWh, u.status = 0, -1, -1, -1, 0
+
+		if v, ok := monitoringData[u.name+".運転モード設定"].(uint8); ok {
+			u.mode = v
+		}
+		if v, ok := monitoringData[u.name+".運転動作状態"].(WorkingStatus); ok {
+			u.status = v
+		}
+		if i == 0 {
+			mode = u.mode
+		}
+		modeOK = modeOK && u.mode != 0 && u.mode == mode
+
+		if v, ok := monitoringData[u.name+".蓄電残量3"].(uint8); ok {
+			u.soc = int(v)
+			socSum += float64(v)
+		} else {
+			socOK = false
+		}
+		if v, ok := monitoringData[u.name+".AC実効容量（充電）"].(uint32); ok {
+			u.capacityWh = int(v)
+			capacity += v
+			weightedSOC += float64(u.soc) * float64(v)
+		} else {
+			capOK = false
+		}
+		if v, ok := monitoringData[u.name+".充電電力設定値"].(uint32); ok {
+			u.chargeSetting = int(v)
+			chargeSetting += v
+		} else {
+			setOK = false
+		}
+		if v, ok := monitoringData[u.name+".瞬時充放電電力計測値"].(int32); ok {
+			power += v
+		} else {
+			powOK = false
+		}
+	}
+
+	if modeOK {
+		monitoringData["蓄電池.運転モード設定"] = mode
+	} else if len(m.batteries) > 1 {
+		log.Println("[制御] 蓄電池ごとに運転モードが異なるか、取得できなかった蓄電池があります。")
+	}
+	if socOK {
+		soc := socSum / float64(len(m.batteries))
+		if capOK && capacity > 0 {
+			soc = weightedSOC / float64(capacity)
+		}
+		monitoringData["蓄電池.蓄電残量3"] = uint8(soc + 0.5)
+	}
+	if capOK {
+		monitoringData["蓄電池.AC実効容量（充電）"] = capacity
+	}
+	if setOK {
+		monitoringData["蓄電池.充電電力設定値"] = chargeSetting
+	}
+	if powOK {
+		monitoringData["蓄電池.瞬時充放電電力計測値"] = power
+	}
+	if !modeOK {
+		return 0
+	}
+	return mode
+}
+
+// splitPower は、合計の電力 (W) を重みに比例して分配します。端数は重みの最も大きい蓄電池に加えます。
+// 重みの合計が 0 以下の場合は均等に分配します。
+func splitPower(total int, weights []float64) []int {
+	shares := make([]int, len(weights))
+	if len(weights) == 0 {
+		return shares
+	}
+	sum := 0.0
+	largest := 0
+	for i, w := range weights {
+		if w > 0 {
+			sum += w
+		}
+		if w > weights[largest] {
+			largest = i
+		}
+	}
+	assigned := 0
+	for i, w := range weights {
+		if sum <= 0 {
+			shares[i] = total / len(weights)
+		} else if w > 0 {
+			shares[i] = int(float64(total) * w / sum)
+		}
+		assigned += shares[i]
+	}
+	shares[largest] += total - assigned
+	return shares
+}
+
+// chargeWeights は、充電電力の分配に使用する各蓄電池の満充電までの残り容量 (Wh) を返します。
+func (m *Monitor) chargeWeights() []float64 {
+	weights := make([]float64, len(m.batteries))
+	for i, u := range m.batteries {
+		if u.soc >= 0 && u.capacityWh >= 0 {
+			weights[i] = float64(u.capacityWh) * float64(100-u.soc) / 100
+		}
+	}
+	return weights
+}
+
+// dischargeWeights は、放電電力の分配に使用する各蓄電池の蓄電量 (Wh) を返します。
+func (m *Monitor) dischargeWeights() []float64 {
+	weights := make([]float64, len(m.batteries))
+	for i, u := range m.batteries {
+		if u.soc >= 0 && u.capacityWh >= 0 {
+			weights[i] = float64(u.capacityWh) * float64(u.soc) / 100
+		}
+	}
+	return weights
+}
+
+// setOperationMode は、運転モードが mode ではない蓄電池の運転モードを設定し、変更した蓄電池を返します。
+// respectInhibit が true の場合、modeChanged で記録した変更から mode_change_inhibit_minutes が経過していない蓄電池は変更しません。
+func (m *Monitor) setOperationMode(mode byte, respectInhibit bool) ([]*batteryUnit, error) {
+	inhibit := time.Duration(m.cfg.ModeChangeInhibitMinutes) * time.Minute
+	var changed []*batteryUnit
+	var errs []error
+	for _, u := range m.batteries {
+		if u.mode == mode {
+			continue
+		}
+		if respectInhibit && !u.lastModeChange.IsZero() && m.cfg.Now().Sub(u.lastModeChange) < inhibit {
+			log.Printf("[制御] %s はモード変更後、抑制時間が経過していないため（残り: %s）、運転モードを変更しません。", u.name, (inhibit - m.cfg.Now().Sub(u.lastModeChange)).Truncate(time.Second))
+			continue
+		}
+		if err := m.clientFor(u.client).setBatteryOperationModeOf(u.eoj, mode); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", u.name, err))
+			continue
+		}
+		u.mode = mode
+		if !m.clientFor(u.client).DryRun { // ドライランでは実際には変更されないため、競合の検出に使用しない
+			u.lastSetMode, u.lastSetAt, u.statusMismatch = mode, m.cfg.Now(), false
+		}
+		changed = append(changed, u)
+	}
+	return changed, errors.Join(errs...)
+}
+
+// modeChanged は、運転モードを変更したことを制御の状態機械と変更した各蓄電池に記録します。
+// 記録から mode_change_inhibit_minutes が経過するまで、充電時間帯の運転モードの変更を抑制します。
+func (m *Monitor) modeChanged(units []*batteryUnit) {
+	if len(units) == 0 {
+		return
+	}
+	m.controller.ModeChanged()
+	now := m.cfg.Now()
+	for _, u := range units {
+		u.lastModeChange = now
+	}
+}
+
+// setChargePower は、合計の充電電力 (W) を満充電までの残り容量に比例して各蓄電池に分配し、充電電力設定値を設定します。
+// 分配した値は device_max_charge_watts を上限とします。分配した値が現在の設定値と同じ蓄電池には設定しません。
+func (m *Monitor) setChargePower(total int) error {
+	shares := splitPower(total, m.chargeWeights())
+	if len(m.batteries) > 1 {
+		log.Printf("[制御] 充電電力 %d W を蓄電池ごとに分配します: %s", total, m.describeShares(shares))
+	}
+	if max := m.cfg.DeviceMaxChargeWatts; max > 0 {
+		for i, u := range m.batteries {
+			if shares[i] > max {
+				log.Printf("[制御] %s の充電電力 %d W が機器の上限 (%d W) を超えるため、上限に制限します。", u.name, shares[i], max)
+				shares[i] = max
+			}
+		}
+	}
+	var errs []error
+	for i, u := range m.batteries {
+		if u.chargeSetting == shares[i] {
+			continue
+		}
+		if err := m.clientFor(u.client).setBatteryChargePower(u.eoj, shares[i]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", u.name, err))
+			continue
+		}
+		u.chargeSetting = shares[i]
+	}
+	return errors.Join(errs...)
+}
+
+// setDischargePower は、合計の放電電力 (W) を蓄電量に比例して各蓄電池に分配し、放電電力設定値を設定します。
+func (m *Monitor) setDischargePower(total int) error {
+	shares := splitPower(total, m.dischargeWeights())
+	if len(m.batteries) > 1 {
+		log.Printf("[制御] 放電電力 %d W を蓄電池ごとに分配します: %s", total, m.describeShares(shares))
+	}
+	var errs []error
+	for i, u := range m.batteries {
+		if err := m.clientFor(u.client).setBatteryDischargePower(u.eoj, shares[i]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", u.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// describeShares は、蓄電池ごとに分配した電力をログ用の文字列にします。
+func (m *Monitor) describeShares(shares []int) string {
+	parts := make([]string, len(shares))
+	for i, u := range m.batteries {
+		parts[i] = fmt.Sprintf("%s %d W", u.name, shares[i])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,103 @@
+package monitor
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestSplitPower(t *testing.T) {
+	for _, tc := range []struct {
+		total   int
+		weights []float64
+		want    []int
+	}{
+		{2000, []float64{1}, []int{2000}},
+		{2000, []float64{5000, 2000}, []int{1429, 571}},
+		{2000, []float64{0, 3000}, []int{0, 2000}},
+		{1001, []float64{0, 0}, []int{501, 500}},
+		{0, []float64{5000, 2000}, []int{0, 0}},
+	} {
+		if got := splitPower(tc.total, tc.weights); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("splitPower(%d, %v) = %v, want %v", tc.total, tc.weights, got, tc.want)
+		}
+	}
+}
+
+func TestMonitorSplitsChargePowerAcrossBatteries(t *testing.T) {
+	now := monitortest.Now
+	device := monitortest.NewFakeEIBS7()
+	second := echonetlite.NewEOJ(0x02, 0x7D, 0x02)
+	device.Props[second] = map[byte][]byte{
+		0xE4: {80},
+		0xDA: {0x42},
+		0xEB: binary.BigEndian.AppendUint32(nil, 0),
+		0xD3: binary.BigEndian.AppendUint32(nil, 0),
+		0xA0: binary.BigEndian.AppendUint32(nil, 10000),
+	}
+	m := newTestMonitor(device, now.Add(-time.Hour), now.Add(time.Hour), func(cfg *config.Config) {
+		cfg.BatteryInstances = []int{1, 2}
+	})
+
+	m.runCycle()
+
+	// 2000 W is split by the energy left to full: 5000 Wh (50%) and 2000 Wh (80%).
+	if len(device.Sets) != 2 {
+		t.Fatalf("expected 2 SetC, got %d", len(device.Sets))
+	}
+	for i, want := range []struct {
+		eoj   echonetlite.EOJ
+		watts uint32
+	}{
+		{echonetlite.NewEOJ(0x02, 0x7D, 0x01), 1429},
+		{second, 571},
+	} {
+		set := device.Sets[i]
+		if p := set.Properties[0]; set.DEOJ != want.eoj || p.EPC != 0xEB || binary.BigEndian.Uint32(p.EDT) != want.watts {
+			t.Errorf("SetC %d = %v EPC 0x%X EDT %X, want %v charge power %d W", i, set.DEOJ, p.EPC, p.EDT, want.eoj, want.watts)
+		}
+	}
+}
+
+func TestSetChargePowerCapsAtDeviceMax(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	now := monitortest.Now
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour), func(cfg *config.Config) {
+		cfg.DeviceMaxChargeWatts = 1000
+	})
+
+	if err := m.setChargePower(1500); err != nil {
+		t.Fatalf("setChargePower: %v", err)
+	}
+	if len(device.Sets) != 1 {
+		t.Fatalf("expected 1 SetC, got %d", len(device.Sets))
+	}
+	if p := device.Sets[0].Properties[0]; p.EPC != 0xEB || binary.BigEndian.Uint32(p.EDT) != 1000 {
+		t.Errorf("SetC EPC 0x%X EDT %X, want charge power 1000 W", p.EPC, p.EDT)
+	}
+}
+
+func TestMonitorSetsModePerBattery(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	second := echonetlite.NewEOJ(0x02, 0x7D, 0x02)
+	device.Props[second] = map[byte][]byte{0xE4: {60}, 0xDA: {0x46}}
+	now := monitortest.Now
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour), func(cfg *config.Config) {
+		cfg.BatteryInstances = []int{1, 2}
+	})
+
+	m.runCycle()
+
+	// Outside the charging window only the unit still in charge mode is switched to auto.
+	if len(device.Sets) != 1 {
+		t.Fatalf("expected 1 SetC, got %d", len(device.Sets))
+	}
+	if set := device.Sets[0]; set.DEOJ != echonetlite.NewEOJ(0x02, 0x7D, 0x01) || set.Properties[0].EDT[0] != 0x46 {
+		t.Errorf("SetC = %v EDT %X, want 027D01 operation mode 0x46", set.DEOJ, set.Properties[0].EDT)
+	}
+}
@@ -0,0 +1,73 @@
+package monitor
+
+import (
+	"log"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// batteryEdge は、蓄電池が満充電または空に近い状態であるかを表します。
+type batteryEdge int
+
+const (
+	batteryNormal batteryEdge = iota // 満充電でも空でもない
+	batteryFull                      // 満充電 (蓄電残量が full_soc_percent 以上)
+	batteryEmpty                     // 空に近い (蓄電残量が empty_soc_percent 以下)
+)
+
+// String は状態の名前を返します。
+func (e batteryEdge) String() string {
+	switch e {
+	case batteryFull:
+		return "満充電"
+	case batteryEmpty:
+		return "残量なし"
+	default:
+		return "通常"
+	}
+}
+
+// batteryEdgeOf は、設定の満充電・空とみなす蓄電残量をもとに、蓄電残量 (%) から満充電・空に近い状態を判定します。
+func batteryEdgeOf(c *config.Config, soc uint8) batteryEdge {
+	switch {
+	case c.FullSOCPercent > 0 && int(soc) >= c.FullSOCPercent:
+		return batteryFull
+	case int(soc) <= c.EmptySOCPercent:
+		return batteryEmpty
+	default:
+		return batteryNormal
+	}
+}
+
+// suppressAtBatteryEdge は、満充電の間は充電電力設定値の更新を、空に近い間は放電電力設定値の更新と放電モードへの切り替えを取り除きます。
+// 蓄電池が充放電できない間に、効果のない設定要求 (SetC) を監視サイクルごとに送信しないようにするためです。
+// 状態が変化した場合のみ、その状態をログに出力します。
+func (m *Monitor) suppressAtBatteryEdge(a controlActions, ms measurements) controlActions {
+	soc, ok := ms.data["蓄電池.蓄電残量3"].(uint8)
+	if !ok {
+		return a
+	}
+	edge := batteryEdgeOf(m.cfg, soc)
+	if edge != m.batteryEdge {
+		log.Printf("[制御] 蓄電池の状態: %s -> %s (蓄電残量: %d%%)", m.batteryEdge, edge, soc)
+		m.batteryEdge = edge
+	}
+
+	switch edge {
+	case batteryFull:
+		if a.chargePower >= 0 {
+			m.client.debugf("[制御] 満充電のため、充電電力設定値 (%d W) の更新を行いません。", a.chargePower)
+			a.chargePower = -1
+		}
+	case batteryEmpty:
+		if a.dischargePower >= 0 {
+			m.client.debugf("[制御] 蓄電残量がないため、放電電力設定値 (%d W) の更新を行いません。", a.dischargePower)
+			a.dischargePower = -1
+		}
+		if a.mode == 0x43 {
+			log.Println("[制御] 蓄電残量がないため、放電モードではなく待機モードに設定します。")
+			a.mode = 0x44 // 0x44: 待機モード
+		}
+	}
+	return a
+}
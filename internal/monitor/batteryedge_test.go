@@ -0,0 +1,76 @@
+package monitor
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestBatteryEdge(t *testing.T) {
+	cfg := &config.Config{FullSOCPercent: 98, EmptySOCPercent: 5}
+	for _, tc := range []struct {
+		soc  uint8
+		want batteryEdge
+	}{
+		{0, batteryEmpty},
+		{5, batteryEmpty},
+		{6, batteryNormal},
+		{97, batteryNormal},
+		{98, batteryFull},
+		{100, batteryFull},
+	} {
+		if got := batteryEdgeOf(cfg, tc.soc); got != tc.want {
+			t.Errorf("batteryEdgeOf(%d) = %s, want %s", tc.soc, got, tc.want)
+		}
+	}
+}
+
+func TestMonitorStopsChargePowerUpdatesWhenFull(t *testing.T) {
+	now := monitortest.Now
+	device := monitortest.NewFakeEIBS7()
+	device.Props[echonetlite.NewEOJ(0x02, 0x7D, 0x01)][0xE4] = []byte{100}
+	m := newTestMonitor(device, now.Add(-time.Hour), now.Add(time.Hour))
+	m.cfg.FullSOCPercent = 100
+
+	m.runCycle()
+	m.runCycle()
+
+	// The target charge power drops to 0 W at 100%, but a full battery needs no update.
+	if len(device.Sets) != 0 {
+		t.Errorf("expected no SetC for a full battery, got %d", len(device.Sets))
+	}
+	if m.batteryEdge != batteryFull {
+		t.Errorf("batteryEdge = %s, want %s", m.batteryEdge, batteryFull)
+	}
+}
+
+func TestMonitorSuppressesDischargeWhenEmpty(t *testing.T) {
+	now := monitortest.Now
+	device := monitortest.NewFakeEIBS7()
+	device.Props[echonetlite.NewEOJ(0x02, 0x87, 0x01)][0xC6] = binary.BigEndian.AppendUint32(nil, 2800)
+	device.Props[echonetlite.NewEOJ(0x02, 0x7D, 0x01)][0xE4] = []byte{3}
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour))
+	m.cfg.EmptySOCPercent = 5
+	m.cfg.PeakShaving = config.PeakShavingConfig{
+		Enabled:              true,
+		TimeSlot:             config.TimeSlot{StartTime: now.Add(-time.Hour).Format("15:04"), EndTime: now.Add(time.Hour).Format("15:04")},
+		ImportThresholdWatts: 2000,
+	}
+	if err := m.cfg.PeakShaving.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	m.runCycle()
+
+	// Peak shaving asks for 800 W, but an empty battery only stands by.
+	if len(device.Sets) != 1 {
+		t.Fatalf("expected 1 SetC, got %d", len(device.Sets))
+	}
+	if p := device.Sets[0].Properties[0]; p.EPC != 0xDA || p.EDT[0] != 0x44 {
+		t.Errorf("SetC = EPC 0x%X EDT %X, want operation mode 0x44", p.EPC, p.EDT)
+	}
+}
@@ -0,0 +1,67 @@
+package monitor
+
+import "time"
+
+// gridChargeBudget は、1日あたりに系統から蓄電池へ充電した電力量を積算し、上限に達したかどうかを判定します。
+// 段階料金プランなどで、想定外に系統から充電し続けてしまうことを防ぐために使用します。
+// 1日の区切りは rolloverHour 時で、夜間の充電時間帯が日付をまたぐ場合も1回の充電として積算できます。
+type gridChargeBudget struct {
+	limitWh      float64       // 1日あたりの上限 (Wh)。0以下の場合は無制限
+	rolloverHour int           // 積算値をリセットする時刻 (時, 0〜23)
+	maxGap       time.Duration // 積算に使用するサンプル間隔の上限 (通信断などで間隔が空いた場合の過大評価を防ぐ)
+	day          string        // 積算中の日付 (YYYY-MM-DD, rolloverHour 時から翌日の rolloverHour 時までを1日とする)
+	usedWh       float64       // 当日の積算値 (Wh)
+	lastSample   time.Time     // 前回サンプルの時刻
+}
+
+// newGridChargeBudget は、1日あたりの上限 (Wh)、1日の区切りの時刻とサンプル間隔の上限を指定して gridChargeBudget を作成します。
+func newGridChargeBudget(limitWh float64, rolloverHour int, maxGap time.Duration) *gridChargeBudget {
+	return &gridChargeBudget{
+		limitWh:      limitWh,
+		rolloverHour: rolloverHour,
+		maxGap:       maxGap,
+	}
+}
+
+// add は、現在の系統からの充電電力 (W) を前回サンプルからの経過時間で積算します。
+// rolloverHour 時を過ぎて日付が変わった場合は積算値をリセットします。
+func (b *gridChargeBudget) add(now time.Time, gridChargeWatts float64) {
+	today := now.Add(-time.Duration(b.rolloverHour) * time.Hour).Format("2006-01-02")
+	if today != b.day {
+		b.day = today
+		b.usedWh = 0
+		b.lastSample = time.Time{}
+	}
+
+	if !b.lastSample.IsZero() && gridChargeWatts > 0 {
+		elapsed := now.Sub(b.lastSample)
+		if elapsed > b.maxGap {
+			elapsed = b.maxGap
+		}
+		if elapsed > 0 {
+			b.usedWh += gridChargeWatts * elapsed.Hours()
+		}
+	}
+	b.lastSample = now
+}
+
+// exhausted は、当日の積算値が上限に達している場合に true を返します。
+func (b *gridChargeBudget) exhausted() bool {
+	return b.limitWh > 0 && b.usedWh >= b.limitWh
+}
+
+// gridChargePower は、蓄電池の充電電力のうち系統から供給されている分 (W) を推定します。
+// 余剰電力 (太陽光発電 - 自家消費) で賄えない充電電力を系統からの充電とみなします。
+func gridChargePower(batteryPower, surplusPower int32) float64 {
+	if batteryPower <= 0 {
+		return 0 // 放電中または停止中
+	}
+	fromPV := surplusPower
+	if fromPV < 0 {
+		fromPV = 0
+	}
+	if batteryPower <= fromPV {
+		return 0
+	}
+	return float64(batteryPower - fromPV)
+}
@@ -0,0 +1,88 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGridChargeBudgetAccumulatesAndResetsDaily(t *testing.T) {
+	b := newGridChargeBudget(1000, 0, time.Minute) // 1 kWh per day
+	base := time.Date(2025, 1, 1, 1, 0, 0, 0, time.Local)
+
+	b.add(base, 2000) // first sample only sets the reference time
+	if b.usedWh != 0 {
+		t.Fatalf("first sample must not accumulate, got %.2f Wh", b.usedWh)
+	}
+	// 30 samples of 2000 W at 1 minute intervals = 1000 Wh
+	for i := 1; i <= 30; i++ {
+		b.add(base.Add(time.Duration(i)*time.Minute), 2000)
+	}
+	if !b.exhausted() {
+		t.Fatalf("budget should be exhausted after %.2f Wh", b.usedWh)
+	}
+
+	// The next day starts from zero.
+	b.add(base.Add(24*time.Hour), 2000)
+	if b.exhausted() || b.usedWh != 0 {
+		t.Fatalf("budget should reset on a new day, used %.2f Wh", b.usedWh)
+	}
+}
+
+func TestGridChargeBudgetRolloverHour(t *testing.T) {
+	b := newGridChargeBudget(1000, 6, time.Minute)
+	night := time.Date(2025, 1, 1, 23, 0, 0, 0, time.Local)
+
+	// Charging from 23:00 to 01:00 crosses midnight but stays in the same budget day.
+	for i := 0; i <= 120; i++ {
+		b.add(night.Add(time.Duration(i)*time.Minute), 400)
+	}
+	if b.usedWh < 799.9 || b.usedWh > 800.1 {
+		t.Fatalf("expected 800 Wh across midnight, got %.2f", b.usedWh)
+	}
+
+	b.add(time.Date(2025, 1, 2, 5, 59, 0, 0, time.Local), 0)
+	if b.usedWh == 0 {
+		t.Fatalf("budget reset before the rollover hour")
+	}
+	b.add(time.Date(2025, 1, 2, 6, 0, 0, 0, time.Local), 0)
+	if b.usedWh != 0 {
+		t.Fatalf("budget should reset at the rollover hour, used %.2f Wh", b.usedWh)
+	}
+}
+
+func TestGridChargeBudgetCapsLongGaps(t *testing.T) {
+	b := newGridChargeBudget(10000, 0, 20*time.Second)
+	base := time.Date(2025, 1, 1, 1, 0, 0, 0, time.Local)
+	b.add(base, 3600)
+	b.add(base.Add(time.Hour), 3600) // gap is capped at 20s -> 20 Wh
+	if b.usedWh < 19.99 || b.usedWh > 20.01 {
+		t.Fatalf("expected 20 Wh with capped gap, got %.2f", b.usedWh)
+	}
+}
+
+func TestGridChargeBudgetUnlimited(t *testing.T) {
+	b := newGridChargeBudget(0, 0, time.Minute)
+	base := time.Date(2025, 1, 1, 1, 0, 0, 0, time.Local)
+	b.add(base, 5000)
+	b.add(base.Add(time.Minute), 5000)
+	if b.exhausted() {
+		t.Fatalf("budget of 0 must mean unlimited")
+	}
+}
+
+func TestGridChargePower(t *testing.T) {
+	cases := []struct {
+		battery, surplus int32
+		want             float64
+	}{
+		{battery: -500, surplus: 1000, want: 0},  // discharging
+		{battery: 1000, surplus: 1500, want: 0},  // fully covered by PV
+		{battery: 1000, surplus: 400, want: 600}, // partially from grid
+		{battery: 1000, surplus: -300, want: 1000},
+	}
+	for _, c := range cases {
+		if got := gridChargePower(c.battery, c.surplus); got != c.want {
+			t.Errorf("gridChargePower(%d, %d) = %.0f, want %.0f", c.battery, c.surplus, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// getPropertyMaps は、機器の Get プロパティマップ (EPC 0x9F) と Set プロパティマップ (EPC 0x9E) を取得し、
+// 取得と設定に対応している EPC の一覧を返します。
+func (c *EchonetClient) getPropertyMaps(eoj echonetlite.EOJ) (get, set []byte, err error) {
+	responseFrame, err := c.Get(eoj, 0x9F, 0x9E) // Get プロパティマップ, Set プロパティマップ
+	if err != nil {
+		return nil, nil, err
+	}
+	tid := responseFrame.TID
+	if responseFrame.ESV != echonetlite.ESVGet_Res {
+		return nil, nil, fmt.Errorf("予期しないESV (0x%X) を受信しました (TID: %d)", responseFrame.ESV, tid)
+	}
+	for _, prop := range responseFrame.Properties {
+		epcs, err := echonetlite.DecodePropertyMap(prop.EDT)
+		if err != nil {
+			return nil, nil, fmt.Errorf("プロパティマップ (EPC 0x%X) の解析に失敗しました (TID: %d): %w", prop.EPC, tid, err)
+		}
+		switch prop.EPC {
+		case 0x9F:
+			get = epcs
+		case 0x9E:
+			set = epcs
+		}
+	}
+	if get == nil || set == nil {
+		return nil, nil, fmt.Errorf("応答にプロパティマップが含まれていません (TID: %d)", tid)
+	}
+	return get, set, nil
+}
+
+// propertyMaps は、機器ごとに取得したプロパティマップです。
+type propertyMaps struct {
+	get, set []byte
+}
+
+// capabilityChecker は、起動時の機能確認で機器ごとのプロパティマップを一度だけ取得します。
+type capabilityChecker struct {
+	m    *Monitor
+	maps map[*EchonetClient]map[echonetlite.EOJ]*propertyMaps // 取得に失敗した機器は nil
+}
+
+// lookup は、機器のプロパティマップを返します。取得できなかった場合は nil を返します。
+func (c *capabilityChecker) lookup(client *EchonetClient, eoj echonetlite.EOJ) *propertyMaps {
+	client = c.m.clientFor(client)
+	byEOJ, ok := c.maps[client]
+	if !ok {
+		byEOJ = make(map[echonetlite.EOJ]*propertyMaps)
+		c.maps[client] = byEOJ
+	}
+	if maps, ok := byEOJ[eoj]; ok {
+		return maps
+	}
+	get, set, err := client.getPropertyMaps(eoj)
+	if err != nil {
+		log.Printf("[機能確認] %02X%02X%02X (%s) のプロパティマップを取得できませんでした。この機器の機能確認を省略します: %v", eoj.ClassGroupCode, eoj.ClassCode, eoj.InstanceCode, client.TargetIP, err)
+		byEOJ[eoj] = nil
+		return nil
+	}
+	byEOJ[eoj] = &propertyMaps{get: get, set: set}
+	return byEOJ[eoj]
+}
+
+// requiredSet は、機能が設定 (SetC) に使用する機器のプロパティです。
+type requiredSet struct {
+	feature string
+	name    string // 機器の名前
+	client  *EchonetClient
+	eoj     echonetlite.EOJ
+	epcs    []byte
+	disable func() // 機能を無効にする。nil の場合は必須の機能のため、起動を中止する
+}
+
+// requiredSets は、有効な制御機能と、その機能が設定に使用するプロパティの一覧を返します。
+func (m *Monitor) requiredSets() []requiredSet {
+	var reqs []requiredSet
+	for _, u := range m.batteries {
+		reqs = append(reqs, requiredSet{feature: "蓄電池の制御", name: u.name, client: u.client, eoj: u.eoj, epcs: []byte{0xDA, 0xEB}})
+		if m.cfg.PeakShaving.Enabled {
+			reqs = append(reqs, requiredSet{feature: "peak_shaving", name: u.name, client: u.client, eoj: u.eoj, epcs: []byte{0xEC},
+				disable: func() { m.cfg.PeakShaving.Enabled = false }})
+		}
+	}
+	if cfg := m.cfg.EVCharger; cfg.Enabled && cfg.Priority == config.EVPriorityBattery {
+		target := evChargerTarget(cfg)
+		reqs = append(reqs, requiredSet{feature: "ev_charger.priority = \"battery\"", name: target.ObjectName, eoj: target.EOJ, epcs: []byte{0xDA},
+			disable: func() { m.cfg.EVCharger.Priority = config.EVPriorityEV }})
+	}
+	if m.cfg.SurplusDiversion.Enabled {
+		for _, l := range m.cfg.SurplusDiversion.Loads {
+			target := diversionLoadTarget(l)
+			reqs = append(reqs, requiredSet{feature: "surplus_diversion", name: target.ObjectName, eoj: target.EOJ, epcs: []byte{0xB0},
+				disable: func() { m.cfg.SurplusDiversion.Enabled = false }})
+		}
+	}
+	if m.cfg.DemandResponse.Enabled {
+		epc := byte(0x8F) // 節電動作設定
+		if m.cfg.DemandResponse.Action == config.DemandResponseSetpoint {
+			epc = 0xB3 // 温度設定値
+		}
+		for _, instance := range m.cfg.DemandResponse.AirConditioners {
+			target := airConditionerTarget(instance)
+			reqs = append(reqs, requiredSet{feature: "demand_response", name: target.ObjectName, eoj: target.EOJ, epcs: []byte{epc},
+				disable: func() { m.cfg.DemandResponse.Enabled = false }})
+		}
+	}
+	return reqs
+}
+
+// CheckCapabilities は、起動時に各監視対象のプロパティマップ (EPC 0x9F, 0x9E) を取得し、機器が対応していない機能を使用しないようにします。
+// 取得に対応していない EPC は、Get 要求全体がエラー応答 (Get_SNA) になるのを避けるため監視対象から除外します。
+// 有効な制御機能が設定に使用する EPC に機器が対応していない場合は、その機能を無効にします。
+// 蓄電池の運転モードと充電電力設定値の設定に対応していない場合は、制御できないためエラーを返します。
+// プロパティマップを取得できなかった機器は、設定どおり対応しているものとみなします。
+func (m *Monitor) CheckCapabilities() error {
+	checker := &capabilityChecker{m: m, maps: make(map[*EchonetClient]map[echonetlite.EOJ]*propertyMaps)}
+
+	var targets []MonitoringTarget
+	for _, t := range m.targets {
+		maps := checker.lookup(t.client, t.EOJ)
+		if maps == nil {
+			targets = append(targets, t)
+			continue
+		}
+		var epcs, slow []byte
+		for _, epc := range t.EPCs {
+			if !containsEPC(maps.get, epc) {
+				log.Printf("[機能確認] %s はプロパティ %s (EPC: 0x%X) の取得に対応していないため、監視対象から除外します。", t.ObjectName, echonetlite.PropertyName(t.EOJ, epc), epc)
+				continue
+			}
+			epcs = append(epcs, epc)
+			if containsEPC(t.SlowEPCs, epc) {
+				slow = append(slow, epc)
+			}
+		}
+		if len(epcs) == 0 {
+			log.Printf("[機能確認] %s は取得できるプロパティがないため、監視対象から除外します。", t.ObjectName)
+			continue
+		}
+		t.EPCs, t.SlowEPCs = epcs, slow
+		targets = append(targets, t)
+	}
+	m.targets = targets
+
+	disabled := make(map[string]bool)
+	for _, req := range m.requiredSets() {
+		if disabled[req.feature] {
+			continue
+		}
+		maps := checker.lookup(req.client, req.eoj)
+		if maps == nil {
+			continue
+		}
+		for _, epc := range req.epcs {
+			if containsEPC(maps.set, epc) {
+				continue
+			}
+			if req.disable == nil {
+				return fmt.Errorf("%s はプロパティ %s (EPC: 0x%X) の設定に対応していないため、%sができません", req.name, echonetlite.PropertyName(req.eoj, epc), epc, req.feature)
+			}
+			log.Printf("[機能確認] %s はプロパティ %s (EPC: 0x%X) の設定に対応していないため、'%s' を無効にします。", req.name, echonetlite.PropertyName(req.eoj, epc), epc, req.feature)
+			req.disable()
+			disabled[req.feature] = true
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package monitor
+
+import (
+	"strings"
+	"testing"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+// withPropertyMaps gives the fake battery Get and Set property maps in the list format.
+func withPropertyMaps(device *monitortest.FakeEIBS7, get, set []byte) {
+	battery := device.Props[echonetlite.NewEOJ(0x02, 0x7D, 0x01)]
+	battery[0x9F] = append([]byte{byte(len(get))}, get...)
+	battery[0x9E] = append([]byte{byte(len(set))}, set...)
+}
+
+func TestCheckCapabilitiesDropsUnsupportedEPCs(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	withPropertyMaps(device, []byte{0xE4, 0xDA, 0xEB, 0xD3, 0xA0, 0x88}, []byte{0xDA, 0xEB, 0xEC})
+	now := monitortest.Now
+	m := newTestMonitor(device, now, now)
+	pvEPCs := append([]byte(nil), m.targets[1].EPCs...)
+
+	if err := m.CheckCapabilities(); err != nil {
+		t.Fatalf("CheckCapabilities failed: %v", err)
+	}
+	if got := m.targets[0].EPCs; string(got) != string([]byte{0xE4, 0xDA, 0xEB, 0xD3, 0xA0, 0x88}) {
+		t.Errorf("battery EPCs = %X, want CF, D0 and 89 dropped", got)
+	}
+	if got := m.targets[0].SlowEPCs; string(got) != string([]byte{0xA0}) {
+		t.Errorf("battery SlowEPCs = %X, want A0", got)
+	}
+	// Without property maps the target is polled as configured.
+	if got := m.targets[1].EPCs; string(got) != string(pvEPCs) {
+		t.Errorf("PV EPCs = %X, want %X unchanged", got, pvEPCs)
+	}
+}
+
+func TestCheckCapabilitiesDisablesUnsupportedFeatures(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	withPropertyMaps(device, []byte{0xE4, 0xDA, 0xEB, 0xD3, 0xA0}, []byte{0xDA, 0xEB})
+	now := monitortest.Now
+	m := newTestMonitor(device, now, now, func(c *config.Config) {
+		c.PeakShaving.Enabled = true
+	})
+
+	if err := m.CheckCapabilities(); err != nil {
+		t.Fatalf("CheckCapabilities failed: %v", err)
+	}
+	if m.cfg.PeakShaving.Enabled {
+		t.Error("peak shaving enabled without the discharge power setting")
+	}
+}
+
+func TestCheckCapabilitiesRequiresChargePowerSetting(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	withPropertyMaps(device, []byte{0xE4, 0xDA, 0xEB, 0xD3, 0xA0}, []byte{0xDA})
+	now := monitortest.Now
+	m := newTestMonitor(device, now, now)
+
+	err := m.CheckCapabilities()
+	if err == nil || !strings.Contains(err.Error(), "EPC: 0xEB") {
+		t.Errorf("CheckCapabilities = %v, want an error for EPC 0xEB", err)
+	}
+}
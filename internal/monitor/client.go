@@ -0,0 +1,217 @@
+package monitor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/controller"
+)
+
+// EchonetClient は、Transport を介して対象機器と ECHONET Lite フレームを送受信します。
+// 要求と応答のやり取りは echonetlite.Client が行い、ドライランと監査ログ、蓄電池の制御をここで扱います。
+type EchonetClient struct {
+	*echonetlite.Client
+	DryRun    bool              // true の場合、SetC を送信せずに設定内容をログに出力する
+	Debug     bool              // true の場合、デバッグログを出力する (-debug フラグで有効化)
+	batteries []echonetlite.EOJ // 制御する蓄電池 (運転モードの一括設定の宛先)
+
+	audit         *DecisionAudit       // 送信した SetC を記録する監査ログ。New で Monitor と共有する
+	alerts        *WebhookAlerter      // SetC_SNA を通知する。New で Monitor と共有する
+	notifications *notificationDeduper // 受信した INF/INFC 通知の重複を抑制するためのフィルタ
+	notified      *propertyCache       // 通知で受信したプロパティの値 (通知駆動の監視モードで使用)
+}
+
+// NewEchonetClient は、Transport と対象機器のアドレスを指定して EchonetClient を作成します。
+// 受信した通知 (INF/INFC) は、重複を抑制したうえで handleNotification で処理します。
+func NewEchonetClient(transport echonetlite.Transport, targetIP string, timeout time.Duration) *EchonetClient {
+	c := &EchonetClient{
+		Client:        echonetlite.NewClient(transport, targetIP, timeout),
+		batteries:     []echonetlite.EOJ{echonetlite.NewEOJ(0x02, 0x7D, 0x01)}, // 蓄電池
+		audit:         &DecisionAudit{},
+		alerts:        &WebhookAlerter{},
+		notifications: newNotificationDeduper(config.DefaultNotificationDedupWindow),
+		notified:      newPropertyCache(),
+	}
+	c.SEOJ = config.DefaultControllerEOJ
+	c.ReceiveBufferSize = config.DefaultReceiveBufferSize
+	c.Clock = controller.SystemClock()
+	c.Notify = c.acceptNotification
+	c.Debugf = c.debugf
+	return c
+}
+
+// Configure は、設定ファイルの送信元の ECHONET Lite オブジェクト、受信バッファのサイズ、再送の設定、通知の重複抑止の期間を反映します。
+// 要求の送信を始める前に呼び出してください。
+func (c *EchonetClient) Configure(cfg *config.Config) {
+	c.SEOJ = cfg.Controller
+	c.ReceiveBufferSize = cfg.ReceiveBufferSize
+	c.Retries, c.RetryBackoff = cfg.RequestRetries, time.Duration(cfg.RetryBackoffMilliseconds)*time.Millisecond
+	c.notifications = newNotificationDeduper(time.Duration(cfg.NotificationDedupWindowSeconds) * time.Second)
+}
+
+// debugf は、デバッグログが有効な場合のみログを出力します。
+func (c *EchonetClient) debugf(format string, v ...interface{}) {
+	if !c.Debug {
+		return
+	}
+	log.Output(2, "[DEBUG] "+fmt.Sprintf(format, v...))
+}
+
+// acceptNotification は、受信した通知のうち重複していないものを handleNotification で処理します。
+func (c *EchonetClient) acceptNotification(frame echonetlite.Frame, data []byte, addr *net.UDPAddr, received time.Time) {
+	if c.notifications.accept(addr.IP.String(), data, received) {
+		c.handleNotification(frame, addr, received)
+	} else {
+		c.debugf("重複した通知を抑制しました (TID: %d, 送信元: %s, 抑制数累計: %d)", frame.TID, addr.String(), c.notifications.suppressedCount())
+	}
+}
+
+// Peer は、c と送受信用のソケットと応答の待機時間・再送の設定、ドライラン、TID の割り当て、監査ログと通知の処理を共有し、
+// 別の機器 targetIP と通信する EchonetClient を作成します。
+func (c *EchonetClient) Peer(targetIP string) *EchonetClient {
+	return &EchonetClient{
+		Client:        c.Client.Peer(targetIP),
+		DryRun:        c.DryRun,
+		Debug:         c.Debug,
+		batteries:     []echonetlite.EOJ{echonetlite.NewEOJ(0x02, 0x7D, 0x01)}, // 蓄電池
+		audit:         c.audit,
+		alerts:        c.alerts,
+		notifications: c.notifications,
+		notified:      c.notified,
+	}
+}
+
+// setBatteryOperationMode はすべての蓄電池の運転モードを設定します。
+func (c *EchonetClient) setBatteryOperationMode(mode byte) error {
+	var errs []error
+	for _, eoj := range c.batteries {
+		if err := c.setBatteryOperationModeOf(eoj, mode); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// setBatteryOperationModeOf は指定された蓄電池の運転モードを設定します。
+func (c *EchonetClient) setBatteryOperationModeOf(eoj echonetlite.EOJ, mode byte) error {
+	return c.setOperationModeOf(eoj, "蓄電池", mode)
+}
+
+// setOperationModeOf は指定された機器の運転モード設定 (EPC 0xDA) を設定します。device はログ出力用の機器の種類です。
+func (c *EchonetClient) setOperationModeOf(eoj echonetlite.EOJ, device string, mode byte) error {
+	return c.setByteProperty(eoj, device, 0xDA, "運転モード", mode)
+}
+
+// setByteProperty は指定された機器の1バイトのプロパティを設定します。device と name はログ出力用の機器の種類とプロパティ名です。
+func (c *EchonetClient) setByteProperty(eoj echonetlite.EOJ, device string, epc byte, name string, value byte) error {
+	setFrame := c.NewRequest(eoj, echonetlite.ESVSetC, echonetlite.Property{EPC: epc, EDT: []byte{value}}) // 0x61: SetC (応答要)
+	log.Printf("[制御] %s (%02X%02X%02X) の%sを 0x%X に設定します (TID: %d)", device, eoj.ClassGroupCode, eoj.ClassCode, eoj.InstanceCode, name, value, setFrame.TID)
+	return c.sendSetC(setFrame)
+}
+
+// sendSetC は、SetC フレームを送信して応答を確認し、結果を監査ログに記録します。ドライランでは送信しません。
+func (c *EchonetClient) sendSetC(setFrame echonetlite.Frame) error {
+	if c.DryRun {
+		c.logDryRun(setFrame)
+		c.audit.request(c.Clock.Now(), c.TargetIP, setFrame, "dry_run", nil)
+		return nil
+	}
+
+	response, err := c.SendSetC(setFrame)
+	var netErr net.Error
+	switch {
+	case err == nil: // 0x71 - SetCの成功応答
+		log.Printf("[制御] SetC応答(成功)を受信しました (TID: %d, ESV: 0x%X)", response.TID, response.ESV)
+		c.audit.request(c.Clock.Now(), c.TargetIP, setFrame, "Set_Res", nil)
+	case response.ESV == echonetlite.ESVSetC_SNA: // 0x51 - SetCの失敗応答
+		c.audit.request(c.Clock.Now(), c.TargetIP, setFrame, "SetC_SNA", nil)
+		c.alerts.fire(config.AlertSetSNA, fmt.Sprintf("機器 (%s) が設定を拒否しました (SetC_SNA, EPC 0x%X)", c.TargetIP, setFrame.Properties[0].EPC))
+	case errors.As(err, &netErr) && netErr.Timeout():
+		c.audit.request(c.Clock.Now(), c.TargetIP, setFrame, "timeout", err)
+	default:
+		c.audit.request(c.Clock.Now(), c.TargetIP, setFrame, "error", err)
+	}
+	return err
+}
+
+// setBatteryChargePower は指定された蓄電池の充電電力設定値 (EPC 0xEB) を設定します。
+func (c *EchonetClient) setBatteryChargePower(eoj echonetlite.EOJ, power int) error {
+	return c.setBatteryPower(eoj, 0xEB, "充電電力設定値", power)
+}
+
+// setBatteryDischargePower は指定された蓄電池の放電電力設定値 (EPC 0xEC) を設定します。
+func (c *EchonetClient) setBatteryDischargePower(eoj echonetlite.EOJ, power int) error {
+	return c.setBatteryPower(eoj, 0xEC, "放電電力設定値", power)
+}
+
+// setBatteryPower は蓄電池の電力設定値 (4バイト, W) のプロパティを設定します。
+func (c *EchonetClient) setBatteryPower(eoj echonetlite.EOJ, epc byte, name string, power int) error {
+	// 電力値を4バイトのバイト列に変換
+	powerBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(powerBytes, uint32(power))
+
+	setFrame := c.NewRequest(eoj, echonetlite.ESVSetC, echonetlite.Property{EPC: epc, EDT: powerBytes}) // 0x61: SetC (応答要)
+	log.Printf("[制御] 蓄電池 (%02X%02X%02X) の%sを %d W に設定します (TID: %d)", eoj.ClassGroupCode, eoj.ClassCode, eoj.InstanceCode, name, power, setFrame.TID)
+	return c.sendSetC(setFrame)
+}
+
+// logDryRun は、ドライランモードで送信しなかった SetC フレームの内容をログに出力します。
+func (c *EchonetClient) logDryRun(frame echonetlite.Frame) {
+	for _, prop := range frame.Properties {
+		log.Printf("[ドライラン] SetC を送信しません (宛先: %s, DEOJ: %02X%02X%02X, EPC: 0x%X (%s), EDT: %X, TID: %d)",
+			c.TargetIP, frame.DEOJ.ClassGroupCode, frame.DEOJ.ClassCode, frame.DEOJ.InstanceCode, prop.EPC, echonetlite.PropertyName(frame.DEOJ, prop.EPC), prop.EDT, frame.TID)
+	}
+}
+
+// SendBatteryOperationModeNoWait は、すべての蓄電池に運転モードを設定する SetC を送信し、応答を待たずに戻ります。
+// 監視ループが停止している間に別の goroutine から使用するため、受信は行いません
+// (応答は監視ループが再開した後に、TID の一致しない応答として破棄されます)。
+func (c *EchonetClient) SendBatteryOperationModeNoWait(mode byte) error {
+	var errs []error
+	for _, eoj := range c.batteries {
+		if err := c.sendOperationModeNoWait(eoj, mode); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sendOperationModeNoWait は、指定された蓄電池に運転モードを設定する SetC を送信し、応答を待たずに戻ります。
+func (c *EchonetClient) sendOperationModeNoWait(eoj echonetlite.EOJ, mode byte) error {
+	setFrame := c.NewRequest(eoj, echonetlite.ESVSetC, echonetlite.Property{EPC: 0xDA, EDT: []byte{mode}}) // 運転モード設定
+	if c.DryRun {
+		c.logDryRun(setFrame)
+		c.audit.request(c.Clock.Now(), c.TargetIP, setFrame, "dry_run", nil)
+		return nil
+	}
+	if err := c.Send(setFrame); err != nil {
+		c.audit.request(c.Clock.Now(), c.TargetIP, setFrame, "error", err)
+		return err
+	}
+	c.audit.request(c.Clock.Now(), c.TargetIP, setFrame, "sent", nil)
+	log.Printf("[制御] 蓄電池 (%02X%02X%02X) の運転モードを 0x%X に設定する要求を送信しました (TID: %d, 応答は待機しません)", eoj.ClassGroupCode, eoj.ClassCode, eoj.InstanceCode, mode, setFrame.TID)
+	return nil
+}
+
+// GetProperties は、指定された機器のプロパティを1回の Get 要求で取得します。
+// 機器が一部のプロパティに応答しない場合 (Get_SNA) も、応答したプロパティを返します。応答しなかったプロパティの EDT は空です。
+func (c *EchonetClient) GetProperties(eoj echonetlite.EOJ, epcs []byte) ([]echonetlite.Property, error) {
+	response, err := c.Get(eoj, epcs...)
+	if err != nil {
+		return nil, err
+	}
+	return response.Properties, nil
+}
+
+// SetProperty は、指定された機器のプロパティに任意の EDT を設定します。
+func (c *EchonetClient) SetProperty(eoj echonetlite.EOJ, epc byte, edt []byte) error {
+	setFrame := c.NewRequest(eoj, echonetlite.ESVSetC, echonetlite.Property{EPC: epc, EDT: edt})
+	log.Printf("[制御] %02X%02X%02X の%sを %X に設定します (TID: %d)", eoj.ClassGroupCode, eoj.ClassCode, eoj.InstanceCode, echonetlite.PropertyName(eoj, epc), edt, setFrame.TID)
+	return c.sendSetC(setFrame)
+}
@@ -0,0 +1,84 @@
+package monitor
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestSendAndReceiveRetriesOnTimeout(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	dropped := 0
+	handler := func(data []byte, addr *net.UDPAddr) []echonetlite.Datagram {
+		if dropped < 2 {
+			dropped++
+			return nil
+		}
+		return device.Handle(data, addr)
+	}
+	transport := echonetlite.NewFakeTransport(handler)
+	c := NewEchonetClient(transport, "192.168.0.10", time.Second)
+	c.Retries, c.RetryBackoff = 2, time.Millisecond
+	get := echonetlite.Frame{
+		EHD1:       echonetlite.EchonetLiteEHD1,
+		EHD2:       echonetlite.Format1,
+		TID:        c.NextTID(),
+		SEOJ:       c.SEOJ,
+		DEOJ:       echonetlite.NewEOJ(0x02, 0x7D, 0x01),
+		ESV:        echonetlite.ESVGet,
+		OPC:        1,
+		Properties: []echonetlite.Property{{EPC: 0xE4}},
+	}
+	if _, _, err := c.SendAndReceive(get); err != nil {
+		t.Fatalf("sendAndReceive with 2 retries: %v", err)
+	}
+	if n := len(transport.Sent()); n != 3 {
+		t.Errorf("sent %d requests, want 3", n)
+	}
+
+	// The last timeout is returned once the retries are used up.
+	dropped = 0
+	c.Retries = 1
+	get.TID = c.NextTID()
+	if _, _, err := c.SendAndReceive(get); err == nil {
+		t.Errorf("sendAndReceive with 1 retry succeeded, want timeout")
+	}
+}
+
+func TestNewClientAcceptsReusedTID(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	get := echonetlite.Frame{
+		EHD1:       echonetlite.EchonetLiteEHD1,
+		EHD2:       echonetlite.Format1,
+		TID:        5,
+		SEOJ:       config.DefaultControllerEOJ,
+		DEOJ:       echonetlite.NewEOJ(0x02, 0x7D, 0x01),
+		ESV:        echonetlite.ESVGet,
+		OPC:        1,
+		Properties: []echonetlite.Property{{EPC: 0xE4}},
+	}
+	// A restarted process (or another command) gets a new client that may start from a TID used by the previous one.
+	for i := 0; i < 2; i++ {
+		c := NewEchonetClient(echonetlite.NewFakeTransport(device.Handle), "192.168.0.10", time.Second)
+		if _, _, err := c.SendAndReceive(get); err != nil {
+			t.Fatalf("client %d: sendAndReceive: %v", i+1, err)
+		}
+	}
+}
+
+func TestPeerKeepsDryRun(t *testing.T) {
+	c := NewEchonetClient(echonetlite.NewFakeTransport(nil), "192.168.0.10", time.Second)
+	c.DryRun = true
+	p := c.Peer("192.168.0.11")
+	if !p.DryRun || p.TargetIP != "192.168.0.11" {
+		t.Errorf("peer DryRun = %t, TargetIP = %q, want true and 192.168.0.11", p.DryRun, p.TargetIP)
+	}
+	// A dry-run peer records SetC without sending it.
+	if err := p.SetProperty(echonetlite.NewEOJ(0x02, 0x7D, 0x01), 0xDA, []byte{0x42}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,49 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// detectConflict は、最後に設定した運転モードと、取得値または通知 (INF) で受け取った運転モードが異なる蓄電池を検出します。
+// 他の HEMS コントローラーが運転モードを変更したとみなし、競合をログに出力して、conflict_backoff_minutes の間は自動制御を控えます。
+// 競合の内容と自動制御を控える期限は、手動操作の status コマンドでも表示します。
+func (m *Monitor) detectConflict(now time.Time) {
+	for _, u := range m.batteries {
+		if u.lastSetMode == 0 {
+			continue
+		}
+		observed := u.mode
+		if v, ok := m.client.notified.get(u.eoj, 0xDA, now.Sub(u.lastSetAt), now); ok && v.at.After(u.lastSetAt) {
+			if mode, ok := v.value.(uint8); ok && mode != u.lastSetMode {
+				observed = mode
+			}
+		}
+		if observed == 0 || observed == u.lastSetMode {
+			continue
+		}
+
+		m.conflictUntil = now.Add(time.Duration(m.cfg.ConflictBackoffMinutes) * time.Minute)
+		log.Printf("[競合] 他のコントローラーが %s の運転モードを 0x%X から 0x%X に変更しました (設定時刻: %s)。%s まで自動制御を控えます。",
+			u.name, u.lastSetMode, observed, u.lastSetAt.Format("15:04:05"), m.conflictUntil.Format("15:04:05"))
+		m.Override.setConflict(fmt.Sprintf("conflict: %s mode 0x%X -> 0x%X at %s, automatic control paused until %s",
+			u.name, u.lastSetMode, observed, now.Format("15:04"), m.conflictUntil.Format("15:04")))
+		// 他のコントローラーが設定した運転モードを基準にし、同じ変更を繰り返し検出しないようにする
+		u.lastSetMode = observed
+	}
+}
+
+// backingOff は、競合を検出してから conflict_backoff_minutes の間は true を返します。期限を過ぎた場合は自動制御を再開します。
+func (m *Monitor) backingOff(now time.Time) bool {
+	if m.conflictUntil.IsZero() {
+		return false
+	}
+	if now.Before(m.conflictUntil) {
+		return true
+	}
+	log.Println("[競合] 自動制御を控える期間が終了しました。自動制御を再開します。")
+	m.conflictUntil = time.Time{}
+	m.Override.setConflict("")
+	return false
+}
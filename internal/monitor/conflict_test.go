@@ -0,0 +1,84 @@
+package monitor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestMonitorBacksOffWhenAnotherControllerChangesMode(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	battery := echonetlite.NewEOJ(0x02, 0x7D, 0x01)
+	now := monitortest.Now
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour), func(c *config.Config) {
+		c.ConflictBackoffMinutes = 30
+	})
+
+	m.runCycle() // switches to auto mode
+	if len(device.Sets) != 1 {
+		t.Fatalf("expected 1 SetC, got %d", len(device.Sets))
+	}
+
+	// Another controller puts the battery back into charge mode.
+	device.Props[battery][0xDA] = []byte{0x42}
+	m.runCycle()
+	if len(device.Sets) != 1 {
+		t.Fatalf("expected no SetC while backing off, got %d in total", len(device.Sets))
+	}
+	if m.conflictUntil.IsZero() {
+		t.Fatal("conflictUntil not set after a conflict")
+	}
+	if res, _ := m.Override.HandleCommand("status", now); !strings.Contains(res, "conflict") {
+		t.Errorf("status = %q, want the conflict", res)
+	}
+
+	// Once the back-off period ends, automatic control resumes.
+	m.conflictUntil = now.Add(-time.Second)
+	m.runCycle()
+	if len(device.Sets) != 2 {
+		t.Fatalf("expected automatic control to resume, got %d SetC in total", len(device.Sets))
+	}
+	if res, _ := m.Override.HandleCommand("status", now); strings.Contains(res, "conflict") {
+		t.Errorf("status = %q after the back-off ended", res)
+	}
+}
+
+func TestDetectConflictFromNotification(t *testing.T) {
+	now := monitortest.Now
+	m := newTestMonitor(monitortest.NewFakeEIBS7(), now, now, func(c *config.Config) {
+		c.ConflictBackoffMinutes = 30
+	})
+	u := m.batteries[0]
+	u.mode = 0x46
+	u.lastSetMode, u.lastSetAt = 0x46, now.Add(-time.Minute)
+
+	m.detectConflict(now)
+	if !m.conflictUntil.IsZero() {
+		t.Fatal("conflict detected without a mode change")
+	}
+
+	m.client.notified.store(u.eoj, 0xDA, "運転モード設定", uint8(0x44), now.Add(-10*time.Second))
+	m.detectConflict(now)
+	if !m.conflictUntil.Equal(now.Add(30 * time.Minute)) {
+		t.Errorf("conflictUntil = %v, want 30 minutes after the notification was seen", m.conflictUntil)
+	}
+	if u.lastSetMode != 0x44 {
+		t.Errorf("lastSetMode = 0x%X, want the other controller's 0x44", u.lastSetMode)
+	}
+}
+
+func TestDryRunDoesNotRecordModeForConflicts(t *testing.T) {
+	now := monitortest.Now
+	m := newTestMonitor(monitortest.NewFakeEIBS7(), now.Add(2*time.Hour), now.Add(3*time.Hour))
+	m.client.DryRun = true
+
+	m.runCycle()
+
+	if m.batteries[0].lastSetMode != 0 {
+		t.Errorf("lastSetMode = 0x%X in dry run, want 0", m.batteries[0].lastSetMode)
+	}
+}
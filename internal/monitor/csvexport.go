@@ -0,0 +1,133 @@
+package monitor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// 監視データの CSV に追加する計算値の列
+const (
+	csvColumnSelfConsumption = "計算値.自家消費電力"
+	csvColumnSurplus         = "計算値.余剰電力"
+)
+
+// monitoringCSV は、監視サイクルごとの監視データを日ごとの CSV ファイルに1行ずつ追記します。
+// ファイル名は monitoring-YYYY-MM-DD.csv で、日付が変わると新しいファイルに切り替えます。
+// 列は時刻と「オブジェクト名.プロパティ名」で、その日の最初の行 (既存のファイルの場合は見出し行) で決まります。
+// 後から取得できるようになったプロパティは、翌日のファイルから列に加わります。
+type monitoringCSV struct {
+	dir     string
+	date    string // 現在のファイルの日付 (YYYY-MM-DD)
+	file    *os.File
+	writer  *csv.Writer
+	columns []string // 時刻を除く列
+	rolled  func()   // 日付が変わって前日のファイルを閉じたときに呼び出す ([archive_upload] のアップロード用)。nil の場合は呼び出さない
+}
+
+// newMonitoringCSV は、出力先のディレクトリを指定して monitoringCSV を作成します。ファイルは最初の行を書き込むときに開きます。
+func newMonitoringCSV(dir string) *monitoringCSV {
+	return &monitoringCSV{dir: dir}
+}
+
+// append は、now の監視データと計算値を1行追記します。now の日付が前の行と異なる場合は、新しいファイルに切り替えます。
+func (c *monitoringCSV) append(now time.Time, data map[string]interface{}, computed map[string]interface{}) error {
+	row := make(map[string]interface{}, len(data)+len(computed))
+	for k, v := range data {
+		row[k] = v
+	}
+	for k, v := range computed {
+		row[k] = v
+	}
+
+	if date := now.Format("2006-01-02"); date != c.date || c.file == nil {
+		rolled := c.file != nil
+		c.close()
+		if rolled && c.rolled != nil {
+			c.rolled()
+		}
+		if err := c.open(date, row); err != nil {
+			return err
+		}
+	}
+	record := make([]string, 0, len(c.columns)+1)
+	record = append(record, now.Format(time.RFC3339))
+	for _, column := range c.columns {
+		record = append(record, FormatCSVValue(row[column]))
+	}
+	if err := c.writer.Write(record); err != nil {
+		return fmt.Errorf("監視データの CSV の書き込みに失敗しました: %w", err)
+	}
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// open は、date の CSV ファイルを追記で開きます。新しいファイルの場合は row の項目から列を決めて見出し行を書き込み、
+// 既存のファイルの場合は見出し行の列を引き継ぎます。
+func (c *monitoringCSV) open(date string, row map[string]interface{}) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("監視データの CSV の出力先 '%s' を作成できませんでした: %w", c.dir, err)
+	}
+	path := filepath.Join(c.dir, "monitoring-"+date+".csv")
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("監視データの CSV '%s' を開けませんでした: %w", path, err)
+	}
+	header, err := csv.NewReader(f).Read()
+	switch {
+	case err == io.EOF:
+		c.columns = make([]string, 0, len(row))
+		for k := range row {
+			c.columns = append(c.columns, k)
+		}
+		sort.Strings(c.columns)
+		c.writer = csv.NewWriter(f)
+		c.writer.Write(append([]string{"時刻"}, c.columns...))
+	case err != nil:
+		f.Close()
+		return fmt.Errorf("監視データの CSV '%s' の見出し行を読み込めませんでした: %w", path, err)
+	default:
+		c.columns = header[1:]
+		c.writer = csv.NewWriter(f)
+	}
+	c.file, c.date = f, date
+	log.Printf("[CSV] 監視データを '%s' に記録します (%d 列)。", path, len(c.columns)+1)
+	return nil
+}
+
+// close は、現在の CSV ファイルを閉じます。
+func (c *monitoringCSV) close() {
+	if c.file == nil {
+		return
+	}
+	c.writer.Flush()
+	c.file.Close()
+	c.file, c.writer = nil, nil
+}
+
+// FormatCSVValue は、監視データの値を CSV の1項目にします。バイト列は16進数にし、値がない場合は空にします。
+func FormatCSVValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return fmt.Sprintf("%X", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// writeMonitoringCSV は、log_monitoring_data と monitoring_csv_dir が設定されている場合に、監視データと計算値を CSV に追記します。
+func (m *Monitor) writeMonitoringCSV(now time.Time, monitoringData map[string]interface{}, computed map[string]interface{}) {
+	if m.monitoringCSV == nil {
+		return
+	}
+	if err := m.monitoringCSV.append(now, monitoringData, computed); err != nil {
+		log.Printf("[CSV] %v", err)
+	}
+}
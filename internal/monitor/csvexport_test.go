@@ -0,0 +1,73 @@
+package monitor
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func readCSV(t *testing.T, path string) [][]string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestMonitoringCSVDailyRollover(t *testing.T) {
+	dir := t.TempDir()
+	c := newMonitoringCSV(dir)
+	rolled := 0
+	c.rolled = func() { rolled++ }
+	day := time.Date(2025, 6, 6, 23, 59, 50, 0, time.UTC)
+	data := map[string]interface{}{"蓄電池.蓄電残量3": uint8(50), "系統.瞬時電力計測値": int32(-300), "蓄電池.状態": []byte{0x01, 0x02}}
+	computed := map[string]interface{}{csvColumnSurplus: int32(1200)}
+
+	if err := c.append(day, data, computed); err != nil {
+		t.Fatal(err)
+	}
+	// A property missing in a later cycle leaves the column empty.
+	delete(data, "系統.瞬時電力計測値")
+	if err := c.append(day.Add(5*time.Second), data, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.append(day.Add(20*time.Second), data, computed); err != nil {
+		t.Fatal(err)
+	}
+	c.close()
+	if rolled != 1 {
+		t.Errorf("rolled %d times, want once at midnight", rolled)
+	}
+
+	want := [][]string{
+		{"時刻", "系統.瞬時電力計測値", "蓄電池.状態", "蓄電池.蓄電残量3", "計算値.余剰電力"},
+		{"2025-06-06T23:59:50Z", "-300", "0102", "50", "1200"},
+		{"2025-06-06T23:59:55Z", "", "0102", "50", ""},
+	}
+	if got := readCSV(t, filepath.Join(dir, "monitoring-2025-06-06.csv")); !reflect.DeepEqual(got, want) {
+		t.Errorf("first day = %v, want %v", got, want)
+	}
+	if got := readCSV(t, filepath.Join(dir, "monitoring-2025-06-07.csv")); len(got) != 2 || got[0][0] != "時刻" || len(got[0]) != 4 {
+		t.Errorf("second day = %v", got)
+	}
+
+	// Reopening an existing file keeps its columns instead of writing another header.
+	c = newMonitoringCSV(dir)
+	if err := c.append(day.Add(30*time.Second), map[string]interface{}{"蓄電池.蓄電残量3": uint8(51)}, nil); err != nil {
+		t.Fatal(err)
+	}
+	c.close()
+	got := readCSV(t, filepath.Join(dir, "monitoring-2025-06-07.csv"))
+	if len(got) != 3 || got[2][len(got[2])-1] != "" || got[2][2] != "51" {
+		t.Errorf("after reopening = %v", got)
+	}
+}
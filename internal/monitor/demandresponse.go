@@ -0,0 +1,110 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// airConditionerTarget は、エアコンの監視対象を返します。
+func airConditionerTarget(instance int) MonitoringTarget {
+	return MonitoringTarget{
+		EOJ:        echonetlite.NewEOJ(0x01, 0x30, byte(instance)),
+		EPCs:       []byte{0x80, 0xB0, 0xB3, 0x8F}, // 動作状態, 運転モード設定, 温度設定値, 節電動作設定
+		ObjectName: fmt.Sprintf("家庭用エアコン (0130%02X)", instance),
+	}
+}
+
+// respondToDemand は、買電電力が import_guard の上限を超えていて、充電電力を下げる余地がない場合 (充電モードで充電電力が 0 W より大きい場合以外) に、
+// 運転中のエアコンの設定を緩和します。買電電力が上限から restore_margin_watts 以上下がった場合は、緩和したエアコンを元の設定に戻します。
+func (m *Monitor) respondToDemand(monitoringData map[string]interface{}, gridPower int32, currentOperationMode byte) {
+	cfg := m.cfg.DemandResponse
+	if !cfg.Enabled {
+		return
+	}
+	limit := m.cfg.ImportGuard.LimitWatts
+	if int(gridPower) <= limit-cfg.RestoreMarginWatts {
+		m.restoreAirConditioners()
+		return
+	}
+	if int(gridPower) <= limit {
+		return
+	}
+	if setting, ok := monitoringData["蓄電池.充電電力設定値"].(uint32); ok && currentOperationMode == 0x42 && setting > 0 {
+		return // 充電電力を下げる余地がある間は、買電制限で充電電力を下げる
+	}
+
+	for _, instance := range cfg.AirConditioners {
+		if _, ok := m.trimmed[instance]; ok {
+			continue
+		}
+		target := airConditionerTarget(instance)
+		status, sOK := monitoringData[target.ObjectName+".動作状態"].(uint8)
+		if !sOK || status != 0x30 { // 0x30: ON
+			continue
+		}
+		log.Printf("[買電制限] 充電電力を下げても買電電力 (%d W) が上限 (%d W) を超えているため、%s の設定を緩和します。", gridPower, limit, target.ObjectName)
+		if setpoint, ok := m.trimAirConditioner(target, monitoringData); ok {
+			m.trimmed[instance] = setpoint
+		}
+	}
+}
+
+// trimAirConditioner は、エアコンを節電動作にするか、設定温度を冷房時は上げ、暖房時は下げます。
+// 設定を変更した場合は、元の温度設定値 (℃, action = "eco" の場合は 0) と true を返します。
+func (m *Monitor) trimAirConditioner(target MonitoringTarget, monitoringData map[string]interface{}) (uint8, bool) {
+	cfg := m.cfg.DemandResponse
+	if cfg.Action == config.DemandResponseEco {
+		if err := m.client.setByteProperty(target.EOJ, "エアコン", 0x8F, "節電動作設定", 0x41); err != nil { // 0x41: 節電動作中
+			log.Printf("[買電制限] %s を節電動作にできませんでした: %v", target.ObjectName, err)
+			return 0, false
+		}
+		return 0, true
+	}
+
+	mode, mOK := monitoringData[target.ObjectName+".運転モード設定"].(uint8)
+	setpoint, tOK := monitoringData[target.ObjectName+".温度設定値"].(uint8)
+	if !mOK || !tOK {
+		log.Printf("[買電制限] %s の運転モードまたは温度設定値が取得できなかったため、設定温度を変更できません。", target.ObjectName)
+		return 0, false
+	}
+	var trimmed int
+	switch mode {
+	case 0x42: // 冷房
+		trimmed = int(setpoint) + cfg.SetpointOffsetCelsius
+	case 0x43: // 暖房
+		trimmed = int(setpoint) - cfg.SetpointOffsetCelsius
+	default:
+		log.Printf("[買電制限] %s は冷房・暖房以外の運転モード (0x%X) のため、設定温度を変更しません。", target.ObjectName, mode)
+		return 0, false
+	}
+	if trimmed < 0 || trimmed > 50 {
+		return 0, false
+	}
+	if err := m.client.setByteProperty(target.EOJ, "エアコン", 0xB3, "温度設定値", byte(trimmed)); err != nil {
+		log.Printf("[買電制限] %s の設定温度を変更できませんでした: %v", target.ObjectName, err)
+		return 0, false
+	}
+	return setpoint, true
+}
+
+// restoreAirConditioners は、設定を緩和したエアコンを元の設定に戻します。
+func (m *Monitor) restoreAirConditioners() {
+	for instance, setpoint := range m.trimmed {
+		target := airConditionerTarget(instance)
+		log.Printf("[買電制限] 買電電力が上限を十分に下回ったため、%s の設定を元に戻します。", target.ObjectName)
+		var err error
+		if m.cfg.DemandResponse.Action == config.DemandResponseEco {
+			err = m.client.setByteProperty(target.EOJ, "エアコン", 0x8F, "節電動作設定", 0x42) // 0x42: 通常動作中
+		} else {
+			err = m.client.setByteProperty(target.EOJ, "エアコン", 0xB3, "温度設定値", setpoint)
+		}
+		if err != nil {
+			log.Printf("[買電制限] %s の設定を元に戻せませんでした: %v", target.ObjectName, err)
+			continue
+		}
+		delete(m.trimmed, instance)
+	}
+}
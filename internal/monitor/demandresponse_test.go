@@ -0,0 +1,82 @@
+package monitor
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+// newDemandResponseTestMonitor returns a monitor importing gridWatts over a 3000 W limit,
+// with the battery in the given mode and one cooling air conditioner set to 26 ℃.
+func newDemandResponseTestMonitor(gridWatts int32, batteryMode byte, action string) (*monitortest.FakeEIBS7, echonetlite.EOJ, *Monitor) {
+	device := monitortest.NewFakeEIBS7()
+	device.Props[echonetlite.NewEOJ(0x02, 0x7D, 0x01)][0xDA] = []byte{batteryMode}
+	device.Props[echonetlite.NewEOJ(0x02, 0x87, 0x01)][0xC6] = binary.BigEndian.AppendUint32(nil, uint32(gridWatts))
+	ac := echonetlite.NewEOJ(0x01, 0x30, 0x01)
+	device.Props[ac] = map[byte][]byte{0x80: {0x30}, 0xB0: {0x42}, 0xB3: {26}, 0x8F: {0x42}}
+
+	now := monitortest.Now
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour), func(c *config.Config) {
+		c.ImportGuard = config.ImportGuardConfig{Enabled: true, LimitWatts: 3000, StepWatts: 500}
+		c.DemandResponse = config.DemandResponseConfig{Enabled: true, AirConditioners: []int{1}, Action: action, SetpointOffsetCelsius: 2, RestoreMarginWatts: 500}
+	})
+	return device, ac, m
+}
+
+// acSets returns the SetC requests sent to the air conditioner.
+func acSets(device *monitortest.FakeEIBS7, ac echonetlite.EOJ) []echonetlite.Property {
+	var props []echonetlite.Property
+	for _, s := range device.Sets {
+		if s.DEOJ == ac {
+			props = append(props, s.Properties[0])
+		}
+	}
+	return props
+}
+
+func TestMonitorSwitchesAirConditionerToEcoOverImportLimit(t *testing.T) {
+	device, ac, m := newDemandResponseTestMonitor(4000, 0x46, config.DemandResponseEco)
+
+	m.runCycle()
+
+	if got := acSets(device, ac); len(got) != 1 || got[0].EPC != 0x8F || got[0].EDT[0] != 0x41 {
+		t.Fatalf("air conditioner SetC = %+v, want power saving (8F=41)", got)
+	}
+
+	// Once the import falls well below the limit, the setting is restored.
+	device.Props[echonetlite.NewEOJ(0x02, 0x87, 0x01)][0xC6] = binary.BigEndian.AppendUint32(nil, 2000)
+	m.runCycle()
+	if got := acSets(device, ac); len(got) != 2 || got[1].EPC != 0x8F || got[1].EDT[0] != 0x42 {
+		t.Fatalf("air conditioner SetC = %+v, want normal operation (8F=42) restored", got)
+	}
+	if len(m.trimmed) != 0 {
+		t.Errorf("trimmed = %v after restoring", m.trimmed)
+	}
+}
+
+func TestMonitorRaisesCoolingSetpointOverImportLimit(t *testing.T) {
+	device, ac, m := newDemandResponseTestMonitor(4000, 0x46, config.DemandResponseSetpoint)
+
+	m.runCycle()
+
+	if got := acSets(device, ac); len(got) != 1 || got[0].EPC != 0xB3 || got[0].EDT[0] != 28 {
+		t.Fatalf("air conditioner SetC = %+v, want setpoint 28 ℃", got)
+	}
+	if m.trimmed[1] != 26 {
+		t.Errorf("saved setpoint = %d, want 26", m.trimmed[1])
+	}
+}
+
+func TestMonitorReducesChargePowerBeforeAirConditioner(t *testing.T) {
+	device, ac, m := newDemandResponseTestMonitor(4000, 0x42, config.DemandResponseEco)
+
+	m.runCycle()
+
+	if got := acSets(device, ac); len(got) != 0 {
+		t.Errorf("air conditioner SetC = %+v, want none while charge power can still be reduced", got)
+	}
+}
@@ -0,0 +1,139 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// newConfiguredDevices は、devices の宣言から蓄電池と監視対象、target_ip 以外の機器のクライアントを作成します。
+// target_ip 以外の機器のクライアントは IP アドレスごとに作成し、target_ip の機器と送受信用のソケットを共有します。
+// target_ip 以外の機器の蓄電池と監視対象の名前には、targets と同様に IP アドレスを付けます。
+func newConfiguredDevices(devices []config.DeviceConfig, primary *EchonetClient) ([]*batteryUnit, []MonitoringTarget, []*EchonetClient) {
+	clients := make(map[string]*EchonetClient)
+	var remote []*EchonetClient
+	clientFor := func(ip string) (*EchonetClient, string) {
+		if ip == primary.TargetIP {
+			return nil, ""
+		}
+		c, ok := clients[ip]
+		if !ok {
+			c = primary.Peer(ip)
+			c.batteries = nil
+			clients[ip] = c
+			remote = append(remote, c)
+		}
+		return c, fmt.Sprintf(" [%s]", ip)
+	}
+
+	primary.batteries = nil
+	var units []*batteryUnit
+	var others []MonitoringTarget
+	for _, d := range devices {
+		client, suffix := clientFor(d.IP)
+		for _, eoj := range d.ParsedEOJs {
+			if d.Role == config.RoleBattery {
+				u := &batteryUnit{eoj: eoj, name: fmt.Sprintf("蓄電池 (%02X%02X%02X)%s", eoj.ClassGroupCode, eoj.ClassCode, eoj.InstanceCode, suffix), client: client}
+				if client == nil {
+					primary.batteries = append(primary.batteries, eoj)
+				} else {
+					client.batteries = append(client.batteries, eoj)
+				}
+				units = append(units, u)
+				continue
+			}
+			for _, target := range defaultMonitoringTargets(nil, []echonetlite.EOJ{eoj}) {
+				target.ObjectName += suffix
+				target.client = client
+				others = append(others, target)
+			}
+		}
+	}
+	return units, append(defaultMonitoringTargets(units, nil), others...), remote
+}
+
+// newDevice は、targets の機器を制御するクライアントと蓄電池、監視対象を作成します。
+// クライアントは target_ip の機器と送受信用のソケットを共有します。
+// 分電盤メータリングの瞬時電力 (住宅全体の買電・売電電力) は target_ip の機器からのみ取得するため、監視対象に含めません。
+func newDevice(t config.DeviceTarget, primary *EchonetClient) (*EchonetClient, []*batteryUnit, []MonitoringTarget) {
+	client := primary.Peer(t.IP)
+	client.batteries = nil
+
+	units := newBatteryUnits(t.BatteryInstances)
+	for _, u := range units {
+		u.client = client
+		u.name = fmt.Sprintf("%s [%s]", u.name, t.IP)
+		client.batteries = append(client.batteries, u.eoj)
+	}
+
+	var targets []MonitoringTarget
+	for _, target := range defaultMonitoringTargets(units, defaultInstances) {
+		switch target.role {
+		case config.RoleMeter:
+			continue
+		case config.RolePV, config.RolePCS:
+			target.ObjectName = fmt.Sprintf("%s [%s]", target.ObjectName, t.IP)
+		}
+		target.client = client
+		targets = append(targets, target)
+	}
+	return client, units, targets
+}
+
+// OnCSVRollover は、監視データの CSV の日付が変わって前日のファイルを閉じたときに呼び出す関数を設定します。
+// 監視データの CSV を出力しない場合は何もしません。
+func (m *Monitor) OnCSVRollover(f func()) {
+	if m.monitoringCSV != nil {
+		m.monitoringCSV.rolled = f
+	}
+}
+
+// Clients は、制御するすべての機器のクライアントを返します。最初の要素は target_ip の機器です。
+func (m *Monitor) Clients() []*EchonetClient {
+	return append([]*EchonetClient{m.client}, m.devices...)
+}
+
+// clientFor は、監視対象や蓄電池に設定したクライアントを返します。nil の場合は target_ip の機器のクライアントを返します。
+func (m *Monitor) clientFor(c *EchonetClient) *EchonetClient {
+	if c != nil {
+		return c
+	}
+	return m.client
+}
+
+// aggregateGeneration は、すべての機器の太陽光発電とマルチ入力PCS の瞬時電力を合計し、
+// "住宅用太陽光発電.瞬時発電電力計測値" と "マルチ入力PCS.瞬時電力計測値" のキーで monitoringData に格納します。
+// いずれかの機器で取得できなかった値は格納しません。
+func (m *Monitor) aggregateGeneration(monitoringData map[string]interface{}) {
+	var (
+		pv, pcs     int32
+		pvOK, pcsOK = true, true
+	)
+	for _, target := range m.targets {
+		switch target.role {
+		case config.RolePV:
+			if v, ok := monitoringData[target.ObjectName+".瞬時発電電力計測値"].(uint16); ok {
+				pv += int32(v)
+			} else {
+				pvOK = false
+			}
+		case config.RolePCS:
+			if v, ok := monitoringData[target.ObjectName+".瞬時電力計測値"].(int32); ok {
+				pcs += v
+			} else {
+				pcsOK = false
+			}
+		}
+	}
+	if pvOK {
+		monitoringData["住宅用太陽光発電.瞬時発電電力計測値"] = pv
+	}
+	if pcsOK {
+		monitoringData["マルチ入力PCS.瞬時電力計測値"] = pcs
+	}
+	if len(m.devices) > 0 && pvOK && pcsOK {
+		log.Printf("[計算値] 全機器の合計: 太陽光発電 %d W, マルチ入力PCS %d W (機器数: %d)", pv, pcs, len(m.devices)+1)
+	}
+}
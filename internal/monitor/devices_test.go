@@ -0,0 +1,82 @@
+package monitor
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestMonitorSplitsChargePowerAcrossDevices(t *testing.T) {
+	primary, second := monitortest.NewFakeEIBS7(), monitortest.NewFakeEIBS7()
+	second.Props[echonetlite.NewEOJ(0x02, 0x79, 0x01)][0xE0] = []byte{0x03, 0xE8} // PV 1000 W
+	transport := echonetlite.NewFakeTransport(func(data []byte, addr *net.UDPAddr) []echonetlite.Datagram {
+		if addr.IP.String() == "192.168.0.11" {
+			return second.Handle(data, addr)
+		}
+		return primary.Handle(data, addr)
+	})
+
+	now := monitortest.Now
+	m := newTestMonitor(primary, now.Add(-time.Hour), now.Add(time.Hour), func(c *config.Config) {
+		c.MaxChargePowerWatts = 5000
+		c.Targets = []config.DeviceTarget{{IP: "192.168.0.11", BatteryInstances: []int{1}}}
+	})
+	m = New(m.cfg, NewEchonetClient(transport, m.cfg.TargetIP, time.Second), Options{})
+	m.runCycle()
+
+	// Combined surplus is 3000 + 1000 W, so the total is capped at 4000-500 = 3500 W
+	// and split evenly between the two batteries, which have the same room to charge.
+	for name, d := range map[string]*monitortest.FakeEIBS7{"primary": primary, "second": second} {
+		if len(d.Sets) != 1 {
+			t.Fatalf("%s: expected 1 SetC, got %d", name, len(d.Sets))
+		}
+		p := d.Sets[0].Properties[0]
+		if p.EPC != 0xEB || len(p.EDT) != 4 || binary.BigEndian.Uint32(p.EDT) != 1750 {
+			t.Errorf("%s: SetC = EPC 0x%X EDT %X, want charge power 1750 W", name, p.EPC, p.EDT)
+		}
+	}
+}
+
+func TestNewConfiguredDevices(t *testing.T) {
+	devices := []config.DeviceConfig{
+		{Role: config.RoleMeter},
+		{Role: config.RoleBattery},
+		{IP: "192.168.0.11", Role: config.RolePV},
+		{IP: "192.168.0.11", Role: config.RoleBattery},
+	}
+	if err := config.ValidateDevices(devices, "192.168.0.10"); err != nil {
+		t.Fatalf("validateDevices failed: %v", err)
+	}
+	primary := NewEchonetClient(echonetlite.NewFakeTransport(monitortest.NewFakeEIBS7().Handle), "192.168.0.10", time.Second)
+
+	batteries, targets, clients := newConfiguredDevices(devices, primary)
+
+	if len(batteries) != 2 || batteries[0].client != nil || batteries[1].name != "蓄電池 (027D01) [192.168.0.11]" {
+		t.Fatalf("batteries = %+v, want the primary battery then the remote one", batteries)
+	}
+	if len(clients) != 1 || batteries[1].client != clients[0] || len(clients[0].batteries) != 1 || len(primary.batteries) != 1 {
+		t.Errorf("clients = %+v, want one client for 192.168.0.11", clients)
+	}
+	var roles []string
+	for _, target := range targets {
+		roles = append(roles, target.role)
+	}
+	want := []string{config.RoleBattery, config.RoleBattery, config.RoleMeter, config.RolePV}
+	if len(roles) != len(want) {
+		t.Fatalf("roles = %v, want %v", roles, want)
+	}
+	for i := range want {
+		if roles[i] != want[i] {
+			t.Errorf("roles = %v, want %v", roles, want)
+			break
+		}
+	}
+	if targets[3].ObjectName != "住宅用太陽光発電 (027901) [192.168.0.11]" || targets[3].client != clients[0] {
+		t.Errorf("pv target = %+v, want the remote client", targets[3])
+	}
+}
@@ -0,0 +1,107 @@
+package monitor
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// ECHONET Lite のマルチキャストアドレス
+var echonetLiteMulticastAddr = &net.UDPAddr{IP: echonetlite.MulticastIP, Port: echonetLitePort}
+
+// DiscoverNodes は、ノードプロファイルの識別番号 (EPC 0x83) をマルチキャストで要求し、
+// タイムアウトまでに応答した機器の IP アドレスと識別番号 (16進文字列) の対応を返します。
+func (c *EchonetClient) DiscoverNodes(timeout time.Duration) (map[string]string, error) {
+	tid := c.NextTID()
+	getFrame := echonetlite.Frame{
+		EHD1:       echonetlite.EchonetLiteEHD1,
+		EHD2:       echonetlite.Format1,
+		TID:        tid,
+		SEOJ:       c.SEOJ,
+		DEOJ:       NodeProfileEOJ,
+		ESV:        echonetlite.ESVGet,
+		OPC:        1,
+		Properties: []echonetlite.Property{{EPC: 0x83}}, // 識別番号
+	}
+	sendData, err := getFrame.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("フレームのシリアライズに失敗しました (TID: %d): %w", tid, err)
+	}
+
+	if err := c.Transport.Send(sendData, echonetLiteMulticastAddr); err != nil {
+		return nil, fmt.Errorf("マルチキャスト送信に失敗しました (宛先: %s): %w", echonetLiteMulticastAddr, err)
+	}
+	log.Printf("[探索] 識別番号の取得要求をマルチキャスト送信しました (TID: %d)", tid)
+
+	nodes := make(map[string]string)
+	buffer := make([]byte, c.ReceiveBufferSize)
+	deadline := c.Clock.Now().Add(timeout)
+	for {
+		bytesRead, addr, err := c.Transport.Receive(buffer, deadline)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				return nodes, nil // タイムアウトまでに応答した機器を返す
+			}
+			return nodes, fmt.Errorf("UDPデータの受信に失敗しました (TID: %d): %w", tid, err)
+		}
+
+		var received echonetlite.Frame
+		if err := received.UnmarshalBinary(buffer[:bytesRead]); err != nil || received.TID != tid || received.ESV != echonetlite.ESVGet_Res {
+			continue
+		}
+		for _, prop := range received.Properties {
+			if prop.EPC == 0x83 && len(prop.EDT) > 0 {
+				nodes[addr.IP.String()] = strings.ToUpper(hex.EncodeToString(prop.EDT))
+				c.debugf("[探索] %s から識別番号を受信しました: %X", addr.IP, prop.EDT)
+			}
+		}
+	}
+}
+
+// targetResolver は、識別番号で指定された機器の IP アドレスを探索によって解決します。
+// DHCP によって機器のアドレスが変わった場合でも、設定を変更せずに通信を継続できるようにします。
+type targetResolver struct {
+	nodeID   string        // 正規化済みの識別番号
+	interval time.Duration // 探索を再試行する最小間隔
+	timeout  time.Duration // 探索の応答待ち時間
+	discover func(timeout time.Duration) (map[string]string, error)
+
+	lastAttempt time.Time
+}
+
+// newTargetResolver は、識別番号と探索に使用する関数を指定して targetResolver を作成します。
+func newTargetResolver(nodeID string, interval, timeout time.Duration, discover func(timeout time.Duration) (map[string]string, error)) *targetResolver {
+	return &targetResolver{
+		nodeID:   config.NormalizeNodeID(nodeID),
+		interval: interval,
+		timeout:  timeout,
+		discover: discover,
+	}
+}
+
+// resolve は、識別番号に一致する機器を探索し、見つかった場合はその IP アドレスと true を返します。
+// 前回の探索から interval が経過していない場合は探索を行わずに false を返します。
+func (r *targetResolver) resolve(now time.Time) (string, bool) {
+	if !r.lastAttempt.IsZero() && now.Sub(r.lastAttempt) < r.interval {
+		return "", false
+	}
+	r.lastAttempt = now
+
+	nodes, err := r.discover(r.timeout)
+	if err != nil {
+		log.Printf("[探索] 機器の探索に失敗しました: %v", err)
+	}
+	for ip, id := range nodes {
+		if id == r.nodeID {
+			return ip, true
+		}
+	}
+	log.Printf("[探索] 識別番号 %s の機器が見つかりませんでした (応答した機器: %d 台)", r.nodeID, len(nodes))
+	return "", false
+}
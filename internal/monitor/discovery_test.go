@@ -0,0 +1,40 @@
+package monitor
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTargetResolverFindsNodeByID(t *testing.T) {
+	calls := 0
+	r := newTargetResolver("fe00000801", time.Minute, time.Second, func(timeout time.Duration) (map[string]string, error) {
+		calls++
+		return map[string]string{
+			"192.168.0.20": "FE00000899",
+			"192.168.0.42": "FE00000801",
+		}, nil
+	})
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local)
+
+	ip, ok := r.resolve(base)
+	if !ok || ip != "192.168.0.42" {
+		t.Fatalf("resolve = (%q, %t), want 192.168.0.42", ip, ok)
+	}
+	// Discovery is rate limited to the configured interval.
+	if _, ok := r.resolve(base.Add(10 * time.Second)); ok || calls != 1 {
+		t.Errorf("discovery ran again before the interval (calls: %d)", calls)
+	}
+	if _, ok := r.resolve(base.Add(time.Minute)); !ok || calls != 2 {
+		t.Errorf("discovery did not run after the interval (calls: %d)", calls)
+	}
+}
+
+func TestTargetResolverNotFound(t *testing.T) {
+	r := newTargetResolver("FE00000801", time.Minute, time.Second, func(timeout time.Duration) (map[string]string, error) {
+		return map[string]string{"192.168.0.20": "FE00000899"}, errors.New("partial failure")
+	})
+	if ip, ok := r.resolve(time.Now()); ok {
+		t.Errorf("unexpected resolution to %q", ip)
+	}
+}
@@ -0,0 +1,81 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// diversionLoadTarget は、振り向け先の監視対象を返します。
+func diversionLoadTarget(l config.DiversionLoad) MonitoringTarget {
+	return MonitoringTarget{
+		EOJ:        l.EOJ(),
+		EPCs:       []byte{0xB0, 0xB2}, // 沸き上げ自動設定, 沸き上げ中状態
+		ObjectName: fmt.Sprintf("電気給湯機 (026B%02X)", l.Instance),
+	}
+}
+
+// divertSurplus は、売電電力が閾値を超え、蓄電池が満充電または充電電力の上限で充電している場合に、
+// 振り向けていない最も優先順位の高い電気給湯機の沸き上げ (手動沸き上げ, 0x42) を開始します。
+// 買電になった場合は、最も優先順位の低い振り向け中の電気給湯機を自動 (0x41) に戻します。
+// 振り向け中の電気給湯機の沸き上げが終了した場合も自動に戻します。いずれも1回の監視サイクルで1台ずつ行います。
+func (m *Monitor) divertSurplus(monitoringData map[string]interface{}, gridPower int32, batteryFull, batteryCapped bool) {
+	cfg := m.cfg.SurplusDiversion
+	if !cfg.Enabled {
+		return
+	}
+
+	// 沸き上げが終了した電気給湯機は自動に戻す
+	for i, l := range cfg.Loads {
+		target := diversionLoadTarget(l)
+		if state, ok := monitoringData[target.ObjectName+".沸き上げ中状態"].(uint8); ok && m.diverting[i] && state == 0x42 { // 0x42: 沸き上げ停止中
+			log.Printf("[余剰電力] %s の沸き上げが終了したため、自動に戻します。", target.ObjectName)
+			m.stopDiversion(i)
+		}
+	}
+
+	exportWatts := -int(gridPower)
+	switch {
+	case exportWatts > cfg.ExportThresholdWatts && (batteryFull || batteryCapped):
+		for i, l := range cfg.Loads {
+			if m.diverting[i] {
+				continue
+			}
+			target := diversionLoadTarget(l)
+			log.Printf("[余剰電力] 売電電力 (%d W) が閾値 (%d W) を超えています (蓄電池: %s)。%s の沸き上げを開始します。", exportWatts, cfg.ExportThresholdWatts, describeBatteryLimit(batteryFull), target.ObjectName)
+			if err := m.client.setByteProperty(target.EOJ, "電気給湯機", 0xB0, "沸き上げ自動設定", 0x42); err != nil { // 0x42: 手動沸き上げ
+				log.Printf("[余剰電力] %s の沸き上げの開始に失敗しました: %v", target.ObjectName, err)
+				return
+			}
+			m.diverting[i] = true
+			return
+		}
+	case gridPower > 0:
+		for i := len(cfg.Loads) - 1; i >= 0; i-- {
+			if m.diverting[i] {
+				log.Printf("[余剰電力] 買電 (%d W) になったため、%s を自動に戻します。", gridPower, diversionLoadTarget(cfg.Loads[i]).ObjectName)
+				m.stopDiversion(i)
+				return
+			}
+		}
+	}
+}
+
+// stopDiversion は、振り向け中の電気給湯機を自動 (0x41) に戻します。
+func (m *Monitor) stopDiversion(i int) {
+	target := diversionLoadTarget(m.cfg.SurplusDiversion.Loads[i])
+	if err := m.client.setByteProperty(target.EOJ, "電気給湯機", 0xB0, "沸き上げ自動設定", 0x41); err != nil { // 0x41: 自動沸き上げ
+		log.Printf("[余剰電力] %s を自動に戻せませんでした: %v", target.ObjectName, err)
+		return
+	}
+	m.diverting[i] = false
+}
+
+// describeBatteryLimit は、振り向けを開始する蓄電池の状態をログ用の文字列にします。
+func describeBatteryLimit(full bool) string {
+	if full {
+		return "満充電"
+	}
+	return "充電電力の上限"
+}
@@ -0,0 +1,78 @@
+package monitor
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+// newDiversionTestMonitor returns a monitor whose battery is full while the house exports gridWatts,
+// with one water heater in the given boiling state.
+func newDiversionTestMonitor(gridWatts int32, boiling byte) (*monitortest.FakeEIBS7, echonetlite.EOJ, *Monitor) {
+	device := monitortest.NewFakeEIBS7()
+	battery := echonetlite.NewEOJ(0x02, 0x7D, 0x01)
+	device.Props[battery][0xE4] = []byte{100}
+	device.Props[battery][0xDA] = []byte{0x46}
+	device.Props[echonetlite.NewEOJ(0x02, 0x87, 0x01)][0xC6] = binary.BigEndian.AppendUint32(nil, uint32(gridWatts))
+	heater := echonetlite.NewEOJ(0x02, 0x6B, 0x01)
+	device.Props[heater] = map[byte][]byte{0xB0: {0x41}, 0xB2: {boiling}}
+
+	now := monitortest.Now
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour), func(c *config.Config) {
+		c.FullSOCPercent = 100
+		c.SurplusDiversion = config.DiversionConfig{Enabled: true, ExportThresholdWatts: 1000, Loads: []config.DiversionLoad{{Type: config.DiversionWaterHeater, Instance: 1}}}
+	})
+	return device, heater, m
+}
+
+// heaterSets returns the boil-up settings sent to the water heater.
+func heaterSets(device *monitortest.FakeEIBS7, heater echonetlite.EOJ) []byte {
+	var values []byte
+	for _, s := range device.Sets {
+		if s.DEOJ == heater && s.Properties[0].EPC == 0xB0 {
+			values = append(values, s.Properties[0].EDT[0])
+		}
+	}
+	return values
+}
+
+func TestMonitorDivertsSurplusToWaterHeater(t *testing.T) {
+	device, heater, m := newDiversionTestMonitor(-2000, 0x42)
+
+	m.runCycle()
+
+	if got := heaterSets(device, heater); len(got) != 1 || got[0] != 0x42 {
+		t.Fatalf("water heater settings = %X, want a manual boil-up (42)", got)
+	}
+	if !m.diverting[0] {
+		t.Error("diverting = false after starting the boil-up")
+	}
+}
+
+func TestMonitorKeepsExportingBelowThreshold(t *testing.T) {
+	device, heater, m := newDiversionTestMonitor(-500, 0x42)
+
+	m.runCycle()
+
+	if got := heaterSets(device, heater); len(got) != 0 {
+		t.Errorf("water heater settings = %X, want none below the threshold", got)
+	}
+}
+
+func TestMonitorStopsDiversionWhenImporting(t *testing.T) {
+	device, heater, m := newDiversionTestMonitor(300, 0x41)
+	m.diverting[0] = true
+
+	m.runCycle()
+
+	if got := heaterSets(device, heater); len(got) != 1 || got[0] != 0x41 {
+		t.Fatalf("water heater settings = %X, want automatic boil-up (41)", got)
+	}
+	if m.diverting[0] {
+		t.Error("diverting = true after returning to automatic")
+	}
+}
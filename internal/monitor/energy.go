@@ -0,0 +1,92 @@
+package monitor
+
+import (
+	"log"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+)
+
+// 積算電力量のプロパティ (EPC) と、その値を合計する種類
+var energyCounterEPCs = map[byte]map[byte]string{
+	0x7D: {0xD8: "充電", 0xD6: "放電"}, // 蓄電池: 積算充電電力量計測値, 積算放電電力量計測値
+	0x79: {0xE1: "発電"},             // 住宅用太陽光発電: 積算発電電力量計測値
+}
+
+// dailyCounter は、積算値 (kWh) から当日の増分を求めるために、日付が変わった時点の積算値を保持します。
+type dailyCounter struct {
+	day   string  // 積算中の日付 (YYYY-MM-DD)
+	atDay float64 // 日付が変わった時点の積算値 (kWh)
+	today float64 // 当日の増分 (kWh)
+}
+
+// update は、積算値 (kWh) から当日の増分を更新します。
+// 日付が変わった場合や、積算値が減少した場合 (桁あふれや機器の交換) は、その時点の積算値を基準にします。
+func (c *dailyCounter) update(now time.Time, value float64) {
+	day := now.Format("2006-01-02")
+	if day != c.day || value < c.atDay {
+		c.day = day
+		c.atDay = value
+	}
+	c.today = value - c.atDay
+}
+
+// energyCounters は、積算電力量から求めた当日の値を monitoringData のキーごとに保持します。
+type energyCounters map[string]*dailyCounter
+
+// withEnergyCounters は、蓄電池と太陽光発電の監視対象に積算電力量のプロパティを追加します。
+// 積算電力量は変化が緩やかなため、通知駆動の監視モードでは slow_poll_interval_seconds ごとに取得します。
+func withEnergyCounters(targets []MonitoringTarget) []MonitoringTarget {
+	for i, t := range targets {
+		epcs, ok := energyCounterEPCs[t.EOJ.ClassCode]
+		if !ok || t.EOJ.ClassGroupCode != 0x02 {
+			continue
+		}
+		targets[i].EPCs = append([]byte(nil), t.EPCs...)
+		targets[i].SlowEPCs = append([]byte(nil), t.SlowEPCs...)
+		for _, epc := range []byte{0xD8, 0xD6, 0xE1} {
+			if _, ok := epcs[epc]; ok {
+				targets[i].EPCs = append(targets[i].EPCs, epc)
+				targets[i].SlowEPCs = append(targets[i].SlowEPCs, epc)
+			}
+		}
+	}
+	return targets
+}
+
+// updateEnergyCounters は、各蓄電池と太陽光発電の積算電力量から当日の充電・放電・発電電力量を求め、ログに出力します。
+// 機器ごとに当日の増分を求めてから合計するため、一部の機器の値が取得できなかったサイクルでも他の機器の増分は失われません。
+func (m *Monitor) updateEnergyCounters(now time.Time, monitoringData map[string]interface{}) {
+	if !m.cfg.EnergyCounters {
+		return
+	}
+	for _, t := range m.targets {
+		for epc := range energyCounterEPCs[t.EOJ.ClassCode] {
+			key := t.ObjectName + "." + echonetlite.PropertyName(t.EOJ, epc)
+			if kwh, ok := monitoringData[key].(float64); ok {
+				c, ok := m.energy[key]
+				if !ok {
+					c = &dailyCounter{}
+					m.energy[key] = c
+				}
+				c.update(now, kwh)
+			}
+		}
+	}
+	totals := m.energyTotals()
+	log.Printf("[積算] 本日の充電電力量: %.3f kWh, 放電電力量: %.3f kWh, 発電電力量: %.3f kWh",
+		totals["充電"], totals["放電"], totals["発電"])
+}
+
+// energyTotals は、当日の充電・放電・発電電力量 (kWh) を種類ごとに合計して返します。
+func (m *Monitor) energyTotals() map[string]float64 {
+	totals := make(map[string]float64)
+	for _, t := range m.targets {
+		for epc, kind := range energyCounterEPCs[t.EOJ.ClassCode] {
+			if c, ok := m.energy[t.ObjectName+"."+echonetlite.PropertyName(t.EOJ, epc)]; ok {
+				totals[kind] += c.today
+			}
+		}
+	}
+	return totals
+}
@@ -0,0 +1,80 @@
+package monitor
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestDailyCounterUpdate(t *testing.T) {
+	var c dailyCounter
+	day := time.Date(2025, 6, 1, 9, 0, 0, 0, time.Local)
+
+	c.update(day, 1000)
+	c.update(day.Add(3*time.Hour), 1002.5)
+	if math.Abs(c.today-2.5) > 1e-9 {
+		t.Errorf("today = %.2f kWh, want 2.50", c.today)
+	}
+
+	// A new day restarts from the counter read at that time.
+	c.update(day.Add(24*time.Hour), 1010)
+	if c.today != 0 {
+		t.Errorf("today after rollover = %.2f kWh, want 0", c.today)
+	}
+
+	// A counter that goes backwards (wrap or replacement) becomes the new baseline.
+	c.update(day.Add(25*time.Hour), 3)
+	if c.today != 0 {
+		t.Errorf("today after counter reset = %.2f kWh, want 0", c.today)
+	}
+}
+
+func TestWithEnergyCounters(t *testing.T) {
+	targets := withEnergyCounters([]MonitoringTarget{
+		{EOJ: echonetlite.NewEOJ(0x02, 0x7D, 0x01), EPCs: []byte{0xE4}},
+		{EOJ: echonetlite.NewEOJ(0x02, 0x79, 0x01), EPCs: []byte{0xE0}},
+		{EOJ: echonetlite.NewEOJ(0x02, 0x87, 0x01), EPCs: []byte{0xC6}},
+	})
+
+	for i, want := range [][]byte{{0xE4, 0xD8, 0xD6}, {0xE0, 0xE1}, {0xC6}} {
+		if string(targets[i].EPCs) != string(want) {
+			t.Errorf("targets[%d].EPCs = %X, want %X", i, targets[i].EPCs, want)
+		}
+	}
+	if string(targets[0].SlowEPCs) != string([]byte{0xD8, 0xD6}) {
+		t.Errorf("battery SlowEPCs = %X, want D8 D6", targets[0].SlowEPCs)
+	}
+}
+
+func TestMonitorTracksEnergyCounters(t *testing.T) {
+	u32 := func(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+	device := monitortest.NewFakeEIBS7()
+	battery := echonetlite.NewEOJ(0x02, 0x7D, 0x01)
+	pv := echonetlite.NewEOJ(0x02, 0x79, 0x01)
+	device.Props[battery][0xD8] = u32(5000) // 5.000 kWh charged
+	device.Props[battery][0xD6] = u32(4000)
+	device.Props[pv][0xE1] = u32(20000)
+
+	now := monitortest.Now
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour), func(c *config.Config) {
+		c.EnergyCounters = true
+	})
+
+	m.runCycle()
+	device.Props[battery][0xD8] = u32(6500)
+	device.Props[pv][0xE1] = u32(23250)
+	m.runCycle()
+
+	charged, discharged, generated := m.energy[m.batteries[0].name+".積算充電電力量計測値"], m.energy[m.batteries[0].name+".積算放電電力量計測値"], m.energy["住宅用太陽光発電 (027901).積算発電電力量計測値"]
+	if charged == nil || discharged == nil || generated == nil {
+		t.Fatalf("energy counters = %v, want charge, discharge and generation", m.energy)
+	}
+	if math.Abs(charged.today-1.5) > 1e-9 || discharged.today != 0 || math.Abs(generated.today-3.25) > 1e-9 {
+		t.Errorf("today = %.3f / %.3f / %.3f kWh, want 1.500 / 0.000 / 3.250", charged.today, discharged.today, generated.today)
+	}
+}
@@ -0,0 +1,48 @@
+package monitor
+
+import (
+	"log"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// applyEPCOverrides は、epc_overrides の設定に従って監視対象の取得するプロパティを変更します。
+// プロパティを置き換えた場合、変化の少ないプロパティ (SlowEPCs) は置き換え後も取得するものだけを残します。
+// 一致する監視対象がない設定は、ログに出力して無視します。
+func applyEPCOverrides(targets []MonitoringTarget, overrides []config.EPCOverride, targetIP string) []MonitoringTarget {
+	for _, o := range overrides {
+		found := false
+		for i, t := range targets {
+			ip := targetIP
+			if t.client != nil {
+				ip = t.client.TargetIP
+			}
+			if t.EOJ != o.ParsedEOJ || ip != o.IP {
+				continue
+			}
+			found = true
+			epcs, slow := t.EPCs, t.SlowEPCs
+			if len(o.ParsedEPCs) > 0 {
+				epcs, slow = append([]byte(nil), o.ParsedEPCs...), nil
+				for _, epc := range t.SlowEPCs {
+					if containsEPC(epcs, epc) {
+						slow = append(slow, epc)
+					}
+				}
+			} else {
+				epcs = append([]byte(nil), epcs...)
+			}
+			for _, epc := range o.ParsedExtraEPCs {
+				if !containsEPC(epcs, epc) {
+					epcs = append(epcs, epc)
+				}
+			}
+			targets[i].EPCs, targets[i].SlowEPCs = epcs, slow
+			log.Printf("[機器構成] %s の取得するプロパティを変更しました: %X", t.ObjectName, epcs)
+		}
+		if !found {
+			log.Printf("[機器構成] epc_overrides の %s (%s) に一致する監視対象がないため、無視します。", o.EOJ, o.IP)
+		}
+	}
+	return targets
+}
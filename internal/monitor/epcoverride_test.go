@@ -0,0 +1,38 @@
+package monitor
+
+import (
+	"testing"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestMonitorAppliesEPCOverrides(t *testing.T) {
+	overrides := []config.EPCOverride{
+		{EOJ: "027901", ExtraEPCs: []string{"E1"}},
+		{EOJ: "027D01", EPCs: []string{"E4", "DA", "EB", "A0"}},
+		{EOJ: "027901", IP: "192.168.0.11", ExtraEPCs: []string{"E1"}}, // no such target
+	}
+	for i := range overrides {
+		if err := overrides[i].Validate("192.168.0.10"); err != nil {
+			t.Fatalf("validate failed: %v", err)
+		}
+	}
+	now := monitortest.Now
+	m := newTestMonitor(monitortest.NewFakeEIBS7(), now, now, func(c *config.Config) {
+		c.EPCOverrides = overrides
+	})
+
+	battery, pv := m.targets[0], m.targets[1]
+	// Fault status EPCs are still added after the override.
+	if string(battery.EPCs) != string([]byte{0xE4, 0xDA, 0xEB, 0xA0, 0x88, 0x89}) || string(battery.SlowEPCs) != string([]byte{0xA0}) {
+		t.Errorf("battery EPCs = %X (slow %X), want E4 DA EB A0 88 89 (slow A0)", battery.EPCs, battery.SlowEPCs)
+	}
+	if string(pv.EPCs) != string([]byte{0xE0, 0xE1, 0x88, 0x89}) {
+		t.Errorf("PV EPCs = %X, want E0 E1 88 89", pv.EPCs)
+	}
+	// The shared default EPC list is not modified.
+	if got := targetClasses[0x79].epcs; string(got) != string([]byte{0xE0}) {
+		t.Errorf("targetClasses PV EPCs = %X, want E0", got)
+	}
+}
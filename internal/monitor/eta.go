@@ -0,0 +1,52 @@
+package monitor
+
+import (
+	"log"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// chargeETA は、現在の蓄電残量と実際の充電電力 (瞬時充放電電力計測値) から、目標の蓄電残量に達する予定時刻を返します。
+// 既に目標に達している場合は now を返し、充電していない (充電電力が 0 以下の) 場合は ok = false を返します。
+func chargeETA(now time.Time, window config.ChargeWindow, acCapacity uint32, soc uint8, batteryPowerWatts int32) (eta time.Time, ok bool) {
+	remainingWh := window.TargetChargeWh(acCapacity, soc)
+	if remainingWh == 0 {
+		return now, true
+	}
+	if batteryPowerWatts <= 0 {
+		return time.Time{}, false
+	}
+	return now.Add(time.Duration(remainingWh / float64(batteryPowerWatts) * float64(time.Hour))), true
+}
+
+// updateChargeETA は、充電時間帯に充電の完了予定時刻を計算してログに出力し、手動操作の status コマンドで表示できるようにします。
+// 完了予定時刻が充電時間帯の終了より後の場合は、目標に達しない見込みであることを出力します。
+func (m *Monitor) updateChargeETA(now time.Time, monitoringData map[string]interface{}, window config.ChargeWindow, inWindow bool) {
+	if !inWindow {
+		m.Override.setChargeETA("")
+		return
+	}
+	acCapacity, acOK := monitoringData["蓄電池.AC実効容量（充電）"].(uint32)
+	soc, sOK := monitoringData["蓄電池.蓄電残量3"].(uint8)
+	batteryPower, bOK := monitoringData["蓄電池.瞬時充放電電力計測値"].(int32)
+	if !acOK || !sOK || !bOK {
+		m.Override.setChargeETA("")
+		return
+	}
+
+	windowEnd := window.End(now)
+	eta, ok := chargeETA(now, window, acCapacity, soc, batteryPower)
+	if !ok {
+		log.Printf("[計算値] 充電していないため、充電の完了予定時刻を計算できません (蓄電残量: %d%%, 目標: %d%%)。", soc, window.TargetSOCPercent)
+		m.Override.setChargeETA("charge ETA unknown (not charging)")
+		return
+	}
+	log.Printf("[計算値] 充電の完了予定時刻: %s (蓄電残量: %d%%, 目標: %d%%, 充電電力: %d W, 充電時間帯の終了: %s)", eta.Format("15:04"), soc, window.TargetSOCPercent, batteryPower, windowEnd.Format("15:04"))
+	status := "charge ETA " + eta.Format("15:04") + " (window ends " + windowEnd.Format("15:04") + ")"
+	if eta.After(windowEnd) {
+		log.Printf("[計算値] 現在の充電電力では、充電時間帯の終了 (%s) までに目標の蓄電残量 (%d%%) に達しない見込みです。", windowEnd.Format("15:04"), window.TargetSOCPercent)
+		status += ", behind schedule"
+	}
+	m.Override.setChargeETA(status)
+}
@@ -0,0 +1,37 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+func TestChargeETA(t *testing.T) {
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.Local)
+	window := config.ChargeWindow{TimeSlot: config.TimeSlot{StartTime: "09:00", EndTime: "15:00"}, TargetSOCPercent: 100}
+
+	// 50% of 10000 Wh at 2500 W takes two hours.
+	if eta, ok := chargeETA(now, window, 10000, 50, 2500); !ok || !eta.Equal(now.Add(2*time.Hour)) {
+		t.Errorf("chargeETA = %s, %t, want 12:00", eta.Format("15:04"), ok)
+	}
+	if eta, ok := chargeETA(now, window, 10000, 100, 0); !ok || !eta.Equal(now) {
+		t.Errorf("chargeETA at the target = %s, %t, want now", eta.Format("15:04"), ok)
+	}
+	if _, ok := chargeETA(now, window, 10000, 50, -300); ok {
+		t.Error("chargeETA succeeded while discharging")
+	}
+}
+
+func TestChargeETAInStatus(t *testing.T) {
+	o := NewManualOverride(time.Local)
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.Local)
+	o.setChargeETA("charge ETA 12:00 (window ends 15:00)")
+	if res, err := o.HandleCommand("status", now); err != nil || res != "none; charge ETA 12:00 (window ends 15:00)" {
+		t.Errorf("status = %q, %v", res, err)
+	}
+	o.setChargeETA("")
+	if res, _ := o.HandleCommand("status", now); res != "none" {
+		t.Errorf("status = %q after clearing the ETA, want none", res)
+	}
+}
@@ -0,0 +1,67 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// evChargerTarget は、EV 充電器の監視対象を返します。
+func evChargerTarget(c config.EVChargerConfig) MonitoringTarget {
+	name := "電気自動車充電器"
+	if c.Bidirectional {
+		name = "電気自動車充放電器"
+	}
+	eoj := c.EOJ()
+	return MonitoringTarget{
+		EOJ:        eoj,
+		EPCs:       []byte{0xD3, 0xDA}, // 瞬時充(放)電電力計測値, 運転モード設定
+		ObjectName: fmt.Sprintf("%s (%02X%02X%02X)", name, eoj.ClassGroupCode, eoj.ClassCode, eoj.InstanceCode),
+	}
+}
+
+// updateEVCharger は、EV の充電電力を取得し、priority が "battery" の場合は蓄電池の充電を優先するよう EV の充電を制御します。
+// 充電時間帯に蓄電残量が目標に達していない間は EV の充電を待機 (0x44) にし、それ以外の間は一時停止した充電を再開 (0x42) します。
+// 一時停止する EV の充電電力は蓄電池の充電に使用できるため、余剰電力に加算する電力 (W) として返します。
+func (m *Monitor) updateEVCharger(monitoringData map[string]interface{}, chargeTimes config.ChargeWindow, inWindow bool) int32 {
+	cfg := m.cfg.EVCharger
+	if !cfg.Enabled {
+		return 0
+	}
+	target := evChargerTarget(cfg)
+	power, pOK := monitoringData[target.ObjectName+"."+echonetlite.PropertyName(target.EOJ, 0xD3)].(int32)
+	mode, mOK := monitoringData[target.ObjectName+".運転モード設定"].(uint8)
+	if !pOK || !mOK {
+		log.Println("[EV] EV 充電器の充電電力または運転モードが取得できなかったため、制御をスキップします。")
+		return 0
+	}
+	log.Printf("[EV] 充電電力: %d W, 運転モード: 0x%X (優先: %s)", power, mode, cfg.Priority)
+	if cfg.Priority != config.EVPriorityBattery {
+		return 0
+	}
+
+	soc, sOK := monitoringData["蓄電池.蓄電残量3"].(uint8)
+	batteryFirst := inWindow && sOK && int(soc) < chargeTimes.TargetSOCPercent
+	switch {
+	case batteryFirst && mode == 0x42: // 0x42: 充電
+		log.Printf("[EV] 蓄電池の充電を優先するため、EV の充電を一時停止します (蓄電残量: %d%%, 目標: %d%%)。", soc, chargeTimes.TargetSOCPercent)
+		if err := m.client.setOperationModeOf(target.EOJ, "EV 充電器", 0x44); err != nil { // 0x44: 待機
+			log.Printf("[EV] EV 充電器の運転モード設定（待機）に失敗しました: %v", err)
+			return 0
+		}
+		m.evPaused = true
+		if power > 0 {
+			return power
+		}
+	case !batteryFirst && m.evPaused:
+		log.Println("[EV] 蓄電池の充電を優先する必要がなくなったため、EV の充電を再開します。")
+		if err := m.client.setOperationModeOf(target.EOJ, "EV 充電器", 0x42); err != nil { // 0x42: 充電
+			log.Printf("[EV] EV 充電器の運転モード設定（充電）に失敗しました: %v", err)
+			return 0
+		}
+		m.evPaused = false
+	}
+	return 0
+}
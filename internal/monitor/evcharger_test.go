@@ -0,0 +1,86 @@
+package monitor
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func newFakeEVCharger(device *monitortest.FakeEIBS7, power uint32, mode byte) echonetlite.EOJ {
+	eoj := echonetlite.NewEOJ(0x02, 0xA1, 0x01)
+	device.Props[eoj] = map[byte][]byte{
+		0xD3: binary.BigEndian.AppendUint32(nil, power),
+		0xDA: {mode},
+	}
+	return eoj
+}
+
+func TestMonitorPausesEVChargingForBattery(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	ev := newFakeEVCharger(device, 2000, 0x42)
+	now := monitortest.Now
+	m := newTestMonitor(device, now.Add(-time.Hour), now.Add(time.Hour), func(c *config.Config) {
+		c.MaxChargePowerWatts = 5000
+		c.EVCharger = config.EVChargerConfig{Enabled: true, Instance: 1, Priority: config.EVPriorityBattery}
+	})
+
+	m.runCycle()
+
+	// The EV is paused, and its 2000 W is added to the 3000 W surplus: min(5000-500, 5000) = 4500 W.
+	if len(device.Sets) != 2 {
+		t.Fatalf("expected 2 SetC, got %d", len(device.Sets))
+	}
+	if s := device.Sets[0]; s.DEOJ != ev || s.Properties[0].EPC != 0xDA || s.Properties[0].EDT[0] != 0x44 {
+		t.Errorf("first SetC = DEOJ %v EPC 0x%X EDT %X, want EV standby", s.DEOJ, s.Properties[0].EPC, s.Properties[0].EDT)
+	}
+	p := device.Sets[1].Properties[0]
+	if p.EPC != 0xEB || binary.BigEndian.Uint32(p.EDT) != 4500 {
+		t.Errorf("second SetC = EPC 0x%X EDT %X, want charge power 4500 W", p.EPC, p.EDT)
+	}
+	if !m.evPaused {
+		t.Error("evPaused = false after pausing the EV")
+	}
+}
+
+func TestMonitorResumesEVChargingOutsideWindow(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	ev := newFakeEVCharger(device, 0, 0x44)
+	now := monitortest.Now
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour), func(c *config.Config) {
+		c.EVCharger = config.EVChargerConfig{Enabled: true, Instance: 1, Priority: config.EVPriorityBattery}
+	})
+	m.evPaused = true
+
+	m.runCycle()
+
+	if len(device.Sets) == 0 {
+		t.Fatal("expected a SetC to resume the EV")
+	}
+	if s := device.Sets[0]; s.DEOJ != ev || s.Properties[0].EPC != 0xDA || s.Properties[0].EDT[0] != 0x42 {
+		t.Errorf("first SetC = DEOJ %v EPC 0x%X EDT %X, want EV charge mode", s.DEOJ, s.Properties[0].EPC, s.Properties[0].EDT)
+	}
+	if m.evPaused {
+		t.Error("evPaused = true after resuming the EV")
+	}
+}
+
+func TestMonitorLeavesEVChargingWithEVPriority(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	ev := newFakeEVCharger(device, 2000, 0x42)
+	now := monitortest.Now
+	m := newTestMonitor(device, now.Add(-time.Hour), now.Add(time.Hour), func(c *config.Config) {
+		c.EVCharger = config.EVChargerConfig{Enabled: true, Instance: 1, Priority: config.EVPriorityEV}
+	})
+
+	m.runCycle()
+
+	for _, s := range device.Sets {
+		if s.DEOJ == ev {
+			t.Errorf("unexpected SetC to the EV charger: EPC 0x%X EDT %X", s.Properties[0].EPC, s.Properties[0].EDT)
+		}
+	}
+}
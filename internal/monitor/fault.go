@@ -0,0 +1,93 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/controller"
+)
+
+// faultDescription は、異常内容 (EPC 0x89) です。下位バイトが異常の種類、上位バイトがその詳細です。
+type faultDescription uint16
+
+var faultDescriptionNames = map[byte]string{
+	0x00: "異常なし",
+	0x01: "電源の入れ直しやリセットで復帰可能",
+	0x02: "正しく取り付けることで復帰可能",
+	0x03: "補給で復帰可能",
+	0x04: "清掃で復帰可能",
+	0x05: "電池の交換で復帰可能",
+	0x0A: "安全装置による異常停止",
+	0x0B: "スイッチの異常",
+	0x0C: "センサーの異常",
+	0x0D: "機能部品の異常",
+	0x0E: "制御基板の異常",
+}
+
+// String は、異常内容の種類と値を返します (例: "センサーの異常 (0x010C)")。
+func (d faultDescription) String() string {
+	code := byte(d)
+	name, ok := faultDescriptionNames[code]
+	switch {
+	case ok:
+	case code <= 0x09:
+		name = "復帰可能な異常"
+	case code <= 0x13:
+		name = "修理が必要な異常"
+	default:
+		name = "不明な異常"
+	}
+	return fmt.Sprintf("%s (0x%04X)", name, uint16(d))
+}
+
+// withFaultStatus は、すべての監視対象に異常発生状態 (EPC 0x88) と異常内容 (EPC 0x89) を追加します。
+func withFaultStatus(targets []MonitoringTarget) []MonitoringTarget {
+	for i, t := range targets {
+		targets[i].EPCs = append(append([]byte(nil), t.EPCs...), 0x88, 0x89)
+	}
+	return targets
+}
+
+// detectFaults は、異常の発生を通知している監視対象を調べ、"<監視対象名>: <異常内容>" の一覧を返します。
+// 異常の発生と解消をログに出力し、異常の発生中は手動操作の status コマンドでも表示します。
+func (m *Monitor) detectFaults(monitoringData map[string]interface{}) []string {
+	var faults []string
+	for _, t := range m.targets {
+		status, ok := monitoringData[t.ObjectName+".異常発生状態"].(uint8)
+		if !ok || status != 0x41 { // 0x41: 異常発生あり
+			continue
+		}
+		desc := "異常内容不明"
+		if d, ok := monitoringData[t.ObjectName+".異常内容"].(faultDescription); ok {
+			desc = d.String()
+		}
+		faults = append(faults, fmt.Sprintf("%s: %s", t.ObjectName, desc))
+	}
+
+	current := strings.Join(faults, ", ")
+	switch {
+	case current != "" && current != m.faults:
+		log.Printf("[異常] 警告: 機器が異常の発生を通知しています (%s)。異常が解消するまで、機器への設定を含む自動制御を停止します。", current)
+		m.Override.setFault("fault: " + current)
+		m.alerts.fire(config.AlertFault, "機器が異常の発生を通知しています: "+current)
+	case current == "" && m.faults != "":
+		log.Printf("[異常] 機器の異常が解消しました (%s)。自動制御を再開します。", m.faults)
+		m.Override.setFault("")
+	}
+	m.faults = current
+	return faults
+}
+
+// controlDeviceFault は、機器が異常の発生を通知している間の制御です。制御状態を DeviceFault にし、機器には何も設定しません。
+func (m *Monitor) controlDeviceFault() {
+	from, state := m.controller.Step(controller.Input{
+		Reachable:   !m.watchdog.unreachable,
+		DeviceFault: true,
+	})
+	if from != state {
+		log.Printf("[制御] 制御状態が遷移しました: %s -> %s", from, state)
+	}
+	log.Printf("[異常] 機器の異常 (%s) が解消していないため、制御をスキップします。", m.faults)
+}
@@ -0,0 +1,57 @@
+package monitor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/controller"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestFaultDescriptionString(t *testing.T) {
+	for d, want := range map[faultDescription]string{
+		0x0000: "異常なし (0x0000)",
+		0x010C: "センサーの異常 (0x010C)",
+		0x0007: "復帰可能な異常 (0x0007)",
+		0x0012: "修理が必要な異常 (0x0012)",
+		0x00FF: "不明な異常 (0x00FF)",
+	} {
+		if got := d.String(); got != want {
+			t.Errorf("faultDescription(0x%04X).String() = %q, want %q", uint16(d), got, want)
+		}
+	}
+}
+
+func TestMonitorStopsControlOnDeviceFault(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	pv := echonetlite.NewEOJ(0x02, 0x79, 0x01)
+	device.Props[pv][0x88] = []byte{0x41}
+	device.Props[pv][0x89] = []byte{0x01, 0x0C}
+	now := monitortest.Now
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour))
+
+	m.runCycle()
+
+	if len(device.Sets) != 0 {
+		t.Fatalf("expected no SetC while a device reports a fault, got %d", len(device.Sets))
+	}
+	if s := m.controller.State(); s != controller.DeviceFault {
+		t.Errorf("controller state = %s, want DeviceFault", s)
+	}
+	res, _ := m.Override.HandleCommand("status", now)
+	if !strings.Contains(res, "fault: 住宅用太陽光発電 (027901): センサーの異常 (0x010C)") {
+		t.Errorf("status = %q, want the fault", res)
+	}
+
+	// Once the fault clears, automatic control resumes.
+	device.Props[pv][0x88] = []byte{0x42}
+	m.runCycle()
+	if len(device.Sets) != 1 {
+		t.Fatalf("expected automatic control to resume, got %d SetC", len(device.Sets))
+	}
+	if res, _ := m.Override.HandleCommand("status", now); strings.Contains(res, "fault") {
+		t.Errorf("status = %q after the fault cleared", res)
+	}
+}
@@ -0,0 +1,190 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// newPVForecaster は、設定された取得元の pvForecaster を作成します。
+func newPVForecaster(cfg config.ForecastConfig) pvForecaster {
+	if cfg.Provider == config.ForecastProviderSolcast {
+		return newSolcastForecaster(cfg)
+	}
+	return newOpenMeteoForecaster(cfg)
+}
+
+// pvForecaster は、指定された期間の太陽光発電量 (kWh) を予測します。
+type pvForecaster interface {
+	forecastPVKWh(from, to time.Time) (float64, error)
+}
+
+// Open-Meteo の予報APIのエンドポイント
+const openMeteoForecastURL = "https://api.open-meteo.com/v1/forecast"
+
+// openMeteoForecaster は、Open-Meteo の傾斜面日射量予報から発電量を予測します。
+type openMeteoForecaster struct {
+	cfg     config.ForecastConfig
+	baseURL string
+	client  *http.Client
+}
+
+// newOpenMeteoForecaster は、設定に基づいて openMeteoForecaster を作成します。
+func newOpenMeteoForecaster(cfg config.ForecastConfig) *openMeteoForecaster {
+	return &openMeteoForecaster{
+		cfg:     cfg,
+		baseURL: openMeteoForecastURL,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// openMeteoResponse は、Open-Meteo の応答のうち必要な部分です。
+type openMeteoResponse struct {
+	Hourly struct {
+		Time                   []string   `json:"time"`
+		GlobalTiltedIrradiance []*float64 `json:"global_tilted_irradiance"`
+	} `json:"hourly"`
+}
+
+// forecastPVKWh は、[from, to) の期間の予測発電量 (kWh) を返します。
+// 1時間ごとの傾斜面日射量 (W/m²) をパネル容量と出力係数で発電量に換算して合計します。
+func (f *openMeteoForecaster) forecastPVKWh(from, to time.Time) (float64, error) {
+	q := url.Values{}
+	q.Set("latitude", strconv.FormatFloat(f.cfg.Latitude, 'f', -1, 64))
+	q.Set("longitude", strconv.FormatFloat(f.cfg.Longitude, 'f', -1, 64))
+	q.Set("hourly", "global_tilted_irradiance")
+	q.Set("tilt", strconv.FormatFloat(f.cfg.PanelTilt, 'f', -1, 64))
+	q.Set("azimuth", strconv.FormatFloat(f.cfg.PanelAzimuth, 'f', -1, 64))
+	q.Set("timezone", "GMT") // 時刻は UTC で受け取り、期間の判定は絶対時刻で行う
+	q.Set("forecast_days", "3")
+
+	resp, err := f.client.Get(f.baseURL + "?" + q.Encode())
+	if err != nil {
+		return 0, fmt.Errorf("発電量予測の取得に失敗しました: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("発電量予測の取得に失敗しました: HTTP %d", resp.StatusCode)
+	}
+
+	var data openMeteoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return 0, fmt.Errorf("発電量予測の解析に失敗しました: %w", err)
+	}
+	if len(data.Hourly.Time) != len(data.Hourly.GlobalTiltedIrradiance) {
+		return 0, fmt.Errorf("発電量予測の形式が不正です (time: %d件, global_tilted_irradiance: %d件)", len(data.Hourly.Time), len(data.Hourly.GlobalTiltedIrradiance))
+	}
+
+	var kwh float64
+	found := false
+	for i, ts := range data.Hourly.Time {
+		t, err := time.ParseInLocation("2006-01-02T15:04", ts, time.UTC)
+		if err != nil {
+			return 0, fmt.Errorf("発電量予測の時刻 '%s' の解析に失敗しました: %w", ts, err)
+		}
+		// 各値は直前1時間の平均値のため、区間の終わりの時刻で判定する
+		if !t.After(from) || t.After(to) {
+			continue
+		}
+		found = true
+		if v := data.Hourly.GlobalTiltedIrradiance[i]; v != nil {
+			kwh += *v / 1000 * f.cfg.PanelKW * f.cfg.PerformanceRatio
+		}
+	}
+	if !found {
+		return 0, fmt.Errorf("発電量予測に %s から %s の期間のデータが含まれていません", from.Format("2006-01-02 15:04"), to.Format("2006-01-02 15:04"))
+	}
+	return kwh, nil
+}
+
+// Solcast の Rooftop Site の予報APIのエンドポイント
+const solcastForecastURL = "https://api.solcast.com.au/rooftop_sites/%s/forecasts"
+
+// solcastForecaster は、Solcast の Rooftop Site の発電量予報 (pv_estimate) から発電量を予測します。
+// パネルの設定は Solcast 側に登録したものが使用されます。
+type solcastForecaster struct {
+	cfg     config.ForecastConfig
+	baseURL string
+	client  *http.Client
+}
+
+// newSolcastForecaster は、設定に基づいて solcastForecaster を作成します。
+func newSolcastForecaster(cfg config.ForecastConfig) *solcastForecaster {
+	return &solcastForecaster{
+		cfg:     cfg,
+		baseURL: solcastForecastURL,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// solcastResponse は、Solcast の応答のうち必要な部分です。
+type solcastResponse struct {
+	Forecasts []struct {
+		PVEstimate float64 `json:"pv_estimate"` // 期間の平均発電電力 (kW)
+		PeriodEnd  string  `json:"period_end"`
+		Period     string  `json:"period"` // ISO 8601 の期間 (例: "PT30M")
+	} `json:"forecasts"`
+}
+
+// forecastPVKWh は、[from, to) の期間の予測発電量 (kWh) を返します。
+func (f *solcastForecaster) forecastPVKWh(from, to time.Time) (float64, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(f.baseURL, url.PathEscape(f.cfg.SolcastResourceID))+"?format=json", nil)
+	if err != nil {
+		return 0, fmt.Errorf("発電量予測の取得に失敗しました: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+string(f.cfg.SolcastAPIKey))
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("発電量予測の取得に失敗しました: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("発電量予測の取得に失敗しました: HTTP %d", resp.StatusCode)
+	}
+
+	var data solcastResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return 0, fmt.Errorf("発電量予測の解析に失敗しました: %w", err)
+	}
+
+	var kwh float64
+	found := false
+	for _, fc := range data.Forecasts {
+		end, err := time.Parse(time.RFC3339Nano, fc.PeriodEnd)
+		if err != nil {
+			return 0, fmt.Errorf("発電量予測の時刻 '%s' の解析に失敗しました: %w", fc.PeriodEnd, err)
+		}
+		period, err := parseISO8601Period(fc.Period)
+		if err != nil {
+			return 0, err
+		}
+		// 区間の終わりの時刻で判定する
+		if !end.After(from) || end.After(to) {
+			continue
+		}
+		found = true
+		kwh += fc.PVEstimate * period.Hours()
+	}
+	if !found {
+		return 0, fmt.Errorf("発電量予測に %s から %s の期間のデータが含まれていません", from.Format("2006-01-02 15:04"), to.Format("2006-01-02 15:04"))
+	}
+	return kwh, nil
+}
+
+// parseISO8601Period は、"PT30M" や "PT1H" 形式の期間を解析します。空文字列の場合は30分とします。
+func parseISO8601Period(s string) (time.Duration, error) {
+	if s == "" {
+		return 30 * time.Minute, nil
+	}
+	d, err := time.ParseDuration(strings.ToLower(strings.TrimPrefix(s, "PT")))
+	if err != nil || !strings.HasPrefix(s, "PT") || d <= 0 {
+		return 0, fmt.Errorf("発電量予測の期間 '%s' の解析に失敗しました", s)
+	}
+	return d, nil
+}
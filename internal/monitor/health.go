@@ -0,0 +1,132 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ServiceHealth は、死活確認 (GET /healthz) と準備完了の確認 (GET /readyz) に使用する状態です。
+// コンテナのオーケストレーターの probe や外形監視から、トークンなしで参照できます。
+type ServiceHealth struct {
+	mu           sync.Mutex
+	started      time.Time     // 監視ループを開始した時刻。ゼロ値の場合は開始前
+	stallTimeout time.Duration // 監視サイクルがこの時間以上完了しない場合は停止しているとみなす (loop_stall_timeout_seconds)
+	lastCycle    time.Time     // 最後に監視サイクルを完了した時刻
+	lastSuccess  time.Time     // 最後に機器から測定値を取得できた監視サイクルの開始時刻
+	reachable    bool          // 最後の監視サイクルで機器に到達できたか
+	configErr    error         // 最後に設定ファイルを読み込み直したときのエラー
+}
+
+// Start は、監視ループの開始を記録します。
+func (h *ServiceHealth) Start(now time.Time, stallTimeout time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.started, h.stallTimeout = now, stallTimeout
+}
+
+// Heartbeat は、監視サイクルを (panic せずに) 完了したことを記録します。
+func (h *ServiceHealth) Heartbeat(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastCycle = now
+}
+
+// Observe は、監視サイクルでの機器の状態を記録します。measured は測定値を取得できた場合に true です。
+func (h *ServiceHealth) Observe(cycleStart time.Time, reachable, measured bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.reachable = reachable
+	if reachable && measured {
+		h.lastSuccess = cycleStart
+	}
+}
+
+// ConfigLoaded は、設定ファイルを読み込み直した結果を記録します。
+func (h *ServiceHealth) ConfigLoaded(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.configErr = err
+}
+
+// HealthStatus は、/healthz と /readyz の応答です。
+type HealthStatus struct {
+	Status          string   `json:"status"` // "ok" または "unavailable"
+	LastCycle       string   `json:"last_cycle,omitempty"`
+	LastSuccess     string   `json:"last_successful_cycle,omitempty"`
+	DeviceReachable bool     `json:"device_reachable"`
+	ConfigValid     bool     `json:"config_valid"`
+	Problems        []string `json:"problems,omitempty"` // 異常とした理由
+}
+
+// check は、現在の状態を返します。ready が false の場合は死活のみ (監視ループが停止していないこと) を確認します。
+// 設定ファイルのエラーの内容はトークンなしで返さないよう、ログにのみ出力します。
+func (h *ServiceHealth) check(now time.Time, ready bool) HealthStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := HealthStatus{DeviceReachable: h.reachable, ConfigValid: h.configErr == nil}
+	if !h.lastCycle.IsZero() {
+		s.LastCycle = h.lastCycle.Format(time.RFC3339)
+	}
+	if !h.lastSuccess.IsZero() {
+		s.LastSuccess = h.lastSuccess.Format(time.RFC3339)
+	}
+
+	// 最初の監視サイクルの完了までは、開始時刻から停止を判定する
+	last := h.lastCycle
+	if last.IsZero() {
+		last = h.started
+	}
+	switch {
+	case h.started.IsZero():
+		s.Problems = append(s.Problems, "監視ループが開始していません")
+	case now.Sub(last) >= h.stallTimeout:
+		s.Problems = append(s.Problems, "監視サイクルが完了していません")
+	}
+	if ready {
+		if h.lastSuccess.IsZero() {
+			s.Problems = append(s.Problems, "機器から測定値を取得できていません")
+		}
+		if !h.reachable {
+			s.Problems = append(s.Problems, "機器に到達できません")
+		}
+		if h.configErr != nil {
+			s.Problems = append(s.Problems, "設定ファイルを読み込み直せませんでした")
+		}
+	}
+	s.Status = "ok"
+	if len(s.Problems) > 0 {
+		s.Status = "unavailable"
+	}
+	return s
+}
+
+// Handler は、ready を指定して /healthz または /readyz のハンドラーを返します。異常の場合は 503 を返します。
+func (h *ServiceHealth) Handler(ready bool, now func() time.Time) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeAPIError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s は使用できません", r.Method))
+			return
+		}
+		s := h.check(now(), ready)
+		code := http.StatusOK
+		if s.Status != "ok" {
+			code = http.StatusServiceUnavailable
+		}
+		writeAPIJSON(w, code, s)
+	}
+}
+
+// writeAPIJSON は、v を JSON で返します。
+func writeAPIJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeAPIError は、エラーを {"error": "..."} の形式で返します。
+func writeAPIError(w http.ResponseWriter, code int, err error) {
+	writeAPIJSON(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,63 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// importGuardChargePower は、買電電力 importWatts が上限 limitWatts を超えている場合に、
+// 現在の充電電力設定値から下げた充電電力 (W) を返します。超過分が stepWatts より大きい場合は超過分を下げます。
+// 上限を超えていない場合は ok = false を返します。
+func importGuardChargePower(importWatts, limitWatts, currentWatts, stepWatts int) (power int, ok bool) {
+	if importWatts <= limitWatts {
+		return currentWatts, false
+	}
+	reduce := importWatts - limitWatts
+	if reduce < stepWatts {
+		reduce = stepWatts
+	}
+	power = currentWatts - reduce
+	if power < 0 {
+		power = 0
+	}
+	return power, true
+}
+
+// enforceImportLimit は、買電電力が上限を超えていて蓄電池が充電モードの場合に、充電電力を下げます。
+// 充電電力を下げた場合は true を返し、呼び出し側はこのサイクルの他の制御を行いません。
+// 充電電力を 0 W まで下げても上限を超える場合は、運転モードを「自動」にします。
+func (m *Monitor) enforceImportLimit(monitoringData map[string]interface{}, gridPower int32, currentOperationMode byte) bool {
+	cfg := m.cfg.ImportGuard
+	if !cfg.Enabled || currentOperationMode != 0x42 || int(gridPower) <= cfg.LimitWatts {
+		return false
+	}
+	currentChargePower, ok := monitoringData["蓄電池.充電電力設定値"].(uint32)
+	if !ok {
+		log.Printf("[買電制限] 買電電力 (%d W) が上限 (%d W) を超えていますが、充電電力設定値が取得できなかったため制御できません。", gridPower, cfg.LimitWatts)
+		return false
+	}
+
+	m.alerts.fire(config.AlertImportLimit, fmt.Sprintf("買電電力 (%d W) が上限 (%d W) を超えました", gridPower, cfg.LimitWatts))
+
+	// 買電を抑えるため、充電電力の引き上げはここから更新間隔が経過するまで行わない
+	m.lastChargePowerIncreaseTime = m.cfg.Now()
+
+	if currentChargePower == 0 {
+		log.Printf("[買電制限] 買電電力 (%d W) が上限 (%d W) を超えています。充電電力が 0 W のため、運転モードを「自動」に設定します。", gridPower, cfg.LimitWatts)
+		changed, err := m.setOperationMode(0x46, false) // 0x46: 自動モード
+		if err != nil {
+			log.Printf("[制御] 蓄電池の運転モード設定（自動）に失敗しました: %v", err)
+		}
+		m.modeChanged(changed)
+		return true
+	}
+
+	power, _ := importGuardChargePower(int(gridPower), cfg.LimitWatts, int(currentChargePower), cfg.StepWatts)
+	log.Printf("[買電制限] 買電電力 (%d W) が上限 (%d W) を超えているため、充電電力を %d W から %d W に下げます。", gridPower, cfg.LimitWatts, currentChargePower, power)
+	if err := m.setChargePower(power); err != nil {
+		log.Printf("[制御] 蓄電池の充電電力設定に失敗しました: %v", err)
+	}
+	return true
+}
@@ -0,0 +1,22 @@
+package monitor
+
+import "testing"
+
+func TestImportGuardChargePower(t *testing.T) {
+	for _, tc := range []struct {
+		importW, current int
+		want             int
+		ok               bool
+	}{
+		{4000, 2000, 2000, false}, // below the limit
+		{5000, 2000, 2000, false}, // at the limit
+		{5100, 2000, 1500, true},  // small excess: one step
+		{6200, 2000, 800, true},   // excess larger than a step
+		{5100, 300, 0, true},      // never below zero
+	} {
+		got, ok := importGuardChargePower(tc.importW, 5000, tc.current, 500)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("importGuardChargePower(%d, 5000, %d, 500) = %d, %t, want %d, %t", tc.importW, tc.current, got, ok, tc.want, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package monitor
+
+import (
+	"hash/fnv"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+)
+
+// notificationDeduper は、受信した通知フレームの内容のハッシュを一定期間記録し、
+// 同一内容のフレームが繰り返し届いた場合に1回だけ処理されるようにします。
+// 一部の EIBS7 のファームウェアは同じ INF フレームを何度も再送するため、その対策です。
+// 応答の待機中と通知の受信待ちなど複数の goroutine から同時に使用できます。
+type notificationDeduper struct {
+	mu         sync.Mutex
+	window     time.Duration
+	seen       map[uint64]time.Time
+	suppressed uint64 // 抑制した重複フレームの累計数
+}
+
+// newNotificationDeduper は、指定された期間を重複判定の対象とする notificationDeduper を作成します。
+func newNotificationDeduper(window time.Duration) *notificationDeduper {
+	return &notificationDeduper{
+		window: window,
+		seen:   make(map[uint64]time.Time),
+	}
+}
+
+// accept は、送信元とフレームの内容が期間内に受信済みでなければ true を返します。
+// 受信済みの場合は抑制数を加算して false を返します。
+func (d *notificationDeduper) accept(source string, data []byte, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for k, t := range d.seen {
+		if now.Sub(t) > d.window {
+			delete(d.seen, k)
+		}
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(source))
+	h.Write([]byte{0})
+	h.Write(data)
+	key := h.Sum64()
+
+	if _, ok := d.seen[key]; ok {
+		d.suppressed++
+		return false
+	}
+	d.seen[key] = now
+	return true
+}
+
+// suppressedCount は、抑制した重複フレームの累計数を返します。
+func (d *notificationDeduper) suppressedCount() uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.suppressed
+}
+
+// propertyKey は、プロパティを識別するためのキー (EOJ と EPC の組) です。
+type propertyKey struct {
+	EOJ echonetlite.EOJ
+	EPC byte
+}
+
+// cachedProperty は、デコード済みのプロパティの値と取得時刻です。
+type cachedProperty struct {
+	name  string
+	value interface{}
+	at    time.Time
+}
+
+// propertyCache は、デコード済みのプロパティの値を取得時刻とともに保持します。
+type propertyCache struct {
+	values map[propertyKey]cachedProperty
+}
+
+// newPropertyCache は、空の propertyCache を作成します。
+func newPropertyCache() *propertyCache {
+	return &propertyCache{values: make(map[propertyKey]cachedProperty)}
+}
+
+// store は、プロパティの値を記録します。
+func (c *propertyCache) store(eoj echonetlite.EOJ, epc byte, name string, value interface{}, at time.Time) {
+	c.values[propertyKey{EOJ: eoj, EPC: epc}] = cachedProperty{name: name, value: value, at: at}
+}
+
+// get は、maxAge 以内に記録されたプロパティの値を返します。
+func (c *propertyCache) get(eoj echonetlite.EOJ, epc byte, maxAge time.Duration, now time.Time) (cachedProperty, bool) {
+	v, ok := c.values[propertyKey{EOJ: eoj, EPC: epc}]
+	if !ok || now.Sub(v.at) > maxAge {
+		return cachedProperty{}, false
+	}
+	return v, true
+}
+
+// handleNotification は、受信した通知フレームのプロパティをデコードしてログに出力し、通知駆動の監視モードのために値を受信時刻 received とともに記録します。
+func (c *EchonetClient) handleNotification(frame echonetlite.Frame, addr *net.UDPAddr, received time.Time) {
+	log.Printf("[通知] %s から通知を受信しました (SEOJ: %02X%02X%02X, ESV: 0x%X, TID: %d)", addr.String(), frame.SEOJ.ClassGroupCode, frame.SEOJ.ClassCode, frame.SEOJ.InstanceCode, frame.ESV, frame.TID)
+	for _, prop := range frame.Properties {
+		decodedValue, propName, err := DecodeEDT(frame.SEOJ, prop.EPC, prop.EDT)
+		if err != nil {
+			log.Printf("[通知]   プロパティ: %s (EPC: 0x%X), PDC: %d, EDT: %X", propName, prop.EPC, prop.PDC, prop.EDT)
+			continue
+		}
+		log.Printf("[通知]   プロパティ: %s (EPC: 0x%X), PDC: %d, EDT: %X, 値: %v", propName, prop.EPC, prop.PDC, prop.EDT, decodedValue)
+		if decodedValue != nil {
+			c.notified.store(frame.SEOJ, prop.EPC, propName, decodedValue, received)
+		}
+	}
+}
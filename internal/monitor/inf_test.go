@@ -0,0 +1,56 @@
+package monitor
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+)
+
+func TestNotificationDeduperSuppressesIdenticalFrames(t *testing.T) {
+	d := newNotificationDeduper(5 * time.Second)
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local)
+	inf := []byte{0x10, 0x81, 0x00, 0x05, 0x02, 0x7D, 0x01, 0x05, 0xFF, 0x01, 0x73, 0x01, 0xE4, 0x01, 0x32}
+
+	if !d.accept("192.168.0.155", inf, base) {
+		t.Fatalf("first notification must be accepted")
+	}
+	for i := 1; i <= 3; i++ {
+		if d.accept("192.168.0.155", inf, base.Add(time.Duration(i)*time.Second)) {
+			t.Fatalf("resent notification #%d must be suppressed", i)
+		}
+	}
+	if d.suppressed != 3 {
+		t.Errorf("suppressed = %d, want 3", d.suppressed)
+	}
+
+	// A different value is a new notification.
+	changed := append([]byte(nil), inf...)
+	changed[len(changed)-1] = 0x33
+	if !d.accept("192.168.0.155", changed, base.Add(4*time.Second)) {
+		t.Errorf("notification with different content must be accepted")
+	}
+	// The same frame from another host is not a duplicate.
+	if !d.accept("192.168.0.156", inf, base.Add(4*time.Second)) {
+		t.Errorf("notification from another host must be accepted")
+	}
+	// Once the window has passed, the same content is delivered again.
+	if !d.accept("192.168.0.155", inf, base.Add(20*time.Second)) {
+		t.Errorf("notification after the window must be accepted")
+	}
+}
+
+func TestHandleNotificationStoresReceivedTime(t *testing.T) {
+	c := NewEchonetClient(echonetlite.NewFakeTransport(nil), "192.168.0.10", time.Second)
+	battery := echonetlite.NewEOJ(0x02, 0x7D, 0x01)
+	frame := echonetlite.Frame{SEOJ: battery, ESV: echonetlite.ESVInf, OPC: 1, Properties: []echonetlite.Property{{EPC: 0xE4, PDC: 1, EDT: []byte{60}}}}
+	received := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	c.handleNotification(frame, &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10), Port: echonetlite.Port}, received)
+
+	v, ok := c.notified.get(battery, 0xE4, time.Minute, received.Add(30*time.Second))
+	if !ok || !v.at.Equal(received) || v.value != uint8(60) {
+		t.Errorf("cached = %+v, %t; want 60 at %v", v, ok, received)
+	}
+}
@@ -0,0 +1,104 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// targetClass は、監視対象にする蓄電池以外の機器のクラスと、既定で取得するプロパティです。
+type targetClass struct {
+	role string
+	name string
+	epcs []byte
+}
+
+// targetClasses は、住宅設備関連機器クラスグループ (0x02) のクラスコードごとの監視対象の定義です。
+var targetClasses = map[byte]targetClass{
+	0x79: {role: config.RolePV, name: "住宅用太陽光発電", epcs: []byte{0xE0}},     // 瞬時発電電力計測値
+	0x87: {role: config.RoleMeter, name: "分電盤メータリング", epcs: []byte{0xC6}}, // 瞬時電力計測値
+	0xA5: {role: config.RolePCS, name: "マルチ入力PCS", epcs: []byte{0xE7}},    // 瞬時電力計測値
+}
+
+// defaultInstances は、インスタンスリストから監視対象を決めない場合の蓄電池以外の監視対象の機器です。
+var defaultInstances = []echonetlite.EOJ{
+	echonetlite.NewEOJ(0x02, 0x79, 0x01), // 住宅用太陽光発電
+	echonetlite.NewEOJ(0x02, 0x87, 0x01), // 分電盤メータリング
+	echonetlite.NewEOJ(0x02, 0xA5, 0x01), // マルチ入力PCS
+}
+
+// GetInstanceList は、ノードプロファイルの自ノードインスタンスリストS (EPC 0xD6) を取得し、機器の ECHONET Lite オブジェクトの一覧を返します。
+func (c *EchonetClient) GetInstanceList() ([]echonetlite.EOJ, error) {
+	responseFrame, err := c.Get(NodeProfileEOJ, 0xD6) // 自ノードインスタンスリストS
+	if err != nil {
+		return nil, err
+	}
+	tid := responseFrame.TID
+	if responseFrame.ESV != echonetlite.ESVGet_Res {
+		return nil, fmt.Errorf("予期しないESV (0x%X) を受信しました (TID: %d)", responseFrame.ESV, tid)
+	}
+	for _, prop := range responseFrame.Properties {
+		if prop.EPC != 0xD6 {
+			continue
+		}
+		// 先頭の1バイトがインスタンスの数、続く3バイトずつが ECHONET Lite オブジェクト
+		if len(prop.EDT) == 0 || len(prop.EDT) != 1+3*int(prop.EDT[0]) {
+			return nil, fmt.Errorf("インスタンスリストの長さ (%d バイト) が不正です (TID: %d)", len(prop.EDT), tid)
+		}
+		var eojs []echonetlite.EOJ
+		for i := 1; i < len(prop.EDT); i += 3 {
+			eojs = append(eojs, echonetlite.NewEOJ(prop.EDT[i], prop.EDT[i+1], prop.EDT[i+2]))
+		}
+		return eojs, nil
+	}
+	return nil, fmt.Errorf("応答にインスタンスリストが含まれていません (TID: %d)", tid)
+}
+
+// DiscoverInstances は、battery_instances が未設定の場合に、target_ip の機器のインスタンスリストから監視対象を決めます。
+// 蓄電池のインスタンスを battery_instances にし、targetClasses のクラスの機器を監視対象にします。
+// 分電盤メータリングは住宅全体の買電・売電電力を計測するものとして、最初のインスタンスのみを監視対象にします。
+// インスタンスリストを取得できなかった場合や蓄電池が含まれていない場合は、既定の監視対象を使用します。
+func DiscoverInstances(client *EchonetClient, cfg *config.Config) {
+	eojs, err := client.GetInstanceList()
+	if err != nil {
+		log.Printf("[機器構成] インスタンスリストを取得できなかったため、既定の監視対象を使用します: %v", err)
+		return
+	}
+
+	var batteries []int
+	instances := []echonetlite.EOJ{} // 蓄電池以外の機器がない場合も既定の監視対象を使用しないよう nil にしない
+	board := false
+	for _, eoj := range eojs {
+		if eoj.ClassGroupCode != 0x02 {
+			continue
+		}
+		if eoj.ClassCode == 0x7D { // 蓄電池
+			batteries = append(batteries, int(eoj.InstanceCode))
+			continue
+		}
+		if _, ok := targetClasses[eoj.ClassCode]; !ok {
+			continue
+		}
+		if eoj.ClassCode == 0x87 {
+			if board {
+				log.Printf("[機器構成] 分電盤メータリング (%02X%02X%02X) は 2 台目以降のため、監視対象にしません。", eoj.ClassGroupCode, eoj.ClassCode, eoj.InstanceCode)
+				continue
+			}
+			board = true
+		}
+		instances = append(instances, eoj)
+	}
+	if len(batteries) == 0 {
+		log.Printf("[機器構成] インスタンスリスト (%d 件) に蓄電池が含まれていないため、既定の監視対象を使用します。", len(eojs))
+		return
+	}
+	if err := config.ValidateBatteryInstances(batteries); err != nil {
+		log.Printf("[機器構成] インスタンスリストの蓄電池が不正なため、既定の監視対象を使用します: %v", err)
+		return
+	}
+	cfg.BatteryInstances = batteries
+	cfg.Instances = instances
+	log.Printf("[機器構成] インスタンスリストから監視対象を決めました: 蓄電池 %v, その他 %d 台", batteries, len(instances))
+}
@@ -0,0 +1,51 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestDiscoverInstances(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	device.Props[NodeProfileEOJ][0xD6] = []byte{
+		6,
+		0x02, 0x7D, 0x01,
+		0x02, 0x7D, 0x02,
+		0x02, 0x79, 0x02,
+		0x02, 0x87, 0x01,
+		0x02, 0x87, 0x02, // a second board is not polled
+		0x02, 0x6B, 0x01, // not a recognized class
+	}
+	client := NewEchonetClient(echonetlite.NewFakeTransport(device.Handle), "192.168.0.10", time.Second)
+	cfg := &config.Config{BatteryInstances: []int{1}, DiscoverTargets: true}
+
+	DiscoverInstances(client, cfg)
+
+	if len(cfg.BatteryInstances) != 2 || cfg.BatteryInstances[1] != 2 {
+		t.Errorf("BatteryInstances = %v, want [1 2]", cfg.BatteryInstances)
+	}
+	var names []string
+	for _, target := range defaultMonitoringTargets(nil, cfg.Instances) {
+		names = append(names, target.ObjectName)
+	}
+	if len(names) != 2 || names[0] != "住宅用太陽光発電 (027902)" || names[1] != "分電盤メータリング (028701)" {
+		t.Errorf("targets = %v, want PV 027902 and board 028701", names)
+	}
+}
+
+func TestDiscoverInstancesKeepsDefaultsWithoutBattery(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	device.Props[NodeProfileEOJ][0xD6] = []byte{1, 0x02, 0x79, 0x01}
+	client := NewEchonetClient(echonetlite.NewFakeTransport(device.Handle), "192.168.0.10", time.Second)
+	cfg := &config.Config{BatteryInstances: []int{1}, DiscoverTargets: true}
+
+	DiscoverInstances(client, cfg)
+
+	if len(cfg.BatteryInstances) != 1 || cfg.Instances != nil {
+		t.Errorf("BatteryInstances = %v, instances = %v, want the defaults", cfg.BatteryInstances, cfg.Instances)
+	}
+}
@@ -0,0 +1,789 @@
+// Package monitor は、蓄電池コントローラーの監視サイクル (機器からのデータ取得・計算・制御) を提供します。
+//
+// New で作成した Monitor の RunCycle を監視間隔ごとに呼び出します。手動操作や通知、測定値の保存先などとの接続は
+// cmd/eibs7-controller が行います。
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/controller"
+)
+
+// defaultMonitoringTargets は、監視対象の ECHONET Lite オブジェクトと取得するプロパティの一覧を返します。
+// 蓄電池は batteries の台数分を、蓄電池以外は instances の機器のうち targetClasses のクラスの機器を監視します。
+func defaultMonitoringTargets(batteries []*batteryUnit, instances []echonetlite.EOJ) []MonitoringTarget {
+	// README_prototype.md および以前の指示に基づく
+	var targets []MonitoringTarget
+	for _, u := range batteries {
+		targets = append(targets, MonitoringTarget{
+			EOJ:        u.eoj,                                            // 蓄電池
+			EPCs:       []byte{0xE4, 0xDA, 0xEB, 0xD3, 0xA0, 0xCF, 0xD0}, // 蓄電残量3, 運転モード, 充電電力設定値, 瞬時充放電電力, AC実効容量, 運転動作状態, 系統連系状態
+			SlowEPCs:   []byte{0xA0},                                     // AC実効容量
+			ObjectName: u.name,
+			role:       config.RoleBattery,
+			client:     u.client,
+		})
+	}
+	for _, eoj := range instances {
+		class, ok := targetClasses[eoj.ClassCode]
+		if !ok || eoj.ClassGroupCode != 0x02 {
+			continue
+		}
+		targets = append(targets, MonitoringTarget{
+			EOJ:        eoj,
+			EPCs:       class.epcs,
+			ObjectName: fmt.Sprintf("%s (%02X%02X%02X)", class.name, eoj.ClassGroupCode, eoj.ClassCode, eoj.InstanceCode),
+			role:       class.role,
+		})
+	}
+	return targets
+}
+
+// Monitor は、監視サイクル (データ取得・計算・制御) を実行し、サイクル間で引き継ぐ状態を保持します。
+type Monitor struct {
+	cfg     *config.Config
+	client  *EchonetClient
+	devices []*EchonetClient // targets の機器のクライアント
+	targets []MonitoringTarget
+
+	watchdog   *reachabilityWatchdog
+	resolver   *targetResolver // 識別番号で対象機器を指定した場合のみ
+	evening    *eveningReserve
+	storm      *stormAlert
+	openADR    *openADRVEN
+	planner    *chargePlanner
+	prices     *priceSchedule
+	controller *controller.Controller
+	smoother   *surplusSmoother
+	gridBudget *gridChargeBudget
+	Override   *ManualOverride // 手動操作。ソケットや HTTP API などのコマンドと共有する
+
+	audit  *DecisionAudit  // 制御の判断の監査ログ
+	alerts *WebhookAlerter // 異常や制御上の出来事の通知
+	health *ServiceHealth  // 死活確認と準備完了の確認に使用する状態
+
+	monitoringCSV *monitoringCSV // log_monitoring_data と monitoring_csv_dir を設定した場合のみ
+
+	lastChargePowerIncreaseTime time.Time
+	surplusPowerHistory         []int32
+	minSurplusPower             int32
+	batteries                   []*batteryUnit // 制御する蓄電池
+	lastDischargePower          int            // 最後に設定した放電電力設定値 (W)。未設定の場合は -1
+	batteryEdge                 batteryEdge    // 前回の監視サイクルの満充電・空の状態
+	chargeStoppedUntil          time.Time      // 目標の蓄電残量に達して充電を終えた充電時間帯の終了時刻
+	evPaused                    bool           // 蓄電池の充電を優先するため EV の充電を一時停止している
+	meter                       smartMeter     // スマートメーターの当日の買電・売電電力量
+	diverting                   []bool         // surplus_diversion.loads の各機器に余剰電力を振り向けている
+	trimmed                     map[int]uint8  // 買電制限のため設定を緩和したエアコンの元の温度設定値 (インスタンスコードごと)
+	conflictUntil               time.Time      // 他のコントローラーとの競合を検出して自動制御を控える期限
+	outageSince                 time.Time      // 停電を検出した時刻。停電中でない場合はゼロ値
+	gridRestoredAt              time.Time      // 停電中に系統の復旧を検出した時刻。自動制御の再開を待っていない場合はゼロ値
+	faults                      string         // 異常の発生を通知している監視対象と異常内容。異常がない場合は空
+	energy                      energyCounters // 積算電力量から求めた当日の値 (monitoringData のキーごと)
+	nextDailySummary            time.Time      // 次に蓄電池のまとめを LINE に送信する時刻。最初の監視サイクルの前はゼロ値
+	slowProperties              *propertyCache // 変化の少ないプロパティの取得値
+}
+
+// Options は、Monitor が cmd/eibs7-controller などの呼び出し側と共有するオブジェクトです。nil の項目は無効 (何もしない) として扱います。
+type Options struct {
+	Audit  *DecisionAudit  // 制御の判断の監査ログ。AddStore で登録した保存先にも監視サイクルの記録を渡す
+	Alerts *WebhookAlerter // 異常や制御上の出来事の通知
+	Health *ServiceHealth  // 監視ループと設定の読み込み直しが更新する状態 (/healthz と /readyz)
+}
+
+// New は、設定と ECHONET Lite クライアント、呼び出し側と共有するオブジェクトを指定して Monitor を作成します。
+func New(cfg *config.Config, client *EchonetClient, opts Options) *Monitor {
+	if opts.Audit == nil {
+		opts.Audit = &DecisionAudit{}
+	}
+	if opts.Alerts == nil {
+		opts.Alerts = &WebhookAlerter{}
+	}
+	if opts.Health == nil {
+		opts.Health = &ServiceHealth{}
+	}
+	// 応答の待機期限や重複判定も、監視サイクルと同じ時計で判定する (Peer は作成時に時計と監査ログ、通知を引き継ぐ)
+	client.Clock = cfg.ControllerClock()
+	client.audit, client.alerts = opts.Audit, opts.Alerts
+	forecaster := newPVForecaster(cfg.Forecast)
+	var (
+		batteries []*batteryUnit
+		targets   []MonitoringTarget
+		devices   []*EchonetClient
+	)
+	if len(cfg.Devices) > 0 {
+		batteries, targets, devices = newConfiguredDevices(cfg.Devices, client)
+	} else {
+		batteries = newBatteryUnits(cfg.BatteryInstances)
+		client.batteries = nil
+		for _, u := range batteries {
+			client.batteries = append(client.batteries, u.eoj)
+		}
+		instances := cfg.Instances
+		if instances == nil {
+			instances = defaultInstances
+		}
+		targets = defaultMonitoringTargets(batteries, instances)
+		for _, t := range cfg.Targets {
+			c, units, ts := newDevice(t, client)
+			devices = append(devices, c)
+			batteries = append(batteries, units...)
+			targets = append(targets, ts...)
+		}
+	}
+	if cfg.EVCharger.Enabled {
+		targets = append(targets, evChargerTarget(cfg.EVCharger))
+	}
+	if cfg.SmartMeter.Enabled {
+		target := smartMeterTarget(cfg.SmartMeter)
+		if cfg.SmartMeter.IP != "" && cfg.SmartMeter.IP != cfg.TargetIP {
+			target.client = client.Peer(cfg.SmartMeter.IP)
+			target.client.batteries = nil
+		}
+		targets = append(targets, target)
+	}
+	if cfg.DemandResponse.Enabled {
+		for _, instance := range cfg.DemandResponse.AirConditioners {
+			targets = append(targets, airConditionerTarget(instance))
+		}
+	}
+	if cfg.SurplusDiversion.Enabled {
+		for _, l := range cfg.SurplusDiversion.Loads {
+			targets = append(targets, diversionLoadTarget(l))
+		}
+	}
+	targets = applyEPCOverrides(targets, cfg.EPCOverrides, cfg.TargetIP)
+	if cfg.EnergyCounters {
+		targets = withEnergyCounters(targets)
+	}
+	targets = withFaultStatus(targets)
+	m := &Monitor{
+		cfg:                cfg,
+		client:             client,
+		lastDischargePower: -1,
+		slowProperties:     newPropertyCache(),
+		Override:           NewManualOverride(cfg.Loc()),
+		audit:              opts.Audit,
+		alerts:             opts.Alerts,
+		health:             opts.Health,
+		batteries:          batteries,
+		devices:            devices,
+		targets:            targets,
+		diverting:          make([]bool, len(cfg.SurplusDiversion.Loads)),
+		trimmed:            make(map[int]uint8),
+		energy:             make(energyCounters),
+		watchdog:           newReachabilityWatchdog(time.Duration(cfg.LivenessCheckIntervalSeconds)*time.Second, cfg.UnreachableFailureThreshold, opts.Alerts),
+		evening:            newEveningReserve(cfg.EveningReserve, forecaster),
+		planner:            newChargePlanner(cfg.ChargePlanning, forecaster),
+		prices:             newPriceSchedule(cfg.PriceSchedule, cfg.Loc()),
+		smoother:           newSurplusSmoother(cfg.SurplusSmoothingSamples, cfg.SurplusSmoothingAlpha),
+		controller:         controller.New(cfg.ControllerConfig(), cfg.ControllerClock()),
+		storm:              newStormAlert(cfg.StormAlert, opts.Alerts),
+		openADR:            newOpenADRVEN(cfg.OpenADR),
+		gridBudget:         newGridChargeBudget(cfg.MaxDailyGridChargeWh, cfg.GridChargeRolloverHour, 2*time.Duration(cfg.MonitorIntervalSeconds)*time.Second),
+	}
+	m.Override.clock = cfg.ControllerClock()
+	if cfg.TargetID != "" {
+		m.resolver = newTargetResolver(cfg.TargetID, time.Duration(cfg.LivenessCheckIntervalSeconds)*time.Second, client.Timeout, client.DiscoverNodes)
+	}
+	if cfg.LogMonitoringData && cfg.MonitoringCSVDir != "" {
+		m.monitoringCSV = newMonitoringCSV(cfg.MonitoringCSVDir)
+	}
+	return m
+}
+
+// runCycle は、監視サイクルを1回実行します。
+func (m *Monitor) runCycle() {
+	var surplusPower int32         // 余剰電力をサイクルのスコープで定義
+	var smoothedSurplusPower int32 // 運転モードの判定に使用する平滑化した余剰電力
+	var householdLoad int32        // 自家消費電力 (放電電力の制限に使用)
+	haveLoad := false
+
+	log.Println("--------------------------------------------------")
+	log.Println("監視サイクル開始")
+
+	cycleStart := m.cfg.Now()
+	m.audit.begin(cycleStart)
+	defer m.audit.end()
+
+	// 識別番号で指定された機器のアドレスが未確定、または機器に到達できない場合は探索して再解決する
+	if m.resolver != nil && (m.client.TargetIP == "" || m.watchdog.unreachable) {
+		if ip, ok := m.resolver.resolve(cycleStart); ok && ip != m.client.TargetIP {
+			log.Printf("[探索] 対象機器のアドレスを更新しました: '%s' -> '%s'", m.client.TargetIP, ip)
+			m.client.TargetIP = ip
+			m.watchdog.lastCheck = time.Time{} // 新しいアドレスで直ちに死活確認を行う
+		}
+	}
+	if m.client.TargetIP == "" {
+		log.Println("対象機器のアドレスが確定していないため、監視サイクルをスキップします。")
+		m.audit.decide(AuditSkipped, "対象機器のアドレスが確定していません")
+		m.health.Observe(cycleStart, false, false)
+		return
+	}
+
+	// 機器の死活確認 (監視サイクルとは別の間隔で実施)
+	if m.watchdog.due(cycleStart) {
+		m.watchdog.record(cycleStart, m.client.checkDeviceLiveness())
+	}
+	log.Printf("[死活監視] 状態: %s", m.watchdog.status())
+	log.Printf("[通知] 重複抑制した通知の累計: %d 件", m.client.notifications.suppressedCount())
+
+	chargeTimes := m.cfg.ChargeTimes(cycleStart)
+	if percent, ok := m.Override.TargetSOCPercent(cycleStart); ok {
+		log.Printf("[手動操作] 充電時間帯の目標の蓄電残量を %d%% に変更しています (設定: %d%%)。", percent, chargeTimes.TargetSOCPercent)
+		chargeTimes.TargetSOCPercent = percent
+	}
+	isChargingTimePeriod, err := chargeTimes.Contains(cycleStart)
+	if err != nil {
+		log.Printf("充電時間帯の判定に失敗しました: %v", err)
+	} else {
+		log.Printf("現在、充電時間帯です: %t (充電時間帯: %s)", isChargingTimePeriod, chargeTimes.TimeSlot)
+	}
+	m.audit.input("charging_window", isChargingTimePeriod)
+	m.audit.input("charge_window", chargeTimes.TimeSlot.String())
+	m.audit.input("charge_target_soc_percent", chargeTimes.TargetSOCPercent)
+
+	// --- 各監視対象からデータを取得 ---
+	monitoringData := m.pollTargets()
+	m.health.Observe(cycleStart, !m.watchdog.unreachable, len(monitoringData) > 0)
+	currentOperationMode := m.aggregateBatteries(monitoringData)
+	m.detectConflict(cycleStart)
+	m.checkWorkingStatus(cycleStart)
+	m.aggregateGeneration(monitoringData)
+	m.updateEnergyCounters(cycleStart, monitoringData)
+	m.sendDailySummary(cycleStart, monitoringData)
+	m.selectGridPower(monitoringData)
+	evAvailablePower := m.updateEVCharger(monitoringData, chargeTimes, isChargingTimePeriod)
+	m.audit.input("operation_mode", fmt.Sprintf("0x%X", currentOperationMode))
+	if soc, ok := monitoringData["蓄電池.蓄電残量3"].(uint8); ok {
+		m.audit.input("soc_percent", soc)
+	}
+
+	// --- 計算値の算出 ---
+	// 型アサーションで各値を取得
+	gridPower, gOK := monitoringData["系統.瞬時電力計測値"].(int32)
+	pcsPower, pOK := monitoringData["マルチ入力PCS.瞬時電力計測値"].(int32)
+	pvPower, pvOK := monitoringData["住宅用太陽光発電.瞬時発電電力計測値"].(int32)
+
+	if gOK && pOK && pvOK {
+		// 自家消費電力 = 買電電力 (スマートメーターまたは分電盤メータリング) - マルチ入力PCS.瞬時電力計測値
+		selfConsumption := gridPower - pcsPower
+		householdLoad, haveLoad = selfConsumption, true
+		// 余剰電力 = 太陽光発電.瞬時発電電力計測値 - 自家消費電力
+		surplusPower = pvPower - selfConsumption
+		// 一時停止する EV の充電電力は、蓄電池の充電に使用できる
+		surplusPower += evAvailablePower
+
+		// 最小余剰電力計算のために履歴に追加
+		maxHistoryCount := m.cfg.MinSurplusPowerJudgmentMinutes * 60 / m.cfg.MonitorIntervalSeconds
+		m.surplusPowerHistory = append(m.surplusPowerHistory, surplusPower)
+		if len(m.surplusPowerHistory) > maxHistoryCount {
+			m.surplusPowerHistory = m.surplusPowerHistory[1:]
+		}
+
+		// 最小余剰電力の計算
+		// m.surplusPowerHistory が空でなければ、その中の最小値を m.minSurplusPower とする
+		if len(m.surplusPowerHistory) > 0 {
+			m.minSurplusPower = m.surplusPowerHistory[0] // 最初の要素で初期化
+			for _, v := range m.surplusPowerHistory {
+				if v < m.minSurplusPower {
+					m.minSurplusPower = v
+				}
+			}
+		} else {
+			m.minSurplusPower = 0 // 履歴が空の場合は0など適切な初期値
+		}
+
+		smoothedSurplusPower = m.smoother.add(surplusPower)
+
+		log.Printf("[計算値] 自家消費電力: %d W, 余剰電力: %d W (平滑化: %d W), 最小余剰電力: %d W", selfConsumption, surplusPower, smoothedSurplusPower, m.minSurplusPower)
+		m.audit.input("grid_watts", gridPower)
+		m.audit.input("pv_watts", pvPower)
+		m.audit.input("household_load_watts", selfConsumption)
+		m.audit.input("surplus_watts", surplusPower)
+		m.audit.input("smoothed_surplus_watts", smoothedSurplusPower)
+		m.audit.input("min_surplus_watts", m.minSurplusPower)
+
+		// 系統からの充電電力量を積算
+		if batteryPower, ok := monitoringData["蓄電池.瞬時充放電電力計測値"].(int32); ok {
+			m.gridBudget.add(m.cfg.Now(), gridChargePower(batteryPower, surplusPower))
+			if m.cfg.MaxDailyGridChargeWh > 0 {
+				log.Printf("[計算値] 本日の系統からの充電電力量: %.1f Wh (上限: %.1f Wh)", m.gridBudget.usedWh, m.gridBudget.limitWh)
+				m.audit.input("grid_budget_exhausted", m.gridBudget.exhausted())
+			}
+		}
+	} else {
+		log.Println("[計算値] 計算に必要なデータが不足しているため、計算をスキップしました。")
+	}
+	computed := map[string]interface{}{}
+	if haveLoad {
+		computed[csvColumnSelfConsumption], computed[csvColumnSurplus] = householdLoad, surplusPower
+	}
+	m.writeMonitoringCSV(cycleStart, monitoringData, computed)
+	m.audit.measurements(monitoringData, computed)
+
+	m.updateChargeETA(m.cfg.Now(), monitoringData, chargeTimes, isChargingTimePeriod)
+
+	// 停電: 自立運転中は outage.policy に従って蓄電池を制御し、買電制限や手動操作を含む通常の制御を停止する
+	if m.detectOutage(cycleStart, monitoringData) {
+		m.audit.decide(AuditOutage, "停電時の動作: "+m.cfg.Outage.Policy)
+		m.controlOutage(monitoringData)
+		log.Println("監視サイクル終了 (全ターゲット処理完了)")
+		return
+	}
+	// 機器の異常: 異常の発生を通知している機器に設定を送り続けないよう、異常が解消するまですべての制御を停止する
+	if faults := m.detectFaults(monitoringData); len(faults) > 0 {
+		m.audit.decide(AuditDeviceFault, fmt.Sprintf("異常を通知している機器: %d 台", len(faults)))
+		m.controlDeviceFault()
+		log.Println("監視サイクル終了 (全ターゲット処理完了)")
+		return
+	}
+
+	// 余剰電力の振り向け: 蓄電池で吸収しきれない余剰電力を売電せず電気給湯機の沸き上げに使用する
+	// 「自動」モードで売電している場合は、蓄電池が余剰電力を上限まで充電しているとみなす
+	if gOK && m.cfg.SurplusDiversion.Enabled {
+		soc, sOK := monitoringData["蓄電池.蓄電残量3"].(uint8)
+		setting, cOK := monitoringData["蓄電池.充電電力設定値"].(uint32)
+		maxChargePower, _ := m.cfg.ChargePowerLimits(cycleStart)
+		full := sOK && batteryEdgeOf(m.cfg, soc) == batteryFull
+		capped := currentOperationMode == 0x46 || (currentOperationMode == 0x42 && cOK && int(setting) >= maxChargePower)
+		m.divertSurplus(monitoringData, gridPower, full, capped)
+	}
+
+	// OpenADR: 手動操作などで制御方式を使用しない間も、イベントの受信と応答を続ける
+	m.openADR.poll(cycleStart)
+
+	// --- 制御ロジック ---
+	// 買電制限の二次対策: 充電電力を下げる余地がなくなっても上限を超えている場合は、エアコンの設定を緩和する
+	if gOK {
+		m.respondToDemand(monitoringData, gridPower, currentOperationMode)
+	}
+	// 買電制限: 買電電力が上限を超えている場合は、時間帯や手動操作にかかわらず直ちに充電電力を下げる
+	if gOK && m.enforceImportLimit(monitoringData, gridPower, currentOperationMode) {
+		m.audit.decide(auditImportLimit, "買電電力が上限を超えています")
+		log.Println("監視サイクル終了 (全ターゲット処理完了)")
+		return
+	}
+
+	// 手動操作: 期限までは嵐警戒モードを含むすべての自動制御より優先する (買電制限を除く)
+	if kind, until := m.Override.Current(cycleStart); kind != OverrideNone {
+		m.audit.decide(auditOverride, fmt.Sprintf("%s (%s まで)", kind, until.Format("15:04")))
+		m.controlManualOverride(kind, until, monitoringData)
+		log.Println("監視サイクル終了 (全ターゲット処理完了)")
+		return
+	}
+
+	// 他のコントローラーとの競合: 運転モードを奪い合わないよう、期限までは自動制御を行わない (買電制限と手動操作を除く)
+	if m.backingOff(cycleStart) {
+		log.Printf("[競合] 他のコントローラーとの競合を検出したため、%s まで自動制御を控えます。", m.conflictUntil.Format("15:04:05"))
+		m.audit.decide(auditConflict, "自動制御を控える期限: "+m.conflictUntil.Format("15:04:05"))
+		log.Println("監視サイクル終了 (全ターゲット処理完了)")
+		return
+	}
+
+	// 嵐警戒モード: 警報の発表中は時間帯の設定にかかわらず、最大充電電力で満充電を目指す
+	if m.storm.active(cycleStart) {
+		m.audit.decide(AuditStorm, "嵐警戒モード")
+		m.controlStormCharge()
+		log.Println("監視サイクル終了 (全ターゲット処理完了)")
+		return
+	}
+
+	// 停電に備えて、蓄電残量が最低リザーブ以下の場合は放電する可能性のある自動モードにしない
+	belowReserve := false
+	if m.cfg.ReserveSOCPercent > 0 {
+		if soc, ok := monitoringData["蓄電池.蓄電残量3"].(uint8); ok && int(soc) <= m.cfg.ReserveSOCPercent {
+			log.Printf("[制御] 蓄電残量 (%d%%) が停電用の最低リザーブ (%d%%) 以下です。放電を行わないよう制御します。", soc, m.cfg.ReserveSOCPercent)
+			belowReserve = true
+		}
+	}
+
+	inPeakShaving := false
+	if m.cfg.PeakShaving.Enabled {
+		if inPeakShaving, err = m.cfg.PeakShaving.Contains(cycleStart); err != nil {
+			log.Printf("[制御] ピークカットの時間帯の判定に失敗しました: %v", err)
+		}
+	}
+	// 運転プロファイル: 曜日・期間・時間帯に応じて閾値などを切り替える
+	if profile, _ := m.cfg.ActiveProfile(cycleStart); profile != "" {
+		log.Printf("[制御] 運転プロファイル: %s", profile)
+	}
+	chargeThreshold, autoThreshold := m.cfg.ModeThresholds(cycleStart)
+	m.controller.SetThresholds(chargeThreshold, autoThreshold)
+	maxChargePower, surplusMargin := m.cfg.ChargePowerLimits(cycleStart)
+	m.audit.threshold("charge_mode_watts", chargeThreshold)
+	m.audit.threshold("auto_mode_watts", autoThreshold)
+	m.audit.threshold("max_charge_power_watts", maxChargePower)
+	m.audit.threshold("surplus_margin_watts", surplusMargin)
+	m.audit.threshold("reserve_soc_percent", m.cfg.ReserveSOCPercent)
+	from, state := m.controller.Step(controller.Input{
+		Reachable:           !m.watchdog.unreachable,
+		InChargingWindow:    isChargingTimePeriod,
+		InPeakShavingWindow: inPeakShaving,
+		SurplusWatts:        int(smoothedSurplusPower),
+	})
+	if from != state {
+		log.Printf("[制御] 制御状態が遷移しました: %s -> %s", from, state)
+	}
+
+	if state == controller.Fault {
+		log.Println("[制御] 対象機器に到達できないため、制御をスキップします。")
+		m.audit.decide(AuditUnreachable, "対象機器に到達できません")
+		return
+	}
+
+	// 制御方式: 時間帯ごとに設定された制御方式で運転モードや充放電電力を決める
+	name, reason := m.cfg.StrategyName(cycleStart)
+	s := m.newStrategy(name)
+	// OpenADR のイベントの期間中は、レベルに応じて充電電力を制限するか放電する
+	if signal, ok := m.openADR.current(cycleStart); ok {
+		s = openADRStrategy{m: m, base: s, signal: signal}
+		reason = fmt.Sprintf("OpenADR のイベント '%s' (レベル %d)", signal.eventID, signal.level)
+		if !signal.end.IsZero() {
+			reason += fmt.Sprintf("、%s まで", signal.end.In(cycleStart.Location()).Format("15:04"))
+		}
+	}
+	log.Printf("[制御] 制御方式: %s (%s)", s.name(), reason)
+	ms := measurements{
+		now:           m.cfg.Now(),
+		data:          monitoringData,
+		operationMode: currentOperationMode,
+		householdLoad: householdLoad,
+		haveLoad:      haveLoad,
+		belowReserve:  belowReserve,
+		chargeTimes:   chargeTimes,
+	}
+	actions := m.suppressAtBatteryEdge(s.decide(ms, state), ms)
+	m.audit.strategy(s.name(), reason, state.String(), actions)
+	m.applyActions(actions, ms)
+
+	log.Println("監視サイクル終了 (全ターゲット処理完了)")
+}
+
+// chargePowerTarget は、充電時間帯の目標の蓄電残量と残り時間から目標充電電力 (W) を計算します。
+// 計算に必要なデータがない場合や充電時間帯の残り時間がない場合は ok = false を返します。
+func (m *Monitor) chargePowerTarget(monitoringData map[string]interface{}, chargeTimes config.ChargeWindow) (target int, ok bool) {
+	// 必要なデータがmonitoringDataにあるか確認
+	now := m.cfg.Now()
+	acCapacity, acOK := monitoringData["蓄電池.AC実効容量（充電）"].(uint32)
+	batteryRemaining, brOK := monitoringData["蓄電池.蓄電残量3"].(uint8)
+	if !acOK || !brOK {
+		log.Println("[制御] 充電電力計算に必要なデータが不足しているため、計算をスキップしました。")
+		return 0, false
+	}
+
+	// 充電時間帯の後の発電で賄える分は充電しないよう、目標の蓄電残量を調整する
+	if planned := m.planner.targetSOCPercent(now, chargeTimes, acCapacity); planned != chargeTimes.TargetSOCPercent {
+		log.Printf("[充電計画] 発電量予測に基づき、目標の蓄電残量を %d%% から %d%% に下げます。", chargeTimes.TargetSOCPercent, planned)
+		chargeTimes.TargetSOCPercent = planned
+	}
+
+	// 目標充電量 (Wh): 充電時間帯の目標の蓄電残量までに必要な充電量
+	targetChargeAmount := chargeTimes.TargetChargeWh(acCapacity, batteryRemaining)
+	if targetChargeAmount == 0 {
+		log.Printf("[制御] 蓄電残量 (%d%%) が目標 (%d%%) に達しています。", batteryRemaining, chargeTimes.TargetSOCPercent)
+	}
+
+	// 残り時間 (分) の計算 (日付をまたぐ充電時間帯にも対応するため、次の終了時刻までの時間とする)
+	chargeEnd := chargeTimes.End(now)
+	remainingMinutes := chargeEnd.Sub(now).Minutes()
+	if remainingMinutes <= 0 {
+		log.Println("[制御] 充電終了時刻を過ぎているか、残り時間が0以下です。充電電力計算をスキップします。")
+		return 0, false
+	}
+
+	// 目標充電電力 (W)
+	targetChargePower := int(targetChargeAmount * 60 / remainingMinutes)
+
+	// 最大充電電力と余剰電力の余力 (期間ごとの設定があればそれを使用する)
+	maxChargePower, surplusMargin := m.cfg.ChargePowerLimits(now)
+	if maxChargePower != m.cfg.MaxChargePowerWatts || surplusMargin != m.cfg.SurplusPowerMarginWatts {
+		log.Printf("[制御] 期間ごとの設定を適用します (最大充電電力: %d W, 余剰電力余力: %d W)", maxChargePower, surplusMargin)
+	}
+
+	// 単価表がある場合は、必要な充電量を最大充電電力で充電できるだけの安いコマを選び、
+	// そのコマでは系統から最大充電電力で充電し、それ以外のコマでは充電しない
+	blockWh := float64(maxChargePower) * priceBlockDuration.Hours()
+	neededBlocks := int(math.Ceil(targetChargeAmount / blockWh))
+	if cheap, ok := m.prices.cheapBlock(now, chargeEnd, neededBlocks); ok {
+		if cheap {
+			log.Printf("[単価] 現在のコマは残り時間のうち安い %d コマに含まれるため、最大充電電力で充電します。", neededBlocks)
+			targetChargePower = maxChargePower
+		} else {
+			log.Printf("[単価] 現在のコマは残り時間のうち安い %d コマに含まれないため、充電を見合わせます。", neededBlocks)
+			targetChargePower = 0
+		}
+	} else {
+		// 上限値の計算
+		// 最小余剰電力(W)-余剰電力余力(W) と 最大充電電力(W) の小さい方を上限とする
+		powerCap := int32(maxChargePower)
+		if m.minSurplusPower-int32(surplusMargin) < powerCap {
+			powerCap = m.minSurplusPower - int32(surplusMargin)
+		}
+		if powerCap < 0 {
+			powerCap = 0
+		}
+
+		// 上限値を適用
+		if targetChargePower > int(powerCap) {
+			targetChargePower = int(powerCap)
+		}
+	}
+
+	// 系統からの充電電力量が上限に達した場合は、余剰電力で賄える分だけ充電する
+	if m.gridBudget.exhausted() {
+		surplusCap := int(m.minSurplusPower) - surplusMargin
+		if surplusCap < 0 {
+			surplusCap = 0
+		}
+		if targetChargePower > surplusCap {
+			log.Printf("[制御] 本日の系統からの充電電力量が上限 (%.0f Wh) に達したため、充電電力を余剰電力の範囲 (%d W) に制限します。", m.cfg.MaxDailyGridChargeWh, surplusCap)
+			targetChargePower = surplusCap
+		}
+	}
+
+	log.Printf("[制御] 目標充電電力: %d W (目標充電量: %.2f Wh, 残り時間: %.2f 分)", targetChargePower, targetChargeAmount, remainingMinutes)
+	return targetChargePower, true
+}
+
+// applyChargePower は、充電電力設定値を目標充電電力 (W) に近づけます。
+// 目標へは段階的に近づけ、引き上げは前回の引き上げから charge_power_update_interval_minutes が経過するまで行いません。
+func (m *Monitor) applyChargePower(targetChargePower int, monitoringData map[string]interface{}) {
+	// 現在の充電電力設定値を取得
+	currentChargePower, cok := monitoringData["蓄電池.充電電力設定値"].(uint32)
+	if !cok {
+		log.Println("[制御] 現在の充電電力設定値が取得できなかったため、充電電力の設定をスキップします。")
+		return
+	}
+
+	// 目標へ段階的に近づける (ランプ制御・比例制御)
+	if stepped := rampChargePower(int(currentChargePower), targetChargePower, m.cfg.ChargePowerRampWatts, m.cfg.ChargePowerGain); stepped != targetChargePower {
+		log.Printf("[制御] 充電電力を段階的に変更します: %d W -> %d W (目標: %d W)", currentChargePower, stepped, targetChargePower)
+		targetChargePower = stepped
+	}
+
+	if targetChargePower > int(currentChargePower) {
+		// 引き上げの場合
+		if m.cfg.Now().Sub(m.lastChargePowerIncreaseTime) < time.Duration(m.cfg.ChargePowerUpdateIntervalMinutes)*time.Minute {
+			log.Printf("[制御] 充電電力の引き上げは、前回の引き上げから%d分経過するまで行えません（残り: %s）。", m.cfg.ChargePowerUpdateIntervalMinutes, (time.Duration(m.cfg.ChargePowerUpdateIntervalMinutes)*time.Minute - m.cfg.Now().Sub(m.lastChargePowerIncreaseTime)).Truncate(time.Second))
+		} else {
+			err := m.setChargePower(targetChargePower)
+			if err != nil {
+				log.Printf("[制御] 蓄電池の充電電力設定に失敗しました: %v", err)
+			} else {
+				m.lastChargePowerIncreaseTime = m.cfg.Now()
+			}
+		}
+	} else if targetChargePower < int(currentChargePower) {
+		// 引き下げの場合
+		err := m.setChargePower(targetChargePower)
+		if err != nil {
+			log.Printf("[制御] 蓄電池の充電電力設定に失敗しました: %v", err)
+		}
+	} else {
+		log.Println("[制御] 目標充電電力と現在の設定値が同じため、設定変更は行いません。")
+	}
+}
+
+// updateDischargePower は、放電電力設定値を power (W) に更新します。
+// 前回の設定値との差が stepWatts 未満の場合は更新しません。更新した場合は true を返します。
+func (m *Monitor) updateDischargePower(power, stepWatts int) bool {
+	if m.lastDischargePower >= 0 {
+		diff := power - m.lastDischargePower
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff < stepWatts {
+			m.client.debugf("[制御] 放電電力設定値の変更量が小さいため更新しません (現在: %d W, 目標: %d W)", m.lastDischargePower, power)
+			return false
+		}
+	}
+	if err := m.setDischargePower(power); err != nil {
+		log.Printf("[制御] 蓄電池の放電電力設定に失敗しました: %v", err)
+		return false
+	}
+	m.lastDischargePower = power
+	return true
+}
+
+// controlStormCharge は、嵐警戒モード中の制御を行います。
+// 停電に備えて、運転モードを「充電」にして最大充電電力で充電します。モード変更の抑制時間や系統からの充電量の上限は適用しません。
+func (m *Monitor) controlStormCharge() {
+	log.Printf("[制御] 嵐警戒モードです (警報コード: %v)。最大充電電力 (%d W) で満充電まで充電します。", m.storm.warnings, m.cfg.MaxChargePowerWatts)
+	m.forceCharge(false)
+}
+
+// controlManualOverride は、手動操作が有効な間の制御を行います。
+// UNIX ドメインソケットからの手動操作にはモード変更の抑制時間を適用しません。
+// HTTP API からの手動操作には、自動制御と同じくモード変更の抑制時間を適用し、蓄電残量が最低リザーブ以下の場合は自動モードにしません。
+func (m *Monitor) controlManualOverride(kind OverrideKind, until time.Time, monitoringData map[string]interface{}) {
+	guarded := m.Override.IsGuarded()
+	switch kind {
+	case overridePause:
+		log.Printf("[手動操作] %s まで自動制御を停止しています。蓄電池の設定は変更しません。", until.Format("15:04:05"))
+	case OverrideCharge:
+		log.Printf("[手動操作] %s まで、最大充電電力 (%d W) で充電します。", until.Format("15:04:05"), m.cfg.MaxChargePowerWatts)
+		m.forceCharge(guarded)
+	case OverrideAuto:
+		if soc, ok := monitoringData["蓄電池.蓄電残量3"].(uint8); guarded && ok && m.cfg.ReserveSOCPercent > 0 && int(soc) <= m.cfg.ReserveSOCPercent {
+			log.Printf("[手動操作] 蓄電残量 (%d%%) が停電用の最低リザーブ (%d%%) 以下のため、自動モードにしません。", soc, m.cfg.ReserveSOCPercent)
+			return
+		}
+		log.Printf("[手動操作] %s まで自動モードにします。", until.Format("15:04:05"))
+		changed, err := m.setOperationMode(0x46, guarded) // 0x46: 自動モード
+		if err != nil {
+			log.Printf("[制御] 蓄電池の運転モード設定（自動）に失敗しました: %v", err)
+		}
+		m.modeChanged(changed)
+	}
+}
+
+// forceCharge は、運転モードを「充電」にして最大充電電力で充電します。
+// respectInhibit が true の場合、モード変更の抑制時間が経過していない蓄電池の運転モードは変更しません。
+func (m *Monitor) forceCharge(respectInhibit bool) {
+	changed, err := m.setOperationMode(0x42, respectInhibit) // 0x42: 充電モード
+	if err != nil {
+		log.Printf("[制御] 蓄電池の運転モード設定（充電）に失敗しました: %v", err)
+	}
+	m.modeChanged(changed)
+	if err := m.setChargePower(m.cfg.MaxChargePowerWatts); err != nil {
+		log.Printf("[制御] 蓄電池の充電電力設定に失敗しました: %v", err)
+	}
+}
+
+// cachedProperty は、通知駆動の監視モードで、再取得せずに使用できるプロパティの値を返します。
+// 通知で受信してから notification_max_age_seconds 以内の値、または変化の少ないプロパティで
+// 取得してから slow_poll_interval_seconds 以内の値を使用できます。
+// targets の機器の値は、通知や取得値の送信元を EOJ だけでは target_ip の機器と区別できないため、常に再取得します。
+func (m *Monitor) cachedProperty(target MonitoringTarget, epc byte) (cachedProperty, bool) {
+	if !m.cfg.NotificationMode || target.client != nil {
+		return cachedProperty{}, false
+	}
+	now := m.cfg.Now()
+	if v, ok := m.client.notified.get(target.EOJ, epc, time.Duration(m.cfg.NotificationMaxAgeSeconds)*time.Second, now); ok {
+		return v, true
+	}
+	if containsEPC(target.SlowEPCs, epc) {
+		return m.slowProperties.get(target.EOJ, epc, time.Duration(m.cfg.SlowPollIntervalSeconds)*time.Second, now)
+	}
+	return cachedProperty{}, false
+}
+
+// containsEPC は、epcs に epc が含まれていれば true を返します。
+func containsEPC(epcs []byte, epc byte) bool {
+	for _, e := range epcs {
+		if e == epc {
+			return true
+		}
+	}
+	return false
+}
+
+// pollTargets は、各監視対象のプロパティを取得し、デコードした値のマップを返します。
+func (m *Monitor) pollTargets() map[string]interface{} {
+	// 監視サイクルごとのデータを保持するマップ
+	monitoringData := make(map[string]interface{})
+	store := func(target MonitoringTarget, propName string, value interface{}) {
+		monitoringData[fmt.Sprintf("%s.%s", target.ObjectName, propName)] = value
+	}
+
+	// 応答がバッファに収まらない場合は要求を分割して再度キューに積むため、キューとして処理する
+	queue := append([]MonitoringTarget(nil), m.targets...)
+	for len(queue) > 0 {
+		target := queue[0]
+		queue = queue[1:]
+		tid := m.client.NextTID()
+		log.Printf("[%s] データ取得開始 (TID: %d)", target.ObjectName, tid)
+
+		var props []echonetlite.Property
+		for _, epc := range target.EPCs {
+			// 通知駆動の監視モードでは、通知で受信した新しい値や、取得間隔内の変化の少ない値は再取得しない
+			if cached, ok := m.cachedProperty(target, epc); ok {
+				m.client.debugf("[%s]   プロパティ: %s (EPC: 0x%X) はキャッシュの値を使用します: %v (取得時刻: %s)", target.ObjectName, cached.name, epc, cached.value, cached.at.Format("15:04:05"))
+				store(target, cached.name, cached.value)
+				continue
+			}
+			props = append(props, echonetlite.Property{EPC: epc, PDC: 0, EDT: nil})
+		}
+		if len(props) == 0 {
+			log.Printf("[%s] すべてのプロパティをキャッシュから取得しました。", target.ObjectName)
+			continue
+		}
+
+		getFrame := echonetlite.Frame{
+			EHD1:       echonetlite.EchonetLiteEHD1,
+			EHD2:       echonetlite.Format1,
+			TID:        tid,
+			SEOJ:       m.clientFor(target.client).SEOJ,
+			DEOJ:       target.EOJ,
+			ESV:        echonetlite.ESVGet,
+			OPC:        byte(len(props)),
+			Properties: props,
+		}
+
+		// --- フレームを送信し、応答を受信 ---
+		receivedData, sourceAddr, err := m.clientFor(target.client).SendAndReceive(getFrame)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				log.Printf("[%s] 処理がタイムアウトしました (TID: %d)", target.ObjectName, tid)
+			} else if errors.Is(err, errResponseTruncated) && len(target.EPCs) > 1 {
+				log.Printf("[%s] 応答が受信バッファに収まらないため、要求を分割して再取得します (TID: %d, EPC数: %d)", target.ObjectName, tid, len(target.EPCs))
+				queue = append(splitMonitoringTarget(target), queue...)
+			} else {
+				log.Printf("[%s] ECHONET Lite 通信中にエラーが発生しました (TID: %d): %v", target.ObjectName, tid, err)
+			}
+			continue // エラーが発生しても次のターゲットの処理へ
+		}
+
+		// --- 応答受信成功時の処理 ---
+		log.Printf("[%s] 正常に応答を受信しました (TID: %d, 送信元: %s, データ長: %d bytes)", target.ObjectName, tid, sourceAddr.String(), len(receivedData))
+
+		// 受信したバイト列 (receivedData) を echonetlite.Frame にデシリアライズする
+		var responseFrame echonetlite.Frame
+		err = responseFrame.UnmarshalBinary(receivedData)
+		if err != nil {
+			log.Printf("[%s] 受信データのデシリアライズに失敗しました (TID: %d): %v", target.ObjectName, tid, err)
+			continue // 次のターゲットへ
+		}
+
+		// ESV の確認
+		switch responseFrame.ESV {
+		case echonetlite.ESVGet_Res: // 0x72 - Property value read response
+			log.Printf("[%s] Get応答を受信しました (TID: %d, ESV: 0x%X)", target.ObjectName, responseFrame.TID, responseFrame.ESV)
+			if len(responseFrame.Properties) == 0 {
+				log.Printf("[%s] Get応答にプロパティが含まれていません (TID: %d)", target.ObjectName, responseFrame.TID)
+			}
+			for _, prop := range responseFrame.Properties {
+				decodedValue, propName, err := DecodeEDT(responseFrame.SEOJ, prop.EPC, prop.EDT)
+				if err != nil {
+					// デコードエラーが発生した場合でも、生データとエラー情報をログに出力
+					log.Printf("[%s]   プロパティ: %s (EPC: 0x%X), PDC: %d, EDT: %X (TID: %d) - デコードエラー: %v", target.ObjectName, propName, prop.EPC, prop.PDC, prop.EDT, responseFrame.TID, err)
+				} else if decodedValue == nil && prop.PDC == 0 { // PDC=0でEDTがnilの場合 (Get要求の正常な応答)
+					log.Printf("[%s]   プロパティ: %s (EPC: 0x%X), PDC: %d, EDT: (なし) (TID: %d)", target.ObjectName, propName, prop.EPC, prop.PDC, responseFrame.TID)
+				} else {
+					log.Printf("[%s]   プロパティ: %s (EPC: 0x%X), PDC: %d, EDT: %X, 値: %v (TID: %d)", target.ObjectName, propName, prop.EPC, prop.PDC, prop.EDT, decodedValue, responseFrame.TID)
+					// デコードした値をマップに保存
+					store(target, propName, decodedValue)
+					if containsEPC(target.SlowEPCs, prop.EPC) && target.client == nil {
+						m.slowProperties.store(target.EOJ, prop.EPC, propName, decodedValue, m.cfg.Now())
+					}
+				}
+			}
+		case echonetlite.ESVGet_SNA: // 0x52 - Property value read request error
+			log.Printf("[%s] Getエラー応答を受信しました (TID: %d, ESV: 0x%X)", target.ObjectName, responseFrame.TID, responseFrame.ESV)
+			// エラー応答の場合、Propertiesにエラーの原因を示す情報が含まれることがある (例: EPCが処理不可など)
+		default:
+			log.Printf("[%s] 予期しないESV (0x%X) を受信しました (TID: %d)", target.ObjectName, responseFrame.ESV, responseFrame.TID)
+		}
+	}
+
+	return monitoringData
+}
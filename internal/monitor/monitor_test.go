@@ -0,0 +1,358 @@
+package monitor
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+// newTestMonitor returns a monitor for the fake device with the charging window from start to end.
+func newTestMonitor(device *monitortest.FakeEIBS7, start, end time.Time, opts ...func(*config.Config)) *Monitor {
+	cfg := monitortest.NewConfig(start, end, opts...)
+	client := NewEchonetClient(echonetlite.NewFakeTransport(device.Handle), cfg.TargetIP, time.Second)
+	return New(cfg, client, Options{})
+}
+
+func TestMonitorSetsAutoModeOutsideChargingWindow(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	now := monitortest.Now
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour))
+
+	m.runCycle()
+
+	if len(device.Sets) != 1 {
+		t.Fatalf("expected 1 SetC, got %d", len(device.Sets))
+	}
+	p := device.Sets[0].Properties[0]
+	if p.EPC != 0xDA || len(p.EDT) != 1 || p.EDT[0] != 0x46 {
+		t.Errorf("SetC = EPC 0x%X EDT %X, want operation mode 0x46", p.EPC, p.EDT)
+	}
+}
+
+func TestMonitorCapsChargePowerBySurplus(t *testing.T) {
+	now := monitortest.Now
+	device := monitortest.NewFakeEIBS7()
+	m := newTestMonitor(device, now.Add(-time.Hour), now.Add(time.Hour))
+
+	m.runCycle()
+
+	// Surplus is 3000 W, so the cap is min(3000-500, 2000) = 2000 W.
+	if len(device.Sets) != 1 {
+		t.Fatalf("expected 1 SetC, got %d", len(device.Sets))
+	}
+	p := device.Sets[0].Properties[0]
+	if p.EPC != 0xEB || len(p.EDT) != 4 || binary.BigEndian.Uint32(p.EDT) != 2000 {
+		t.Errorf("SetC = EPC 0x%X EDT %X, want charge power 2000 W", p.EPC, p.EDT)
+	}
+}
+
+func TestMonitorReplaysRecordedSession(t *testing.T) {
+	f, err := os.Open("testdata/replay_outside_charging_window.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	exchanges, err := echonetlite.ReadRecording(f)
+	if err != nil {
+		t.Fatalf("ReadRecording failed: %v", err)
+	}
+	replayer := echonetlite.NewReplayer(exchanges)
+
+	now := monitortest.Now
+	m := newTestMonitor(monitortest.NewFakeEIBS7(), now.Add(2*time.Hour), now.Add(3*time.Hour))
+	m.client = NewEchonetClient(echonetlite.NewFakeTransport(replayer.Handle), m.cfg.TargetIP, time.Second)
+	m.runCycle()
+
+	// The replayed cycle must issue exactly the recorded requests, ending with the switch to auto mode.
+	if unmatched := replayer.Unmatched(); len(unmatched) != 0 {
+		t.Errorf("requests not in the recording: %X", unmatched[0].Data)
+	}
+	if n := replayer.Remaining(); n != 0 {
+		t.Errorf("%d recorded requests were not replayed", n)
+	}
+}
+
+func TestMonitorInhibitsDischargeOutsideDischargeWindow(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	now := monitortest.Now
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour))
+	m.cfg.DischargeTimes = config.TimeSlot{StartTime: now.Add(4 * time.Hour).Format("15:04"), EndTime: now.Add(5 * time.Hour).Format("15:04")}
+
+	m.runCycle()
+
+	if len(device.Sets) != 1 {
+		t.Fatalf("expected 1 SetC, got %d", len(device.Sets))
+	}
+	p := device.Sets[0].Properties[0]
+	if p.EPC != 0xDA || len(p.EDT) != 1 || p.EDT[0] != 0x44 {
+		t.Errorf("SetC = EPC 0x%X EDT %X, want operation mode 0x44", p.EPC, p.EDT)
+	}
+}
+
+func TestMonitorKeepsReserveSOC(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	now := monitortest.Now
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour))
+	m.cfg.ReserveSOCPercent = 50 // the fake battery reports 50%
+
+	m.runCycle()
+
+	// Outside the charging window the battery would normally go to auto mode, which may discharge.
+	if len(device.Sets) != 1 {
+		t.Fatalf("expected 1 SetC, got %d", len(device.Sets))
+	}
+	p := device.Sets[0].Properties[0]
+	if p.EPC != 0xDA || len(p.EDT) != 1 || p.EDT[0] != 0x44 {
+		t.Errorf("SetC = EPC 0x%X EDT %X, want operation mode 0x44", p.EPC, p.EDT)
+	}
+}
+
+func TestMonitorCapsDischargeToHouseholdLoad(t *testing.T) {
+	now := monitortest.Now
+	device := monitortest.NewFakeEIBS7()
+	device.Props[echonetlite.NewEOJ(0x02, 0x87, 0x01)][0xC6] = binary.BigEndian.AppendUint32(nil, 1500)
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour))
+	m.cfg.DischargeCap = config.DischargeCapConfig{
+		Enabled:     true,
+		TimeSlot:    config.TimeSlot{StartTime: now.Add(-time.Hour).Format("15:04"), EndTime: now.Add(time.Hour).Format("15:04")},
+		MarginWatts: 100,
+	}
+	if err := m.cfg.DischargeCap.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	m.runCycle()
+
+	if len(device.Sets) != 2 {
+		t.Fatalf("expected 2 SetC, got %d", len(device.Sets))
+	}
+	p := device.Sets[1].Properties[0]
+	if p.EPC != 0xEC || len(p.EDT) != 4 || binary.BigEndian.Uint32(p.EDT) != 1400 {
+		t.Errorf("SetC = EPC 0x%X EDT %X, want discharge power 1400 W", p.EPC, p.EDT)
+	}
+
+	// A small change in load does not rewrite the setting.
+	device.Props[echonetlite.NewEOJ(0x02, 0x87, 0x01)][0xC6] = binary.BigEndian.AppendUint32(nil, 1550)
+	m.runCycle()
+	if len(device.Sets) != 2 {
+		t.Errorf("discharge power rewritten for a small change (%d SetC)", len(device.Sets))
+	}
+}
+
+func TestMonitorUsesNotifiedProperties(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	var gets []byte
+	handler := func(data []byte, addr *net.UDPAddr) []echonetlite.Datagram {
+		var req echonetlite.Frame
+		if err := req.UnmarshalBinary(data); err == nil && req.ESV == echonetlite.ESVGet && req.DEOJ == echonetlite.NewEOJ(0x02, 0x7D, 0x01) {
+			for _, p := range req.Properties {
+				gets = append(gets, p.EPC)
+			}
+		}
+		return device.Handle(data, addr)
+	}
+	now := monitortest.Now
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour))
+	m.client = NewEchonetClient(echonetlite.NewFakeTransport(handler), m.cfg.TargetIP, time.Second)
+	m.cfg.NotificationMode = true
+	m.cfg.NotificationMaxAgeSeconds = 120
+	m.cfg.SlowPollIntervalSeconds = 300
+
+	battery := echonetlite.NewEOJ(0x02, 0x7D, 0x01)
+	m.client.notified.store(battery, 0xE4, "蓄電残量3", uint8(50), now)
+	m.client.notified.store(battery, 0xD3, "瞬時充放電電力計測値", int32(0), now)
+
+	m.runCycle()
+	if string(gets) != string([]byte{0xDA, 0xEB, 0xA0, 0xCF, 0xD0, 0x88, 0x89}) {
+		t.Errorf("first cycle requested EPCs %X, want DA EB A0 CF D0 88 89", gets)
+	}
+
+	// The AC capacity is only polled every slow_poll_interval_seconds.
+	gets = nil
+	m.runCycle()
+	if string(gets) != string([]byte{0xDA, 0xEB, 0xCF, 0xD0, 0x88, 0x89}) {
+		t.Errorf("second cycle requested EPCs %X, want DA EB CF D0 88 89", gets)
+	}
+}
+
+func TestPropertyCacheExpires(t *testing.T) {
+	c := newPropertyCache()
+	eoj := echonetlite.NewEOJ(0x02, 0x7D, 0x01)
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c.store(eoj, 0xE4, "蓄電残量3", uint8(80), at)
+
+	if v, ok := c.get(eoj, 0xE4, time.Minute, at.Add(30*time.Second)); !ok || v.value != uint8(80) {
+		t.Errorf("get within max age = %v, %t, want 80, true", v.value, ok)
+	}
+	if _, ok := c.get(eoj, 0xE4, time.Minute, at.Add(2*time.Minute)); ok {
+		t.Error("expired value returned")
+	}
+	if _, ok := c.get(eoj, 0xD3, time.Minute, at); ok {
+		t.Error("value returned for an EPC that was never stored")
+	}
+}
+
+func TestMonitorDryRunSendsNoSetC(t *testing.T) {
+	now := monitortest.Now
+	device := monitortest.NewFakeEIBS7()
+	device.Props[echonetlite.NewEOJ(0x02, 0x7D, 0x01)][0xDA] = []byte{0x46} // auto mode
+	m := newTestMonitor(device, now.Add(-time.Hour), now.Add(time.Hour))
+	m.client.DryRun = true
+
+	m.runCycle()
+
+	// The cycle would switch to charge mode and set the charge power, but nothing may reach the device.
+	if len(device.Sets) != 0 {
+		t.Errorf("dry run sent %d SetC", len(device.Sets))
+	}
+	if got := device.Props[echonetlite.NewEOJ(0x02, 0x7D, 0x01)][0xDA]; got[0] != 0x46 {
+		t.Errorf("operation mode changed to 0x%X in dry run", got[0])
+	}
+}
+
+func TestMonitorManualOverrideForcesAuto(t *testing.T) {
+	now := monitortest.Now
+	device := monitortest.NewFakeEIBS7()
+	m := newTestMonitor(device, now.Add(-time.Hour), now.Add(time.Hour))
+	m.Override.set(OverrideAuto, time.Hour, now)
+
+	m.runCycle()
+
+	// Inside the charging window with plenty of surplus, the override still wins.
+	if len(device.Sets) != 1 {
+		t.Fatalf("expected 1 SetC, got %d", len(device.Sets))
+	}
+	p := device.Sets[0].Properties[0]
+	if p.EPC != 0xDA || len(p.EDT) != 1 || p.EDT[0] != 0x46 {
+		t.Errorf("SetC = EPC 0x%X EDT %X, want operation mode 0x46", p.EPC, p.EDT)
+	}
+}
+
+func TestMonitorGuardedOverrideKeepsReserve(t *testing.T) {
+	now := monitortest.Now
+	device := monitortest.NewFakeEIBS7()
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour))
+	m.cfg.ReserveSOCPercent = 60 // above the fake device's SOC of 50%
+	if _, err := m.Override.HandleGuardedCommand("auto 60", now); err != nil {
+		t.Fatal(err)
+	}
+
+	m.runCycle()
+
+	if len(device.Sets) != 0 {
+		t.Errorf("guarded auto override sent %d SetC below the reserve", len(device.Sets))
+	}
+
+	// The same command from the socket is not guarded and switches to auto mode.
+	if _, err := m.Override.HandleCommand("auto 60", now); err != nil {
+		t.Fatal(err)
+	}
+	m.runCycle()
+	if len(device.Sets) != 1 || device.Sets[0].Properties[0].EDT[0] != 0x46 {
+		t.Errorf("unguarded auto override sent %v, want operation mode 0x46", device.Sets)
+	}
+}
+
+func TestMonitorTargetSOCOverride(t *testing.T) {
+	now := monitortest.Now
+	device := monitortest.NewFakeEIBS7()
+	m := newTestMonitor(device, now.Add(-time.Hour), now.Add(time.Hour))
+	// The fake device reports 50%, so a 40% target means charging is already complete.
+	if _, err := m.Override.HandleCommand("target 40", now); err != nil {
+		t.Fatal(err)
+	}
+	var record *AuditRecord
+	m.audit.AddStore(func(r *AuditRecord) { record = r })
+
+	m.runCycle()
+
+	if record == nil || record.Inputs["charge_target_soc_percent"] != 40 {
+		t.Fatalf("audit inputs = %v, want charge_target_soc_percent 40", record)
+	}
+}
+
+func TestMonitorImportGuardReducesChargePower(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	device.Props[echonetlite.NewEOJ(0x02, 0x87, 0x01)][0xC6] = binary.BigEndian.AppendUint32(nil, 5600)
+	device.Props[echonetlite.NewEOJ(0x02, 0x7D, 0x01)][0xEB] = binary.BigEndian.AppendUint32(nil, 2000)
+	now := monitortest.Now
+	// Outside the charging window: the guard applies regardless of the schedule.
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour))
+	m.cfg.ImportGuard = config.ImportGuardConfig{Enabled: true, LimitWatts: 5000}
+	if err := m.cfg.ImportGuard.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	m.runCycle()
+
+	if len(device.Sets) != 1 {
+		t.Fatalf("expected 1 SetC, got %d", len(device.Sets))
+	}
+	p := device.Sets[0].Properties[0]
+	if p.EPC != 0xEB || len(p.EDT) != 4 || binary.BigEndian.Uint32(p.EDT) != 1400 {
+		t.Errorf("SetC = EPC 0x%X EDT %X, want charge power 1400 W", p.EPC, p.EDT)
+	}
+}
+
+func TestMonitorExportMaximizationStandsBy(t *testing.T) {
+	now := monitortest.Now
+	device := monitortest.NewFakeEIBS7()
+	m := newTestMonitor(device, now.Add(-time.Hour), now.Add(time.Hour))
+	m.cfg.ExportMaximization = []config.ExportWindow{{
+		DateRange: config.DateRange{Start: "01-01", End: "12-31"},
+		TimeSlot:  config.TimeSlot{StartTime: now.Add(-time.Hour).Format("15:04"), EndTime: now.Add(time.Hour).Format("15:04")},
+	}}
+
+	m.runCycle()
+
+	// Even inside the charging window with surplus, the battery must not absorb PV.
+	if len(device.Sets) != 1 {
+		t.Fatalf("expected 1 SetC, got %d", len(device.Sets))
+	}
+	p := device.Sets[0].Properties[0]
+	if p.EPC != 0xDA || len(p.EDT) != 1 || p.EDT[0] != 0x44 {
+		t.Errorf("SetC = EPC 0x%X EDT %X, want operation mode 0x44", p.EPC, p.EDT)
+	}
+}
+
+func TestMonitorPeakShavingDischargesExcessImport(t *testing.T) {
+	now := monitortest.Now
+	device := monitortest.NewFakeEIBS7()
+	device.Props[echonetlite.NewEOJ(0x02, 0x87, 0x01)][0xC6] = binary.BigEndian.AppendUint32(nil, 2800)
+	device.Props[echonetlite.NewEOJ(0x02, 0x7D, 0x01)][0xDA] = []byte{0x46}
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour))
+	m.cfg.PeakShaving = config.PeakShavingConfig{
+		Enabled:              true,
+		TimeSlot:             config.TimeSlot{StartTime: now.Add(-time.Hour).Format("15:04"), EndTime: now.Add(time.Hour).Format("15:04")},
+		ImportThresholdWatts: 2000,
+		MinSOCPercent:        20,
+	}
+	if err := m.cfg.PeakShaving.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	m.runCycle()
+
+	if len(device.Sets) != 2 {
+		t.Fatalf("expected 2 SetC, got %d", len(device.Sets))
+	}
+	if p := device.Sets[0].Properties[0]; p.EPC != 0xEC || binary.BigEndian.Uint32(p.EDT) != 800 {
+		t.Errorf("SetC = EPC 0x%X EDT %X, want discharge power 800 W", p.EPC, p.EDT)
+	}
+	if p := device.Sets[1].Properties[0]; p.EPC != 0xDA || p.EDT[0] != 0x43 {
+		t.Errorf("SetC = EPC 0x%X EDT %X, want operation mode 0x43", p.EPC, p.EDT)
+	}
+
+	// At the SOC floor the battery stands by instead.
+	device.Props[echonetlite.NewEOJ(0x02, 0x7D, 0x01)][0xE4] = []byte{20}
+	m.runCycle()
+	if p := device.Sets[len(device.Sets)-1].Properties[0]; p.EPC != 0xDA || p.EDT[0] != 0x44 {
+		t.Errorf("SetC = EPC 0x%X EDT %X, want operation mode 0x44 at the SOC floor", p.EPC, p.EDT)
+	}
+}
@@ -0,0 +1,117 @@
+// Package monitortest は、monitor を使用するテストのための模擬 EIBS7 と設定を提供します。
+//
+// monitor のテストからも使用するため、monitor には依存しません。
+package monitortest
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// Now は、テストの基準時刻です。NewConfig で作成した設定の時計は、この時刻を返します。
+// 基準時刻の前後数時間の時間帯が日付をまたがないよう、ローカル時刻の正午にしています。
+var Now = time.Date(2025, 6, 1, 12, 0, 0, 0, time.Local)
+
+// Clock は、T の時刻を返す時計です。テストで時刻を進める場合は T を変更します。
+type Clock struct {
+	T time.Time
+}
+
+// Now は、T を返します。
+func (c *Clock) Now() time.Time {
+	return c.T
+}
+
+// FakeEIBS7 は、プロパティの値の表から Get と SetC に応答し、受信した SetC を記録する模擬 EIBS7 です。
+// Handle を echonetlite.NewFakeTransport に渡して使用します。
+type FakeEIBS7 struct {
+	Props map[echonetlite.EOJ]map[byte][]byte // ECHONET Lite オブジェクトと EPC ごとのプロパティの値
+	Sets  []echonetlite.Frame                 // 受信した SetC
+}
+
+// NewFakeEIBS7 は、蓄電残量 50%、充電モードで太陽光発電が 3000 W の FakeEIBS7 を作成します。
+func NewFakeEIBS7() *FakeEIBS7 {
+	u32 := func(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+	return &FakeEIBS7{Props: map[echonetlite.EOJ]map[byte][]byte{
+		echonetlite.NewEOJ(0x0E, 0xF0, 0x01): {0x80: {0x30}}, // ノードプロファイル
+		echonetlite.NewEOJ(0x02, 0x7D, 0x01): {
+			0xE4: {50},       // 蓄電残量3: 50%
+			0xDA: {0x42},     // 運転モード設定: 充電
+			0xEB: u32(1000),  // 充電電力設定値
+			0xD3: u32(0),     // 瞬時充放電電力計測値
+			0xA0: u32(10000), // AC実効容量
+			0xCF: {0x42},     // 運転動作状態: 充電
+			0xD0: {0x00},     // 系統連系状態: 系統連系
+		},
+		echonetlite.NewEOJ(0x02, 0x79, 0x01): {0xE0: {0x0B, 0xB8}}, // 太陽光発電: 3000 W
+		echonetlite.NewEOJ(0x02, 0x87, 0x01): {0xC6: u32(0)},
+		echonetlite.NewEOJ(0x02, 0xA5, 0x01): {0xE7: u32(0)},
+	}}
+}
+
+// Handle は、受信した Get に Props の値で応答し、SetC を Sets に記録して Props に反映します。それ以外の要求には応答しません。
+func (d *FakeEIBS7) Handle(data []byte, addr *net.UDPAddr) []echonetlite.Datagram {
+	var req echonetlite.Frame
+	if err := req.UnmarshalBinary(data); err != nil {
+		return nil
+	}
+	res := echonetlite.Frame{
+		EHD1: req.EHD1,
+		EHD2: req.EHD2,
+		TID:  req.TID,
+		SEOJ: req.DEOJ,
+		DEOJ: req.SEOJ,
+	}
+	switch req.ESV {
+	case echonetlite.ESVGet:
+		res.ESV = echonetlite.ESVGet_Res
+		for _, p := range req.Properties {
+			edt := d.Props[req.DEOJ][p.EPC]
+			res.Properties = append(res.Properties, echonetlite.Property{EPC: p.EPC, PDC: byte(len(edt)), EDT: edt})
+		}
+	case echonetlite.ESVSetC:
+		d.Sets = append(d.Sets, req)
+		res.ESV = echonetlite.ESVSet_Res
+		for _, p := range req.Properties {
+			d.Props[req.DEOJ][p.EPC] = p.EDT
+			res.Properties = append(res.Properties, echonetlite.Property{EPC: p.EPC})
+		}
+	default:
+		return nil
+	}
+	res.OPC = byte(len(res.Properties))
+	out, err := res.MarshalBinary()
+	if err != nil {
+		return nil
+	}
+	return []echonetlite.Datagram{{Data: out, Addr: addr}}
+}
+
+// NewConfig は、1台の EIBS7 を対象とし、充電時間帯を start から end までとする設定を返します。opts で設定を変更できます。
+// 設定の時計は Now を返す Clock です。
+func NewConfig(start, end time.Time, opts ...func(*config.Config)) *config.Config {
+	cfg := &config.Config{
+		TargetIP:                         "192.168.0.10",
+		MonitorIntervalSeconds:           60,
+		ChargeStartTime:                  start.Format("15:04"),
+		ChargeEndTime:                    end.Format("15:04"),
+		ChargeTargetSOCPercent:           100,
+		ChargePowerUpdateIntervalMinutes: 10,
+		AutoModeThresholdWatts:           100,
+		ModeChangeInhibitMinutes:         10,
+		MinSurplusPowerJudgmentMinutes:   10,
+		SurplusPowerMarginWatts:          500,
+		MaxChargePowerWatts:              2000,
+		LivenessCheckIntervalSeconds:     60,
+		UnreachableFailureThreshold:      3,
+		Clock:                            &Clock{T: Now},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
@@ -0,0 +1,350 @@
+package monitor
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/controller"
+)
+
+// oadrPayload は、VTN の oadrDistributeEvent のうち、使用する項目です。名前空間の接頭辞は問いません。
+type oadrPayload struct {
+	RequestID string      `xml:"oadrSignedObject>oadrDistributeEvent>requestID"`
+	Events    []oadrEvent `xml:"oadrSignedObject>oadrDistributeEvent>oadrEvent"`
+}
+
+// oadrEvent は、1つのイベントです。
+type oadrEvent struct {
+	EventID          string       `xml:"eiEvent>eventDescriptor>eventID"`
+	Modification     int          `xml:"eiEvent>eventDescriptor>modificationNumber"`
+	Status           string       `xml:"eiEvent>eventDescriptor>eventStatus"`
+	Start            string       `xml:"eiEvent>eiActivePeriod>properties>dtstart>date-time"`
+	Duration         string       `xml:"eiEvent>eiActivePeriod>properties>duration>duration"`
+	Signals          []oadrSignal `xml:"eiEvent>eiEventSignals>eiEventSignal"`
+	ResponseRequired string       `xml:"oadrResponseRequired"`
+}
+
+// oadrSignal は、イベントのシグナルです。
+type oadrSignal struct {
+	Name      string         `xml:"signalName"`
+	Current   *float64       `xml:"currentValue>payloadFloat>value"`
+	Intervals []oadrInterval `xml:"intervals>interval"`
+}
+
+// oadrInterval は、シグナルの区間です。
+type oadrInterval struct {
+	Duration string  `xml:"duration>duration"`
+	Value    float64 `xml:"signalPayload>payloadFloat>value"`
+}
+
+// openADREvent は、VTN から受信したイベントのうち、制御に使用する内容です。
+type openADREvent struct {
+	id        string
+	start     time.Time
+	end       time.Time // ゼロ値の場合は終了時刻なし
+	intervals []openADRInterval
+	current   int // 区間のない SIMPLE シグナルのレベル
+}
+
+// openADRInterval は、SIMPLE シグナルのレベルが一定の区間です。
+type openADRInterval struct {
+	start, end time.Time
+	level      int
+}
+
+// level は、now の時点の SIMPLE シグナルのレベルを返します。イベントの期間外の場合は ok = false です。
+func (e openADREvent) level(now time.Time) (int, bool) {
+	if now.Before(e.start) || (!e.end.IsZero() && !now.Before(e.end)) {
+		return 0, false
+	}
+	for _, iv := range e.intervals {
+		if !now.Before(iv.start) && (iv.end.IsZero() || now.Before(iv.end)) {
+			return iv.level, true
+		}
+	}
+	return e.current, true
+}
+
+// openADRSignal は、現在有効なイベントとその制御です。
+type openADRSignal struct {
+	eventID string
+	level   int
+	action  string
+	end     time.Time
+}
+
+// openADRVEN は、VTN にイベントを定期的に問い合わせ、受信したイベントを保持します。
+type openADRVEN struct {
+	cfg    config.OpenADRConfig
+	client *http.Client
+
+	lastPoll  time.Time
+	events    map[string]openADREvent
+	responded map[string]int // イベント ID ごとに応答した modificationNumber
+	requestID int
+}
+
+// newOpenADRVEN は、設定に基づいて openADRVEN を作成します。
+func newOpenADRVEN(cfg config.OpenADRConfig) *openADRVEN {
+	v := &openADRVEN{
+		cfg:       cfg,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		events:    make(map[string]openADREvent),
+		responded: make(map[string]int),
+	}
+	if cfg.Enabled {
+		tlsConfig, err := cfg.TLSConfig()
+		if err != nil {
+			log.Printf("[OpenADR] TLS の設定に失敗しました: %v", err) // validate で確認済みのため、通常は発生しない
+		} else {
+			v.client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+		}
+	}
+	return v
+}
+
+// poll は、前回の問い合わせから poll_interval_seconds が経過していれば VTN にイベントを問い合わせます。
+// 取得に失敗した場合は前回受信したイベントを維持します。
+func (v *openADRVEN) poll(now time.Time) {
+	if !v.cfg.Enabled || (!v.lastPoll.IsZero() && now.Sub(v.lastPoll) < time.Duration(v.cfg.PollIntervalSeconds)*time.Second) {
+		return
+	}
+	v.lastPoll = now
+	payload, err := v.requestEvents()
+	if err != nil {
+		log.Printf("[OpenADR] イベントの問い合わせに失敗しました (前回受信したイベントを維持します): %v", err)
+		return
+	}
+	events := make(map[string]openADREvent)
+	for _, e := range payload.Events {
+		status := strings.ToLower(e.Status)
+		if status == "cancelled" || status == "completed" {
+			continue
+		}
+		event, err := parseOpenADREvent(e)
+		if err != nil {
+			log.Printf("[OpenADR] イベント '%s' を読み込めませんでした: %v", e.EventID, err)
+			continue
+		}
+		if _, ok := v.events[e.EventID]; !ok {
+			log.Printf("[OpenADR] イベント '%s' を受信しました (開始: %s)。", e.EventID, event.start.In(now.Location()).Format("2006-01-02 15:04"))
+		}
+		events[e.EventID] = event
+		if strings.EqualFold(e.ResponseRequired, "never") {
+			continue
+		}
+		if n, ok := v.responded[e.EventID]; ok && n == e.Modification {
+			continue
+		}
+		if err := v.respond(payload.RequestID, e); err != nil {
+			log.Printf("[OpenADR] イベント '%s' への応答に失敗しました: %v", e.EventID, err)
+			continue
+		}
+		v.responded[e.EventID] = e.Modification
+	}
+	v.events = events
+}
+
+// current は、now の時点で有効なイベントのうち、最もレベルの高いものを返します。opt_out の場合は常に ok = false です。
+func (v *openADRVEN) current(now time.Time) (openADRSignal, bool) {
+	if !v.cfg.Enabled || v.cfg.OptOut {
+		return openADRSignal{}, false
+	}
+	var best openADRSignal
+	found := false
+	for id, e := range v.events {
+		level, ok := e.level(now)
+		if !ok || v.cfg.Action(level) == config.OpenADRNone {
+			continue
+		}
+		if !found || level > best.level {
+			best = openADRSignal{eventID: id, level: level, action: v.cfg.Action(level), end: e.end}
+			found = true
+		}
+	}
+	return best, found
+}
+
+// requestEvents は、VTN に oadrRequestEvent を送信し、oadrDistributeEvent を受信します。
+func (v *openADRVEN) requestEvents() (oadrPayload, error) {
+	v.requestID++
+	body := fmt.Sprintf(`<oadrRequestEvent ei:schemaVersion="2.0b"><pyld:eiRequestEvent><pyld:requestID>%s</pyld:requestID><ei:venID>%s</ei:venID></pyld:eiRequestEvent></oadrRequestEvent>`,
+		xmlEscape(fmt.Sprintf("eibs7-%d", v.requestID)), xmlEscape(v.cfg.VENID))
+	resp, err := v.post(body)
+	if err != nil {
+		return oadrPayload{}, err
+	}
+	var payload oadrPayload
+	if err := xml.Unmarshal(resp, &payload); err != nil {
+		return oadrPayload{}, fmt.Errorf("応答を解析できませんでした: %w", err)
+	}
+	return payload, nil
+}
+
+// respond は、イベントに oadrCreatedEvent で optIn または optOut を応答します。
+func (v *openADRVEN) respond(requestID string, e oadrEvent) error {
+	opt := "optIn"
+	if v.cfg.OptOut {
+		opt = "optOut"
+	}
+	body := fmt.Sprintf(`<oadrCreatedEvent ei:schemaVersion="2.0b"><pyld:eiCreatedEvent>`+
+		`<ei:eiResponse><ei:responseCode>200</ei:responseCode><ei:responseDescription>OK</ei:responseDescription><pyld:requestID/></ei:eiResponse>`+
+		`<ei:eventResponses><ei:eventResponse><ei:responseCode>200</ei:responseCode><pyld:requestID>%s</pyld:requestID>`+
+		`<ei:qualifiedEventID><ei:eventID>%s</ei:eventID><ei:modificationNumber>%d</ei:modificationNumber></ei:qualifiedEventID>`+
+		`<ei:optType>%s</ei:optType></ei:eventResponse></ei:eventResponses>`+
+		`<ei:venID>%s</ei:venID></pyld:eiCreatedEvent></oadrCreatedEvent>`,
+		xmlEscape(requestID), xmlEscape(e.EventID), e.Modification, opt, xmlEscape(v.cfg.VENID))
+	if _, err := v.post(body); err != nil {
+		return err
+	}
+	log.Printf("[OpenADR] イベント '%s' に %s で応答しました。", e.EventID, opt)
+	return nil
+}
+
+// post は、oadrPayload で包んだ要求を VTN の EiEvent サービスに送信し、応答の本文を返します。
+func (v *openADRVEN) post(body string) ([]byte, error) {
+	payload := `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<oadrPayload xmlns="http://openadr.org/oadr-2.0b/2012/07" xmlns:ei="http://docs.oasis-open.org/ns/energyinterop/201110" xmlns:pyld="http://docs.oasis-open.org/ns/energyinterop/201110/payloads">` +
+		`<oadrSignedObject>` + body + `</oadrSignedObject></oadrPayload>`
+	resp, err := v.client.Post(v.cfg.VTNURL+"/EiEvent", "application/xml", bytes.NewReader([]byte(payload)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// xmlEscape は、XML の文字データとして s をエスケープします。
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// parseOpenADREvent は、イベントの期間と SIMPLE シグナルのレベルを読み込みます。SIMPLE 以外のシグナルは使用しません。
+func parseOpenADREvent(e oadrEvent) (openADREvent, error) {
+	start, err := time.Parse(time.RFC3339, strings.TrimSpace(e.Start))
+	if err != nil {
+		return openADREvent{}, fmt.Errorf("開始時刻 ('%s') が不正です: %w", e.Start, err)
+	}
+	d, err := parseISO8601Duration(e.Duration)
+	if err != nil {
+		return openADREvent{}, fmt.Errorf("期間 ('%s') が不正です: %w", e.Duration, err)
+	}
+	event := openADREvent{id: e.EventID, start: start}
+	if d > 0 {
+		event.end = start.Add(d)
+	}
+	for _, s := range e.Signals {
+		if !strings.EqualFold(s.Name, "SIMPLE") {
+			continue
+		}
+		if s.Current != nil {
+			event.current = int(*s.Current)
+		}
+		at := start
+		for _, iv := range s.Intervals {
+			d, err := parseISO8601Duration(iv.Duration)
+			if err != nil {
+				return openADREvent{}, fmt.Errorf("区間の期間 ('%s') が不正です: %w", iv.Duration, err)
+			}
+			interval := openADRInterval{start: at, level: int(iv.Value)}
+			if d > 0 {
+				interval.end = at.Add(d)
+			}
+			event.intervals = append(event.intervals, interval)
+			at = at.Add(d)
+		}
+	}
+	return event, nil
+}
+
+// iso8601Duration は、ISO 8601 の期間 (PnWnDTnHnMnS) の形式です。年と月は使用しません。
+var iso8601Duration = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseISO8601Duration は、ISO 8601 の期間を time.Duration に変換します。空の場合は 0 を返します。
+func parseISO8601Duration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	m := iso8601Duration.FindStringSubmatch(s)
+	if m == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("ISO 8601 の期間ではありません")
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute} {
+		if m[i+2] != "" {
+			n, _ := strconv.Atoi(m[i+2])
+			d += time.Duration(n) * unit
+		}
+	}
+	if m[6] != "" {
+		sec, _ := strconv.ParseFloat(m[6], 64)
+		d += time.Duration(sec * float64(time.Second))
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+// openADRStrategy は、OpenADR のイベントの期間中に使用する制御方式です。
+// "limit_charge" は通常の制御方式の充電電力を上限で制限し、"discharge" は放電モードにして放電します。
+type openADRStrategy struct {
+	m      *Monitor
+	base   strategy // イベントがない場合に使用する制御方式
+	signal openADRSignal
+}
+
+func (s openADRStrategy) name() string { return "openadr_" + s.signal.action }
+
+func (s openADRStrategy) decide(ms measurements, state controller.State) controlActions {
+	cfg := s.m.cfg.OpenADR
+	if s.signal.action == config.OpenADRDischarge {
+		if ms.belowReserve {
+			log.Println("[OpenADR] 最低リザーブ以下のため、イベントの放電は行わず待機モードに設定します。")
+			return modeActions(0x44) // 0x44: 待機モード
+		}
+		power := cfg.DischargeWatts
+		if power == 0 {
+			if !ms.haveLoad {
+				log.Println("[OpenADR] 自家消費電力が取得できなかったため、放電電力を決められません。制御をスキップします。")
+				return noActions()
+			}
+			power = int(ms.householdLoad)
+			if power < 0 {
+				power = 0
+			}
+		}
+		a := modeActions(0x43) // 0x43: 放電モード
+		a.recordModeChange = true
+		a.dischargePower = power
+		a.dischargeStep = 100
+		a.dischargeNote = fmt.Sprintf("[OpenADR] イベント '%s' のため、放電電力を %d W に設定しました。", s.signal.eventID, power)
+		return a
+	}
+
+	a := s.base.decide(ms, state)
+	mode := a.mode
+	if mode == 0 {
+		mode = ms.operationMode
+	}
+	if mode == 0x42 && (a.chargePower < 0 || a.chargePower > cfg.LimitChargeWatts) { // 0x42: 充電モード
+		log.Printf("[OpenADR] イベント '%s' のため、充電電力を %d W に制限します。", s.signal.eventID, cfg.LimitChargeWatts)
+		a.chargePower = cfg.LimitChargeWatts
+	}
+	return a
+}
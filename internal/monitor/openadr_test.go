@@ -0,0 +1,194 @@
+package monitor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestParseISO8601Duration(t *testing.T) {
+	for s, want := range map[string]time.Duration{
+		"":          0,
+		"PT1H":      time.Hour,
+		"PT1H30M":   90 * time.Minute,
+		"P1DT2H":    26 * time.Hour,
+		"P1W":       7 * 24 * time.Hour,
+		"PT0.5S":    500 * time.Millisecond,
+		"-PT15M":    -15 * time.Minute,
+		" PT10M \n": 10 * time.Minute,
+	} {
+		if got, err := parseISO8601Duration(s); err != nil || got != want {
+			t.Errorf("parseISO8601Duration(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+	for _, bad := range []string{"P", "PT", "1H", "P1Y", "PT1X"} {
+		if _, err := parseISO8601Duration(bad); err == nil {
+			t.Errorf("parseISO8601Duration(%q) succeeded", bad)
+		}
+	}
+}
+
+// openADRDistributeEvent returns an oadrDistributeEvent with one SIMPLE event
+// in two 30 minute intervals (levels 1 and 2).
+func openADRDistributeEvent(start time.Time, status string, modification int) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<oadr:oadrPayload xmlns:oadr="http://openadr.org/oadr-2.0b/2012/07" xmlns:ei="http://docs.oasis-open.org/ns/energyinterop/201110" xmlns:pyld="http://docs.oasis-open.org/ns/energyinterop/201110/payloads" xmlns:xcal="urn:ietf:params:xml:ns:icalendar-2.0" xmlns:strm="urn:ietf:params:xml:ns:icalendar-2.0:stream">
+ <oadr:oadrSignedObject>
+  <oadr:oadrDistributeEvent ei:schemaVersion="2.0b">
+   <pyld:requestID>req-1</pyld:requestID>
+   <oadr:oadrEvent>
+    <ei:eiEvent>
+     <ei:eventDescriptor>
+      <ei:eventID>event-1</ei:eventID>
+      <ei:modificationNumber>%d</ei:modificationNumber>
+      <ei:eventStatus>%s</ei:eventStatus>
+     </ei:eventDescriptor>
+     <ei:eiActivePeriod>
+      <xcal:properties>
+       <xcal:dtstart><xcal:date-time>%s</xcal:date-time></xcal:dtstart>
+       <xcal:duration><xcal:duration>PT1H</xcal:duration></xcal:duration>
+      </xcal:properties>
+     </ei:eiActivePeriod>
+     <ei:eiEventSignals>
+      <ei:eiEventSignal>
+       <strm:intervals>
+        <ei:interval><xcal:duration><xcal:duration>PT30M</xcal:duration></xcal:duration><ei:signalPayload><ei:payloadFloat><ei:value>1.0</ei:value></ei:payloadFloat></ei:signalPayload></ei:interval>
+        <ei:interval><xcal:duration><xcal:duration>PT30M</xcal:duration></xcal:duration><ei:signalPayload><ei:payloadFloat><ei:value>2.0</ei:value></ei:payloadFloat></ei:signalPayload></ei:interval>
+       </strm:intervals>
+       <ei:signalName>SIMPLE</ei:signalName>
+       <ei:signalType>level</ei:signalType>
+       <ei:currentValue><ei:payloadFloat><ei:value>0.0</ei:value></ei:payloadFloat></ei:currentValue>
+      </ei:eiEventSignal>
+     </ei:eiEventSignals>
+    </ei:eiEvent>
+    <oadr:oadrResponseRequired>always</oadr:oadrResponseRequired>
+   </oadr:oadrEvent>
+  </oadr:oadrDistributeEvent>
+ </oadr:oadrSignedObject>
+</oadr:oadrPayload>`, modification, status, start.UTC().Format(time.RFC3339))
+}
+
+func TestOpenADRVEN(t *testing.T) {
+	start := time.Date(2025, 7, 1, 14, 0, 0, 0, time.UTC)
+	status, modification := "far", 0
+	var responses []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/OpenADR2/Simple/2.0b/EiEvent" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "oadrCreatedEvent") {
+			responses = append(responses, string(body))
+			io.WriteString(w, `<oadrPayload><oadrSignedObject><oadrResponse/></oadrSignedObject></oadrPayload>`)
+			return
+		}
+		if !strings.Contains(string(body), "<ei:venID>ven-1</ei:venID>") {
+			t.Errorf("oadrRequestEvent without the VEN ID: %s", body)
+		}
+		io.WriteString(w, openADRDistributeEvent(start, status, modification))
+	}))
+	defer srv.Close()
+
+	cfg := config.OpenADRConfig{Enabled: true, VTNURL: srv.URL + "/OpenADR2/Simple/2.0b/", VENID: "ven-1"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	v := newOpenADRVEN(cfg)
+
+	now := start.Add(-time.Hour)
+	v.poll(now)
+	if len(responses) != 1 || !strings.Contains(responses[0], "<ei:optType>optIn</ei:optType>") || !strings.Contains(responses[0], "<ei:eventID>event-1</ei:eventID>") {
+		t.Fatalf("responses = %q", responses)
+	}
+	if _, ok := v.current(now); ok {
+		t.Error("event active before its start")
+	}
+
+	// The same modification is not answered twice; a new one is.
+	status = "active"
+	v.poll(start.Add(time.Minute))
+	if len(responses) != 1 {
+		t.Errorf("answered an unchanged event again: %d responses", len(responses))
+	}
+	modification = 1
+	v.poll(start.Add(2 * time.Minute))
+	if len(responses) != 2 {
+		t.Errorf("did not answer the modified event: %d responses", len(responses))
+	}
+
+	for _, c := range []struct {
+		at     time.Duration
+		level  int
+		action string
+		ok     bool
+	}{
+		{10 * time.Minute, 1, config.OpenADRLimitCharge, true},
+		{40 * time.Minute, 2, config.OpenADRDischarge, true},
+		{61 * time.Minute, 0, "", false},
+	} {
+		signal, ok := v.current(start.Add(c.at))
+		if ok != c.ok || signal.level != c.level || signal.action != c.action {
+			t.Errorf("current(+%s) = %+v, %t, want level %d %q", c.at, signal, ok, c.level, c.action)
+		}
+	}
+
+	// Cancelled events are dropped; polling waits for the interval.
+	status = "cancelled"
+	v.poll(start.Add(2*time.Minute + 30*time.Second))
+	if _, ok := v.current(start.Add(10 * time.Minute)); !ok {
+		t.Error("polled again before poll_interval_seconds")
+	}
+	v.poll(start.Add(4 * time.Minute))
+	if _, ok := v.current(start.Add(10 * time.Minute)); ok {
+		t.Error("cancelled event still active")
+	}
+
+	// Opting out answers optOut and never controls the battery.
+	status, modification = "active", 2
+	v.cfg.OptOut = true
+	v.poll(start.Add(6 * time.Minute))
+	if len(responses) != 3 || !strings.Contains(responses[2], "<ei:optType>optOut</ei:optType>") {
+		t.Errorf("opt-out response = %q", responses[len(responses)-1])
+	}
+	if _, ok := v.current(start.Add(10 * time.Minute)); ok {
+		t.Error("opted-out event is active")
+	}
+}
+
+func TestMonitorOpenADRDischarge(t *testing.T) {
+	now := monitortest.Now
+	device := monitortest.NewFakeEIBS7()
+	device.Props[echonetlite.NewEOJ(0x02, 0x87, 0x01)][0xC6] = binary.BigEndian.AppendUint32(nil, 1800)
+	device.Props[echonetlite.NewEOJ(0x02, 0x7D, 0x01)][0xDA] = []byte{0x46}
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour))
+	m.cfg.OpenADR = config.OpenADRConfig{Enabled: true, VTNURL: "http://vtn.invalid", VENID: "ven"}
+	if err := m.cfg.OpenADR.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	m.openADR = newOpenADRVEN(m.cfg.OpenADR)
+	m.openADR.lastPoll = now // do not poll the VTN
+	m.openADR.events["event-1"] = openADREvent{id: "event-1", start: now.Add(-time.Minute), end: now.Add(time.Hour), current: 2}
+
+	m.runCycle()
+
+	// Level 2 discharges to cover the household load (1800 W).
+	if len(device.Sets) != 2 {
+		t.Fatalf("expected 2 SetC, got %d", len(device.Sets))
+	}
+	if p := device.Sets[0].Properties[0]; p.EPC != 0xEC || binary.BigEndian.Uint32(p.EDT) != 1800 {
+		t.Errorf("SetC = EPC 0x%X EDT %X, want discharge power 1800 W", p.EPC, p.EDT)
+	}
+	if p := device.Sets[1].Properties[0]; p.EPC != 0xDA || p.EDT[0] != 0x43 {
+		t.Errorf("SetC = EPC 0x%X EDT %X, want operation mode 0x43", p.EPC, p.EDT)
+	}
+}
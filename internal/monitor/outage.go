@@ -0,0 +1,93 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// gridIndependent は、系統連系状態 (EPC 0xD0) のうち独立 (停電時の自立運転) を表す値です。
+// 0x00 は系統連系 (逆潮流可)、0x02 は系統連系 (逆潮流不可) です。
+const gridIndependent = 0x01
+
+// detectOutage は、蓄電池の系統連系状態から停電による自立運転を検出し、停電中の制御を行う間は true を返します。
+// いずれかの蓄電池が自立運転の場合は停電とみなします。outage.resume_after_minutes を設定した場合は、
+// 系統の復旧後その時間が経過するまで停電中の制御を続け、その間に再び停電した場合は停電が続いているものとします。
+// 停電の開始と復旧をログに出力し、停電中は手動操作の status コマンドでも表示します。
+// 系統連系状態が取得できなかった場合は、前回の状態を維持します。
+func (m *Monitor) detectOutage(now time.Time, monitoringData map[string]interface{}) bool {
+	islanded, known := false, false
+	for _, u := range m.batteries {
+		if v, ok := monitoringData[u.name+".系統連系状態"].(uint8); ok {
+			known = true
+			if v == gridIndependent {
+				log.Printf("[停電] %s は自立運転中です。", u.name)
+				islanded = true
+			}
+		}
+	}
+	if !known {
+		return !m.outageSince.IsZero()
+	}
+
+	resumeAfter := time.Duration(m.cfg.Outage.ResumeAfterMinutes) * time.Minute
+	switch {
+	case islanded && m.outageSince.IsZero():
+		m.outageSince = now
+		log.Printf("[停電] 警告: 停電を検出しました (%s)。復旧するまで充電電力の設定を含む自動制御を停止します (停電時の動作: %s)。", now.Format("15:04:05"), m.cfg.Outage.Policy)
+		m.Override.setOutage(fmt.Sprintf("outage since %s, automatic control suspended", now.Format("15:04")))
+	case islanded && !m.gridRestoredAt.IsZero():
+		log.Printf("[停電] 系統の復旧後 %s で再び停電しました。停電中の制御を続けます。", now.Sub(m.gridRestoredAt).Truncate(time.Second))
+		m.gridRestoredAt = time.Time{}
+		m.Override.setOutage(fmt.Sprintf("outage since %s, automatic control suspended", m.outageSince.Format("15:04")))
+	case !islanded && !m.outageSince.IsZero() && m.gridRestoredAt.IsZero() && resumeAfter > 0:
+		m.gridRestoredAt = now
+		log.Printf("[停電] 系統の復旧を検出しました (停電時間: %s)。%s 停電がなければ自動制御を再開します。", now.Sub(m.outageSince).Truncate(time.Second), resumeAfter)
+		m.Override.setOutage(fmt.Sprintf("grid restored at %s, resuming at %s", now.Format("15:04"), now.Add(resumeAfter).Format("15:04")))
+	case !islanded && !m.outageSince.IsZero() && now.Sub(m.gridRestoredAt) >= resumeAfter:
+		log.Printf("[停電] 系統が復旧しました (停電時間: %s)。自動制御を再開します。", now.Sub(m.outageSince).Truncate(time.Second))
+		m.outageSince, m.gridRestoredAt = time.Time{}, time.Time{}
+		m.Override.setOutage("")
+	}
+	return !m.outageSince.IsZero()
+}
+
+// controlOutage は、停電中の制御です。余剰電力を振り向けている電気給湯機は、蓄電池から給湯機に電力を供給しないよう自動に戻します。
+// 蓄電池は outage.policy に従い、suspend では何も設定せず、hold_soc では待機モードに、
+// loads_only では自動モード (蓄電残量が hold_soc_percent 以下の場合は待機モード) にします。
+// 系統の復旧後、自動制御の再開を待っている間は蓄電池の設定を変更しません。
+func (m *Monitor) controlOutage(monitoringData map[string]interface{}) {
+	for i, diverting := range m.diverting {
+		if diverting {
+			log.Printf("[停電] 停電中のため、%s を自動に戻します。", diversionLoadTarget(m.cfg.SurplusDiversion.Loads[i]).ObjectName)
+			m.stopDiversion(i)
+		}
+	}
+	if !m.gridRestoredAt.IsZero() {
+		log.Printf("[停電] 系統の復旧 (%s) 後、安定するまで蓄電池の制御を再開しません。", m.gridRestoredAt.Format("15:04:05"))
+		return
+	}
+
+	var mode byte
+	switch m.cfg.Outage.Policy {
+	case config.OutagePolicyHoldSOC:
+		mode = 0x44 // 0x44: 待機モード
+	case config.OutagePolicyLoadsOnly:
+		mode = 0x46 // 0x46: 自動モード (自立運転中は家庭の負荷にのみ放電する)
+		if soc, ok := monitoringData["蓄電池.蓄電残量3"].(uint8); ok && int(soc) <= m.cfg.Outage.HoldSOCPercent {
+			log.Printf("[停電] 蓄電残量 (%d%%) が %d%% 以下のため、放電を止めて蓄電残量を温存します。", soc, m.cfg.Outage.HoldSOCPercent)
+			mode = 0x44 // 0x44: 待機モード
+		}
+	default:
+		log.Printf("[停電] 停電中 (%s から) のため、蓄電池の制御を行いません。", m.outageSince.Format("15:04:05"))
+		return
+	}
+	log.Printf("[停電] 停電中 (%s から) のため、蓄電池を運転モード 0x%X にします (停電時の動作: %s)。", m.outageSince.Format("15:04:05"), mode, m.cfg.Outage.Policy)
+	changed, err := m.setOperationMode(mode, false)
+	if err != nil {
+		log.Printf("[停電] 運転モードの設定に失敗しました: %v", err)
+	}
+	m.modeChanged(changed)
+}
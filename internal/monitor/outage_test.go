@@ -0,0 +1,125 @@
+package monitor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestMonitorSuspendsControlDuringOutage(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	battery := echonetlite.NewEOJ(0x02, 0x7D, 0x01)
+	device.Props[battery][0xD0] = []byte{0x01} // independent operation
+	now := monitortest.Now
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour))
+
+	m.runCycle()
+
+	if len(device.Sets) != 0 {
+		t.Fatalf("expected no SetC during an outage, got %d", len(device.Sets))
+	}
+	if m.outageSince.IsZero() {
+		t.Fatal("outageSince not set during an outage")
+	}
+	if res, _ := m.Override.HandleCommand("status", now); !strings.Contains(res, "outage") {
+		t.Errorf("status = %q, want the outage", res)
+	}
+
+	// A cycle without the grid status keeps the outage.
+	delete(device.Props[battery], 0xD0)
+	m.runCycle()
+	if len(device.Sets) != 0 || m.outageSince.IsZero() {
+		t.Fatalf("outage ended without the grid status (%d SetC)", len(device.Sets))
+	}
+
+	// Once the grid is back, automatic control resumes.
+	device.Props[battery][0xD0] = []byte{0x00}
+	m.runCycle()
+	if len(device.Sets) != 1 {
+		t.Fatalf("expected automatic control to resume, got %d SetC", len(device.Sets))
+	}
+	if res, _ := m.Override.HandleCommand("status", now); strings.Contains(res, "outage") {
+		t.Errorf("status = %q after the grid recovered", res)
+	}
+}
+
+func TestOutageReturnsDivertedLoadsToAuto(t *testing.T) {
+	device, heater, m := newDiversionTestMonitor(-2000, 0x41)
+	device.Props[echonetlite.NewEOJ(0x02, 0x7D, 0x01)][0xD0] = []byte{0x01}
+	m.diverting[0] = true
+
+	m.runCycle()
+
+	if got := heaterSets(device, heater); len(got) != 1 || got[0] != 0x41 {
+		t.Fatalf("water heater settings = %X, want automatic boil-up (41)", got)
+	}
+	if len(device.Sets) != 1 {
+		t.Errorf("expected only the water heater SetC, got %d", len(device.Sets))
+	}
+}
+
+func TestOutagePolicy(t *testing.T) {
+	battery := echonetlite.NewEOJ(0x02, 0x7D, 0x01)
+	for _, tc := range []struct {
+		name   string
+		outage config.OutageConfig
+		soc    byte
+		want   byte
+	}{
+		{"hold_soc", config.OutageConfig{Policy: config.OutagePolicyHoldSOC}, 50, 0x44},
+		{"loads_only", config.OutageConfig{Policy: config.OutagePolicyLoadsOnly, HoldSOCPercent: 20}, 50, 0x46},
+		{"loads_only at hold_soc_percent", config.OutageConfig{Policy: config.OutagePolicyLoadsOnly, HoldSOCPercent: 20}, 20, 0x44},
+	} {
+		device := monitortest.NewFakeEIBS7()
+		device.Props[battery][0xD0] = []byte{0x01}
+		device.Props[battery][0xE4] = []byte{tc.soc}
+		now := monitortest.Now
+		m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour), func(c *config.Config) { c.Outage = tc.outage })
+
+		m.runCycle()
+
+		if len(device.Sets) != 1 || device.Sets[0].Properties[0].EPC != 0xDA || device.Sets[0].Properties[0].EDT[0] != tc.want {
+			t.Errorf("%s: SetC = %+v, want operation mode %X", tc.name, device.Sets, tc.want)
+		}
+	}
+}
+
+func TestOutageResumesAfterStableGrid(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	battery := echonetlite.NewEOJ(0x02, 0x7D, 0x01)
+	device.Props[battery][0xD0] = []byte{0x01}
+	now := monitortest.Now
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour), func(c *config.Config) {
+		c.Outage = config.OutageConfig{Policy: config.OutagePolicySuspend, ResumeAfterMinutes: 10}
+	})
+	m.runCycle()
+
+	// The grid is back, but control stays suspended until it has been stable for 10 minutes.
+	device.Props[battery][0xD0] = []byte{0x00}
+	m.runCycle()
+	if len(device.Sets) != 0 || m.gridRestoredAt.IsZero() {
+		t.Fatalf("control resumed right after the grid recovered (%d SetC)", len(device.Sets))
+	}
+	if res, _ := m.Override.HandleCommand("status", now); !strings.Contains(res, "grid restored") {
+		t.Errorf("status = %q, want the pending resume", res)
+	}
+
+	// Another outage restarts the wait.
+	device.Props[battery][0xD0] = []byte{0x01}
+	m.runCycle()
+	if !m.gridRestoredAt.IsZero() || m.outageSince.IsZero() {
+		t.Fatalf("a repeated outage should cancel the pending resume")
+	}
+	device.Props[battery][0xD0] = []byte{0x00}
+	m.runCycle()
+	m.gridRestoredAt = m.gridRestoredAt.Add(-10 * time.Minute)
+
+	m.runCycle()
+	if len(device.Sets) != 1 || !m.outageSince.IsZero() {
+		t.Fatalf("expected automatic control to resume after 10 minutes, got %d SetC", len(device.Sets))
+	}
+}
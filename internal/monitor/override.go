@@ -0,0 +1,270 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/controller"
+)
+
+// デフォルトの手動操作の時間 (分)
+const DefaultOverrideMinutes = 60
+
+// OverrideKind は、手動操作の種類です。
+type OverrideKind int
+
+const (
+	OverrideNone   OverrideKind = iota
+	overridePause               // 自動制御を停止し、蓄電池の設定を変更しない
+	OverrideCharge              // 充電モードにして最大充電電力で充電する
+	OverrideAuto                // 自動モードにする
+)
+
+// String は、手動操作のコマンド名を返します。
+func (k OverrideKind) String() string {
+	switch k {
+	case overridePause:
+		return "pause"
+	case OverrideCharge:
+		return "charge"
+	case OverrideAuto:
+		return "auto"
+	default:
+		return "none"
+	}
+}
+
+// ManualOverride は、手動操作の内容と期限を保持します。期限を過ぎると通常の制御に戻ります。
+// ソケットの受付と監視サイクルの両方から参照されるため、排他制御を行います。
+type ManualOverride struct {
+	mu    sync.Mutex
+	kind  OverrideKind
+	until time.Time
+	loc   *time.Location   // コマンドの応答の時刻を表示するタイムゾーン
+	clock controller.Clock // ソケットから受け付けたコマンドの時刻に使用する時計。nil の場合はシステムの時計
+
+	guarded bool // HTTP API から設定した手動操作。自動制御と同じモード変更の抑制時間と最低リザーブを適用する
+
+	targetSOC      int       // target コマンドで設定した充電時間帯の目標の蓄電残量 (%)。設定していない場合は 0
+	targetSOCUntil time.Time // targetSOC の期限
+
+	chargeETA string // status コマンドで表示する充電の完了予定時刻。充電時間帯外は空
+	conflict  string // status コマンドで表示する他のコントローラーとの競合。自動制御を控えていない間は空
+	outage    string // status コマンドで表示する停電の状態。停電中でない間は空
+	fault     string // status コマンドで表示する機器の異常。異常がない間は空
+}
+
+// NewManualOverride は、手動操作のない ManualOverride を作成します。コマンドの応答の時刻は loc のタイムゾーンで表示します。
+func NewManualOverride(loc *time.Location) *ManualOverride {
+	return &ManualOverride{loc: loc}
+}
+
+// Now は、手動操作の時計の現在時刻を loc のタイムゾーンで返します。
+func (o *ManualOverride) Now() time.Time {
+	if o.clock == nil {
+		return time.Now().In(o.loc)
+	}
+	return o.clock.Now().In(o.loc)
+}
+
+// set は、手動操作を now から d の間有効にします。
+func (o *ManualOverride) set(kind OverrideKind, d time.Duration, now time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.kind = kind
+	o.until = now.Add(d)
+	o.guarded = false
+}
+
+// IsGuarded は、有効な手動操作に自動制御と同じ安全確認を適用する場合に true を返します。
+func (o *ManualOverride) IsGuarded() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.guarded
+}
+
+// TargetSOCPercent は、now の時点で target コマンドにより上書きされた目標の蓄電残量 (%) を返します。
+// 期限を過ぎた場合はここで解除します。
+func (o *ManualOverride) TargetSOCPercent(now time.Time) (int, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.targetSOC != 0 && !now.Before(o.targetSOCUntil) {
+		log.Printf("[手動操作] 目標の蓄電残量 (%d%%) の期限 (%s) が過ぎたため、設定の目標に戻ります。", o.targetSOC, o.targetSOCUntil.Format("15:04:05"))
+		o.targetSOC, o.targetSOCUntil = 0, time.Time{}
+	}
+	return o.targetSOC, o.targetSOC != 0
+}
+
+// clear は、手動操作を解除します。
+func (o *ManualOverride) clear() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.kind = OverrideNone
+	o.until = time.Time{}
+	o.guarded = false
+	o.targetSOC, o.targetSOCUntil = 0, time.Time{}
+}
+
+// setChargeETA は、status コマンドで表示する充電の完了予定時刻を設定します。
+func (o *ManualOverride) setChargeETA(eta string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.chargeETA = eta
+}
+
+// setConflict は、status コマンドで表示する他のコントローラーとの競合を設定します。
+func (o *ManualOverride) setConflict(conflict string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.conflict = conflict
+}
+
+// setOutage は、status コマンドで表示する停電の状態を設定します。
+func (o *ManualOverride) setOutage(outage string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.outage = outage
+}
+
+// setFault は、status コマンドで表示する機器の異常を設定します。
+func (o *ManualOverride) setFault(fault string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.fault = fault
+}
+
+// Current は、now の時点で有効な手動操作とその期限を返します。期限を過ぎた手動操作はここで解除します。
+func (o *ManualOverride) Current(now time.Time) (OverrideKind, time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.kind != OverrideNone && !now.Before(o.until) {
+		log.Printf("[手動操作] 手動操作 (%s) の期限 (%s) が過ぎたため、通常の制御に戻ります。", o.kind, o.until.Format("15:04:05"))
+		o.kind = OverrideNone
+		o.until = time.Time{}
+	}
+	return o.kind, o.until
+}
+
+// HandleCommand は、1行のコマンドを実行し、応答の文字列を返します。
+//
+//	pause [分]   自動制御を停止する
+//	charge [分]  充電モードにして最大充電電力で充電する
+//	auto [分]    自動モードにする
+//	target <%> [分]  充電時間帯の目標の蓄電残量を変更する (1〜100%)
+//	resume       手動操作と目標の蓄電残量の変更を解除して通常の制御に戻る
+//	status       現在の手動操作と、充電時間帯であれば充電の完了予定時刻、他のコントローラーとの競合、停電の状態、機器の異常を表示する
+//
+// 分を省略した場合は DefaultOverrideMinutes 分間有効です。
+func (o *ManualOverride) HandleCommand(line string, now time.Time) (string, error) {
+	return o.runCommand(line, now, false)
+}
+
+// HandleGuardedCommand は、HandleCommand と同じくコマンドを実行します。
+// 設定した手動操作には、自動制御と同じモード変更の抑制時間と最低リザーブを適用します (HTTP API 用)。
+func (o *ManualOverride) HandleGuardedCommand(line string, now time.Time) (string, error) {
+	return o.runCommand(line, now, true)
+}
+
+// runCommand は、1行のコマンドを実行します。guarded は、設定する手動操作に安全確認を適用するかどうかです。
+func (o *ManualOverride) runCommand(line string, now time.Time, guarded bool) (string, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", errors.New("コマンドが空です")
+	}
+
+	var kind OverrideKind
+	switch strings.ToLower(fields[0]) {
+	case "pause":
+		kind = overridePause
+	case "charge":
+		kind = OverrideCharge
+	case "auto":
+		kind = OverrideAuto
+	case "target":
+		return o.handleTargetCommand(fields, now)
+	case "resume":
+		if len(fields) != 1 {
+			return "", fmt.Errorf("'%s' に引数は指定できません", fields[0])
+		}
+		o.clear()
+		log.Println("[手動操作] 手動操作を解除しました。通常の制御に戻ります。")
+		return "resumed", nil
+	case "status":
+		if len(fields) != 1 {
+			return "", fmt.Errorf("'%s' に引数は指定できません", fields[0])
+		}
+		kind, until := o.Current(now)
+		res := "none"
+		if kind != OverrideNone {
+			res = fmt.Sprintf("%s until %s", kind, until.Format("15:04:05"))
+		}
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if o.chargeETA != "" {
+			res += "; " + o.chargeETA
+		}
+		if o.conflict != "" {
+			res += "; " + o.conflict
+		}
+		if o.outage != "" {
+			res += "; " + o.outage
+		}
+		if o.targetSOC != 0 {
+			res += fmt.Sprintf("; target %d%% until %s", o.targetSOC, o.targetSOCUntil.Format("15:04:05"))
+		}
+		if o.fault != "" {
+			res += "; " + o.fault
+		}
+		return res, nil
+	default:
+		return "", fmt.Errorf("不明なコマンドです: '%s'", fields[0])
+	}
+
+	minutes := DefaultOverrideMinutes
+	switch len(fields) {
+	case 1:
+	case 2:
+		var err error
+		if minutes, err = strconv.Atoi(fields[1]); err != nil || minutes <= 0 {
+			return "", fmt.Errorf("時間 (分) は正の整数である必要があります: '%s'", fields[1])
+		}
+	default:
+		return "", fmt.Errorf("'%s' の引数が多すぎます", fields[0])
+	}
+
+	until := now.Add(time.Duration(minutes) * time.Minute)
+	o.mu.Lock()
+	o.kind, o.until, o.guarded = kind, until, guarded
+	o.mu.Unlock()
+	log.Printf("[手動操作] 手動操作 (%s) を %s まで有効にしました。", kind, until.Format("15:04:05"))
+	return fmt.Sprintf("%s until %s", kind, until.Format("15:04:05")), nil
+}
+
+// handleTargetCommand は、target コマンドで充電時間帯の目標の蓄電残量を変更します。
+// 運転モードの手動操作とは独立しており、期限までは充電時間帯の設定より優先します。
+func (o *ManualOverride) handleTargetCommand(fields []string, now time.Time) (string, error) {
+	if len(fields) < 2 || len(fields) > 3 {
+		return "", errors.New("'target' には目標の蓄電残量 (%) と、省略可能な時間 (分) を指定してください")
+	}
+	percent, err := strconv.Atoi(strings.TrimSuffix(fields[1], "%"))
+	if err != nil || percent < 1 || percent > 100 {
+		return "", fmt.Errorf("目標の蓄電残量は 1 から 100 の整数である必要があります: '%s'", fields[1])
+	}
+	minutes := DefaultOverrideMinutes
+	if len(fields) == 3 {
+		if minutes, err = strconv.Atoi(fields[2]); err != nil || minutes <= 0 {
+			return "", fmt.Errorf("時間 (分) は正の整数である必要があります: '%s'", fields[2])
+		}
+	}
+	until := now.Add(time.Duration(minutes) * time.Minute)
+	o.mu.Lock()
+	o.targetSOC, o.targetSOCUntil = percent, until
+	o.mu.Unlock()
+	log.Printf("[手動操作] 充電時間帯の目標の蓄電残量を %s まで %d%% にしました。", until.Format("15:04:05"), percent)
+	return fmt.Sprintf("target %d%% until %s", percent, until.Format("15:04:05")), nil
+}
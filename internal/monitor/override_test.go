@@ -0,0 +1,96 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManualOverrideCommands(t *testing.T) {
+	o := NewManualOverride(time.Local)
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.Local)
+
+	if _, err := o.HandleCommand("charge 30", now); err != nil {
+		t.Fatalf("charge 30: %v", err)
+	}
+	if kind, until := o.Current(now.Add(29 * time.Minute)); kind != OverrideCharge || !until.Equal(now.Add(30*time.Minute)) {
+		t.Errorf("current = %s until %s, want charge until 10:30", kind, until.Format("15:04"))
+	}
+	if kind, _ := o.Current(now.Add(30 * time.Minute)); kind != OverrideNone {
+		t.Errorf("override still active after it expired: %s", kind)
+	}
+
+	if _, err := o.HandleCommand("PAUSE", now); err != nil {
+		t.Fatalf("PAUSE: %v", err)
+	}
+	if kind, until := o.Current(now); kind != overridePause || !until.Equal(now.Add(DefaultOverrideMinutes*time.Minute)) {
+		t.Errorf("current = %s until %s, want pause for the default duration", kind, until.Format("15:04"))
+	}
+	if res, err := o.HandleCommand("status\n", now); err != nil || res != "pause until 11:00:00" {
+		t.Errorf("status = %q, %v", res, err)
+	}
+	if _, err := o.HandleCommand("resume", now); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if kind, _ := o.Current(now); kind != OverrideNone {
+		t.Errorf("override still active after resume: %s", kind)
+	}
+
+	for _, bad := range []string{"", "discharge", "auto -5", "auto x", "auto 5 6", "resume 5"} {
+		if _, err := o.HandleCommand(bad, now); err == nil {
+			t.Errorf("HandleCommand(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestManualOverrideTargetSOC(t *testing.T) {
+	o := NewManualOverride(time.Local)
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.Local)
+
+	if _, ok := o.TargetSOCPercent(now); ok {
+		t.Error("target SOC set before any command")
+	}
+	if _, err := o.HandleCommand("target 80% 30", now); err != nil {
+		t.Fatalf("target 80%% 30: %v", err)
+	}
+	if percent, ok := o.TargetSOCPercent(now.Add(29 * time.Minute)); !ok || percent != 80 {
+		t.Errorf("TargetSOCPercent = %d, %t, want 80", percent, ok)
+	}
+	if res, err := o.HandleCommand("status", now); err != nil || res != "none; target 80% until 10:30:00" {
+		t.Errorf("status = %q, %v", res, err)
+	}
+	if _, ok := o.TargetSOCPercent(now.Add(30 * time.Minute)); ok {
+		t.Error("target SOC still set after it expired")
+	}
+
+	// resume clears the target together with the mode override.
+	o.HandleCommand("target 90", now)
+	o.HandleCommand("resume", now)
+	if _, ok := o.TargetSOCPercent(now); ok {
+		t.Error("target SOC still set after resume")
+	}
+
+	for _, bad := range []string{"target", "target 0", "target 101", "target x", "target 50 -1", "target 50 1 2"} {
+		if _, err := o.HandleCommand(bad, now); err == nil {
+			t.Errorf("HandleCommand(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestManualOverrideGuarded(t *testing.T) {
+	o := NewManualOverride(time.Local)
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.Local)
+
+	if _, err := o.HandleGuardedCommand("charge 30", now); err != nil {
+		t.Fatal(err)
+	}
+	if !o.IsGuarded() {
+		t.Error("override from HandleGuardedCommand is not guarded")
+	}
+	// A later socket command replaces the override and drops the guard.
+	if _, err := o.HandleCommand("charge 30", now); err != nil {
+		t.Fatal(err)
+	}
+	if o.IsGuarded() {
+		t.Error("override from HandleCommand is guarded")
+	}
+}
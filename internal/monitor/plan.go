@@ -0,0 +1,61 @@
+package monitor
+
+import (
+	"log"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// chargePlanner は、充電時間帯の後の予測発電量から、太陽光で賄える分を差し引いた目標の蓄電残量を計算します。
+type chargePlanner struct {
+	cfg        config.ChargePlanningConfig
+	forecaster pvForecaster
+
+	// 予測を取得した充電時間帯の終了時刻 (同じ時間帯の間は予測を再取得しない)
+	windowEnd   time.Time
+	surplusKWh  float64
+	forecastOK  bool
+	lastAttempt time.Time
+}
+
+// newChargePlanner は、設定と発電量予測の取得元を指定して chargePlanner を作成します。
+func newChargePlanner(cfg config.ChargePlanningConfig, forecaster pvForecaster) *chargePlanner {
+	return &chargePlanner{cfg: cfg, forecaster: forecaster}
+}
+
+// targetSOCPercent は、充電時間帯 window の目標の蓄電残量 (%) を返します。
+// 充電時間帯の終了から forecast_until までの予測発電量から家庭の消費電力量の見込みを差し引いた余剰分を、
+// AC実効容量 (Wh) に対する割合として目標から差し引きます。予測を取得できない場合は目標をそのまま返します。
+func (p *chargePlanner) targetSOCPercent(now time.Time, window config.ChargeWindow, acCapacity uint32) int {
+	if !p.cfg.Enabled || acCapacity == 0 {
+		return window.TargetSOCPercent
+	}
+	end := window.End(now)
+	if !end.Equal(p.windowEnd) || (!p.forecastOK && now.Sub(p.lastAttempt) >= forecastRetryInterval) {
+		p.windowEnd = end
+		p.lastAttempt = now
+		until := config.NextOccurrence(end, p.cfg.ForecastUntil)
+		kwh, err := p.forecaster.forecastPVKWh(end, until)
+		if err != nil {
+			log.Printf("[充電計画] 充電時間帯の後の発電量予測の取得に失敗しました: %v", err)
+			p.forecastOK = false
+		} else {
+			p.surplusKWh = kwh - p.cfg.DaytimeConsumptionKWh
+			p.forecastOK = true
+			log.Printf("[充電計画] %s から %s の予測発電量: %.2f kWh (消費見込みを除いた余剰: %.2f kWh)", end.Format("01/02 15:04"), until.Format("01/02 15:04"), kwh, p.surplusKWh)
+		}
+	}
+	if !p.forecastOK || p.surplusKWh <= 0 {
+		return window.TargetSOCPercent
+	}
+
+	target := window.TargetSOCPercent - int(p.surplusKWh*1000/float64(acCapacity)*100)
+	if target < p.cfg.MinTargetSOCPercent {
+		target = p.cfg.MinTargetSOCPercent
+	}
+	if target > window.TargetSOCPercent {
+		target = window.TargetSOCPercent
+	}
+	return target
+}
@@ -0,0 +1,42 @@
+package monitor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+func TestChargePlannerLowersTargetByForecastSurplus(t *testing.T) {
+	cfg := config.ChargePlanningConfig{Enabled: true, DaytimeConsumptionKWh: 2, MinTargetSOCPercent: 30}
+	if err := cfg.Validate(config.ForecastConfig{PanelKW: 5}); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	f := &fakeForecaster{kwh: 5}
+	p := newChargePlanner(cfg, f)
+	window := config.ChargeWindow{TimeSlot: config.TimeSlot{StartTime: "23:00", EndTime: "07:00"}, TargetSOCPercent: 100}
+	night := time.Date(2025, 6, 1, 23, 30, 0, 0, time.Local)
+
+	// 5 kWh forecast - 2 kWh consumption = 3 kWh surplus = 30% of 10 kWh.
+	if got := p.targetSOCPercent(night, window, 10000); got != 70 {
+		t.Errorf("targetSOCPercent = %d, want 70", got)
+	}
+	// The forecast is fetched once per charging window.
+	p.targetSOCPercent(night.Add(time.Hour), window, 10000)
+	if f.calls != 1 {
+		t.Errorf("forecast fetched %d times, want 1", f.calls)
+	}
+
+	// A large surplus is limited by the minimum target.
+	f.kwh = 20
+	if got := p.targetSOCPercent(night.AddDate(0, 0, 1), window, 10000); got != 30 {
+		t.Errorf("targetSOCPercent = %d, want 30", got)
+	}
+
+	// Without a forecast the configured target is kept.
+	f.err = errors.New("unavailable")
+	if got := p.targetSOCPercent(night.AddDate(0, 0, 2), window, 10000); got != 100 {
+		t.Errorf("targetSOCPercent on forecast error = %d, want 100", got)
+	}
+}
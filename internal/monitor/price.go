@@ -0,0 +1,140 @@
+package monitor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// 単価表の1コマ (30分) の長さ
+const priceBlockDuration = 30 * time.Minute
+
+// parsePriceTable は、単価表の CSV を読み込み、各コマの開始時刻と単価の対応を返します。
+// 1列目は受渡日 (YYYY/MM/DD または YYYY-MM-DD)、2列目は時刻コード (1〜48, 1 = 0:00〜0:30) です。
+// JEPX の CSV の見出し行のように、受渡日や単価として解釈できない行は読み飛ばします。
+func parsePriceTable(r io.Reader, priceColumn int, loc *time.Location) (map[time.Time]float64, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	prices := make(map[time.Time]float64)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("単価表の読み込みに失敗しました: %w", err)
+		}
+		if len(record) <= priceColumn {
+			continue
+		}
+		date, err := time.ParseInLocation("2006/01/02", strings.ReplaceAll(strings.TrimSpace(record[0]), "-", "/"), loc)
+		if err != nil {
+			continue
+		}
+		slot, err := strconv.Atoi(strings.TrimSpace(record[1]))
+		if err != nil || slot < 1 || slot > 48 {
+			continue
+		}
+		price, err := strconv.ParseFloat(strings.TrimSpace(record[priceColumn]), 64)
+		if err != nil {
+			continue
+		}
+		start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc).Add(time.Duration(slot-1) * priceBlockDuration)
+		prices[start] = price
+	}
+	if len(prices) == 0 {
+		return nil, fmt.Errorf("単価表に有効な行がありません")
+	}
+	return prices, nil
+}
+
+// priceSchedule は、単価表を保持し、充電に使用する安いコマを選びます。
+// 単価表のファイルが更新された場合は、次の参照時に読み込み直します。
+type priceSchedule struct {
+	cfg config.PriceScheduleConfig
+	loc *time.Location // 単価表の日付と時刻を解釈するタイムゾーン
+
+	modTime time.Time
+	prices  map[time.Time]float64
+}
+
+// newPriceSchedule は、設定に基づいて priceSchedule を作成します。単価表の日付と時刻は loc のタイムゾーンで解釈します。
+func newPriceSchedule(cfg config.PriceScheduleConfig, loc *time.Location) *priceSchedule {
+	return &priceSchedule{cfg: cfg, loc: loc}
+}
+
+// reload は、単価表のファイルが前回の読み込みから更新されていれば読み込み直します。
+func (s *priceSchedule) reload() {
+	info, err := os.Stat(s.cfg.File)
+	if err != nil {
+		log.Printf("[単価] 単価表 '%s' を確認できませんでした: %v", s.cfg.File, err)
+		return
+	}
+	if s.prices != nil && info.ModTime().Equal(s.modTime) {
+		return
+	}
+	f, err := os.Open(s.cfg.File)
+	if err != nil {
+		log.Printf("[単価] 単価表 '%s' を開けませんでした: %v", s.cfg.File, err)
+		return
+	}
+	defer f.Close()
+	prices, err := parsePriceTable(f, s.cfg.PriceColumn, s.loc)
+	if err != nil {
+		log.Printf("[単価] 単価表 '%s' の読み込みに失敗しました: %v", s.cfg.File, err)
+		return
+	}
+	s.prices = prices
+	s.modTime = info.ModTime()
+	log.Printf("[単価] 単価表 '%s' を読み込みました (%d コマ)", s.cfg.File, len(prices))
+}
+
+// blockStart は、t を含むコマの開始時刻を返します。
+func blockStart(t time.Time) time.Time {
+	y, m, d := t.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	return midnight.Add(t.Sub(midnight) / priceBlockDuration * priceBlockDuration)
+}
+
+// cheapBlock は、現在のコマが [now, end) の期間のうち単価の安い順に needed コマに含まれるかを返します。
+// 期間内のすべてのコマの単価が単価表にない場合は、ok = false を返します (単価による選択を行わない)。
+func (s *priceSchedule) cheapBlock(now, end time.Time, needed int) (cheap bool, ok bool) {
+	if !s.cfg.Enabled {
+		return false, false
+	}
+	s.reload()
+	if s.prices == nil {
+		return false, false
+	}
+
+	current := blockStart(now)
+	var blocks []time.Time
+	for b := current; b.Before(end); b = b.Add(priceBlockDuration) {
+		if _, found := s.prices[b]; !found {
+			return false, false
+		}
+		blocks = append(blocks, b)
+	}
+	if len(blocks) == 0 {
+		return false, false
+	}
+	// 単価が同じ場合は早いコマを優先する
+	sort.SliceStable(blocks, func(i, j int) bool { return s.prices[blocks[i]] < s.prices[blocks[j]] })
+	if needed > len(blocks) {
+		needed = len(blocks)
+	}
+	for _, b := range blocks[:needed] {
+		if b.Equal(current) {
+			return true, true
+		}
+	}
+	return false, true
+}
@@ -0,0 +1,67 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+const testPriceCSV = `受渡日,時刻コード,売り入札量(kWh),買い入札量(kWh),約定総量(kWh),システムプライス(円/kWh)
+2025/06/01,47,0,0,0,12.5
+2025/06/01,48,0,0,0,9.0
+2025/06/02,1,0,0,0,8.0
+2025/06/02,2,0,0,0,10.0
+`
+
+func TestParsePriceTable(t *testing.T) {
+	prices, err := parsePriceTable(strings.NewReader(testPriceCSV), 5, time.UTC)
+	if err != nil {
+		t.Fatalf("parsePriceTable: %v", err)
+	}
+	if len(prices) != 4 {
+		t.Fatalf("got %d blocks, want 4", len(prices))
+	}
+	if p := prices[time.Date(2025, 6, 1, 23, 30, 0, 0, time.UTC)]; p != 9.0 {
+		t.Errorf("price of 23:30 = %v, want 9.0", p)
+	}
+	if _, err := parsePriceTable(strings.NewReader("header only\n"), 5, time.UTC); err == nil {
+		t.Errorf("expected error for a table without prices")
+	}
+}
+
+func TestPriceScheduleCheapBlock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.csv")
+	if err := os.WriteFile(path, []byte(testPriceCSV), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.PriceScheduleConfig{Enabled: true, File: path}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	s := newPriceSchedule(cfg, time.Local)
+	end := time.Date(2025, 6, 2, 1, 0, 0, 0, time.Local)
+
+	// The two cheapest blocks before 01:00 are 23:30 (9.0) and 00:00 (8.0).
+	for _, tc := range []struct {
+		now   time.Time
+		cheap bool
+	}{
+		{time.Date(2025, 6, 1, 23, 10, 0, 0, time.Local), false},
+		{time.Date(2025, 6, 1, 23, 45, 0, 0, time.Local), true},
+		{time.Date(2025, 6, 2, 0, 20, 0, 0, time.Local), true},
+	} {
+		cheap, ok := s.cheapBlock(tc.now, end, 2)
+		if !ok || cheap != tc.cheap {
+			t.Errorf("cheapBlock(%s) = (%t, %t), want (%t, true)", tc.now.Format("15:04"), cheap, ok, tc.cheap)
+		}
+	}
+
+	// Blocks without a price fall back to the normal control.
+	if _, ok := s.cheapBlock(time.Date(2025, 6, 1, 22, 0, 0, 0, time.Local), end, 2); ok {
+		t.Errorf("expected no price schedule when the table does not cover the window")
+	}
+}
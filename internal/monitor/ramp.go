@@ -0,0 +1,21 @@
+package monitor
+
+// rampChargePower は、現在の充電電力設定値 current (W) から目標 target (W) へ向けて、
+// 1回の更新で変更する充電電力を返します。
+// gain (0 < gain <= 1) を指定した場合は差分の gain 倍だけ変更し (比例制御)、
+// rampWatts (> 0) を指定した場合は1回の変更量を ±rampWatts に制限します。
+// 雲の通過などで余剰電力が急変した場合に、充電電力が大きく振動するのを抑えます。
+func rampChargePower(current, target, rampWatts int, gain float64) int {
+	delta := target - current
+	if gain > 0 && gain < 1 {
+		delta = int(float64(delta) * gain)
+	}
+	if rampWatts > 0 {
+		if delta > rampWatts {
+			delta = rampWatts
+		} else if delta < -rampWatts {
+			delta = -rampWatts
+		}
+	}
+	return current + delta
+}
@@ -0,0 +1,22 @@
+package monitor
+
+import "testing"
+
+func TestRampChargePower(t *testing.T) {
+	for _, tc := range []struct {
+		current, target, ramp int
+		gain                  float64
+		want                  int
+	}{
+		{1000, 3000, 0, 0, 3000},   // disabled: jump to the target
+		{1000, 3000, 500, 0, 1500}, // ramp limits the increase
+		{3000, 1000, 500, 0, 2500}, // ramp limits the decrease
+		{1000, 3000, 0, 0.5, 2000}, // proportional step
+		{1000, 3000, 300, 0.5, 1300},
+		{1000, 1200, 500, 0, 1200}, // small changes reach the target
+	} {
+		if got := rampChargePower(tc.current, tc.target, tc.ramp, tc.gain); got != tc.want {
+			t.Errorf("rampChargePower(%d, %d, %d, %v) = %d, want %d", tc.current, tc.target, tc.ramp, tc.gain, got, tc.want)
+		}
+	}
+}
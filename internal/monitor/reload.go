@@ -0,0 +1,127 @@
+package monitor
+
+import (
+	"log"
+	"reflect"
+	"strings"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// ReloadConfig は、設定ファイルを読み込み直し、新しい設定に切り替えます。dryRun はコマンドライン引数の -dry-run です。
+// 読み込みや検証に失敗した場合は、現在の設定のまま監視を続けます。
+// 監視サイクルの間に呼び出すため、サイクルの途中で設定が変わることはありません。
+func (m *Monitor) ReloadConfig(filePath string, dryRun bool) {
+	cfg, err := config.Load(filePath)
+	m.health.ConfigLoaded(err)
+	if err != nil {
+		log.Printf("[設定] 設定ファイルを読み込み直せませんでした。現在の設定で監視を続けます: %v", err)
+		return
+	}
+	if dryRun {
+		cfg.DryRun = true // コマンドライン引数は設定ファイルより優先する
+	}
+	m.applyConfig(cfg)
+	log.Printf("[設定] 設定ファイル '%s' を読み込み直しました。", filePath)
+
+	// 新しい設定で有効にした機能が機器の対応していないものでないか確認し直す
+	if err := m.CheckCapabilities(); err != nil {
+		log.Printf("[機能確認] %v", err)
+	}
+}
+
+// applyConfig は、監視サイクルで参照する設定を cfg に切り替えます。
+// 閾値や時間帯などは次の監視サイクルから反映し、運転モードの変更時刻や充電電力の引き上げ時刻などの状態は維持します。
+// 監視対象の機器、通信、監視ループの設定は起動時にのみ反映されるため、変更されていても現在の値を維持します。
+func (m *Monitor) applyConfig(cfg *config.Config) {
+	old := m.cfg
+	if old.DiscoverTargets && cfg.DiscoverTargets {
+		cfg.BatteryInstances = old.BatteryInstances // 起動時にインスタンスリストから決めた蓄電池
+	}
+	cfg.DiscoverTargets, cfg.Instances = old.DiscoverTargets, old.Instances
+	cfg.Controller = old.Controller
+	cfg.Location = old.Location // 単価表や手動操作のソケットが起動時のタイムゾーンを参照しているため
+	cfg.Clock = old.Clock       // 制御の状態機械と同じ時計を使い続ける
+
+	var restart []string
+	keepSetting(&restart, "target_ip", old.TargetIP, &cfg.TargetIP)
+	keepSetting(&restart, "target_id", old.TargetID, &cfg.TargetID)
+	keepSetting(&restart, "controller_instance", old.ControllerInstance, &cfg.ControllerInstance)
+	keepSetting(&restart, "controller_eoj", old.ControllerEOJ, &cfg.ControllerEOJ)
+	keepSetting(&restart, "timezone", old.Timezone, &cfg.Timezone)
+	keepSetting(&restart, "monitor_interval_seconds", old.MonitorIntervalSeconds, &cfg.MonitorIntervalSeconds)
+	keepSetting(&restart, "battery_instances", old.BatteryInstances, &cfg.BatteryInstances)
+	keepSetting(&restart, "targets", old.Targets, &cfg.Targets)
+	keepSetting(&restart, "devices", old.Devices, &cfg.Devices)
+	keepSetting(&restart, "epc_overrides", old.EPCOverrides, &cfg.EPCOverrides)
+	keepSetting(&restart, "enable_control", old.EnableControl, &cfg.EnableControl)
+	keepSetting(&restart, "dry_run", old.DryRun, &cfg.DryRun)
+	keepSetting(&restart, "shutdown_operation_mode", old.ShutdownOperationMode, &cfg.ShutdownOperationMode)
+	keepSetting(&restart, "loop_stall_timeout_seconds", old.LoopStallTimeoutSeconds, &cfg.LoopStallTimeoutSeconds)
+	keepSetting(&restart, "receive_buffer_size", old.ReceiveBufferSize, &cfg.ReceiveBufferSize)
+	keepSetting(&restart, "response_timeout_milliseconds", old.ResponseTimeoutMilliseconds, &cfg.ResponseTimeoutMilliseconds)
+	keepSetting(&restart, "request_retries", old.RequestRetries, &cfg.RequestRetries)
+	keepSetting(&restart, "retry_backoff_milliseconds", old.RetryBackoffMilliseconds, &cfg.RetryBackoffMilliseconds)
+	keepSetting(&restart, "liveness_check_interval_seconds", old.LivenessCheckIntervalSeconds, &cfg.LivenessCheckIntervalSeconds)
+	keepSetting(&restart, "unreachable_failure_threshold", old.UnreachableFailureThreshold, &cfg.UnreachableFailureThreshold)
+	keepSetting(&restart, "notification_dedup_window_seconds", old.NotificationDedupWindowSeconds, &cfg.NotificationDedupWindowSeconds)
+	keepSetting(&restart, "notification_mode", old.NotificationMode, &cfg.NotificationMode)
+	keepSetting(&restart, "energy_counters", old.EnergyCounters, &cfg.EnergyCounters)
+	keepSetting(&restart, "forecast", old.Forecast, &cfg.Forecast)
+	keepSetting(&restart, "capture", old.Capture, &cfg.Capture)
+	keepSetting(&restart, "log_file", old.LogFile, &cfg.LogFile)
+	keepSetting(&restart, "audit_log", old.AuditLog, &cfg.AuditLog)
+	keepSetting(&restart, "history", old.History, &cfg.History)
+	keepSetting(&restart, "postgres", old.Postgres, &cfg.Postgres)
+	keepSetting(&restart, "mqtt", old.MQTT, &cfg.MQTT)
+	keepSetting(&restart, "log_monitoring_data", old.LogMonitoringData, &cfg.LogMonitoringData)
+	keepSetting(&restart, "monitoring_csv_dir", old.MonitoringCSVDir, &cfg.MonitoringCSVDir)
+	keepSetting(&restart, "override", old.Override, &cfg.Override)
+	keepSetting(&restart, "http_api", old.HTTPAPI, &cfg.HTTPAPI)
+	keepSetting(&restart, "alerts", old.Alerts, &cfg.Alerts)
+	keepSetting(&restart, "email_report", old.EmailReport, &cfg.EmailReport)
+	keepSetting(&restart, "modbus", old.Modbus, &cfg.Modbus)
+	keepSetting(&restart, "archive_upload", old.ArchiveUpload, &cfg.ArchiveUpload)
+	keepSetting(&restart, "google_sheets", old.GoogleSheets, &cfg.GoogleSheets)
+	keepSetting(&restart, "state_webhooks", old.StateWebhooks, &cfg.StateWebhooks)
+	keepSetting(&restart, "smart_meter", old.SmartMeter, &cfg.SmartMeter)
+	keepSetting(&restart, "ev_charger.enabled", old.EVCharger.Enabled, &cfg.EVCharger.Enabled)
+	keepSetting(&restart, "ev_charger.bidirectional", old.EVCharger.Bidirectional, &cfg.EVCharger.Bidirectional)
+	keepSetting(&restart, "ev_charger.instance", old.EVCharger.Instance, &cfg.EVCharger.Instance)
+	keepSetting(&restart, "surplus_diversion.enabled", old.SurplusDiversion.Enabled, &cfg.SurplusDiversion.Enabled)
+	keepSetting(&restart, "surplus_diversion.loads", old.SurplusDiversion.Loads, &cfg.SurplusDiversion.Loads)
+	keepSetting(&restart, "demand_response.enabled", old.DemandResponse.Enabled, &cfg.DemandResponse.Enabled)
+	keepSetting(&restart, "demand_response.air_conditioners", old.DemandResponse.AirConditioners, &cfg.DemandResponse.AirConditioners)
+	keepSetting(&restart, "openadr.enabled", old.OpenADR.Enabled, &cfg.OpenADR.Enabled)
+	keepSetting(&restart, "openadr.vtn_url", old.OpenADR.VTNURL, &cfg.OpenADR.VTNURL)
+	keepSetting(&restart, "openadr.ven_id", old.OpenADR.VENID, &cfg.OpenADR.VENID)
+	keepSetting(&restart, "openadr.ca_file", old.OpenADR.CAFile, &cfg.OpenADR.CAFile)
+	keepSetting(&restart, "openadr.cert_file", old.OpenADR.CertFile, &cfg.OpenADR.CertFile)
+	keepSetting(&restart, "openadr.key_file", old.OpenADR.KeyFile, &cfg.OpenADR.KeyFile)
+	if len(restart) > 0 {
+		log.Printf("[設定] 次の設定の変更は再起動後に反映されます: %s", strings.Join(restart, ", "))
+	}
+
+	m.cfg = cfg
+	m.controller.SetConfig(cfg.ControllerConfig())
+	m.evening.cfg = cfg.EveningReserve
+	m.planner.cfg = cfg.ChargePlanning
+	m.storm.cfg = cfg.StormAlert
+	m.openADR.cfg = cfg.OpenADR
+	m.gridBudget.limitWh, m.gridBudget.rolloverHour = cfg.MaxDailyGridChargeWh, cfg.GridChargeRolloverHour
+	if !reflect.DeepEqual(old.PriceSchedule, cfg.PriceSchedule) {
+		m.prices = newPriceSchedule(cfg.PriceSchedule, cfg.Loc())
+	}
+	if old.SurplusSmoothingSamples != cfg.SurplusSmoothingSamples || old.SurplusSmoothingAlpha != cfg.SurplusSmoothingAlpha {
+		m.smoother = newSurplusSmoother(cfg.SurplusSmoothingSamples, cfg.SurplusSmoothingAlpha) // 平滑化の方法が変わるため、サンプルは引き継がない
+	}
+}
+
+// keepSetting は、起動時にのみ反映される設定 v が変更されていれば現在の値 current に戻し、設定名 name を changed に追加します。
+func keepSetting[T any](changed *[]string, name string, current T, v *T) {
+	if reflect.DeepEqual(current, *v) {
+		return
+	}
+	*changed = append(*changed, name)
+	*v = current
+}
@@ -0,0 +1,56 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestApplyConfigKeepsState(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	now := monitortest.Now
+	m := newTestMonitor(device, now.Add(-time.Hour), now.Add(time.Hour))
+	m.controller.ModeChanged()
+	increased := now.Add(-time.Minute)
+	m.lastChargePowerIncreaseTime = increased
+
+	cfg := *m.cfg
+	cfg.TargetIP = "192.168.0.99"
+	cfg.MaxChargePowerWatts = 3000
+	cfg.ModeChangeInhibitMinutes = 5
+	cfg.ChargeStartTime = "08:00"
+	m.applyConfig(&cfg)
+
+	if m.cfg.MaxChargePowerWatts != 3000 || m.cfg.ChargeStartTime != "08:00" {
+		t.Errorf("cfg = %+v, want the new limits and schedule", m.cfg)
+	}
+	if m.cfg.TargetIP != "192.168.0.10" {
+		t.Errorf("TargetIP = %s, want the address used at startup", m.cfg.TargetIP)
+	}
+	if got := m.controller.InhibitRemaining(); got <= 0 || got > 5*time.Minute {
+		t.Errorf("InhibitRemaining() = %s, want the mode change kept with the new inhibit time", got)
+	}
+	if !m.lastChargePowerIncreaseTime.Equal(increased) {
+		t.Errorf("lastChargePowerIncreaseTime = %s, want %s", m.lastChargePowerIncreaseTime, increased)
+	}
+}
+
+func TestReloadConfigKeepsCurrentOnError(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	now := monitortest.Now
+	m := newTestMonitor(device, now.Add(-time.Hour), now.Add(time.Hour))
+	cfg := m.cfg
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(`monitor_interval_seconds = 5`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m.ReloadConfig(path, false)
+
+	if m.cfg != cfg {
+		t.Error("cfg replaced by an invalid config file")
+	}
+}
@@ -0,0 +1,84 @@
+package monitor
+
+import (
+	"log"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// reservePercentForForecast は、翌朝の予測発電量 (kWh) からリザーブ (%) を計算します。
+// 予測発電量が 0 のときは最大リザーブ、SunnyMorningKWh 以上のときは最小リザーブとし、その間は線形に補間します。
+func reservePercentForForecast(cfg config.EveningReserveConfig, morningKWh float64) int {
+	ratio := morningKWh / cfg.SunnyMorningKWh
+	if ratio < 0 {
+		ratio = 0
+	} else if ratio > 1 {
+		ratio = 1
+	}
+	span := float64(cfg.MaxReservePercent - cfg.MinReservePercent)
+	return cfg.MaxReservePercent - int(span*ratio+0.5)
+}
+
+// 発電量予測の取得に失敗した場合に再取得を試みる間隔
+const forecastRetryInterval = 30 * time.Minute
+
+// eveningReserve は、翌朝の発電量予測を1日1回取得し、夕方の放電時間帯のリザーブを決定します。
+type eveningReserve struct {
+	cfg        config.EveningReserveConfig
+	forecaster pvForecaster
+
+	forecastDay string  // 予測を取得した日 (YYYY-MM-DD)
+	morningKWh  float64 // 翌朝の予測発電量 (kWh)
+	forecastOK  bool    // 予測の取得に成功したかどうか
+	lastAttempt time.Time
+}
+
+// newEveningReserve は、設定と発電量予測の取得元を指定して eveningReserve を作成します。
+func newEveningReserve(cfg config.EveningReserveConfig, forecaster pvForecaster) *eveningReserve {
+	return &eveningReserve{cfg: cfg, forecaster: forecaster}
+}
+
+// active は、現在時刻が夕方の放電時間帯内であれば true を返します。
+func (r *eveningReserve) active(now time.Time) bool {
+	if !r.cfg.Enabled {
+		return false
+	}
+	ok, err := config.IsChargingTime(now, r.cfg.StartTime, r.cfg.EndTime)
+	return err == nil && ok
+}
+
+// reservePercent は、現在の夕方の放電時間帯に適用するリザーブ (%) を返します。
+// 翌朝の発電量予測は1日1回だけ取得します。取得に失敗した場合は最大リザーブを返し、
+// forecastRetryInterval ごとに再取得を試みます。
+func (r *eveningReserve) reservePercent(now time.Time) int {
+	today := now.Format("2006-01-02")
+	if r.forecastDay != today || (!r.forecastOK && now.Sub(r.lastAttempt) >= forecastRetryInterval) {
+		r.forecastDay = today
+		r.lastAttempt = now
+		from, to := nextMorning(now, r.cfg.MorningEndTime)
+		kwh, err := r.forecaster.forecastPVKWh(from, to)
+		if err != nil {
+			log.Printf("[リザーブ] 翌朝の発電量予測の取得に失敗しました: %v", err)
+			r.forecastOK = false
+		} else {
+			r.morningKWh = kwh
+			r.forecastOK = true
+			log.Printf("[リザーブ] 翌朝 (%s まで) の予測発電量: %.2f kWh", to.Format("01/02 15:04"), kwh)
+		}
+	}
+	if !r.forecastOK {
+		log.Printf("[リザーブ] 翌朝の発電量予測がないため、最大リザーブ (%d%%) を使用します。", r.cfg.MaxReservePercent)
+		return r.cfg.MaxReservePercent
+	}
+	return reservePercentForForecast(r.cfg, r.morningKWh)
+}
+
+// nextMorning は、翌日の 0:00 から morningEnd (HH:MM) までの期間を返します。
+func nextMorning(now time.Time, morningEnd string) (time.Time, time.Time) {
+	y, m, d := now.Date()
+	from := time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+	end, _ := time.Parse("15:04", morningEnd)
+	to := from.Add(time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute)
+	return from, to
+}
@@ -0,0 +1,149 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+type fakeForecaster struct {
+	kwh   float64
+	err   error
+	calls int
+}
+
+func (f *fakeForecaster) forecastPVKWh(from, to time.Time) (float64, error) {
+	f.calls++
+	return f.kwh, f.err
+}
+
+func testReserveConfig() config.EveningReserveConfig {
+	cfg := config.EveningReserveConfig{Enabled: true, MinReservePercent: 20, MaxReservePercent: 60}
+	if err := cfg.Validate(config.ForecastConfig{PanelKW: 5}); err != nil {
+		panic(err)
+	}
+	return cfg
+}
+
+func TestReservePercentForForecast(t *testing.T) {
+	cfg := testReserveConfig() // sunny_morning_kwh defaults to 5.0
+	cases := []struct {
+		kwh  float64
+		want int
+	}{
+		{0, 60},
+		{2.5, 40},
+		{5, 20},
+		{12, 20},
+		{-1, 60},
+	}
+	for _, c := range cases {
+		if got := reservePercentForForecast(cfg, c.kwh); got != c.want {
+			t.Errorf("reservePercentForForecast(%.1f) = %d, want %d", c.kwh, got, c.want)
+		}
+	}
+}
+
+func TestEveningReserveFetchesForecastOncePerDay(t *testing.T) {
+	f := &fakeForecaster{kwh: 5}
+	r := newEveningReserve(testReserveConfig(), f)
+	evening := time.Date(2025, 6, 1, 18, 0, 0, 0, time.Local)
+
+	if !r.active(evening) || r.active(evening.Add(-3*time.Hour)) {
+		t.Fatalf("active() does not follow the 17:00-23:00 window")
+	}
+	if got := r.reservePercent(evening); got != 20 {
+		t.Errorf("sunny morning: got reserve %d, want 20", got)
+	}
+	r.reservePercent(evening.Add(time.Hour))
+	if f.calls != 1 {
+		t.Errorf("forecast fetched %d times on the same day, want 1", f.calls)
+	}
+	f.kwh = 0
+	if got := r.reservePercent(evening.Add(24 * time.Hour)); got != 60 || f.calls != 2 {
+		t.Errorf("next day: got reserve %d after %d calls, want 60 after 2", got, f.calls)
+	}
+}
+
+func TestEveningReserveFallsBackToMaxOnError(t *testing.T) {
+	f := &fakeForecaster{err: errors.New("network down")}
+	r := newEveningReserve(testReserveConfig(), f)
+	evening := time.Date(2025, 6, 1, 18, 0, 0, 0, time.Local)
+
+	if got := r.reservePercent(evening); got != 60 {
+		t.Errorf("got reserve %d on forecast error, want max 60", got)
+	}
+	r.reservePercent(evening.Add(time.Minute))
+	if f.calls != 1 {
+		t.Errorf("forecast retried too early (%d calls)", f.calls)
+	}
+	f.err, f.kwh = nil, 5
+	if got := r.reservePercent(evening.Add(forecastRetryInterval)); got != 20 || f.calls != 2 {
+		t.Errorf("after retry interval: got reserve %d after %d calls, want 20 after 2", got, f.calls)
+	}
+}
+
+func TestOpenMeteoForecaster(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("hourly") != "global_tilted_irradiance" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `{"hourly":{"time":["2025-06-02T00:00","2025-06-02T01:00","2025-06-02T02:00","2025-06-02T03:00"],
+			"global_tilted_irradiance":[100,500,null,800]}}`)
+	}))
+	defer srv.Close()
+
+	f := newOpenMeteoForecaster(config.ForecastConfig{PanelKW: 4, PerformanceRatio: 0.5})
+	f.baseURL = srv.URL
+	from := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+	kwh, err := f.forecastPVKWh(from, from.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("forecastPVKWh: %v", err)
+	}
+	// Only the 01:00 (500 W/m2) and 02:00 (null) slots fall in (from, to]: 0.5 * 4 * 0.5 = 1.0 kWh
+	if kwh < 0.999 || kwh > 1.001 {
+		t.Errorf("got %.3f kWh, want 1.0", kwh)
+	}
+
+	if _, err := f.forecastPVKWh(from.Add(48*time.Hour), from.Add(50*time.Hour)); err == nil {
+		t.Errorf("expected error when the forecast does not cover the requested period")
+	}
+}
+
+func TestSolcastForecaster(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.URL.Path != "/site-1/forecasts" {
+			t.Errorf("unexpected request: %s %s", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		fmt.Fprint(w, `{"forecasts":[
+			{"pv_estimate":2.0,"period_end":"2025-06-02T00:30:00.0000000Z","period":"PT30M"},
+			{"pv_estimate":4.0,"period_end":"2025-06-02T01:00:00.0000000Z","period":"PT30M"},
+			{"pv_estimate":6.0,"period_end":"2025-06-02T01:30:00.0000000Z","period":"PT30M"}]}`)
+	}))
+	defer srv.Close()
+
+	cfg := config.ForecastConfig{Provider: config.ForecastProviderSolcast, SolcastAPIKey: "secret", SolcastResourceID: "site-1"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	f := newSolcastForecaster(cfg)
+	f.baseURL = srv.URL + "/%s/forecasts"
+	from := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+	kwh, err := f.forecastPVKWh(from, from.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("forecastPVKWh: %v", err)
+	}
+	// (2.0 + 4.0) kW * 0.5 h = 3.0 kWh
+	if kwh < 2.999 || kwh > 3.001 {
+		t.Errorf("got %.3f kWh, want 3.0", kwh)
+	}
+
+	if err := (&config.ForecastConfig{Provider: config.ForecastProviderSolcast}).Validate(); err == nil {
+		t.Errorf("expected error without Solcast credentials")
+	}
+}
@@ -0,0 +1,138 @@
+package monitor
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+// scenarioClock is a Clock that only moves when the scenario runner advances it.
+type scenarioClock struct{ now time.Time }
+
+func (c *scenarioClock) Now() time.Time { return c.now }
+
+// scenarioStep is one monitor cycle of a scenario: the simulated time of the cycle,
+// the device values the cycle reads, and the battery settings expected after the cycle.
+type scenarioStep struct {
+	at        string // HH:MM on the scenario day
+	pvWatts   int    // PV generation
+	loadWatts int    // household load measured by the distribution board
+	soc       byte   // state of charge; 0 keeps the previous value
+
+	wantMode        byte // expected operation mode (EPC 0xDA) after the cycle
+	wantChargeWatts int  // expected charge power setting (EPC 0xEB) after the cycle; -1 does not check it
+}
+
+// scenarioLocation is a fixed zone so that scenarios do not depend on the tzdata of the host.
+var scenarioLocation = time.FixedZone("JST", 9*60*60)
+
+// runScenario runs one monitor cycle per step against a fake EIBS7, advancing the simulated clock
+// to each step's time, and reports every step whose battery settings differ from the expectation.
+func runScenario(t *testing.T, day time.Time, steps []scenarioStep, opts ...func(*config.Config)) {
+	t.Helper()
+	device := monitortest.NewFakeEIBS7()
+	clock := &scenarioClock{}
+	opts = append([]func(*config.Config){func(cfg *config.Config) {
+		cfg.Clock = clock
+		cfg.Location = scenarioLocation
+	}}, opts...)
+	m := newTestMonitor(device, day, day, opts...)
+	battery := echonetlite.NewEOJ(0x02, 0x7D, 0x01)
+
+	for _, step := range steps {
+		at, err := time.ParseInLocation("15:04", step.at, scenarioLocation)
+		if err != nil {
+			t.Fatalf("step %s: %v", step.at, err)
+		}
+		clock.now = time.Date(day.Year(), day.Month(), day.Day(), at.Hour(), at.Minute(), 0, 0, scenarioLocation)
+		device.Props[echonetlite.NewEOJ(0x02, 0x79, 0x01)][0xE0] = binary.BigEndian.AppendUint16(nil, uint16(step.pvWatts))
+		device.Props[echonetlite.NewEOJ(0x02, 0x87, 0x01)][0xC6] = binary.BigEndian.AppendUint32(nil, uint32(step.loadWatts))
+		if step.soc != 0 {
+			device.Props[battery][0xE4] = []byte{step.soc}
+		}
+
+		m.runCycle()
+
+		if mode := device.Props[battery][0xDA][0]; mode != step.wantMode {
+			t.Errorf("%s: operation mode = 0x%X, want 0x%X", step.at, mode, step.wantMode)
+		}
+		if step.wantChargeWatts >= 0 {
+			if watts := binary.BigEndian.Uint32(device.Props[battery][0xEB]); int(watts) != step.wantChargeWatts {
+				t.Errorf("%s: charge power = %d W, want %d W", step.at, watts, step.wantChargeWatts)
+			}
+		}
+	}
+}
+
+// scenarioWindow sets the charging window to start-end (HH:MM).
+func scenarioWindow(start, end string) func(*config.Config) {
+	return func(cfg *config.Config) {
+		cfg.ChargeStartTime, cfg.ChargeEndTime = start, end
+	}
+}
+
+// scenarioJudgment sets min_surplus_power_judgment_minutes, the window of the minimum surplus that caps the charge power.
+func scenarioJudgment(minutes int) func(*config.Config) {
+	return func(cfg *config.Config) {
+		cfg.MinSurplusPowerJudgmentMinutes = minutes
+	}
+}
+
+func TestScenarios(t *testing.T) {
+	monday := time.Date(2026, 6, 1, 0, 0, 0, 0, scenarioLocation)
+	for _, tc := range []struct {
+		name  string
+		day   time.Time
+		opts  []func(*config.Config)
+		steps []scenarioStep
+	}{
+		{
+			name: "full day with a midday charging window",
+			day:  monday,
+			opts: []func(*config.Config){scenarioWindow("10:00", "14:00"), scenarioJudgment(1)},
+			steps: []scenarioStep{
+				{at: "06:00", pvWatts: 200, loadWatts: 500, wantMode: 0x46, wantChargeWatts: 1000},
+				// 5000 Wh to the target SOC over the 240 minutes left in the window.
+				{at: "10:00", pvWatts: 3500, loadWatts: 500, wantMode: 0x42, wantChargeWatts: 1250},
+				{at: "10:01", pvWatts: 3500, loadWatts: 500, wantMode: 0x42, wantChargeWatts: 1250},
+				// Surplus falls: the charge power follows it down to surplus minus the margin.
+				{at: "11:00", pvWatts: 1500, loadWatts: 500, wantMode: 0x42, wantChargeWatts: 500},
+				// The target SOC is reached: target_reached_action "hold" keeps charge mode until the window ends.
+				{at: "13:00", pvWatts: 3500, loadWatts: 500, soc: 100, wantMode: 0x42, wantChargeWatts: -1},
+				{at: "14:00", pvWatts: 3000, loadWatts: 500, wantMode: 0x46, wantChargeWatts: -1},
+				{at: "20:00", pvWatts: 0, loadWatts: 800, wantMode: 0x46, wantChargeWatts: -1},
+			},
+		},
+		{
+			name: "charging window crossing midnight",
+			day:  monday,
+			opts: []func(*config.Config){scenarioWindow("23:00", "01:00")},
+			steps: []scenarioStep{
+				{at: "22:59", pvWatts: 3000, loadWatts: 500, wantMode: 0x46, wantChargeWatts: -1},
+				{at: "23:30", pvWatts: 3000, loadWatts: 500, wantMode: 0x42, wantChargeWatts: 2000},
+			},
+		},
+		{
+			name: "mode change inhibited after switching",
+			day:  monday,
+			opts: []func(*config.Config){scenarioWindow("10:00", "14:00"), scenarioJudgment(1)},
+			steps: []scenarioStep{
+				{at: "09:55", pvWatts: 3000, loadWatts: 500, wantMode: 0x46, wantChargeWatts: -1},
+				{at: "10:00", pvWatts: 3000, loadWatts: 500, wantMode: 0x42, wantChargeWatts: -1},
+				// Losing the surplus switches back to auto right away.
+				{at: "10:05", pvWatts: 0, loadWatts: 500, wantMode: 0x46, wantChargeWatts: -1},
+				// The surplus is back, but the mode was changed less than mode_change_inhibit_minutes ago.
+				{at: "10:10", pvWatts: 3000, loadWatts: 500, wantMode: 0x46, wantChargeWatts: -1},
+				{at: "10:16", pvWatts: 3000, loadWatts: 500, wantMode: 0x42, wantChargeWatts: -1},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			runScenario(t, tc.day, tc.steps, tc.opts...)
+		})
+	}
+}
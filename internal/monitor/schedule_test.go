@@ -0,0 +1,58 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestMonitorStopsChargingAtTargetSOC(t *testing.T) {
+	now := monitortest.Now
+	device := monitortest.NewFakeEIBS7()
+	battery := echonetlite.NewEOJ(0x02, 0x7D, 0x01)
+	device.Props[battery][0xE4] = []byte{80}
+	m := newTestMonitor(device, now.Add(-time.Hour), now.Add(time.Hour))
+	m.cfg.ChargeTargetSOCPercent = 80
+	m.cfg.TargetReachedAction = config.TargetReachedStop
+
+	m.runCycle()
+
+	if len(device.Sets) != 1 {
+		t.Fatalf("expected 1 SetC, got %d", len(device.Sets))
+	}
+	if p := device.Sets[0].Properties[0]; p.EPC != 0xDA || p.EDT[0] != 0x46 {
+		t.Errorf("SetC = EPC 0x%X EDT %X, want operation mode 0x46", p.EPC, p.EDT)
+	}
+
+	// Discharging below the target later in the window must not resume charging.
+	device.Props[battery][0xE4] = []byte{75}
+	m.runCycle()
+	if len(device.Sets) != 1 {
+		t.Errorf("expected no further SetC after stopping, got %d", len(device.Sets)-1)
+	}
+}
+
+func TestDailyRolloverAcrossDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("tzdata not available: %v", err)
+	}
+	b := newGridChargeBudget(10000, 6, time.Hour)
+
+	// 2025-03-09 is only 23 hours long in New York. The budget day must still roll over at 06:00 local time.
+	start := time.Date(2025, 3, 9, 5, 0, 0, 0, newYork) // 05:00 EST
+	b.add(start, 1000)
+	for now := start.Add(30 * time.Minute); now.Before(time.Date(2025, 3, 10, 5, 59, 0, 0, newYork)); now = now.Add(30 * time.Minute) {
+		b.add(now, 1000)
+	}
+	if b.usedWh < 500 {
+		t.Fatalf("expected charge to accumulate over the 23-hour budget day, got %.1f Wh", b.usedWh)
+	}
+	b.add(time.Date(2025, 3, 10, 6, 0, 0, 0, newYork), 1000)
+	if b.usedWh > 500.1 {
+		t.Errorf("budget should roll over at 06:00 EDT, used %.1f Wh", b.usedWh)
+	}
+}
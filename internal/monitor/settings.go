@@ -0,0 +1,10 @@
+package monitor
+
+import "kuramo.ch/eibs7-controller/echonetlite"
+
+// ECHONET Lite の標準ポート
+const echonetLitePort = echonetlite.Port
+
+// errResponseTruncated は、応答フレームが受信バッファに収まらなかったことを示します。
+// 呼び出し側は要求するプロパティを分割して再要求できます。
+var errResponseTruncated = echonetlite.ErrResponseTruncated
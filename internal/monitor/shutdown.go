@@ -0,0 +1,25 @@
+package monitor
+
+import (
+	"log"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// RestoreSafeOperationMode は、終了時に蓄電池を安全な運転モードに戻します。
+// 充電モードで大きな充電電力を設定したまま終了すると、系統から充電し続けるおそれがあるためです。
+func RestoreSafeOperationMode(client *EchonetClient, mode string) {
+	epc, ok := config.ShutdownOperationModes[mode]
+	if !ok {
+		log.Println("[制御] 終了時の運転モードの変更は無効です。蓄電池の設定は変更しません。")
+		return
+	}
+	if client.TargetIP == "" {
+		log.Println("[制御] 対象機器のアドレスが確定していないため、終了時の運転モードの変更をスキップします。")
+		return
+	}
+	log.Printf("[制御] 終了前に蓄電池の運転モードを「%s」に戻します。", mode)
+	if err := client.setBatteryOperationMode(epc); err != nil {
+		log.Printf("[制御] 終了時の運転モード設定に失敗しました: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestRestoreSafeOperationMode(t *testing.T) {
+	for _, tc := range []struct {
+		mode string
+		want []byte // EDT of the operation mode SetC, nil if none is sent
+	}{
+		{"auto", []byte{0x46}},
+		{"standby", []byte{0x44}},
+		{"none", nil},
+	} {
+		if err := config.ValidateShutdownOperationMode(tc.mode); err != nil {
+			t.Errorf("ValidateShutdownOperationMode(%q): %v", tc.mode, err)
+		}
+		device := monitortest.NewFakeEIBS7()
+		client := NewEchonetClient(echonetlite.NewFakeTransport(device.Handle), "192.168.0.10", time.Second)
+
+		RestoreSafeOperationMode(client, tc.mode)
+
+		if tc.want == nil {
+			if len(device.Sets) != 0 {
+				t.Errorf("%s: expected no SetC, got %d", tc.mode, len(device.Sets))
+			}
+			continue
+		}
+		if len(device.Sets) != 1 {
+			t.Fatalf("%s: expected 1 SetC, got %d", tc.mode, len(device.Sets))
+		}
+		p := device.Sets[0].Properties[0]
+		if p.EPC != 0xDA || string(p.EDT) != string(tc.want) {
+			t.Errorf("%s: SetC = EPC 0x%X EDT %X, want operation mode %X", tc.mode, p.EPC, p.EDT, tc.want)
+		}
+	}
+
+	if err := config.ValidateShutdownOperationMode("charge"); err == nil {
+		t.Error("charge mode accepted as a shutdown mode")
+	}
+}
+
+func TestReceiveNotificationsStopsOnShutdown(t *testing.T) {
+	client := NewEchonetClient(echonetlite.NewFakeTransport(nil), "192.168.0.10", time.Second)
+	done := make(chan struct{})
+	close(done)
+
+	start := time.Now()
+	if client.ReceiveNotifications(start.Add(time.Minute), done) {
+		t.Error("receiveNotifications reached the deadline after shutdown")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("receiveNotifications took %s to stop", elapsed)
+	}
+}
@@ -0,0 +1,87 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// smartMeterTarget は、スマートメーターの監視対象を返します。
+func smartMeterTarget(c config.SmartMeterConfig) MonitoringTarget {
+	eoj := echonetlite.NewEOJ(0x02, 0x88, byte(c.Instance))
+	return MonitoringTarget{
+		EOJ:        eoj,
+		EPCs:       []byte{0xE7, 0xE0, 0xE3, 0xE1}, // 瞬時電力計測値, 積算電力量計測値 (正方向, 逆方向), 積算電力量単位
+		SlowEPCs:   []byte{0xE1},                   // 積算電力量単位
+		ObjectName: fmt.Sprintf("低圧スマート電力量メータ (0288%02X)", c.Instance),
+	}
+}
+
+// cumulativeEnergyUnits は、積算電力量単位 (EPC 0xE1) の値と kWh への係数です。
+var cumulativeEnergyUnits = map[uint8]float64{
+	0x00: 1,
+	0x01: 0.1,
+	0x02: 0.01,
+	0x03: 0.001,
+	0x04: 0.0001,
+	0x0A: 10,
+	0x0B: 100,
+	0x0C: 1000,
+	0x0D: 10000,
+}
+
+// smartMeter は、スマートメーターの積算電力量から求めた当日の買電・売電電力量です。
+type smartMeter struct {
+	imported dailyCounter // 積算電力量 (正方向) から求めた当日の買電電力量
+	exported dailyCounter // 積算電力量 (逆方向) から求めた当日の売電電力量
+}
+
+// distributionBoardName は、住宅全体の買電・売電電力を計測する分電盤メータリング (役割が "meter" の最初の監視対象) の監視対象名を返します。
+func (m *Monitor) distributionBoardName() string {
+	for _, t := range m.targets {
+		if t.role == config.RoleMeter {
+			return t.ObjectName
+		}
+	}
+	return ""
+}
+
+// selectGridPower は、余剰電力と買電制限の判定に使用する買電電力 (W, 売電は負) を選び、"系統.瞬時電力計測値" のキーで monitoringData に格納します。
+// スマートメーターが有効で計測値を取得できた場合はそれを、それ以外の場合は分電盤メータリングの瞬時電力を使用します。
+// スマートメーターの積算電力量を取得できた場合は、当日の買電・売電電力量をログに出力します。
+func (m *Monitor) selectGridPower(monitoringData map[string]interface{}) {
+	board, boardOK := monitoringData[m.distributionBoardName()+".瞬時電力計測値"].(int32)
+	if !m.cfg.SmartMeter.Enabled {
+		if boardOK {
+			monitoringData["系統.瞬時電力計測値"] = board
+		}
+		return
+	}
+
+	name := smartMeterTarget(m.cfg.SmartMeter).ObjectName
+	if meter, ok := monitoringData[name+".瞬時電力計測値"].(int32); ok {
+		monitoringData["系統.瞬時電力計測値"] = meter
+		if boardOK {
+			log.Printf("[スマートメーター] 買電電力: %d W (分電盤メータリング: %d W)", meter, board)
+		} else {
+			log.Printf("[スマートメーター] 買電電力: %d W", meter)
+		}
+	} else if boardOK {
+		log.Printf("[スマートメーター] 瞬時電力が取得できなかったため、分電盤メータリングの値 (%d W) を使用します。", board)
+		monitoringData["系統.瞬時電力計測値"] = board
+	}
+
+	unit, uOK := monitoringData[name+".積算電力量単位（正方向、逆方向計測値）"].(uint8)
+	forward, fOK := monitoringData[name+".積算電力量計測値（正方向計測値）"].(uint32)
+	reverse, rOK := monitoringData[name+".積算電力量計測値（逆方向計測値）"].(uint32)
+	factor, known := cumulativeEnergyUnits[unit]
+	if !uOK || !fOK || !rOK || !known {
+		return
+	}
+	now := m.cfg.Now()
+	m.meter.imported.update(now, float64(forward)*factor)
+	m.meter.exported.update(now, float64(reverse)*factor)
+	log.Printf("[スマートメーター] 本日の買電電力量: %.2f kWh, 売電電力量: %.2f kWh", m.meter.imported.today, m.meter.exported.today)
+}
@@ -0,0 +1,61 @@
+package monitor
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestSelectGridPowerPrefersSmartMeter(t *testing.T) {
+	m := &Monitor{cfg: &config.Config{SmartMeter: config.SmartMeterConfig{Enabled: true, Instance: 1}}, targets: defaultMonitoringTargets(nil, defaultInstances)}
+	data := map[string]interface{}{
+		"分電盤メータリング (028701).瞬時電力計測値":                int32(200),
+		"低圧スマート電力量メータ (028801).瞬時電力計測値":             int32(350),
+		"低圧スマート電力量メータ (028801).積算電力量単位（正方向、逆方向計測値）": uint8(0x01),
+		"低圧スマート電力量メータ (028801).積算電力量計測値（正方向計測値）":    uint32(12345),
+		"低圧スマート電力量メータ (028801).積算電力量計測値（逆方向計測値）":    uint32(678),
+	}
+	m.selectGridPower(data)
+	if v := data["系統.瞬時電力計測値"]; v != int32(350) {
+		t.Errorf("grid power = %v, want the smart meter's 350 W", v)
+	}
+	if m.meter.imported.day == "" {
+		t.Error("cumulative counters were not recorded")
+	}
+
+	// Without a reading from the meter the distribution board value is used.
+	delete(data, "低圧スマート電力量メータ (028801).瞬時電力計測値")
+	m.selectGridPower(data)
+	if v := data["系統.瞬時電力計測値"]; v != int32(200) {
+		t.Errorf("grid power = %v, want the distribution board's 200 W", v)
+	}
+}
+
+func TestMonitorUsesSmartMeterForSurplus(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	device.Props[echonetlite.NewEOJ(0x02, 0x88, 0x01)] = map[byte][]byte{
+		0xE7: binary.BigEndian.AppendUint32(nil, 1000), // importing 1000 W
+		0xE0: binary.BigEndian.AppendUint32(nil, 0),
+		0xE3: binary.BigEndian.AppendUint32(nil, 0),
+		0xE1: {0x01},
+	}
+	now := monitortest.Now
+	m := newTestMonitor(device, now.Add(-time.Hour), now.Add(time.Hour), func(c *config.Config) {
+		c.SmartMeter = config.SmartMeterConfig{Enabled: true, Instance: 1}
+	})
+
+	m.runCycle()
+
+	// Surplus is 3000 - 1000 W from the meter (the board reads 0 W), so the cap is 2000-500 = 1500 W.
+	if len(device.Sets) != 1 {
+		t.Fatalf("expected 1 SetC, got %d", len(device.Sets))
+	}
+	p := device.Sets[0].Properties[0]
+	if p.EPC != 0xEB || binary.BigEndian.Uint32(p.EDT) != 1500 {
+		t.Errorf("SetC = EPC 0x%X EDT %X, want charge power 1500 W", p.EPC, p.EDT)
+	}
+}
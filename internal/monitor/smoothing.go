@@ -0,0 +1,45 @@
+package monitor
+
+// surplusSmoother は、余剰電力のサンプルを平滑化します。
+// alpha (0 < alpha < 1) を指定した場合は指数移動平均 (EMA)、それ以外は直近 window 個の単純移動平均を使用します。
+// 分電盤メータリングの一時的な計測値の揺れで運転モードが切り替わるのを防ぎます。
+type surplusSmoother struct {
+	window int
+	alpha  float64
+
+	samples []int32
+	ema     float64
+	primed  bool
+}
+
+// newSurplusSmoother は、移動平均のサンプル数と EMA の係数を指定して surplusSmoother を作成します。
+// window が 1 以下で alpha が 0 の場合は平滑化を行いません。
+func newSurplusSmoother(window int, alpha float64) *surplusSmoother {
+	if window < 1 {
+		window = 1
+	}
+	return &surplusSmoother{window: window, alpha: alpha}
+}
+
+// add は、サンプルを追加し、平滑化した余剰電力 (W) を返します。
+func (s *surplusSmoother) add(surplus int32) int32 {
+	if s.alpha > 0 && s.alpha < 1 {
+		if !s.primed {
+			s.ema = float64(surplus)
+			s.primed = true
+		} else {
+			s.ema = s.alpha*float64(surplus) + (1-s.alpha)*s.ema
+		}
+		return int32(s.ema)
+	}
+
+	s.samples = append(s.samples, surplus)
+	if len(s.samples) > s.window {
+		s.samples = s.samples[1:]
+	}
+	var sum int64
+	for _, v := range s.samples {
+		sum += int64(v)
+	}
+	return int32(sum / int64(len(s.samples)))
+}
@@ -0,0 +1,39 @@
+package monitor
+
+import "testing"
+
+func TestSurplusSmootherMovingAverage(t *testing.T) {
+	s := newSurplusSmoother(3, 0)
+	for i, tc := range []struct{ in, want int32 }{
+		{900, 900},
+		{600, 750},
+		{0, 500}, // a single low sample does not drop the average below 500
+		{900, 500},
+		{900, 600},
+	} {
+		if got := s.add(tc.in); got != tc.want {
+			t.Errorf("sample %d: add(%d) = %d, want %d", i, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestSurplusSmootherEMA(t *testing.T) {
+	s := newSurplusSmoother(0, 0.5)
+	if got := s.add(1000); got != 1000 {
+		t.Errorf("first sample = %d, want 1000", got)
+	}
+	if got := s.add(0); got != 500 {
+		t.Errorf("second sample = %d, want 500", got)
+	}
+	if got := s.add(0); got != 250 {
+		t.Errorf("third sample = %d, want 250", got)
+	}
+}
+
+func TestSurplusSmootherDisabled(t *testing.T) {
+	s := newSurplusSmoother(0, 0)
+	s.add(1000)
+	if got := s.add(-200); got != -200 {
+		t.Errorf("disabled smoother = %d, want -200", got)
+	}
+}
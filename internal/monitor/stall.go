@@ -0,0 +1,19 @@
+package monitor
+
+import (
+	"log"
+	"runtime/debug"
+)
+
+// RunCycleRecovering は、監視サイクルを実行し、サイクル中の panic を回復してログに出力します。
+// サイクルが最後まで完了した場合は true を返します。
+func RunCycleRecovering(m *Monitor) (completed bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[ウォッチドッグ] 監視サイクル中に panic が発生しました: %v\n%s", r, debug.Stack())
+			completed = false
+		}
+	}()
+	m.runCycle()
+	return true
+}
@@ -0,0 +1,38 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestRunCycleRecoveringFromPanic(t *testing.T) {
+	m := newTestMonitor(monitortest.NewFakeEIBS7(), monitortest.Now.Add(2*time.Hour), monitortest.Now.Add(3*time.Hour))
+	m.client = nil // runCycle panics on the nil client
+
+	if RunCycleRecovering(m) {
+		t.Error("RunCycleRecovering reported a completed cycle after a panic")
+	}
+}
+
+func TestSendBatteryOperationModeNoWait(t *testing.T) {
+	transport := echonetlite.NewFakeTransport(nil)
+	client := NewEchonetClient(transport, "192.168.0.10", time.Second)
+
+	if err := client.SendBatteryOperationModeNoWait(0x46); err != nil {
+		t.Fatal(err)
+	}
+	sent := transport.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 datagram, got %d", len(sent))
+	}
+	var f echonetlite.Frame
+	if err := f.UnmarshalBinary(sent[0].Data); err != nil {
+		t.Fatal(err)
+	}
+	if f.ESV != echonetlite.ESVSetC || len(f.Properties) != 1 || f.Properties[0].EPC != 0xDA || f.Properties[0].EDT[0] != 0x46 {
+		t.Errorf("sent %+v, want SetC of operation mode 0x46", f)
+	}
+}
@@ -0,0 +1,112 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// 気象庁の警報・注意報 (府県予報区ごと) の JSON の URL
+const jmaWarningURL = "https://www.jma.go.jp/bosai/warning/data/warning/%s.json"
+
+// jmaWarningResponse は、気象庁の警報・注意報の JSON のうち必要な部分です。
+type jmaWarningResponse struct {
+	AreaTypes []struct {
+		Areas []struct {
+			Code     string `json:"code"`
+			Warnings []struct {
+				Code   string `json:"code"`
+				Status string `json:"status"`
+			} `json:"warnings"`
+		} `json:"areas"`
+	} `json:"areaTypes"`
+}
+
+// activeStormWarnings は、区域に発表中 (発表・継続) の警報のうち、codes に含まれるもののコードを返します。
+func activeStormWarnings(data jmaWarningResponse, areaCode string, codes []string) []string {
+	var active []string
+	for _, areaType := range data.AreaTypes {
+		for _, area := range areaType.Areas {
+			if area.Code != areaCode {
+				continue
+			}
+			for _, w := range area.Warnings {
+				if w.Status != "発表" && w.Status != "継続" {
+					continue
+				}
+				for _, code := range codes {
+					if w.Code == code {
+						active = append(active, w.Code)
+					}
+				}
+			}
+		}
+	}
+	return active
+}
+
+// stormAlert は、気象庁の警報を定期的に確認し、嵐警戒モードの状態を保持します。
+type stormAlert struct {
+	cfg     config.StormAlertConfig
+	baseURL string
+	client  *http.Client
+	alerts  *WebhookAlerter // 嵐警戒モードの開始を通知する
+
+	lastCheck time.Time
+	warnings  []string // 発表中の対象警報のコード
+}
+
+// newStormAlert は、設定と嵐警戒モードの開始を通知する WebhookAlerter を指定して stormAlert を作成します。
+func newStormAlert(cfg config.StormAlertConfig, alerts *WebhookAlerter) *stormAlert {
+	return &stormAlert{
+		cfg:     cfg,
+		alerts:  alerts,
+		baseURL: jmaWarningURL,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// active は、対象の警報が発表中であれば true を返します。
+// 警報は check_interval_minutes ごとに確認し、取得に失敗した場合は前回の状態を維持します。
+func (s *stormAlert) active(now time.Time) bool {
+	if !s.cfg.Enabled {
+		return false
+	}
+	if s.lastCheck.IsZero() || now.Sub(s.lastCheck) >= time.Duration(s.cfg.CheckIntervalMinutes)*time.Minute {
+		s.lastCheck = now
+		warnings, err := s.fetch()
+		if err != nil {
+			log.Printf("[警報] 気象庁の警報の取得に失敗しました (前回の状態を維持します): %v", err)
+		} else {
+			if len(warnings) > 0 && len(s.warnings) == 0 {
+				log.Printf("[警報] 対象の警報が発表されました (警報コード: %v)。嵐警戒モードを開始します。", warnings)
+				s.alerts.fire(config.AlertStorm, fmt.Sprintf("警報が発表されたため、嵐警戒モードを開始しました (警報コード: %v)", warnings))
+			} else if len(warnings) == 0 && len(s.warnings) > 0 {
+				log.Println("[警報] 対象の警報が解除されました。嵐警戒モードを終了します。")
+			}
+			s.warnings = warnings
+		}
+	}
+	return len(s.warnings) > 0
+}
+
+// fetch は、気象庁の警報を取得し、区域に発表中の対象警報のコードを返します。
+func (s *stormAlert) fetch() ([]string, error) {
+	resp, err := s.client.Get(fmt.Sprintf(s.baseURL, s.cfg.OfficeCode))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	var data jmaWarningResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("警報の解析に失敗しました: %w", err)
+	}
+	return activeStormWarnings(data, s.cfg.AreaCode, s.cfg.WarningCodes), nil
+}
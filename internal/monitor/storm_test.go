@@ -0,0 +1,49 @@
+package monitor
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+func TestStormAlertFollowsJMAWarnings(t *testing.T) {
+	status := "発表"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/130000.json" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		fmt.Fprintf(w, `{"areaTypes":[
+			{"areas":[{"code":"130010","warnings":[{"code":"05","status":"%s"}]}]},
+			{"areas":[{"code":"1310100","warnings":[{"code":"14","status":"発表"},{"code":"05","status":"%s"}]},
+			          {"code":"1310200","warnings":[{"code":"03","status":"発表"}]}]}]}`, status, status)
+	}))
+	defer srv.Close()
+
+	cfg := config.StormAlertConfig{Enabled: true, OfficeCode: "130000", AreaCode: "1310100"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	s := newStormAlert(cfg, &WebhookAlerter{})
+	s.baseURL = srv.URL + "/%s.json"
+
+	now := time.Date(2025, 9, 1, 12, 0, 0, 0, time.Local)
+	if !s.active(now) {
+		t.Fatalf("expected storm mode while the storm warning is issued")
+	}
+	if len(s.warnings) != 1 || s.warnings[0] != "05" {
+		t.Errorf("warnings = %v, want [05]", s.warnings)
+	}
+
+	// The warning is cleared, but the state is only refreshed after the check interval.
+	status = "解除"
+	if !s.active(now.Add(time.Minute)) {
+		t.Errorf("state refreshed before the check interval")
+	}
+	if s.active(now.Add(10 * time.Minute)) {
+		t.Errorf("storm mode still active after the warning was cleared")
+	}
+}
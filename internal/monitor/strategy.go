@@ -0,0 +1,268 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/controller"
+)
+
+// measurements は、制御方式が運転モードなどを決めるために使用する監視サイクルの計測値です。
+type measurements struct {
+	now           time.Time
+	data          map[string]interface{} // 各監視対象から取得したデータ
+	operationMode byte                   // 現在の運転モード
+	householdLoad int32                  // 自家消費電力 (W)
+	haveLoad      bool                   // 自家消費電力が計算できた
+	belowReserve  bool                   // 蓄電残量が停電用の最低リザーブ以下である
+	chargeTimes   config.ChargeWindow    // 適用する充電時間帯
+}
+
+// controlActions は、制御方式が決めた蓄電池の設定です。
+type controlActions struct {
+	mode             byte   // 設定する運転モード。0 の場合は変更しない
+	recordModeChange bool   // 運転モードを変更した場合に、モード変更の抑制時間の対象として記録する
+	chargePower      int    // 目標充電電力 (W)。負の場合は変更しない
+	dischargePower   int    // 放電電力設定値 (W)。負の場合は変更しない
+	dischargeStep    int    // 前回の放電電力設定値との差がこの値未満の場合は更新しない (W)
+	dischargeNote    string // 放電電力設定値を更新した場合に出力するログ
+}
+
+// noActions は、蓄電池の設定を変更しない controlActions を返します。
+func noActions() controlActions {
+	return controlActions{chargePower: -1, dischargePower: -1}
+}
+
+// modeActions は、運転モードのみを設定する controlActions を返します。
+func modeActions(mode byte) controlActions {
+	a := noActions()
+	a.mode = mode
+	return a
+}
+
+// strategy は、計測値と制御状態から蓄電池の設定を決める制御方式です。
+// decide は機器との通信を行わず、決めた設定は Monitor.applyActions で反映します。
+type strategy interface {
+	name() string
+	decide(ms measurements, state controller.State) controlActions
+}
+
+// chargeScheduleStrategy は、充電時間帯は余剰電力に応じて充電し、それ以外の時間帯は自家消費する従来の制御方式です。
+// ピークカットの時間帯は peak_shave と同じ制御を行います。
+type chargeScheduleStrategy struct{ m *Monitor }
+
+func (s chargeScheduleStrategy) name() string { return config.StrategyChargeSchedule }
+
+func (s chargeScheduleStrategy) decide(ms measurements, state controller.State) controlActions {
+	m := s.m
+	switch state {
+	case controller.Inhibited:
+		// 安全性: モード変更頻度抑制
+		log.Printf("[制御] モード変更後、抑制時間が経過していないため（残り: %s）、制御をスキップします。", m.controller.InhibitRemaining().Truncate(time.Second))
+		return noActions()
+
+	case controller.SurplusLimited:
+		// 買電抑制制御 (ヒステリシス): 余剰電力が auto_mode_threshold_watts を下回ると「自動」に、
+		// charge_mode_threshold_watts 以上に回復すると「充電」に切り替える
+		a := modeActions(0x46) // 0x46: 自動モード
+		_, autoModeWatts := m.cfg.ModeThresholds(ms.now)
+		if ms.belowReserve {
+			log.Printf("[制御] 余剰電力が閾値 (%d W) を下回りましたが、最低リザーブ以下のため「自動」ではなく「待機」に設定します。", autoModeWatts)
+			a.mode = 0x44 // 0x44: 待機モード
+		} else {
+			log.Printf("[制御] 余剰電力が閾値 (%d W) を下回ったため、運転モードを「自動」に設定します。", autoModeWatts)
+		}
+		a.recordModeChange = true
+		return a
+
+	case controller.Charging:
+		if m.chargeTargetReached(ms) {
+			a := modeActions(0x46) // 0x46: 自動モード
+			if ms.belowReserve {
+				a.mode = 0x44 // 0x44: 待機モード
+			}
+			return a
+		}
+		log.Println("[制御] 充電時間帯です。余剰電力は閾値以上のため、充電を継続します。")
+		a := modeActions(0x42) // 0x42: 充電モード
+		if target, ok := m.chargePowerTarget(ms.data, ms.chargeTimes); ok {
+			a.chargePower = target
+		}
+		return a
+
+	case controller.PeakShaving:
+		return peakShaveStrategy{m}.decide(ms, state)
+
+	default:
+		return selfConsumptionStrategy{m}.decide(ms, state)
+	}
+}
+
+// chargeTargetReached は、target_reached_action が "stop" で、充電時間帯の途中で目標の蓄電残量に達した場合に true を返します。
+// 一度達した後は、放電で蓄電残量が下がっても充電時間帯の終了まで true を返し、「充電」と「自動」の切り替えを繰り返さないようにします。
+func (m *Monitor) chargeTargetReached(ms measurements) bool {
+	if m.cfg.TargetReachedAction != config.TargetReachedStop {
+		return false
+	}
+	if ms.now.Before(m.chargeStoppedUntil) {
+		log.Printf("[制御] 目標の蓄電残量に達したため、充電時間帯の終了 (%s) まで充電を行いません。", m.chargeStoppedUntil.Format("15:04"))
+		return true
+	}
+	soc, ok := ms.data["蓄電池.蓄電残量3"].(uint8)
+	if !ok || int(soc) < ms.chargeTimes.TargetSOCPercent {
+		return false
+	}
+	m.chargeStoppedUntil = ms.chargeTimes.End(ms.now)
+	log.Printf("[制御] 蓄電残量 (%d%%) が目標 (%d%%) に達したため、充電時間帯の終了 (%s) を待たずに自動モードに切り替えます。", soc, ms.chargeTimes.TargetSOCPercent, m.chargeStoppedUntil.Format("15:04"))
+	return true
+}
+
+// selfConsumptionStrategy は、自動モードで太陽光の余剰電力を充電し、家庭の消費に合わせて放電する制御方式です。
+// 最低リザーブ、放電時間帯、夕方のリザーブ、ピーク時間帯の放電電力の制限を適用します。
+type selfConsumptionStrategy struct{ m *Monitor }
+
+func (s selfConsumptionStrategy) name() string { return config.StrategySelfConsumption }
+
+func (s selfConsumptionStrategy) decide(ms measurements, state controller.State) controlActions {
+	m := s.m
+	a := modeActions(0x46) // 0x46: 自動モード
+	if ms.belowReserve {
+		a.mode = 0x44 // 0x44: 待機モード
+	}
+	if a.mode == 0x46 && m.cfg.DischargeTimes.Configured() {
+		if inWindow, err := m.cfg.DischargeTimes.Contains(ms.now); err != nil {
+			log.Printf("[制御] 放電時間帯の判定に失敗しました: %v", err)
+		} else if !inWindow {
+			log.Printf("[制御] 放電時間帯 (%s) ではないため、放電を止めるよう待機モードに設定します。", m.cfg.DischargeTimes)
+			a.mode = 0x44 // 0x44: 待機モード
+		}
+	}
+	if a.mode == 0x46 && m.evening.active(ms.now) {
+		// 夕方の放電時間帯: 翌朝の発電量予測に応じたリザーブを下回らないよう放電を止める
+		reserve := m.evening.reservePercent(ms.now)
+		if soc, ok := ms.data["蓄電池.蓄電残量3"].(uint8); !ok {
+			log.Println("[制御] 蓄電残量が取得できなかったため、リザーブの判定をスキップします。")
+		} else if int(soc) <= reserve {
+			log.Printf("[制御] 蓄電残量 (%d%%) がリザーブ (%d%%) 以下のため、放電を止めるよう待機モードに設定します。", soc, reserve)
+			a.mode = 0x44 // 0x44: 待機モード
+		} else {
+			log.Printf("[制御] 蓄電残量 (%d%%) はリザーブ (%d%%) を上回っています。", soc, reserve)
+		}
+	}
+	if a.mode == 0x46 && state == controller.Idle {
+		log.Println("[制御] 充電時間帯ではありません。自動モードに設定します。")
+	} else if a.mode == 0x46 {
+		log.Println("[制御] 自家消費を優先するため、自動モードに設定します。")
+	}
+
+	// ピーク時間帯は放電電力を家庭の消費電力以下に制限し、逆潮流を防ぐ
+	if a.mode == 0x46 && m.cfg.DischargeCap.Enabled {
+		if inPeak, err := m.cfg.DischargeCap.Contains(ms.now); err != nil {
+			log.Printf("[制御] ピーク時間帯の判定に失敗しました: %v", err)
+		} else if inPeak && !ms.haveLoad {
+			log.Println("[制御] 自家消費電力が取得できなかったため、放電電力の制限をスキップします。")
+		} else if inPeak {
+			a.dischargePower = m.cfg.DischargeCap.DischargePowerCap(ms.householdLoad)
+			a.dischargeStep = m.cfg.DischargeCap.UpdateStepWatts
+			a.dischargeNote = fmt.Sprintf("[制御] ピーク時間帯のため、放電電力を自家消費電力 (%d W) に合わせて %d W に制限しました。", ms.householdLoad, a.dischargePower)
+		}
+	}
+	return a
+}
+
+// peakShaveStrategy は、買電電力が peak_shaving.import_threshold_watts を超えた分だけ放電モードで放電し、
+// 閾値以下の間や蓄電残量が下限以下の場合は待機モードにして蓄電残量を温存する制御方式です。
+type peakShaveStrategy struct{ m *Monitor }
+
+func (s peakShaveStrategy) name() string { return config.StrategyPeakShave }
+
+func (s peakShaveStrategy) decide(ms measurements, _ controller.State) controlActions {
+	cfg := s.m.cfg.PeakShaving
+	if ms.belowReserve {
+		log.Println("[制御] 最低リザーブ以下のため、ピークカットの放電は行わず待機モードに設定します。")
+		return modeActions(0x44) // 0x44: 待機モード
+	}
+	gridPower, gOK := ms.data["系統.瞬時電力計測値"].(int32)
+	soc, sOK := ms.data["蓄電池.蓄電残量3"].(uint8)
+	if !gOK || !sOK {
+		log.Println("[ピークカット] 買電電力または蓄電残量が取得できなかったため、制御をスキップします。")
+		return noActions()
+	}
+
+	a := modeActions(0x44) // 0x44: 待機モード
+	if int(soc) <= cfg.MinSOCPercent {
+		log.Printf("[ピークカット] 蓄電残量 (%d%%) が下限 (%d%%) 以下のため、放電しません。", soc, cfg.MinSOCPercent)
+		return a
+	}
+	currentDischarge := 0
+	if batteryPower, ok := ms.data["蓄電池.瞬時充放電電力計測値"].(int32); ok && batteryPower < 0 {
+		currentDischarge = int(-batteryPower)
+	}
+	power := cfg.PeakShavingDischargePower(int(gridPower), currentDischarge)
+	if power == 0 {
+		log.Printf("[ピークカット] 買電電力 (%d W) は閾値 (%d W) 以下のため、放電しません。", gridPower, cfg.ImportThresholdWatts)
+		return a
+	}
+	log.Printf("[ピークカット] 買電電力 (%d W) を閾値 (%d W) 以下にするため、%d W で放電します。", gridPower, cfg.ImportThresholdWatts, power)
+	a.mode = 0x43 // 0x43: 放電モード
+	a.dischargePower = power
+	a.dischargeStep = cfg.UpdateStepWatts
+	return a
+}
+
+// exportPriorityStrategy は、蓄電池が太陽光の余剰電力を吸収しないよう待機モードにして、余剰電力をすべて売電する制御方式です。
+type exportPriorityStrategy struct{ m *Monitor }
+
+func (s exportPriorityStrategy) name() string { return config.StrategyExportPriority }
+
+func (s exportPriorityStrategy) decide(measurements, controller.State) controlActions {
+	log.Println("[制御] 売電を優先するため、待機モードに設定します。")
+	return modeActions(0x44) // 0x44: 待機モード
+}
+
+// newStrategy は、名前に対応する制御方式を返します。不明な名前の場合は charge_schedule を返します。
+func (m *Monitor) newStrategy(name string) strategy {
+	switch name {
+	case config.StrategySelfConsumption:
+		return selfConsumptionStrategy{m}
+	case config.StrategyPeakShave:
+		return peakShaveStrategy{m}
+	case config.StrategyExportPriority:
+		return exportPriorityStrategy{m}
+	default:
+		return chargeScheduleStrategy{m}
+	}
+}
+
+// applyActions は、制御方式が決めた設定を蓄電池に反映します。
+// 放電モードにする場合は、前の設定値で放電を始めないよう放電電力設定値を先に更新します。
+func (m *Monitor) applyActions(a controlActions, ms measurements) {
+	if a.dischargePower >= 0 && a.mode == 0x43 {
+		m.applyDischargePower(a)
+	}
+	if a.mode != 0 && a.mode != ms.operationMode {
+		changed, err := m.setOperationMode(a.mode, a.recordModeChange)
+		if err != nil {
+			log.Printf("[制御] 蓄電池の運転モード設定 (0x%X) に失敗しました: %v", a.mode, err)
+			// エラーが発生しても処理を続行
+		}
+		if a.recordModeChange {
+			m.modeChanged(changed)
+		}
+	}
+	if a.dischargePower >= 0 && a.mode != 0x43 {
+		m.applyDischargePower(a)
+	}
+	if a.chargePower >= 0 {
+		m.applyChargePower(a.chargePower, ms.data)
+	}
+}
+
+// applyDischargePower は、放電電力設定値を更新し、更新した場合は dischargeNote を出力します。
+func (m *Monitor) applyDischargePower(a controlActions) {
+	if m.updateDischargePower(a.dischargePower, a.dischargeStep) && a.dischargeNote != "" {
+		log.Println(a.dischargeNote)
+	}
+}
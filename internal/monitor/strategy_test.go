@@ -0,0 +1,33 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestMonitorStrategyPeriodOverridesChargeWindow(t *testing.T) {
+	now := monitortest.Now
+	device := monitortest.NewFakeEIBS7()
+	m := newTestMonitor(device, now.Add(-time.Hour), now.Add(time.Hour))
+	m.cfg.StrategyPeriods = []config.StrategyPeriod{{
+		Strategy: "self_consumption",
+		TimeSlot: config.TimeSlot{StartTime: now.Add(-time.Hour).Format("15:04"), EndTime: now.Add(time.Hour).Format("15:04")},
+	}}
+
+	m.runCycle()
+
+	// Inside the charging window, self_consumption switches to auto instead of charging.
+	if len(device.Sets) != 1 {
+		t.Fatalf("expected 1 SetC, got %d", len(device.Sets))
+	}
+	if p := device.Sets[0].Properties[0]; p.EPC != 0xDA || p.EDT[0] != 0x46 {
+		t.Errorf("SetC = EPC 0x%X EDT %X, want operation mode 0x46", p.EPC, p.EDT)
+	}
+	if device.Props[echonetlite.NewEOJ(0x02, 0x7D, 0x01)][0xDA][0] != 0x46 {
+		t.Error("battery did not end up in auto mode")
+	}
+}
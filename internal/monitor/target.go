@@ -0,0 +1,200 @@
+package monitor
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+)
+
+// MonitoringTarget は、監視対象のECHONET Liteオブジェクトと取得するプロパティのリストを定義します。
+type MonitoringTarget struct {
+	EOJ        echonetlite.EOJ
+	EPCs       []byte
+	SlowEPCs   []byte // EPCs のうち値がほとんど変化しないもの (通知駆動の監視モードでは slow_poll_interval_seconds ごとに取得)
+	ObjectName string // ログ出力用のオブジェクト名
+
+	role   string         // 機器の役割 (roleBattery など)。制御で使用する機器は役割で探す
+	client *EchonetClient // targets の機器の場合のみ。nil の場合は target_ip の機器
+}
+
+// splitMonitoringTarget は、監視対象の EPC リストを2つに分割した監視対象を返します。
+func splitMonitoringTarget(target MonitoringTarget) []MonitoringTarget {
+	half := len(target.EPCs) / 2
+	first, second := target, target
+	first.EPCs, second.EPCs = target.EPCs[:half], target.EPCs[half:]
+	return []MonitoringTarget{first, second}
+}
+
+// DecodeEDT は、指定されたEPCに基づいてEDT（プロパティ値データ）を適切なGoの型にデコードします。
+// 対応していないEPCの場合は、元のバイト列とエラーを返します。
+func DecodeEDT(deoj echonetlite.EOJ, epc byte, edt []byte) (interface{}, string, error) {
+	if edt == nil {
+		// Get要求の応答でPDC=0の場合、EDTはnilになりうる。これはエラーではない。
+		// ただし、値がないことを示すためにnilを返す。
+		return nil, echonetlite.PropertyName(deoj, epc), nil
+	}
+	pdc := len(edt)
+	propName := echonetlite.PropertyName(deoj, epc)
+
+	if deoj.ClassGroupCode != 0x0E { // 機器オブジェクトスーパークラス (プロファイル以外の全クラス共通)
+		switch epc {
+		case 0x88: // 異常発生状態 - unsigned char (1 byte)
+			if pdc != 1 {
+				return edt, propName, fmt.Errorf("EPC 0x88 (異常発生状態) expects PDC=1, got %d", pdc)
+			}
+			return uint8(edt[0]), propName, nil
+		case 0x89: // 異常内容 - unsigned short (2 bytes)
+			if pdc != 2 {
+				return edt, propName, fmt.Errorf("EPC 0x89 (異常内容) expects PDC=2, got %d", pdc)
+			}
+			return faultDescription(binary.BigEndian.Uint16(edt)), propName, nil
+		}
+	}
+
+	switch deoj.ClassGroupCode {
+	case 0x01: // 空調関連機器クラスグループ
+		switch deoj.ClassCode {
+		case 0x30: // 家庭用エアコンクラス
+			switch epc {
+			case 0x80, 0xB0, 0xB3, 0x8F: // 動作状態, 運転モード設定, 温度設定値 (℃), 節電動作設定 - unsigned char (1 byte)
+				if pdc != 1 {
+					return edt, propName, fmt.Errorf("EPC 0x%X (%s) expects PDC=1, got %d", epc, propName, pdc)
+				}
+				return uint8(edt[0]), propName, nil
+			}
+		}
+	case 0x02: // 住宅設備関連機器クラスグループ
+		switch deoj.ClassCode {
+		case 0x7D: // 蓄電池クラス
+			switch epc {
+			case 0xE4: // 蓄電残量3 (%) - unsigned char (1 byte)
+				if pdc != 1 {
+					return edt, propName, fmt.Errorf("EPC 0xE4 (蓄電残量3) expects PDC=1, got %d", pdc)
+				}
+				return uint8(edt[0]), propName, nil
+			case 0xDA: // 運転モード設定 - unsigned char (1 byte)
+				if pdc != 1 {
+					return edt, propName, fmt.Errorf("EPC 0xDA (運転モード設定) expects PDC=1, got %d", pdc)
+				}
+				return uint8(edt[0]), propName, nil // 具体的な値の意味は別途解釈
+			case 0xEB: // 充電電力設定値 (W) - unsigned long (4 bytes)
+				if pdc != 4 {
+					return edt, propName, fmt.Errorf("EPC 0xEB (充電電力設定値) expects PDC=4, got %d", pdc)
+				}
+				return binary.BigEndian.Uint32(edt), propName, nil
+			case 0xEC: // 放電電力設定値 (W) - unsigned long (4 bytes)
+				if pdc != 4 {
+					return edt, propName, fmt.Errorf("EPC 0xEC (放電電力設定値) expects PDC=4, got %d", pdc)
+				}
+				return binary.BigEndian.Uint32(edt), propName, nil
+			case 0xD3: // 瞬時充放電電力計測値 (W) - signed long (4 bytes)
+				if pdc != 4 {
+					return edt, propName, fmt.Errorf("EPC 0xD3 (瞬時充放電電力計測値) expects PDC=4, got %d", pdc)
+				}
+				return int32(binary.BigEndian.Uint32(edt)), propName, nil
+			case 0xA0: // AC実効容量（充電） (Wh) - unsigned long (4 bytes)
+				if pdc != 4 {
+					return edt, propName, fmt.Errorf("EPC 0xA0 (AC実効容量) expects PDC=4, got %d", pdc)
+				}
+				return binary.BigEndian.Uint32(edt), propName, nil
+			case 0xD6, 0xD8: // 積算放電電力量計測値, 積算充電電力量計測値 (0.001kWh) - unsigned long (4 bytes)
+				if pdc != 4 {
+					return edt, propName, fmt.Errorf("EPC 0x%X (%s) expects PDC=4, got %d", epc, propName, pdc)
+				}
+				return float64(binary.BigEndian.Uint32(edt)) * 0.001, propName, nil // kWh
+			case 0xD0: // 系統連系状態 - unsigned char (1 byte)
+				if pdc != 1 {
+					return edt, propName, fmt.Errorf("EPC 0xD0 (系統連系状態) expects PDC=1, got %d", pdc)
+				}
+				return uint8(edt[0]), propName, nil
+			case 0xCF: // 運転動作状態 - unsigned char (1 byte)
+				if pdc != 1 {
+					return edt, propName, fmt.Errorf("EPC 0xCF (運転動作状態) expects PDC=1, got %d", pdc)
+				}
+				return WorkingStatus(edt[0]), propName, nil
+			}
+		case 0x79: // 住宅用太陽光発電クラス
+			switch epc {
+			case 0xE0: // 瞬時発電電力計測値 (W) - unsigned short (2 bytes)
+				if pdc != 2 {
+					return edt, propName, fmt.Errorf("EPC 0xE0 (瞬時発電電力計測値) expects PDC=2, got %d", pdc)
+				}
+				return binary.BigEndian.Uint16(edt), propName, nil
+			case 0xE1: // 積算発電電力量計測値 (0.001kWh) - unsigned long (4 bytes)
+				if pdc != 4 {
+					return edt, propName, fmt.Errorf("EPC 0xE1 (積算発電電力量計測値) expects PDC=4, got %d", pdc)
+				}
+				return float64(binary.BigEndian.Uint32(edt)) * 0.001, propName, nil // kWh
+			}
+		case 0x87: // 分電盤メータリングクラス
+			switch epc {
+			case 0xC6: // 瞬時電力計測値 (W) - signed long (4 bytes)
+				if pdc != 4 {
+					return edt, propName, fmt.Errorf("EPC 0xC6 (瞬時電力計測値) expects PDC=4, got %d", pdc)
+				}
+				return int32(binary.BigEndian.Uint32(edt)), propName, nil
+			}
+		case 0xA5: // マルチ入力PCSクラス
+			switch epc {
+			case 0xE7: // 瞬時電力計測値 (W) - signed long (4 bytes)
+				if pdc != 4 {
+					return edt, propName, fmt.Errorf("EPC 0xE7 (瞬時電力計測値) expects PDC=4, got %d", pdc)
+				}
+				return int32(binary.BigEndian.Uint32(edt)), propName, nil
+			}
+		case 0x6B: // 電気給湯機クラス
+			switch epc {
+			case 0xB0, 0xB2: // 沸き上げ自動設定, 沸き上げ中状態 - unsigned char (1 byte)
+				if pdc != 1 {
+					return edt, propName, fmt.Errorf("EPC 0x%X (%s) expects PDC=1, got %d", epc, propName, pdc)
+				}
+				return uint8(edt[0]), propName, nil
+			}
+		case 0x88: // 低圧スマート電力量メータクラス
+			switch epc {
+			case 0xE7: // 瞬時電力計測値 (W) - signed long (4 bytes)
+				if pdc != 4 {
+					return edt, propName, fmt.Errorf("EPC 0xE7 (瞬時電力計測値) expects PDC=4, got %d", pdc)
+				}
+				return int32(binary.BigEndian.Uint32(edt)), propName, nil
+			case 0xE0, 0xE3: // 積算電力量計測値 (正方向, 逆方向) - unsigned long (4 bytes)
+				if pdc != 4 {
+					return edt, propName, fmt.Errorf("EPC 0x%X (%s) expects PDC=4, got %d", epc, propName, pdc)
+				}
+				return binary.BigEndian.Uint32(edt), propName, nil
+			case 0xE1: // 積算電力量単位 - unsigned char (1 byte)
+				if pdc != 1 {
+					return edt, propName, fmt.Errorf("EPC 0xE1 (積算電力量単位) expects PDC=1, got %d", pdc)
+				}
+				return uint8(edt[0]), propName, nil
+			}
+		case 0x7E, 0xA1: // 電気自動車充放電器クラス, 電気自動車充電器クラス
+			switch epc {
+			case 0xD3: // 瞬時充放電電力計測値 / 瞬時充電電力計測値 (W) - signed long / unsigned long (4 bytes)
+				if pdc != 4 {
+					return edt, propName, fmt.Errorf("EPC 0xD3 (%s) expects PDC=4, got %d", propName, pdc)
+				}
+				return int32(binary.BigEndian.Uint32(edt)), propName, nil
+			case 0xDA: // 運転モード設定 - unsigned char (1 byte)
+				if pdc != 1 {
+					return edt, propName, fmt.Errorf("EPC 0xDA (運転モード設定) expects PDC=1, got %d", pdc)
+				}
+				return uint8(edt[0]), propName, nil
+			}
+		}
+	case 0x0E: // プロファイルクラスグループ
+		switch deoj.ClassCode {
+		case 0xF0: // ノードプロファイルクラス
+			switch epc {
+			case 0x80: // 動作状態 - unsigned char (1 byte)
+				if pdc != 1 {
+					return edt, propName, fmt.Errorf("EPC 0x80 (動作状態) expects PDC=1, got %d", pdc)
+				}
+				return uint8(edt[0]), propName, nil
+			}
+		}
+	}
+	// 未知のDEOJ/EPCの組み合わせ
+	return edt, propName, fmt.Errorf("unknown DEOJ (ClassGroup: 0x%02X, Class: 0x%02X) or EPC 0x%X, cannot decode EDT, returning raw bytes", deoj.ClassGroupCode, deoj.ClassCode, epc)
+}
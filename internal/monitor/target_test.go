@@ -0,0 +1,27 @@
+package monitor
+
+import (
+	"testing"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+)
+
+func TestSplitMonitoringTarget(t *testing.T) {
+	target := MonitoringTarget{
+		EOJ:        echonetlite.NewEOJ(0x02, 0x7D, 0x01),
+		EPCs:       []byte{0xE4, 0xDA, 0xEB, 0xD3, 0xA0},
+		ObjectName: "蓄電池 (027D01)",
+	}
+	parts := splitMonitoringTarget(target)
+	if len(parts) != 2 {
+		t.Fatalf("expected 2 parts, got %d", len(parts))
+	}
+	if len(parts[0].EPCs)+len(parts[1].EPCs) != len(target.EPCs) {
+		t.Errorf("EPCs lost while splitting: %v / %v", parts[0].EPCs, parts[1].EPCs)
+	}
+	for _, p := range parts {
+		if p.EOJ != target.EOJ || p.ObjectName != target.ObjectName || len(p.EPCs) == 0 {
+			t.Errorf("unexpected part: %+v", p)
+		}
+	}
+}
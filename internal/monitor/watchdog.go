@@ -0,0 +1,95 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+)
+
+// ノードプロファイルオブジェクト (EOJ: 0EF001)
+var NodeProfileEOJ = echonetlite.NewEOJ(0x0E, 0xF0, 0x01)
+
+// reachabilityWatchdog は、ノードプロファイルの動作状態 (EPC 0x80) を定期的に取得し、
+// 連続して応答が得られない場合に機器を到達不能と判定します。
+// 監視サイクル中の個々の通信エラーとは区別して、機器そのものの死活を表します。
+type reachabilityWatchdog struct {
+	interval  time.Duration   // 死活確認の間隔
+	threshold int             // 到達不能と判定する連続失敗回数
+	alerts    *WebhookAlerter // 到達不能を通知する
+
+	lastCheck           time.Time
+	lastSuccess         time.Time
+	consecutiveFailures int
+	unreachable         bool
+}
+
+// newReachabilityWatchdog は、死活確認の間隔と連続失敗回数の閾値、到達不能を通知する WebhookAlerter を指定して reachabilityWatchdog を作成します。
+func newReachabilityWatchdog(interval time.Duration, threshold int, alerts *WebhookAlerter) *reachabilityWatchdog {
+	return &reachabilityWatchdog{interval: interval, threshold: threshold, alerts: alerts}
+}
+
+// due は、前回の死活確認から間隔が経過していれば true を返します。
+func (w *reachabilityWatchdog) due(now time.Time) bool {
+	return w.lastCheck.IsZero() || now.Sub(w.lastCheck) >= w.interval
+}
+
+// record は、死活確認の結果を記録し、到達不能状態の変化をログに出力します。
+func (w *reachabilityWatchdog) record(now time.Time, err error) {
+	w.lastCheck = now
+	if err == nil {
+		if w.unreachable {
+			log.Printf("[死活監視] 機器との通信が回復しました (連続失敗回数: %d)", w.consecutiveFailures)
+		}
+		w.consecutiveFailures = 0
+		w.unreachable = false
+		w.lastSuccess = now
+		return
+	}
+
+	w.consecutiveFailures++
+	log.Printf("[死活監視] 死活確認に失敗しました (連続失敗回数: %d/%d): %v", w.consecutiveFailures, w.threshold, err)
+	if !w.unreachable && w.consecutiveFailures >= w.threshold {
+		w.unreachable = true
+		log.Printf("[死活監視] 機器到達不能: %d 回連続で応答がありません (最終応答: %s)", w.consecutiveFailures, formatLastSuccess(w.lastSuccess))
+		w.alerts.fire(config.AlertUnreachable, fmt.Sprintf("機器に到達できません: %d 回連続で応答がありません (最終応答: %s)", w.consecutiveFailures, formatLastSuccess(w.lastSuccess)))
+	}
+}
+
+// status は、死活監視の状態を表す文字列を返します。
+func (w *reachabilityWatchdog) status() string {
+	if w.unreachable {
+		return fmt.Sprintf("到達不能 (連続失敗回数: %d, 最終応答: %s)", w.consecutiveFailures, formatLastSuccess(w.lastSuccess))
+	}
+	if w.lastCheck.IsZero() {
+		return "未確認"
+	}
+	return fmt.Sprintf("正常 (最終応答: %s)", formatLastSuccess(w.lastSuccess))
+}
+
+// formatLastSuccess は、最終応答時刻をログ出力用の文字列に変換します。
+func formatLastSuccess(t time.Time) string {
+	if t.IsZero() {
+		return "なし"
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// checkDeviceLiveness は、ノードプロファイルの動作状態 (EPC 0x80) を取得し、機器が応答するかを確認します。
+func (c *EchonetClient) checkDeviceLiveness() error {
+	responseFrame, err := c.Get(NodeProfileEOJ, 0x80) // 動作状態
+	if err != nil {
+		return err
+	}
+	if responseFrame.ESV != echonetlite.ESVGet_Res {
+		return fmt.Errorf("予期しないESV (0x%X) を受信しました (TID: %d)", responseFrame.ESV, responseFrame.TID)
+	}
+	for _, prop := range responseFrame.Properties {
+		if prop.EPC == 0x80 && len(prop.EDT) == 1 && prop.EDT[0] != 0x30 {
+			log.Printf("[死活監視] ノードプロファイルの動作状態が ON (0x30) ではありません: 0x%X", prop.EDT[0])
+		}
+	}
+	return nil
+}
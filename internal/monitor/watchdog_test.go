@@ -0,0 +1,55 @@
+package monitor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReachabilityWatchdogThreshold(t *testing.T) {
+	w := newReachabilityWatchdog(time.Minute, 3, &WebhookAlerter{})
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local)
+	fail := errors.New("timeout")
+
+	if !w.due(base) {
+		t.Fatalf("first check must be due immediately")
+	}
+	w.record(base, nil)
+	if w.due(base.Add(30 * time.Second)) {
+		t.Errorf("check must not be due before the interval elapses")
+	}
+	if !w.due(base.Add(time.Minute)) {
+		t.Errorf("check must be due after the interval")
+	}
+
+	for i := 1; i <= 2; i++ {
+		w.record(base.Add(time.Duration(i)*time.Minute), fail)
+		if w.unreachable {
+			t.Fatalf("unreachable raised after only %d failures", i)
+		}
+	}
+	w.record(base.Add(3*time.Minute), fail)
+	if !w.unreachable {
+		t.Fatalf("unreachable not raised after 3 consecutive failures")
+	}
+	if !strings.HasPrefix(w.status(), "到達不能") {
+		t.Errorf("status does not report unreachable: %s", w.status())
+	}
+
+	w.record(base.Add(4*time.Minute), nil)
+	if w.unreachable || w.consecutiveFailures != 0 {
+		t.Errorf("watchdog did not recover after a successful check: %+v", w)
+	}
+}
+
+func TestReachabilityWatchdogFailureStreakResets(t *testing.T) {
+	w := newReachabilityWatchdog(time.Minute, 2, &WebhookAlerter{})
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local)
+	w.record(base, errors.New("timeout"))
+	w.record(base.Add(time.Minute), nil)
+	w.record(base.Add(2*time.Minute), errors.New("timeout"))
+	if w.unreachable {
+		t.Errorf("non-consecutive failures must not raise unreachable")
+	}
+}
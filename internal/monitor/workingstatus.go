@@ -0,0 +1,71 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// WorkingStatus は、蓄電池の運転動作状態 (EPC 0xCF) です。
+type WorkingStatus uint8
+
+var workingStatusNames = map[WorkingStatus]string{
+	0x40: "その他",
+	0x41: "急速充電",
+	0x42: "充電",
+	0x43: "放電",
+	0x44: "待機",
+	0x45: "テスト",
+	0x46: "自動",
+	0x48: "再起動",
+	0x49: "実効容量再計算処理",
+}
+
+// String は、運転動作状態の名前と値を返します (例: "充電 (0x42)")。
+func (s WorkingStatus) String() string {
+	if name, ok := workingStatusNames[s]; ok {
+		return fmt.Sprintf("%s (0x%X)", name, uint8(s))
+	}
+	return fmt.Sprintf("不明 (0x%X)", uint8(s))
+}
+
+// contradicts は、運転モード mode を設定した蓄電池の運転動作状態として s が矛盾する場合に true を返します。
+// 充電モードでの待機 (満充電や充電電力 0 W) のように、設定どおりでも起こりうる状態は矛盾とみなしません。
+// 自動モードや、再起動・実効容量再計算処理などの一時的な状態は判定しません。
+func (s WorkingStatus) contradicts(mode byte) bool {
+	switch mode {
+	case 0x42: // 充電
+		return s == 0x43
+	case 0x43: // 放電
+		return s == 0x41 || s == 0x42
+	case 0x44: // 待機
+		return s == 0x41 || s == 0x42 || s == 0x43
+	}
+	return false
+}
+
+// checkWorkingStatus は、各蓄電池の運転動作状態をログに出力し、このサイクルより前に設定した運転モードが反映されているかを確認します。
+// 運転モードは設定どおりでも運転動作状態が矛盾する場合は、設定が反映されていないとみなして警告します。警告は状態が解消するまで繰り返しません。
+func (m *Monitor) checkWorkingStatus(cycleStart time.Time) {
+	for _, u := range m.batteries {
+		if u.status == 0 {
+			continue
+		}
+		log.Printf("[制御] %s の運転動作状態: %s", u.name, u.status)
+		if u.lastSetMode == 0 || !u.lastSetAt.Before(cycleStart) || u.mode != u.lastSetMode {
+			continue
+		}
+		if !u.status.contradicts(u.lastSetMode) {
+			if u.statusMismatch {
+				log.Printf("[制御] %s の運転動作状態が運転モード (0x%X) と一致しました。", u.name, u.lastSetMode)
+				u.statusMismatch = false
+			}
+			continue
+		}
+		if !u.statusMismatch {
+			log.Printf("[制御] 警告: %s の運転モードを 0x%X に設定しましたが (設定時刻: %s)、運転動作状態が %s です。設定が反映されていない可能性があります。",
+				u.name, u.lastSetMode, u.lastSetAt.Format("15:04:05"), u.status)
+			u.statusMismatch = true
+		}
+	}
+}
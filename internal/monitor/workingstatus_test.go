@@ -0,0 +1,72 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"kuramo.ch/eibs7-controller/echonetlite"
+	"kuramo.ch/eibs7-controller/internal/config"
+	"kuramo.ch/eibs7-controller/internal/monitor/monitortest"
+)
+
+func TestWorkingStatusString(t *testing.T) {
+	if s := WorkingStatus(0x41).String(); s != "急速充電 (0x41)" {
+		t.Errorf("String() = %q", s)
+	}
+	if s := WorkingStatus(0x50).String(); s != "不明 (0x50)" {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestWorkingStatusContradicts(t *testing.T) {
+	for _, tc := range []struct {
+		status WorkingStatus
+		mode   byte
+		want   bool
+	}{
+		{0x42, 0x42, false},
+		{0x44, 0x42, false}, // full or zero charge power
+		{0x43, 0x42, true},
+		{0x42, 0x43, true},
+		{0x43, 0x44, true},
+		{0x43, 0x46, false},
+		{0x49, 0x44, false},
+	} {
+		if got := tc.status.contradicts(tc.mode); got != tc.want {
+			t.Errorf("%v.contradicts(0x%X) = %t, want %t", tc.status, tc.mode, got, tc.want)
+		}
+	}
+}
+
+func TestMonitorWarnsWhenModeDoesNotTakeEffect(t *testing.T) {
+	device := monitortest.NewFakeEIBS7()
+	battery := echonetlite.NewEOJ(0x02, 0x7D, 0x01)
+	now := monitortest.Now
+	m := newTestMonitor(device, now.Add(2*time.Hour), now.Add(3*time.Hour), func(c *config.Config) {
+		c.DischargeTimes = config.TimeSlot{StartTime: now.Add(4 * time.Hour).Format("15:04"), EndTime: now.Add(5 * time.Hour).Format("15:04")}
+	})
+
+	m.runCycle() // switches to standby mode
+	u := m.batteries[0]
+	if u.lastSetMode != 0x44 {
+		t.Fatalf("lastSetMode = 0x%X, want standby", u.lastSetMode)
+	}
+	if u.status != 0x42 {
+		t.Errorf("status = %v, want the polled charging status", u.status)
+	}
+
+	// The battery accepts standby mode but keeps discharging.
+	device.Props[battery][0xDA] = []byte{0x44}
+	device.Props[battery][0xCF] = []byte{0x43}
+	u.lastSetAt = now.Add(-time.Minute)
+	m.runCycle()
+	if !u.statusMismatch {
+		t.Error("statusMismatch = false while discharging in standby mode")
+	}
+
+	device.Props[battery][0xCF] = []byte{0x44}
+	m.runCycle()
+	if u.statusMismatch {
+		t.Error("statusMismatch = true after the battery went to standby")
+	}
+}